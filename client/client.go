@@ -0,0 +1,179 @@
+// Package client is a Go client for the meds-bot HTTP API described at /api/openapi.json.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sample is a dose event
+type Sample struct {
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	Status        string `json:"status"`
+	ScheduledDate string `json:"scheduledDate"`
+	StartDate     string `json:"startDate,omitempty"`
+	EndDate       string `json:"endDate,omitempty"`
+	Value         int    `json:"value"`
+	Unit          string `json:"unit"`
+}
+
+// Contact is a prescriber or pharmacy
+type Contact struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Email   string `json:"email"`
+	Address string `json:"address"`
+}
+
+// Medication is a medication's details with its linked contacts
+type Medication struct {
+	Name         string   `json:"name"`
+	Dose         string   `json:"dose"`
+	Instructions string   `json:"instructions"`
+	StartDate    string   `json:"startDate"`
+	RefillStatus string   `json:"refillStatus"`
+	LeafletURL   string   `json:"leafletUrl"`
+	Prescriber   *Contact `json:"prescriber,omitempty"`
+	Pharmacy     *Contact `json:"pharmacy,omitempty"`
+}
+
+// Refill is a logged refill with its cost and copay
+type Refill struct {
+	Medication string  `json:"medication"`
+	Date       string  `json:"date"`
+	Cost       float64 `json:"cost"`
+	Copay      float64 `json:"copay"`
+}
+
+// DosesParams filters the exported dose events. Zero values use the API defaults.
+type DosesParams struct {
+	Since      time.Time
+	Days       int
+	Medication string
+}
+
+// Error is a non-successful API response
+type Error struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is set when the request was rate limited
+	RetryAfter time.Duration
+}
+
+// Error returns the status code and message of the response
+func (e *Error) Error() string {
+	return fmt.Sprintf("meds-bot API error %d: %s", e.StatusCode, e.Message)
+}
+
+// Client calls the meds-bot HTTP API
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// New creates a client for the API at baseURL, authenticated with the bot's export token.
+// httpClient may be nil to use http.DefaultClient.
+func New(baseURL, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+// ListDoses returns dose events, oldest first
+func (c *Client) ListDoses(ctx context.Context, params DosesParams) ([]Sample, error) {
+	query := url.Values{"format": {"json"}}
+	if !params.Since.IsZero() {
+		query.Set("since", params.Since.Format("2006-01-02"))
+	}
+	if params.Days > 0 {
+		query.Set("days", strconv.Itoa(params.Days))
+	}
+	if params.Medication != "" {
+		query.Set("medication", params.Medication)
+	}
+
+	var response struct {
+		Samples []Sample `json:"samples"`
+	}
+	if err := c.get(ctx, "/export/doses", query, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Samples, nil
+}
+
+// ListMedications returns each medication's details with its prescriber and pharmacy contacts
+func (c *Client) ListMedications(ctx context.Context) ([]Medication, error) {
+	var response struct {
+		Medications []Medication `json:"medications"`
+	}
+	if err := c.get(ctx, "/export/medications", url.Values{"format": {"json"}}, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Medications, nil
+}
+
+// ListRefills returns a year's refills with their costs, or this year's if year is 0
+func (c *Client) ListRefills(ctx context.Context, year int) ([]Refill, error) {
+	query := url.Values{"format": {"json"}}
+	if year != 0 {
+		query.Set("year", strconv.Itoa(year))
+	}
+
+	var response struct {
+		Refills []Refill `json:"refills"`
+	}
+	if err := c.get(ctx, "/export/refills", query, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Refills, nil
+}
+
+// get makes an authenticated GET request and decodes the JSON response into out
+func (c *Client) get(ctx context.Context, path string, query url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return apiErr
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+
+	return nil
+}
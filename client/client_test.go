@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"meds-bot/internal/db"
+	"meds-bot/internal/export"
+)
+
+// TestClient tests the client against the export handlers
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "test_client.db"
+	defer os.Remove(dbPath)
+
+	store, err := db.NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	reminder, err := store.GetTodayReminder(ctx, "Med1")
+	if err != nil {
+		t.Fatalf("Failed to create reminder: %v", err)
+	}
+	if err := store.RecordAcknowledgment(ctx, reminder.ID, reminder.Version, "", "", time.Time{}); err != nil {
+		t.Fatalf("Failed to acknowledge reminder: %v", err)
+	}
+
+	if err := store.SaveMedicationInfo(ctx, &db.MedicationInfo{Name: "Med1", Dose: "5mg", PillsRemaining: db.UntrackedPills}); err != nil {
+		t.Fatalf("Failed to save medication info: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(export.DosesPath, export.NewHandler(store, "secret", time.UTC))
+	mux.Handle(export.MedicationsPath, export.NewMedicationsHandler(store, "secret"))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New(server.URL, "secret", nil)
+
+	doses, err := client.ListDoses(ctx, DosesParams{Medication: "Med1"})
+	if err != nil {
+		t.Fatalf("Failed to list doses: %v", err)
+	}
+	if len(doses) != 1 || doses[0].Status != "taken" {
+		t.Errorf("Unexpected doses: %+v", doses)
+	}
+
+	medications, err := client.ListMedications(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list medications: %v", err)
+	}
+	if len(medications) != 1 || medications[0].Dose != "5mg" {
+		t.Errorf("Unexpected medications: %+v", medications)
+	}
+
+	_, err = New(server.URL, "wrong", nil).ListDoses(ctx, DosesParams{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected an unauthorized error, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+// Package ackhook serves an inbound webhook that marks a dose as taken without Discord, for automations
+// such as a smart pillbox, an NFC tag or an IFTTT or Zapier applet. Requests are authenticated with a
+// bearer token in the Authorization header.
+package ackhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"meds-bot/internal/db"
+	"meds-bot/internal/export"
+)
+
+// Path is the HTTP path the webhook is served from
+const Path = "/api/hooks/ack"
+
+// Source is where doses marked as taken by the webhook are recorded as taken from
+const Source = "webhook"
+
+// maxClockSkew is how far in the future a request's timestamp can be, for devices whose clocks run fast
+const maxClockSkew = 5 * time.Minute
+
+// maxBodyBytes is the largest request body read
+const maxBodyBytes = 1 << 16
+
+// Acknowledger marks today's dose of a medication as taken at the time it was taken
+type Acknowledger interface {
+	AcknowledgeMedicationAt(ctx context.Context, medicationName, source string, takenAt time.Time) (bool, error)
+}
+
+// Request is the body of a request to the webhook, as JSON or a form
+type Request struct {
+	Medication string `json:"medication"`
+	// Timestamp is when the dose was taken, as RFC 3339 or Unix seconds. It defaults to now, and must be
+	// today, since only today's dose can be marked as taken.
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
+// Response is the JSON body of a successful request
+type Response struct {
+	Medication string `json:"medication"`
+	// Date is the date (YYYY-MM-DD) of the dose marked as taken
+	Date string `json:"date"`
+	// AlreadyTaken is true if the dose had already been marked as taken
+	AlreadyTaken bool `json:"already_taken"`
+}
+
+// Handler serves the inbound acknowledgment webhook
+type Handler struct {
+	token        string
+	acknowledger Acknowledger
+	location     *time.Location
+	now          func() time.Time
+}
+
+// NewHandler creates a handler accepting requests authenticated with the token
+func NewHandler(token string, acknowledger Acknowledger, location *time.Location) *Handler {
+	return &Handler{
+		token:        token,
+		acknowledger: acknowledger,
+		location:     location,
+		now:          time.Now,
+	}
+}
+
+// ServeHTTP marks the requested medication's dose as taken
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !export.HeaderAuthorized(r, h.token) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	req, err := decodeRequest(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Medication == "" {
+		http.Error(w, "medication is required", http.StatusBadRequest)
+		return
+	}
+
+	now := h.now().In(h.location)
+	takenAt := now
+	if req.Timestamp != "" {
+		takenAt, err = parseTimestamp(req.Timestamp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		takenAt = takenAt.In(h.location)
+	}
+	if takenAt.After(now.Add(maxClockSkew)) {
+		http.Error(w, "timestamp is in the future", http.StatusUnprocessableEntity)
+		return
+	}
+	// A dose from an earlier day was either missed or already recorded
+	if date := takenAt.Format("2006-01-02"); date != now.Format("2006-01-02") {
+		http.Error(w, fmt.Sprintf("timestamp is on %s, but only today's dose can be marked as taken", date), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// The dose is recorded as taken when the request says, which may be a while before it was sent
+	source := fmt.Sprintf("%s (taken at %s)", Source, takenAt.Format("15:04"))
+	alreadyTaken, err := h.acknowledger.AcknowledgeMedicationAt(r.Context(), req.Medication, source, takenAt)
+	if errors.Is(err, db.ErrMedicationInactive) {
+		http.Error(w, fmt.Sprintf("%s isn't a scheduled medication", req.Medication), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, db.ErrConfirmationRequired) {
+		http.Error(w, fmt.Sprintf("%s needs confirming by others in Discord before it's recorded", req.Medication), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, db.ErrPhotoRequired) {
+		http.Error(w, fmt.Sprintf("%s needs a photo added in Discord before it's recorded", req.Medication), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error acknowledging %s via webhook: %v", req.Medication, err)
+		http.Error(w, "Failed to record the dose, please try again", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("%s marked as taken via webhook, taken at %s", req.Medication, takenAt.Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Response{
+		Medication:   req.Medication,
+		Date:         now.Format("2006-01-02"),
+		AlreadyTaken: alreadyTaken,
+	}); err != nil {
+		log.Printf("Error writing webhook response: %v", err)
+	}
+}
+
+// decodeRequest reads a request from a JSON body, or from form values for services that send forms
+func decodeRequest(w http.ResponseWriter, r *http.Request) (Request, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return Request{}, fmt.Errorf("invalid JSON body: %w", err)
+		}
+		return req, nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return Request{}, fmt.Errorf("invalid form body: %w", err)
+	}
+	return Request{Medication: r.Form.Get("medication"), Timestamp: r.Form.Get("timestamp")}, nil
+}
+
+// parseTimestamp parses an RFC 3339 timestamp or Unix seconds
+func parseTimestamp(timestamp string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp must be RFC 3339 (e.g. 2024-05-01T08:00:00Z) or Unix seconds")
+	}
+	return t, nil
+}
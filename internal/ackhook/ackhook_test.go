@@ -0,0 +1,104 @@
+package ackhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"meds-bot/internal/db"
+)
+
+type fakeAcknowledger struct {
+	taken   map[string]bool
+	sources []string
+	times   []time.Time
+}
+
+func (f *fakeAcknowledger) AcknowledgeMedicationAt(ctx context.Context, medicationName, source string, takenAt time.Time) (bool, error) {
+	if medicationName == "Retired Pill" {
+		return false, db.ErrMedicationInactive
+	}
+	if medicationName == "Insulin" {
+		return false, db.ErrConfirmationRequired
+	}
+	if medicationName == "Supervised Pill" {
+		return false, db.ErrPhotoRequired
+	}
+	f.sources = append(f.sources, source)
+	f.times = append(f.times, takenAt)
+	alreadyTaken := f.taken[medicationName]
+	f.taken[medicationName] = true
+	return alreadyTaken, nil
+}
+
+func TestServeHTTP(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	acknowledger := &fakeAcknowledger{taken: map[string]bool{}}
+	handler := NewHandler("hook-token", acknowledger, time.UTC)
+	handler.now = func() time.Time { return now }
+
+	tests := []struct {
+		name        string
+		token       string
+		query       string
+		contentType string
+		body        string
+		status      int
+	}{
+		{name: "Missing token", token: "", contentType: "application/json", body: `{"medication":"Morning Pill"}`, status: http.StatusUnauthorized},
+		{name: "Token in the URL", query: "?token=hook-token", contentType: "application/json", body: `{"medication":"Morning Pill"}`, status: http.StatusUnauthorized},
+		{name: "Wrong token", token: "other", contentType: "application/json", body: `{"medication":"Morning Pill"}`, status: http.StatusUnauthorized},
+		{name: "Missing medication", token: "hook-token", contentType: "application/json", body: `{}`, status: http.StatusBadRequest},
+		{name: "Invalid timestamp", token: "hook-token", contentType: "application/json", body: `{"medication":"Morning Pill","timestamp":"yesterday"}`, status: http.StatusBadRequest},
+		{name: "Earlier day", token: "hook-token", contentType: "application/json", body: `{"medication":"Morning Pill","timestamp":"2024-04-30T08:00:00Z"}`, status: http.StatusUnprocessableEntity},
+		{name: "Future", token: "hook-token", contentType: "application/json", body: `{"medication":"Morning Pill","timestamp":"2024-05-01T10:00:00Z"}`, status: http.StatusUnprocessableEntity},
+		{name: "Unknown medication", token: "hook-token", contentType: "application/json", body: `{"medication":"Retired Pill"}`, status: http.StatusNotFound},
+		{name: "Needs confirming", token: "hook-token", contentType: "application/json", body: `{"medication":"Insulin"}`, status: http.StatusConflict},
+		{name: "Needs a photo", token: "hook-token", contentType: "application/json", body: `{"medication":"Supervised Pill"}`, status: http.StatusConflict},
+		{name: "JSON", token: "hook-token", contentType: "application/json", body: `{"medication":"Morning Pill","timestamp":"2024-05-01T08:02:00Z"}`, status: http.StatusOK},
+		{name: "Form", token: "hook-token", contentType: "application/x-www-form-urlencoded", body: url.Values{"medication": {"Evening Pill"}, "timestamp": {"1714550400"}}.Encode(), status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, Path+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("Status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+
+	if len(acknowledger.sources) != 2 || acknowledger.sources[0] != "webhook (taken at 08:02)" || acknowledger.sources[1] != "webhook (taken at 08:00)" {
+		t.Errorf("Unexpected sources: %v", acknowledger.sources)
+	}
+	if len(acknowledger.times) != 2 || !acknowledger.times[0].Equal(time.Date(2024, 5, 1, 8, 2, 0, 0, time.UTC)) {
+		t.Errorf("Expected the dose to be recorded as taken at the timestamp, got %v", acknowledger.times)
+	}
+
+	// Marking a dose as taken again says it already was
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(`{"medication":"Morning Pill"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer hook-token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var resp Response
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !resp.AlreadyTaken || resp.Date != "2024-05-01" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+}
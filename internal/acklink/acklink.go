@@ -0,0 +1,217 @@
+package acklink
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"meds-bot/internal/db"
+)
+
+var (
+	// ErrInvalidSignature is returned when a link's signature doesn't match its contents
+	ErrInvalidSignature = errors.New("invalid acknowledgment link signature")
+	// ErrExpired is returned when a link is used after its expiry time
+	ErrExpired = errors.New("acknowledgment link has expired")
+	// ErrMalformed is returned when a link is missing required parameters
+	ErrMalformed = errors.New("malformed acknowledgment link")
+)
+
+// Path is the HTTP path acknowledgment links are served from
+const Path = "/ack"
+
+// Acknowledger marks a medication dose as taken
+type Acknowledger interface {
+	AcknowledgeMedication(ctx context.Context, medicationName, source string) (bool, error)
+}
+
+// Claims identifies the dose an acknowledgment link is for
+type Claims struct {
+	Medication string
+	Date       string
+	Expires    time.Time
+}
+
+// Signer creates and verifies signed acknowledgment links
+type Signer struct {
+	baseURL string
+	secret  []byte
+	ttl     time.Duration
+}
+
+// NewSigner creates a new link signer for links served under baseURL
+func NewSigner(baseURL, secret string, ttl time.Duration) *Signer {
+	return &Signer{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  []byte(secret),
+		ttl:     ttl,
+	}
+}
+
+// URL returns a signed acknowledgment URL for a medication's dose on the given date
+func (s *Signer) URL(medication, date string, now time.Time) string {
+	expires := now.Add(s.ttl).Unix()
+
+	params := url.Values{}
+	params.Set("med", medication)
+	params.Set("date", date)
+	params.Set("exp", strconv.FormatInt(expires, 10))
+	params.Set("sig", s.sign(medication, date, expires))
+
+	return s.baseURL + Path + "?" + params.Encode()
+}
+
+// Verify checks the signature and expiry of an acknowledgment link's query parameters
+func (s *Signer) Verify(params url.Values, now time.Time) (*Claims, error) {
+	medication := params.Get("med")
+	date := params.Get("date")
+	sig := params.Get("sig")
+	if medication == "" || date == "" || sig == "" {
+		return nil, ErrMalformed
+	}
+
+	expires, err := strconv.ParseInt(params.Get("exp"), 10, 64)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(s.sign(medication, date, expires))) {
+		return nil, ErrInvalidSignature
+	}
+
+	if now.Unix() > expires {
+		return nil, ErrExpired
+	}
+
+	return &Claims{
+		Medication: medication,
+		Date:       date,
+		Expires:    time.Unix(expires, 0),
+	}, nil
+}
+
+// sign computes the signature for a link's contents
+func (s *Signer) sign(medication, date string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%d", medication, date, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Handler serves acknowledgment links
+type Handler struct {
+	signer       *Signer
+	acknowledger Acknowledger
+	calendar     *db.Calendar
+}
+
+// NewHandler creates a new HTTP handler for acknowledgment links, which are only valid on the date of
+// the dose in the calendar
+func NewHandler(signer *Signer, acknowledger Acknowledger, calendar *db.Calendar) *Handler {
+	if calendar == nil {
+		calendar = db.NewCalendar(time.UTC)
+	}
+
+	return &Handler{
+		signer:       signer,
+		acknowledger: acknowledger,
+		calendar:     calendar,
+	}
+}
+
+// confirmPage asks for a dose to be marked as taken. Following a link only shows this page, and the
+// dose is recorded by its form, so link previews and prefetching can't mark a dose as taken.
+var confirmPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Take {{.Medication}}</title>
+</head>
+<body>
+<p>Mark your {{.Medication}} as taken?</p>
+<form method="post" action="{{.Action}}">
+<button type="submit">I've taken it</button>
+</form>
+</body>
+</html>
+`))
+
+// ServeHTTP shows a page asking to confirm the dose identified by a signed link on GET, and
+// acknowledges it on POST
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+
+	claims, err := h.signer.Verify(r.URL.Query(), now)
+	if err != nil {
+		log.Printf("Rejected acknowledgment link: %v", err)
+		if errors.Is(err, ErrExpired) {
+			http.Error(w, "This acknowledgment link has expired.", http.StatusGone)
+			return
+		}
+		http.Error(w, "This acknowledgment link is not valid.", http.StatusForbidden)
+		return
+	}
+
+	// Links only acknowledge the dose they were issued for
+	if claims.Date != h.calendar.Date(claims.Medication, now) {
+		http.Error(w, "This acknowledgment link is for a different day.", http.StatusGone)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		err := confirmPage.Execute(w, struct{ Medication, Action string }{
+			Medication: claims.Medication,
+			Action:     "?" + r.URL.RawQuery,
+		})
+		if err != nil {
+			log.Printf("Error rendering acknowledgment page: %v", err)
+		}
+		return
+	}
+
+	alreadyTaken, err := h.acknowledger.AcknowledgeMedication(r.Context(), claims.Medication, "acknowledgment link")
+	if errors.Is(err, db.ErrMedicationInactive) {
+		http.Error(w, fmt.Sprintf("%s is no longer scheduled.", claims.Medication), http.StatusGone)
+		return
+	}
+	if errors.Is(err, db.ErrConfirmationRequired) {
+		http.Error(w, fmt.Sprintf("%s needs confirming by others in Discord before it's recorded.", claims.Medication), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, db.ErrPhotoRequired) {
+		http.Error(w, fmt.Sprintf("%s needs a photo added in Discord before it's recorded.", claims.Medication), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error acknowledging %s via link: %v", claims.Medication, err)
+		http.Error(w, "Failed to record your dose, please try again.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if alreadyTaken {
+		fmt.Fprintf(w, "You've already acknowledged taking your %s today. Thank you!", claims.Medication)
+		return
+	}
+	fmt.Fprintf(w, "Thank you for taking your %s! Your response has been recorded.", claims.Medication)
+}
@@ -0,0 +1,171 @@
+package acklink
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signer := NewSigner("https://meds.example.com/", "test-secret", time.Hour)
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	link, err := url.Parse(signer.URL("Morning Pill", "2024-05-01", now))
+	if err != nil {
+		t.Fatalf("Failed to parse link: %v", err)
+	}
+
+	if link.Host != "meds.example.com" || link.Path != Path {
+		t.Errorf("Unexpected link location: %s", link)
+	}
+
+	// Test case: A fresh link verifies
+	claims, err := signer.Verify(link.Query(), now.Add(30*time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to verify link: %v", err)
+	}
+	if claims.Medication != "Morning Pill" || claims.Date != "2024-05-01" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	// Test case: The link expires after the TTL
+	if _, err := signer.Verify(link.Query(), now.Add(2*time.Hour)); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+
+	// Test case: Changing the medication invalidates the signature
+	tampered := link.Query()
+	tampered.Set("med", "Evening Pill")
+	if _, err := signer.Verify(tampered, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+
+	// Test case: Extending the expiry invalidates the signature
+	tampered = link.Query()
+	tampered.Set("exp", "9999999999")
+	if _, err := signer.Verify(tampered, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+
+	// Test case: A link signed with another secret is rejected
+	other := NewSigner("https://meds.example.com", "other-secret", time.Hour)
+	if _, err := other.Verify(link.Query(), now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+
+	// Test case: Missing parameters are rejected
+	if _, err := signer.Verify(url.Values{}, now); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Expected ErrMalformed, got %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	signer := NewSigner("https://meds.example.com", "test-secret", time.Hour)
+	acknowledger := &fakeAcknowledger{}
+	handler := NewHandler(signer, acknowledger, nil)
+
+	now := time.Now()
+	link := signer.URL("Morning Pill", now.UTC().Format("2006-01-02"), now)
+
+	visit := func(method string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, link, nil))
+		return rec
+	}
+
+	// Test case: Following a link shows a page to confirm the dose, without acknowledging it
+	rec := visit(http.MethodGet)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the confirmation page, got status %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `<form method="post"`) {
+		t.Errorf("Expected the page to post a form, got %s", rec.Body.String())
+	}
+	if len(acknowledger.acknowledged) != 0 {
+		t.Errorf("Expected a GET to leave the dose unacknowledged, got %v", acknowledger.acknowledged)
+	}
+
+	// Test case: Posting the form acknowledges the dose
+	if rec := visit(http.MethodPost); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the dose to be acknowledged, got status %d", rec.Code)
+	}
+	if len(acknowledger.acknowledged) != 1 || acknowledger.acknowledged[0] != "Morning Pill" {
+		t.Errorf("Expected the dose to be acknowledged once, got %v", acknowledger.acknowledged)
+	}
+
+	// Test case: Other methods aren't allowed
+	if rec := visit(http.MethodPut); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected a PUT to be refused, got status %d", rec.Code)
+	}
+	if len(acknowledger.acknowledged) != 1 {
+		t.Errorf("Expected a PUT to leave the dose alone, got %v", acknowledger.acknowledged)
+	}
+}
+
+type fakeAcknowledger struct {
+	acknowledged []string
+}
+
+func (f *fakeAcknowledger) AcknowledgeMedication(ctx context.Context, medicationName, source string) (bool, error) {
+	f.acknowledged = append(f.acknowledged, medicationName)
+	return false, nil
+}
+
+func TestTagLinks(t *testing.T) {
+	ctx := context.Background()
+	signer := NewSigner("https://meds.example.com", "test-secret", time.Hour)
+	store := db.NewMemoryStore(time.UTC)
+	acknowledger := &fakeAcknowledger{}
+	handler := NewTagHandler(signer, store, []config.Medication{
+		{Name: "Metformin 08:00", Hour: 8, Frequency: "daily", BaseName: "Metformin"},
+		{Name: "Metformin 20:00", Hour: 20, Frequency: "daily", BaseName: "Metformin"},
+		{Name: "Methotrexate", Hour: 9, Frequency: "weekly", Day: "friday"},
+	}, acknowledger, time.UTC)
+	// Wednesday evening
+	handler.now = func() time.Time { return time.Date(2024, 5, 1, 19, 30, 0, 0, time.UTC) }
+
+	visit := func(link string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link, nil))
+		return rec.Code
+	}
+
+	link := signer.TagURL("Metformin", 0)
+	// Test case: A tag for a medication taken twice a day marks the dose due closest to now
+	if code := visit(link); code != http.StatusOK {
+		t.Fatalf("Expected the tag link to work, got status %d", code)
+	}
+	if len(acknowledger.acknowledged) != 1 || acknowledger.acknowledged[0] != "Metformin 20:00" {
+		t.Errorf("Expected the evening dose to be marked as taken, got %v", acknowledger.acknowledged)
+	}
+
+	// Test case: A medication that isn't due today isn't marked as taken
+	if code := visit(signer.TagURL("Methotrexate", 0)); code != http.StatusConflict {
+		t.Errorf("Expected a conflict for a medication not due today, got status %d", code)
+	}
+
+	// Test case: A tampered tag link is rejected
+	if code := visit(signer.TagURL("Metformin", 0) + "0"); code != http.StatusForbidden {
+		t.Errorf("Expected a tampered link to be rejected, got status %d", code)
+	}
+
+	// Test case: Revoking a medication's tags stops its links working, and new ones are created for the next generation
+	generation, err := RevokeTags(ctx, store, "Metformin")
+	if err != nil {
+		t.Fatalf("RevokeTags failed: %v", err)
+	}
+	if code := visit(link); code != http.StatusGone {
+		t.Errorf("Expected a revoked link to be gone, got status %d", code)
+	}
+	if code := visit(signer.TagURL("Metformin", generation)); code != http.StatusOK {
+		t.Errorf("Expected a link for the new generation to work, got status %d", code)
+	}
+}
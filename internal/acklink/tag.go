@@ -0,0 +1,209 @@
+package acklink
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+)
+
+// TagPath is the HTTP path tag links are served from
+const TagPath = "/ack/tag"
+
+// ErrRevoked is returned when a tag link has been revoked
+var ErrRevoked = errors.New("acknowledgment tag link has been revoked")
+
+// tagGenerationStateKeyPrefix prefixes the state keys each medication's tag link generation is stored under
+const tagGenerationStateKeyPrefix = "ack_tag_generation:"
+
+// StateStore stores bot state
+type StateStore interface {
+	GetState(ctx context.Context, key string) (string, error)
+	SetState(ctx context.Context, key, value string) error
+}
+
+// TagURL returns a signed link that marks today's dose of a medication as taken whenever it's visited,
+// for an NFC tag on the pill bottle or a phone shortcut. Unlike acknowledgment links it doesn't expire,
+// and it works until the medication's tag links are revoked, which moves them on to the next generation.
+func (s *Signer) TagURL(medication string, generation int) string {
+	params := url.Values{}
+	params.Set("med", medication)
+	params.Set("gen", strconv.Itoa(generation))
+	params.Set("sig", s.signTag(medication, generation))
+
+	return s.baseURL + TagPath + "?" + params.Encode()
+}
+
+// VerifyTag checks the signature of a tag link's query parameters, returning its medication and generation
+func (s *Signer) VerifyTag(params url.Values) (string, int, error) {
+	medication := params.Get("med")
+	sig := params.Get("sig")
+	if medication == "" || sig == "" {
+		return "", 0, ErrMalformed
+	}
+
+	generation, err := strconv.Atoi(params.Get("gen"))
+	if err != nil {
+		return "", 0, ErrMalformed
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(s.signTag(medication, generation))) {
+		return "", 0, ErrInvalidSignature
+	}
+
+	return medication, generation, nil
+}
+
+// signTag computes the signature of a tag link's contents
+func (s *Signer) signTag(medication string, generation int) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "tag\n%s\n%d", medication, generation)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// TagGeneration returns the generation of a medication's tag links that's currently accepted
+func TagGeneration(ctx context.Context, store StateStore, medication string) (int, error) {
+	value, err := store.GetState(ctx, tagGenerationStateKeyPrefix+medication)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get tag link generation: %w", err)
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	generation, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse tag link generation: %w", err)
+	}
+	return generation, nil
+}
+
+// RevokeTags revokes every tag link of a medication, returning the generation new links are created for
+func RevokeTags(ctx context.Context, store StateStore, medication string) (int, error) {
+	generation, err := TagGeneration(ctx, store, medication)
+	if err != nil {
+		return 0, err
+	}
+
+	generation++
+	if err := store.SetState(ctx, tagGenerationStateKeyPrefix+medication, strconv.Itoa(generation)); err != nil {
+		return 0, fmt.Errorf("failed to save tag link generation: %w", err)
+	}
+	return generation, nil
+}
+
+// TagHandler serves tag links
+type TagHandler struct {
+	signer       *Signer
+	store        StateStore
+	medications  []config.Medication
+	acknowledger Acknowledger
+	location     *time.Location
+	now          func() time.Time
+}
+
+// NewTagHandler creates a new HTTP handler for tag links
+func NewTagHandler(signer *Signer, store StateStore, medications []config.Medication, acknowledger Acknowledger, location *time.Location) *TagHandler {
+	if location == nil {
+		location = time.UTC
+	}
+
+	return &TagHandler{
+		signer:       signer,
+		store:        store,
+		medications:  medications,
+		acknowledger: acknowledger,
+		location:     location,
+		now:          time.Now,
+	}
+}
+
+// ServeHTTP marks today's dose of the medication a tag link is for as taken
+func (h *TagHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, generation, err := h.signer.VerifyTag(r.URL.Query())
+	if err != nil {
+		log.Printf("Rejected acknowledgment tag link: %v", err)
+		http.Error(w, "This tag link is not valid.", http.StatusForbidden)
+		return
+	}
+
+	current, err := TagGeneration(r.Context(), h.store, name)
+	if err != nil {
+		log.Printf("Error checking tag link for %s: %v", name, err)
+		http.Error(w, "Failed to record your dose, please try again.", http.StatusInternalServerError)
+		return
+	}
+	if generation != current {
+		log.Printf("Rejected acknowledgment tag link for %s: %v", name, ErrRevoked)
+		http.Error(w, "This tag link has been revoked.", http.StatusGone)
+		return
+	}
+
+	now := h.now().In(h.location)
+	medication, ok := h.doseDue(name, now)
+	if !ok {
+		http.Error(w, fmt.Sprintf("%s isn't due today.", name), http.StatusConflict)
+		return
+	}
+
+	alreadyTaken, err := h.acknowledger.AcknowledgeMedication(r.Context(), medication, "NFC tag or shortcut")
+	if errors.Is(err, db.ErrMedicationInactive) {
+		http.Error(w, fmt.Sprintf("%s is no longer scheduled.", medication), http.StatusGone)
+		return
+	}
+	if errors.Is(err, db.ErrConfirmationRequired) {
+		http.Error(w, fmt.Sprintf("%s needs confirming by others in Discord before it's recorded.", medication), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, db.ErrPhotoRequired) {
+		http.Error(w, fmt.Sprintf("%s needs a photo added in Discord before it's recorded.", medication), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error acknowledging %s via tag link: %v", medication, err)
+		http.Error(w, "Failed to record your dose, please try again.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if alreadyTaken {
+		fmt.Fprintf(w, "You've already acknowledged taking your %s today. Thank you!", medication)
+		return
+	}
+	fmt.Fprintf(w, "Thank you for taking your %s! Your response has been recorded.", medication)
+}
+
+// doseDue returns the name of the dose a tag link for a medication marks as taken now. A medication
+// taken more than once a day has a tag for all of its doses, which marks the one due closest to now.
+func (h *TagHandler) doseDue(name string, now time.Time) (string, bool) {
+	var due string
+	var closest time.Duration
+	for _, medication := range h.medications {
+		local := medication.In(now)
+		if (medication.Name != name && medication.Base() != name) || !medication.IsScheduledOn(local) {
+			continue
+		}
+
+		distance := now.Sub(medication.DueAt(local)).Abs()
+		if due == "" || distance < closest {
+			due, closest = medication.Name, distance
+		}
+	}
+	return due, due != ""
+}
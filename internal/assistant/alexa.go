@@ -0,0 +1,158 @@
+package assistant
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// alexaTimestampTolerance is how far a request's timestamp can be from now, which Alexa requires skills
+// to check so a recorded request can't be replayed later
+const alexaTimestampTolerance = 150 * time.Second
+
+// alexaCertName is the name Alexa's signing certificate is issued for
+const alexaCertName = "echo-api.amazon.com"
+
+// maxCertChainBytes is the largest certificate chain downloaded
+const maxCertChainBytes = 1 << 16
+
+// alexaVerifier checks that requests were signed by Alexa, as described in "Host a custom skill as a web
+// service" in the Alexa Skills Kit documentation. Signing certificates are downloaded the first time
+// they're used and kept until they expire.
+type alexaVerifier struct {
+	// roots are the certificate authorities trusted to issue the signing certificate, nil for the system's
+	roots *x509.CertPool
+	// fetch downloads a certificate chain
+	fetch func(certURL string) ([]byte, error)
+
+	mu    sync.Mutex
+	certs map[string]*x509.Certificate
+}
+
+// newAlexaVerifier creates a verifier that downloads certificates with the client
+func newAlexaVerifier(client *http.Client) *alexaVerifier {
+	return &alexaVerifier{
+		fetch: func(certURL string) ([]byte, error) {
+			resp, err := client.Get(certURL)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("certificate chain returned %s", resp.Status)
+			}
+			return io.ReadAll(io.LimitReader(resp.Body, maxCertChainBytes))
+		},
+		certs: make(map[string]*x509.Certificate),
+	}
+}
+
+// verify checks a request's signature of its body, at now
+func (v *alexaVerifier) verify(r *http.Request, body []byte, now time.Time) error {
+	certURL := r.Header.Get("SignatureCertChainUrl")
+	if err := checkCertURL(certURL); err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(r.Header.Get("Signature-256"))
+	if err != nil || len(signature) == 0 {
+		return errors.New("missing or malformed Signature-256 header")
+	}
+
+	cert, err := v.certificate(certURL, now)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("signing certificate doesn't have an RSA key")
+	}
+
+	digest := sha256.Sum256(body)
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	return nil
+}
+
+// certificate returns the signing certificate at a URL, once its chain has been verified
+func (v *alexaVerifier) certificate(certURL string, now time.Time) (*x509.Certificate, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if cert, ok := v.certs[certURL]; ok && now.Before(cert.NotAfter) {
+		return cert, nil
+	}
+
+	chain, err := v.fetch(certURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download signing certificate: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(chain); block != nil; block, rest = pem.Decode(rest) {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no signing certificate found")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		DNSName:       alexaCertName,
+		CurrentTime:   now,
+	}); err != nil {
+		return nil, fmt.Errorf("untrusted signing certificate: %w", err)
+	}
+
+	v.certs[certURL] = certs[0]
+	return certs[0], nil
+}
+
+// checkCertURL checks that a signing certificate is downloaded from where Alexa keeps them
+func checkCertURL(certURL string) error {
+	u, err := url.Parse(certURL)
+	if err != nil || certURL == "" {
+		return errors.New("missing or malformed SignatureCertChainUrl header")
+	}
+
+	if !strings.EqualFold(u.Scheme, "https") || !strings.EqualFold(u.Hostname(), "s3.amazonaws.com") ||
+		(u.Port() != "" && u.Port() != "443") || !strings.HasPrefix(path.Clean(u.Path), "/echo.api/") {
+		return fmt.Errorf("signing certificate isn't from Alexa: %s", certURL)
+	}
+	return nil
+}
+
+// checkTimestamp checks that a request was made recently
+func checkTimestamp(timestamp string, now time.Time) error {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return fmt.Errorf("missing or malformed timestamp %q", timestamp)
+	}
+	if gap := now.Sub(t).Abs(); gap > alexaTimestampTolerance {
+		return fmt.Errorf("timestamp %s is %s from now", timestamp, gap.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+// Package assistant serves a fulfillment endpoint for voice assistants, so "Alexa, tell meds bot I took
+// my morning pills" marks the pending doses as taken. It answers Alexa custom skill requests and Dialogflow
+// webhook requests, used by Google Assistant actions, and is authenticated with a token. Alexa requests
+// must also carry Alexa's signature and a recent timestamp.
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/export"
+)
+
+// Path is the HTTP path the fulfillment endpoint is served from
+const Path = "/api/assistant"
+
+// IntentTakeMedication is the name of the intent that marks doses as taken. Its optional medication
+// and time_of_day slots (parameters in Dialogflow) narrow down which pending doses it marks.
+const IntentTakeMedication = "TakeMedication"
+
+// earlyWindow is how long before a dose is due saying it was taken marks it as taken, when the
+// medication or time of day isn't named
+const earlyWindow = time.Hour
+
+// maxBodyBytes is the largest request body read
+const maxBodyBytes = 1 << 16
+
+// Acknowledger marks today's dose of a medication as taken
+type Acknowledger interface {
+	AcknowledgeMedication(ctx context.Context, medicationName, source string) (bool, error)
+}
+
+// ReminderStore gets today's reminder for a medication
+type ReminderStore interface {
+	GetTodayReminder(ctx context.Context, medicationType string) (*db.Reminder, error)
+}
+
+// Request is the part of an Alexa or Dialogflow request the endpoint reads
+type Request struct {
+	// Alexa custom skill requests
+	Alexa *struct {
+		Type      string `json:"type"`
+		Timestamp string `json:"timestamp"`
+		Intent    struct {
+			Name  string `json:"name"`
+			Slots map[string]struct {
+				Value string `json:"value"`
+			} `json:"slots"`
+		} `json:"intent"`
+	} `json:"request"`
+	// Dialogflow webhook requests
+	QueryResult *struct {
+		Intent struct {
+			DisplayName string `json:"displayName"`
+		} `json:"intent"`
+		Parameters map[string]any `json:"parameters"`
+	} `json:"queryResult"`
+}
+
+// alexaResponse is the response to an Alexa custom skill request
+type alexaResponse struct {
+	Version  string `json:"version"`
+	Response struct {
+		OutputSpeech *struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"outputSpeech,omitempty"`
+		ShouldEndSession bool `json:"shouldEndSession"`
+	} `json:"response"`
+}
+
+// dialogflowResponse is the response to a Dialogflow webhook request
+type dialogflowResponse struct {
+	FulfillmentText string `json:"fulfillmentText"`
+}
+
+// Handler serves the voice assistant fulfillment endpoint
+type Handler struct {
+	token        string
+	medications  []config.Medication
+	store        ReminderStore
+	acknowledger Acknowledger
+	location     *time.Location
+	now          func() time.Time
+	// alexa checks Alexa's signatures on its requests
+	alexa *alexaVerifier
+}
+
+// NewHandler creates a handler accepting requests authenticated with the token
+func NewHandler(token string, medications []config.Medication, store ReminderStore, acknowledger Acknowledger, location *time.Location) *Handler {
+	return &Handler{
+		token:        token,
+		medications:  medications,
+		store:        store,
+		acknowledger: acknowledger,
+		location:     location,
+		now:          time.Now,
+		alexa:        newAlexaVerifier(&http.Client{Timeout: 10 * time.Second}),
+	}
+}
+
+// ServeHTTP answers an Alexa or Dialogflow request with what was said to be taken
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !export.Authorized(r, h.token) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+	var req Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case req.Alexa != nil:
+		// The token is in the skill's URL, so Alexa requests must also be signed by Alexa, and recent
+		if err := h.alexa.verify(r, body, h.now()); err != nil {
+			log.Printf("Warning: Rejected Alexa request: %v", err)
+			http.Error(w, "Invalid Alexa signature", http.StatusBadRequest)
+			return
+		}
+		if err := checkTimestamp(req.Alexa.Timestamp, h.now()); err != nil {
+			log.Printf("Warning: Rejected Alexa request: %v", err)
+			http.Error(w, "Stale Alexa request", http.StatusBadRequest)
+			return
+		}
+		h.serveAlexa(r.Context(), w, &req)
+	case req.QueryResult != nil:
+		h.serveDialogflow(r.Context(), w, &req)
+	default:
+		http.Error(w, "expected an Alexa or Dialogflow request", http.StatusBadRequest)
+	}
+}
+
+// serveAlexa answers an Alexa custom skill request
+func (h *Handler) serveAlexa(ctx context.Context, w http.ResponseWriter, req *Request) {
+	var speech string
+	switch req.Alexa.Type {
+	case "LaunchRequest":
+		speech = "Tell me which medication you took, or say I took my morning pills."
+	case "IntentRequest":
+		if req.Alexa.Intent.Name != IntentTakeMedication {
+			speech = "I can only mark your medication as taken."
+			break
+		}
+		slots := req.Alexa.Intent.Slots
+		speech = h.takeMedication(ctx, slots["medication"].Value, slots["time_of_day"].Value, "Alexa")
+	default:
+		// Session ended requests can't be answered with speech
+	}
+
+	var resp alexaResponse
+	resp.Version = "1.0"
+	resp.Response.ShouldEndSession = true
+	if speech != "" {
+		resp.Response.OutputSpeech = &struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{Type: "PlainText", Text: speech}
+	}
+	writeJSON(w, resp)
+}
+
+// serveDialogflow answers a Dialogflow webhook request
+func (h *Handler) serveDialogflow(ctx context.Context, w http.ResponseWriter, req *Request) {
+	speech := "I can only mark your medication as taken."
+	if req.QueryResult.Intent.DisplayName == IntentTakeMedication {
+		medication, _ := req.QueryResult.Parameters["medication"].(string)
+		timeOfDay, _ := req.QueryResult.Parameters["time_of_day"].(string)
+		speech = h.takeMedication(ctx, medication, timeOfDay, "Google Assistant")
+	}
+	writeJSON(w, dialogflowResponse{FulfillmentText: speech})
+}
+
+// takeMedication marks the pending doses matching what was said as taken, returning what to say back
+func (h *Handler) takeMedication(ctx context.Context, name, timeOfDay, source string) string {
+	name, timeOfDay = strings.TrimSpace(name), strings.TrimSpace(timeOfDay)
+	// "My morning pills" is heard as a medication name by assistants that don't have a time of day slot
+	if timeOfDay == "" && !h.known(name) {
+		if _, ok := parseTimeOfDay(name); ok {
+			name, timeOfDay = "", name
+		}
+	}
+	period, ok := parseTimeOfDay(timeOfDay)
+	if timeOfDay != "" && !ok {
+		return fmt.Sprintf("I don't know when %s is. Try morning, afternoon, evening or night.", timeOfDay)
+	}
+
+	now := h.now().In(h.location)
+	if name != "" && !h.known(name) {
+		return fmt.Sprintf("I couldn't find a medication called %s.", name)
+	}
+
+	var taken, alreadyTaken, unconfirmed, unphotographed []string
+	for _, medication := range h.dosesDue(name, period, now) {
+		reminder, err := h.store.GetTodayReminder(ctx, medication)
+		if err != nil {
+			log.Printf("Error getting today's reminder for %s: %v", medication, err)
+			return "Sorry, I couldn't record your medication. Please try again."
+		}
+		if reminder.Acknowledged {
+			alreadyTaken = append(alreadyTaken, medication)
+			continue
+		}
+
+		already, err := h.acknowledger.AcknowledgeMedication(ctx, medication, source)
+		if errors.Is(err, db.ErrMedicationInactive) {
+			continue
+		}
+		if errors.Is(err, db.ErrConfirmationRequired) {
+			unconfirmed = append(unconfirmed, medication)
+			continue
+		}
+		if errors.Is(err, db.ErrPhotoRequired) {
+			unphotographed = append(unphotographed, medication)
+			continue
+		}
+		if err != nil {
+			log.Printf("Error acknowledging %s via %s: %v", medication, source, err)
+			return "Sorry, I couldn't record your medication. Please try again."
+		}
+		if already {
+			alreadyTaken = append(alreadyTaken, medication)
+		} else {
+			taken = append(taken, medication)
+		}
+	}
+	if len(taken) > 0 {
+		log.Printf("%s marked as taken via %s", strings.Join(taken, ", "), source)
+	}
+
+	// Doses that can only be recorded in Discord are explained after any that were taken
+	var waiting []string
+	if len(unconfirmed) > 0 {
+		waiting = append(waiting, fmt.Sprintf("%s needs confirming by someone else in Discord before it's recorded.", spokenList(unconfirmed)))
+	}
+	if len(unphotographed) > 0 {
+		waiting = append(waiting, fmt.Sprintf("%s needs a photo added in Discord before it's recorded.", spokenList(unphotographed)))
+	}
+
+	switch {
+	case len(taken) > 0 && len(waiting) > 0:
+		return fmt.Sprintf("Got it, I've marked %s as taken. %s", spokenList(taken), strings.Join(waiting, " "))
+	case len(taken) > 0:
+		return fmt.Sprintf("Got it, I've marked %s as taken.", spokenList(taken))
+	case len(waiting) > 0:
+		return strings.Join(waiting, " ")
+	case len(alreadyTaken) > 0:
+		return fmt.Sprintf("You've already taken %s today.", spokenList(alreadyTaken))
+	case period != nil:
+		return fmt.Sprintf("You don't have any %s doses left today.", period.name)
+	default:
+		return "You don't have any doses due right now."
+	}
+}
+
+// known reports whether a medication has the name, or is taken at several times under it
+func (h *Handler) known(name string) bool {
+	return slices.ContainsFunc(h.medications, func(medication config.Medication) bool {
+		return named(medication, name)
+	})
+}
+
+// named reports whether a medication is called the name, ignoring case
+func named(medication config.Medication, name string) bool {
+	return strings.EqualFold(medication.Name, name) || strings.EqualFold(medication.Base(), name)
+}
+
+// dosesDue returns the doses matching the medication name and time of day that can be marked as taken
+// now. Without a time of day, only doses that are due or nearly due are returned, so naming a
+// medication taken twice a day marks the dose due now.
+func (h *Handler) dosesDue(name string, period *timeOfDay, now time.Time) []string {
+	var due []string
+	for _, medication := range h.medications {
+		if name != "" && !named(medication, name) {
+			continue
+		}
+		local := medication.In(now)
+		if medication.AsNeeded() || !medication.IsScheduledOn(local) {
+			continue
+		}
+		dueAt := medication.DueAt(local)
+		// Doses past their reminder window were missed
+		if !now.Before(dueAt.Add(config.ReminderWindowHours * time.Hour)) {
+			continue
+		}
+		if period != nil {
+			if !period.contains(dueAt.Hour()) {
+				continue
+			}
+		} else if dueAt.After(now.Add(earlyWindow)) {
+			continue
+		}
+		due = append(due, medication.Name)
+	}
+	return due
+}
+
+// timeOfDay is a part of the day doses can be named by, holding the hours doses due in it are due at
+type timeOfDay struct {
+	name       string
+	start, end int
+}
+
+// timesOfDay are the parts of the day, by the words they're said with
+var timesOfDay = map[string]*timeOfDay{
+	"morning":   {name: "morning", start: 4, end: 12},
+	"afternoon": {name: "afternoon", start: 12, end: 17},
+	"evening":   {name: "evening", start: 17, end: 21},
+	"night":     {name: "night", start: 21, end: 4},
+	"tonight":   {name: "night", start: 21, end: 4},
+	"bedtime":   {name: "night", start: 21, end: 4},
+}
+
+// contains reports whether a dose due at the hour is due in the part of the day
+func (t *timeOfDay) contains(hour int) bool {
+	if t.start > t.end {
+		return hour >= t.start || hour < t.end
+	}
+	return hour >= t.start && hour < t.end
+}
+
+// parseTimeOfDay finds the part of the day in what was said, such as "morning" or "my evening pills"
+func parseTimeOfDay(said string) (*timeOfDay, bool) {
+	for _, word := range strings.Fields(strings.ToLower(said)) {
+		if period, ok := timesOfDay[strings.TrimSuffix(word, "'s")]; ok {
+			return period, true
+		}
+	}
+	return nil, false
+}
+
+// spokenList joins names the way they're said, e.g. "A, B and C"
+func spokenList(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing assistant response: %v", err)
+	}
+}
@@ -0,0 +1,303 @@
+package assistant
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+)
+
+type fakeAcknowledger struct {
+	store   *db.MemoryStore
+	sources []string
+}
+
+func (f *fakeAcknowledger) AcknowledgeMedication(ctx context.Context, medicationName, source string) (bool, error) {
+	f.sources = append(f.sources, source)
+	reminder, err := f.store.GetTodayReminder(ctx, medicationName)
+	if err != nil {
+		return false, err
+	}
+	if reminder.Acknowledged {
+		return true, nil
+	}
+	return false, f.store.RecordAcknowledgment(ctx, reminder.ID, reminder.Version, "", "", time.Time{})
+}
+
+// testCertURL is where the test signing certificate is downloaded from
+const testCertURL = "https://s3.amazonaws.com/echo.api/echo-api-cert.pem"
+
+// alexaSigner signs requests as Alexa does, with a certificate issued by its own authority
+type alexaSigner struct {
+	key   *rsa.PrivateKey
+	roots *x509.CertPool
+	chain []byte
+}
+
+// newAlexaSigner creates a certificate authority and a signing certificate for the name, valid around now
+func newAlexaSigner(t *testing.T, name string, now time.Time) *alexaSigner {
+	t.Helper()
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, _ = x509.ParseCertificate(caDER)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+	chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})...)
+	return &alexaSigner{key: key, roots: roots, chain: chain}
+}
+
+// verifier returns a verifier trusting the signer's authority, which downloads its chain
+func (a *alexaSigner) verifier() *alexaVerifier {
+	return &alexaVerifier{
+		roots: a.roots,
+		fetch: func(certURL string) ([]byte, error) {
+			return a.chain, nil
+		},
+		certs: make(map[string]*x509.Certificate),
+	}
+}
+
+// sign sets the headers signing a request's body
+func (a *alexaSigner) sign(t *testing.T, req *http.Request, body string) {
+	t.Helper()
+	digest := sha256.Sum256([]byte(body))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	req.Header.Set("SignatureCertChainUrl", testCertURL)
+	req.Header.Set("Signature-256", base64.StdEncoding.EncodeToString(signature))
+}
+
+func newTestHandler(t *testing.T, now time.Time) (*Handler, *fakeAcknowledger) {
+	t.Helper()
+	store := db.NewMemoryStore(time.UTC)
+	acknowledger := &fakeAcknowledger{store: store}
+	handler := NewHandler("assistant-token", []config.Medication{
+		{Name: "Metformin 08:00", BaseName: "Metformin", Hour: 8, Frequency: "daily"},
+		{Name: "Metformin 20:00", BaseName: "Metformin", Hour: 20, Frequency: "daily"},
+		{Name: "Vitamin D", Hour: 8, Minute: 30, Frequency: "daily"},
+		{Name: "Ibuprofen", Frequency: config.FrequencyAsNeeded},
+	}, store, acknowledger, time.UTC)
+	handler.now = func() time.Time { return now }
+	handler.alexa = testAlexaSigner(t).verifier()
+	return handler, acknowledger
+}
+
+// testSigner signs the test requests, valid for the times they're made at. It's created once, since
+// generating its keys is slow.
+var (
+	testSignerOnce sync.Once
+	testSigner     *alexaSigner
+)
+
+// testAlexaSigner returns the signer of the test requests
+func testAlexaSigner(t *testing.T) *alexaSigner {
+	t.Helper()
+	testSignerOnce.Do(func() {
+		testSigner = newAlexaSigner(t, alexaCertName, time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC))
+	})
+	return testSigner
+}
+
+// serve sends a request body, signed as if by Alexa, to the handler and returns the response
+func serve(t *testing.T, handler *Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer assistant-token")
+	testAlexaSigner(t).sign(t, req, body)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func alexaSpeech(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp alexaResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Response.OutputSpeech == nil {
+		return ""
+	}
+	return resp.Response.OutputSpeech.Text
+}
+
+func TestAlexa(t *testing.T) {
+	handler, acknowledger := newTestHandler(t, time.Date(2024, 5, 1, 8, 45, 0, 0, time.UTC))
+
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(`{"request":{"type":"LaunchRequest"}}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Status without a token = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	morning := `{"version":"1.0","request":{"type":"IntentRequest","timestamp":"2024-05-01T08:44:30Z","intent":{"name":"TakeMedication","slots":{"time_of_day":{"name":"time_of_day","value":"morning"}}}}}`
+	if got := alexaSpeech(t, serve(t, handler, morning)); got != "Got it, I've marked Metformin 08:00 and Vitamin D as taken." {
+		t.Errorf("Unexpected speech: %q", got)
+	}
+	if len(acknowledger.sources) != 2 || acknowledger.sources[0] != "Alexa" {
+		t.Errorf("Unexpected sources: %v", acknowledger.sources)
+	}
+
+	if got := alexaSpeech(t, serve(t, handler, morning)); got != "You've already taken Metformin 08:00 and Vitamin D today." {
+		t.Errorf("Unexpected speech when already taken: %q", got)
+	}
+
+	if got := alexaSpeech(t, serve(t, handler, `{"request":{"type":"SessionEndedRequest","timestamp":"2024-05-01T08:45:00Z"}}`)); got != "" {
+		t.Errorf("Expected no speech when the session ends, got %q", got)
+	}
+}
+
+// TestAlexaVerification tests that Alexa requests are only answered if Alexa signed them recently
+func TestAlexaVerification(t *testing.T) {
+	now := time.Date(2024, 5, 1, 8, 45, 0, 0, time.UTC)
+	body := `{"request":{"type":"LaunchRequest","timestamp":"2024-05-01T08:45:00Z"}}`
+	other := newAlexaSigner(t, alexaCertName, now)
+	wrongName := newAlexaSigner(t, "example.com", now)
+
+	tests := []struct {
+		name string
+		body string
+		sign func(req *http.Request)
+		want int
+	}{
+		{name: "Signed", body: body, sign: func(req *http.Request) { testAlexaSigner(t).sign(t, req, body) }, want: http.StatusOK},
+		{name: "Unsigned", body: body, sign: func(req *http.Request) {}, want: http.StatusBadRequest},
+		{name: "Body changed", body: strings.Replace(body, "Launch", "Intent", 1), sign: func(req *http.Request) { testAlexaSigner(t).sign(t, req, body) }, want: http.StatusBadRequest},
+		{name: "Certificate from elsewhere", body: body, sign: func(req *http.Request) {
+			testAlexaSigner(t).sign(t, req, body)
+			req.Header.Set("SignatureCertChainUrl", "https://example.com/echo.api/cert.pem")
+		}, want: http.StatusBadRequest},
+		{name: "Path outside echo.api", body: body, sign: func(req *http.Request) {
+			testAlexaSigner(t).sign(t, req, body)
+			req.Header.Set("SignatureCertChainUrl", "https://s3.amazonaws.com/echo.api/../invalid/cert.pem")
+		}, want: http.StatusBadRequest},
+		{name: "Untrusted authority", body: body, sign: func(req *http.Request) { other.sign(t, req, body) }, want: http.StatusBadRequest},
+		{name: "Stale", body: strings.Replace(body, "08:45:00", "08:40:00", 1), sign: func(req *http.Request) {
+			testAlexaSigner(t).sign(t, req, strings.Replace(body, "08:45:00", "08:40:00", 1))
+		}, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _ := newTestHandler(t, now)
+			// Only the test authority is trusted, even for a chain issued for the right name
+			if tt.name == "Untrusted authority" {
+				handler.alexa.fetch = func(string) ([]byte, error) { return other.chain, nil }
+			}
+
+			req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer assistant-token")
+			tt.sign(req)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("Status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	// A chain that doesn't name Alexa isn't trusted, even from the trusted authority's pool
+	handler, _ := newTestHandler(t, now)
+	handler.alexa.roots = wrongName.roots
+	handler.alexa.fetch = func(string) ([]byte, error) { return wrongName.chain, nil }
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer assistant-token")
+	wrongName.sign(t, req, body)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Status for a certificate not issued to Alexa = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestDialogflow(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters string
+		want       string
+	}{
+		{name: "Due now", parameters: `{}`, want: "Got it, I've marked Metformin 08:00 and Vitamin D as taken."},
+		{name: "Medication", parameters: `{"medication":"metformin"}`, want: "Got it, I've marked Metformin 08:00 as taken."},
+		{name: "Time of day as medication", parameters: `{"medication":"my evening pills"}`, want: "Got it, I've marked Metformin 20:00 as taken."},
+		{name: "Unknown medication", parameters: `{"medication":"Aspirin"}`, want: "I couldn't find a medication called Aspirin."},
+		{name: "Unknown time of day", parameters: `{"time_of_day":"lunch"}`, want: "I don't know when lunch is. Try morning, afternoon, evening or night."},
+		{name: "Nothing left", parameters: `{"time_of_day":"afternoon"}`, want: "You don't have any afternoon doses left today."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _ := newTestHandler(t, time.Date(2024, 5, 1, 8, 10, 0, 0, time.UTC))
+			rec := serve(t, handler, `{"queryResult":{"intent":{"displayName":"TakeMedication"},"parameters":`+tt.parameters+`}}`)
+
+			var resp dialogflowResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.FulfillmentText != tt.want {
+				t.Errorf("FulfillmentText = %q, want %q", resp.FulfillmentText, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeOfDayContains(t *testing.T) {
+	night := timesOfDay["night"]
+	for hour, want := range map[int]bool{22: true, 2: true, 4: false, 12: false} {
+		if got := night.contains(hour); got != want {
+			t.Errorf("night.contains(%d) = %v, want %v", hour, got, want)
+		}
+	}
+}
@@ -0,0 +1,577 @@
+// Package caldav publishes doses as a read-only CalDAV calendar, for calendar clients that sync over
+// CalDAV rather than subscribing to an .ics feed. Each scheduled dose is an event at the time it's due,
+// showing whether it was taken, skipped or missed, from a month back to two weeks ahead. Clients log in
+// with HTTP Basic authentication, using the export token as the password.
+package caldav
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/export"
+)
+
+// CalDAV paths
+const (
+	// Path is the root of the CalDAV server, which is also the principal and calendar home
+	Path = "/caldav/"
+	// CalendarPath is the calendar of doses
+	CalendarPath = Path + "doses/"
+	// WellKnownPath redirects clients to the server, so only the host needs to be entered (RFC 6764)
+	WellKnownPath = "/.well-known/caldav"
+)
+
+// The calendar covers doses from historyDays ago to aheadDays from now
+const (
+	historyDays = 30
+	aheadDays   = 14
+)
+
+// doseDuration is how long a dose's event lasts
+const doseDuration = 15 * time.Minute
+
+// maxBodyBytes is the largest request body read
+const maxBodyBytes = 1 << 16
+
+// XML namespaces
+const (
+	nsDAV          = "DAV:"
+	nsCalDAV       = "urn:ietf:params:xml:ns:caldav"
+	nsCalendarServ = "http://calendarserver.org/ns/"
+)
+
+// ReminderStore reads the history of doses
+type ReminderStore interface {
+	GetReminderHistory(ctx context.Context, medicationType string, since time.Time) ([]db.Reminder, error)
+}
+
+// Handler serves the CalDAV calendar of doses
+type Handler struct {
+	store       ReminderStore
+	medications []config.Medication
+	token       string
+	location    *time.Location
+	now         func() time.Time
+}
+
+// NewHandler creates a CalDAV handler authenticated with the export token
+func NewHandler(store ReminderStore, medications []config.Medication, token string, location *time.Location) *Handler {
+	if location == nil {
+		location = time.UTC
+	}
+
+	return &Handler{
+		store:       store,
+		medications: medications,
+		token:       token,
+		location:    location,
+		now:         time.Now,
+	}
+}
+
+// event is a dose in the calendar
+type event struct {
+	href string
+	// data is the event as an iCalendar object, and etag identifies its current version
+	data  string
+	etag  string
+	start time.Time
+}
+
+// ServeHTTP serves the read-only subset of WebDAV and CalDAV that calendar clients sync with
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == WellKnownPath {
+		http.Redirect(w, r, Path, http.StatusMovedPermanently)
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="meds-bot", charset="UTF-8"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1, calendar-access")
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND, REPORT")
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		h.serveEvent(w, r)
+	case "PROPFIND":
+		h.propfind(w, r)
+	case "REPORT":
+		h.report(w, r)
+	case http.MethodPut, http.MethodDelete, http.MethodPost, "PROPPATCH", "MKCOL", "MKCALENDAR", "COPY", "MOVE", "LOCK":
+		http.Error(w, "The calendar is read-only", http.StatusForbidden)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized checks the password of HTTP Basic authentication, which is all most calendar clients
+// support, against the token, or the token sent the same way as to the export endpoints
+func (h *Handler) authorized(r *http.Request) bool {
+	if _, password, ok := r.BasicAuth(); ok {
+		return password != "" && subtle.ConstantTimeCompare([]byte(password), []byte(h.token)) == 1
+	}
+	return export.Authorized(r, h.token)
+}
+
+// serveEvent serves a dose's event as an iCalendar object
+func (h *Handler) serveEvent(w http.ResponseWriter, r *http.Request) {
+	events, err := h.events(r.Context())
+	if err != nil {
+		log.Printf("Error loading CalDAV events: %v", err)
+		http.Error(w, "Failed to load doses", http.StatusInternalServerError)
+		return
+	}
+
+	i := slices.IndexFunc(events, func(e event) bool { return e.href == r.URL.Path })
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("ETag", events[i].etag)
+	if r.Method == http.MethodGet {
+		io.WriteString(w, events[i].data)
+	}
+}
+
+// propfind lists the properties of the server, the calendar and its events
+func (h *Handler) propfind(w http.ResponseWriter, r *http.Request) {
+	req, err := parseRequest(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.events(r.Context())
+	if err != nil {
+		log.Printf("Error loading CalDAV events: %v", err)
+		http.Error(w, "Failed to load doses", http.StatusInternalServerError)
+		return
+	}
+
+	depth := r.Header.Get("Depth")
+	var responses []response
+	switch r.URL.Path {
+	case Path:
+		responses = append(responses, h.rootResponse(req))
+		if depth == "1" {
+			responses = append(responses, h.calendarResponse(req, events))
+		}
+	case CalendarPath:
+		responses = append(responses, h.calendarResponse(req, events))
+		if depth == "1" {
+			for _, e := range events {
+				responses = append(responses, eventResponse(req, e, false))
+			}
+		}
+	default:
+		i := slices.IndexFunc(events, func(e event) bool { return e.href == r.URL.Path })
+		if i < 0 {
+			http.NotFound(w, r)
+			return
+		}
+		responses = append(responses, eventResponse(req, events[i], false))
+	}
+
+	writeMultistatus(w, responses)
+}
+
+// report answers calendar-query and calendar-multiget reports on the calendar
+func (h *Handler) report(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != CalendarPath {
+		http.Error(w, "Reports are only supported on the calendar", http.StatusForbidden)
+		return
+	}
+
+	req, err := parseRequest(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.events(r.Context())
+	if err != nil {
+		log.Printf("Error loading CalDAV events: %v", err)
+		http.Error(w, "Failed to load doses", http.StatusInternalServerError)
+		return
+	}
+
+	var responses []response
+	switch req.root {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		for _, e := range events {
+			if !req.start.IsZero() && !e.start.Add(doseDuration).After(req.start) {
+				continue
+			}
+			if !req.end.IsZero() && !e.start.Before(req.end) {
+				continue
+			}
+			responses = append(responses, eventResponse(req, e, true))
+		}
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		for _, href := range req.hrefs {
+			i := slices.IndexFunc(events, func(e event) bool { return e.href == href })
+			if i < 0 {
+				responses = append(responses, response{Href: href, Status: "HTTP/1.1 404 Not Found"})
+				continue
+			}
+			responses = append(responses, eventResponse(req, events[i], true))
+		}
+	default:
+		http.Error(w, "Unsupported report", http.StatusForbidden)
+		return
+	}
+
+	writeMultistatus(w, responses)
+}
+
+// rootResponse describes the root, which is the principal of the only user and their calendar home
+func (h *Handler) rootResponse(req request) response {
+	return propResponse(Path, req, map[xml.Name]string{
+		{Space: nsDAV, Local: "resourcetype"}:               "<D:collection/><D:principal/>",
+		{Space: nsDAV, Local: "displayname"}:                "meds-bot",
+		{Space: nsDAV, Local: "current-user-principal"}:     "<D:href>" + Path + "</D:href>",
+		{Space: nsDAV, Local: "principal-URL"}:              "<D:href>" + Path + "</D:href>",
+		{Space: nsCalDAV, Local: "calendar-home-set"}:       "<D:href>" + Path + "</D:href>",
+		{Space: nsDAV, Local: "current-user-privilege-set"}: readPrivileges,
+	})
+}
+
+// calendarResponse describes the calendar, whose ctag changes whenever any of its events do
+func (h *Handler) calendarResponse(req request, events []event) response {
+	ctag := sha256.New()
+	for _, e := range events {
+		io.WriteString(ctag, e.href+e.etag)
+	}
+
+	return propResponse(CalendarPath, req, map[xml.Name]string{
+		{Space: nsDAV, Local: "resourcetype"}:                        "<D:collection/><C:calendar/>",
+		{Space: nsDAV, Local: "displayname"}:                         "Medications",
+		{Space: nsDAV, Local: "current-user-principal"}:              "<D:href>" + Path + "</D:href>",
+		{Space: nsDAV, Local: "current-user-privilege-set"}:          readPrivileges,
+		{Space: nsDAV, Local: "supported-report-set"}:                supportedReports,
+		{Space: nsCalDAV, Local: "supported-calendar-component-set"}: `<C:comp name="VEVENT"/>`,
+		{Space: nsCalDAV, Local: "calendar-description"}:             "Scheduled doses and whether they were taken",
+		{Space: nsCalendarServ, Local: "getctag"}:                    hex.EncodeToString(ctag.Sum(nil))[:16],
+	})
+}
+
+// eventResponse describes an event, with its iCalendar data when it's asked for
+func eventResponse(req request, e event, withData bool) response {
+	props := map[xml.Name]string{
+		{Space: nsDAV, Local: "resourcetype"}:     "",
+		{Space: nsDAV, Local: "getetag"}:          escape(e.etag),
+		{Space: nsDAV, Local: "getcontenttype"}:   "text/calendar; charset=utf-8; component=VEVENT",
+		{Space: nsDAV, Local: "getcontentlength"}: fmt.Sprint(len(e.data)),
+		{Space: nsCalDAV, Local: "calendar-data"}: escape(e.data),
+	}
+	// Listing the calendar only needs the etags, to tell which events have changed
+	if !withData && req.allProps {
+		delete(props, xml.Name{Space: nsCalDAV, Local: "calendar-data"})
+	}
+	return propResponse(e.href, req, props)
+}
+
+// Property values shared by the root and calendar
+const (
+	readPrivileges   = "<D:privilege><D:read/></D:privilege><D:privilege><D:read-current-user-privilege-set/></D:privilege>"
+	supportedReports = "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report>" +
+		"<D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>"
+)
+
+// events builds the calendar's events from the doses scheduled over its range. Past doses are only
+// included if they were reminded about, and doses of medications no longer in the configuration are left
+// out, since when they were due isn't known.
+func (h *Handler) events(ctx context.Context) ([]event, error) {
+	now := h.now().In(h.location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, h.location)
+	first := today.AddDate(0, 0, -historyDays)
+
+	history, err := h.store.GetReminderHistory(ctx, "", first)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reminder history: %w", err)
+	}
+	reminders := make(map[string]db.Reminder)
+	for _, reminder := range history {
+		reminders[reminder.Date+"\n"+reminder.MedicationType] = reminder
+	}
+
+	var events []event
+	for day := first; !day.After(today.AddDate(0, 0, aheadDays)); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		for _, medication := range h.medications {
+			if !medication.IsScheduledOn(day) {
+				continue
+			}
+			reminder, ok := reminders[date+"\n"+medication.Name]
+			if !ok && day.Before(today) {
+				continue
+			}
+
+			dueAt := medication.DueOn(day)
+			data := iCalendar(medication.Name, date, dueAt, reminder, now)
+			sum := sha256.Sum256([]byte(data))
+			events = append(events, event{
+				href:  CalendarPath + uid(medication.Name, date) + ".ics",
+				data:  data,
+				etag:  `"` + hex.EncodeToString(sum[:8]) + `"`,
+				start: dueAt,
+			})
+		}
+	}
+	return events, nil
+}
+
+// uid identifies the event for a dose, made only of characters that are safe in a URL
+func uid(medication, date string) string {
+	sum := sha256.Sum256([]byte(medication))
+	return date + "-" + hex.EncodeToString(sum[:6])
+}
+
+// iCalendar renders the event for a dose. The dose's state is only shown once it's known, and doses that
+// aren't taken by the end of their reminder window are missed.
+func iCalendar(medication, date string, dueAt time.Time, reminder db.Reminder, now time.Time) string {
+	summary := "💊 " + medication
+	description := ""
+	switch {
+	case reminder.Acknowledged:
+		summary = "✅ " + medication
+		if !reminder.AcknowledgedAt.IsZero() {
+			description = "Taken at " + reminder.AcknowledgedAt.In(dueAt.Location()).Format("15:04")
+		} else {
+			description = "Taken"
+		}
+	case reminder.Skipped:
+		summary = "⏭️ " + medication
+		description = "Skipped"
+		if reminder.SkipReason != "" {
+			description += ": " + reminder.SkipReason
+		}
+	case !now.Before(dueAt.Add(config.ReminderWindowHours * time.Hour)):
+		summary = "❌ " + medication
+		description = "Missed"
+	}
+
+	var b strings.Builder
+	line := func(content string) {
+		b.WriteString(fold(content))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//meds-bot//CalDAV//EN")
+	line("BEGIN:VEVENT")
+	line("UID:" + uid(medication, date) + "@meds-bot")
+	// The stamp is fixed, so an event only changes when its dose does
+	line("DTSTAMP:" + dueAt.UTC().Format("20060102T150405Z"))
+	line("DTSTART:" + dueAt.UTC().Format("20060102T150405Z"))
+	line("DURATION:PT15M")
+	line("SUMMARY:" + escapeText(summary))
+	if description != "" {
+		line("DESCRIPTION:" + escapeText(description))
+	}
+	line("CATEGORIES:Medication")
+	line("TRANSP:TRANSPARENT")
+	line("END:VEVENT")
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+// escapeText escapes an iCalendar text value (RFC 5545 3.3.11)
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// fold splits a content line into lines of at most 75 octets, without splitting characters (RFC 5545 3.1)
+func fold(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+// request is the part of a PROPFIND or REPORT body that's read
+type request struct {
+	root xml.Name
+	// props are the properties asked for, or allProps when all of them are
+	props    []xml.Name
+	allProps bool
+	// hrefs are the events a calendar-multiget asks for
+	hrefs []string
+	// start and end are a calendar-query's time range, zero when it's open
+	start, end time.Time
+}
+
+// parseRequest reads a PROPFIND or REPORT body, where an empty body asks for all properties
+func parseRequest(body io.Reader) (request, error) {
+	var req request
+	decoder := xml.NewDecoder(body)
+	var stack []xml.Name
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return request{}, fmt.Errorf("invalid XML body: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case len(stack) == 0:
+				req.root = t.Name
+			case stack[len(stack)-1] == xml.Name{Space: nsDAV, Local: "prop"} && len(stack) == 2:
+				req.props = append(req.props, t.Name)
+			case t.Name == xml.Name{Space: nsDAV, Local: "allprop"}:
+				req.allProps = true
+			case t.Name == xml.Name{Space: nsCalDAV, Local: "time-range"}:
+				for _, attr := range t.Attr {
+					value, err := time.Parse("20060102T150405Z", attr.Value)
+					if err != nil {
+						return request{}, fmt.Errorf("invalid time range: %s", attr.Value)
+					}
+					switch attr.Name.Local {
+					case "start":
+						req.start = value
+					case "end":
+						req.end = value
+					}
+				}
+			}
+			stack = append(stack, t.Name)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) == 2 && stack[1] == (xml.Name{Space: nsDAV, Local: "href"}) {
+				// Some clients send full URLs rather than paths
+				href := strings.TrimSpace(string(t))
+				if u, err := url.Parse(href); err == nil {
+					href = u.Path
+				}
+				req.hrefs = append(req.hrefs, href)
+			}
+		}
+	}
+
+	if len(req.props) == 0 {
+		req.allProps = true
+	}
+	return req, nil
+}
+
+// response is a resource's part of a multistatus response
+type response struct {
+	Href string
+	// Found are the properties the resource has and Missing those it doesn't, as XML
+	Found   []string
+	Missing []string
+	// Status is set instead of properties for a resource that doesn't exist
+	Status string
+}
+
+// propResponse describes a resource with the properties asked for, from the XML of those it has
+func propResponse(href string, req request, props map[xml.Name]string) response {
+	resp := response{Href: href}
+	names := req.props
+	if req.allProps {
+		names = nil
+		for name := range props {
+			names = append(names, name)
+		}
+		slices.SortFunc(names, func(a, b xml.Name) int {
+			return strings.Compare(a.Space+a.Local, b.Space+b.Local)
+		})
+	}
+
+	for _, name := range names {
+		value, ok := props[name]
+		if ok {
+			resp.Found = append(resp.Found, element(name, value))
+		} else {
+			resp.Missing = append(resp.Missing, element(name, ""))
+		}
+	}
+	return resp
+}
+
+// element renders a property with the prefix of its namespace
+func element(name xml.Name, value string) string {
+	prefix := ""
+	switch name.Space {
+	case nsDAV:
+		prefix = "D:"
+	case nsCalDAV:
+		prefix = "C:"
+	case nsCalendarServ:
+		prefix = "CS:"
+	default:
+		// Properties of other namespaces are declared where they're used
+		if value == "" {
+			return fmt.Sprintf(`<X:%s xmlns:X="%s"/>`, name.Local, escape(name.Space))
+		}
+		return fmt.Sprintf(`<X:%s xmlns:X="%s">%s</X:%s>`, name.Local, escape(name.Space), value, name.Local)
+	}
+	if value == "" {
+		return fmt.Sprintf("<%s%s/>", prefix, name.Local)
+	}
+	return fmt.Sprintf("<%s%s>%s</%s%s>", prefix, name.Local, value, prefix, name.Local)
+}
+
+// writeMultistatus writes a WebDAV multistatus response
+func writeMultistatus(w http.ResponseWriter, responses []response) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<D:multistatus xmlns:D="%s" xmlns:C="%s" xmlns:CS="%s">`, nsDAV, nsCalDAV, nsCalendarServ)
+	for _, resp := range responses {
+		b.WriteString("<D:response><D:href>" + escape(resp.Href) + "</D:href>")
+		if resp.Status != "" {
+			b.WriteString("<D:status>" + resp.Status + "</D:status>")
+		}
+		if len(resp.Found) > 0 {
+			b.WriteString("<D:propstat><D:prop>" + strings.Join(resp.Found, "") + "</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat>")
+		}
+		if len(resp.Missing) > 0 {
+			b.WriteString("<D:propstat><D:prop>" + strings.Join(resp.Missing, "") + "</D:prop><D:status>HTTP/1.1 404 Not Found</D:status></D:propstat>")
+		}
+		b.WriteString("</D:response>")
+	}
+	b.WriteString("</D:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, b.String())
+}
+
+// escape escapes text for XML
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package caldav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+)
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	ctx := context.Background()
+	store := db.NewMemoryStore(time.UTC)
+
+	reminder, err := store.GetTodayReminder(ctx, "Vitamin D")
+	if err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+	if err := store.RecordSkip(ctx, reminder.ID, reminder.Version, "", "Fasting, blood test"); err != nil {
+		t.Fatalf("Failed to skip dose: %v", err)
+	}
+
+	handler := NewHandler(store, []config.Medication{
+		{Name: "Vitamin D", Hour: 8, Frequency: "daily"},
+		{Name: "Ibuprofen", Frequency: config.FrequencyAsNeeded},
+	}, "secret", time.UTC)
+	now := time.Now().UTC()
+	handler.now = func() time.Time { return now }
+	return handler
+}
+
+func serve(handler *Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.SetBasicAuth("anyone", "secret")
+	req.Header.Set("Depth", "1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthentication(t *testing.T) {
+	handler := newTestHandler(t)
+
+	req := httptest.NewRequest("PROPFIND", Path, nil)
+	req.SetBasicAuth("anyone", "wrong")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Status with the wrong password = %d, want %d with a challenge", rec.Code, http.StatusUnauthorized)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, WellKnownPath, nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != Path {
+		t.Errorf("Well-known redirect = %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	if rec := serve(handler, http.MethodPut, CalendarPath+"new.ics", "BEGIN:VCALENDAR"); rec.Code != http.StatusForbidden {
+		t.Errorf("Status of a write = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestPropfind(t *testing.T) {
+	handler := newTestHandler(t)
+
+	rec := serve(handler, "PROPFIND", Path, `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:current-user-principal/><c:calendar-home-set/><d:resourcetype/><d:quota-used-bytes/></d:prop>
+</d:propfind>`)
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("Status = %d, want %d", rec.Code, http.StatusMultiStatus)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<C:calendar-home-set><D:href>/caldav/</D:href></C:calendar-home-set>",
+		"<D:href>/caldav/doses/</D:href>",
+		"<D:collection/><C:calendar/>",
+		"<D:quota-used-bytes/></D:prop><D:status>HTTP/1.1 404 Not Found</D:status>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Response is missing %q:\n%s", want, body)
+		}
+	}
+
+	rec = serve(handler, "PROPFIND", CalendarPath, `<d:propfind xmlns:d="DAV:"><d:prop><d:getetag/></d:prop></d:propfind>`)
+	// Today and the two weeks ahead, without the as-needed medication
+	if got := strings.Count(rec.Body.String(), ".ics</D:href>"); got != aheadDays+1 {
+		t.Errorf("Calendar lists %d events, want %d", got, aheadDays+1)
+	}
+}
+
+func TestReport(t *testing.T) {
+	handler := newTestHandler(t)
+	today := handler.now().Format("2006-01-02")
+	href := CalendarPath + uid("Vitamin D", today) + ".ics"
+
+	rec := serve(handler, "REPORT", CalendarPath, `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <d:href>`+href+`</d:href>
+  <d:href>`+CalendarPath+`unknown.ics</d:href>
+</c:calendar-multiget>`)
+	body := rec.Body.String()
+	if !strings.Contains(body, `SUMMARY:⏭️ Vitamin D`) || !strings.Contains(body, `DESCRIPTION:Skipped: Fasting\, blood test`) {
+		t.Errorf("Multiget is missing the skipped dose:\n%s", body)
+	}
+	if !strings.Contains(body, "<D:status>HTTP/1.1 404 Not Found</D:status></D:response>") {
+		t.Errorf("Multiget is missing the unknown event:\n%s", body)
+	}
+
+	start := handler.now().AddDate(0, 0, 2).Truncate(24 * time.Hour)
+	rec = serve(handler, "REPORT", CalendarPath, `<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/></d:prop>
+  <c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT">
+    <c:time-range start="`+start.Format("20060102T150405Z")+`" end="`+start.AddDate(0, 0, 2).Format("20060102T150405Z")+`"/>
+  </c:comp-filter></c:comp-filter></c:filter>
+</c:calendar-query>`)
+	if got := strings.Count(rec.Body.String(), "<D:response>"); got != 2 {
+		t.Errorf("Query over two days returned %d events, want 2", got)
+	}
+
+	rec = serve(handler, http.MethodGet, href, "")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Body.String(), "BEGIN:VCALENDAR\r\n") || rec.Header().Get("ETag") == "" {
+		t.Errorf("GET of the event = %d: %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestFold(t *testing.T) {
+	folded := fold("DESCRIPTION:" + strings.Repeat("é", 60))
+	for _, line := range strings.Split(folded, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line of %d octets: %q", len(line), line)
+		}
+	}
+	if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != "DESCRIPTION:"+strings.Repeat("é", 60) {
+		t.Errorf("Unfolded line = %q", unfolded)
+	}
+}
@@ -0,0 +1,126 @@
+// Package componentid encodes the custom IDs of Discord buttons, select menus and modals. An ID packs
+// an action and its arguments, such as a medication name, within Discord's 100 character limit, and is
+// signed so that IDs which have been tampered with, rather than sent back as the bot made them, are rejected.
+package componentid
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxLength is the longest custom ID Discord accepts
+	MaxLength = 100
+	// signatureBytes is how much of the HMAC is kept, enough to make forging an ID impractical
+	// while leaving most of the limit for arguments
+	signatureBytes = 12
+	// separator joins the parts of an ID, and can't appear in an action or an encoded argument
+	separator = "."
+)
+
+var (
+	// ErrTooLong is returned when an action and its arguments don't fit in a custom ID
+	ErrTooLong = errors.New("custom ID is too long")
+	// ErrInvalidAction is returned for actions using anything other than lowercase letters, digits and underscores
+	ErrInvalidAction = errors.New("invalid custom ID action")
+	// ErrMalformed is returned when decoding a custom ID that wasn't encoded by a codec
+	ErrMalformed = errors.New("malformed custom ID")
+	// ErrInvalidSignature is returned when a custom ID's signature doesn't match its contents
+	ErrInvalidSignature = errors.New("invalid custom ID signature")
+)
+
+// ID is a decoded custom ID
+type ID struct {
+	Action string
+	Args   []string
+}
+
+// Codec encodes and decodes signed custom IDs
+type Codec struct {
+	key []byte
+}
+
+// NewCodec creates a codec signing IDs with a key, which must stay the same for as long as the
+// components using them are clickable
+func NewCodec(key []byte) *Codec {
+	return &Codec{key: key}
+}
+
+// Encode returns the custom ID for an action and its arguments. The action is kept readable, so it
+// shows in logs, and the arguments are base64 encoded so they can hold any characters.
+func (c *Codec) Encode(action string, args ...string) (string, error) {
+	if !validAction(action) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidAction, action)
+	}
+
+	parts := []string{action}
+	for _, arg := range args {
+		parts = append(parts, base64.RawURLEncoding.EncodeToString([]byte(arg)))
+	}
+	payload := strings.Join(parts, separator)
+
+	id := payload + separator + c.sign(payload)
+	if len(id) > MaxLength {
+		return "", fmt.Errorf("%w: %s needs %d characters", ErrTooLong, action, len(id))
+	}
+	return id, nil
+}
+
+// Decode checks a custom ID's signature and returns its action and arguments
+func (c *Codec) Decode(customID string) (ID, error) {
+	payload, signature, ok := cutLast(customID, separator)
+	if !ok || len(customID) > MaxLength {
+		return ID{}, ErrMalformed
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(c.sign(payload))) {
+		return ID{}, ErrInvalidSignature
+	}
+
+	parts := strings.Split(payload, separator)
+	id := ID{Action: parts[0]}
+	if !validAction(id.Action) {
+		return ID{}, ErrMalformed
+	}
+	for _, part := range parts[1:] {
+		arg, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return ID{}, ErrMalformed
+		}
+		id.Args = append(id.Args, string(arg))
+	}
+
+	return id, nil
+}
+
+// sign returns the signature of an ID's action and encoded arguments
+func (c *Codec) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signatureBytes])
+}
+
+// validAction reports whether an action is non-empty and only uses lowercase letters, digits and underscores
+func validAction(action string) bool {
+	if action == "" {
+		return false
+	}
+	for _, r := range action {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
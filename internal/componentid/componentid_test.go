@@ -0,0 +1,80 @@
+package componentid
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	codec := NewCodec([]byte("secret"))
+
+	// Arguments can hold the separator and any other characters
+	id, err := codec.Encode("taken", "Vitamin D. 1000 IU", "ünïcode")
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if !strings.HasPrefix(id, "taken.") || len(id) > MaxLength {
+		t.Errorf("Unexpected custom ID %s", id)
+	}
+
+	decoded, err := codec.Decode(id)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if decoded.Action != "taken" || !slices.Equal(decoded.Args, []string{"Vitamin D. 1000 IU", "ünïcode"}) {
+		t.Errorf("Unexpected decoded ID %+v", decoded)
+	}
+
+	// Actions without arguments
+	id, err = codec.Encode("details")
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if decoded, err := codec.Decode(id); err != nil || decoded.Action != "details" || len(decoded.Args) != 0 {
+		t.Errorf("Unexpected decoded ID %+v: %v", decoded, err)
+	}
+}
+
+func TestEncodeLimits(t *testing.T) {
+	codec := NewCodec([]byte("secret"))
+
+	if _, err := codec.Encode("taken", strings.Repeat("x", 80)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Expected ErrTooLong, got %v", err)
+	}
+	for _, action := range []string{"", "Taken", "taken.now", "taken now"} {
+		if _, err := codec.Encode(action); !errors.Is(err, ErrInvalidAction) {
+			t.Errorf("Expected ErrInvalidAction for %q, got %v", action, err)
+		}
+	}
+}
+
+func TestDecodeTampered(t *testing.T) {
+	codec := NewCodec([]byte("secret"))
+	id, err := codec.Encode("taken", "Morning Pill")
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	forged, err := codec.Encode("taken", "Evening Pill")
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	tests := map[string]error{
+		// The arguments of one ID with the signature of another
+		forged[:strings.LastIndex(forged, ".")] + id[strings.LastIndex(id, "."):]: ErrInvalidSignature,
+		"medication_taken_Morning Pill":                                           ErrMalformed,
+		strings.Replace(id, "taken", "refilled", 1):                               ErrInvalidSignature,
+	}
+	for customID, want := range tests {
+		if _, err := codec.Decode(customID); !errors.Is(err, want) {
+			t.Errorf("Decode(%q) returned %v, want %v", customID, err, want)
+		}
+	}
+
+	// IDs signed with another key
+	if _, err := NewCodec([]byte("other")).Decode(id); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+}
@@ -5,13 +5,29 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
+	"unicode/utf8"
+
+	"meds-bot/internal/logging"
+	"meds-bot/internal/schedule"
 
 	"github.com/joho/godotenv"
 )
 
+// IntentNames are the names of the gateway intents that can be requested with DISCORD_EXTRA_INTENTS
+var IntentNames = []string{
+	"guilds", "guild_members", "guild_moderation", "guild_emojis", "guild_integrations", "guild_webhooks",
+	"guild_invites", "guild_voice_states", "guild_presences", "guild_messages", "guild_message_reactions",
+	"guild_message_typing", "direct_messages", "direct_message_reactions", "direct_message_typing",
+	"message_content", "guild_scheduled_events", "auto_moderation_configuration", "auto_moderation_execution",
+}
+
 // ConfigSource represents the source of configuration
 type ConfigSource string
 
@@ -21,6 +37,64 @@ const (
 	DefaultPath              = "./config.json"
 )
 
+// Reminder presentation modes
+const (
+	ReminderModeIndividual = "individual"
+	ReminderModeChecklist  = "checklist"
+)
+
+// Photo proof options, for whether a photo of a dose is asked for when it's marked as taken
+const (
+	PhotoProofOptional = "optional"
+	PhotoProofRequired = "required"
+)
+
+// Database drivers
+const (
+	DBDriverSQLite   = "sqlite"
+	DBDriverMemory   = "memory"
+	DBDriverPostgres = "postgres"
+)
+
+// Backup channels reminders fail over to while Discord is down
+const (
+	FailoverNtfy  = "ntfy"
+	FailoverEmail = "email"
+	FailoverSMS   = "sms"
+)
+
+// MaxStartupBackoff caps the delay between startup retries
+const MaxStartupBackoff = 5 * time.Minute
+
+// ReminderWindowHours is how long reminders are sent for after a medication is due, after which the dose is missed
+const ReminderWindowHours = 5
+
+// MaxSleepInDeferHours caps how long morning reminders are held, leaving at least an hour of a dose's
+// reminder window
+const MaxSleepInDeferHours = ReminderWindowHours - 1
+
+// SleepInMorningEndHour is the hour from which doses are no longer morning doses that can be held
+const SleepInMorningEndHour = 12
+
+// Events a medication's time can be anchored to
+const (
+	AnchorSunrise = "sunrise"
+	AnchorSunset  = "sunset"
+	// AnchorWake follows the day's "/meds awake" check-in
+	AnchorWake = "wake"
+)
+
+// FrequencyAsNeeded is the frequency of a medication taken when needed rather than on a schedule.
+// It gets no reminders, and its doses are recorded with "/meds log".
+const FrequencyAsNeeded = "as_needed"
+
+// Medication priority levels
+const (
+	PriorityLow      = "low"
+	PriorityNormal   = "normal"
+	PriorityCritical = "critical"
+)
+
 type Config struct {
 	DiscordToken         string
 	DiscordChannelID     string
@@ -29,13 +103,236 @@ type Config struct {
 	Medications          []Medication
 	DBPath               string
 	Timezone             string
+	QuietHoursStart      int
+	QuietHoursEnd        int
+	EscalateAfterNags    int
+	ReminderMode         string
+	ChecklistHour        int
+	PublicURL            string
+	AckLinkSecret        string
+	AckLinkTTLHours      int
+	ReminderQRCode       bool
+	ExportToken          string
+	RefillReminderDays   int
+	RefillReminderHour   int
+	WeeklyReportDay      string
+	WeeklyReportHour     int
+	StockWarningDays     int
+	LabReminderHour      int
+	Latitude             float64
+	Longitude            float64
+	ReminderTemplate     string
+	// Encouragement appends a rotating encouragement line to reminders and acknowledgments
+	Encouragement         bool
+	EncouragementMessages []string
+	// ReminderSound is the path of an audio file attached to reminder messages
+	ReminderSound string
+	// AccessibleReminders uses simplified reminders by default, until the user chooses otherwise
+	AccessibleReminders bool
+	// ReminderWorkers is how many medications' reminders are sent at once, bounded to respect Discord rate limits
+	ReminderWorkers int
+	// OperatorChannelID is where losing access to the reminder channel is reported,
+	// instead of messaging DiscordUserIDToPing
+	OperatorChannelID string
+	// ArchiveChannelID is where each day's doses are summarized before its reminder messages
+	// are deleted from the reminder channel
+	ArchiveChannelID string
+	// MessageRetentionDays is how long the bot's messages are kept in the reminder channel before being
+	// cleaned up, unless a pending reminder still uses them. Zero keeps them forever.
+	MessageRetentionDays int
+	// ProofRetentionDays is how long photos of doses are kept before being deleted, since they're more
+	// sensitive than the record of the dose itself, which is kept. Zero keeps them forever.
+	ProofRetentionDays int
+	// ExtraIntents are gateway intents requested in addition to the ones the bot needs, by name, e.g. "guild_messages"
+	ExtraIntents []string
+	// MinimalPermissions runs without the Manage Messages permission, editing messages that would be deleted
+	MinimalPermissions bool
+	// DiscordTokenFile is a file the token is read from, such as a mounted secret, and reloaded from when it changes
+	DiscordTokenFile string
+	// FeedbackWebhookURL is a Discord webhook /meds feedback is forwarded to, empty only records it
+	FeedbackWebhookURL string
+	// InteractionSecret signs the custom IDs of buttons, select menus and modals, empty generates a key kept in the database
+	InteractionSecret string
+	// DBDriver is the store used, where the memory driver keeps everything in memory and nothing on disk
+	DBDriver string
+	// DatabaseURL is the Postgres database connected to by the postgres driver
+	DatabaseURL string
+	// LitestreamReplicaURL is the Litestream replica the database is restored from when missing,
+	// and enables settings compatible with Litestream replicating it
+	LitestreamReplicaURL string
+	// DBReadDSN is a read-only replica that reporting queries are served from, empty uses the primary database
+	DBReadDSN string
+	// GuildOnboarding starts a setup flow when the bot is added to a new guild
+	GuildOnboarding bool
+	// StartupRetries is how many times a failure to connect to the database or Discord on startup is retried
+	StartupRetries int
+	// StartupBackoffSecs is the delay before the first startup retry, doubling for each retry after it
+	StartupBackoffSecs int
+	// LogFile is a file logs are also written to, rotated when it reaches LogFileMaxSizeMB
+	LogFile           string
+	LogFileMaxSizeMB  int
+	LogFileMaxBackups int
+	// LogFileMaxAgeDays is how long rotated log files are kept, 0 keeps them regardless of age
+	LogFileMaxAgeDays int
+	// LogLevel is the minimum level logged, and LogLevels overrides it per component, e.g. "discord=debug,db=warn"
+	LogLevel  string
+	LogLevels string
+	// HTTP server settings, where zero timeouts use the server defaults
+	HTTPAddr             string
+	HTTPReadTimeoutSecs  int
+	HTTPWriteTimeoutSecs int
+	HTTPIdleTimeoutSecs  int
+	CORSAllowedOrigins   []string
+	// RateLimitPerMinute limits API and acknowledgment link requests per client IP and per token, 0 disables it
+	RateLimitPerMinute  int
+	RateLimitBurst      int
+	RateLimitTrustProxy bool
+	// GraphQLEnabled serves the GraphQL history API, authenticated with the export token
+	GraphQLEnabled bool
+	// GRPCAddr is the address the gRPC API listens on, authenticated with the export token, empty disables it
+	GRPCAddr string
+	// Discord OAuth login for the web dashboard, which is enabled when a client ID is set
+	DiscordClientID        string
+	DiscordClientSecret    string
+	DashboardSessionSecret string
+	// DashboardGuildID admits members of a guild to the dashboard
+	DashboardGuildID string
+	// DashboardAllowedUsers admits specific Discord user IDs to the dashboard
+	DashboardAllowedUsers []string
+	// TelemetryEnabled opts in to sending anonymous usage reports to TelemetryURL
+	TelemetryEnabled bool
+	TelemetryURL     string
+	// SleepInDeferHours holds morning reminders for up to this many hours until the user is seen
+	// active that day, 0 disables it
+	SleepInDeferHours int
+	// PresenceRouting chooses how reminders reach the user from their Discord presence
+	PresenceRouting bool
+	// MQTTBrokerURL is the MQTT broker medications are published to as Home Assistant devices, empty
+	// disables it. MQTTTopicPrefix prefixes the bot's own topics and HADiscoveryPrefix is the prefix
+	// Home Assistant discovers devices under.
+	MQTTBrokerURL     string
+	MQTTUsername      string
+	MQTTPassword      string
+	MQTTTopicPrefix   string
+	HADiscoveryPrefix string
+	// AckHookToken authenticates the inbound webhook that marks doses as taken, empty disables it
+	AckHookToken string
+	// AdminToken authenticates the endpoint changing log levels, empty disables it
+	AdminToken string
+	// GRPCWriteToken authenticates gRPC calls that acknowledge doses or change medications, which the
+	// read-only export token can't make. Empty refuses them.
+	GRPCWriteToken string
+	// AssistantToken authenticates Alexa and Dialogflow requests to the voice assistant fulfillment
+	// endpoint, empty disables it
+	AssistantToken string
+	// CalDAVEnabled serves doses as a read-only CalDAV calendar, authenticated with the export token
+	CalDAVEnabled bool
+	// FailoverChannels are the backup channels reminders are delivered by, tried in order, once
+	// FailoverAfterFailures reminders in a row have failed to send or the gateway has been disconnected
+	// for FailoverGatewayDownMinutes. Empty disables failover.
+	FailoverChannels           []string
+	FailoverAfterFailures      int
+	FailoverGatewayDownMinutes int
+	// NtfyURL is the ntfy topic backup reminders are published to, e.g. https://ntfy.sh/my-meds
+	NtfyURL   string
+	NtfyToken string
+	// SMTPAddr is the mail server (host:port) backup reminders are emailed through
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	EmailFrom    string
+	EmailTo      string
+	// Twilio account backup reminders are texted from
+	TwilioAccountSID string
+	TwilioAuthToken  string
+	SMSFrom          string
+	SMSTo            string
+	// LowSupplyDoses is how many doses of a medication with tracked stock are left when a warning to
+	// refill it is sent, 0 disables the warning
+	LowSupplyDoses int
+	// CommandCooldownSecs is how long each user waits between runs of an expensive command such as
+	// /meds stats, and GuildCommandCooldownSecs how long each guild does, so one busy server can't use up
+	// the bot's shared Discord rate limits. 0 disables each of them.
+	CommandCooldownSecs      int
+	GuildCommandCooldownSecs int
+	// AcknowledgedTemplate replaces the wording of reminder messages once their dose is marked as taken
+	AcknowledgedTemplate string
 }
 
 type Medication struct {
 	Name      string
 	Hour      int
+	Minute    int
 	Frequency string
 	Day       string
+	Priority  string
+	// LeadTimeMins sends a heads-up this many minutes before the medication is due, 0 disables it
+	LeadTimeMins int
+	// Anchor schedules the medication relative to sunrise, sunset or waking up instead of at Hour,
+	// which is still used on days without a sunrise, sunset or wake check-in
+	Anchor           string
+	AnchorOffsetMins int
+	// ButtonLabel, ButtonEmoji and ButtonStyle customise the button for marking the medication as taken
+	ButtonLabel string
+	ButtonEmoji string
+	ButtonStyle string
+	// Template overrides the reminder message template for the medication
+	Template string
+	// Sound overrides the audio file attached to the medication's reminders
+	Sound string
+	// Image is a local file path or URL of a picture of the pill, shown on reminders
+	Image string
+	// WebhookURL is notified when a dose of the medication is taken or missed, with WebhookHeaders
+	// added to each request, e.g. to authenticate with a clinic's portal
+	WebhookURL     string
+	WebhookHeaders map[string]string
+	// UserID is the Discord user the medication is for, who is pinged for it and the only one who can
+	// mark it as taken. It defaults to DiscordUserIDToPing.
+	UserID string
+	// MinGapHours asks for confirmation before recording a dose this soon after the last one, 0 disables it
+	MinGapHours int
+	// MaxDosesPer24h limits how many doses of an as-needed medication can be logged in any 24 hours,
+	// 0 disables the limit. Going over it asks for confirmation, or is refused with BlockOverLimit.
+	MaxDosesPer24h int
+	BlockOverLimit bool
+	// GuardianID is the Discord user who confirms doses of a child's medication. When set, the user it's
+	// for marking it as taken leaves the dose pending until the guardian confirms it.
+	GuardianID string
+	// Times are the times (HH:MM) of a medication taken more than once a day. Validation replaces it with
+	// a medication for each dose, named after it and the dose's time, e.g. "Metformin 08:00".
+	Times []string
+	// BaseName is the name of the medication a dose taken more than once a day belongs to, which its
+	// details and stock are recorded under, and DailyDoses is how many doses of it are taken a day
+	BaseName   string
+	DailyDoses int
+	// Confirmations is how many different people must confirm a dose before it's recorded as taken,
+	// starting with the user it's for and followed by its guardian or Confirmers, e.g. a nurse for a
+	// high-risk medication. It defaults to 2 with a guardian, or otherwise 1.
+	Confirmations int
+	Confirmers    []string
+	// PhotoProof asks for a photo of each dose when it's marked as taken, for supervised regimens. With
+	// PhotoProofRequired the dose isn't recorded until the photo is attached, and empty never asks for one.
+	PhotoProof string
+	// Schedule is a cron expression (minute hour day month weekday) for the days and times the medication
+	// is due, such as "0 9 * * 1#1" for the first Monday of each month. It replaces Frequency, Day and
+	// Hour, and a schedule with several times a day is taken as its dose Times. It can instead be an
+	// interval such as "every 2d from 2024-05-01", which only sets the days and keeps Hour.
+	Schedule string
+	// Timezone overrides Timezone for the medication, e.g. for someone in another country, so it's due at
+	// Hour there. Trips don't move it.
+	Timezone string
+}
+
+// PriorityPolicy describes how reminders for a medication behave based on its priority
+type PriorityPolicy struct {
+	// PingUser mentions the configured user in reminder messages
+	PingUser bool
+	// NagEvery is the number of reminder intervals to wait between nags
+	NagEvery int
+	// Escalate allows the reminder to escalate after repeated unacknowledged nags
+	Escalate bool
+	// OverrideQuietHours allows reminders to be sent during quiet hours
+	OverrideQuietHours bool
 }
 
 // LoadConfig loads the application configuration from environment variables by default
@@ -79,8 +376,34 @@ func LoadJSONConfig(configPath string) (*Config, error) {
 	return &config, nil
 }
 
+// ReadTokenFile reads a Discord token from a file, such as a mounted secret, ignoring surrounding whitespace
+func ReadTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Discord token file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("Discord token file %s is empty", path)
+	}
+
+	return token, nil
+}
+
 // validateConfig validates the configuration
 func validateConfig(cfg *Config) error {
+	if cfg.DiscordTokenFile != "" {
+		if cfg.DiscordToken != "" {
+			return fmt.Errorf("only one of DISCORD_TOKEN and DISCORD_TOKEN_FILE can be set")
+		}
+		token, err := ReadTokenFile(cfg.DiscordTokenFile)
+		if err != nil {
+			return err
+		}
+		cfg.DiscordToken = token
+	}
+
 	if cfg.DiscordToken == "" {
 		return fmt.Errorf("Discord token is required")
 	}
@@ -89,39 +412,439 @@ func validateConfig(cfg *Config) error {
 		return fmt.Errorf("Discord channel ID is required")
 	}
 
+	if cfg.ArchiveChannelID == cfg.DiscordChannelID {
+		return fmt.Errorf("archive channel must be different from the reminder channel")
+	}
+
 	if cfg.ReminderIntervalMins < 1 {
 		return fmt.Errorf("reminder interval must be at least 1 minute")
 	}
 
+	if cfg.ReminderWorkers < 1 {
+		return fmt.Errorf("reminder workers must be at least 1")
+	}
+
+	if cfg.LogFile != "" && cfg.LogFileMaxSizeMB < 1 {
+		return fmt.Errorf("log file max size must be at least 1 MB")
+	}
+
+	if cfg.LogFileMaxBackups < 0 || cfg.LogFileMaxAgeDays < 0 {
+		return fmt.Errorf("log file max backups and max age cannot be negative")
+	}
+
+	// Validate log level, defaulting to info
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = "info"
+	}
+	if _, err := logging.ParseLevel(cfg.LogLevel); err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+
+	if _, err := logging.ParseLevels(cfg.LogLevels); err != nil {
+		return fmt.Errorf("invalid component log levels: %w", err)
+	}
+
+	if cfg.StartupRetries < 0 {
+		return fmt.Errorf("startup retries cannot be negative")
+	}
+
+	if cfg.StartupRetries > 0 && cfg.StartupBackoffSecs < 1 {
+		return fmt.Errorf("startup retry backoff must be at least 1 second")
+	}
+
 	if len(cfg.Medications) == 0 {
 		return fmt.Errorf("at least one medication is required")
 	}
 
+	medications, err := applySchedules(cfg.Medications)
+	if err != nil {
+		return err
+	}
+	medications, err = expandDoseTimes(medications)
+	if err != nil {
+		return err
+	}
+	cfg.Medications = medications
+
 	for i, med := range cfg.Medications {
 		if med.Name == "" {
 			return fmt.Errorf("medication #%d has no name", i+1)
 		}
+		if len(med.Name) > MaxMedicationNameBytes {
+			return fmt.Errorf("medication %s has a name longer than %d bytes, which doesn't fit in its button", med.Name, MaxMedicationNameBytes)
+		}
 		if med.Hour < 0 || med.Hour > 23 {
 			return fmt.Errorf("medication %s has invalid hour: %d (must be between 0 and 23)", med.Name, med.Hour)
 		}
+		if med.Minute < 0 || med.Minute > 59 {
+			return fmt.Errorf("medication %s has invalid minute: %d (must be between 0 and 59)", med.Name, med.Minute)
+		}
 
 		// Validate frequency
 		if med.Frequency == "" {
 			med.Frequency = "daily" // Default to daily if not specified
-		} else if med.Frequency != "daily" && med.Frequency != "weekly" {
-			return fmt.Errorf("medication %s has invalid frequency: %s (must be 'daily' or 'weekly')", med.Name, med.Frequency)
+		} else if med.Frequency != "daily" && med.Frequency != "weekly" && med.Frequency != FrequencyAsNeeded {
+			return fmt.Errorf("medication %s has invalid frequency: %s (must be 'daily', 'weekly' or '%s')", med.Name, med.Frequency, FrequencyAsNeeded)
 		}
 
 		// Validate day for weekly medications
 		if med.Frequency == "weekly" && med.Day == "" {
 			return fmt.Errorf("medication %s has weekly frequency but no day specified", med.Name)
 		}
+
+		if med.LeadTimeMins < 0 {
+			return fmt.Errorf("medication %s has invalid lead time: %d (must not be negative)", med.Name, med.LeadTimeMins)
+		}
+
+		switch strings.ToLower(med.Anchor) {
+		case "":
+		case AnchorSunrise, AnchorSunset:
+			if !cfg.HasCoordinates() {
+				return fmt.Errorf("medication %s is anchored to %s but LATITUDE and LONGITUDE are not set", med.Name, med.Anchor)
+			}
+			cfg.Medications[i].Anchor = strings.ToLower(med.Anchor)
+		case AnchorWake:
+			cfg.Medications[i].Anchor = AnchorWake
+		default:
+			return fmt.Errorf("medication %s has invalid anchor: %s (must be 'sunrise', 'sunset' or 'wake')", med.Name, med.Anchor)
+		}
+
+		if med.WebhookURL != "" && !strings.HasPrefix(med.WebhookURL, "https://") && !strings.HasPrefix(med.WebhookURL, "http://") {
+			return fmt.Errorf("medication %s has invalid webhook URL: %s (must be an http or https URL)", med.Name, med.WebhookURL)
+		}
+		if len(med.WebhookHeaders) > 0 && med.WebhookURL == "" {
+			return fmt.Errorf("medication %s has webhook headers but no webhook URL", med.Name)
+		}
+
+		if med.MinGapHours < 0 {
+			return fmt.Errorf("medication %s has invalid minimum gap: %d (must not be negative)", med.Name, med.MinGapHours)
+		}
+
+		if med.MaxDosesPer24h < 0 {
+			return fmt.Errorf("medication %s has invalid dose limit: %d (must not be negative)", med.Name, med.MaxDosesPer24h)
+		}
+		if med.MaxDosesPer24h > 0 && !med.AsNeeded() {
+			return fmt.Errorf("medication %s has a dose limit but isn't taken as needed (set its frequency to '%s')", med.Name, FrequencyAsNeeded)
+		}
+		if med.BlockOverLimit && med.MaxDosesPer24h == 0 {
+			return fmt.Errorf("medication %s blocks doses over its limit but has no dose limit", med.Name)
+		}
+
+		// Doses are tracked by medication name, so users sharing the bot need distinct names
+		for _, other := range cfg.Medications[:i] {
+			if other.Name == med.Name {
+				return fmt.Errorf("medication %s is configured more than once (give each user's medication its own name)", med.Name)
+			}
+		}
+		if med.UserID == "" {
+			cfg.Medications[i].UserID = cfg.DiscordUserIDToPing
+		}
+		if med.GuardianID != "" && med.GuardianID == cfg.Medications[i].UserID {
+			return fmt.Errorf("medication %s has the user it's for as its guardian (the guardian must be someone else)", med.Name)
+		}
+		if med.Confirmations < 0 {
+			return fmt.Errorf("medication %s has invalid confirmations: %d (must not be negative)", med.Name, med.Confirmations)
+		}
+		if required, confirmers := cfg.Medications[i].RequiredConfirmations(), len(cfg.Medications[i].ConfirmingUsers()); required > confirmers {
+			return fmt.Errorf("medication %s needs %d confirmations but only %d people can confirm it (add them to its confirmers)", med.Name, required, confirmers)
+		}
+		if med.AsNeeded() && cfg.Medications[i].RequiredConfirmations() > 1 {
+			return fmt.Errorf("medication %s is taken as needed, so its doses can't need confirming", med.Name)
+		}
+
+		switch photoProof := strings.ToLower(med.PhotoProof); photoProof {
+		case "", PhotoProofOptional, PhotoProofRequired:
+			cfg.Medications[i].PhotoProof = photoProof
+		default:
+			return fmt.Errorf("medication %s has invalid photo proof: %s (must be '%s' or '%s')", med.Name, med.PhotoProof, PhotoProofOptional, PhotoProofRequired)
+		}
+		if med.AsNeeded() && cfg.Medications[i].PhotoProof != "" {
+			return fmt.Errorf("medication %s is taken as needed, so its doses can't ask for a photo", med.Name)
+		}
+		if cfg.Medications[i].PhotoProof == PhotoProofRequired && cfg.Medications[i].RequiredConfirmations() > 1 {
+			return fmt.Errorf("medication %s can't both require a photo and need more than one confirmation", med.Name)
+		}
+
+		if med.Timezone != "" {
+			if _, err := time.LoadLocation(med.Timezone); err != nil {
+				return fmt.Errorf("medication %s has invalid timezone: %s - %w", med.Name, med.Timezone, err)
+			}
+		}
+
+		if utf8.RuneCountInString(med.ButtonLabel) > 80 {
+			return fmt.Errorf("medication %s has a button label longer than 80 characters", med.Name)
+		}
+
+		switch strings.ToLower(med.ButtonStyle) {
+		case "", "primary", "secondary", "success", "danger":
+			cfg.Medications[i].ButtonStyle = strings.ToLower(med.ButtonStyle)
+		default:
+			return fmt.Errorf("medication %s has invalid button style: %s (must be 'primary', 'secondary', 'success' or 'danger')", med.Name, med.ButtonStyle)
+		}
+
+		if med.Template != "" {
+			if _, err := template.New(med.Name).Parse(med.Template); err != nil {
+				return fmt.Errorf("medication %s has invalid template: %w", med.Name, err)
+			}
+		}
+
+		if err := validateSound(med.Sound); err != nil {
+			return fmt.Errorf("medication %s has invalid sound: %w", med.Name, err)
+		}
+
+		if err := validateImage(med.Image); err != nil {
+			return fmt.Errorf("medication %s has invalid image: %w", med.Name, err)
+		}
+
+		// Validate priority, defaulting to normal
+		switch strings.ToLower(med.Priority) {
+		case "":
+			cfg.Medications[i].Priority = PriorityNormal
+		case PriorityLow, PriorityNormal, PriorityCritical:
+			cfg.Medications[i].Priority = strings.ToLower(med.Priority)
+		default:
+			return fmt.Errorf("medication %s has invalid priority: %s (must be 'low', 'normal' or 'critical')", med.Name, med.Priority)
+		}
+	}
+
+	if cfg.ReminderTemplate != "" {
+		if _, err := template.New("reminder").Parse(cfg.ReminderTemplate); err != nil {
+			return fmt.Errorf("invalid reminder template: %w", err)
+		}
+	}
+
+	if cfg.AcknowledgedTemplate != "" {
+		if _, err := template.New("acknowledged").Parse(cfg.AcknowledgedTemplate); err != nil {
+			return fmt.Errorf("invalid acknowledged template: %w", err)
+		}
+	}
+
+	if cfg.HTTPAddr == "" {
+		cfg.HTTPAddr = ":8080"
+	}
+
+	if cfg.HTTPReadTimeoutSecs < 0 || cfg.HTTPWriteTimeoutSecs < 0 || cfg.HTTPIdleTimeoutSecs < 0 {
+		return fmt.Errorf("HTTP timeouts must not be negative")
+	}
+
+	if cfg.RateLimitPerMinute < 0 || cfg.RateLimitBurst < 0 {
+		return fmt.Errorf("rate limits must not be negative")
+	}
+
+	if cfg.GraphQLEnabled && cfg.ExportToken == "" {
+		return fmt.Errorf("the GraphQL API requires an export token")
+	}
+
+	if cfg.CalDAVEnabled && cfg.ExportToken == "" {
+		return fmt.Errorf("the CalDAV calendar requires an export token")
+	}
+
+	if cfg.GRPCAddr != "" && cfg.ExportToken == "" {
+		return fmt.Errorf("the gRPC API requires an export token")
+	}
+
+	if cfg.AdminToken != "" && cfg.AdminToken == cfg.ExportToken {
+		return fmt.Errorf("ADMIN_TOKEN must be different from EXPORT_TOKEN, which is shared with more integrations")
+	}
+
+	if cfg.GRPCWriteToken != "" && cfg.GRPCWriteToken == cfg.ExportToken {
+		return fmt.Errorf("GRPC_WRITE_TOKEN must be different from EXPORT_TOKEN, which only allows reading")
+	}
+
+	if cfg.DashboardEnabled() {
+		if cfg.DiscordClientSecret == "" || cfg.DashboardSessionSecret == "" || cfg.PublicURL == "" {
+			return fmt.Errorf("the dashboard requires a Discord client secret, session secret and public URL")
+		}
+		if cfg.DashboardGuildID == "" && len(cfg.DashboardAllowedUsers) == 0 && cfg.DiscordUserIDToPing == "" {
+			return fmt.Errorf("the dashboard requires a guild, allowed users or a user to ping")
+		}
+	}
+
+	if err := validateSound(cfg.ReminderSound); err != nil {
+		return fmt.Errorf("invalid reminder sound: %w", err)
+	}
+
+	if cfg.Latitude < -90 || cfg.Latitude > 90 {
+		return fmt.Errorf("invalid latitude: %v (must be between -90 and 90)", cfg.Latitude)
+	}
+	if cfg.Longitude < -180 || cfg.Longitude > 180 {
+		return fmt.Errorf("invalid longitude: %v (must be between -180 and 180)", cfg.Longitude)
+	}
+
+	if cfg.QuietHoursStart < 0 || cfg.QuietHoursStart > 23 {
+		return fmt.Errorf("invalid quiet hours start: %d (must be between 0 and 23)", cfg.QuietHoursStart)
+	}
+	if cfg.QuietHoursEnd < 0 || cfg.QuietHoursEnd > 23 {
+		return fmt.Errorf("invalid quiet hours end: %d (must be between 0 and 23)", cfg.QuietHoursEnd)
+	}
+
+	if cfg.SleepInDeferHours < 0 || cfg.SleepInDeferHours > MaxSleepInDeferHours {
+		return fmt.Errorf("invalid sleep-in defer hours: %d (must be between 0 and %d)", cfg.SleepInDeferHours, MaxSleepInDeferHours)
+	}
+
+	if cfg.EscalateAfterNags < 0 {
+		return fmt.Errorf("escalate after nags must not be negative")
+	}
+
+	// Validate reminder mode, defaulting to individual messages
+	switch strings.ToLower(cfg.ReminderMode) {
+	case "":
+		cfg.ReminderMode = ReminderModeIndividual
+	case ReminderModeIndividual, ReminderModeChecklist:
+		cfg.ReminderMode = strings.ToLower(cfg.ReminderMode)
+	default:
+		return fmt.Errorf("invalid reminder mode: %s (must be 'individual' or 'checklist')", cfg.ReminderMode)
+	}
+
+	if cfg.ChecklistHour < 0 || cfg.ChecklistHour > 23 {
+		return fmt.Errorf("invalid checklist hour: %d (must be between 0 and 23)", cfg.ChecklistHour)
+	}
+
+	if cfg.AckLinkTTLHours < 0 {
+		return fmt.Errorf("acknowledgment link TTL must not be negative")
+	} else if cfg.AckLinkTTLHours == 0 {
+		cfg.AckLinkTTLHours = 12
+	}
+
+	if cfg.RefillReminderDays < 0 {
+		return fmt.Errorf("refill reminder days must not be negative")
+	}
+
+	if cfg.RefillReminderHour < 0 || cfg.RefillReminderHour > 23 {
+		return fmt.Errorf("invalid refill reminder hour: %d (must be between 0 and 23)", cfg.RefillReminderHour)
+	}
+
+	if cfg.LabReminderHour < 0 || cfg.LabReminderHour > 23 {
+		return fmt.Errorf("invalid lab reminder hour: %d (must be between 0 and 23)", cfg.LabReminderHour)
+	}
+
+	if cfg.WeeklyReportDay != "" {
+		if _, ok := ParseWeekday(cfg.WeeklyReportDay); !ok {
+			return fmt.Errorf("invalid weekly report day: %s", cfg.WeeklyReportDay)
+		}
+	}
+
+	if cfg.WeeklyReportHour < 0 || cfg.WeeklyReportHour > 23 {
+		return fmt.Errorf("invalid weekly report hour: %d (must be between 0 and 23)", cfg.WeeklyReportHour)
+	}
+
+	if cfg.StockWarningDays < 0 {
+		return fmt.Errorf("stock warning days must not be negative")
+	} else if cfg.StockWarningDays == 0 {
+		cfg.StockWarningDays = 14
+	}
+
+	if cfg.MessageRetentionDays < 0 {
+		return fmt.Errorf("message retention days must not be negative")
+	}
+
+	if cfg.LowSupplyDoses < 0 {
+		return fmt.Errorf("LOW_SUPPLY_DOSES must not be negative")
+	}
+
+	if cfg.CommandCooldownSecs < 0 || cfg.GuildCommandCooldownSecs < 0 {
+		return fmt.Errorf("command cooldowns must not be negative")
+	}
+
+	if cfg.ProofRetentionDays < 0 {
+		return fmt.Errorf("proof retention days must not be negative")
+	}
+
+	if cfg.MinimalPermissions && cfg.MessageRetentionDays > 0 {
+		return fmt.Errorf("MESSAGE_RETENTION_DAYS can't be used with DISCORD_MINIMAL_PERMISSIONS, which doesn't delete messages")
+	}
+
+	for i, intent := range cfg.ExtraIntents {
+		intent = strings.ToLower(intent)
+		if !slices.Contains(IntentNames, intent) {
+			return fmt.Errorf("unknown gateway intent: %s (must be one of %s)", cfg.ExtraIntents[i], strings.Join(IntentNames, ", "))
+		}
+		cfg.ExtraIntents[i] = intent
+	}
+
+	if cfg.PresenceRouting && !slices.Contains(cfg.ExtraIntents, "guild_presences") {
+		return fmt.Errorf("presence routing needs the guild_presences intent in DISCORD_EXTRA_INTENTS")
+	}
+	if cfg.SleepInDeferHours > 0 && !slices.Contains(cfg.ExtraIntents, "guild_presences") {
+		return fmt.Errorf("SLEEP_IN_DEFER_HOURS needs the guild_presences intent in DISCORD_EXTRA_INTENTS to see when you come online")
+	}
+
+	if cfg.FeedbackWebhookURL != "" && !strings.HasPrefix(cfg.FeedbackWebhookURL, "https://") && !strings.HasPrefix(cfg.FeedbackWebhookURL, "http://") {
+		return fmt.Errorf("FEEDBACK_WEBHOOK_URL must be an http or https URL")
+	}
+
+	if cfg.TelemetryEnabled && !strings.HasPrefix(cfg.TelemetryURL, "https://") && !strings.HasPrefix(cfg.TelemetryURL, "http://") {
+		return fmt.Errorf("TELEMETRY_ENABLED requires TELEMETRY_URL to be set to an http or https URL")
+	}
+
+	if cfg.MQTTBrokerURL != "" {
+		if !strings.HasPrefix(cfg.MQTTBrokerURL, "tcp://") && !strings.HasPrefix(cfg.MQTTBrokerURL, "ssl://") &&
+			!strings.HasPrefix(cfg.MQTTBrokerURL, "ws://") && !strings.HasPrefix(cfg.MQTTBrokerURL, "wss://") {
+			return fmt.Errorf("MQTT_BROKER_URL must be a tcp, ssl, ws or wss URL, e.g. tcp://homeassistant.local:1883")
+		}
+		if cfg.MQTTTopicPrefix == "" {
+			cfg.MQTTTopicPrefix = "meds-bot"
+		}
+		if cfg.HADiscoveryPrefix == "" {
+			cfg.HADiscoveryPrefix = "homeassistant"
+		}
+		if strings.ContainsAny(cfg.MQTTTopicPrefix+cfg.HADiscoveryPrefix, "#+") {
+			return fmt.Errorf("MQTT_TOPIC_PREFIX and HA_DISCOVERY_PREFIX can't contain MQTT wildcards")
+		}
+	}
+
+	for _, channel := range cfg.FailoverChannels {
+		switch channel {
+		case FailoverNtfy:
+			if !strings.HasPrefix(cfg.NtfyURL, "https://") && !strings.HasPrefix(cfg.NtfyURL, "http://") {
+				return fmt.Errorf("failing over to ntfy requires NTFY_URL to be set to an http or https URL")
+			}
+		case FailoverEmail:
+			if cfg.SMTPAddr == "" || cfg.EmailFrom == "" || cfg.EmailTo == "" {
+				return fmt.Errorf("failing over to email requires SMTP_ADDR, EMAIL_FROM and EMAIL_TO to be set")
+			}
+		case FailoverSMS:
+			if cfg.TwilioAccountSID == "" || cfg.TwilioAuthToken == "" || cfg.SMSFrom == "" || cfg.SMSTo == "" {
+				return fmt.Errorf("failing over to SMS requires TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN, SMS_FROM and SMS_TO to be set")
+			}
+		default:
+			return fmt.Errorf("FAILOVER_CHANNELS must be a list of %q, %q and %q, got %q", FailoverNtfy, FailoverEmail, FailoverSMS, channel)
+		}
+	}
+	if len(cfg.FailoverChannels) > 0 && (cfg.FailoverAfterFailures < 1 || cfg.FailoverGatewayDownMinutes < 1) {
+		return fmt.Errorf("FAILOVER_AFTER_FAILURES and FAILOVER_GATEWAY_DOWN_MINUTES must be at least 1")
+	}
+
+	if cfg.ReminderQRCode && !cfg.AckLinksEnabled() {
+		return fmt.Errorf("reminder QR codes require PUBLIC_URL and ACK_LINK_SECRET to be set")
 	}
 
 	if cfg.DBPath == "" {
 		cfg.DBPath = "./meds_reminder.db"
 	}
 
+	if cfg.DBDriver == "" {
+		cfg.DBDriver = DBDriverSQLite
+	} else if cfg.DBDriver != DBDriverSQLite && cfg.DBDriver != DBDriverMemory && cfg.DBDriver != DBDriverPostgres {
+		return fmt.Errorf("DB_DRIVER must be %q, %q or %q", DBDriverSQLite, DBDriverMemory, DBDriverPostgres)
+	}
+
+	if cfg.DBDriver == DBDriverPostgres && cfg.DatabaseURL == "" {
+		return fmt.Errorf("DATABASE_URL is required by the postgres driver")
+	}
+
+	if cfg.LitestreamReplicaURL != "" && cfg.DBDriver == DBDriverPostgres {
+		return fmt.Errorf("LITESTREAM_REPLICA_URL can't be used with the postgres driver")
+	}
+
+	if cfg.LitestreamReplicaURL != "" && cfg.DBDriver == DBDriverMemory {
+		return fmt.Errorf("LITESTREAM_REPLICA_URL can't be used with the memory driver")
+	}
+
+	if cfg.DBReadDSN != "" && cfg.DBDriver == DBDriverMemory {
+		return fmt.Errorf("DB_READ_DSN can't be used with the memory driver")
+	}
+
 	// Validate and set default timezone
 	if cfg.Timezone == "" {
 		cfg.Timezone = "UTC"
@@ -146,8 +869,19 @@ func LoadEnvConfig() (*Config, error) {
 	}
 
 	token := os.Getenv("DISCORD_TOKEN")
+	tokenFile := os.Getenv("DISCORD_TOKEN_FILE")
 	channelID := os.Getenv("DISCORD_CHANNEL_ID")
 	userIDToPing := os.Getenv("DISCORD_USER_ID_TO_PING")
+	operatorChannelID := os.Getenv("DISCORD_OPERATOR_CHANNEL_ID")
+	archiveChannelID := os.Getenv("DISCORD_ARCHIVE_CHANNEL_ID")
+	minimalPermissions := strings.EqualFold(os.Getenv("DISCORD_MINIMAL_PERMISSIONS"), "true")
+
+	var extraIntents []string
+	for _, intent := range strings.Split(os.Getenv("DISCORD_EXTRA_INTENTS"), ",") {
+		if intent = strings.TrimSpace(intent); intent != "" {
+			extraIntents = append(extraIntents, intent)
+		}
+	}
 
 	intervalStr := os.Getenv("REMINDER_INTERVAL_MINUTES")
 	interval := 30
@@ -159,7 +893,42 @@ func LoadEnvConfig() (*Config, error) {
 		interval = parsedInterval
 	}
 
+	reminderWorkers, err := getEnvInt("REMINDER_WORKERS", 4)
+	if err != nil {
+		return nil, err
+	}
+
+	startupRetries, err := getEnvInt("STARTUP_RETRIES", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	startupBackoffSecs, err := getEnvInt("STARTUP_RETRY_BACKOFF_SECONDS", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	logFileMaxSizeMB, err := getEnvInt("LOG_FILE_MAX_SIZE_MB", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	logFileMaxBackups, err := getEnvInt("LOG_FILE_MAX_BACKUPS", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	logFileMaxAgeDays, err := getEnvInt("LOG_FILE_MAX_AGE_DAYS", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	dbDriver := strings.ToLower(os.Getenv("DB_DRIVER"))
 	dbPath := os.Getenv("DB_PATH")
+	databaseURL := os.Getenv("DATABASE_URL")
+	litestreamReplicaURL := os.Getenv("LITESTREAM_REPLICA_URL")
+	dbReadDSN := os.Getenv("DB_READ_DSN")
+	guildOnboarding := strings.EqualFold(os.Getenv("GUILD_ONBOARDING"), "true")
 	if dbPath == "" {
 		dbPath = "./meds_reminder.db"
 	}
@@ -169,20 +938,215 @@ func LoadEnvConfig() (*Config, error) {
 		timezone = "UTC" // Default to UTC if not specified
 	}
 
-	var medications []Medication
+	quietHoursStart, err := getEnvInt("QUIET_HOURS_START", 0)
+	if err != nil {
+		return nil, err
+	}
 
-	// Dynamically load all medications from environment variables
-	for i := 1; ; i++ {
-		nameKey := fmt.Sprintf("MED_%d_NAME", i)
-		name := os.Getenv(nameKey)
+	quietHoursEnd, err := getEnvInt("QUIET_HOURS_END", 0)
+	if err != nil {
+		return nil, err
+	}
 
-		// Exit case, no env found
-		if name == "" {
-			break
-		}
+	sleepInDeferHours, err := getEnvInt("SLEEP_IN_DEFER_HOURS", 0)
+	if err != nil {
+		return nil, err
+	}
 
-		hourKey := fmt.Sprintf("MED_%d_HOUR", i)
-		hourStr := os.Getenv(hourKey)
+	escalateAfterNags, err := getEnvInt("ESCALATE_AFTER_NAGS", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	reminderMode := os.Getenv("REMINDER_MODE")
+
+	checklistHour, err := getEnvInt("CHECKLIST_HOUR", 7)
+	if err != nil {
+		return nil, err
+	}
+
+	publicURL := os.Getenv("PUBLIC_URL")
+	ackLinkSecret := os.Getenv("ACK_LINK_SECRET")
+
+	ackLinkTTLHours, err := getEnvInt("ACK_LINK_TTL_HOURS", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	reminderQRCode := strings.EqualFold(os.Getenv("REMINDER_QR_CODE"), "true")
+
+	exportToken := os.Getenv("EXPORT_TOKEN")
+	ackHookToken := os.Getenv("ACK_HOOK_TOKEN")
+
+	refillReminderDays, err := getEnvInt("REFILL_REMINDER_DAYS", 7)
+	if err != nil {
+		return nil, err
+	}
+
+	refillReminderHour, err := getEnvInt("REFILL_REMINDER_HOUR", 9)
+	if err != nil {
+		return nil, err
+	}
+
+	lowSupplyDoses, err := getEnvInt("LOW_SUPPLY_DOSES", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	commandCooldownSecs, err := getEnvInt("COMMAND_COOLDOWN_SECONDS", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	guildCommandCooldownSecs, err := getEnvInt("GUILD_COMMAND_COOLDOWN_SECONDS", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	weeklyReportDay := os.Getenv("WEEKLY_REPORT_DAY")
+
+	weeklyReportHour, err := getEnvInt("WEEKLY_REPORT_HOUR", 18)
+	if err != nil {
+		return nil, err
+	}
+
+	stockWarningDays, err := getEnvInt("STOCK_WARNING_DAYS", 14)
+	if err != nil {
+		return nil, err
+	}
+
+	messageRetentionDays, err := getEnvInt("MESSAGE_RETENTION_DAYS", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	proofRetentionDays, err := getEnvInt("PROOF_RETENTION_DAYS", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	labReminderHour, err := getEnvInt("LAB_REMINDER_HOUR", 9)
+	if err != nil {
+		return nil, err
+	}
+
+	latitude, err := getEnvFloat("LATITUDE", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	longitude, err := getEnvFloat("LONGITUDE", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	reminderTemplate := os.Getenv("REMINDER_TEMPLATE")
+
+	reminderSound := os.Getenv("REMINDER_SOUND")
+
+	accessibleReminders := strings.EqualFold(os.Getenv("ACCESSIBLE_REMINDERS"), "true")
+
+	httpAddr := os.Getenv("HTTP_ADDR")
+
+	httpReadTimeoutSecs, err := getEnvInt("HTTP_READ_TIMEOUT_SECONDS", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	httpWriteTimeoutSecs, err := getEnvInt("HTTP_WRITE_TIMEOUT_SECONDS", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	httpIdleTimeoutSecs, err := getEnvInt("HTTP_IDLE_TIMEOUT_SECONDS", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitPerMinute, err := getEnvInt("RATE_LIMIT_PER_MINUTE", 60)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitBurst, err := getEnvInt("RATE_LIMIT_BURST", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitTrustProxy := strings.EqualFold(os.Getenv("RATE_LIMIT_TRUST_PROXY"), "true")
+
+	graphQLEnabled := strings.EqualFold(os.Getenv("GRAPHQL_ENABLED"), "true")
+
+	grpcAddr := os.Getenv("GRPC_ADDR")
+
+	discordClientID := os.Getenv("DISCORD_CLIENT_ID")
+	discordClientSecret := os.Getenv("DISCORD_CLIENT_SECRET")
+	dashboardSessionSecret := os.Getenv("DASHBOARD_SESSION_SECRET")
+	dashboardGuildID := os.Getenv("DASHBOARD_GUILD_ID")
+
+	var dashboardAllowedUsers []string
+	for _, userID := range strings.Split(os.Getenv("DASHBOARD_ALLOWED_USERS"), ",") {
+		if userID = strings.TrimSpace(userID); userID != "" {
+			dashboardAllowedUsers = append(dashboardAllowedUsers, userID)
+		}
+	}
+
+	var failoverChannels []string
+	for _, channel := range strings.Split(os.Getenv("FAILOVER_CHANNELS"), ",") {
+		if channel = strings.ToLower(strings.TrimSpace(channel)); channel != "" {
+			failoverChannels = append(failoverChannels, channel)
+		}
+	}
+
+	failoverAfterFailures, err := getEnvInt("FAILOVER_AFTER_FAILURES", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	failoverGatewayDownMinutes, err := getEnvInt("FAILOVER_GATEWAY_DOWN_MINUTES", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	var corsAllowedOrigins []string
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			corsAllowedOrigins = append(corsAllowedOrigins, origin)
+		}
+	}
+
+	encouragement := strings.EqualFold(os.Getenv("ENCOURAGEMENT"), "true")
+
+	// Custom encouragement lines are separated by |
+	var encouragementMessages []string
+	for _, line := range strings.Split(os.Getenv("ENCOURAGEMENT_MESSAGES"), "|") {
+		if line = strings.TrimSpace(line); line != "" {
+			encouragementMessages = append(encouragementMessages, line)
+		}
+	}
+
+	var medications []Medication
+
+	// Dynamically load all medications from environment variables
+	for i := 1; ; i++ {
+		nameKey := fmt.Sprintf("MED_%d_NAME", i)
+		name := os.Getenv(nameKey)
+
+		// Exit case, no env found
+		if name == "" {
+			break
+		}
+
+		// Get frequency (default to "daily" if not specified)
+		frequencyKey := fmt.Sprintf("MED_%d_FREQUENCY", i)
+		frequency := os.Getenv(frequencyKey)
+		if frequency == "" {
+			frequency = "daily"
+		}
+
+		// Get hour (not needed for as-needed medications, which aren't scheduled)
+		hourKey := fmt.Sprintf("MED_%d_HOUR", i)
+		hourStr := os.Getenv(hourKey)
 		var hour int
 		if hourStr != "" {
 			parsedHour, err := strconv.Atoi(hourStr)
@@ -190,16 +1154,15 @@ func LoadEnvConfig() (*Config, error) {
 				return nil, fmt.Errorf("invalid %s: %w", hourKey, err)
 			}
 			hour = parsedHour
-		} else {
+		} else if frequency != FrequencyAsNeeded && os.Getenv(fmt.Sprintf("MED_%d_TIMES", i)) == "" && !scheduleSetsTimes(os.Getenv(fmt.Sprintf("MED_%d_SCHEDULE", i))) {
 			log.Printf("No hour found for %s, skipping this medication.\n", name)
 			continue
 		}
 
-		// Get frequency (default to "daily" if not specified)
-		frequencyKey := fmt.Sprintf("MED_%d_FREQUENCY", i)
-		frequency := os.Getenv(frequencyKey)
-		if frequency == "" {
-			frequency = "daily"
+		// Get minute past the hour (defaults to on the hour)
+		minute, err := getEnvInt(fmt.Sprintf("MED_%d_MINUTE", i), 0)
+		if err != nil {
+			return nil, err
 		}
 
 		// Get day (only needed for weekly frequency)
@@ -209,25 +1172,198 @@ func LoadEnvConfig() (*Config, error) {
 			day = os.Getenv(dayKey)
 		}
 
+		// Get priority (defaults to "normal" during validation)
+		priorityKey := fmt.Sprintf("MED_%d_PRIORITY", i)
+		priority := os.Getenv(priorityKey)
+
+		// Get heads-up lead time in minutes (0 disables the heads-up)
+		leadTimeMins, err := getEnvInt(fmt.Sprintf("MED_%d_LEAD_MINUTES", i), 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// Get the optional sunrise or sunset anchor and offset from it
+		anchor := os.Getenv(fmt.Sprintf("MED_%d_ANCHOR", i))
+		anchorOffsetMins, err := getEnvInt(fmt.Sprintf("MED_%d_ANCHOR_OFFSET_MINUTES", i), 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// Get the minimum gap between doses, in hours (0 disables the double-dose warning)
+		minGapHours, err := getEnvInt(fmt.Sprintf("MED_%d_MIN_GAP_HOURS", i), 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// Get the times of a medication taken more than once a day, which replace its hour
+		var times []string
+		for _, doseTime := range strings.Split(os.Getenv(fmt.Sprintf("MED_%d_TIMES", i)), ",") {
+			if doseTime = strings.TrimSpace(doseTime); doseTime != "" {
+				times = append(times, doseTime)
+			}
+		}
+
+		// Get the people who can confirm doses alongside the user the medication is for
+		var confirmers []string
+		for _, userID := range strings.Split(os.Getenv(fmt.Sprintf("MED_%d_CONFIRMERS", i)), ",") {
+			if userID = strings.TrimSpace(userID); userID != "" {
+				confirmers = append(confirmers, userID)
+			}
+		}
+		confirmations, err := getEnvInt(fmt.Sprintf("MED_%d_CONFIRMATIONS", i), 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// Get the limit on as-needed doses in any 24 hours (0 disables the limit)
+		maxDosesPer24h, err := getEnvInt(fmt.Sprintf("MED_%d_MAX_DOSES_PER_24H", i), 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// Get the optional webhook notified of the medication's doses, and the headers sent to it
+		webhookHeaders, err := parseHeaders(os.Getenv(fmt.Sprintf("MED_%d_WEBHOOK_HEADERS", i)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid MED_%d_WEBHOOK_HEADERS: %w", i, err)
+		}
+
 		// Add the medication to our list
 		medications = append(medications, Medication{
-			Name:      name,
-			Hour:      hour,
-			Frequency: frequency,
-			Day:       day,
+			ButtonLabel:      os.Getenv(fmt.Sprintf("MED_%d_BUTTON_LABEL", i)),
+			ButtonEmoji:      os.Getenv(fmt.Sprintf("MED_%d_BUTTON_EMOJI", i)),
+			ButtonStyle:      os.Getenv(fmt.Sprintf("MED_%d_BUTTON_STYLE", i)),
+			Template:         os.Getenv(fmt.Sprintf("MED_%d_TEMPLATE", i)),
+			Sound:            os.Getenv(fmt.Sprintf("MED_%d_SOUND", i)),
+			Image:            os.Getenv(fmt.Sprintf("MED_%d_IMAGE", i)),
+			Name:             name,
+			Hour:             hour,
+			Minute:           minute,
+			Frequency:        frequency,
+			Day:              day,
+			Priority:         priority,
+			LeadTimeMins:     leadTimeMins,
+			Anchor:           anchor,
+			AnchorOffsetMins: anchorOffsetMins,
+			WebhookURL:       os.Getenv(fmt.Sprintf("MED_%d_WEBHOOK_URL", i)),
+			WebhookHeaders:   webhookHeaders,
+			UserID:           os.Getenv(fmt.Sprintf("MED_%d_USER_ID", i)),
+			MinGapHours:      minGapHours,
+			MaxDosesPer24h:   maxDosesPer24h,
+			BlockOverLimit:   strings.EqualFold(os.Getenv(fmt.Sprintf("MED_%d_BLOCK_OVER_LIMIT", i)), "true"),
+			GuardianID:       os.Getenv(fmt.Sprintf("MED_%d_GUARDIAN_ID", i)),
+			Times:            times,
+			Confirmations:    confirmations,
+			Confirmers:       confirmers,
+			PhotoProof:       os.Getenv(fmt.Sprintf("MED_%d_PHOTO_PROOF", i)),
+			Schedule:         strings.TrimSpace(os.Getenv(fmt.Sprintf("MED_%d_SCHEDULE", i))),
+			Timezone:         os.Getenv(fmt.Sprintf("MED_%d_TIMEZONE", i)),
 		})
 
-		log.Printf("Loaded medication: %s, hour: %d, frequency: %s, day: %s\n", name, hour, frequency, day)
+		log.Printf("Loaded medication: %s, time: %02d:%02d, frequency: %s, day: %s, priority: %s\n", name, hour, minute, frequency, day, priority)
 	}
 
 	config := &Config{
-		DiscordToken:         token,
-		DiscordChannelID:     channelID,
-		DiscordUserIDToPing:  userIDToPing,
-		ReminderIntervalMins: interval,
-		Medications:          medications,
-		DBPath:               dbPath,
-		Timezone:             timezone,
+		DiscordToken:           token,
+		DiscordTokenFile:       tokenFile,
+		FeedbackWebhookURL:     os.Getenv("FEEDBACK_WEBHOOK_URL"),
+		InteractionSecret:      os.Getenv("INTERACTION_SECRET"),
+		DiscordChannelID:       channelID,
+		DiscordUserIDToPing:    userIDToPing,
+		OperatorChannelID:      operatorChannelID,
+		ArchiveChannelID:       archiveChannelID,
+		MessageRetentionDays:   messageRetentionDays,
+		ProofRetentionDays:     proofRetentionDays,
+		ExtraIntents:           extraIntents,
+		MinimalPermissions:     minimalPermissions,
+		ReminderIntervalMins:   interval,
+		ReminderWorkers:        reminderWorkers,
+		Medications:            medications,
+		DBPath:                 dbPath,
+		DBDriver:               dbDriver,
+		DatabaseURL:            databaseURL,
+		LitestreamReplicaURL:   litestreamReplicaURL,
+		DBReadDSN:              dbReadDSN,
+		GuildOnboarding:        guildOnboarding,
+		StartupRetries:         startupRetries,
+		StartupBackoffSecs:     startupBackoffSecs,
+		LogFile:                os.Getenv("LOG_FILE"),
+		LogFileMaxSizeMB:       logFileMaxSizeMB,
+		LogFileMaxBackups:      logFileMaxBackups,
+		LogFileMaxAgeDays:      logFileMaxAgeDays,
+		LogLevel:               os.Getenv("LOG_LEVEL"),
+		LogLevels:              os.Getenv("LOG_LEVELS"),
+		Timezone:               timezone,
+		QuietHoursStart:        quietHoursStart,
+		QuietHoursEnd:          quietHoursEnd,
+		EscalateAfterNags:      escalateAfterNags,
+		ReminderMode:           reminderMode,
+		ChecklistHour:          checklistHour,
+		PublicURL:              publicURL,
+		AckLinkSecret:          ackLinkSecret,
+		AckLinkTTLHours:        ackLinkTTLHours,
+		ReminderQRCode:         reminderQRCode,
+		ExportToken:            exportToken,
+		RefillReminderDays:     refillReminderDays,
+		RefillReminderHour:     refillReminderHour,
+		WeeklyReportDay:        weeklyReportDay,
+		WeeklyReportHour:       weeklyReportHour,
+		StockWarningDays:       stockWarningDays,
+		LabReminderHour:        labReminderHour,
+		Latitude:               latitude,
+		Longitude:              longitude,
+		ReminderTemplate:       reminderTemplate,
+		Encouragement:          encouragement,
+		EncouragementMessages:  encouragementMessages,
+		ReminderSound:          reminderSound,
+		AccessibleReminders:    accessibleReminders,
+		HTTPAddr:               httpAddr,
+		HTTPReadTimeoutSecs:    httpReadTimeoutSecs,
+		HTTPWriteTimeoutSecs:   httpWriteTimeoutSecs,
+		HTTPIdleTimeoutSecs:    httpIdleTimeoutSecs,
+		CORSAllowedOrigins:     corsAllowedOrigins,
+		RateLimitPerMinute:     rateLimitPerMinute,
+		RateLimitBurst:         rateLimitBurst,
+		RateLimitTrustProxy:    rateLimitTrustProxy,
+		GraphQLEnabled:         graphQLEnabled,
+		GRPCAddr:               grpcAddr,
+		DiscordClientID:        discordClientID,
+		DiscordClientSecret:    discordClientSecret,
+		DashboardSessionSecret: dashboardSessionSecret,
+		DashboardGuildID:       dashboardGuildID,
+		DashboardAllowedUsers:  dashboardAllowedUsers,
+		TelemetryEnabled:       strings.EqualFold(os.Getenv("TELEMETRY_ENABLED"), "true"),
+		TelemetryURL:           os.Getenv("TELEMETRY_URL"),
+		SleepInDeferHours:      sleepInDeferHours,
+		PresenceRouting:        strings.EqualFold(os.Getenv("PRESENCE_ROUTING"), "true"),
+		MQTTBrokerURL:          os.Getenv("MQTT_BROKER_URL"),
+		MQTTUsername:           os.Getenv("MQTT_USERNAME"),
+		MQTTPassword:           os.Getenv("MQTT_PASSWORD"),
+		MQTTTopicPrefix:        os.Getenv("MQTT_TOPIC_PREFIX"),
+		HADiscoveryPrefix:      os.Getenv("HA_DISCOVERY_PREFIX"),
+		AckHookToken:           ackHookToken,
+		AssistantToken:         os.Getenv("ASSISTANT_TOKEN"),
+		AdminToken:             os.Getenv("ADMIN_TOKEN"),
+		GRPCWriteToken:         os.Getenv("GRPC_WRITE_TOKEN"),
+		CalDAVEnabled:          strings.EqualFold(os.Getenv("CALDAV_ENABLED"), "true"),
+
+		FailoverChannels:           failoverChannels,
+		FailoverAfterFailures:      failoverAfterFailures,
+		FailoverGatewayDownMinutes: failoverGatewayDownMinutes,
+		NtfyURL:                    os.Getenv("NTFY_URL"),
+		NtfyToken:                  os.Getenv("NTFY_TOKEN"),
+		SMTPAddr:                   os.Getenv("SMTP_ADDR"),
+		SMTPUsername:               os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:               os.Getenv("SMTP_PASSWORD"),
+		EmailFrom:                  os.Getenv("EMAIL_FROM"),
+		EmailTo:                    os.Getenv("EMAIL_TO"),
+		TwilioAccountSID:           os.Getenv("TWILIO_ACCOUNT_SID"),
+		TwilioAuthToken:            os.Getenv("TWILIO_AUTH_TOKEN"),
+		SMSFrom:                    os.Getenv("SMS_FROM"),
+		SMSTo:                      os.Getenv("SMS_TO"),
+		LowSupplyDoses:             lowSupplyDoses,
+		CommandCooldownSecs:        commandCooldownSecs,
+		GuildCommandCooldownSecs:   guildCommandCooldownSecs,
+		AcknowledgedTemplate:       os.Getenv("ACKNOWLEDGED_TEMPLATE"),
 	}
 
 	// Validate the config
@@ -246,7 +1382,384 @@ func (c *Config) GetReminderInterval() time.Duration {
 	return time.Duration(c.ReminderIntervalMins) * time.Minute
 }
 
+// AckLinksEnabled reports whether signed acknowledgment links can be generated
+func (c *Config) AckLinksEnabled() bool {
+	return c.PublicURL != "" && c.AckLinkSecret != ""
+}
+
+// DashboardEnabled reports whether the web dashboard and its Discord login are configured
+func (c *Config) DashboardEnabled() bool {
+	return c.DiscordClientID != ""
+}
+
+// GetAckLinkTTL returns how long acknowledgment links remain valid
+func (c *Config) GetAckLinkTTL() time.Duration {
+	return time.Duration(c.AckLinkTTLHours) * time.Hour
+}
+
 // GetLocation returns the time.Location for the configured timezone
 func (c *Config) GetLocation() (*time.Location, error) {
 	return time.LoadLocation(c.Timezone)
 }
+
+// GetStartupBackoff returns the delay before a startup retry, doubling after each attempt
+func (c *Config) GetStartupBackoff(attempt int) time.Duration {
+	backoff := time.Duration(c.StartupBackoffSecs) * time.Second
+	for i := 0; i < attempt && backoff < MaxStartupBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, MaxStartupBackoff)
+}
+
+// getEnvInt reads an integer environment variable, returning the default if it is unset
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	return parsed, nil
+}
+
+// MaxSoundBytes is the largest audio file that can be attached to reminders
+const MaxSoundBytes = 8 << 20
+
+// SoundContentTypes maps supported audio file extensions to their content types
+var SoundContentTypes = map[string]string{
+	".mp3": "audio/mpeg",
+	".ogg": "audio/ogg",
+	".wav": "audio/wav",
+	".m4a": "audio/mp4",
+}
+
+// validateSound checks that an optional reminder sound is a supported audio file of a reasonable size
+func validateSound(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	if _, ok := SoundContentTypes[strings.ToLower(filepath.Ext(path))]; !ok {
+		return fmt.Errorf("%s must be an mp3, ogg, wav or m4a file", path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if info.Size() > MaxSoundBytes {
+		return fmt.Errorf("%s is larger than 8MB", path)
+	}
+
+	return nil
+}
+
+// ImageContentTypes maps supported image file extensions to their content types
+var ImageContentTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// parseHeaders parses HTTP headers separated by "|", e.g. "Authorization: Bearer abc|X-Clinic-ID: 42"
+func parseHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, header := range strings.Split(value, "|") {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("header %q must be in the form Name: value", strings.TrimSpace(header))
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// scheduleSetsTimes reports whether a schedule sets the times of day a medication is due, which intervals
+// don't. Invalid schedules are reported when the config is validated.
+func scheduleSetsTimes(expr string) bool {
+	if strings.TrimSpace(expr) == "" {
+		return false
+	}
+	recurrence, err := schedule.ParseRecurrence(expr)
+	return err != nil || len(recurrence.Times()) > 0
+}
+
+// maxScheduleTimes is the most times a day a medication's schedule can be due
+const maxScheduleTimes = 24
+
+// applySchedules sets the time of each medication with a cron schedule from it, or its dose times if
+// the schedule is due more than once a day. Dose times already given take the place of the schedule's.
+func applySchedules(medications []Medication) ([]Medication, error) {
+	medications = slices.Clone(medications)
+	for i, med := range medications {
+		if med.Schedule == "" {
+			continue
+		}
+		if med.AsNeeded() || med.Frequency == "weekly" {
+			return nil, fmt.Errorf("medication %s has a schedule, which replaces its %s frequency (remove one of them)", med.Name, med.Frequency)
+		}
+
+		recurrence, err := schedule.ParseRecurrence(med.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("medication %s has invalid schedule %q: %w", med.Name, med.Schedule, err)
+		}
+		if len(med.Times) > 0 {
+			continue
+		}
+
+		// Intervals have no times, so the medication keeps its hour
+		times := recurrence.Times()
+		switch {
+		case len(times) > maxScheduleTimes:
+			return nil, fmt.Errorf("medication %s has a schedule due %d times a day (the most is %d)", med.Name, len(times), maxScheduleTimes)
+		case len(times) == 1:
+			medications[i].Hour, medications[i].Minute = times[0]/60, times[0]%60
+		default:
+			for _, t := range times {
+				medications[i].Times = append(medications[i].Times, schedule.FormatTime(t))
+			}
+		}
+	}
+	return medications, nil
+}
+
+// expandDoseTimes replaces each medication taken more than once a day with a medication for each of its
+// doses, so each dose is reminded about and acknowledged on its own
+func expandDoseTimes(medications []Medication) ([]Medication, error) {
+	var expanded []Medication
+	for _, med := range medications {
+		if len(med.Times) == 0 {
+			expanded = append(expanded, med)
+			continue
+		}
+		if med.AsNeeded() {
+			return nil, fmt.Errorf("medication %s is taken as needed, so it can't have dose times", med.Name)
+		}
+
+		for _, doseTime := range med.Times {
+			t, err := time.Parse("15:04", strings.TrimSpace(doseTime))
+			if err != nil {
+				return nil, fmt.Errorf("medication %s has invalid dose time: %s (must be HH:MM)", med.Name, doseTime)
+			}
+
+			dose := med
+			dose.Name = fmt.Sprintf("%s %s", med.Name, t.Format("15:04"))
+			dose.Hour, dose.Minute = t.Hour(), t.Minute()
+			dose.Times = nil
+			dose.BaseName = med.Name
+			dose.DailyDoses = len(med.Times)
+			expanded = append(expanded, dose)
+		}
+	}
+	return expanded, nil
+}
+
+// MaxMedicationNameBytes is the longest medication name, in bytes, that fits in the custom ID of its
+// buttons alongside the action and signature
+const MaxMedicationNameBytes = 45
+
+// IsImageURL reports whether a medication image is a URL rather than a local file
+func IsImageURL(image string) bool {
+	return strings.HasPrefix(image, "http://") || strings.HasPrefix(image, "https://")
+}
+
+// validateImage checks that an optional medication image is a URL or a supported local image file
+func validateImage(image string) error {
+	if image == "" || IsImageURL(image) {
+		return nil
+	}
+
+	if _, ok := ImageContentTypes[strings.ToLower(filepath.Ext(image))]; !ok {
+		return fmt.Errorf("%s must be an http(s) URL or a png, jpg, gif or webp file", image)
+	}
+
+	if _, err := os.Stat(image); err != nil {
+		return fmt.Errorf("failed to read %s: %w", image, err)
+	}
+
+	return nil
+}
+
+// getEnvFloat reads a float environment variable, returning the default if it's unset
+func getEnvFloat(key string, defaultValue float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	return parsed, nil
+}
+
+// HasCoordinates reports whether a latitude and longitude have been configured
+func (c *Config) HasCoordinates() bool {
+	return c.Latitude != 0 || c.Longitude != 0
+}
+
+// InQuietHours reports whether the given time falls within the configured quiet hours.
+// Quiet hours are disabled when the start and end hours are equal.
+func (c *Config) InQuietHours(t time.Time) bool {
+	if c.QuietHoursStart == c.QuietHoursEnd {
+		return false
+	}
+
+	hour := t.Hour()
+	if c.QuietHoursStart < c.QuietHoursEnd {
+		return hour >= c.QuietHoursStart && hour < c.QuietHoursEnd
+	}
+
+	// Quiet hours wrap around midnight (e.g. 22 to 7)
+	return hour >= c.QuietHoursStart || hour < c.QuietHoursEnd
+}
+
+// ParseWeekday parses a day name such as "monday" (case insensitive)
+func ParseWeekday(day string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), day) {
+			return d, true
+		}
+	}
+	return time.Sunday, false
+}
+
+// IsScheduledOn reports whether the medication is due on the date of day
+func (m Medication) IsScheduledOn(day time.Time) bool {
+	if m.AsNeeded() {
+		return false
+	}
+	if m.Schedule != "" {
+		// The schedule was checked when the config was validated
+		recurrence, err := schedule.ParseRecurrence(m.Schedule)
+		return err == nil && recurrence.On(day)
+	}
+	if m.Frequency == "weekly" {
+		return strings.ToLower(m.Day) == strings.ToLower(day.Weekday().String())
+	}
+
+	return true
+}
+
+// Base returns the name the medication's details and stock are recorded under, which for a dose of a
+// medication taken more than once a day is the name of the medication
+func (m Medication) Base() string {
+	if m.BaseName != "" {
+		return m.BaseName
+	}
+	return m.Name
+}
+
+// RequiredConfirmations returns how many different people must confirm a dose of the medication before
+// it's recorded as taken
+func (m Medication) RequiredConfirmations() int {
+	if m.Confirmations > 0 {
+		return m.Confirmations
+	}
+	if m.GuardianID != "" {
+		return 2
+	}
+	return 1
+}
+
+// Confirmed reports whether a dose confirmed by the given users can be recorded as taken, which needs
+// enough different people and, for a medication with a guardian, the guardian among them
+func (m Medication) Confirmed(confirmedBy []string) bool {
+	if m.GuardianID != "" && !slices.Contains(confirmedBy, m.GuardianID) {
+		return false
+	}
+	return len(confirmedBy) >= m.RequiredConfirmations()
+}
+
+// ConfirmingUsers returns the people who can confirm a dose of the medication, without duplicates:
+// the user it's for, then its guardian and confirmers
+func (m Medication) ConfirmingUsers() []string {
+	var users []string
+	for _, userID := range append([]string{m.UserID, m.GuardianID}, m.Confirmers...) {
+		if userID != "" && !slices.Contains(users, userID) {
+			users = append(users, userID)
+		}
+	}
+	return users
+}
+
+// AsNeeded reports whether the medication is taken when needed rather than on a schedule
+func (m Medication) AsNeeded() bool {
+	return m.Frequency == FrequencyAsNeeded
+}
+
+// DueAt returns the time the medication is due on the same day as t, in t's location
+func (m Medication) DueAt(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), m.Hour, m.Minute, 0, 0, t.Location())
+}
+
+// medicationLocations caches the locations of medications' timezones, so they aren't loaded on every check
+var medicationLocations sync.Map
+
+// In returns t in the medication's timezone, or t unchanged if it follows the configured timezone
+func (m Medication) In(t time.Time) time.Time {
+	if m.Timezone == "" {
+		return t
+	}
+	if loc, ok := medicationLocations.Load(m.Timezone); ok {
+		return t.In(loc.(*time.Location))
+	}
+
+	// The timezone was checked when the config was validated
+	loc, err := time.LoadLocation(m.Timezone)
+	if err != nil {
+		return t
+	}
+	medicationLocations.Store(m.Timezone, loc)
+	return t.In(loc)
+}
+
+// DueOn returns when the medication is due on the date of day, in its own timezone if it has one
+func (m Medication) DueOn(day time.Time) time.Time {
+	return m.DueAt(time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, m.In(day).Location()))
+}
+
+// Clock returns the time of day the medication is due, e.g. "07:30"
+func (m Medication) Clock() string {
+	return fmt.Sprintf("%02d:%02d", m.Hour, m.Minute)
+}
+
+// Policy returns the reminder behaviour for the medication's priority
+func (m Medication) Policy() PriorityPolicy {
+	switch m.Priority {
+	case PriorityLow:
+		return PriorityPolicy{
+			PingUser:           false,
+			NagEvery:           2,
+			Escalate:           false,
+			OverrideQuietHours: false,
+		}
+	case PriorityCritical:
+		return PriorityPolicy{
+			PingUser:           true,
+			NagEvery:           1,
+			Escalate:           true,
+			OverrideQuietHours: true,
+		}
+	default:
+		return PriorityPolicy{
+			PingUser:           true,
+			NagEvery:           1,
+			Escalate:           true,
+			OverrideQuietHours: false,
+		}
+	}
+}
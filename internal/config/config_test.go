@@ -0,0 +1,116 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestConfirmed tests that doses need enough confirmations, always including the guardian's
+func TestConfirmed(t *testing.T) {
+	tests := []struct {
+		name        string
+		medication  Medication
+		confirmedBy []string
+		expected    bool
+	}{
+		{name: "Guardian confirmed", medication: Medication{UserID: "child", GuardianID: "parent"}, confirmedBy: []string{"child", "parent"}, expected: true},
+		{name: "Guardian yet to confirm", medication: Medication{UserID: "child", GuardianID: "parent"}, confirmedBy: []string{"child"}, expected: false},
+		{name: "Enough without the guardian", medication: Medication{UserID: "child", GuardianID: "parent", Confirmations: 2, Confirmers: []string{"nurse"}}, confirmedBy: []string{"child", "nurse"}, expected: false},
+		{name: "Enough with the guardian", medication: Medication{UserID: "child", GuardianID: "parent", Confirmations: 2, Confirmers: []string{"nurse"}}, confirmedBy: []string{"child", "nurse", "parent"}, expected: true},
+		{name: "Not enough confirmers", medication: Medication{UserID: "user", Confirmations: 3, Confirmers: []string{"a", "b"}}, confirmedBy: []string{"user", "a"}, expected: false},
+		{name: "Enough confirmers", medication: Medication{UserID: "user", Confirmations: 3, Confirmers: []string{"a", "b"}}, confirmedBy: []string{"user", "a", "b"}, expected: true},
+		{name: "Nobody", medication: Medication{UserID: "user", Confirmations: 2, Confirmers: []string{"a"}}, confirmedBy: nil, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.medication.Confirmed(tt.confirmedBy); result != tt.expected {
+				t.Errorf("Confirmed(%v) = %v, want %v", tt.confirmedBy, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestExpandDoseTimes tests that a medication taken more than once a day becomes a medication for each dose
+func TestExpandDoseTimes(t *testing.T) {
+	type dose struct {
+		name         string
+		hour, minute int
+		base         string
+		dailyDoses   int
+	}
+
+	tests := []struct {
+		name        string
+		medications []Medication
+		want        []dose
+		wantErr     bool
+	}{
+		{
+			name:        "Once a day",
+			medications: []Medication{{Name: "Vitamin D", Hour: 9}},
+			want:        []dose{{name: "Vitamin D", hour: 9}},
+		},
+		{
+			name:        "Twice a day",
+			medications: []Medication{{Name: "Metformin", Hour: 9, Times: []string{"08:00", " 20:30"}}},
+			want: []dose{
+				{name: "Metformin 08:00", hour: 8, base: "Metformin", dailyDoses: 2},
+				{name: "Metformin 20:30", hour: 20, minute: 30, base: "Metformin", dailyDoses: 2},
+			},
+		},
+		{
+			name:        "Times are normalized",
+			medications: []Medication{{Name: "Metformin", Times: []string{"8:05"}}},
+			want:        []dose{{name: "Metformin 08:05", hour: 8, minute: 5, base: "Metformin", dailyDoses: 1}},
+		},
+		{
+			name: "Order is kept",
+			medications: []Medication{
+				{Name: "Metformin", Times: []string{"08:00", "20:00"}},
+				{Name: "Vitamin D", Hour: 9},
+			},
+			want: []dose{
+				{name: "Metformin 08:00", hour: 8, base: "Metformin", dailyDoses: 2},
+				{name: "Metformin 20:00", hour: 20, base: "Metformin", dailyDoses: 2},
+				{name: "Vitamin D", hour: 9},
+			},
+		},
+		{
+			name:        "Invalid time",
+			medications: []Medication{{Name: "Metformin", Times: []string{"08:00", "25:00"}}},
+			wantErr:     true,
+		},
+		{
+			name:        "As needed",
+			medications: []Medication{{Name: "Ibuprofen", Frequency: FrequencyAsNeeded, Times: []string{"08:00"}}},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expanded, err := expandDoseTimes(tt.medications)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expandDoseTimes succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandDoseTimes failed: %v", err)
+			}
+
+			var got []dose
+			for _, med := range expanded {
+				if len(med.Times) != 0 {
+					t.Errorf("%s still has times %v", med.Name, med.Times)
+				}
+				got = append(got, dose{name: med.Name, hour: med.Hour, minute: med.Minute, base: med.BaseName, dailyDoses: med.DailyDoses})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandDoseTimes = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,417 @@
+// Package dashboard serves a web dashboard of today's doses and recent history, protected by
+// "Login with Discord" so that dashboard users are identified by the same Discord user IDs as reminders.
+package dashboard
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+)
+
+// Path is the HTTP path the dashboard is served under
+const Path = "/dashboard"
+
+// historyDays is the number of days of history shown on the dashboard
+const historyDays = 7
+
+// Acknowledger marks a medication dose as taken by a Discord user
+type Acknowledger interface {
+	AcknowledgeMedicationAs(ctx context.Context, medicationName, userID, source string) (bool, error)
+}
+
+// Options configures the dashboard's Discord login
+type Options struct {
+	ClientID      string
+	ClientSecret  string
+	SessionSecret string
+	// PublicURL is the externally reachable base URL the OAuth callback is served under
+	PublicURL string
+	Access    Access
+}
+
+// Handler serves the dashboard and its login flow
+type Handler struct {
+	store        db.StoreInterface
+	medications  []config.Medication
+	acknowledger Acknowledger
+	location     *time.Location
+	oauth        *oauth
+	sessions     *sessions
+	access       Access
+}
+
+// NewHandler creates a new dashboard handler
+func NewHandler(store db.StoreInterface, medications []config.Medication, acknowledger Acknowledger, location *time.Location, opts Options) *Handler {
+	if location == nil {
+		location = time.UTC
+	}
+
+	publicURL := strings.TrimRight(opts.PublicURL, "/")
+
+	return &Handler{
+		store:        store,
+		medications:  medications,
+		acknowledger: acknowledger,
+		location:     location,
+		oauth: &oauth{
+			clientID:     opts.ClientID,
+			clientSecret: opts.ClientSecret,
+			redirectURL:  publicURL + Path + "/callback",
+			httpClient:   &http.Client{Timeout: 10 * time.Second},
+		},
+		sessions: &sessions{
+			secret: []byte(opts.SessionSecret),
+			secure: strings.HasPrefix(publicURL, "https://"),
+		},
+		access: opts.Access,
+	}
+}
+
+// ServeHTTP routes dashboard requests
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, Path) {
+	case "", "/":
+		h.serveDashboard(w, r)
+	case "/login":
+		h.serveLogin(w, r)
+	case "/callback":
+		h.serveCallback(w, r)
+	case "/logout":
+		h.serveLogout(w, r)
+	case "/ack":
+		h.serveAcknowledge(w, r)
+	case "/photo":
+		h.servePhoto(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// canTake reports whether a user can mark a medication as taken, which is anyone if it isn't for a particular user
+func canTake(medication config.Medication, userID string) bool {
+	return medication.UserID == "" || medication.UserID == userID
+}
+
+// session returns the logged in user, if any
+func (h *Handler) session(r *http.Request) *Session {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+
+	session, err := h.sessions.decode(cookie.Value, time.Now())
+	if err != nil {
+		return nil
+	}
+	return session
+}
+
+// serveLogin sends the user to Discord to log in
+func (h *Handler) serveLogin(w http.ResponseWriter, r *http.Request) {
+	state := make([]byte, 16)
+	if _, err := rand.Read(state); err != nil {
+		log.Printf("Error generating OAuth state: %v", err)
+		http.Error(w, "Failed to start login, please try again.", http.StatusInternalServerError)
+		return
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(state)
+
+	h.sessions.setCookie(w, stateCookie, encoded, time.Now().Add(10*time.Minute))
+	http.Redirect(w, r, h.oauth.authCodeURL(encoded), http.StatusFound)
+}
+
+// serveCallback completes the Discord login and starts a session for allowed users
+func (h *Handler) serveCallback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(stateCookie)
+	state := r.URL.Query().Get("state")
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		http.Error(w, "This login link is not valid, please try again.", http.StatusBadRequest)
+		return
+	}
+	h.sessions.clearCookie(w, stateCookie)
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "Login was cancelled.", http.StatusBadRequest)
+		return
+	}
+
+	accessToken, err := h.oauth.exchange(r.Context(), code)
+	if err != nil {
+		log.Printf("Error exchanging OAuth code: %v", err)
+		http.Error(w, "Failed to log in with Discord, please try again.", http.StatusBadGateway)
+		return
+	}
+
+	user, err := h.oauth.identify(accessToken)
+	if err != nil {
+		log.Printf("Error identifying dashboard user: %v", err)
+		http.Error(w, "Failed to log in with Discord, please try again.", http.StatusBadGateway)
+		return
+	}
+
+	if !h.access.allows(user) {
+		log.Printf("Rejected dashboard login from Discord user %s", user.UserID)
+		http.Error(w, "Your Discord account doesn't have access to this dashboard.", http.StatusForbidden)
+		return
+	}
+
+	session := Session{UserID: user.UserID, Username: user.Username, Expires: time.Now().Add(sessionTTL)}
+	h.sessions.setCookie(w, sessionCookie, h.sessions.encode(session), session.Expires)
+	log.Printf("Dashboard login from Discord user %s", user.UserID)
+
+	http.Redirect(w, r, Path, http.StatusFound)
+}
+
+// serveLogout ends the session
+func (h *Handler) serveLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.sessions.clearCookie(w, sessionCookie)
+	http.Redirect(w, r, Path, http.StatusSeeOther)
+}
+
+// serveAcknowledge marks a dose as taken on behalf of the logged in user
+func (h *Handler) serveAcknowledge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := h.session(r)
+	if session == nil {
+		http.Error(w, "Please log in again.", http.StatusUnauthorized)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(r.FormValue("csrf")), []byte(h.sessions.csrfToken(session))) != 1 {
+		http.Error(w, "This form has expired, please reload the page.", http.StatusForbidden)
+		return
+	}
+
+	medication := r.FormValue("medication")
+	// Only the user a medication is for can mark it as taken, as in Discord
+	if i := slices.IndexFunc(h.medications, func(m config.Medication) bool { return m.Name == medication }); i >= 0 && !canTake(h.medications[i], session.UserID) {
+		http.Error(w, fmt.Sprintf("%s is someone else's medication, so you can't mark it as taken.", medication), http.StatusForbidden)
+		return
+	}
+
+	_, err := h.acknowledger.AcknowledgeMedicationAs(r.Context(), medication, session.UserID, "the dashboard ("+session.Username+")")
+	if errors.Is(err, db.ErrMedicationInactive) {
+		http.Error(w, fmt.Sprintf("%s is no longer scheduled.", medication), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, db.ErrConfirmationRequired) {
+		http.Error(w, fmt.Sprintf("%s needs confirming by others in Discord before it's recorded.", medication), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, db.ErrPhotoRequired) {
+		http.Error(w, fmt.Sprintf("%s needs a photo added in Discord before it's recorded.", medication), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error acknowledging %s from the dashboard: %v", medication, err)
+		http.Error(w, "Failed to record your dose, please try again.", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, Path, http.StatusSeeOther)
+}
+
+// servePhoto serves the photo of a dose to the logged in user
+func (h *Handler) servePhoto(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.session(r) == nil {
+		http.Error(w, "Please log in again.", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.URL.Query().Get("dose"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid dose.", http.StatusBadRequest)
+		return
+	}
+
+	photo, err := h.store.GetDosePhoto(r.Context(), id)
+	if err != nil {
+		log.Printf("Error loading photo of dose %d: %v", id, err)
+		http.Error(w, "Failed to load the photo, please try again.", http.StatusInternalServerError)
+		return
+	}
+	if photo == nil {
+		http.Error(w, "This dose doesn't have a photo.", http.StatusNotFound)
+		return
+	}
+
+	// Only images are served as themselves, so an upload can't be served as a page on the dashboard
+	contentType := photo.ContentType
+	if !strings.HasPrefix(contentType, "image/") || strings.Contains(contentType, "svg") {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(photo.Data)
+}
+
+// dose is a row on the dashboard
+type dose struct {
+	Date       string
+	Medication string
+	Time       string
+	// CanTake is whether the logged in user can mark the dose as taken
+	CanTake bool
+	Taken   bool
+	Skipped bool
+	// DoseID identifies the dose's photo, and PhotoHash is the photo's SHA-256, if one was added
+	DoseID    int64
+	PhotoHash string
+}
+
+// page is the data the dashboard template is rendered with
+type page struct {
+	Session *Session
+	CSRF    string
+	Today   []dose
+	History []dose
+}
+
+// serveDashboard renders today's doses and recent history, or a login prompt
+func (h *Handler) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data := page{Session: h.session(r)}
+	if data.Session != nil {
+		data.CSRF = h.sessions.csrfToken(data.Session)
+
+		var err error
+		data.Today, data.History, err = h.doses(r.Context(), data.Session.UserID)
+		if err != nil {
+			log.Printf("Error loading dashboard: %v", err)
+			http.Error(w, "Failed to load the dashboard, please try again.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := dashboardTemplate.Execute(w, data); err != nil {
+		log.Printf("Error rendering dashboard: %v", err)
+	}
+}
+
+// doses loads today's scheduled doses and the previous days' reminders, as seen by a user. Today is
+// where each medication is taken, which the store's calendar knows.
+func (h *Handler) doses(ctx context.Context, userID string) ([]dose, []dose, error) {
+	now := time.Now().In(h.location)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, h.location)
+	calendar := h.store.Calendar()
+
+	reminders, err := h.store.GetReminderHistory(ctx, "", midnight.AddDate(0, 0, -historyDays))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	todayReminders := make(map[string]db.Reminder)
+	var history []dose
+	for _, reminder := range reminders {
+		if reminder.Date == calendar.Date(reminder.MedicationType, now) {
+			todayReminders[reminder.MedicationType] = reminder
+			continue
+		}
+		history = append(history, dose{
+			Date:       reminder.Date,
+			Medication: reminder.MedicationType,
+			Taken:      reminder.Acknowledged,
+			Skipped:    reminder.Skipped,
+			DoseID:     reminder.ID,
+			PhotoHash:  reminder.ProofHash,
+		})
+	}
+
+	var todayDoses []dose
+	for _, medication := range h.medications {
+		local := now.In(calendar.Location(medication.Name))
+		if !medication.IsScheduledOn(local) {
+			continue
+		}
+		reminder := todayReminders[medication.Name]
+		todayDoses = append(todayDoses, dose{
+			Date:       local.Format("2006-01-02"),
+			Medication: medication.Name,
+			Time:       medication.DueAt(local).Format("15:04"),
+			CanTake:    canTake(medication, userID),
+			Taken:      reminder.Acknowledged,
+			Skipped:    reminder.Skipped,
+			DoseID:     reminder.ID,
+			PhotoHash:  reminder.ProofHash,
+		})
+	}
+
+	// Most recent history first
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+
+	return todayDoses, history, nil
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Medications</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 2rem auto; padding: 0 1rem; }
+table { width: 100%; border-collapse: collapse; margin-bottom: 2rem; }
+td, th { text-align: left; padding: 0.4rem; border-bottom: 1px solid #ddd; }
+form { display: inline; }
+</style>
+</head>
+<body>
+{{if .Session}}
+<form method="post" action="/dashboard/logout">Logged in as {{.Session.Username}} <button>Log out</button></form>
+<h1>Today</h1>
+<table>
+<tr><th>Medication</th><th>Due</th><th></th></tr>
+{{range .Today}}<tr><td>{{.Medication}}</td><td>{{.Time}}</td><td>{{if .Taken}}✅ Taken{{template "photo" .}}{{else}}{{if .Skipped}}⏭️ Skipped {{end}}{{if .CanTake}}<form method="post" action="/dashboard/ack"><input type="hidden" name="csrf" value="{{$.CSRF}}"><input type="hidden" name="medication" value="{{.Medication}}"><button>Mark as taken</button></form>{{end}}{{end}}</td></tr>
+{{else}}<tr><td colspan="3">Nothing scheduled today.</td></tr>
+{{end}}</table>
+<h1>Recent days</h1>
+<table>
+<tr><th>Date</th><th>Medication</th><th></th></tr>
+{{range .History}}<tr><td>{{.Date}}</td><td>{{.Medication}}</td><td>{{if .Taken}}✅ Taken{{template "photo" .}}{{else if .Skipped}}⏭️ Skipped{{else}}❌ Missed{{end}}</td></tr>
+{{else}}<tr><td colspan="3">No reminders yet.</td></tr>
+{{end}}</table>
+{{else}}
+<h1>Medications</h1>
+<p><a href="/dashboard/login">Login with Discord</a></p>
+{{end}}
+</body>
+</html>
+{{define "photo"}}{{if .PhotoHash}} <a href="/dashboard/photo?dose={{.DoseID}}" title="SHA-256 {{.PhotoHash}}">📷 Photo</a>{{end}}{{end}}
+`))
@@ -0,0 +1,229 @@
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	s := &sessions{secret: []byte("secret")}
+	now := time.Unix(1700000000, 0)
+	session := Session{UserID: "123", Username: "alex.example", Expires: now.Add(time.Hour)}
+
+	value := s.encode(session)
+
+	decoded, err := s.decode(value, now)
+	if err != nil {
+		t.Fatalf("decode() error = %v", err)
+	}
+	if decoded.UserID != "123" || decoded.Username != "alex.example" {
+		t.Errorf("decode() = %+v, want user 123 alex.example", decoded)
+	}
+
+	if _, err := s.decode(value, now.Add(2*time.Hour)); err != ErrInvalidSession {
+		t.Errorf("decode() after expiry error = %v, want ErrInvalidSession", err)
+	}
+
+	tampered := strings.Replace(value, "123", "456", 1)
+	if _, err := s.decode(tampered, now); err != ErrInvalidSession {
+		t.Errorf("decode() of tampered session error = %v, want ErrInvalidSession", err)
+	}
+
+	other := &sessions{secret: []byte("other")}
+	if _, err := other.decode(value, now); err != ErrInvalidSession {
+		t.Errorf("decode() with another secret error = %v, want ErrInvalidSession", err)
+	}
+}
+
+func TestAccessAllows(t *testing.T) {
+	access := Access{GuildID: "guild", UserIDs: []string{"allowed"}}
+
+	tests := []struct {
+		name string
+		user identity
+		want bool
+	}{
+		{"allowed user", identity{UserID: "allowed"}, true},
+		{"guild member", identity{UserID: "member", GuildIDs: []string{"other", "guild"}}, true},
+		{"stranger", identity{UserID: "stranger", GuildIDs: []string{"other"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := access.allows(&tt.user); got != tt.want {
+				t.Errorf("allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if (Access{}).allows(&identity{UserID: "anyone"}) {
+		t.Error("empty access allowed a user")
+	}
+}
+
+func TestCallbackRejectsMismatchedState(t *testing.T) {
+	h := NewHandler(nil, nil, nil, nil, Options{ClientID: "client", SessionSecret: "secret", PublicURL: "https://meds.example.com"})
+
+	req := httptest.NewRequest(http.MethodGet, "/dashboard/callback?state=forged&code=abc", nil)
+	req.AddCookie(&http.Cookie{Name: stateCookie, Value: "expected"})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAcknowledgeRequiresSession(t *testing.T) {
+	h := NewHandler(nil, nil, nil, nil, Options{ClientID: "client", SessionSecret: "secret", PublicURL: "https://meds.example.com"})
+
+	req := httptest.NewRequest(http.MethodPost, "/dashboard/ack", strings.NewReader("medication=Morning+Pill"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+// fakeAcknowledger records who acknowledged each medication
+type fakeAcknowledger struct {
+	acknowledgedBy map[string]string
+}
+
+func (f *fakeAcknowledger) AcknowledgeMedicationAs(ctx context.Context, medicationName, userID, source string) (bool, error) {
+	f.acknowledgedBy[medicationName] = userID
+	return false, nil
+}
+
+func TestAcknowledgeAsSessionUser(t *testing.T) {
+	acknowledger := &fakeAcknowledger{acknowledgedBy: make(map[string]string)}
+	medications := []config.Medication{{Name: "Morning Pill", UserID: "user"}, {Name: "Shared Vitamin"}}
+	h := NewHandler(nil, medications, acknowledger, time.UTC, Options{SessionSecret: "secret"})
+
+	tests := []struct {
+		name       string
+		userID     string
+		medication string
+		status     int
+	}{
+		{name: "Someone else's medication", userID: "other", medication: "Morning Pill", status: http.StatusForbidden},
+		{name: "Own medication", userID: "user", medication: "Morning Pill", status: http.StatusSeeOther},
+		{name: "Anyone's medication", userID: "other", medication: "Shared Vitamin", status: http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := Session{UserID: tt.userID, Username: tt.userID, Expires: time.Now().Add(time.Hour)}
+			form := "csrf=" + h.sessions.csrfToken(&session) + "&medication=" + strings.ReplaceAll(tt.medication, " ", "+")
+			req := httptest.NewRequest(http.MethodPost, "/dashboard/ack", strings.NewReader(form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.AddCookie(&http.Cookie{Name: sessionCookie, Value: h.sessions.encode(session)})
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if by, ok := acknowledger.acknowledgedBy[tt.medication]; tt.status == http.StatusSeeOther && by != tt.userID {
+				t.Errorf("acknowledged by %q, want %q", by, tt.userID)
+			} else if tt.status != http.StatusSeeOther && ok {
+				t.Errorf("acknowledged %s for someone else", tt.medication)
+			}
+		})
+	}
+}
+
+func TestDosesShowPhoto(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore(time.UTC)
+	reminder, err := store.GetTodayReminder(ctx, "Morning Pill")
+	if err != nil {
+		t.Fatalf("GetTodayReminder() error = %v", err)
+	}
+	if err := store.RecordAcknowledgment(ctx, reminder.ID, reminder.Version, "", "user", time.Time{}); err != nil {
+		t.Fatalf("RecordAcknowledgment() error = %v", err)
+	}
+	photo := &db.DosePhoto{ContentType: "image/jpeg", Data: []byte("jpeg")}
+	if err := store.RecordDoseProof(ctx, reminder.ID, photo); err != nil {
+		t.Fatalf("RecordDoseProof() error = %v", err)
+	}
+
+	h := NewHandler(store, []config.Medication{{Name: "Morning Pill", Hour: 8}}, nil, time.UTC, Options{SessionSecret: "secret"})
+	today, _, err := h.doses(ctx, "user")
+	if err != nil {
+		t.Fatalf("doses() error = %v", err)
+	}
+	if len(today) != 1 || !today[0].Taken || today[0].DoseID != reminder.ID || today[0].PhotoHash != photo.Hash() {
+		t.Fatalf("doses() = %+v, want the taken dose with its photo", today)
+	}
+
+	var rendered bytes.Buffer
+	if err := dashboardTemplate.Execute(&rendered, page{Session: &Session{Username: "alex"}, Today: today}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !strings.Contains(rendered.String(), fmt.Sprintf(`href="/dashboard/photo?dose=%d"`, reminder.ID)) || !strings.Contains(rendered.String(), "SHA-256 "+photo.Hash()) {
+		t.Errorf("dashboard doesn't link the photo: %s", rendered.String())
+	}
+
+	// The photo is served from the database to logged in users
+	path := fmt.Sprintf("/dashboard/photo?dose=%d", reminder.ID)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the photo to need a login, got status %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: h.sessions.encode(Session{UserID: "user", Username: "alex", Expires: time.Now().Add(time.Hour)})})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "jpeg" || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("Expected the photo, got status %d, %q (%s)", rec.Code, rec.Body.String(), rec.Header().Get("Content-Type"))
+	}
+}
+
+// TestDosesInMedicationTimezone tests that today's dose of a medication taken a day ahead of the
+// configured timezone is shown as today's, not in the history
+func TestDosesInMedicationTimezone(t *testing.T) {
+	ctx := context.Background()
+	home, err := time.LoadLocation("Pacific/Pago_Pago")
+	if err != nil {
+		t.Skipf("Timezone data isn't available: %v", err)
+	}
+	medication := config.Medication{Name: "Morning Pill", Hour: 8, Frequency: "daily", Timezone: "Pacific/Kiritimati"}
+	store := db.NewMemoryStore(home)
+	store.Calendar().SetLocator(func(medicationType string) *time.Location {
+		return medication.In(time.Now()).Location()
+	})
+
+	reminder, err := store.GetTodayReminder(ctx, medication.Name)
+	if err != nil {
+		t.Fatalf("GetTodayReminder() error = %v", err)
+	}
+	if err := store.RecordAcknowledgment(ctx, reminder.ID, reminder.Version, "", "user", time.Time{}); err != nil {
+		t.Fatalf("RecordAcknowledgment() error = %v", err)
+	}
+
+	h := NewHandler(store, []config.Medication{medication}, nil, home, Options{SessionSecret: "secret"})
+	today, history, err := h.doses(ctx, "user")
+	if err != nil {
+		t.Fatalf("doses() error = %v", err)
+	}
+	if len(today) != 1 || !today[0].Taken || today[0].Date != reminder.Date {
+		t.Errorf("doses() today = %+v, want the dose taken on %s", today, reminder.Date)
+	}
+	if len(history) != 0 {
+		t.Errorf("doses() history = %+v, want today's dose left out", history)
+	}
+}
@@ -0,0 +1,128 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord OAuth2 endpoints
+var (
+	authorizeURL = discordgo.EndpointOAuth2 + "authorize"
+	tokenURL     = discordgo.EndpointOAuth2 + "token"
+)
+
+// identity is the Discord user behind an OAuth login
+type identity struct {
+	UserID   string
+	Username string
+	GuildIDs []string
+}
+
+// oauth runs the Discord authorization code flow
+type oauth struct {
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	httpClient   *http.Client
+}
+
+// authCodeURL returns the Discord URL the user is sent to in order to log in
+func (o *oauth) authCodeURL(state string) string {
+	params := url.Values{}
+	params.Set("client_id", o.clientID)
+	params.Set("redirect_uri", o.redirectURL)
+	params.Set("response_type", "code")
+	params.Set("scope", "identify guilds")
+	params.Set("state", state)
+	params.Set("prompt", "none")
+	return authorizeURL + "?" + params.Encode()
+}
+
+// exchange trades an authorization code for an access token
+func (o *oauth) exchange(ctx context.Context, code string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", o.redirectURL)
+
+	ctxRequest, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctxRequest, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(o.clientID, o.clientSecret)
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("token response did not include an access token")
+	}
+
+	return token.AccessToken, nil
+}
+
+// identify looks up the user and guilds an access token belongs to
+func (o *oauth) identify(accessToken string) (*identity, error) {
+	session, err := discordgo.New("Bearer " + accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Discord session: %w", err)
+	}
+	session.Client = o.httpClient
+
+	user, err := session.User("@me")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	guilds, err := session.UserGuilds(200, "", "", false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guilds: %w", err)
+	}
+
+	result := &identity{UserID: user.ID, Username: user.Username}
+	for _, guild := range guilds {
+		result.GuildIDs = append(result.GuildIDs, guild.ID)
+	}
+	return result, nil
+}
+
+// Access decides which Discord users may use the dashboard
+type Access struct {
+	// GuildID admits members of a guild, usually the one the reminders are posted in
+	GuildID string
+	// UserIDs admits specific users, such as the user reminders ping
+	UserIDs []string
+}
+
+// allows reports whether a Discord user may use the dashboard
+func (a Access) allows(user *identity) bool {
+	if slices.Contains(a.UserIDs, user.UserID) {
+		return true
+	}
+	return a.GuildID != "" && slices.Contains(user.GuildIDs, a.GuildID)
+}
@@ -0,0 +1,119 @@
+package dashboard
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionCookie holds the signed identity of the logged in user
+	sessionCookie = "meds_session"
+	// stateCookie holds the OAuth state while the user is sent to Discord
+	stateCookie = "meds_oauth_state"
+	// sessionTTL is how long a dashboard login lasts
+	sessionTTL = 7 * 24 * time.Hour
+)
+
+// ErrInvalidSession is returned when a session cookie is missing, tampered with or expired
+var ErrInvalidSession = errors.New("invalid dashboard session")
+
+// Session identifies a logged in Discord user
+type Session struct {
+	UserID   string
+	Username string
+	Expires  time.Time
+}
+
+// sessions signs and verifies session cookies
+type sessions struct {
+	secret []byte
+	secure bool
+}
+
+// encode returns the signed cookie value for a session
+func (s *sessions) encode(session Session) string {
+	payload := strings.Join([]string{
+		session.UserID,
+		base64.RawURLEncoding.EncodeToString([]byte(session.Username)),
+		strconv.FormatInt(session.Expires.Unix(), 10),
+	}, ".")
+	return payload + "." + s.sign(payload)
+}
+
+// decode verifies a signed cookie value and returns its session
+func (s *sessions) decode(value string, now time.Time) (*Session, error) {
+	payload, sig, ok := cutLast(value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(payload))) {
+		return nil, ErrInvalidSession
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidSession
+	}
+
+	username, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || now.Unix() > expires {
+		return nil, ErrInvalidSession
+	}
+
+	return &Session{UserID: parts[0], Username: string(username), Expires: time.Unix(expires, 0)}, nil
+}
+
+// csrfToken returns the token forms must include to act as the session's user
+func (s *sessions) csrfToken(session *Session) string {
+	return s.sign(fmt.Sprintf("csrf\n%s\n%d", session.UserID, session.Expires.Unix()))
+}
+
+// sign computes the signature of a value
+func (s *sessions) sign(value string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// setCookie writes an HTTP-only cookie scoped to the dashboard
+func (s *sessions) setCookie(w http.ResponseWriter, name, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     Path,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearCookie removes a dashboard cookie
+func (s *sessions) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     Path,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,228 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long cached reminders are used before being read again, which bounds how
+// stale they can be when another instance shares the database
+const DefaultCacheTTL = 30 * time.Second
+
+// CachedStore caches today's reminders in memory, since they're read for every medication on every
+// tick and on every button click. Cached reminders are invalidated when they're updated through the
+// cache and expire after a TTL, so updates made by other instances are picked up.
+type CachedStore struct {
+	StoreInterface
+
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+	// generation is incremented on every invalidation, so a read that raced with an update
+	// doesn't cache the reminder as it was before the update
+	generation uint64
+	// tenants are the cached stores of other tenants (guilds), created the first time they're needed
+	tenants map[string]*CachedStore
+}
+
+// cacheKey identifies a medication's reminder for a day
+type cacheKey struct {
+	date       string
+	medication string
+}
+
+// cacheEntry is a cached reminder and when it expires
+type cacheEntry struct {
+	reminder Reminder
+	expires  time.Time
+}
+
+// NewCachedStore wraps a store with a cache of today's reminders, dated by the store's calendar
+func NewCachedStore(store StoreInterface, ttl time.Duration) *CachedStore {
+	return &CachedStore{
+		StoreInterface: store,
+		ttl:            ttl,
+		entries:        make(map[cacheKey]cacheEntry),
+	}
+}
+
+// ForTenant returns a cached store scoped to a tenant (guild), whose reminders are cached separately
+func (c *CachedStore) ForTenant(tenantID string) (StoreInterface, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if scoped, ok := c.tenants[tenantID]; ok {
+		return scoped, nil
+	}
+
+	base, err := ScopeToTenant(c.StoreInterface, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if c.tenants == nil {
+		c.tenants = make(map[string]*CachedStore)
+	}
+
+	scoped := NewCachedStore(base, c.ttl)
+	c.tenants[tenantID] = scoped
+	return scoped, nil
+}
+
+// GetTodayReminder gets or creates a reminder for today, from the cache if it's fresh
+func (c *CachedStore) GetTodayReminder(ctx context.Context, medicationType string) (*Reminder, error) {
+	now := time.Now()
+	key := cacheKey{date: c.Calendar().Date(medicationType, now), medication: medicationType}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		// Callers get their own copy so they can't change the cached reminder
+		reminder := entry.reminder
+		return &reminder, nil
+	}
+
+	reminder, err := c.StoreInterface.GetTodayReminder(ctx, medicationType)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store(generation, now, *reminder)
+
+	return reminder, nil
+}
+
+// EnsureReminders creates any missing reminders for the medications on a date and returns them.
+// The database is only queried if any of the medications aren't cached.
+func (c *CachedStore) EnsureReminders(ctx context.Context, date string, medicationTypes []string) ([]Reminder, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	generation := c.generation
+	var reminders []Reminder
+	for _, medicationType := range medicationTypes {
+		entry, ok := c.entries[cacheKey{date: date, medication: medicationType}]
+		if !ok || !now.Before(entry.expires) {
+			reminders = nil
+			break
+		}
+		reminders = append(reminders, entry.reminder)
+	}
+	c.mu.Unlock()
+
+	if len(medicationTypes) > 0 && reminders != nil {
+		return reminders, nil
+	}
+
+	reminders, err := c.StoreInterface.EnsureReminders(ctx, date, medicationTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only today's reminders are cached, which is a different date for medications in other timezones
+	var today []Reminder
+	for _, reminder := range reminders {
+		if reminder.Date == c.Calendar().Date(reminder.MedicationType, now) {
+			today = append(today, reminder)
+		}
+	}
+	c.store(generation, now, today...)
+
+	return reminders, nil
+}
+
+// RecordNag records a nag and invalidates the reminder's cached copy
+func (c *CachedStore) RecordNag(ctx context.Context, id, version int64, messageID string) error {
+	defer c.invalidate(id)
+	return c.StoreInterface.RecordNag(ctx, id, version, messageID)
+}
+
+// RecordAcknowledgment records an acknowledgment and invalidates the reminder's cached copy
+func (c *CachedStore) RecordAcknowledgment(ctx context.Context, id, version int64, messageID, userID string, takenAt time.Time) error {
+	defer c.invalidate(id)
+	return c.StoreInterface.RecordAcknowledgment(ctx, id, version, messageID, userID, takenAt)
+}
+
+// RecordSkip records a skipped dose and invalidates the reminder's cached copy
+func (c *CachedStore) RecordSkip(ctx context.Context, id, version int64, messageID, reason string) error {
+	defer c.invalidate(id)
+	return c.StoreInterface.RecordSkip(ctx, id, version, messageID, reason)
+}
+
+// RecordHeadsUp records that a heads-up was sent and invalidates the reminder's cached copy
+func (c *CachedStore) RecordHeadsUp(ctx context.Context, id int64, messageID string) error {
+	defer c.invalidate(id)
+	return c.StoreInterface.RecordHeadsUp(ctx, id, messageID)
+}
+
+// RecordDoseProof records a photo of a dose and invalidates its reminder's cached copy
+func (c *CachedStore) RecordDoseProof(ctx context.Context, id int64, photo *DosePhoto) error {
+	defer c.invalidate(id)
+	return c.StoreInterface.RecordDoseProof(ctx, id, photo)
+}
+
+// DeleteDoseProofs deletes the photos of doses before a date, emptying the cache so no cached reminder
+// keeps a deleted photo's hash
+func (c *CachedStore) DeleteDoseProofs(ctx context.Context, before string) (int64, error) {
+	defer c.invalidateAll()
+	return c.StoreInterface.DeleteDoseProofs(ctx, before)
+}
+
+// MoveReminderMessage records a reminder's new message and invalidates its cached copy
+func (c *CachedStore) MoveReminderMessage(ctx context.Context, id int64, messageID string) error {
+	defer c.invalidate(id)
+	return c.StoreInterface.MoveReminderMessage(ctx, id, messageID)
+}
+
+// RenameReminders moves a medication's reminders to another name, emptying the cache
+func (c *CachedStore) RenameReminders(ctx context.Context, from, to string) (int64, error) {
+	defer c.invalidateAll()
+	return c.StoreInterface.RenameReminders(ctx, from, to)
+}
+
+// store caches today's reminders, unless the cache was invalidated since they were read
+func (c *CachedStore) store(generation uint64, now time.Time, reminders ...Reminder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+
+	for _, reminder := range reminders {
+		// A medication's entries from previous days are dropped as they'll never be read again
+		for key := range c.entries {
+			if key.medication == reminder.MedicationType && key.date != reminder.Date {
+				delete(c.entries, key)
+			}
+		}
+
+		c.entries[cacheKey{date: reminder.Date, medication: reminder.MedicationType}] = cacheEntry{reminder: reminder, expires: now.Add(c.ttl)}
+	}
+}
+
+// invalidateAll removes every reminder from the cache
+func (c *CachedStore) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.entries)
+}
+
+// invalidate removes a reminder from the cache
+func (c *CachedStore) invalidate(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key, entry := range c.entries {
+		if entry.reminder.ID == id {
+			delete(c.entries, key)
+		}
+	}
+}
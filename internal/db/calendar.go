@@ -0,0 +1,53 @@
+package db
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Locator returns the timezone a medication's doses are taken in, or nil if it's the store's timezone
+type Locator func(medicationType string) *time.Location
+
+// Calendar dates each medication's doses, so today's reminder for a medication is the one for the date
+// where its dose is taken. That's the store's timezone until a locator is set, which the reminder service
+// does so medications with their own timezone, and every medication while travelling, follow the clock
+// their reminders are sent on.
+type Calendar struct {
+	location *time.Location
+	locator  atomic.Pointer[Locator]
+}
+
+// NewCalendar creates a calendar that dates every dose in a timezone until a locator is set
+func NewCalendar(location *time.Location) *Calendar {
+	if location == nil {
+		location = time.UTC
+	}
+
+	return &Calendar{location: location}
+}
+
+// SetLocator sets where each medication's doses are taken. It's safe to call while the store is in use.
+func (c *Calendar) SetLocator(locate Locator) {
+	c.locator.Store(&locate)
+}
+
+// Location returns the timezone a medication's doses are dated in
+func (c *Calendar) Location(medicationType string) *time.Location {
+	if locate := c.locator.Load(); locate != nil && *locate != nil {
+		if loc := (*locate)(medicationType); loc != nil {
+			return loc
+		}
+	}
+
+	return c.location
+}
+
+// Date returns the date a medication's dose at t is recorded under
+func (c *Calendar) Date(medicationType string, t time.Time) string {
+	return t.In(c.Location(medicationType)).Format("2006-01-02")
+}
+
+// Today returns the date a medication's dose taken now is recorded under
+func (c *Calendar) Today(medicationType string) string {
+	return c.Date(medicationType, time.Now())
+}
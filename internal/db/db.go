@@ -2,9 +2,14 @@ package db
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	_ "github.com/ncruces/go-sqlite3/driver"
@@ -14,15 +19,81 @@ import (
 // StoreInterface defines the interface for database operations
 type StoreInterface interface {
 	Close() error
+	Calendar() *Calendar
 	GetTodayReminder(ctx context.Context, medicationType string) (*Reminder, error)
-	UpdateReminderStatus(ctx context.Context, id int64, acknowledged bool, messageID string) error
+	RecordNag(ctx context.Context, id, version int64, messageID string) error
+	RecordAcknowledgment(ctx context.Context, id, version int64, messageID, userID string, takenAt time.Time) error
+	RecordSkip(ctx context.Context, id, version int64, messageID, reason string) error
+	RecordDoseProof(ctx context.Context, id int64, photo *DosePhoto) error
+	GetDosePhoto(ctx context.Context, id int64) (*DosePhoto, error)
+	DeleteDoseProofs(ctx context.Context, before string) (int64, error)
+	RecordHeadsUp(ctx context.Context, id int64, messageID string) error
+	MoveReminderMessage(ctx context.Context, id int64, messageID string) error
+	RenameReminders(ctx context.Context, from, to string) (int64, error)
+	GetRemindersForDate(ctx context.Context, date string) ([]Reminder, error)
+	EnsureReminders(ctx context.Context, date string, medicationTypes []string) ([]Reminder, error)
+	GetReminderHistory(ctx context.Context, medicationType string, since time.Time) ([]Reminder, error)
+	GetTodayChecklist(ctx context.Context) (string, error)
+	SaveTodayChecklist(ctx context.Context, messageID string) error
+	GetMedicationInfo(ctx context.Context, name string) (*MedicationInfo, error)
+	SaveMedicationInfo(ctx context.Context, info *MedicationInfo) error
+	ListMedicationInfo(ctx context.Context) ([]MedicationInfo, error)
+	AdjustPills(ctx context.Context, name string, delta int) (int, error)
+	RestockPills(ctx context.Context, name string, count int) (int, error)
+	MarkRefilled(ctx context.Context, name, nextDue, status string) error
+	RecordRefillReminder(ctx context.Context, name, date string) error
+	GetContact(ctx context.Context, kind, name string) (*Contact, error)
+	SaveContact(ctx context.Context, contact *Contact) error
+	ListContacts(ctx context.Context) ([]Contact, error)
+	RecordRefill(ctx context.Context, refill *Refill) error
+	GetRefills(ctx context.Context, from, to time.Time) ([]Refill, error)
+	SaveLabTest(ctx context.Context, test *LabTest) error
+	GetLabTest(ctx context.Context, name string) (*LabTest, error)
+	ListLabTests(ctx context.Context) ([]LabTest, error)
+	RecordLabResult(ctx context.Context, result *LabResult) error
+	GetLabResults(ctx context.Context, testID int64, limit int) ([]LabResult, error)
+	GetState(ctx context.Context, key string) (string, error)
+	SetState(ctx context.Context, key, value string) error
+	CompareAndSetState(ctx context.Context, key, old, value string) (bool, error)
+	AppendDoseEvent(ctx context.Context, event *DoseEvent) error
+	GetDoseEvents(ctx context.Context, medication string, since time.Time) ([]DoseEvent, error)
+	GetGuildSettings(ctx context.Context, guildID string) (*GuildSettings, error)
+	SaveGuildSettings(ctx context.Context, settings *GuildSettings) error
+	RecordFeedback(ctx context.Context, feedback *Feedback) error
 }
 
 type Store struct {
-	db       *sql.DB
+	db *sql.DB
+	// replica serves reporting queries when set, so they don't contend with the write path
+	replica  *sql.DB
 	location *time.Location
+	// calendar dates each medication's doses, and is shared by the store's tenants
+	calendar *Calendar
+	// tenant scopes every query to one guild's data, where the default tenant is empty
+	tenant string
+	// driver is the database/sql driver, which decides the SQL dialect queries are run in
+	driver string
 }
 
+// Errors returned by the store for the state of a dose, rather than a failure of the database,
+// so callers can tell users what happened
+var (
+	// ErrReminderConflict is returned when a reminder being updated was changed since it was read
+	ErrReminderConflict = errors.New("reminder was changed since it was read")
+	// ErrReminderNotFound is returned when a reminder being updated doesn't exist
+	ErrReminderNotFound = errors.New("reminder not found")
+	// ErrAlreadyAcknowledged is returned when a reminder being updated was already acknowledged
+	ErrAlreadyAcknowledged = errors.New("dose was already acknowledged")
+	// ErrDoseSkipped is returned when a reminder is sent for a dose that was skipped
+	ErrDoseSkipped = errors.New("dose was skipped")
+	// ErrMedicationInactive is returned when a dose is recorded for a medication that's no longer scheduled
+	ErrMedicationInactive = errors.New("medication is no longer scheduled")
+	// ErrConfirmationRequired is returned when a dose is recorded that needs confirming by others first
+	ErrConfirmationRequired = errors.New("dose needs confirming by others before it's recorded")
+	// ErrPhotoRequired is returned when a dose is recorded that needs a photo of it first
+	ErrPhotoRequired = errors.New("dose needs a photo before it's recorded")
+)
+
 type Reminder struct {
 	ID               int64
 	Date             string
@@ -30,11 +101,188 @@ type Reminder struct {
 	Acknowledged     bool
 	LastReminderTime time.Time
 	MessageID        string
+	NagCount         int
+	HeadsUpSent      bool
+	// CorrelationID identifies the dose cycle in logs and the dose event log, from scheduling to acknowledgment
+	CorrelationID string
+	// Version is incremented on every update, so copies of a reminder can be checked for staleness
+	Version int64
+	// AcknowledgedAt is when the dose was taken, while LastReminderTime is when the last reminder was sent
+	AcknowledgedAt time.Time
+	// AcknowledgedBy is the Discord user ID of whoever marked the dose as taken, empty if it isn't known
+	AcknowledgedBy string
+	// ProofHash is the SHA-256 (in hex) of the photo of the dose being taken, which is read with
+	// GetDosePhoto, or empty without a photo
+	ProofHash string
+	// Skipped is true if the dose was skipped on purpose, such as when fasting for a blood test, with the
+	// reason given if any. A skipped dose isn't taken, but isn't missed either.
+	Skipped    bool
+	SkipReason string
+}
+
+// DosePhoto is a photo of a dose being taken. The photo itself is kept, since Discord's links to
+// attachments expire.
+type DosePhoto struct {
+	ContentType string
+	Data        []byte
+}
+
+// Hash returns the SHA-256 of the photo in hex
+func (p *DosePhoto) Hash() string {
+	sum := sha256.Sum256(p.Data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Resolved reports whether the dose was taken or skipped, so it's no longer reminded about
+func (r Reminder) Resolved() bool {
+	return r.Acknowledged || r.Skipped
+}
+
+// newCorrelationIDSQL generates a correlation ID in SQL, in the same format as newCorrelationID
+const newCorrelationIDSQL = "lower(hex(randomblob(8)))"
+
+// newCorrelationID returns a random ID for a reminder's dose cycle
+func newCorrelationID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// MedicationInfo holds the details recorded for a medication
+type MedicationInfo struct {
+	Name         string
+	Dose         string
+	Instructions string
+	Prescriber   string
+	Pharmacy     string
+	StartDate    string
+	RefillStatus string
+	LeafletURL   string
+	// RefillDue is the date (YYYY-MM-DD) the medication needs refilling by, empty if not set
+	RefillDue string
+	// RefillRemindedOn is the date the last refill reminder was sent
+	RefillRemindedOn string
+	// PillsRemaining is the number of doses left, or UntrackedPills if stock isn't tracked
+	PillsRemaining int
+}
+
+// UntrackedPills is the PillsRemaining value of medications whose stock isn't tracked
+const UntrackedPills = -1
+
+// Contact kinds
+const (
+	ContactPrescriber = "prescriber"
+	ContactPharmacy   = "pharmacy"
+)
+
+// Contact holds the contact details of a prescriber or pharmacy.
+// Medications are linked to contacts by the prescriber and pharmacy names in their details.
+type Contact struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Email   string `json:"email"`
+	Address string `json:"address"`
+}
+
+// Refill is a logged medication refill, with optional cost and copay in cents
+type Refill struct {
+	ID         int64
+	Medication string
+	Date       string
+	CostCents  int64
+	CopayCents int64
+}
+
+// LabTest is a recurring lab test (e.g. an INR check) linked to a medication
+type LabTest struct {
+	ID           int64
+	Name         string
+	Medication   string
+	Unit         string
+	IntervalDays int
+	// NextDue is the date (YYYY-MM-DD) the next test is due
+	NextDue string
+	// RemindedOn is the date the last reminder for the test was sent
+	RemindedOn string
+}
+
+// LabResult is a recorded lab test result
+type LabResult struct {
+	ID     int64
+	TestID int64
+	Date   string
+	Value  float64
+}
+
+// Dose event types
+const (
+	DoseEventReminded     = "reminded"
+	DoseEventAcknowledged = "acknowledged"
+	DoseEventMissed       = "missed"
+	DoseEventSkipped      = "skipped"
+	// DoseEventPendingConfirmation is a confirmation of a dose that needs more than one, before it has them all
+	DoseEventPendingConfirmation = "pending_confirmation"
+	// DoseEventLogged is a dose of an as-needed medication, which has no reminder
+	DoseEventLogged = "logged"
+	// DoseEventUndelivered is a reminder a channel failed to deliver
+	DoseEventUndelivered = "undelivered"
+)
+
+// ChannelDiscord is the Source of reminders delivered by Discord rather than a backup channel
+const ChannelDiscord = "Discord"
+
+// DoseEvent is an entry in the append-only log of everything that happened to a dose
+type DoseEvent struct {
+	ID         int64
+	Medication string
+	// Date is the date (YYYY-MM-DD) of the dose the event is for
+	Date       string
+	Type       string
+	ReminderID int64
+	// Source describes where an acknowledgment came from, why a dose was skipped, or which channel
+	// delivered or failed to deliver a reminder
+	Source string
+	// CorrelationID is the correlation ID of the reminder the event is for
+	CorrelationID string
+	CreatedAt     time.Time
+}
+
+// Feedback is feedback about the bot sent by a user with /meds feedback
+type Feedback struct {
+	ID        int64
+	UserID    string
+	Text      string
+	CreatedAt time.Time
+}
+
+// GuildSettings are the settings chosen for a guild during onboarding. The guild ID is also
+// the guild's tenant ID.
+type GuildSettings struct {
+	GuildID   string
+	ChannelID string
+	Timezone  string
+	// Medication and MedicationHour are the first medication set up
+	Medication     string
+	MedicationHour int
+	// Completed is set once every setting has been chosen
+	Completed bool
 }
 
+// The database/sql drivers a Store can run on
+const (
+	sqliteDriver   = "sqlite3"
+	postgresDriver = "postgres"
+)
+
 // NewStore creates a new database store
 func NewStore(ctx context.Context, dbPath string, location *time.Location) (*Store, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	return openStore(ctx, sqliteDriver, dbPath, location)
+}
+
+// openStore opens a store on a database/sql driver and brings its schema up to date
+func openStore(ctx context.Context, driver, dsn string, location *time.Location) (*Store, error) {
+	db, err := sql.Open(driver, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -59,6 +307,8 @@ func NewStore(ctx context.Context, dbPath string, location *time.Location) (*Sto
 	store := &Store{
 		db:       db,
 		location: location,
+		calendar: NewCalendar(location),
+		driver:   driver,
 	}
 
 	if err := store.initSchema(ctx); err != nil {
@@ -71,11 +321,132 @@ func NewStore(ctx context.Context, dbPath string, location *time.Location) (*Sto
 
 // Close closes the database connection
 func (s *Store) Close() error {
+	if s.replica != nil {
+		if err := s.replica.Close(); err != nil {
+			return fmt.Errorf("failed to close read replica: %w", err)
+		}
+	}
 	return s.db.Close()
 }
 
+// ForTenant returns a store sharing the same database, with every query scoped to a tenant (guild),
+// so one tenant can never read or change another's data. Closing any of the stores closes the database.
+func (s *Store) ForTenant(tenantID string) *Store {
+	scoped := *s
+	scoped.tenant = tenantID
+	return &scoped
+}
+
+// ScopeToTenant returns a store scoped to a tenant (guild), or the store itself for the default tenant.
+// Stores that can't be scoped return an error rather than sharing their data with every tenant.
+func ScopeToTenant(store StoreInterface, tenantID string) (StoreInterface, error) {
+	if tenantID == "" {
+		return store, nil
+	}
+
+	switch s := store.(type) {
+	case *CachedStore:
+		return s.ForTenant(tenantID)
+	case *MemoryStore:
+		return s.ForTenant(tenantID), nil
+	case interface{ ForTenant(tenantID string) *Store }:
+		return s.ForTenant(tenantID), nil
+	}
+	return nil, fmt.Errorf("%T can't be scoped to tenant %s", store, tenantID)
+}
+
+// Calendar returns the calendar today's reminders are dated by
+func (s *Store) Calendar() *Calendar {
+	return s.calendar
+}
+
+// Tenant returns the tenant the store's queries are scoped to
+func (s *Store) Tenant() string {
+	return s.tenant
+}
+
+// OpenReadReplica opens a read-only connection that reporting queries are served from, such as the
+// history, refills and dose event log, which may lag slightly behind the primary database
+func (s *Store) OpenReadReplica(ctx context.Context, dsn string) error {
+	replica, err := sql.Open(s.driver, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open read replica: %w", err)
+	}
+
+	ctxPing, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := replica.PingContext(ctxPing); err != nil {
+		replica.Close()
+		return fmt.Errorf("failed to ping read replica: %w", err)
+	}
+
+	s.replica = replica
+	return nil
+}
+
+// primaryKey marks a context whose reporting queries read from the primary database
+type primaryKey struct{}
+
+// WithPrimary returns a context whose reporting queries read from the primary database rather than the
+// read replica, for checks that must see a dose recorded a moment ago, such as for a double dose
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// reports returns the connection used for reporting queries, which is the read replica unless the
+// context asks for the primary database
+func (s *Store) reports(ctx context.Context) *sql.DB {
+	if s.replica != nil && ctx.Value(primaryKey{}) == nil {
+		return s.replica
+	}
+	return s.db
+}
+
+// inDialect returns a query, written for SQLite, in the SQL dialect of a driver
+func inDialect(driver, query string) string {
+	if driver != postgresDriver {
+		return query
+	}
+	if rewritten, ok := postgresQueries[query]; ok {
+		return rewritten
+	}
+	return rebind(query)
+}
+
+// Checkpoint copies the write-ahead log into the database without blocking readers or writers,
+// which is safe while Litestream replicates the database
+func (s *Store) Checkpoint(ctx context.Context) error {
+	ctxExec, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var busy, logFrames, checkpointed int
+	err := s.db.QueryRowContext(ctxExec, "PRAGMA wal_checkpoint(PASSIVE)").Scan(&busy, &logFrames, &checkpointed)
+	if err != nil {
+		return fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+
+	return nil
+}
+
+// Snapshot writes a consistent copy of the database to a new file at path, while it's still in use
+func (s *Store) Snapshot(ctx context.Context, path string) error {
+	if _, err := s.db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	return nil
+}
+
+// SchemaVersion identifies the database schema, and is increased whenever a table or column is added,
+// so state exported by a newer version of the bot is refused by an older one rather than misread
+const SchemaVersion = 6
+
 // initSchema initializes the database schema
 func (s *Store) initSchema(ctx context.Context) error {
+	if s.driver == postgresDriver {
+		return s.migratePostgres(ctx)
+	}
+
 	createTableSQL := `
 	CREATE TABLE IF NOT EXISTS reminders (
 		id INTEGER PRIMARY KEY,
@@ -83,45 +454,339 @@ func (s *Store) initSchema(ctx context.Context) error {
 		medication_type TEXT NOT NULL,
 		acknowledged INTEGER DEFAULT 0,
 		last_reminder_time TEXT,
-		message_id TEXT
-	);`
+		acknowledged_at TEXT,
+		message_id TEXT,
+		nag_count INTEGER DEFAULT 0,
+		heads_up_sent INTEGER DEFAULT 0,
+		version INTEGER NOT NULL DEFAULT 0,
+		correlation_id TEXT NOT NULL DEFAULT '',
+		tenant_id TEXT NOT NULL DEFAULT '',
+		acknowledged_by TEXT NOT NULL DEFAULT '',
+		proof_url TEXT NOT NULL DEFAULT '',
+		proof_hash TEXT NOT NULL DEFAULT '',
+		skipped INTEGER NOT NULL DEFAULT 0,
+		skip_reason TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS dose_photos (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		reminder_id INTEGER NOT NULL,
+		content_type TEXT NOT NULL,
+		data BLOB NOT NULL,
+		PRIMARY KEY (tenant_id, reminder_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS checklists (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		date TEXT NOT NULL,
+		message_id TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, date)
+	);
+
+	CREATE TABLE IF NOT EXISTS medications (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		dose TEXT NOT NULL DEFAULT '',
+		instructions TEXT NOT NULL DEFAULT '',
+		prescriber TEXT NOT NULL DEFAULT '',
+		pharmacy TEXT NOT NULL DEFAULT '',
+		start_date TEXT NOT NULL DEFAULT '',
+		refill_status TEXT NOT NULL DEFAULT '',
+		leaflet_url TEXT NOT NULL DEFAULT '',
+		refill_due TEXT NOT NULL DEFAULT '',
+		refill_reminded_on TEXT NOT NULL DEFAULT '',
+		pills_remaining INTEGER NOT NULL DEFAULT -1,
+		PRIMARY KEY (tenant_id, name)
+	);
+
+	CREATE TABLE IF NOT EXISTS contacts (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		kind TEXT NOT NULL,
+		name TEXT NOT NULL,
+		phone TEXT NOT NULL DEFAULT '',
+		email TEXT NOT NULL DEFAULT '',
+		address TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (tenant_id, kind, name)
+	);
+
+	CREATE TABLE IF NOT EXISTS refills (
+		id INTEGER PRIMARY KEY,
+		medication TEXT NOT NULL,
+		date TEXT NOT NULL,
+		cost_cents INTEGER NOT NULL DEFAULT 0,
+		copay_cents INTEGER NOT NULL DEFAULT 0,
+		tenant_id TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS lab_tests (
+		id INTEGER PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL COLLATE NOCASE,
+		medication TEXT NOT NULL,
+		unit TEXT NOT NULL DEFAULT '',
+		interval_days INTEGER NOT NULL,
+		next_due TEXT NOT NULL,
+		reminded_on TEXT NOT NULL DEFAULT '',
+		UNIQUE (tenant_id, name)
+	);
+
+	CREATE TABLE IF NOT EXISTS lab_results (
+		id INTEGER PRIMARY KEY,
+		test_id INTEGER NOT NULL REFERENCES lab_tests(id),
+		date TEXT NOT NULL,
+		value REAL NOT NULL,
+		tenant_id TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS state (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, key)
+	);
+
+	CREATE TABLE IF NOT EXISTS dose_events (
+		id INTEGER PRIMARY KEY,
+		medication TEXT NOT NULL,
+		date TEXT NOT NULL,
+		type TEXT NOT NULL,
+		reminder_id INTEGER NOT NULL DEFAULT 0,
+		source TEXT NOT NULL DEFAULT '',
+		correlation_id TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		tenant_id TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS guild_settings (
+		guild_id TEXT PRIMARY KEY,
+		channel_id TEXT NOT NULL DEFAULT '',
+		timezone TEXT NOT NULL DEFAULT '',
+		medication TEXT NOT NULL DEFAULT '',
+		medication_hour INTEGER NOT NULL DEFAULT 0,
+		completed INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS feedback (
+		id INTEGER PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		user_id TEXT NOT NULL,
+		text TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS dose_events_date ON dose_events (date);
+
+	CREATE TRIGGER IF NOT EXISTS dose_events_no_update BEFORE UPDATE ON dose_events
+	BEGIN
+		SELECT RAISE(ABORT, 'dose events are append-only');
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS dose_events_no_delete BEFORE DELETE ON dose_events
+	BEGIN
+		SELECT RAISE(ABORT, 'dose events are append-only');
+	END;`
 
 	ctxExec, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctxExec, createTableSQL)
-	return err
+	// Tables keyed without a tenant are moved aside, recreated below with tenant keys, then copied back
+	if err := s.renameUntenantedTables(ctxExec); err != nil {
+		return err
+	}
+
+	if _, err := s.db.ExecContext(ctxExec, createTableSQL); err != nil {
+		return err
+	}
+
+	if err := s.copyUntenantedTables(ctxExec); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema, applied to existing databases
+	migrations := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"reminders", "nag_count", "INTEGER DEFAULT 0"},
+		{"reminders", "heads_up_sent", "INTEGER DEFAULT 0"},
+		{"reminders", "version", "INTEGER NOT NULL DEFAULT 0"},
+		{"reminders", "tenant_id", "TEXT NOT NULL DEFAULT ''"},
+		{"refills", "tenant_id", "TEXT NOT NULL DEFAULT ''"},
+		{"lab_results", "tenant_id", "TEXT NOT NULL DEFAULT ''"},
+		{"dose_events", "tenant_id", "TEXT NOT NULL DEFAULT ''"},
+		{"medications", "refill_due", "TEXT NOT NULL DEFAULT ''"},
+		{"medications", "refill_reminded_on", "TEXT NOT NULL DEFAULT ''"},
+		{"medications", "pills_remaining", "INTEGER NOT NULL DEFAULT -1"},
+		{"reminders", "correlation_id", "TEXT NOT NULL DEFAULT ''"},
+		{"dose_events", "correlation_id", "TEXT NOT NULL DEFAULT ''"},
+		{"reminders", "acknowledged_at", "TEXT"},
+		{"reminders", "acknowledged_by", "TEXT NOT NULL DEFAULT ''"},
+		{"reminders", "proof_url", "TEXT NOT NULL DEFAULT ''"},
+		{"reminders", "proof_hash", "TEXT NOT NULL DEFAULT ''"},
+		{"reminders", "skipped", "INTEGER NOT NULL DEFAULT 0"},
+		{"reminders", "skip_reason", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, m := range migrations {
+		if err := s.addColumnIfMissing(ctxExec, m.table, m.column, m.definition); err != nil {
+			return err
+		}
+	}
+
+	// Reminders created before correlation IDs were added get one, so every dose can be traced
+	if _, err := s.db.ExecContext(ctxExec, "UPDATE reminders SET correlation_id = "+newCorrelationIDSQL+" WHERE correlation_id = ''"); err != nil {
+		return fmt.Errorf("failed to add correlation IDs to reminders: %w", err)
+	}
+
+	// Each dose has one reminder, which is what lets instances create them at once without duplicates
+	for _, query := range []string{dedupeRemindersSQL, orphanedDosePhotosSQL, remindersDoseIndexSQL} {
+		if _, err := s.db.ExecContext(ctxExec, query); err != nil {
+			return fmt.Errorf("failed to add the reminders' unique index: %w", err)
+		}
+	}
+
+	// Acknowledgments used to overwrite the last reminder time, so it's when doses acknowledged before were taken
+	if _, err := s.db.ExecContext(ctxExec, "UPDATE reminders SET acknowledged_at = last_reminder_time WHERE acknowledged = 1 AND acknowledged_at IS NULL"); err != nil {
+		return fmt.Errorf("failed to add acknowledgment times to reminders: %w", err)
+	}
+
+	return nil
+}
+
+// tenantKeyedTables are the tables whose keys include the tenant, which are rebuilt in databases
+// created before tenants were added
+var tenantKeyedTables = []string{"checklists", "medications", "contacts", "lab_tests", "state"}
+
+// renameUntenantedTables moves aside keyed tables created before tenants were added
+func (s *Store) renameUntenantedTables(ctx context.Context) error {
+	var untenanted []string
+	for _, table := range tenantKeyedTables {
+		columns, err := s.columns(ctx, table)
+		if err != nil {
+			return err
+		}
+		if len(columns) > 0 && !slices.Contains(columns, "tenant_id") {
+			untenanted = append(untenanted, table)
+		}
+	}
+	if len(untenanted) == 0 {
+		return nil
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	// Keep references to the tables, such as lab results' tests, pointing at the recreated tables
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF; PRAGMA legacy_alter_table = ON"); err != nil {
+		return fmt.Errorf("failed to enable legacy table renames: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA legacy_alter_table = OFF; PRAGMA foreign_keys = ON")
+
+	for _, table := range untenanted {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s RENAME TO %s_untenanted", table, table)); err != nil {
+			return fmt.Errorf("failed to rename %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// copyUntenantedTables copies the rows of tables moved aside by renameUntenantedTables into the
+// recreated tables, in the default tenant
+func (s *Store) copyUntenantedTables(ctx context.Context) error {
+	for _, table := range tenantKeyedTables {
+		columns, err := s.columns(ctx, table+"_untenanted")
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			continue
+		}
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+
+		list := strings.Join(columns, ", ")
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s_untenanted", table, list, list, table))
+		if err == nil {
+			_, err = tx.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s_untenanted", table))
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to copy %s into tenant table: %w", table, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// columns returns the names of a table's columns, or none if the table doesn't exist
+func (s *Store) columns(ctx context.Context, table string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table info for %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return nil, fmt.Errorf("failed to scan table info for %s: %w", table, err)
+		}
+		columns = append(columns, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read table info for %s: %w", table, err)
+	}
+
+	return columns, nil
+}
+
+// addColumnIfMissing adds a column to a table if it doesn't already exist
+func (s *Store) addColumnIfMissing(ctx context.Context, table, column, definition string) error {
+	columns, err := s.columns(ctx, table)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(columns, column) {
+		return nil
+	}
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s to %s: %w", column, table, err)
+	}
+
+	return nil
 }
 
 // GetTodayReminder gets or creates a reminder for today for a specific medication
 func (s *Store) GetTodayReminder(ctx context.Context, medicationType string) (*Reminder, error) {
-	// Use the configured timezone to get today's date
-	today := time.Now().In(s.location).Format("2006-01-02")
+	// Doses are dated where they're taken, which is the configured timezone unless the calendar says otherwise
+	today := s.calendar.Today(medicationType)
 
 	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	var id int64
-	var acknowledged int
-	var messageID sql.NullString
-	var lastReminderTimeStr sql.NullString
-
-	err := s.db.QueryRowContext(ctxQuery, "SELECT id, acknowledged, message_id, last_reminder_time FROM reminders WHERE date = ? AND medication_type = ?", today, medicationType).Scan(&id, &acknowledged, &messageID, &lastReminderTimeStr)
-
+	reminder, err := s.querier().getTodayReminder(ctxQuery, today, medicationType)
 	if err == nil {
-		var lastReminderTime time.Time
-		if lastReminderTimeStr.Valid {
-			lastReminderTime, _ = time.Parse(time.RFC3339, lastReminderTimeStr.String)
-		}
-
-		return &Reminder{
-			ID:               id,
-			Date:             today,
-			MedicationType:   medicationType,
-			Acknowledged:     acknowledged == 1,
-			LastReminderTime: lastReminderTime,
-			MessageID:        messageID.String,
-		}, nil
+		return &reminder, nil
 	}
 
 	if !errors.Is(err, sql.ErrNoRows) {
@@ -131,45 +796,671 @@ func (s *Store) GetTodayReminder(ctx context.Context, medicationType string) (*R
 	ctxInsert, cancelInsert := context.WithTimeout(ctx, 5*time.Second)
 	defer cancelInsert()
 
-	result, err := s.db.ExecContext(ctxInsert,
-		"INSERT INTO reminders (date, medication_type, acknowledged) VALUES (?, ?, 0)",
-		today, medicationType)
-	if err != nil {
+	// Another instance may create the reminder meanwhile, in which case theirs is kept and read back
+	if err := s.querier().createReminder(ctxInsert, today, medicationType, newCorrelationID()); err != nil {
 		return nil, fmt.Errorf("failed to create reminder: %w", err)
 	}
 
-	id, err = result.LastInsertId()
+	reminder, err = s.querier().getTodayReminder(ctxInsert, today, medicationType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminder: %w", err)
+	}
+
+	return &reminder, nil
+}
+
+// RecordNag records that a reminder was sent in a new message, counting it as a nag. It returns
+// ErrReminderConflict if the reminder isn't still at the given version, or ErrAlreadyAcknowledged
+// or ErrDoseSkipped if it was acknowledged or skipped, so a nag can't undo either.
+func (s *Store) RecordNag(ctx context.Context, id, version int64, messageID string) error {
+	now := time.Now().In(s.location).Format(time.RFC3339)
+	return s.updateReminder(ctx, id, true, func(ctx context.Context, q querier) (int64, error) {
+		return q.recordNag(ctx, id, version, messageID, now)
+	})
+}
+
+// RecordAcknowledgment records that a dose was taken, the user who took it and the message showing it,
+// returning ErrReminderConflict if the reminder isn't still at the given version, or ErrAlreadyAcknowledged
+// if it was already acknowledged. A skipped dose can still be taken, which undoes the skip. takenAt is
+// when the dose was taken, if it was reported later, or zero for now.
+func (s *Store) RecordAcknowledgment(ctx context.Context, id, version int64, messageID, userID string, takenAt time.Time) error {
+	if takenAt.IsZero() {
+		takenAt = time.Now()
+	}
+	return s.updateReminder(ctx, id, false, func(ctx context.Context, q querier) (int64, error) {
+		return q.recordAcknowledgment(ctx, id, version, messageID, userID, takenAt.In(s.location).Format(time.RFC3339))
+	})
+}
+
+// RecordSkip records that a dose was skipped on purpose, why and the message showing it, returning
+// ErrReminderConflict if the reminder isn't still at the given version, or ErrAlreadyAcknowledged if
+// the dose was already taken
+func (s *Store) RecordSkip(ctx context.Context, id, version int64, messageID, reason string) error {
+	return s.updateReminder(ctx, id, false, func(ctx context.Context, q querier) (int64, error) {
+		return q.recordSkip(ctx, id, version, messageID, reason)
+	})
+}
+
+// RecordDoseProof records a photo of a dose being taken, replacing any recorded before, or deletes it
+// when the photo is nil
+func (s *Store) RecordDoseProof(ctx context.Context, id int64, photo *DosePhoto) error {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// The reminder and its photo are updated together, so a reminder never has the hash of another photo
+	tx, err := s.db.BeginTx(ctxUpdate, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	q := s.querier().in(tx)
+
+	hash := ""
+	if photo != nil {
+		hash = photo.Hash()
+	}
+	updated, err := q.recordDoseProof(ctxUpdate, id, hash)
+	if err != nil {
+		return fmt.Errorf("failed to record dose photo: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("failed to record dose photo: %w", ErrReminderNotFound)
+	}
+
+	if photo == nil {
+		err = q.deleteDosePhoto(ctxUpdate, id)
+	} else {
+		err = q.saveDosePhoto(ctxUpdate, id, photo)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record dose photo: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record dose photo: %w", err)
+	}
+
+	return nil
+}
+
+// GetDosePhoto returns the photo of a dose, or nil if it doesn't have one
+func (s *Store) GetDosePhoto(ctx context.Context, id int64) (*DosePhoto, error) {
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	photo, err := s.querier().getDosePhoto(ctxQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get last insert ID: %w", err)
+		return nil, fmt.Errorf("failed to get dose photo: %w", err)
 	}
 
-	return &Reminder{
-		ID:             id,
-		Date:           today,
-		MedicationType: medicationType,
-		Acknowledged:   false,
-	}, nil
+	return &photo, nil
 }
 
-// UpdateReminderStatus updates the status of a reminder
-func (s *Store) UpdateReminderStatus(ctx context.Context, id int64, acknowledged bool, messageID string) error {
+// DeleteDoseProofs deletes the photos of doses before a date (YYYY-MM-DD), returning how many doses had one
+func (s *Store) DeleteDoseProofs(ctx context.Context, before string) (int64, error) {
 	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	var ack int
-	if acknowledged {
-		ack = 1
+	tx, err := s.db.BeginTx(ctxUpdate, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
+	q := s.querier().in(tx)
 
-	// Use the configured timezone for the timestamp
-	now := time.Now().In(s.location).Format(time.RFC3339)
+	if err := q.deleteDosePhotos(ctxUpdate, before); err != nil {
+		return 0, fmt.Errorf("failed to delete dose photos: %w", err)
+	}
 
-	_, err := s.db.ExecContext(ctxUpdate,
-		"UPDATE reminders SET acknowledged = ?, message_id = ?, last_reminder_time = ? WHERE id = ?",
-		ack, messageID, now, id)
+	deleted, err := q.deleteDoseProofs(ctxUpdate, before)
 	if err != nil {
-		return fmt.Errorf("failed to update reminder: %w", err)
+		return 0, fmt.Errorf("failed to delete dose photos: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to delete dose photos: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// RecordHeadsUp records that the heads-up before a reminder was sent, without counting it as a nag
+func (s *Store) RecordHeadsUp(ctx context.Context, id int64, messageID string) error {
+	err := s.updateReminder(ctx, id, false, func(ctx context.Context, q querier) (int64, error) {
+		return q.recordHeadsUp(ctx, id, messageID)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record heads-up: %w", err)
 	}
+	return nil
+}
 
+// MoveReminderMessage records that a reminder was re-posted as a new message, without counting it as a nag
+func (s *Store) MoveReminderMessage(ctx context.Context, id int64, messageID string) error {
+	err := s.updateReminder(ctx, id, false, func(ctx context.Context, q querier) (int64, error) {
+		return q.moveReminderMessage(ctx, id, messageID)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move reminder message: %w", err)
+	}
 	return nil
 }
+
+// RenameReminders moves a medication's reminders to another name, except on dates the other name
+// already has a reminder, and returns how many were moved
+func (s *Store) RenameReminders(ctx context.Context, from, to string) (int64, error) {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	renamed, err := s.querier().renameReminders(ctxUpdate, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rename reminders: %w", err)
+	}
+	return renamed, nil
+}
+
+// updateReminder runs an update of a reminder, which returns how many rows it changed. If no row
+// matched, the reminder is read again to return ErrReminderNotFound, ErrAlreadyAcknowledged or
+// ErrReminderConflict for why, or ErrDoseSkipped for a nag of a skipped dose.
+func (s *Store) updateReminder(ctx context.Context, id int64, nag bool, update func(ctx context.Context, q querier) (int64, error)) error {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	updated, err := update(ctxUpdate, s.querier())
+	if err != nil {
+		return fmt.Errorf("failed to update reminder: %w", err)
+	}
+	if updated > 0 {
+		return nil
+	}
+
+	acknowledged, skipped, err := s.querier().reminderAcknowledged(ctxUpdate, id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrReminderNotFound
+	case err != nil:
+		return fmt.Errorf("failed to query reminder: %w", err)
+	case acknowledged:
+		return ErrAlreadyAcknowledged
+	case skipped && nag:
+		return ErrDoseSkipped
+	default:
+		return ErrReminderConflict
+	}
+}
+
+// GetReminderHistory returns the reminders for a medication from the given date onwards, oldest first.
+// An empty medication type returns reminders for all medications.
+func (s *Store) GetReminderHistory(ctx context.Context, medicationType string, since time.Time) ([]Reminder, error) {
+	sinceDate := since.In(s.location).Format("2006-01-02")
+
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	reminders, err := s.reportQuerier(ctx).reminderHistory(ctxQuery, sinceDate, medicationType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminder history: %w", err)
+	}
+
+	return reminders, nil
+}
+
+// GetRemindersForDate returns every medication's reminder for a date (YYYY-MM-DD) in one query
+func (s *Store) GetRemindersForDate(ctx context.Context, date string) ([]Reminder, error) {
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	reminders, err := s.querier().remindersForDate(ctxQuery, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminders: %w", err)
+	}
+
+	return reminders, nil
+}
+
+// EnsureReminders creates any missing reminders for the medications on a date in one statement,
+// and returns the date's reminders, including at least those for the medications
+func (s *Store) EnsureReminders(ctx context.Context, date string, medicationTypes []string) ([]Reminder, error) {
+	reminders, err := s.GetRemindersForDate(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool, len(reminders))
+	for _, reminder := range reminders {
+		existing[reminder.MedicationType] = true
+	}
+
+	var missing []string
+	for _, medicationType := range medicationTypes {
+		if existing[medicationType] {
+			continue
+		}
+		existing[medicationType] = true
+		missing = append(missing, medicationType)
+	}
+	if len(missing) == 0 {
+		return reminders, nil
+	}
+
+	ctxInsert, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.querier().createReminders(ctxInsert, date, missing); err != nil {
+		return nil, fmt.Errorf("failed to create reminders: %w", err)
+	}
+
+	return s.GetRemindersForDate(ctx, date)
+}
+
+// GetTodayChecklist returns the message ID of today's checklist, or an empty string if none has been posted
+func (s *Store) GetTodayChecklist(ctx context.Context) (string, error) {
+	today := time.Now().In(s.location).Format("2006-01-02")
+
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	messageID, err := s.querier().getChecklist(ctxQuery, today)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query checklist: %w", err)
+	}
+
+	return messageID, nil
+}
+
+// SaveTodayChecklist records the message ID of today's checklist
+func (s *Store) SaveTodayChecklist(ctx context.Context, messageID string) error {
+	today := time.Now().In(s.location).Format("2006-01-02")
+
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.querier().saveChecklist(ctxUpdate, today, messageID); err != nil {
+		return fmt.Errorf("failed to save checklist: %w", err)
+	}
+
+	return nil
+}
+
+// GetMedicationInfo returns the details recorded for a medication.
+// A medication with no recorded details returns an empty record.
+func (s *Store) GetMedicationInfo(ctx context.Context, name string) (*MedicationInfo, error) {
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	info, err := s.querier().getMedicationInfo(ctxQuery, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &MedicationInfo{Name: name, PillsRemaining: UntrackedPills}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query medication info: %w", err)
+	}
+
+	return &info, nil
+}
+
+// SaveMedicationInfo creates or replaces the details recorded for a medication
+func (s *Store) SaveMedicationInfo(ctx context.Context, info *MedicationInfo) error {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.querier().saveMedicationInfo(ctxUpdate, info); err != nil {
+		return fmt.Errorf("failed to save medication info: %w", err)
+	}
+
+	return nil
+}
+
+// AdjustPills adds delta doses to a medication's stock, stopping at none left, and returns how many are
+// left. Stock that isn't tracked is left alone, returning UntrackedPills.
+func (s *Store) AdjustPills(ctx context.Context, name string, delta int) (int, error) {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	remaining, err := s.querier().adjustPills(ctxUpdate, name, delta)
+	if errors.Is(err, sql.ErrNoRows) {
+		return UntrackedPills, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to adjust pills remaining: %w", err)
+	}
+
+	return remaining, nil
+}
+
+// RestockPills adds count doses to a medication's stock and returns how many are left, starting to track
+// the stock at count if it wasn't. Only the stock is written, so details saved meanwhile aren't lost.
+func (s *Store) RestockPills(ctx context.Context, name string, count int) (int, error) {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	remaining, err := s.querier().restockPills(ctxUpdate, name, count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to restock pills: %w", err)
+	}
+
+	return remaining, nil
+}
+
+// MarkRefilled sets a medication's refill due date and status and clears when it was last reminded
+// about, leaving its other details alone
+func (s *Store) MarkRefilled(ctx context.Context, name, nextDue, status string) error {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.querier().markRefilled(ctxUpdate, name, nextDue, status); err != nil {
+		return fmt.Errorf("failed to mark medication as refilled: %w", err)
+	}
+
+	return nil
+}
+
+// RecordRefillReminder records the date a medication's refill reminder was sent, leaving its other
+// details alone
+func (s *Store) RecordRefillReminder(ctx context.Context, name, date string) error {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.querier().refillReminded(ctxUpdate, name, date); err != nil {
+		return fmt.Errorf("failed to record refill reminder: %w", err)
+	}
+
+	return nil
+}
+
+// ListMedicationInfo returns the details recorded for all medications, ordered by name
+func (s *Store) ListMedicationInfo(ctx context.Context) ([]MedicationInfo, error) {
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	infos, err := s.querier().listMedicationInfo(ctxQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query medication info: %w", err)
+	}
+
+	return infos, nil
+}
+
+// GetContact returns a prescriber or pharmacy contact, or nil if none is recorded
+func (s *Store) GetContact(ctx context.Context, kind, name string) (*Contact, error) {
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	contact, err := s.querier().getContact(ctxQuery, kind, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contact: %w", err)
+	}
+
+	return &contact, nil
+}
+
+// SaveContact creates or replaces a prescriber or pharmacy contact
+func (s *Store) SaveContact(ctx context.Context, contact *Contact) error {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.querier().saveContact(ctxUpdate, contact); err != nil {
+		return fmt.Errorf("failed to save contact: %w", err)
+	}
+
+	return nil
+}
+
+// ListContacts returns all prescriber and pharmacy contacts, ordered by kind and name
+func (s *Store) ListContacts(ctx context.Context) ([]Contact, error) {
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	contacts, err := s.querier().listContacts(ctxQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contacts: %w", err)
+	}
+
+	return contacts, nil
+}
+
+// RecordRefill logs a medication refill, defaulting the date to today
+func (s *Store) RecordRefill(ctx context.Context, refill *Refill) error {
+	if refill.Date == "" {
+		refill.Date = time.Now().In(s.location).Format("2006-01-02")
+	}
+
+	ctxInsert, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	id, err := s.querier().recordRefill(ctxInsert, refill)
+	if err != nil {
+		return fmt.Errorf("failed to record refill: %w", err)
+	}
+	refill.ID = id
+
+	return nil
+}
+
+// GetRefills returns the refills logged between two dates (inclusive), oldest first
+func (s *Store) GetRefills(ctx context.Context, from, to time.Time) ([]Refill, error) {
+	fromDate := from.In(s.location).Format("2006-01-02")
+	toDate := to.In(s.location).Format("2006-01-02")
+
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	refills, err := s.reportQuerier(ctx).getRefills(ctxQuery, fromDate, toDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refills: %w", err)
+	}
+
+	return refills, nil
+}
+
+// SaveLabTest creates or updates a lab test, matched by name
+func (s *Store) SaveLabTest(ctx context.Context, test *LabTest) error {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	id, err := s.querier().saveLabTest(ctxUpdate, test)
+	if err != nil {
+		return fmt.Errorf("failed to save lab test: %w", err)
+	}
+	test.ID = id
+
+	return nil
+}
+
+// GetLabTest returns a lab test by name, or nil if it doesn't exist
+func (s *Store) GetLabTest(ctx context.Context, name string) (*LabTest, error) {
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	test, err := s.querier().getLabTest(ctxQuery, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lab test: %w", err)
+	}
+
+	return &test, nil
+}
+
+// ListLabTests returns all lab tests, ordered by name
+func (s *Store) ListLabTests(ctx context.Context) ([]LabTest, error) {
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tests, err := s.querier().listLabTests(ctxQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lab tests: %w", err)
+	}
+
+	return tests, nil
+}
+
+// RecordLabResult records a lab test result, defaulting the date to today
+func (s *Store) RecordLabResult(ctx context.Context, result *LabResult) error {
+	if result.Date == "" {
+		result.Date = time.Now().In(s.location).Format("2006-01-02")
+	}
+
+	ctxInsert, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	id, err := s.querier().recordLabResult(ctxInsert, result)
+	if err != nil {
+		return fmt.Errorf("failed to record lab result: %w", err)
+	}
+	result.ID = id
+
+	return nil
+}
+
+// GetLabResults returns the most recent results of a lab test, oldest first
+func (s *Store) GetLabResults(ctx context.Context, testID int64, limit int) ([]LabResult, error) {
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	results, err := s.querier().getLabResults(ctxQuery, testID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lab results: %w", err)
+	}
+
+	return results, nil
+}
+
+// AppendDoseEvent adds an event to the dose event log, timestamping it with the current time if unset
+func (s *Store) AppendDoseEvent(ctx context.Context, event *DoseEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().In(s.location)
+	}
+
+	ctxInsert, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	id, err := s.querier().appendDoseEvent(ctxInsert, event)
+	if err != nil {
+		return fmt.Errorf("failed to append dose event: %w", err)
+	}
+	event.ID = id
+
+	return nil
+}
+
+// RecordFeedback saves feedback sent by a user
+func (s *Store) RecordFeedback(ctx context.Context, feedback *Feedback) error {
+	if feedback.CreatedAt.IsZero() {
+		feedback.CreatedAt = time.Now().In(s.location)
+	}
+
+	ctxInsert, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	id, err := s.querier().recordFeedback(ctxInsert, feedback)
+	if err != nil {
+		return fmt.Errorf("failed to record feedback: %w", err)
+	}
+	feedback.ID = id
+
+	return nil
+}
+
+// GetDoseEvents returns the logged events for doses on or after a date in the order they happened,
+// for a single medication or all medications if empty
+func (s *Store) GetDoseEvents(ctx context.Context, medication string, since time.Time) ([]DoseEvent, error) {
+	sinceDate := since.In(s.location).Format("2006-01-02")
+
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	events, err := s.reportQuerier(ctx).doseEvents(ctxQuery, sinceDate, medication)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dose events: %w", err)
+	}
+
+	return events, nil
+}
+
+// GetGuildSettings returns a guild's onboarding settings, or nil if it hasn't been onboarded
+func (s *Store) GetGuildSettings(ctx context.Context, guildID string) (*GuildSettings, error) {
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	settings, err := s.querier().getGuildSettings(ctxQuery, guildID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query guild settings: %w", err)
+	}
+
+	return &settings, nil
+}
+
+// SaveGuildSettings creates or replaces a guild's onboarding settings
+func (s *Store) SaveGuildSettings(ctx context.Context, settings *GuildSettings) error {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.querier().saveGuildSettings(ctxUpdate, settings); err != nil {
+		return fmt.Errorf("failed to save guild settings: %w", err)
+	}
+
+	return nil
+}
+
+// GetState returns a persisted bot state value, or an empty string if it isn't set
+func (s *Store) GetState(ctx context.Context, key string) (string, error) {
+	ctxQuery, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	value, err := s.querier().getState(ctxQuery, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query state %s: %w", key, err)
+	}
+
+	return value, nil
+}
+
+// SetState persists a bot state value
+func (s *Store) SetState(ctx context.Context, key, value string) error {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.querier().setState(ctxUpdate, key, value); err != nil {
+		return fmt.Errorf("failed to save state %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// CompareAndSetState persists a bot state value only if the state is still old, where a missing value is
+// empty, and reports whether it was set. It lets values read and changed by several users at once, such
+// as a dose's confirmations, be updated without losing each other's changes.
+func (s *Store) CompareAndSetState(ctx context.Context, key, old, value string) (bool, error) {
+	ctxUpdate, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var set int64
+	var err error
+	if old == "" {
+		set, err = s.querier().setEmptyState(ctxUpdate, key, value)
+	} else {
+		set, err = s.querier().compareAndSetState(ctxUpdate, key, old, value)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save state %s: %w", key, err)
+	}
+
+	return set > 0, nil
+}
@@ -2,7 +2,14 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -48,13 +55,36 @@ func TestGetTodayReminder(t *testing.T) {
 		t.Fatalf("Failed to get reminder second time: %v", err)
 	}
 
-	// Verify it's the same reminder
+	// Verify it's the same reminder, in the same dose cycle
 	if reminder.ID != reminder2.ID {
 		t.Errorf("Expected same reminder ID, got %d and %d", reminder.ID, reminder2.ID)
 	}
+	if reminder.CorrelationID == "" || reminder.CorrelationID != reminder2.CorrelationID {
+		t.Errorf("Expected the same correlation ID, got %q and %q", reminder.CorrelationID, reminder2.CorrelationID)
+	}
+
+	// Test case: Record a heads-up, which isn't a nag
+	err = store.RecordHeadsUp(ctx, reminder.ID, "heads-up-message-id")
+	if err != nil {
+		t.Fatalf("Failed to record heads-up: %v", err)
+	}
+
+	headsUp, err := store.GetTodayReminder(ctx, medicationType)
+	if err != nil {
+		t.Fatalf("Failed to get reminder after heads-up: %v", err)
+	}
+	if !headsUp.HeadsUpSent || headsUp.NagCount != 0 || headsUp.MessageID != "heads-up-message-id" {
+		t.Errorf("Unexpected reminder after heads-up: %+v", headsUp)
+	}
+
+	// Test case: An update from a copy read before the heads-up conflicts
+	err = store.RecordAcknowledgment(ctx, reminder.ID, reminder.Version, "stale-message-id", "", time.Time{})
+	if !errors.Is(err, ErrReminderConflict) {
+		t.Fatalf("Expected a conflict updating a stale reminder, got %v", err)
+	}
 
 	// Test case: Update the reminder status
-	err = store.UpdateReminderStatus(ctx, reminder.ID, true, "test-message-id")
+	err = store.RecordAcknowledgment(ctx, reminder.ID, headsUp.Version, "test-message-id", "123456789", time.Time{})
 	if err != nil {
 		t.Fatalf("Failed to update reminder status: %v", err)
 	}
@@ -71,4 +101,1254 @@ func TestGetTodayReminder(t *testing.T) {
 	if reminder3.MessageID != "test-message-id" {
 		t.Errorf("Expected message ID 'test-message-id', got %s", reminder3.MessageID)
 	}
+	if reminder3.AcknowledgedBy != "123456789" {
+		t.Errorf("Expected the dose to be acknowledged by 123456789, got %q", reminder3.AcknowledgedBy)
+	}
+	if reminder3.AcknowledgedAt.IsZero() || !reminder3.LastReminderTime.IsZero() {
+		t.Errorf("Expected only the acknowledgment time to be set, got %+v", reminder3)
+	}
+
+	// Test case: A nag racing with the acknowledgment doesn't undo it
+	if err := store.RecordNag(ctx, reminder.ID, reminder3.Version, "nag-message-id"); !errors.Is(err, ErrAlreadyAcknowledged) {
+		t.Fatalf("Expected nagging an acknowledged reminder to fail, got %v", err)
+	}
+
+	reminder4, err := store.GetTodayReminder(ctx, medicationType)
+	if err != nil {
+		t.Fatalf("Failed to get reminder after nag: %v", err)
+	}
+	if !reminder4.Acknowledged || reminder4.MessageID != "test-message-id" || reminder4.NagCount != 0 {
+		t.Errorf("Expected the acknowledgment to be kept, got %+v", reminder4)
+	}
+}
+
+func TestTodayChecklist(t *testing.T) {
+	dbPath := "test_checklist.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// No checklist has been posted yet
+	messageID, err := store.GetTodayChecklist(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get checklist: %v", err)
+	}
+	if messageID != "" {
+		t.Errorf("Expected no checklist, got %s", messageID)
+	}
+
+	if err := store.SaveTodayChecklist(ctx, "checklist-1"); err != nil {
+		t.Fatalf("Failed to save checklist: %v", err)
+	}
+
+	// Saving again replaces the message ID
+	if err := store.SaveTodayChecklist(ctx, "checklist-2"); err != nil {
+		t.Fatalf("Failed to save checklist second time: %v", err)
+	}
+
+	messageID, err = store.GetTodayChecklist(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get checklist after save: %v", err)
+	}
+	if messageID != "checklist-2" {
+		t.Errorf("Expected message ID 'checklist-2', got %s", messageID)
+	}
+}
+
+func TestGetReminderHistory(t *testing.T) {
+	dbPath := "test_history.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	taken, err := store.GetTodayReminder(ctx, "TakenMed")
+	if err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+	if err := store.RecordAcknowledgment(ctx, taken.ID, taken.Version, "", "", time.Time{}); err != nil {
+		t.Fatalf("Failed to update reminder status: %v", err)
+	}
+
+	if _, err := store.GetTodayReminder(ctx, "PendingMed"); err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+
+	// Test case: All medications
+	history, err := store.GetReminderHistory(ctx, "", time.Now().AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatalf("Failed to get reminder history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 reminders, got %d", len(history))
+	}
+
+	// Test case: A single medication
+	history, err = store.GetReminderHistory(ctx, "TakenMed", time.Now().AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatalf("Failed to get reminder history: %v", err)
+	}
+	if len(history) != 1 || !history[0].Acknowledged {
+		t.Errorf("Expected one acknowledged reminder, got %+v", history)
+	}
+
+	// Test case: Nothing after today
+	history, err = store.GetReminderHistory(ctx, "", time.Now().AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Failed to get reminder history: %v", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("Expected no reminders, got %d", len(history))
+	}
+}
+
+func TestMedicationInfo(t *testing.T) {
+	dbPath := "test_medication_info.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Test case: No details recorded yet
+	info, err := store.GetMedicationInfo(ctx, "TestMed")
+	if err != nil {
+		t.Fatalf("Failed to get medication info: %v", err)
+	}
+	if info.Name != "TestMed" || info.Dose != "" || info.PillsRemaining != UntrackedPills {
+		t.Errorf("Expected empty record for TestMed, got %+v", info)
+	}
+
+	// Test case: Save and read back
+	info.Dose = "500mg"
+	info.Pharmacy = "CityPharm"
+	if err := store.SaveMedicationInfo(ctx, info); err != nil {
+		t.Fatalf("Failed to save medication info: %v", err)
+	}
+
+	// Test case: Saving again updates the record
+	info.Dose = "1000mg"
+	info.PillsRemaining = 30
+	if err := store.SaveMedicationInfo(ctx, info); err != nil {
+		t.Fatalf("Failed to update medication info: %v", err)
+	}
+
+	saved, err := store.GetMedicationInfo(ctx, "TestMed")
+	if err != nil {
+		t.Fatalf("Failed to get medication info after save: %v", err)
+	}
+	if saved.Dose != "1000mg" || saved.Pharmacy != "CityPharm" || saved.PillsRemaining != 30 {
+		t.Errorf("Unexpected medication info: %+v", saved)
+	}
+
+	// Test case: Adjusting the stock stops at none left
+	if remaining, err := store.AdjustPills(ctx, "TestMed", -1); err != nil || remaining != 29 {
+		t.Errorf("Expected 29 pills left, got %d (%v)", remaining, err)
+	}
+	if remaining, err := store.AdjustPills(ctx, "TestMed", -40); err != nil || remaining != 0 {
+		t.Errorf("Expected no pills left, got %d (%v)", remaining, err)
+	}
+
+	// Test case: Untracked stock isn't adjusted
+	if remaining, err := store.AdjustPills(ctx, "OtherMed", -1); err != nil || remaining != UntrackedPills {
+		t.Errorf("Expected untracked pills for OtherMed, got %d (%v)", remaining, err)
+	}
+
+	// Test case: Restocking adds to tracked stock, and starts tracking untracked stock
+	if remaining, err := store.RestockPills(ctx, "TestMed", 28); err != nil || remaining != 28 {
+		t.Errorf("Expected 28 pills after restocking, got %d (%v)", remaining, err)
+	}
+	if remaining, err := store.RestockPills(ctx, "OtherMed", 10); err != nil || remaining != 10 {
+		t.Errorf("Expected OtherMed to be tracked from 10 pills, got %d (%v)", remaining, err)
+	}
+
+	// Test case: Marking as refilled only changes the refill columns
+	if err := store.RecordRefillReminder(ctx, "TestMed", "2024-05-01"); err != nil {
+		t.Fatalf("Failed to record refill reminder: %v", err)
+	}
+	if err := store.MarkRefilled(ctx, "TestMed", "2024-06-01", "Refilled on 2024-05-02"); err != nil {
+		t.Fatalf("Failed to mark as refilled: %v", err)
+	}
+	saved, err = store.GetMedicationInfo(ctx, "TestMed")
+	if err != nil {
+		t.Fatalf("Failed to get medication info after refill: %v", err)
+	}
+	if saved.Dose != "1000mg" || saved.Pharmacy != "CityPharm" || saved.PillsRemaining != 28 ||
+		saved.RefillDue != "2024-06-01" || saved.RefillRemindedOn != "" || saved.RefillStatus != "Refilled on 2024-05-02" {
+		t.Errorf("Unexpected medication info after refill: %+v", saved)
+	}
+}
+
+func TestContacts(t *testing.T) {
+	dbPath := "test_contacts.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Test case: Unknown contacts return nil
+	contact, err := store.GetContact(ctx, ContactPharmacy, "CityPharm")
+	if err != nil {
+		t.Fatalf("Failed to get contact: %v", err)
+	}
+	if contact != nil {
+		t.Errorf("Expected no contact, got %+v", contact)
+	}
+
+	if err := store.SaveContact(ctx, &Contact{Kind: ContactPharmacy, Name: "CityPharm", Phone: "555-1234"}); err != nil {
+		t.Fatalf("Failed to save contact: %v", err)
+	}
+	if err := store.SaveContact(ctx, &Contact{Kind: ContactPrescriber, Name: "Dr Smith", Email: "smith@example.com"}); err != nil {
+		t.Fatalf("Failed to save contact: %v", err)
+	}
+
+	// Test case: Lookups by name ignore case
+	contact, err = store.GetContact(ctx, ContactPharmacy, "citypharm")
+	if err != nil {
+		t.Fatalf("Failed to get contact: %v", err)
+	}
+	if contact == nil || contact.Phone != "555-1234" {
+		t.Errorf("Expected CityPharm contact, got %+v", contact)
+	}
+
+	contacts, err := store.ListContacts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list contacts: %v", err)
+	}
+	if len(contacts) != 2 {
+		t.Errorf("Expected 2 contacts, got %d", len(contacts))
+	}
+}
+
+func TestRefills(t *testing.T) {
+	dbPath := "test_refills.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	refills := []*Refill{
+		{Medication: "TestMed", Date: "2023-12-20", CostCents: 4500, CopayCents: 1000},
+		{Medication: "TestMed", Date: "2024-01-20", CostCents: 4500, CopayCents: 1000},
+		{Medication: "OtherMed", CostCents: 1250},
+	}
+	for _, refill := range refills {
+		if err := store.RecordRefill(ctx, refill); err != nil {
+			t.Fatalf("Failed to record refill: %v", err)
+		}
+	}
+
+	// Test case: The date defaults to today
+	if refills[2].Date != time.Now().UTC().Format("2006-01-02") {
+		t.Errorf("Expected refill dated today, got %s", refills[2].Date)
+	}
+
+	// Test case: Only refills within the range are returned
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	got, err := store.GetRefills(ctx, from, to)
+	if err != nil {
+		t.Fatalf("Failed to get refills: %v", err)
+	}
+	if len(got) != 1 || got[0].Date != "2024-01-20" || got[0].CostCents != 4500 {
+		t.Errorf("Unexpected refills: %+v", got)
+	}
+}
+
+func TestState(t *testing.T) {
+	dbPath := "test_state.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	value, err := store.GetState(ctx, "test_key")
+	if err != nil {
+		t.Fatalf("Failed to get state: %v", err)
+	}
+	if value != "" {
+		t.Errorf("Expected empty state, got %s", value)
+	}
+
+	if err := store.SetState(ctx, "test_key", "one"); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+	if err := store.SetState(ctx, "test_key", "two"); err != nil {
+		t.Fatalf("Failed to overwrite state: %v", err)
+	}
+
+	value, err = store.GetState(ctx, "test_key")
+	if err != nil {
+		t.Fatalf("Failed to get state after set: %v", err)
+	}
+	if value != "two" {
+		t.Errorf("Expected state 'two', got %s", value)
+	}
+}
+
+// TestCompareAndSetState tests that state is only changed from the value it was read as
+func TestCompareAndSetState(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(ctx, filepath.Join(t.TempDir(), "cas.db"), time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for name, s := range map[string]StoreInterface{"sqlite": store, "memory": NewMemoryStore(time.UTC)} {
+		t.Run(name, func(t *testing.T) {
+			steps := []struct {
+				old, value string
+				want       bool
+			}{
+				{old: "", value: "one", want: true},
+				{old: "", value: "lost", want: false},
+				{old: "one", value: "two", want: true},
+				{old: "one", value: "lost", want: false},
+				{old: "two", value: "", want: true},
+				{old: "", value: "three", want: true},
+			}
+			for _, step := range steps {
+				set, err := s.CompareAndSetState(ctx, "key", step.old, step.value)
+				if err != nil {
+					t.Fatalf("Failed to set state: %v", err)
+				}
+				if set != step.want {
+					t.Errorf("CompareAndSetState(%q, %q) = %v, want %v", step.old, step.value, set, step.want)
+				}
+			}
+
+			if value, _ := s.GetState(ctx, "key"); value != "three" {
+				t.Errorf("Expected state 'three', got %q", value)
+			}
+		})
+	}
+}
+
+func TestLabTests(t *testing.T) {
+	dbPath := "test_lab_tests.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	test := &LabTest{Name: "INR", Medication: "Warfarin", IntervalDays: 14, NextDue: "2024-05-01"}
+	if err := store.SaveLabTest(ctx, test); err != nil {
+		t.Fatalf("Failed to save lab test: %v", err)
+	}
+	if test.ID == 0 {
+		t.Fatalf("Expected lab test ID to be set")
+	}
+
+	// Test case: Saving by name updates the existing test
+	updated := &LabTest{Name: "inr", Medication: "Warfarin", IntervalDays: 7, NextDue: "2024-05-08"}
+	if err := store.SaveLabTest(ctx, updated); err != nil {
+		t.Fatalf("Failed to update lab test: %v", err)
+	}
+	if updated.ID != test.ID {
+		t.Errorf("Expected same lab test ID, got %d and %d", test.ID, updated.ID)
+	}
+
+	saved, err := store.GetLabTest(ctx, "INR")
+	if err != nil {
+		t.Fatalf("Failed to get lab test: %v", err)
+	}
+	if saved == nil || saved.IntervalDays != 7 || saved.NextDue != "2024-05-08" {
+		t.Errorf("Unexpected lab test: %+v", saved)
+	}
+
+	// Test case: Only the most recent results are returned, oldest first
+	for i, value := range []float64{2.1, 2.8, 3.4} {
+		result := &LabResult{TestID: test.ID, Date: fmt.Sprintf("2024-05-0%d", i+1), Value: value}
+		if err := store.RecordLabResult(ctx, result); err != nil {
+			t.Fatalf("Failed to record lab result: %v", err)
+		}
+	}
+
+	results, err := store.GetLabResults(ctx, test.ID, 2)
+	if err != nil {
+		t.Fatalf("Failed to get lab results: %v", err)
+	}
+	if len(results) != 2 || results[0].Value != 2.8 || results[1].Value != 3.4 {
+		t.Errorf("Unexpected lab results: %+v", results)
+	}
+}
+
+func TestDoseEvents(t *testing.T) {
+	dbPath := "test_dose_events.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	events := []*DoseEvent{
+		{Medication: "TestMed", Date: "2024-01-01", Type: DoseEventReminded, ReminderID: 1},
+		{Medication: "TestMed", Date: "2024-01-02", Type: DoseEventReminded, ReminderID: 2},
+		{Medication: "OtherMed", Date: "2024-01-02", Type: DoseEventReminded, ReminderID: 3},
+		{Medication: "TestMed", Date: "2024-01-02", Type: DoseEventAcknowledged, ReminderID: 2, Source: "Discord", CorrelationID: "abc123"},
+	}
+	for _, event := range events {
+		if err := store.AppendDoseEvent(ctx, event); err != nil {
+			t.Fatalf("Failed to append dose event: %v", err)
+		}
+	}
+
+	// Test case: Only events for the medication since the date are returned, in order
+	got, err := store.GetDoseEvents(ctx, "TestMed", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Failed to get dose events: %v", err)
+	}
+	if len(got) != 2 || got[0].Type != DoseEventReminded || got[1].Type != DoseEventAcknowledged || got[1].Source != "Discord" {
+		t.Errorf("Unexpected dose events: %+v", got)
+	}
+	if got[1].CreatedAt.IsZero() {
+		t.Error("Expected dose event to be timestamped")
+	}
+	if got[1].CorrelationID != "abc123" {
+		t.Errorf("Expected the correlation ID to be saved, got %q", got[1].CorrelationID)
+	}
+
+	// Test case: Events can't be changed or removed
+	if _, err := store.db.ExecContext(ctx, "UPDATE dose_events SET type = ? WHERE id = ?", DoseEventMissed, got[0].ID); err == nil {
+		t.Error("Expected updating a dose event to fail")
+	}
+	if _, err := store.db.ExecContext(ctx, "DELETE FROM dose_events"); err == nil {
+		t.Error("Expected deleting dose events to fail")
+	}
+}
+
+func TestCachedStore(t *testing.T) {
+	dbPath := "test_cached_store.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	cache := NewCachedStore(store, time.Minute)
+
+	reminder, err := cache.GetTodayReminder(ctx, "TestMed")
+	if err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+
+	// Test case: Changes to a returned reminder don't affect the cache
+	reminder.Acknowledged = true
+	cached, err := cache.GetTodayReminder(ctx, "TestMed")
+	if err != nil {
+		t.Fatalf("Failed to get cached reminder: %v", err)
+	}
+	if cached.Acknowledged {
+		t.Error("Expected the cached reminder to be unaffected by callers")
+	}
+
+	// Test case: Updates made outside of the cache aren't seen until it expires
+	if err := store.RecordNag(ctx, reminder.ID, reminder.Version, "msg1"); err != nil {
+		t.Fatalf("Failed to update reminder: %v", err)
+	}
+	cached, _ = cache.GetTodayReminder(ctx, "TestMed")
+	if cached.MessageID != "" {
+		t.Errorf("Expected the cached reminder before expiry, got message ID %q", cached.MessageID)
+	}
+
+	// Test case: Updates through the cache invalidate it
+	if err := cache.RecordAcknowledgment(ctx, reminder.ID, reminder.Version+1, "msg2", "", time.Time{}); err != nil {
+		t.Fatalf("Failed to update reminder: %v", err)
+	}
+	cached, _ = cache.GetTodayReminder(ctx, "TestMed")
+	if !cached.Acknowledged || cached.MessageID != "msg2" || cached.NagCount != 1 || cached.Version != 2 {
+		t.Errorf("Expected the updated reminder after invalidation, got %+v", cached)
+	}
+
+	// Test case: Deleting dose photos through the cache drops their hashes from cached reminders
+	if err := cache.RecordDoseProof(ctx, reminder.ID, &DosePhoto{ContentType: "image/jpeg", Data: []byte("jpeg")}); err != nil {
+		t.Fatalf("Failed to record dose photo: %v", err)
+	}
+	if cached, _ = cache.GetTodayReminder(ctx, "TestMed"); cached.ProofHash == "" {
+		t.Fatal("Expected the cached reminder to have the photo's hash")
+	}
+	if _, err := cache.DeleteDoseProofs(ctx, time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02")); err != nil {
+		t.Fatalf("Failed to delete dose photos: %v", err)
+	}
+	if cached, _ = cache.GetTodayReminder(ctx, "TestMed"); cached.ProofHash != "" {
+		t.Errorf("Expected the deleted photo's hash to be gone from the cache, got %q", cached.ProofHash)
+	}
+}
+
+func TestEnsureReminders(t *testing.T) {
+	dbPath := "test_ensure_reminders.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	existing, err := store.GetTodayReminder(ctx, "MedA")
+	if err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+	if err := store.RecordAcknowledgment(ctx, existing.ID, existing.Version, "msg1", "", time.Time{}); err != nil {
+		t.Fatalf("Failed to update reminder: %v", err)
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	reminders, err := store.EnsureReminders(ctx, today, []string{"MedA", "MedB", "MedC", "MedB"})
+	if err != nil {
+		t.Fatalf("Failed to ensure reminders: %v", err)
+	}
+	if len(reminders) != 3 {
+		t.Fatalf("Expected 3 reminders, got %d", len(reminders))
+	}
+	if reminders[0].ID != existing.ID || !reminders[0].Acknowledged || reminders[0].MessageID != "msg1" {
+		t.Errorf("Expected the existing reminder to be kept, got %+v", reminders[0])
+	}
+	if reminders[0].CorrelationID != existing.CorrelationID || reminders[1].CorrelationID == "" || reminders[1].CorrelationID == reminders[2].CorrelationID {
+		t.Errorf("Expected each dose to have its own correlation ID, got %+v", reminders)
+	}
+
+	// Test case: Reminders aren't created twice
+	reminders, err = store.GetRemindersForDate(ctx, today)
+	if err != nil {
+		t.Fatalf("Failed to get reminders: %v", err)
+	}
+	if len(reminders) != 3 {
+		t.Errorf("Expected 3 reminders for today, got %d", len(reminders))
+	}
+
+	reminder, err := store.GetTodayReminder(ctx, "MedB")
+	if err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+	if reminder.ID != reminders[1].ID {
+		t.Errorf("Expected the created reminder to be returned, got ID %d and %d", reminder.ID, reminders[1].ID)
+	}
+}
+
+func BenchmarkGetTodayReminder(b *testing.B) {
+	ctx := context.Background()
+	store, err := NewStore(ctx, filepath.Join(b.TempDir(), "bench.db"), time.UTC)
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for b.Loop() {
+		if _, err := store.GetTodayReminder(ctx, "BenchMed"); err != nil {
+			b.Fatalf("Failed to get reminder: %v", err)
+		}
+	}
+}
+
+func BenchmarkCachedGetTodayReminder(b *testing.B) {
+	ctx := context.Background()
+	store, err := NewStore(ctx, filepath.Join(b.TempDir(), "bench.db"), time.UTC)
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	cache := NewCachedStore(store, time.Minute)
+
+	for b.Loop() {
+		if _, err := cache.GetTodayReminder(ctx, "BenchMed"); err != nil {
+			b.Fatalf("Failed to get reminder: %v", err)
+		}
+	}
+}
+
+func BenchmarkEnsureReminders(b *testing.B) {
+	ctx := context.Background()
+	store, err := NewStore(ctx, filepath.Join(b.TempDir(), "bench.db"), time.UTC)
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	names := make([]string, 50)
+	for i := range names {
+		names[i] = fmt.Sprintf("BenchMed%d", i)
+	}
+	today := time.Now().UTC().Format("2006-01-02")
+
+	for b.Loop() {
+		if _, err := store.EnsureReminders(ctx, today, names); err != nil {
+			b.Fatalf("Failed to ensure reminders: %v", err)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	var store StoreInterface = NewMemoryStore(time.UTC)
+
+	reminder, err := store.GetTodayReminder(ctx, "TestMed")
+	if err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+	if reminder.ID == 0 || reminder.Acknowledged {
+		t.Fatalf("Expected a new unacknowledged reminder, got %+v", reminder)
+	}
+
+	if err := store.RecordNag(ctx, reminder.ID, reminder.Version, "msg1"); err != nil {
+		t.Fatalf("Failed to update reminder: %v", err)
+	}
+	if err := store.RecordAcknowledgment(ctx, reminder.ID, reminder.Version, "msg2", "", time.Time{}); !errors.Is(err, ErrReminderConflict) {
+		t.Errorf("Expected a conflict updating a stale reminder, got %v", err)
+	}
+	if err := store.RecordAcknowledgment(ctx, reminder.ID, reminder.Version+1, "msg2", "", time.Time{}); err != nil {
+		t.Fatalf("Failed to update reminder: %v", err)
+	}
+
+	updated, err := store.GetTodayReminder(ctx, "TestMed")
+	if err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+	if updated.ID != reminder.ID || !updated.Acknowledged || updated.MessageID != "msg2" || updated.NagCount != 1 || updated.Version != 2 ||
+		updated.AcknowledgedAt.IsZero() || updated.LastReminderTime.IsZero() {
+		t.Errorf("Expected the updated reminder, got %+v", updated)
+	}
+
+	if err := store.RecordNag(ctx, reminder.ID, updated.Version, "msg3"); !errors.Is(err, ErrAlreadyAcknowledged) {
+		t.Errorf("Expected nagging an acknowledged reminder to fail, got %v", err)
+	}
+	if err := store.RecordAcknowledgment(ctx, reminder.ID, updated.Version, "msg3", "", time.Time{}); !errors.Is(err, ErrAlreadyAcknowledged) {
+		t.Errorf("Expected acknowledging twice to fail, got %v", err)
+	}
+	if err := store.MoveReminderMessage(ctx, 999, "msg3"); !errors.Is(err, ErrReminderNotFound) {
+		t.Errorf("Expected moving a missing reminder to fail, got %v", err)
+	}
+	if nagged, _ := store.GetTodayReminder(ctx, "TestMed"); !nagged.Acknowledged || nagged.MessageID != "msg2" || nagged.NagCount != 1 {
+		t.Errorf("Expected a nag after the acknowledgment to be ignored, got %+v", nagged)
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	reminders, err := store.EnsureReminders(ctx, today, []string{"TestMed", "OtherMed"})
+	if err != nil {
+		t.Fatalf("Failed to ensure reminders: %v", err)
+	}
+	if len(reminders) != 2 || reminders[0].MedicationType != "OtherMed" || reminders[1].ID != reminder.ID {
+		t.Errorf("Expected the existing reminder and a new one, got %+v", reminders)
+	}
+
+	// Test case: Saved records are returned by value
+	info := &MedicationInfo{Name: "TestMed", Dose: "10mg", PillsRemaining: 30}
+	if err := store.SaveMedicationInfo(ctx, info); err != nil {
+		t.Fatalf("Failed to save medication info: %v", err)
+	}
+	info.Dose = "20mg"
+	saved, err := store.GetMedicationInfo(ctx, "TestMed")
+	if err != nil {
+		t.Fatalf("Failed to get medication info: %v", err)
+	}
+	if saved.Dose != "10mg" {
+		t.Errorf("Expected the saved dose to be unaffected, got %q", saved.Dose)
+	}
+
+	missing, err := store.GetMedicationInfo(ctx, "Unknown")
+	if err != nil {
+		t.Fatalf("Failed to get medication info: %v", err)
+	}
+	if missing.PillsRemaining != UntrackedPills {
+		t.Errorf("Expected untracked pills for unknown medication, got %d", missing.PillsRemaining)
+	}
+
+	if err := store.SetState(ctx, "key", "value"); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+	if value, _ := store.GetState(ctx, "key"); value != "value" {
+		t.Errorf("Expected state value, got %q", value)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "checkpoint.db")
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, "file:"+dbPath+"?_pragma=journal_mode(wal)&_pragma=wal_autocheckpoint(0)", time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if _, err := store.GetTodayReminder(ctx, "TestMed"); err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+
+	if err := store.Checkpoint(ctx); err != nil {
+		t.Errorf("Failed to checkpoint: %v", err)
+	}
+}
+
+func TestReadReplica(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// A separate database stands in for a replica, so queries served from it can be told apart
+	replica, err := NewStore(ctx, filepath.Join(dir, "replica.db"), time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create replica: %v", err)
+	}
+	if _, err := replica.GetTodayReminder(ctx, "ReplicaMed"); err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+	replica.Close()
+
+	store, err := NewStore(ctx, filepath.Join(dir, "primary.db"), time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.OpenReadReplica(ctx, "file:"+filepath.Join(dir, "replica.db")+"?mode=ro"); err != nil {
+		t.Fatalf("Failed to open read replica: %v", err)
+	}
+
+	if _, err := store.GetTodayReminder(ctx, "PrimaryMed"); err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+
+	history, err := store.GetReminderHistory(ctx, "", time.Now().AddDate(0, 0, -1))
+	if err != nil {
+		t.Fatalf("Failed to get reminder history: %v", err)
+	}
+	if len(history) != 1 || history[0].MedicationType != "ReplicaMed" {
+		t.Errorf("Expected history from the replica, got %+v", history)
+	}
+
+	today, err := store.GetRemindersForDate(ctx, time.Now().UTC().Format("2006-01-02"))
+	if err != nil {
+		t.Fatalf("Failed to get reminders: %v", err)
+	}
+	if len(today) != 1 || today[0].MedicationType != "PrimaryMed" {
+		t.Errorf("Expected today's reminders from the primary, got %+v", today)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(ctx, filepath.Join(t.TempDir(), "tenants.db"), time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	guildA := store.ForTenant("guild-a")
+	guildB := store.ForTenant("guild-b")
+
+	reminderA, err := guildA.GetTodayReminder(ctx, "SharedMed")
+	if err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+	if err := guildA.RecordAcknowledgment(ctx, reminderA.ID, reminderA.Version, "msgA", "", time.Time{}); err != nil {
+		t.Fatalf("Failed to update reminder: %v", err)
+	}
+
+	// Test case: A medication with the same name is a separate reminder in another guild
+	reminderB, err := guildB.GetTodayReminder(ctx, "SharedMed")
+	if err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+	if reminderB.ID == reminderA.ID || reminderB.Acknowledged {
+		t.Errorf("Expected guild B to have its own unacknowledged reminder, got %+v", reminderB)
+	}
+
+	// Test case: A guild can't change another guild's reminder by ID
+	if err := guildB.RecordNag(ctx, reminderA.ID, reminderA.Version+1, "msgB"); !errors.Is(err, ErrReminderNotFound) {
+		t.Fatalf("Expected another guild's reminder not to be found, got %v", err)
+	}
+	if err := guildB.RecordHeadsUp(ctx, reminderA.ID, "msgB"); !errors.Is(err, ErrReminderNotFound) {
+		t.Fatalf("Expected another guild's reminder not to be found, got %v", err)
+	}
+	reminderA, _ = guildA.GetTodayReminder(ctx, "SharedMed")
+	if !reminderA.Acknowledged || reminderA.MessageID != "msgA" || reminderA.HeadsUpSent {
+		t.Errorf("Expected guild A's reminder to be unchanged, got %+v", reminderA)
+	}
+
+	// Test case: History, logs and records only include the guild's own data
+	since := time.Now().AddDate(0, 0, -1)
+	for name, scoped := range map[string]*Store{"guild-a": guildA, "guild-b": guildB, "default": store} {
+		history, err := scoped.GetReminderHistory(ctx, "", since)
+		if err != nil {
+			t.Fatalf("Failed to get history: %v", err)
+		}
+		want := 1
+		if name == "default" {
+			want = 0
+		}
+		if len(history) != want {
+			t.Errorf("Expected %d reminders in %s history, got %d", want, name, len(history))
+		}
+		for _, reminder := range history {
+			if (name == "guild-a" && reminder.ID != reminderA.ID) || (name == "guild-b" && reminder.ID != reminderB.ID) {
+				t.Errorf("Expected only %s's reminders, got %+v", name, reminder)
+			}
+		}
+	}
+
+	if err := guildA.SaveMedicationInfo(ctx, &MedicationInfo{Name: "SharedMed", Dose: "10mg", PillsRemaining: UntrackedPills}); err != nil {
+		t.Fatalf("Failed to save medication info: %v", err)
+	}
+	if err := guildA.SetState(ctx, "key", "a"); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+	if err := guildA.AppendDoseEvent(ctx, &DoseEvent{Medication: "SharedMed", Date: reminderA.Date, Type: DoseEventAcknowledged}); err != nil {
+		t.Fatalf("Failed to append dose event: %v", err)
+	}
+	if err := guildA.SaveLabTest(ctx, &LabTest{Name: "INR", Medication: "SharedMed", IntervalDays: 7, NextDue: reminderA.Date}); err != nil {
+		t.Fatalf("Failed to save lab test: %v", err)
+	}
+	if err := guildB.SaveLabTest(ctx, &LabTest{Name: "INR", Medication: "SharedMed", IntervalDays: 14, NextDue: reminderA.Date}); err != nil {
+		t.Fatalf("Failed to save lab test with the same name in another guild: %v", err)
+	}
+
+	info, _ := guildB.GetMedicationInfo(ctx, "SharedMed")
+	infos, _ := guildB.ListMedicationInfo(ctx)
+	value, _ := guildB.GetState(ctx, "key")
+	events, _ := guildB.GetDoseEvents(ctx, "", since)
+	test, _ := guildB.GetLabTest(ctx, "INR")
+	if info.Dose != "" || len(infos) != 0 || value != "" || len(events) != 0 || test.IntervalDays != 14 {
+		t.Errorf("Expected guild B not to see guild A's data, got %+v, %v, %q, %v, %+v", info, infos, value, events, test)
+	}
+}
+
+// TestScopeToTenant tests that in-memory and cached stores scoped to a guild don't share its data
+func TestScopeToTenant(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range map[string]StoreInterface{
+		"memory": NewMemoryStore(time.UTC),
+		"cached": NewCachedStore(NewMemoryStore(time.UTC), DefaultCacheTTL),
+	} {
+		t.Run(name, func(t *testing.T) {
+			home, err := store.GetTodayReminder(ctx, "SharedMed")
+			if err != nil {
+				t.Fatalf("Failed to get reminder: %v", err)
+			}
+			if err := store.RecordAcknowledgment(ctx, home.ID, home.Version, "msgHome", "", time.Time{}); err != nil {
+				t.Fatalf("Failed to update reminder: %v", err)
+			}
+
+			// Test case: The default tenant is the store itself
+			if scoped, err := ScopeToTenant(store, ""); err != nil || scoped != store {
+				t.Errorf("Expected the default tenant to be the store itself, got %v, %v", scoped, err)
+			}
+
+			// Test case: Another guild has its own reminder, and keeps the same store between calls
+			guild, err := ScopeToTenant(store, "guild-a")
+			if err != nil {
+				t.Fatalf("Failed to scope store: %v", err)
+			}
+			reminder, err := guild.GetTodayReminder(ctx, "SharedMed")
+			if err != nil {
+				t.Fatalf("Failed to get reminder: %v", err)
+			}
+			if reminder.Acknowledged {
+				t.Errorf("Expected guild A to have its own unacknowledged reminder, got %+v", reminder)
+			}
+			if again, _ := ScopeToTenant(store, "guild-a"); again != guild {
+				t.Error("Expected the same store for the guild each time")
+			}
+
+			// Test case: The guild's updates don't reach the default tenant
+			if err := guild.SaveMedicationInfo(ctx, &MedicationInfo{Name: "SharedMed", Dose: "10mg"}); err != nil {
+				t.Fatalf("Failed to save medication info: %v", err)
+			}
+			if info, _ := store.GetMedicationInfo(ctx, "SharedMed"); info != nil && info.Dose != "" {
+				t.Errorf("Expected the default tenant's info to be unchanged, got %+v", info)
+			}
+		})
+	}
+
+	// Test case: Stores that can't be scoped are refused rather than shared
+	if _, err := ScopeToTenant(struct{ StoreInterface }{NewMemoryStore(time.UTC)}, "guild-a"); err == nil {
+		t.Error("Expected an error scoping a store without tenants")
+	}
+}
+
+func TestTenantMigration(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+	ctx := context.Background()
+
+	// Create a database with the schema from before tenants were added
+	legacy, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = legacy.ExecContext(ctx, `
+	CREATE TABLE reminders (id INTEGER PRIMARY KEY, date TEXT NOT NULL, medication_type TEXT NOT NULL, acknowledged INTEGER DEFAULT 0, last_reminder_time TEXT, message_id TEXT);
+	CREATE TABLE medications (name TEXT PRIMARY KEY, dose TEXT NOT NULL DEFAULT '');
+	CREATE TABLE state (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+	CREATE TABLE lab_tests (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE COLLATE NOCASE, medication TEXT NOT NULL, unit TEXT NOT NULL DEFAULT '', interval_days INTEGER NOT NULL, next_due TEXT NOT NULL, reminded_on TEXT NOT NULL DEFAULT '');
+	CREATE TABLE lab_results (id INTEGER PRIMARY KEY, test_id INTEGER NOT NULL REFERENCES lab_tests(id), date TEXT NOT NULL, value REAL NOT NULL);
+	INSERT INTO reminders (date, medication_type, acknowledged) VALUES ('2024-01-01', 'OldMed', 1);
+	INSERT INTO medications (name, dose) VALUES ('OldMed', '5mg');
+	INSERT INTO state (key, value) VALUES ('trip', 'Europe/Paris');
+	INSERT INTO lab_tests (name, medication, interval_days, next_due) VALUES ('INR', 'OldMed', 7, '2024-01-08');
+	INSERT INTO lab_results (test_id, date, value) VALUES (1, '2024-01-01', 2.5);`)
+	legacy.Close()
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to migrate store: %v", err)
+	}
+	defer store.Close()
+
+	history, _ := store.GetReminderHistory(ctx, "OldMed", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	info, _ := store.GetMedicationInfo(ctx, "OldMed")
+	value, _ := store.GetState(ctx, "trip")
+	test, _ := store.GetLabTest(ctx, "INR")
+	if len(history) != 1 || info.Dose != "5mg" || value != "Europe/Paris" || test == nil {
+		t.Fatalf("Expected existing data in the default tenant, got %v, %+v, %q, %+v", history, info, value, test)
+	}
+
+	results, err := store.GetLabResults(ctx, test.ID, 10)
+	if err != nil || len(results) != 1 {
+		t.Errorf("Expected lab results to still reference their test, got %v, %v", results, err)
+	}
+
+	// Test case: The migrated tables are keyed by tenant
+	if err := store.ForTenant("guild-a").SaveMedicationInfo(ctx, &MedicationInfo{Name: "OldMed", Dose: "1mg"}); err != nil {
+		t.Errorf("Failed to save medication with the same name in another tenant: %v", err)
+	}
+	var schema string
+	store.db.QueryRowContext(ctx, "SELECT sql FROM sqlite_master WHERE name = 'lab_results'").Scan(&schema)
+	if !strings.Contains(schema, "REFERENCES lab_tests(id)") {
+		t.Errorf("Expected lab results to reference lab_tests, got %s", schema)
+	}
+}
+
+func TestDuplicateReminderMigration(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "duplicates.db")
+	ctx := context.Background()
+
+	// Create a database with reminders duplicated by instances creating them at once
+	legacy, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = legacy.ExecContext(ctx, `
+	CREATE TABLE reminders (id INTEGER PRIMARY KEY, date TEXT NOT NULL, medication_type TEXT NOT NULL, acknowledged INTEGER DEFAULT 0, last_reminder_time TEXT, message_id TEXT);
+	INSERT INTO reminders (date, medication_type, acknowledged) VALUES ('2024-01-01', 'Med', 0);
+	INSERT INTO reminders (date, medication_type, acknowledged) VALUES ('2024-01-01', 'Med', 1);
+	INSERT INTO reminders (date, medication_type, acknowledged) VALUES ('2024-01-02', 'Med', 0);
+	INSERT INTO reminders (date, medication_type, acknowledged) VALUES ('2024-01-02', 'Med', 0);`)
+	legacy.Close()
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to migrate store: %v", err)
+	}
+	defer store.Close()
+
+	// Test case: One reminder is kept for each dose, preferring the acknowledged one, then the first
+	history, err := store.GetReminderHistory(ctx, "Med", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(history) != 2 || history[0].ID != 2 || !history[0].Acknowledged || history[1].ID != 3 {
+		t.Fatalf("Expected the acknowledged and first reminders to be kept, got %+v", history)
+	}
+
+	// Test case: Another reminder for the same dose is refused
+	if _, err := store.db.ExecContext(ctx, "INSERT INTO reminders (date, medication_type) VALUES ('2024-01-01', 'Med')"); err == nil {
+		t.Error("Expected a duplicate reminder to be refused")
+	}
+}
+
+func TestGuildSettings(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(ctx, filepath.Join(t.TempDir(), "guilds.db"), time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for name, s := range map[string]StoreInterface{"sqlite": store, "memory": NewMemoryStore(time.UTC)} {
+		t.Run(name, func(t *testing.T) {
+			missing, err := s.GetGuildSettings(ctx, "guild-a")
+			if err != nil {
+				t.Fatalf("Failed to get guild settings: %v", err)
+			}
+			if missing != nil {
+				t.Fatalf("Expected no settings for a new guild, got %+v", missing)
+			}
+
+			if err := s.SaveGuildSettings(ctx, &GuildSettings{GuildID: "guild-a"}); err != nil {
+				t.Fatalf("Failed to save guild settings: %v", err)
+			}
+			want := GuildSettings{GuildID: "guild-a", ChannelID: "chan", Timezone: "Europe/London", Medication: "Vitamin D", MedicationHour: 9, Completed: true}
+			if err := s.SaveGuildSettings(ctx, &want); err != nil {
+				t.Fatalf("Failed to save guild settings: %v", err)
+			}
+
+			got, err := s.GetGuildSettings(ctx, "guild-a")
+			if err != nil {
+				t.Fatalf("Failed to get guild settings: %v", err)
+			}
+			if got == nil || *got != want {
+				t.Errorf("Expected %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestFeedback(t *testing.T) {
+	dbPath := "test_feedback.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	first := &Feedback{UserID: "user", Text: "The reminders are too loud"}
+	second := &Feedback{UserID: "user", Text: "Thanks!"}
+	for _, feedback := range []*Feedback{first, second} {
+		if err := store.RecordFeedback(ctx, feedback); err != nil {
+			t.Fatalf("Failed to record feedback: %v", err)
+		}
+	}
+
+	if first.ID == 0 || second.ID == first.ID {
+		t.Errorf("Expected distinct IDs, got %d and %d", first.ID, second.ID)
+	}
+	if first.CreatedAt.IsZero() {
+		t.Error("Expected the feedback time to be set")
+	}
+}
+
+func TestDoseProofs(t *testing.T) {
+	dbPath := "test_dose_proofs.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	old, err := store.EnsureReminders(ctx, "2024-01-01", []string{"Morning Pill"})
+	if err != nil {
+		t.Fatalf("Failed to create old reminder: %v", err)
+	}
+	today, err := store.GetTodayReminder(ctx, "Morning Pill")
+	if err != nil {
+		t.Fatalf("Failed to get today's reminder: %v", err)
+	}
+	photo := &DosePhoto{ContentType: "image/jpeg", Data: []byte("jpeg")}
+	for _, id := range []int64{old[0].ID, today.ID} {
+		if err := store.RecordDoseProof(ctx, id, photo); err != nil {
+			t.Fatalf("Failed to record dose photo: %v", err)
+		}
+	}
+
+	deleted, err := store.DeleteDoseProofs(ctx, "2024-01-02")
+	if err != nil {
+		t.Fatalf("Failed to delete dose photos: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 photo deleted, got %d", deleted)
+	}
+
+	reminders, err := store.GetReminderHistory(ctx, "Morning Pill", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Failed to get reminder history: %v", err)
+	}
+	for _, reminder := range reminders {
+		kept := reminder.ID == today.ID
+		if (reminder.ProofHash == photo.Hash()) != kept {
+			t.Errorf("Expected only today's photo to be kept, got %+v", reminder)
+		}
+		// The photo itself is kept in the database, rather than as a link that expires
+		stored, err := store.GetDosePhoto(ctx, reminder.ID)
+		if err != nil {
+			t.Fatalf("Failed to get dose photo: %v", err)
+		}
+		if (stored != nil && string(stored.Data) == "jpeg" && stored.ContentType == "image/jpeg") != kept {
+			t.Errorf("Expected only today's photo to be kept, got %+v", stored)
+		}
+	}
+
+	// Removing the photo keeps the dose
+	if err := store.RecordDoseProof(ctx, today.ID, nil); err != nil {
+		t.Fatalf("Failed to remove dose photo: %v", err)
+	}
+	if stored, err := store.GetDosePhoto(ctx, today.ID); err != nil || stored != nil {
+		t.Errorf("Expected the photo to be removed, got %+v, %v", stored, err)
+	}
+}
+
+func TestSkippedDoses(t *testing.T) {
+	dbPath := "test_skipped_doses.db"
+	defer os.Remove(dbPath)
+
+	ctx := context.Background()
+	store, err := NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for _, s := range []StoreInterface{store, NewMemoryStore(time.UTC)} {
+		reminder, err := s.GetTodayReminder(ctx, "Morning Pill")
+		if err != nil {
+			t.Fatalf("Failed to get today's reminder: %v", err)
+		}
+		if err := s.RecordSkip(ctx, reminder.ID, reminder.Version, "msg1", "Fasting for a blood test"); err != nil {
+			t.Fatalf("Failed to record skip: %v", err)
+		}
+
+		skipped, err := s.GetTodayReminder(ctx, "Morning Pill")
+		if err != nil {
+			t.Fatalf("Failed to get today's reminder: %v", err)
+		}
+		if !skipped.Skipped || skipped.SkipReason != "Fasting for a blood test" || skipped.Acknowledged || !skipped.Resolved() {
+			t.Errorf("Expected the dose to be skipped, got %+v", skipped)
+		}
+
+		// A nag can't undo the skip
+		if err := s.RecordNag(ctx, skipped.ID, skipped.Version, "msg2"); !errors.Is(err, ErrDoseSkipped) {
+			t.Errorf("Expected ErrDoseSkipped for a nag after the skip, got %v", err)
+		}
+
+		// Taking the dose after all undoes the skip
+		if err := s.RecordAcknowledgment(ctx, skipped.ID, skipped.Version, "msg1", "", time.Time{}); err != nil {
+			t.Fatalf("Failed to record acknowledgment: %v", err)
+		}
+		taken, err := s.GetTodayReminder(ctx, "Morning Pill")
+		if err != nil {
+			t.Fatalf("Failed to get today's reminder: %v", err)
+		}
+		if !taken.Acknowledged || taken.Skipped || taken.SkipReason != "" {
+			t.Errorf("Expected the dose to be taken rather than skipped, got %+v", taken)
+		}
+		if err := s.RecordSkip(ctx, taken.ID, taken.Version, "msg1", ""); !errors.Is(err, ErrAlreadyAcknowledged) {
+			t.Errorf("Expected ErrAlreadyAcknowledged for skipping a taken dose, got %v", err)
+		}
+	}
+}
+
+func TestQueries(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(ctx, filepath.Join(t.TempDir(), "queries.db"), time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Preparing a query checks its tables and columns against the schema without running it
+	for name, query := range queries {
+		stmt, err := store.db.PrepareContext(ctx, query)
+		if err != nil {
+			t.Errorf("%s doesn't match the schema: %v", name, err)
+			continue
+		}
+		stmt.Close()
+	}
+}
+
+func TestRebind(t *testing.T) {
+	got := rebind("SELECT value FROM state WHERE tenant_id = ? AND key = ?")
+	if want := "SELECT value FROM state WHERE tenant_id = $1 AND key = $2"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+// TestRebindQueries tests that every query, as run on Postgres, numbers the same placeholders as its
+// SQLite form in order, so its arguments line up without a Postgres database to run it against
+func TestRebindQueries(t *testing.T) {
+	store := &Store{driver: postgresDriver}
+	placeholder := regexp.MustCompile(`\$(\d+)`)
+
+	for name, query := range queries {
+		rebound := store.querier().sql(query)
+		if strings.Contains(rebound, "?") {
+			t.Errorf("%s still has a ? placeholder on Postgres: %s", name, rebound)
+			continue
+		}
+
+		// Rewritten queries may use a placeholder more than once, but never skip one
+		highest := 0
+		for _, match := range placeholder.FindAllStringSubmatch(rebound, -1) {
+			n, _ := strconv.Atoi(match[1])
+			if n > highest+1 {
+				t.Errorf("%s skips from $%d to $%d on Postgres", name, highest, n)
+			}
+			highest = max(highest, n)
+		}
+		if want := strings.Count(query, "?"); highest != want {
+			t.Errorf("%s takes %d arguments on SQLite but %d on Postgres", name, want, highest)
+		}
+	}
+}
+
+// TestPostgresQueries checks every query against the Postgres schema, when TEST_DATABASE_URL
+// points at a database the test can migrate
+func TestPostgresQueries(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL isn't set")
+	}
+
+	ctx := context.Background()
+	store, err := NewPostgresStore(ctx, databaseURL, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for name, query := range queries {
+		stmt, err := store.db.PrepareContext(ctx, store.querier().sql(query))
+		if err != nil {
+			t.Errorf("%s doesn't match the schema: %v", name, err)
+			continue
+		}
+		stmt.Close()
+	}
 }
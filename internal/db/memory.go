@@ -0,0 +1,746 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a StoreInterface kept entirely in memory, for demos, tests and simulations that
+// shouldn't touch disk. Everything is lost when the process exits.
+type MemoryStore struct {
+	location *time.Location
+	calendar *Calendar
+
+	mu          sync.Mutex
+	nextID      int64
+	reminders   []Reminder
+	photos      map[int64]DosePhoto
+	checklists  map[string]string
+	medications map[string]MedicationInfo
+	contacts    []Contact
+	refills     []Refill
+	labTests    []LabTest
+	labResults  []LabResult
+	state       map[string]string
+	doseEvents  []DoseEvent
+	guilds      map[string]GuildSettings
+	feedback    []Feedback
+	// tenants are the stores of other tenants (guilds), created the first time they're needed
+	tenants map[string]*MemoryStore
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore(location *time.Location) *MemoryStore {
+	if location == nil {
+		location = time.UTC
+	}
+
+	return &MemoryStore{
+		location:    location,
+		calendar:    NewCalendar(location),
+		photos:      make(map[int64]DosePhoto),
+		checklists:  make(map[string]string),
+		medications: make(map[string]MedicationInfo),
+		state:       make(map[string]string),
+		guilds:      make(map[string]GuildSettings),
+	}
+}
+
+// Close does nothing, as there's nothing to release
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// ForTenant returns the store of a tenant (guild), which shares the store's calendar but none of its
+// data, so one tenant can never read or change another's
+func (s *MemoryStore) ForTenant(tenantID string) *MemoryStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if scoped, ok := s.tenants[tenantID]; ok {
+		return scoped
+	}
+	if s.tenants == nil {
+		s.tenants = make(map[string]*MemoryStore)
+	}
+
+	scoped := NewMemoryStore(s.location)
+	scoped.calendar = s.calendar
+	s.tenants[tenantID] = scoped
+	return scoped
+}
+
+// Calendar returns the calendar today's reminders are dated by
+func (s *MemoryStore) Calendar() *Calendar {
+	return s.calendar
+}
+
+// today returns today's date in the store's timezone
+func (s *MemoryStore) today() string {
+	return time.Now().In(s.location).Format("2006-01-02")
+}
+
+// newID returns the next row ID, which is shared by all tables
+func (s *MemoryStore) newID() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// findReminder returns the reminder for a medication on a date, or nil if there isn't one
+func (s *MemoryStore) findReminder(date, medicationType string) *Reminder {
+	for i := range s.reminders {
+		if s.reminders[i].Date == date && s.reminders[i].MedicationType == medicationType {
+			return &s.reminders[i]
+		}
+	}
+	return nil
+}
+
+// GetTodayReminder gets or creates a reminder for today for a specific medication
+func (s *MemoryStore) GetTodayReminder(ctx context.Context, medicationType string) (*Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	today := s.calendar.Today(medicationType)
+	if reminder := s.findReminder(today, medicationType); reminder != nil {
+		copied := *reminder
+		return &copied, nil
+	}
+
+	reminder := Reminder{ID: s.newID(), Date: today, MedicationType: medicationType, CorrelationID: newCorrelationID()}
+	s.reminders = append(s.reminders, reminder)
+
+	return &reminder, nil
+}
+
+// RecordNag records that a reminder was sent in a new message, counting it as a nag. It returns
+// ErrReminderConflict if the reminder was changed first, or ErrAlreadyAcknowledged or ErrDoseSkipped
+// if it was acknowledged or skipped.
+func (s *MemoryStore) RecordNag(ctx context.Context, id, version int64, messageID string) error {
+	return s.updateReminder(id, version, false, func(reminder *Reminder) {
+		reminder.MessageID = messageID
+		reminder.LastReminderTime = time.Now().In(s.location).Truncate(time.Second)
+		reminder.NagCount++
+	})
+}
+
+// RecordAcknowledgment records that a dose was taken, the user who took it and the message showing it,
+// returning ErrReminderConflict if the reminder was changed first, or ErrAlreadyAcknowledged if it was acknowledged.
+// A skipped dose can still be taken, which undoes the skip. takenAt is when the dose was taken, or zero for now.
+func (s *MemoryStore) RecordAcknowledgment(ctx context.Context, id, version int64, messageID, userID string, takenAt time.Time) error {
+	if takenAt.IsZero() {
+		takenAt = time.Now()
+	}
+	return s.updateReminder(id, version, true, func(reminder *Reminder) {
+		reminder.Acknowledged = true
+		reminder.AcknowledgedAt = takenAt.In(s.location).Truncate(time.Second)
+		reminder.AcknowledgedBy = userID
+		reminder.MessageID = messageID
+		reminder.Skipped = false
+		reminder.SkipReason = ""
+	})
+}
+
+// RecordSkip records that a dose was skipped on purpose, why and the message showing it, returning
+// ErrReminderConflict if the reminder was changed first, or ErrAlreadyAcknowledged if it was acknowledged
+func (s *MemoryStore) RecordSkip(ctx context.Context, id, version int64, messageID, reason string) error {
+	return s.updateReminder(id, version, true, func(reminder *Reminder) {
+		reminder.Skipped = true
+		reminder.SkipReason = reason
+		reminder.MessageID = messageID
+	})
+}
+
+// updateReminder applies an update to an unacknowledged reminder if it's still at the given version,
+// and to a skipped one only if the update can follow a skip
+func (s *MemoryStore) updateReminder(id, version int64, afterSkip bool, update func(reminder *Reminder)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder := s.reminderByID(id)
+	switch {
+	case reminder == nil:
+		return ErrReminderNotFound
+	case reminder.Acknowledged:
+		return ErrAlreadyAcknowledged
+	case reminder.Skipped && !afterSkip:
+		return ErrDoseSkipped
+	case reminder.Version != version:
+		return ErrReminderConflict
+	}
+
+	update(reminder)
+	reminder.Version++
+	return nil
+}
+
+// reminderByID returns the reminder with an ID, or nil if there isn't one. The caller must hold the lock.
+func (s *MemoryStore) reminderByID(id int64) *Reminder {
+	for i := range s.reminders {
+		if s.reminders[i].ID == id {
+			return &s.reminders[i]
+		}
+	}
+	return nil
+}
+
+// RecordHeadsUp records that the heads-up before a reminder was sent, without counting it as a nag
+func (s *MemoryStore) RecordHeadsUp(ctx context.Context, id int64, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder := s.reminderByID(id)
+	if reminder == nil {
+		return fmt.Errorf("failed to record heads-up: %w", ErrReminderNotFound)
+	}
+	reminder.HeadsUpSent = true
+	reminder.MessageID = messageID
+	reminder.Version++
+
+	return nil
+}
+
+// RecordDoseProof records a photo of a dose being taken, replacing any recorded before, or deletes it
+// when the photo is nil
+func (s *MemoryStore) RecordDoseProof(ctx context.Context, id int64, photo *DosePhoto) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder := s.reminderByID(id)
+	if reminder == nil {
+		return fmt.Errorf("failed to record dose photo: %w", ErrReminderNotFound)
+	}
+	reminder.ProofHash = ""
+	delete(s.photos, id)
+	if photo != nil {
+		reminder.ProofHash = photo.Hash()
+		s.photos[id] = DosePhoto{ContentType: photo.ContentType, Data: slices.Clone(photo.Data)}
+	}
+	reminder.Version++
+
+	return nil
+}
+
+// GetDosePhoto returns the photo of a dose, or nil if it doesn't have one
+func (s *MemoryStore) GetDosePhoto(ctx context.Context, id int64) (*DosePhoto, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	photo, ok := s.photos[id]
+	if !ok {
+		return nil, nil
+	}
+	return &DosePhoto{ContentType: photo.ContentType, Data: slices.Clone(photo.Data)}, nil
+}
+
+// DeleteDoseProofs deletes the photos of doses before a date (YYYY-MM-DD), returning how many doses had one
+func (s *MemoryStore) DeleteDoseProofs(ctx context.Context, before string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for i := range s.reminders {
+		reminder := &s.reminders[i]
+		if reminder.Date < before && reminder.ProofHash != "" {
+			reminder.ProofHash = ""
+			reminder.Version++
+			delete(s.photos, reminder.ID)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// MoveReminderMessage records that a reminder was re-posted as a new message, without counting it as a nag
+func (s *MemoryStore) MoveReminderMessage(ctx context.Context, id int64, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder := s.reminderByID(id)
+	if reminder == nil {
+		return fmt.Errorf("failed to move reminder message: %w", ErrReminderNotFound)
+	}
+	reminder.MessageID = messageID
+	reminder.Version++
+
+	return nil
+}
+
+// RenameReminders moves a medication's reminders to another name, except on dates the other name
+// already has a reminder, and returns how many were moved
+func (s *MemoryStore) RenameReminders(ctx context.Context, from, to string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	taken := make(map[string]bool)
+	for _, reminder := range s.reminders {
+		if reminder.MedicationType == to {
+			taken[reminder.Date] = true
+		}
+	}
+
+	var renamed int64
+	for i := range s.reminders {
+		if s.reminders[i].MedicationType == from && !taken[s.reminders[i].Date] {
+			s.reminders[i].MedicationType = to
+			s.reminders[i].Version++
+			renamed++
+		}
+	}
+	return renamed, nil
+}
+
+// GetRemindersForDate returns every medication's reminder for a date (YYYY-MM-DD)
+func (s *MemoryStore) GetRemindersForDate(ctx context.Context, date string) ([]Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.remindersForDate(date), nil
+}
+
+// remindersForDate returns the reminders for a date, ordered by medication
+func (s *MemoryStore) remindersForDate(date string) []Reminder {
+	var reminders []Reminder
+	for _, reminder := range s.reminders {
+		if reminder.Date == date {
+			reminders = append(reminders, reminder)
+		}
+	}
+
+	slices.SortFunc(reminders, func(a, b Reminder) int {
+		return strings.Compare(a.MedicationType, b.MedicationType)
+	})
+
+	return reminders
+}
+
+// EnsureReminders creates any missing reminders for the medications on a date,
+// and returns all of the date's reminders
+func (s *MemoryStore) EnsureReminders(ctx context.Context, date string, medicationTypes []string) ([]Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, medicationType := range medicationTypes {
+		if s.findReminder(date, medicationType) == nil {
+			s.reminders = append(s.reminders, Reminder{ID: s.newID(), Date: date, MedicationType: medicationType, CorrelationID: newCorrelationID()})
+		}
+	}
+
+	return s.remindersForDate(date), nil
+}
+
+// GetReminderHistory returns the reminders for a medication from the given date onwards, oldest first.
+// An empty medication type returns reminders for all medications.
+func (s *MemoryStore) GetReminderHistory(ctx context.Context, medicationType string, since time.Time) ([]Reminder, error) {
+	sinceDate := since.In(s.location).Format("2006-01-02")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reminders []Reminder
+	for _, reminder := range s.reminders {
+		if reminder.Date >= sinceDate && (medicationType == "" || reminder.MedicationType == medicationType) {
+			reminders = append(reminders, reminder)
+		}
+	}
+
+	slices.SortFunc(reminders, func(a, b Reminder) int {
+		if c := strings.Compare(a.Date, b.Date); c != 0 {
+			return c
+		}
+		return strings.Compare(a.MedicationType, b.MedicationType)
+	})
+
+	return reminders, nil
+}
+
+// GetTodayChecklist returns the message ID of today's checklist, or an empty string if none has been posted
+func (s *MemoryStore) GetTodayChecklist(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.checklists[s.today()], nil
+}
+
+// SaveTodayChecklist records the message ID of today's checklist
+func (s *MemoryStore) SaveTodayChecklist(ctx context.Context, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checklists[s.today()] = messageID
+	return nil
+}
+
+// GetMedicationInfo returns the details recorded for a medication.
+// A medication with no recorded details returns an empty record.
+func (s *MemoryStore) GetMedicationInfo(ctx context.Context, name string) (*MedicationInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, ok := s.medications[name]
+	if !ok {
+		info = MedicationInfo{Name: name, PillsRemaining: UntrackedPills}
+	}
+
+	return &info, nil
+}
+
+// SaveMedicationInfo creates or replaces the details recorded for a medication
+func (s *MemoryStore) SaveMedicationInfo(ctx context.Context, info *MedicationInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.medications[info.Name] = *info
+	return nil
+}
+
+// AdjustPills adds delta doses to a medication's stock, stopping at none left, and returns how many are
+// left. Stock that isn't tracked is left alone, returning UntrackedPills.
+func (s *MemoryStore) AdjustPills(ctx context.Context, name string, delta int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, ok := s.medications[name]
+	if !ok || info.PillsRemaining == UntrackedPills {
+		return UntrackedPills, nil
+	}
+
+	info.PillsRemaining = max(info.PillsRemaining+delta, 0)
+	s.medications[name] = info
+	return info.PillsRemaining, nil
+}
+
+// RestockPills adds count doses to a medication's stock and returns how many are left, starting to track
+// the stock at count if it wasn't
+func (s *MemoryStore) RestockPills(ctx context.Context, name string, count int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, ok := s.medications[name]
+	if !ok {
+		info = MedicationInfo{Name: name, PillsRemaining: UntrackedPills}
+	}
+
+	if info.PillsRemaining == UntrackedPills {
+		info.PillsRemaining = count
+	} else {
+		info.PillsRemaining += count
+	}
+	s.medications[name] = info
+	return info.PillsRemaining, nil
+}
+
+// MarkRefilled sets a medication's refill due date and status and clears when it was last reminded about
+func (s *MemoryStore) MarkRefilled(ctx context.Context, name, nextDue, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, ok := s.medications[name]
+	if !ok {
+		info = MedicationInfo{Name: name, PillsRemaining: UntrackedPills}
+	}
+
+	info.RefillDue = nextDue
+	info.RefillRemindedOn = ""
+	info.RefillStatus = status
+	s.medications[name] = info
+	return nil
+}
+
+// RecordRefillReminder records the date a medication's refill reminder was sent
+func (s *MemoryStore) RecordRefillReminder(ctx context.Context, name, date string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if info, ok := s.medications[name]; ok {
+		info.RefillRemindedOn = date
+		s.medications[name] = info
+	}
+	return nil
+}
+
+// ListMedicationInfo returns the details recorded for all medications, ordered by name
+func (s *MemoryStore) ListMedicationInfo(ctx context.Context) ([]MedicationInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var infos []MedicationInfo
+	for _, info := range s.medications {
+		infos = append(infos, info)
+	}
+
+	slices.SortFunc(infos, func(a, b MedicationInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return infos, nil
+}
+
+// GetContact returns a prescriber or pharmacy contact, or nil if none is recorded.
+// Names are matched case-insensitively.
+func (s *MemoryStore) GetContact(ctx context.Context, kind, name string) (*Contact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, contact := range s.contacts {
+		if contact.Kind == kind && strings.EqualFold(contact.Name, name) {
+			found := contact
+			found.Name = name
+			return &found, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// SaveContact creates or replaces a prescriber or pharmacy contact
+func (s *MemoryStore) SaveContact(ctx context.Context, contact *Contact) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.contacts {
+		if s.contacts[i].Kind == contact.Kind && s.contacts[i].Name == contact.Name {
+			s.contacts[i] = *contact
+			return nil
+		}
+	}
+
+	s.contacts = append(s.contacts, *contact)
+	return nil
+}
+
+// ListContacts returns all prescriber and pharmacy contacts, ordered by kind and name
+func (s *MemoryStore) ListContacts(ctx context.Context) ([]Contact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contacts := slices.Clone(s.contacts)
+	slices.SortFunc(contacts, func(a, b Contact) int {
+		if c := strings.Compare(a.Kind, b.Kind); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return contacts, nil
+}
+
+// RecordRefill logs a medication refill, defaulting the date to today
+func (s *MemoryStore) RecordRefill(ctx context.Context, refill *Refill) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if refill.Date == "" {
+		refill.Date = s.today()
+	}
+	refill.ID = s.newID()
+	s.refills = append(s.refills, *refill)
+
+	return nil
+}
+
+// GetRefills returns the refills logged between two dates (inclusive), oldest first
+func (s *MemoryStore) GetRefills(ctx context.Context, from, to time.Time) ([]Refill, error) {
+	fromDate := from.In(s.location).Format("2006-01-02")
+	toDate := to.In(s.location).Format("2006-01-02")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var refills []Refill
+	for _, refill := range s.refills {
+		if refill.Date >= fromDate && refill.Date <= toDate {
+			refills = append(refills, refill)
+		}
+	}
+
+	slices.SortStableFunc(refills, func(a, b Refill) int {
+		return strings.Compare(a.Date, b.Date)
+	})
+
+	return refills, nil
+}
+
+// SaveLabTest creates or updates a lab test, matched by name
+func (s *MemoryStore) SaveLabTest(ctx context.Context, test *LabTest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.labTests {
+		if s.labTests[i].Name == test.Name {
+			test.ID = s.labTests[i].ID
+			s.labTests[i] = *test
+			return nil
+		}
+	}
+
+	test.ID = s.newID()
+	s.labTests = append(s.labTests, *test)
+	return nil
+}
+
+// GetLabTest returns a lab test by name, or nil if it doesn't exist
+func (s *MemoryStore) GetLabTest(ctx context.Context, name string) (*LabTest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, test := range s.labTests {
+		if test.Name == name {
+			return &test, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ListLabTests returns all lab tests, ordered by name
+func (s *MemoryStore) ListLabTests(ctx context.Context) ([]LabTest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tests := slices.Clone(s.labTests)
+	slices.SortFunc(tests, func(a, b LabTest) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return tests, nil
+}
+
+// RecordLabResult records a lab test result, defaulting the date to today
+func (s *MemoryStore) RecordLabResult(ctx context.Context, result *LabResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if result.Date == "" {
+		result.Date = s.today()
+	}
+	result.ID = s.newID()
+	s.labResults = append(s.labResults, *result)
+
+	return nil
+}
+
+// GetLabResults returns the most recent results of a lab test, oldest first
+func (s *MemoryStore) GetLabResults(ctx context.Context, testID int64, limit int) ([]LabResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []LabResult
+	for _, result := range s.labResults {
+		if result.TestID == testID {
+			results = append(results, result)
+		}
+	}
+
+	// Results are appended with increasing IDs, so a stable sort by date keeps them in ID order
+	slices.SortStableFunc(results, func(a, b LabResult) int {
+		return strings.Compare(a.Date, b.Date)
+	})
+	if limit >= 0 && len(results) > limit {
+		results = results[len(results)-limit:]
+	}
+
+	return results, nil
+}
+
+// AppendDoseEvent adds an event to the dose event log, timestamping it with the current time if unset
+func (s *MemoryStore) AppendDoseEvent(ctx context.Context, event *DoseEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().In(s.location)
+	}
+	event.ID = s.newID()
+	s.doseEvents = append(s.doseEvents, *event)
+
+	return nil
+}
+
+// RecordFeedback saves feedback sent by a user
+func (s *MemoryStore) RecordFeedback(ctx context.Context, feedback *Feedback) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if feedback.CreatedAt.IsZero() {
+		feedback.CreatedAt = time.Now().In(s.location)
+	}
+	feedback.ID = s.newID()
+	s.feedback = append(s.feedback, *feedback)
+
+	return nil
+}
+
+// GetDoseEvents returns the logged events for doses on or after a date in the order they happened,
+// for a single medication or all medications if empty
+func (s *MemoryStore) GetDoseEvents(ctx context.Context, medication string, since time.Time) ([]DoseEvent, error) {
+	sinceDate := since.In(s.location).Format("2006-01-02")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []DoseEvent
+	for _, event := range s.doseEvents {
+		if event.Date >= sinceDate && (medication == "" || event.Medication == medication) {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+// GetState returns a persisted bot state value, or an empty string if it isn't set
+func (s *MemoryStore) GetState(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state[key], nil
+}
+
+// SetState persists a bot state value
+func (s *MemoryStore) SetState(ctx context.Context, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state[key] = value
+	return nil
+}
+
+// CompareAndSetState sets a bot state value only if the state is still old, reporting whether it was set
+func (s *MemoryStore) CompareAndSetState(ctx context.Context, key, old, value string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state[key] != old {
+		return false, nil
+	}
+	s.state[key] = value
+	return true, nil
+}
+
+// GetGuildSettings returns a guild's onboarding settings, or nil if it hasn't been onboarded
+func (s *MemoryStore) GetGuildSettings(ctx context.Context, guildID string) (*GuildSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings, ok := s.guilds[guildID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &settings, nil
+}
+
+// SaveGuildSettings creates or replaces a guild's onboarding settings
+func (s *MemoryStore) SaveGuildSettings(ctx context.Context, settings *GuildSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.guilds[settings.GuildID] = *settings
+	return nil
+}
@@ -0,0 +1,262 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// NewPostgresStore creates a database store on a Postgres database, for hosts such as Fly.io and Heroku
+// whose local disk doesn't survive a redeploy. The schema is brought up to date by postgresMigrations.
+func NewPostgresStore(ctx context.Context, databaseURL string, location *time.Location) (*Store, error) {
+	return openStore(ctx, postgresDriver, databaseURL, location)
+}
+
+// postgresCorrelationIDSQL generates a correlation ID in Postgres, in the same format as newCorrelationID
+const postgresCorrelationIDSQL = "substr(md5(random()::text), 1, 16)"
+
+// postgresQueries are the queries in queries.go that Postgres needs written differently, rather than
+// only with its placeholders. SQLite's NOCASE collation has no Postgres equivalent, so names that are
+// matched regardless of case are compared in lower case.
+var postgresQueries = map[string]string{
+	getContactSQL: "SELECT phone, email, address FROM contacts WHERE tenant_id = $1 AND kind = $2 AND lower(name) = lower($3)",
+	getLabTestSQL: "SELECT id, name, medication, unit, interval_days, next_due, reminded_on FROM lab_tests WHERE tenant_id = $1 AND lower(name) = lower($2)",
+	saveLabTestSQL: `
+	INSERT INTO lab_tests (tenant_id, name, medication, unit, interval_days, next_due, reminded_on)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (tenant_id, lower(name)) DO UPDATE SET
+		medication = excluded.medication,
+		unit = excluded.unit,
+		interval_days = excluded.interval_days,
+		next_due = excluded.next_due,
+		reminded_on = excluded.reminded_on
+	RETURNING id`,
+}
+
+// rebind numbers the ? placeholders of a query written for SQLite as Postgres' $1, $2, ...
+func rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		fmt.Fprintf(&b, "$%d", n)
+	}
+	return b.String()
+}
+
+// postgresMigrationLock is the advisory lock held while migrating, so instances starting together
+// wait for each other rather than applying the same migration twice
+const postgresMigrationLock = 7_364_283_501
+
+// postgresMigrations are the changes made to a Postgres database's schema, in order. Each is applied
+// once and recorded in schema_migrations, so tables and columns are added by appending a migration,
+// never by changing one that has already run. Times are kept as RFC 3339 text, as they are in SQLite.
+var postgresMigrations = []string{
+	`
+	CREATE TABLE reminders (
+		id BIGSERIAL PRIMARY KEY,
+		date TEXT NOT NULL,
+		medication_type TEXT NOT NULL,
+		acknowledged INTEGER DEFAULT 0,
+		last_reminder_time TEXT,
+		acknowledged_at TEXT,
+		message_id TEXT,
+		nag_count INTEGER DEFAULT 0,
+		heads_up_sent INTEGER DEFAULT 0,
+		version BIGINT NOT NULL DEFAULT 0,
+		correlation_id TEXT NOT NULL DEFAULT '',
+		tenant_id TEXT NOT NULL DEFAULT '',
+		acknowledged_by TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE checklists (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		date TEXT NOT NULL,
+		message_id TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, date)
+	);
+
+	CREATE TABLE medications (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		dose TEXT NOT NULL DEFAULT '',
+		instructions TEXT NOT NULL DEFAULT '',
+		prescriber TEXT NOT NULL DEFAULT '',
+		pharmacy TEXT NOT NULL DEFAULT '',
+		start_date TEXT NOT NULL DEFAULT '',
+		refill_status TEXT NOT NULL DEFAULT '',
+		leaflet_url TEXT NOT NULL DEFAULT '',
+		refill_due TEXT NOT NULL DEFAULT '',
+		refill_reminded_on TEXT NOT NULL DEFAULT '',
+		pills_remaining INTEGER NOT NULL DEFAULT -1,
+		PRIMARY KEY (tenant_id, name)
+	);
+
+	CREATE TABLE contacts (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		kind TEXT NOT NULL,
+		name TEXT NOT NULL,
+		phone TEXT NOT NULL DEFAULT '',
+		email TEXT NOT NULL DEFAULT '',
+		address TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (tenant_id, kind, name)
+	);
+
+	CREATE TABLE refills (
+		id BIGSERIAL PRIMARY KEY,
+		medication TEXT NOT NULL,
+		date TEXT NOT NULL,
+		cost_cents BIGINT NOT NULL DEFAULT 0,
+		copay_cents BIGINT NOT NULL DEFAULT 0,
+		tenant_id TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE lab_tests (
+		id BIGSERIAL PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		medication TEXT NOT NULL,
+		unit TEXT NOT NULL DEFAULT '',
+		interval_days INTEGER NOT NULL,
+		next_due TEXT NOT NULL,
+		reminded_on TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE UNIQUE INDEX lab_tests_name ON lab_tests (tenant_id, lower(name));
+
+	CREATE TABLE lab_results (
+		id BIGSERIAL PRIMARY KEY,
+		test_id BIGINT NOT NULL REFERENCES lab_tests(id),
+		date TEXT NOT NULL,
+		value DOUBLE PRECISION NOT NULL,
+		tenant_id TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE state (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, key)
+	);
+
+	CREATE TABLE dose_events (
+		id BIGSERIAL PRIMARY KEY,
+		medication TEXT NOT NULL,
+		date TEXT NOT NULL,
+		type TEXT NOT NULL,
+		reminder_id BIGINT NOT NULL DEFAULT 0,
+		source TEXT NOT NULL DEFAULT '',
+		correlation_id TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		tenant_id TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE guild_settings (
+		guild_id TEXT PRIMARY KEY,
+		channel_id TEXT NOT NULL DEFAULT '',
+		timezone TEXT NOT NULL DEFAULT '',
+		medication TEXT NOT NULL DEFAULT '',
+		medication_hour INTEGER NOT NULL DEFAULT 0,
+		completed INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE feedback (
+		id BIGSERIAL PRIMARY KEY,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		user_id TEXT NOT NULL,
+		text TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+
+	CREATE INDEX dose_events_date ON dose_events (date);
+
+	CREATE FUNCTION dose_events_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'dose events are append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER dose_events_append_only BEFORE UPDATE OR DELETE ON dose_events
+	FOR EACH ROW EXECUTE FUNCTION dose_events_append_only();`,
+	`
+	ALTER TABLE reminders
+		ADD COLUMN proof_url TEXT NOT NULL DEFAULT '',
+		ADD COLUMN proof_hash TEXT NOT NULL DEFAULT '';`,
+	`
+	ALTER TABLE reminders
+		ADD COLUMN skipped INTEGER NOT NULL DEFAULT 0,
+		ADD COLUMN skip_reason TEXT NOT NULL DEFAULT '';`,
+	`
+	CREATE TABLE dose_photos (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		reminder_id BIGINT NOT NULL,
+		content_type TEXT NOT NULL,
+		data BYTEA NOT NULL,
+		PRIMARY KEY (tenant_id, reminder_id)
+	);`,
+	`
+	DELETE FROM reminders WHERE EXISTS (
+		SELECT 1 FROM reminders kept
+		WHERE kept.tenant_id = reminders.tenant_id AND kept.date = reminders.date AND kept.medication_type = reminders.medication_type
+		AND (COALESCE(kept.acknowledged, 0) > COALESCE(reminders.acknowledged, 0)
+			OR (COALESCE(kept.acknowledged, 0) = COALESCE(reminders.acknowledged, 0) AND kept.id < reminders.id))
+	);
+
+	DELETE FROM dose_photos WHERE NOT EXISTS (SELECT 1 FROM reminders WHERE reminders.id = dose_photos.reminder_id AND reminders.tenant_id = dose_photos.tenant_id);
+
+	CREATE UNIQUE INDEX reminders_dose ON reminders (tenant_id, date, medication_type);`,
+}
+
+// migratePostgres applies the migrations a Postgres database hasn't had yet, all in one transaction
+func (s *Store) migratePostgres(ctx context.Context) error {
+	// Migrations run over the network, so they're given longer than a local SQLite database
+	ctxExec, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctxExec, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctxExec, "SELECT pg_advisory_xact_lock($1)", postgresMigrationLock); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctxExec, "CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at TEXT NOT NULL)"); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var applied int
+	if err := tx.QueryRowContext(ctxExec, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&applied); err != nil {
+		return fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	// A database migrated by a newer version of the bot is refused rather than misread
+	if applied > len(postgresMigrations) {
+		return fmt.Errorf("database has migration %d, but this version of the bot only knows %d", applied, len(postgresMigrations))
+	}
+
+	for n, migration := range postgresMigrations[applied:] {
+		version := applied + n + 1
+		if _, err := tx.ExecContext(ctxExec, migration); err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctxExec, "INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)",
+			version, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", version, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migrations: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,500 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// dbtx is a database, or a transaction on one, that queries are run on
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// querier runs the queries in queries.go for one tenant. Each query has a method that takes its
+// arguments and returns its columns as Go values, and is the only code that runs it, so a query's
+// placeholders and result columns are matched to Go types in one place and a call with the wrong
+// arguments doesn't compile. Errors are returned as they are, so sql.ErrNoRows can be checked for.
+type querier struct {
+	db     dbtx
+	driver string
+	tenant string
+}
+
+// querier returns the store's queries, run on the primary database
+func (s *Store) querier() querier {
+	return querier{db: s.db, driver: s.driver, tenant: s.tenant}
+}
+
+// reportQuerier returns the store's queries, run on the connection used for reporting queries
+func (s *Store) reportQuerier(ctx context.Context) querier {
+	q := s.querier()
+	q.db = s.reports(ctx)
+	return q
+}
+
+// in returns the queries run in a transaction
+func (q querier) in(tx *sql.Tx) querier {
+	q.db = tx
+	return q
+}
+
+// sql returns a query, written for SQLite, in the SQL dialect of the driver
+func (q querier) sql(query string) string {
+	return inDialect(q.driver, query)
+}
+
+// exec runs a statement and returns how many rows it changed
+func (q querier) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	result, err := q.db.ExecContext(ctx, q.sql(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// insert runs an insert and returns the ID of the row added. Postgres doesn't report the last
+// insert ID, so it's returned by the query instead.
+func (q querier) insert(ctx context.Context, query string, args ...any) (int64, error) {
+	if q.driver == postgresDriver {
+		var id int64
+		err := q.db.QueryRowContext(ctx, q.sql(query+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := q.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// collect reads every row of a query with scan
+func collect[T any](rows *sql.Rows, err error, scan func(row rowScanner) (T, error)) ([]T, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []T
+	for rows.Next() {
+		value, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// Reminders
+
+// scanReminder reads a reminder from a row of reminderColumns
+func scanReminder(row rowScanner) (Reminder, error) {
+	var reminder Reminder
+	var acknowledged, headsUpSent, skipped int
+	var messageID, lastReminderTime, acknowledgedAt sql.NullString
+
+	err := row.Scan(&reminder.ID, &reminder.Date, &reminder.MedicationType, &acknowledged, &messageID, &lastReminderTime, &acknowledgedAt, &reminder.NagCount, &headsUpSent, &reminder.Version, &reminder.CorrelationID, &reminder.AcknowledgedBy, &reminder.ProofHash, &skipped, &reminder.SkipReason)
+	if err != nil {
+		return Reminder{}, err
+	}
+
+	reminder.Acknowledged = acknowledged == 1
+	reminder.HeadsUpSent = headsUpSent == 1
+	reminder.Skipped = skipped == 1
+	reminder.MessageID = messageID.String
+	if lastReminderTime.Valid {
+		reminder.LastReminderTime, _ = time.Parse(time.RFC3339, lastReminderTime.String)
+	}
+	if acknowledgedAt.Valid {
+		reminder.AcknowledgedAt, _ = time.Parse(time.RFC3339, acknowledgedAt.String)
+	}
+
+	return reminder, nil
+}
+
+// getTodayReminder returns a medication's reminder for a date
+func (q querier) getTodayReminder(ctx context.Context, date, medicationType string) (Reminder, error) {
+	return scanReminder(q.db.QueryRowContext(ctx, q.sql(getTodayReminderSQL), q.tenant, date, medicationType))
+}
+
+// remindersForDate returns every medication's reminder for a date, ordered by medication
+func (q querier) remindersForDate(ctx context.Context, date string) ([]Reminder, error) {
+	rows, err := q.db.QueryContext(ctx, q.sql(remindersForDateSQL), q.tenant, date)
+	return collect(rows, err, scanReminder)
+}
+
+// reminderHistory returns the reminders from a date onwards, for one medication or all of them if
+// medicationType is empty, oldest first
+func (q querier) reminderHistory(ctx context.Context, since, medicationType string) ([]Reminder, error) {
+	query := reminderHistorySQL
+	args := []any{q.tenant, since}
+	if medicationType != "" {
+		query += " AND medication_type = ?"
+		args = append(args, medicationType)
+	}
+	query += " ORDER BY date, medication_type"
+
+	rows, err := q.db.QueryContext(ctx, q.sql(query), args...)
+	return collect(rows, err, scanReminder)
+}
+
+// createReminder adds a medication's reminder for a date, unless it already has one
+func (q querier) createReminder(ctx context.Context, date, medicationType, correlationID string) error {
+	_, err := q.db.ExecContext(ctx, q.sql(createReminderSQL), q.tenant, date, medicationType, correlationID)
+	return err
+}
+
+// createReminders adds the reminders for a date of the medications without one, in one statement.
+// Reminders another instance created since they were found to be missing are kept by the unique index.
+func (q querier) createReminders(ctx context.Context, date string, medicationTypes []string) error {
+	values := make([]string, len(medicationTypes))
+	args := make([]any, 0, len(medicationTypes)+2)
+	for i, medicationType := range medicationTypes {
+		values[i] = "(CAST(? AS TEXT))"
+		args = append(args, medicationType)
+	}
+
+	correlationIDSQL := newCorrelationIDSQL
+	if q.driver == postgresDriver {
+		correlationIDSQL = postgresCorrelationIDSQL
+	}
+
+	// SQLite needs a WHERE clause to tell the upsert's ON from a join's
+	query := "WITH missing(medication_type) AS (VALUES " + strings.Join(values, ", ") + ") " +
+		"INSERT INTO reminders (tenant_id, date, medication_type, acknowledged, correlation_id) " +
+		"SELECT CAST(? AS TEXT), CAST(? AS TEXT), medication_type, 0, " + correlationIDSQL + " FROM missing WHERE true " +
+		"ON CONFLICT(tenant_id, date, medication_type) DO NOTHING"
+	args = append(args, q.tenant, date)
+
+	_, err := q.db.ExecContext(ctx, q.sql(query), args...)
+	return err
+}
+
+// recordNag records a reminder sent at a time in a message, if it's still at the version and neither
+// acknowledged nor skipped, and returns how many reminders were updated
+func (q querier) recordNag(ctx context.Context, id, version int64, messageID, sentAt string) (int64, error) {
+	return q.exec(ctx, recordNagSQL, messageID, sentAt, id, q.tenant, version)
+}
+
+// recordAcknowledgment records a dose taken by a user at a time, shown in a message, if its reminder is
+// still at the version and unacknowledged, and returns how many reminders were updated
+func (q querier) recordAcknowledgment(ctx context.Context, id, version int64, messageID, userID, takenAt string) (int64, error) {
+	return q.exec(ctx, recordAcknowledgmentSQL, takenAt, userID, messageID, id, q.tenant, version)
+}
+
+// recordSkip records a dose skipped for a reason, shown in a message, if its reminder is still at the
+// version and unacknowledged, and returns how many reminders were updated
+func (q querier) recordSkip(ctx context.Context, id, version int64, messageID, reason string) (int64, error) {
+	return q.exec(ctx, recordSkipSQL, reason, messageID, id, q.tenant, version)
+}
+
+// recordDoseProof sets the hash of a dose's photo, empty for none, and returns how many reminders were updated
+func (q querier) recordDoseProof(ctx context.Context, id int64, hash string) (int64, error) {
+	return q.exec(ctx, recordDoseProofSQL, hash, id, q.tenant)
+}
+
+// deleteDoseProofs clears the photo hashes of doses before a date and returns how many doses had one
+func (q querier) deleteDoseProofs(ctx context.Context, before string) (int64, error) {
+	return q.exec(ctx, deleteDoseProofsSQL, q.tenant, before)
+}
+
+// recordHeadsUp records the heads-up before a reminder sent in a message, and returns how many
+// reminders were updated
+func (q querier) recordHeadsUp(ctx context.Context, id int64, messageID string) (int64, error) {
+	return q.exec(ctx, recordHeadsUpSQL, messageID, id, q.tenant)
+}
+
+// moveReminderMessage records a reminder re-posted in a message, and returns how many reminders were updated
+func (q querier) moveReminderMessage(ctx context.Context, id int64, messageID string) (int64, error) {
+	return q.exec(ctx, moveReminderMessageSQL, messageID, id, q.tenant)
+}
+
+// renameReminders moves a medication's reminders to another name, except on dates the other name has
+// one, and returns how many were moved
+func (q querier) renameReminders(ctx context.Context, from, to string) (int64, error) {
+	return q.exec(ctx, renameRemindersSQL, to, q.tenant, from, to)
+}
+
+// reminderAcknowledged reports whether a reminder's dose was acknowledged or skipped
+func (q querier) reminderAcknowledged(ctx context.Context, id int64) (acknowledged, skipped bool, err error) {
+	var acknowledgedFlag, skippedFlag int
+	err = q.db.QueryRowContext(ctx, q.sql(reminderAcknowledgedSQL), id, q.tenant).Scan(&acknowledgedFlag, &skippedFlag)
+	return acknowledgedFlag == 1, skippedFlag == 1, err
+}
+
+// Dose photos
+
+// getDosePhoto returns the photo of a dose
+func (q querier) getDosePhoto(ctx context.Context, reminderID int64) (DosePhoto, error) {
+	var photo DosePhoto
+	err := q.db.QueryRowContext(ctx, q.sql(getDosePhotoSQL), q.tenant, reminderID).Scan(&photo.ContentType, &photo.Data)
+	return photo, err
+}
+
+// saveDosePhoto creates or replaces the photo of a dose
+func (q querier) saveDosePhoto(ctx context.Context, reminderID int64, photo *DosePhoto) error {
+	_, err := q.db.ExecContext(ctx, q.sql(saveDosePhotoSQL), q.tenant, reminderID, photo.ContentType, photo.Data)
+	return err
+}
+
+// deleteDosePhoto deletes the photo of a dose
+func (q querier) deleteDosePhoto(ctx context.Context, reminderID int64) error {
+	_, err := q.db.ExecContext(ctx, q.sql(deleteDosePhotoSQL), q.tenant, reminderID)
+	return err
+}
+
+// deleteDosePhotos deletes the photos of doses before a date
+func (q querier) deleteDosePhotos(ctx context.Context, before string) error {
+	_, err := q.db.ExecContext(ctx, q.sql(deleteDosePhotosSQL), q.tenant, q.tenant, before)
+	return err
+}
+
+// Checklists
+
+// getChecklist returns the message ID of a date's checklist
+func (q querier) getChecklist(ctx context.Context, date string) (string, error) {
+	var messageID string
+	err := q.db.QueryRowContext(ctx, q.sql(getChecklistSQL), q.tenant, date).Scan(&messageID)
+	return messageID, err
+}
+
+// saveChecklist records the message ID of a date's checklist
+func (q querier) saveChecklist(ctx context.Context, date, messageID string) error {
+	_, err := q.db.ExecContext(ctx, q.sql(saveChecklistSQL), q.tenant, date, messageID)
+	return err
+}
+
+// Medications
+
+// getMedicationInfo returns the details recorded for a medication
+func (q querier) getMedicationInfo(ctx context.Context, name string) (MedicationInfo, error) {
+	info := MedicationInfo{Name: name}
+	err := q.db.QueryRowContext(ctx, q.sql(getMedicationInfoSQL), q.tenant, name).Scan(&info.Dose, &info.Instructions, &info.Prescriber, &info.Pharmacy, &info.StartDate, &info.RefillStatus, &info.LeafletURL, &info.RefillDue, &info.RefillRemindedOn, &info.PillsRemaining)
+	return info, err
+}
+
+// listMedicationInfo returns the details recorded for every medication, ordered by name
+func (q querier) listMedicationInfo(ctx context.Context) ([]MedicationInfo, error) {
+	rows, err := q.db.QueryContext(ctx, q.sql(listMedicationInfoSQL), q.tenant)
+	return collect(rows, err, func(row rowScanner) (MedicationInfo, error) {
+		var info MedicationInfo
+		err := row.Scan(&info.Name, &info.Dose, &info.Instructions, &info.Prescriber, &info.Pharmacy, &info.StartDate, &info.RefillStatus, &info.LeafletURL, &info.RefillDue, &info.RefillRemindedOn, &info.PillsRemaining)
+		return info, err
+	})
+}
+
+// saveMedicationInfo creates or replaces the details recorded for a medication
+func (q querier) saveMedicationInfo(ctx context.Context, info *MedicationInfo) error {
+	_, err := q.db.ExecContext(ctx, q.sql(saveMedicationInfoSQL),
+		q.tenant, info.Name, info.Dose, info.Instructions, info.Prescriber, info.Pharmacy, info.StartDate, info.RefillStatus, info.LeafletURL, info.RefillDue, info.RefillRemindedOn, info.PillsRemaining)
+	return err
+}
+
+// adjustPills adds delta doses to a medication's tracked stock, stopping at none left, and returns how
+// many are left
+func (q querier) adjustPills(ctx context.Context, name string, delta int) (int, error) {
+	var remaining int
+	err := q.db.QueryRowContext(ctx, q.sql(adjustPillsSQL), delta, delta, q.tenant, name).Scan(&remaining)
+	return remaining, err
+}
+
+// restockPills adds count doses to a medication's stock, starting to track it if it wasn't, and returns
+// how many are left
+func (q querier) restockPills(ctx context.Context, name string, count int) (int, error) {
+	var remaining int
+	err := q.db.QueryRowContext(ctx, q.sql(restockPillsSQL), q.tenant, name, count).Scan(&remaining)
+	return remaining, err
+}
+
+// markRefilled sets a medication's refill due date and status and clears when it was reminded about
+func (q querier) markRefilled(ctx context.Context, name, nextDue, status string) error {
+	_, err := q.db.ExecContext(ctx, q.sql(markRefilledSQL), q.tenant, name, nextDue, status)
+	return err
+}
+
+// refillReminded records the date a medication's refill reminder was sent
+func (q querier) refillReminded(ctx context.Context, name, date string) error {
+	_, err := q.db.ExecContext(ctx, q.sql(refillRemindedSQL), date, q.tenant, name)
+	return err
+}
+
+// Contacts
+
+// getContact returns a contact, matching its name regardless of case
+func (q querier) getContact(ctx context.Context, kind, name string) (Contact, error) {
+	contact := Contact{Kind: kind, Name: name}
+	err := q.db.QueryRowContext(ctx, q.sql(getContactSQL), q.tenant, kind, name).Scan(&contact.Phone, &contact.Email, &contact.Address)
+	return contact, err
+}
+
+// listContacts returns every contact, ordered by kind and name
+func (q querier) listContacts(ctx context.Context) ([]Contact, error) {
+	rows, err := q.db.QueryContext(ctx, q.sql(listContactsSQL), q.tenant)
+	return collect(rows, err, func(row rowScanner) (Contact, error) {
+		var contact Contact
+		err := row.Scan(&contact.Kind, &contact.Name, &contact.Phone, &contact.Email, &contact.Address)
+		return contact, err
+	})
+}
+
+// saveContact creates or replaces a contact
+func (q querier) saveContact(ctx context.Context, contact *Contact) error {
+	_, err := q.db.ExecContext(ctx, q.sql(saveContactSQL), q.tenant, contact.Kind, contact.Name, contact.Phone, contact.Email, contact.Address)
+	return err
+}
+
+// Refills
+
+// getRefills returns the refills between two dates (inclusive), oldest first
+func (q querier) getRefills(ctx context.Context, from, to string) ([]Refill, error) {
+	rows, err := q.db.QueryContext(ctx, q.sql(getRefillsSQL), q.tenant, from, to)
+	return collect(rows, err, func(row rowScanner) (Refill, error) {
+		var refill Refill
+		err := row.Scan(&refill.ID, &refill.Medication, &refill.Date, &refill.CostCents, &refill.CopayCents)
+		return refill, err
+	})
+}
+
+// recordRefill logs a refill and returns its ID
+func (q querier) recordRefill(ctx context.Context, refill *Refill) (int64, error) {
+	return q.insert(ctx, recordRefillSQL, q.tenant, refill.Medication, refill.Date, refill.CostCents, refill.CopayCents)
+}
+
+// Lab tests and results
+
+// scanLabTest reads a lab test from a row of its columns
+func scanLabTest(row rowScanner) (LabTest, error) {
+	var test LabTest
+	err := row.Scan(&test.ID, &test.Name, &test.Medication, &test.Unit, &test.IntervalDays, &test.NextDue, &test.RemindedOn)
+	return test, err
+}
+
+// getLabTest returns a lab test, matching its name regardless of case
+func (q querier) getLabTest(ctx context.Context, name string) (LabTest, error) {
+	return scanLabTest(q.db.QueryRowContext(ctx, q.sql(getLabTestSQL), q.tenant, name))
+}
+
+// listLabTests returns every lab test, ordered by name
+func (q querier) listLabTests(ctx context.Context) ([]LabTest, error) {
+	rows, err := q.db.QueryContext(ctx, q.sql(listLabTestsSQL), q.tenant)
+	return collect(rows, err, scanLabTest)
+}
+
+// saveLabTest creates or updates a lab test, matched by name, and returns its ID
+func (q querier) saveLabTest(ctx context.Context, test *LabTest) (int64, error) {
+	var id int64
+	err := q.db.QueryRowContext(ctx, q.sql(saveLabTestSQL),
+		q.tenant, test.Name, test.Medication, test.Unit, test.IntervalDays, test.NextDue, test.RemindedOn).Scan(&id)
+	return id, err
+}
+
+// getLabResults returns a lab test's most recent results, up to limit, oldest first
+func (q querier) getLabResults(ctx context.Context, testID int64, limit int) ([]LabResult, error) {
+	rows, err := q.db.QueryContext(ctx, q.sql(getLabResultsSQL), q.tenant, testID, limit)
+	return collect(rows, err, func(row rowScanner) (LabResult, error) {
+		var result LabResult
+		err := row.Scan(&result.ID, &result.TestID, &result.Date, &result.Value)
+		return result, err
+	})
+}
+
+// recordLabResult records a lab test result and returns its ID
+func (q querier) recordLabResult(ctx context.Context, result *LabResult) (int64, error) {
+	return q.insert(ctx, recordLabResultSQL, q.tenant, result.TestID, result.Date, result.Value)
+}
+
+// Dose events
+
+// doseEvents returns the events for doses on or after a date, for one medication or all of them if
+// medication is empty, in the order they happened
+func (q querier) doseEvents(ctx context.Context, since, medication string) ([]DoseEvent, error) {
+	query := doseEventsSQL
+	args := []any{q.tenant, since}
+	if medication != "" {
+		query += " AND medication = ?"
+		args = append(args, medication)
+	}
+	query += " ORDER BY id"
+
+	rows, err := q.db.QueryContext(ctx, q.sql(query), args...)
+	return collect(rows, err, func(row rowScanner) (DoseEvent, error) {
+		var event DoseEvent
+		var createdAt string
+		if err := row.Scan(&event.ID, &event.Medication, &event.Date, &event.Type, &event.ReminderID, &event.Source, &event.CorrelationID, &createdAt); err != nil {
+			return DoseEvent{}, err
+		}
+		var err error
+		event.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+		return event, err
+	})
+}
+
+// appendDoseEvent adds an event to the dose event log and returns its ID
+func (q querier) appendDoseEvent(ctx context.Context, event *DoseEvent) (int64, error) {
+	return q.insert(ctx, appendDoseEventSQL,
+		q.tenant, event.Medication, event.Date, event.Type, event.ReminderID, event.Source, event.CorrelationID, event.CreatedAt.Format(time.RFC3339Nano))
+}
+
+// Feedback
+
+// recordFeedback saves feedback and returns its ID
+func (q querier) recordFeedback(ctx context.Context, feedback *Feedback) (int64, error) {
+	return q.insert(ctx, recordFeedbackSQL, q.tenant, feedback.UserID, feedback.Text, feedback.CreatedAt.Format(time.RFC3339))
+}
+
+// Guild settings, which belong to the guild rather than a tenant
+
+// getGuildSettings returns a guild's onboarding settings
+func (q querier) getGuildSettings(ctx context.Context, guildID string) (GuildSettings, error) {
+	settings := GuildSettings{GuildID: guildID}
+	var completed int
+	err := q.db.QueryRowContext(ctx, q.sql(getGuildSettingsSQL), guildID).Scan(&settings.ChannelID, &settings.Timezone, &settings.Medication, &settings.MedicationHour, &completed)
+	settings.Completed = completed == 1
+	return settings, err
+}
+
+// saveGuildSettings creates or replaces a guild's onboarding settings
+func (q querier) saveGuildSettings(ctx context.Context, settings *GuildSettings) error {
+	var completed int
+	if settings.Completed {
+		completed = 1
+	}
+	_, err := q.db.ExecContext(ctx, q.sql(saveGuildSettingsSQL),
+		settings.GuildID, settings.ChannelID, settings.Timezone, settings.Medication, settings.MedicationHour, completed)
+	return err
+}
+
+// Bot state
+
+// getState returns a state value
+func (q querier) getState(ctx context.Context, key string) (string, error) {
+	var value string
+	err := q.db.QueryRowContext(ctx, q.sql(getStateSQL), q.tenant, key).Scan(&value)
+	return value, err
+}
+
+// setState creates or replaces a state value
+func (q querier) setState(ctx context.Context, key, value string) error {
+	_, err := q.db.ExecContext(ctx, q.sql(setStateSQL), q.tenant, key, value)
+	return err
+}
+
+// setEmptyState sets a state value if it's missing or empty, and returns how many values were set
+func (q querier) setEmptyState(ctx context.Context, key, value string) (int64, error) {
+	return q.exec(ctx, setEmptyStateSQL, q.tenant, key, value)
+}
+
+// compareAndSetState sets a state value if it's still old, and returns how many values were set
+func (q querier) compareAndSetState(ctx context.Context, key, old, value string) (int64, error) {
+	return q.exec(ctx, compareAndSetStateSQL, value, q.tenant, key, old)
+}
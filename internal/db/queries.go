@@ -0,0 +1,202 @@
+package db
+
+// The Store's queries live here, rather than inline in its methods, so every query can be checked
+// against the schema by TestQueries and a column change only has to be made in one place.
+// Queries filtered by optional arguments hold their unfiltered form, which the method appends to.
+// Each query is run only by its method on querier, in querier.go, which takes its arguments and
+// returns its columns as Go values.
+
+// reminderColumns are the reminder columns read by scanReminder, in order
+const reminderColumns = "id, date, medication_type, acknowledged, message_id, last_reminder_time, acknowledged_at, nag_count, heads_up_sent, version, correlation_id, acknowledged_by, proof_hash, skipped, skip_reason"
+
+// Reminders
+const (
+	getTodayReminderSQL     = "SELECT " + reminderColumns + " FROM reminders WHERE tenant_id = ? AND date = ? AND medication_type = ?"
+	remindersForDateSQL     = "SELECT " + reminderColumns + " FROM reminders WHERE tenant_id = ? AND date = ? ORDER BY medication_type"
+	reminderHistorySQL      = "SELECT " + reminderColumns + " FROM reminders WHERE tenant_id = ? AND date >= ?"
+	createReminderSQL       = "INSERT INTO reminders (tenant_id, date, medication_type, acknowledged, correlation_id) VALUES (?, ?, ?, 0, ?) ON CONFLICT(tenant_id, date, medication_type) DO NOTHING"
+	recordNagSQL            = "UPDATE reminders SET message_id = ?, last_reminder_time = ?, nag_count = nag_count + 1, version = version + 1 WHERE id = ? AND tenant_id = ? AND version = ? AND acknowledged = 0 AND skipped = 0"
+	recordAcknowledgmentSQL = "UPDATE reminders SET acknowledged = 1, acknowledged_at = ?, acknowledged_by = ?, message_id = ?, skipped = 0, skip_reason = '', version = version + 1 WHERE id = ? AND tenant_id = ? AND version = ? AND acknowledged = 0"
+	recordSkipSQL           = "UPDATE reminders SET skipped = 1, skip_reason = ?, message_id = ?, version = version + 1 WHERE id = ? AND tenant_id = ? AND version = ? AND acknowledged = 0"
+	recordDoseProofSQL      = "UPDATE reminders SET proof_url = '', proof_hash = ?, version = version + 1 WHERE id = ? AND tenant_id = ?"
+	deleteDoseProofsSQL     = "UPDATE reminders SET proof_url = '', proof_hash = '', version = version + 1 WHERE tenant_id = ? AND date < ? AND proof_hash != ''"
+	recordHeadsUpSQL        = "UPDATE reminders SET heads_up_sent = 1, message_id = ?, version = version + 1 WHERE id = ? AND tenant_id = ?"
+	moveReminderMessageSQL  = "UPDATE reminders SET message_id = ?, version = version + 1 WHERE id = ? AND tenant_id = ?"
+	renameRemindersSQL      = "UPDATE reminders SET medication_type = ?, version = version + 1 WHERE tenant_id = ? AND medication_type = ? AND NOT EXISTS (SELECT 1 FROM reminders renamed WHERE renamed.tenant_id = reminders.tenant_id AND renamed.medication_type = ? AND renamed.date = reminders.date)"
+	reminderAcknowledgedSQL = "SELECT acknowledged, skipped FROM reminders WHERE id = ? AND tenant_id = ?"
+)
+
+// Reminders used to be created without a unique key, so instances creating the same reminder at once
+// could each add one. dedupeRemindersSQL keeps one reminder per dose, preferring the acknowledged one
+// and otherwise the first created, so the unique index can be added, and orphanedDosePhotosSQL deletes
+// the photos of the reminders it removes. Postgres databases have the same done by a migration.
+const (
+	dedupeRemindersSQL = `
+	DELETE FROM reminders WHERE EXISTS (
+		SELECT 1 FROM reminders kept
+		WHERE kept.tenant_id = reminders.tenant_id AND kept.date = reminders.date AND kept.medication_type = reminders.medication_type
+		AND (COALESCE(kept.acknowledged, 0) > COALESCE(reminders.acknowledged, 0)
+			OR (COALESCE(kept.acknowledged, 0) = COALESCE(reminders.acknowledged, 0) AND kept.id < reminders.id))
+	)`
+	orphanedDosePhotosSQL = "DELETE FROM dose_photos WHERE NOT EXISTS (SELECT 1 FROM reminders WHERE reminders.id = dose_photos.reminder_id AND reminders.tenant_id = dose_photos.tenant_id)"
+	remindersDoseIndexSQL = "CREATE UNIQUE INDEX IF NOT EXISTS reminders_dose ON reminders (tenant_id, date, medication_type)"
+)
+
+// Dose photos
+const (
+	getDosePhotoSQL     = "SELECT content_type, data FROM dose_photos WHERE tenant_id = ? AND reminder_id = ?"
+	saveDosePhotoSQL    = "INSERT INTO dose_photos (tenant_id, reminder_id, content_type, data) VALUES (?, ?, ?, ?) ON CONFLICT(tenant_id, reminder_id) DO UPDATE SET content_type = excluded.content_type, data = excluded.data"
+	deleteDosePhotoSQL  = "DELETE FROM dose_photos WHERE tenant_id = ? AND reminder_id = ?"
+	deleteDosePhotosSQL = "DELETE FROM dose_photos WHERE tenant_id = ? AND reminder_id IN (SELECT id FROM reminders WHERE tenant_id = ? AND date < ?)"
+)
+
+// Checklists
+const (
+	getChecklistSQL  = "SELECT message_id FROM checklists WHERE tenant_id = ? AND date = ?"
+	saveChecklistSQL = "INSERT INTO checklists (tenant_id, date, message_id) VALUES (?, ?, ?) ON CONFLICT(tenant_id, date) DO UPDATE SET message_id = excluded.message_id"
+)
+
+// Medications
+const (
+	getMedicationInfoSQL  = "SELECT dose, instructions, prescriber, pharmacy, start_date, refill_status, leaflet_url, refill_due, refill_reminded_on, pills_remaining FROM medications WHERE tenant_id = ? AND name = ?"
+	adjustPillsSQL        = "UPDATE medications SET pills_remaining = CASE WHEN pills_remaining + ? < 0 THEN 0 ELSE pills_remaining + ? END WHERE tenant_id = ? AND name = ? AND pills_remaining != -1 RETURNING pills_remaining"
+	restockPillsSQL       = "INSERT INTO medications (tenant_id, name, pills_remaining) VALUES (?, ?, ?) ON CONFLICT(tenant_id, name) DO UPDATE SET pills_remaining = CASE WHEN medications.pills_remaining = -1 THEN excluded.pills_remaining ELSE medications.pills_remaining + excluded.pills_remaining END RETURNING pills_remaining"
+	markRefilledSQL       = "INSERT INTO medications (tenant_id, name, refill_due, refill_status) VALUES (?, ?, ?, ?) ON CONFLICT(tenant_id, name) DO UPDATE SET refill_due = excluded.refill_due, refill_reminded_on = '', refill_status = excluded.refill_status"
+	refillRemindedSQL     = "UPDATE medications SET refill_reminded_on = ? WHERE tenant_id = ? AND name = ?"
+	listMedicationInfoSQL = "SELECT name, dose, instructions, prescriber, pharmacy, start_date, refill_status, leaflet_url, refill_due, refill_reminded_on, pills_remaining FROM medications WHERE tenant_id = ? ORDER BY name"
+	saveMedicationInfoSQL = `
+	INSERT INTO medications (tenant_id, name, dose, instructions, prescriber, pharmacy, start_date, refill_status, leaflet_url, refill_due, refill_reminded_on, pills_remaining)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(tenant_id, name) DO UPDATE SET
+		dose = excluded.dose,
+		instructions = excluded.instructions,
+		prescriber = excluded.prescriber,
+		pharmacy = excluded.pharmacy,
+		start_date = excluded.start_date,
+		refill_status = excluded.refill_status,
+		leaflet_url = excluded.leaflet_url,
+		refill_due = excluded.refill_due,
+		refill_reminded_on = excluded.refill_reminded_on,
+		pills_remaining = excluded.pills_remaining`
+)
+
+// Contacts
+const (
+	getContactSQL   = "SELECT phone, email, address FROM contacts WHERE tenant_id = ? AND kind = ? AND name = ? COLLATE NOCASE"
+	listContactsSQL = "SELECT kind, name, phone, email, address FROM contacts WHERE tenant_id = ? ORDER BY kind, name"
+	saveContactSQL  = `
+	INSERT INTO contacts (tenant_id, kind, name, phone, email, address)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT(tenant_id, kind, name) DO UPDATE SET
+		phone = excluded.phone,
+		email = excluded.email,
+		address = excluded.address`
+)
+
+// Refills
+const (
+	getRefillsSQL   = "SELECT id, medication, date, cost_cents, copay_cents FROM refills WHERE tenant_id = ? AND date >= ? AND date <= ? ORDER BY date, id"
+	recordRefillSQL = "INSERT INTO refills (tenant_id, medication, date, cost_cents, copay_cents) VALUES (?, ?, ?, ?, ?)"
+)
+
+// Lab tests and results
+const (
+	getLabTestSQL   = "SELECT id, name, medication, unit, interval_days, next_due, reminded_on FROM lab_tests WHERE tenant_id = ? AND name = ?"
+	listLabTestsSQL = "SELECT id, name, medication, unit, interval_days, next_due, reminded_on FROM lab_tests WHERE tenant_id = ? ORDER BY name"
+	saveLabTestSQL  = `
+	INSERT INTO lab_tests (tenant_id, name, medication, unit, interval_days, next_due, reminded_on)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(tenant_id, name) DO UPDATE SET
+		medication = excluded.medication,
+		unit = excluded.unit,
+		interval_days = excluded.interval_days,
+		next_due = excluded.next_due,
+		reminded_on = excluded.reminded_on
+	RETURNING id`
+	getLabResultsSQL = `
+	SELECT id, test_id, date, value FROM (
+		SELECT id, test_id, date, value FROM lab_results WHERE tenant_id = ? AND test_id = ? ORDER BY date DESC, id DESC LIMIT ?
+	) AS recent ORDER BY date, id`
+	recordLabResultSQL = "INSERT INTO lab_results (tenant_id, test_id, date, value) VALUES (?, ?, ?, ?)"
+)
+
+// Dose events
+const (
+	doseEventsSQL      = "SELECT id, medication, date, type, reminder_id, source, correlation_id, created_at FROM dose_events WHERE tenant_id = ? AND date >= ?"
+	appendDoseEventSQL = "INSERT INTO dose_events (tenant_id, medication, date, type, reminder_id, source, correlation_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+)
+
+// Feedback
+const recordFeedbackSQL = "INSERT INTO feedback (tenant_id, user_id, text, created_at) VALUES (?, ?, ?, ?)"
+
+// Guild settings
+const (
+	getGuildSettingsSQL  = "SELECT channel_id, timezone, medication, medication_hour, completed FROM guild_settings WHERE guild_id = ?"
+	saveGuildSettingsSQL = `
+	INSERT INTO guild_settings (guild_id, channel_id, timezone, medication, medication_hour, completed)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT(guild_id) DO UPDATE SET
+		channel_id = excluded.channel_id,
+		timezone = excluded.timezone,
+		medication = excluded.medication,
+		medication_hour = excluded.medication_hour,
+		completed = excluded.completed`
+)
+
+// Bot state
+const (
+	getStateSQL = "SELECT value FROM state WHERE tenant_id = ? AND key = ?"
+	setStateSQL = "INSERT INTO state (tenant_id, key, value) VALUES (?, ?, ?) ON CONFLICT(tenant_id, key) DO UPDATE SET value = excluded.value"
+	// A missing key counts as empty, so it's created when it's expected to be empty
+	setEmptyStateSQL      = "INSERT INTO state (tenant_id, key, value) VALUES (?, ?, ?) ON CONFLICT(tenant_id, key) DO UPDATE SET value = excluded.value WHERE state.value = ''"
+	compareAndSetStateSQL = "UPDATE state SET value = ? WHERE tenant_id = ? AND key = ? AND value = ?"
+)
+
+// queries lists every query above, for TestQueries
+var queries = map[string]string{
+	"getTodayReminderSQL":     getTodayReminderSQL,
+	"remindersForDateSQL":     remindersForDateSQL,
+	"reminderHistorySQL":      reminderHistorySQL,
+	"createReminderSQL":       createReminderSQL,
+	"recordNagSQL":            recordNagSQL,
+	"recordAcknowledgmentSQL": recordAcknowledgmentSQL,
+	"recordSkipSQL":           recordSkipSQL,
+	"recordDoseProofSQL":      recordDoseProofSQL,
+	"deleteDoseProofsSQL":     deleteDoseProofsSQL,
+	"getDosePhotoSQL":         getDosePhotoSQL,
+	"saveDosePhotoSQL":        saveDosePhotoSQL,
+	"deleteDosePhotoSQL":      deleteDosePhotoSQL,
+	"deleteDosePhotosSQL":     deleteDosePhotosSQL,
+	"recordHeadsUpSQL":        recordHeadsUpSQL,
+	"moveReminderMessageSQL":  moveReminderMessageSQL,
+	"renameRemindersSQL":      renameRemindersSQL,
+	"reminderAcknowledgedSQL": reminderAcknowledgedSQL,
+	"getChecklistSQL":         getChecklistSQL,
+	"saveChecklistSQL":        saveChecklistSQL,
+	"getMedicationInfoSQL":    getMedicationInfoSQL,
+	"adjustPillsSQL":          adjustPillsSQL,
+	"listMedicationInfoSQL":   listMedicationInfoSQL,
+	"saveMedicationInfoSQL":   saveMedicationInfoSQL,
+	"restockPillsSQL":         restockPillsSQL,
+	"markRefilledSQL":         markRefilledSQL,
+	"refillRemindedSQL":       refillRemindedSQL,
+	"getContactSQL":           getContactSQL,
+	"listContactsSQL":         listContactsSQL,
+	"saveContactSQL":          saveContactSQL,
+	"getRefillsSQL":           getRefillsSQL,
+	"recordRefillSQL":         recordRefillSQL,
+	"getLabTestSQL":           getLabTestSQL,
+	"listLabTestsSQL":         listLabTestsSQL,
+	"saveLabTestSQL":          saveLabTestSQL,
+	"getLabResultsSQL":        getLabResultsSQL,
+	"recordLabResultSQL":      recordLabResultSQL,
+	"doseEventsSQL":           doseEventsSQL,
+	"appendDoseEventSQL":      appendDoseEventSQL,
+	"recordFeedbackSQL":       recordFeedbackSQL,
+	"getGuildSettingsSQL":     getGuildSettingsSQL,
+	"saveGuildSettingsSQL":    saveGuildSettingsSQL,
+	"getStateSQL":             getStateSQL,
+	"setStateSQL":             setStateSQL,
+	"setEmptyStateSQL":        setEmptyStateSQL,
+	"compareAndSetStateSQL":   compareAndSetStateSQL,
+}
@@ -0,0 +1,141 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"meds-bot/internal/events"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// accessCheckInterval is how often access to the reminder channel is rechecked while sending is paused
+const accessCheckInterval = time.Minute
+
+// lostAccessCodes are the Discord error codes meaning the reminder channel is gone or the bot can't post in it
+var lostAccessCodes = []int{discordgo.ErrCodeUnknownChannel, discordgo.ErrCodeMissingAccess, discordgo.ErrCodeMissingPermissions}
+
+// lostAccessReason returns Discord's explanation if an error means the bot can no longer post in the channel
+func lostAccessReason(err error) (string, bool) {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) || restErr.Message == nil || !slices.Contains(lostAccessCodes, restErr.Message.Code) {
+		return "", false
+	}
+	return restErr.Message.Message, true
+}
+
+// whileAccessible wraps a handler that posts in the reminder channel, skipping it with ErrDeliveryPaused
+// while access to the channel is lost or maintenance mode is on, so the event is published again once
+// sending resumes. Sending is paused if the handler fails because access was lost.
+func whileAccessible[T events.Event](ctx context.Context, c *Client, handler func(ctx context.Context, event T) error) func(ctx context.Context, event T) error {
+	return func(eventCtx context.Context, event T) error {
+		if c.ChannelLost() || c.InMaintenance() {
+			return events.ErrDeliveryPaused
+		}
+
+		err := handler(eventCtx, event)
+		if reason, ok := lostAccessReason(err); ok {
+			c.loseChannel(ctx, reason)
+		}
+		return err
+	}
+}
+
+// ChannelLost reports whether sending is paused because the bot can't post in the reminder channel
+func (c *Client) ChannelLost() bool {
+	c.accessMu.Lock()
+	defer c.accessMu.Unlock()
+	return c.channelLost
+}
+
+// loseChannel pauses sending and tells the operator, then rechecks access until it's restored
+func (c *Client) loseChannel(ctx context.Context, reason string) {
+	c.accessMu.Lock()
+	if c.channelLost {
+		c.accessMu.Unlock()
+		return
+	}
+	c.channelLost = true
+	c.accessMu.Unlock()
+
+	log.Printf("Warning: Lost access to reminder channel %s (%s), pausing reminders until it's restored", c.channelID, reason)
+	c.alertOperator(fmt.Sprintf("⚠️ I can't post in <#%s> any more (%s), so medication reminders are paused. "+
+		"They'll resume automatically once I can post there again.", c.channelID, reason))
+
+	go c.watchChannelAccess(ctx)
+}
+
+// watchChannelAccess resumes sending once the bot can post in the reminder channel again
+func (c *Client) watchChannelAccess(ctx context.Context) {
+	ticker := time.NewTicker(accessCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := c.checkChannelAccess(); err != nil {
+			log.Printf("Debug: Reminder channel still can't be reached: %v", err)
+			continue
+		}
+
+		c.accessMu.Lock()
+		c.channelLost = false
+		c.accessMu.Unlock()
+
+		log.Printf("Access to reminder channel %s restored, resuming reminders", c.channelID)
+		c.alertOperator(fmt.Sprintf("✅ I can post in <#%s> again, so medication reminders have resumed.", c.channelID))
+		return
+	}
+}
+
+// checkChannelAccess checks the bot can post reminders in the reminder channel, including their embeds and attachments
+func (c *Client) checkChannelAccess() error {
+	if err := validateChannel(c.session.Load(), c.channelID); err != nil {
+		return err
+	}
+
+	required, names := int64(discordgo.PermissionEmbedLinks), "Embed Links"
+	if c.attachesFiles() {
+		required |= discordgo.PermissionAttachFiles
+		names += " and Attach Files"
+	}
+
+	permissions, err := c.session.Load().UserChannelPermissions(c.session.Load().State.User.ID, c.channelID)
+	if err != nil {
+		return fmt.Errorf("failed to get permissions: %w", err)
+	}
+	if permissions&required != required {
+		return fmt.Errorf("the bot needs the %s permissions", names)
+	}
+
+	return nil
+}
+
+// alertOperator reports a problem in the operator channel or, if there isn't one, by DM to the user reminders are for
+func (c *Client) alertOperator(content string) {
+	channelID := c.operatorChannelID
+	if channelID == "" || channelID == c.channelID {
+		if c.userIDToPing == "" {
+			return
+		}
+
+		dm, err := c.session.Load().UserChannelCreate(c.userIDToPing)
+		if err != nil {
+			log.Printf("Error opening DM to alert %s: %v", c.userIDToPing, err)
+			return
+		}
+		channelID = dm.ID
+	}
+
+	if _, err := c.session.Load().ChannelMessageSend(channelID, content); err != nil {
+		log.Printf("Error sending operator alert: %v", err)
+	}
+}
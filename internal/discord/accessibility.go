@@ -0,0 +1,65 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// accessibilityStateKeyPrefix prefixes the state keys users' accessibility preferences are stored under
+const accessibilityStateKeyPrefix = "accessibility_"
+
+// accessible reports whether reminders should use the simplified accessible layout, based on the
+// pinged user's preference and falling back to the configured default
+func (c *Client) accessible(ctx context.Context) bool {
+	if c.userIDToPing == "" {
+		return c.accessibleDefault
+	}
+
+	value, err := c.storeFor(ctx).GetState(ctx, accessibilityStateKeyPrefix+c.userIDToPing)
+	if err != nil {
+		log.Printf("Error getting accessibility preference: %v", err)
+		return c.accessibleDefault
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return c.accessibleDefault
+	}
+
+	return enabled
+}
+
+// accessibleReminderContent returns a plain reminder message without emoji or formatting
+func accessibleReminderContent(medicationName string) string {
+	return fmt.Sprintf("Time to take your %s.\nPress the button below when you have taken it.", medicationName)
+}
+
+// handleAccessibilityCommand saves the user's preference for simplified accessible reminders
+func (c *Client) handleAccessibilityCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	enabled := options["enabled"].BoolValue()
+
+	user := i.User
+	if i.Member != nil {
+		user = i.Member.User
+	}
+
+	if err := c.storeFor(ctx).SetState(ctx, accessibilityStateKeyPrefix+user.ID, strconv.FormatBool(enabled)); err != nil {
+		log.Printf("Error saving accessibility preference: %v", err)
+		c.respond(s, i, "Failed to save accessibility preference")
+		return
+	}
+
+	content := "Accessible reminders are off."
+	if enabled {
+		content = "Accessible reminders are on. Reminders will use plain wording and large buttons."
+	}
+	if user.ID != c.userIDToPing {
+		content += " Reminders follow the preference of the user they are sent to."
+	}
+
+	c.respond(s, i, content)
+}
@@ -0,0 +1,47 @@
+package discord
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"meds-bot/internal/events"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// noteActivity publishes that the user to ping is up the first time they're seen active each day,
+// so morning reminders held while they slept in are sent
+func (c *Client) noteActivity(ctx context.Context, at time.Time) {
+	today := at.In(c.location).Format("2006-01-02")
+
+	c.activityMu.Lock()
+	if c.activeOn == today {
+		c.activityMu.Unlock()
+		return
+	}
+	c.activeOn = today
+	c.activityMu.Unlock()
+
+	if err := c.events.Publish(ctx, events.UserActive{At: at}); err != nil {
+		log.Printf("Error publishing user activity: %v", err)
+	}
+}
+
+// handlePresenceUpdate notes the user to ping as active when they come online. Presence updates are
+// only received with the guild_presences intent.
+func (c *Client) handlePresenceUpdate(ctx context.Context, p *discordgo.PresenceUpdate) {
+	if p.User == nil || c.userIDToPing == "" || p.User.ID != c.userIDToPing {
+		return
+	}
+
+	// Idle and offline users may still be asleep
+	if p.Status == discordgo.StatusOnline || p.Status == discordgo.StatusDoNotDisturb {
+		c.noteActivity(ctx, time.Now())
+	}
+}
+
+// fromUserToPing checks if an interaction was made by the user to ping
+func (c *Client) fromUserToPing(i *discordgo.InteractionCreate) bool {
+	return c.userIDToPing != "" && interactionUserID(i) == c.userIDToPing
+}
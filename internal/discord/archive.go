@@ -0,0 +1,65 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"meds-bot/internal/events"
+)
+
+// onArchiveDue summarizes a past day's doses in the archive channel, then deletes that day's
+// reminder messages from the reminder channel
+func (c *Client) onArchiveDue(ctx context.Context, event events.ArchiveDue) error {
+	day, err := time.ParseInLocation("2006-01-02", event.Date, c.location)
+	if err != nil {
+		return fmt.Errorf("invalid archive date %s: %w", event.Date, err)
+	}
+
+	var lines []string
+	for _, reminder := range event.Reminders {
+		switch {
+		case reminder.Acknowledged && !reminder.AcknowledgedAt.IsZero():
+			lines = append(lines, fmt.Sprintf("✅ **%s** taken at %s", reminder.MedicationType, reminder.AcknowledgedAt.In(c.location).Format("15:04")))
+		case reminder.Acknowledged:
+			lines = append(lines, fmt.Sprintf("✅ **%s** taken", reminder.MedicationType))
+		case reminder.Skipped && reminder.SkipReason != "":
+			lines = append(lines, fmt.Sprintf("⏭️ **%s** skipped: %s", reminder.MedicationType, reminder.SkipReason))
+		case reminder.Skipped:
+			lines = append(lines, fmt.Sprintf("⏭️ **%s** skipped", reminder.MedicationType))
+		case reminder.NagCount > 0:
+			lines = append(lines, fmt.Sprintf("❌ **%s** missed after %d reminders", reminder.MedicationType, reminder.NagCount))
+		}
+	}
+
+	if len(lines) > 0 {
+		content := fmt.Sprintf("🗂️ **Doses on %s**\n%s", day.Format("Monday, 2 January 2006"), strings.Join(lines, "\n"))
+		if _, err := c.session.Load().ChannelMessageSend(c.archiveChannelID, content); err != nil {
+			return fmt.Errorf("failed to send archive for %s: %w", event.Date, err)
+		}
+	}
+
+	// Reminders can share a message, such as the day's checklist, so each is only deleted once
+	deleted := make(map[string]bool)
+	for _, reminder := range event.Reminders {
+		if reminder.MessageID == "" {
+			continue
+		}
+
+		if !deleted[reminder.MessageID] {
+			deleted[reminder.MessageID] = true
+			if err := c.DeleteMessage(ctx, reminder.MessageID); err != nil {
+				log.Printf("Error deleting archived message for %s on %s [dose %s]: %v", reminder.MedicationType, event.Date, reminder.CorrelationID, err)
+			}
+		}
+
+		if err := c.storeFor(ctx).MoveReminderMessage(ctx, reminder.ID, ""); err != nil {
+			log.Printf("Error clearing archived message for %s on %s [dose %s]: %v", reminder.MedicationType, event.Date, reminder.CorrelationID, err)
+		}
+	}
+
+	log.Printf("Archived reminders for %s", event.Date)
+	return nil
+}
@@ -0,0 +1,185 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// overLimitAction is the custom ID action of the button logging an as-needed dose over its limit
+const overLimitAction = "over_limit"
+
+// handleLogCommand records a dose of an as-needed medication, warning about or refusing doses over its
+// limit for any 24 hours
+func (c *Client) handleLogCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	name := options["name"].StringValue()
+	if !c.hasMedication(name) {
+		c.respondWithError(s, i, fmt.Sprintf("Unknown medication: %s", name))
+		return
+	}
+
+	medication := c.medicationByName(name)
+	if !medication.AsNeeded() {
+		c.respond(s, i, fmt.Sprintf("%s is taken on a schedule, so mark it as taken from its reminder instead.", name))
+		return
+	}
+	if !canTake(medication, interactionUserID(i)) {
+		c.respond(s, i, fmt.Sprintf("%s is someone else's medication, so you can't log a dose of it.", name))
+		return
+	}
+
+	doses, now, logged, err := c.logDose(ctx, medication, false)
+	if err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error logging %s", name), err)
+		return
+	}
+
+	if !logged {
+		next, _ := nextAllowedDose(doses, medication.MaxDosesPer24h)
+		if medication.BlockOverLimit {
+			c.respond(s, i, fmt.Sprintf("🚫 You've logged %d doses of %s in the last 24 hours, the most allowed. Your next dose is allowed at %s (<t:%d:R>).",
+				len(doses), name, next.Format("15:04 on Monday"), next.Unix()))
+			return
+		}
+		c.askToConfirmOverLimit(s, i, medication, len(doses), next)
+		return
+	}
+
+	c.doseLogged(ctx, s, i, medication, doses, now)
+}
+
+// registerOverLimitHandler registers the handler for logging an as-needed dose over its limit anyway
+func (c *Client) registerOverLimitHandler(ctx context.Context) {
+	c.RegisterHandler(overLimitAction, func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+		medication := c.medicationByName(args[0])
+		if !canTake(medication, interactionUserID(i)) {
+			c.respond(s, i, fmt.Sprintf("%s is someone else's medication, so you can't log a dose of it.", medication.Name))
+			return
+		}
+		// The limit may have been made a hard one since the warning was sent
+		if medication.BlockOverLimit {
+			c.respond(s, i, fmt.Sprintf("🚫 Doses of %s over its limit can't be logged.", medication.Name))
+			return
+		}
+
+		doses, now, _, err := c.logDose(ctx, medication, true)
+		if err != nil {
+			c.respondWithFailure(s, i, fmt.Sprintf("Error logging %s", medication.Name), err)
+			return
+		}
+		log.Printf("Warning: %s logged a dose of %s over its limit of %d in 24 hours", interactionUserID(i), medication.Name, medication.MaxDosesPer24h)
+		c.doseLogged(ctx, s, i, medication, doses, now)
+	})
+}
+
+// askToConfirmOverLimit warns that a dose would go over an as-needed medication's limit, with a button
+// to log it anyway
+func (c *Client) askToConfirmOverLimit(s *discordgo.Session, i *discordgo.InteractionCreate, medication config.Medication, taken int, next time.Time) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("⚠️ You've logged %d doses of %s in the last 24 hours, and the limit is %d. Your next dose is allowed at %s (<t:%d:R>). Are you sure you've taken another?",
+				taken, medication.Name, medication.MaxDosesPer24h, next.Format("15:04 on Monday"), next.Unix()),
+			Flags: discordgo.MessageFlagsEphemeral,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Yes, log it",
+							Style:    discordgo.DangerButton,
+							CustomID: c.customID(overLimitAction, medication.Name),
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error asking to confirm dose of %s over its limit: %v", medication.Name, err)
+	}
+}
+
+// logDose records a dose of an as-needed medication unless it would go over its limit, or anyway if
+// overLimit is set. It returns the doses already logged in the 24 hours before it, when it was logged and
+// whether it was. Doses are counted and logged under a lock, reading from the primary database, so two
+// logged at once can't both fit under the limit.
+func (c *Client) logDose(ctx context.Context, medication config.Medication, overLimit bool) ([]time.Time, time.Time, bool, error) {
+	c.logMu.Lock()
+	defer c.logMu.Unlock()
+
+	now := time.Now().In(c.location)
+	doses, err := c.recentLoggedDoses(db.WithPrimary(ctx), medication.Name, now)
+	if err != nil {
+		return nil, now, false, fmt.Errorf("failed to get recent doses: %w", err)
+	}
+	if _, over := nextAllowedDose(doses, medication.MaxDosesPer24h); over && !overLimit {
+		return doses, now, false, nil
+	}
+
+	err = c.storeFor(ctx).AppendDoseEvent(ctx, &db.DoseEvent{
+		Medication: medication.Name,
+		Date:       now.Format("2006-01-02"),
+		Type:       db.DoseEventLogged,
+		Source:     "Discord",
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return doses, now, false, fmt.Errorf("failed to log dose: %w", err)
+	}
+	return doses, now, true, nil
+}
+
+// doseLogged takes a logged dose of an as-needed medication from its stock, and says how many have been
+// taken in the last 24 hours, given the doses logged before it, and when the next is allowed
+func (c *Client) doseLogged(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, medication config.Medication, doses []time.Time, now time.Time) {
+	if _, err := c.storeFor(ctx).AdjustPills(ctx, medication.Base(), -1); err != nil {
+		log.Printf("Error updating stock of %s: %v", medication.Base(), err)
+	}
+	doses = append(doses, now)
+
+	content := fmt.Sprintf("💊 Logged a dose of %s at %s.", medication.Name, now.Format("15:04"))
+	if medication.MaxDosesPer24h > 0 {
+		content += fmt.Sprintf(" That's %d of %d in the last 24 hours.", len(doses), medication.MaxDosesPer24h)
+		if next, over := nextAllowedDose(doses, medication.MaxDosesPer24h); over {
+			content += fmt.Sprintf(" Your next dose is allowed at %s (<t:%d:R>).", next.Format("15:04 on Monday"), next.Unix())
+		}
+	}
+	c.respond(s, i, content)
+}
+
+// recentLoggedDoses returns the times of the doses of an as-needed medication logged in the 24 hours before now
+func (c *Client) recentLoggedDoses(ctx context.Context, medicationName string, now time.Time) ([]time.Time, error) {
+	since := now.Add(-24 * time.Hour)
+	doseEvents, err := c.storeFor(ctx).GetDoseEvents(ctx, medicationName, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var doses []time.Time
+	for _, event := range doseEvents {
+		if event.Type == db.DoseEventLogged && event.CreatedAt.After(since) {
+			doses = append(doses, event.CreatedAt.In(c.location))
+		}
+	}
+	return doses, nil
+}
+
+// nextAllowedDose returns when another dose can be taken without going over a limit of doses in any
+// 24 hours, and whether one taken now would go over it. A limit of 0 means there isn't one.
+func nextAllowedDose(doses []time.Time, limit int) (time.Time, bool) {
+	if limit <= 0 || len(doses) < limit {
+		return time.Time{}, false
+	}
+
+	doses = slices.Clone(doses)
+	slices.SortFunc(doses, func(a, b time.Time) int { return a.Compare(b) })
+	// The dose limit doses ago has to be 24 hours old before another is taken
+	return doses[len(doses)-limit].Add(24 * time.Hour), true
+}
@@ -0,0 +1,196 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"meds-bot/internal/config"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// progressBarWidth is the number of segments in the checklist progress bar
+	progressBarWidth = 10
+	// maxChecklistButtons is Discord's limit of 5 rows of 5 buttons per message
+	maxChecklistButtons = 25
+)
+
+// checklistItem is a single dose shown on the daily checklist
+type checklistItem struct {
+	Medication config.Medication
+	Taken      bool
+	// Skipped is true if the dose was skipped on purpose, which counts as done without being taken
+	Skipped bool
+}
+
+// SendChecklist posts today's medication checklist and returns the message ID
+func (c *Client) SendChecklist(ctx context.Context) (string, error) {
+	items, err := c.checklistItems(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	content, components := c.renderChecklist(items, c.accessible(ctx))
+
+	msg, err := c.session.Load().ChannelMessageSendComplex(c.channelID, &discordgo.MessageSend{
+		Content:    content,
+		Components: components,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to send checklist message: %w", err)
+	}
+
+	return msg.ID, nil
+}
+
+// updateChecklist re-renders an existing checklist message with the current progress
+func (c *Client) updateChecklist(ctx context.Context, messageID string) error {
+	items, err := c.checklistItems(ctx)
+	if err != nil {
+		return err
+	}
+
+	content, components := c.renderChecklist(items, c.accessible(ctx))
+
+	_, err = c.session.Load().ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel:    c.channelID,
+		ID:         messageID,
+		Content:    &content,
+		Components: &components,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to edit checklist message: %w", err)
+	}
+
+	return nil
+}
+
+// checklistItems builds the list of today's doses and whether each has been taken
+func (c *Client) checklistItems(ctx context.Context) ([]checklistItem, error) {
+	today := time.Now().In(c.location)
+
+	var items []checklistItem
+	for _, medication := range c.medications {
+		if !medication.IsScheduledOn(today) {
+			continue
+		}
+
+		reminder, err := c.storeFor(ctx).GetTodayReminder(ctx, medication.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get reminder for %s: %w", medication.Name, err)
+		}
+
+		items = append(items, checklistItem{
+			Medication: medication,
+			Taken:      reminder.Acknowledged,
+			Skipped:    reminder.Skipped && !reminder.Acknowledged,
+		})
+	}
+
+	return items, nil
+}
+
+// renderChecklist builds the checklist message content and a button for each dose not yet taken
+func (c *Client) renderChecklist(items []checklistItem, accessible bool) (string, []discordgo.MessageComponent) {
+	if accessible {
+		return c.renderAccessibleChecklist(items)
+	}
+
+	var content strings.Builder
+
+	for _, userID := range c.medicationUsers() {
+		content.WriteString(mention(userID))
+	}
+	content.WriteString(fmt.Sprintf("📋 **Medication Checklist: %s** 📋\n", time.Now().In(c.location).Format("Monday 2 January")))
+
+	done := 0
+	var buttons []discordgo.MessageComponent
+	for _, item := range items {
+		if item.Taken {
+			done++
+			content.WriteString(fmt.Sprintf("✅ ~~%s~~ (%s)\n", item.Medication.Name, item.Medication.Clock()))
+			continue
+		}
+		if item.Skipped {
+			done++
+			content.WriteString(fmt.Sprintf("⏭️ ~~%s~~ (%s) skipped\n", item.Medication.Name, item.Medication.Clock()))
+			continue
+		}
+
+		content.WriteString(fmt.Sprintf("⬜ %s (%s)\n", item.Medication.Name, item.Medication.Clock()))
+		if len(buttons) < maxChecklistButtons {
+			buttons = append(buttons, c.takenButton(item.Medication, item.Medication.Name, ""))
+		}
+	}
+
+	content.WriteString(fmt.Sprintf("\n%s %d/%d", progressBar(done, len(items)), done, len(items)))
+	if len(items) > 0 && done == len(items) {
+		content.WriteString("\n🎉 All done for today!")
+	}
+
+	// Discord allows up to 5 buttons per action row
+	components := []discordgo.MessageComponent{}
+	for start := 0; start < len(buttons); start += 5 {
+		end := start + 5
+		if end > len(buttons) {
+			end = len(buttons)
+		}
+		components = append(components, discordgo.ActionsRow{Components: buttons[start:end]})
+	}
+
+	return content.String(), components
+}
+
+// renderAccessibleChecklist builds a plain checklist without emoji or formatting, with one button per row
+func (c *Client) renderAccessibleChecklist(items []checklistItem) (string, []discordgo.MessageComponent) {
+	var content strings.Builder
+
+	for _, userID := range c.medicationUsers() {
+		content.WriteString(mention(userID))
+	}
+	content.WriteString(fmt.Sprintf("Medicines for %s\n", time.Now().In(c.location).Format("Monday 2 January")))
+
+	taken, skipped := 0, 0
+	components := []discordgo.MessageComponent{}
+	for _, item := range items {
+		if item.Taken {
+			taken++
+			content.WriteString(fmt.Sprintf("%s at %s: taken\n", item.Medication.Name, item.Medication.Clock()))
+			continue
+		}
+		if item.Skipped {
+			skipped++
+			content.WriteString(fmt.Sprintf("%s at %s: skipped\n", item.Medication.Name, item.Medication.Clock()))
+			continue
+		}
+
+		content.WriteString(fmt.Sprintf("%s at %s: not taken yet\n", item.Medication.Name, item.Medication.Clock()))
+
+		// Discord allows up to 5 action rows per message
+		if len(components) < 5 {
+			button := c.takenButton(item.Medication, fmt.Sprintf("I have taken my %s", item.Medication.Name), "")
+			button.Emoji = nil
+			components = append(components, discordgo.ActionsRow{Components: []discordgo.MessageComponent{button}})
+		}
+	}
+
+	content.WriteString(fmt.Sprintf("\n%d of %d taken.", taken, len(items)))
+	if len(items) > 0 && taken+skipped == len(items) {
+		content.WriteString(" All done for today.")
+	}
+
+	return content.String(), components
+}
+
+// progressBar renders a text progress bar for the number of completed doses
+func progressBar(done, total int) string {
+	if total == 0 {
+		return strings.Repeat("░", progressBarWidth)
+	}
+
+	filled := done * progressBarWidth / total
+	return strings.Repeat("▓", filled) + strings.Repeat("░", progressBarWidth-filled)
+}
@@ -0,0 +1,150 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"meds-bot/internal/db"
+	"meds-bot/internal/events"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// bulkDeleteMaxAge is the age after which Discord no longer bulk deletes messages, less a margin
+	// for the time taken to collect them
+	bulkDeleteMaxAge = 14*24*time.Hour - time.Hour
+	// bulkDeleteMaxMessages is the most messages Discord deletes in one bulk delete
+	bulkDeleteMaxMessages = 100
+	// discordEpoch is the start of time for Discord snowflake IDs, in Unix milliseconds
+	discordEpoch = 1420070400000
+)
+
+// onCleanupDue deletes the bot's messages in the reminder channel from before the cutoff, except
+// those still used by a pending reminder or today's checklist
+func (c *Client) onCleanupDue(ctx context.Context, event events.CleanupDue) error {
+	reminders, err := c.storeFor(ctx).GetReminderHistory(ctx, "", time.Time{})
+	if err != nil {
+		return fmt.Errorf("failed to get reminders: %w", err)
+	}
+
+	inUse := make(map[string]bool)
+	byMessage := make(map[string][]db.Reminder)
+	for _, reminder := range reminders {
+		if reminder.MessageID == "" {
+			continue
+		}
+		if !reminder.Resolved() {
+			inUse[reminder.MessageID] = true
+		}
+		byMessage[reminder.MessageID] = append(byMessage[reminder.MessageID], reminder)
+	}
+
+	checklistID, err := c.storeFor(ctx).GetTodayChecklist(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get today's checklist: %w", err)
+	}
+	inUse[checklistID] = true
+
+	stale, err := c.staleMessages(ctx, event.Before, inUse)
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	deleted := c.deleteMessages(ctx, stale)
+	for _, messageID := range deleted {
+		// Acknowledged reminders keep their message ID until it's deleted, so stop pointing at it
+		for _, reminder := range byMessage[messageID] {
+			if err := c.storeFor(ctx).MoveReminderMessage(ctx, reminder.ID, ""); err != nil {
+				log.Printf("Error clearing deleted message for %s on %s [dose %s]: %v", reminder.MedicationType, reminder.Date, reminder.CorrelationID, err)
+			}
+		}
+	}
+
+	log.Printf("Cleaned up %d of %d messages older than %s", len(deleted), len(stale), event.Before.Format("2006-01-02"))
+	return nil
+}
+
+// staleMessages pages back through the reminder channel from the cutoff, returning the bot's
+// messages that aren't in use, newest first
+func (c *Client) staleMessages(ctx context.Context, before time.Time, inUse map[string]bool) ([]*discordgo.Message, error) {
+	var stale []*discordgo.Message
+	beforeID := snowflakeAt(before)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		messages, err := c.session.Load().ChannelMessages(c.channelID, 100, beforeID, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list messages: %w", err)
+		}
+
+		for _, message := range messages {
+			if message.Author != nil && message.Author.ID == c.session.Load().State.User.ID && !inUse[message.ID] {
+				stale = append(stale, message)
+			}
+		}
+
+		if len(messages) < 100 {
+			return stale, nil
+		}
+		beforeID = messages[len(messages)-1].ID
+	}
+}
+
+// deleteMessages deletes messages in the reminder channel, bulk deleting those recent enough and
+// deleting older ones one at a time, and returns the IDs of those deleted
+func (c *Client) deleteMessages(ctx context.Context, messages []*discordgo.Message) []string {
+	var recent, old []string
+	for _, message := range messages {
+		if time.Since(message.Timestamp) < bulkDeleteMaxAge {
+			recent = append(recent, message.ID)
+		} else {
+			old = append(old, message.ID)
+		}
+	}
+
+	var deleted []string
+	for start := 0; start < len(recent); start += bulkDeleteMaxMessages {
+		batch := recent[start:min(start+bulkDeleteMaxMessages, len(recent))]
+		if err := c.session.Load().ChannelMessagesBulkDelete(c.channelID, batch); err != nil {
+			log.Printf("Error bulk deleting %d old messages: %v", len(batch), err)
+			continue
+		}
+		deleted = append(deleted, batch...)
+	}
+
+	ticker := time.NewTicker(buttonRefreshDelay)
+	defer ticker.Stop()
+
+	for i, messageID := range old {
+		if i > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return deleted
+			}
+		}
+
+		if err := c.session.Load().ChannelMessageDelete(c.channelID, messageID); err != nil {
+			log.Printf("Error deleting old message %s: %v", messageID, err)
+			continue
+		}
+		deleted = append(deleted, messageID)
+	}
+
+	return deleted
+}
+
+// snowflakeAt returns the lowest Discord ID for the given time, for paging through messages by time
+func snowflakeAt(t time.Time) string {
+	return strconv.FormatInt((t.UnixMilli()-discordEpoch)<<22, 10)
+}
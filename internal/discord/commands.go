@@ -0,0 +1,1244 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/schedule"
+	"meds-bot/internal/stats"
+	"meds-bot/internal/telemetry"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// commandName is the top-level slash command all bot commands are grouped under
+const commandName = "meds"
+
+// maxShiftPlanSteps is the number of days of a schedule shift shown when it is saved
+const maxShiftPlanSteps = 30
+
+// minPills is the minimum value of the pills option, where -1 stops tracking stock
+var minPills = float64(db.UntrackedPills)
+
+// minRefillDoses is the minimum number of doses a refill adds
+var minRefillDoses = float64(1)
+
+// subcommand is a /meds subcommand and its handler
+type subcommand struct {
+	Option  *discordgo.ApplicationCommandOption
+	Handler func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption)
+	// Cooldown limits how often each user and guild can run the subcommand successfully, for subcommands
+	// that read a lot from the database or send a lot to Discord
+	Cooldown bool
+}
+
+// subcommands returns all /meds subcommands
+func (c *Client) subcommands() []subcommand {
+	return []subcommand{
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "info",
+				Description: "Show the details recorded for a medication",
+				Options: []*discordgo.ApplicationCommandOption{
+					c.medicationOption(),
+				},
+			},
+			Handler: c.handleInfoCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "update",
+				Description: "Update the details recorded for a medication",
+				Options: []*discordgo.ApplicationCommandOption{
+					c.medicationOption(),
+					stringOption("dose", "Dose, e.g. 500mg"),
+					stringOption("instructions", "How to take it, e.g. with food"),
+					stringOption("prescriber", "Prescribing doctor"),
+					stringOption("pharmacy", "Dispensing pharmacy"),
+					stringOption("start_date", "Date you started taking it (YYYY-MM-DD)"),
+					stringOption("refill_status", "Refill status, e.g. 2 repeats left"),
+					stringOption("leaflet_url", "Link to the patient information leaflet"),
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "pills",
+						Description: "Number of doses remaining, or -1 to stop tracking stock",
+						MinValue:    &minPills,
+					},
+				},
+			},
+			Handler: c.handleUpdateCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "contact",
+				Description: "Add or update a prescriber or pharmacy contact",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "type",
+						Description: "Type of contact",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "Prescriber", Value: db.ContactPrescriber},
+							{Name: "Pharmacy", Value: db.ContactPharmacy},
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "name",
+						Description: "Name, matching the prescriber or pharmacy in medication details",
+						Required:    true,
+					},
+					stringOption("phone", "Phone number"),
+					stringOption("email", "Email address"),
+					stringOption("address", "Address"),
+				},
+			},
+			Handler: c.handleContactCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "contacts",
+				Description: "List prescriber and pharmacy contacts",
+			},
+			Handler: c.handleContactsCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "refilldue",
+				Description: "Set the date a medication needs refilling by",
+				Options: []*discordgo.ApplicationCommandOption{
+					c.medicationOption(),
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "date",
+						Description: "Refill due date (YYYY-MM-DD)",
+						Required:    true,
+					},
+				},
+			},
+			Handler: c.handleRefillDueCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "refilled",
+				Description: "Mark a medication as refilled, stopping refill reminders",
+				Options: []*discordgo.ApplicationCommandOption{
+					c.medicationOption(),
+					stringOption("next_due", "Next refill due date (YYYY-MM-DD)"),
+					{
+						Type:        discordgo.ApplicationCommandOptionNumber,
+						Name:        "cost",
+						Description: "Full cost of the refill",
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionNumber,
+						Name:        "copay",
+						Description: "Amount you paid (copay)",
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "count",
+						Description: "Number of doses picked up, added to the medication's stock",
+						MinValue:    &minRefillDoses,
+					},
+				},
+			},
+			Handler: c.handleRefilledCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "log",
+				Description: "Log a dose of an as-needed medication",
+				Options: []*discordgo.ApplicationCommandOption{
+					c.medicationOption(),
+				},
+			},
+			Handler: c.handleLogCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "photo",
+				Description: "Add a photo of today's dose of a medication, recording it as taken",
+				Options: []*discordgo.ApplicationCommandOption{
+					c.doseOption(),
+					{
+						Type:        discordgo.ApplicationCommandOptionAttachment,
+						Name:        "photo",
+						Description: "Photo of the dose",
+						Required:    true,
+					},
+				},
+			},
+			Handler: c.handlePhotoCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "redact",
+				Description: "Remove the photo of a dose of a medication, keeping the record of the dose",
+				Options: []*discordgo.ApplicationCommandOption{
+					c.doseOption(),
+					stringOption("date", "Date of the dose (YYYY-MM-DD), today by default"),
+				},
+			},
+			Handler: c.handleRedactCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "trip",
+				Description: "Follow another timezone's clock for the dates of a trip",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "timezone",
+						Description: "Destination timezone, e.g. Asia/Tokyo",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "start",
+						Description: "First day of the trip (YYYY-MM-DD)",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "end",
+						Description: "Last day of the trip (YYYY-MM-DD)",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "shift_hours",
+						Description: "Shift dose times gradually by this many hours a day",
+					},
+				},
+			},
+			Handler: c.handleTripCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "tripcancel",
+				Description: "Cancel the current trip and return to the home timezone",
+			},
+			Handler: c.handleTripCancelCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "shift",
+				Description: "Gradually move a medication's time by a number of minutes a day",
+				Options: []*discordgo.ApplicationCommandOption{
+					c.doseOption(),
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "target",
+						Description: "Time to move the medication to (HH:MM)",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "step_minutes",
+						Description: "Minutes to move the medication each day",
+						Required:    true,
+					},
+					stringOption("start", "First day at a shifted time (YYYY-MM-DD), defaults to tomorrow"),
+				},
+			},
+			Handler: c.handleShiftCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "shiftcancel",
+				Description: "Cancel a medication's time shift, returning it to its configured time",
+				Options: []*discordgo.ApplicationCommandOption{
+					c.doseOption(),
+				},
+			},
+			Handler: c.handleShiftCancelCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "awake",
+				Description: "Check in when you wake up, scheduling today's wake-anchored medications from now",
+				Options: []*discordgo.ApplicationCommandOption{
+					stringOption("time", "Time you woke up today (HH:MM), defaults to now"),
+				},
+			},
+			Handler: c.handleAwakeCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "accessibility",
+				Description: "Use simplified reminders with plain wording and large buttons",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Name:        "enabled",
+						Description: "Whether to use accessible reminders",
+						Required:    true,
+					},
+				},
+			},
+			Handler: c.handleAccessibilityCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "costs",
+				Description: "Summarise refill costs for a year",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "year",
+						Description: "Year to summarise (defaults to this year)",
+					},
+				},
+			},
+			Handler:  c.handleCostsCommand,
+			Cooldown: true,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "status",
+				Description: "Show today's doses and when each medication runs out",
+			},
+			Handler:  c.handleStatusCommand,
+			Cooldown: true,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "stats",
+				Description: "Show each medication's adherence, streak and missed days",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "days",
+						Description: "Days to look back (defaults to 30)",
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "30 days", Value: 30},
+							{Name: "90 days", Value: 90},
+						},
+					},
+				},
+			},
+			Handler:  c.handleStatsCommand,
+			Cooldown: true,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "missed",
+				Description: "List doses recorded as missed, with the reminders sent for them",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "days",
+						Description: "Days to look back (defaults to 7)",
+						MinValue:    &minMissedDays,
+						MaxValue:    maxMissedDays,
+					},
+				},
+			},
+			Handler:  c.handleMissedCommand,
+			Cooldown: true,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "delivery",
+				Description: "Show how reliably reminders were delivered, and whether missed doses were reminded about",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "days",
+						Description: "Days to look back (defaults to 30)",
+						MinValue:    &minMissedDays,
+						MaxValue:    maxMissedDays,
+					},
+				},
+			},
+			Handler:  c.handleDeliveryCommand,
+			Cooldown: true,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "diagnose",
+				Description: "Check the bot's permissions and configuration, and how to fix any problems",
+			},
+			Handler:  c.handleDiagnoseCommand,
+			Cooldown: true,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "labtest",
+				Description: "Add or update a recurring lab test linked to a medication",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "test",
+						Description: "Lab test name, e.g. INR",
+						Required:    true,
+					},
+					c.medicationChoiceOption("medication", "Medication the test monitors"),
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "interval_days",
+						Description: "Days between tests",
+						Required:    true,
+					},
+					stringOption("next_due", "Date the next test is due (YYYY-MM-DD, defaults to today)"),
+					stringOption("unit", "Unit of the result, e.g. mmol/L"),
+				},
+			},
+			Handler: c.handleLabTestCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "labresult",
+				Description: "Record a lab test result",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "test",
+						Description: "Lab test name",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionNumber,
+						Name:        "value",
+						Description: "Result value",
+						Required:    true,
+					},
+				},
+			},
+			Handler: c.handleLabResultCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "labchart",
+				Description: "Chart a lab test's results over time",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "test",
+						Description: "Lab test name",
+						Required:    true,
+					},
+				},
+			},
+			Handler:  c.handleLabChartCommand,
+			Cooldown: true,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "loglevel",
+				Description: "Change how much the bot logs, without restarting it",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "level",
+						Description: "Minimum level logged",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "Debug", Value: "debug"},
+							{Name: "Info", Value: "info"},
+							{Name: "Warn", Value: "warn"},
+							{Name: "Error", Value: "error"},
+						},
+					},
+					stringOption("component", "Only change the level of this component, e.g. discord, reminder or db"),
+				},
+			},
+			Handler: c.handleLogLevelCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "maintenance",
+				Description: "Pause all reminders while the bot is moved or maintained",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "mode",
+						Description: "Turn maintenance mode on or off",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "On", Value: "on"},
+							{Name: "Off", Value: "off"},
+						},
+					},
+				},
+			},
+			Handler: c.handleMaintenanceCommand,
+		},
+		{
+			Option: &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "feedback",
+				Description: "Report a problem or suggest an improvement to whoever runs the bot",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "text",
+						Description: "Your feedback",
+						Required:    true,
+						MaxLength:   maxFeedbackLength,
+					},
+				},
+			},
+			Handler:  c.handleFeedbackCommand,
+			Cooldown: true,
+		},
+	}
+}
+
+// RegisterCommands registers the /meds slash command with Discord.
+// Commands are registered to the guild of the configured channel so they are available immediately.
+func (c *Client) RegisterCommands(ctx context.Context) error {
+	guildID := ""
+	channel, err := c.session.Load().Channel(c.channelID)
+	if err != nil {
+		log.Printf("Error looking up channel %s, registering commands globally: %v", c.channelID, err)
+	} else {
+		guildID = channel.GuildID
+	}
+
+	var options []*discordgo.ApplicationCommandOption
+	for _, sub := range c.subcommands() {
+		options = append(options, sub.Option)
+	}
+
+	_, err = c.session.Load().ApplicationCommandCreate(c.session.Load().State.User.ID, guildID, &discordgo.ApplicationCommand{
+		Name:        commandName,
+		Description: "Medication reminder commands",
+		Options:     options,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register commands: %w", err)
+	}
+
+	c.addHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		if i.Type == discordgo.InteractionApplicationCommand {
+			c.handleCommand(ctx, s, i)
+		}
+	})
+
+	return nil
+}
+
+// handleCommand dispatches a /meds slash command to its subcommand handler
+func (c *Client) handleCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	if data.Name != commandName || len(data.Options) == 0 {
+		return
+	}
+
+	invoked := data.Options[0]
+	options := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(invoked.Options))
+	for _, option := range invoked.Options {
+		options[option.Name] = option
+	}
+
+	for _, sub := range c.subcommands() {
+		if sub.Option.Name == invoked.Name {
+			telemetry.Default.Command(invoked.Name)
+			if c.fromUserToPing(i) {
+				c.noteActivity(ctx, time.Now())
+			}
+			ctx, err := c.withGuildStore(ctx, s, i)
+			if err != nil {
+				c.respondWithFailure(s, i, "Error finding this server's data", err)
+				return
+			}
+			if sub.Cooldown {
+				c.runWithCooldown(s, i, invoked.Name, func() { sub.Handler(ctx, s, i, options) })
+				return
+			}
+			sub.Handler(ctx, s, i, options)
+			return
+		}
+	}
+
+	log.Printf("Warning: No handler found for command: /%s %s", data.Name, invoked.Name)
+}
+
+// handleInfoCommand shows the details recorded for a medication
+func (c *Client) handleInfoCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	name := options["name"].StringValue()
+	if !c.hasBaseMedication(name) {
+		c.respondWithError(s, i, fmt.Sprintf("Unknown medication: %s", name))
+		return
+	}
+
+	info, err := c.storeFor(ctx).GetMedicationInfo(ctx, name)
+	if err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error getting medication info for %s", name), err)
+		return
+	}
+
+	prescriber, err := c.contactSummary(ctx, db.ContactPrescriber, info.Prescriber)
+	if err != nil {
+		log.Printf("Error getting prescriber contact for %s: %v", name, err)
+	}
+
+	pharmacy, err := c.contactSummary(ctx, db.ContactPharmacy, info.Pharmacy)
+	if err != nil {
+		log.Printf("Error getting pharmacy contact for %s: %v", name, err)
+	}
+
+	refillStatus := info.RefillStatus
+	if line := c.refillContactLine(ctx, info); line != "" {
+		if refillStatus != "" {
+			refillStatus += "\n"
+		}
+		refillStatus += line
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("💊 %s", name),
+		URL:   info.LeafletURL,
+		Fields: []*discordgo.MessageEmbedField{
+			embedField("Dose", info.Dose),
+			embedField("Instructions", info.Instructions),
+			embedField("Prescriber", prescriber),
+			embedField("Pharmacy", pharmacy),
+			embedField("Start date", info.StartDate),
+			embedField("Refill status", refillStatus),
+			embedField("Refill due", info.RefillDue),
+		},
+	}
+	if info.PillsRemaining != db.UntrackedPills {
+		forecast := stats.ForecastStock(c.medicationByName(name), info.PillsRemaining, time.Now().In(c.location))
+		embed.Fields = append(embed.Fields, embedField("Stock", fmt.Sprintf("%d doses left\n%s", info.PillsRemaining, forecast.Warning())))
+	}
+	if info.LeafletURL != "" {
+		embed.Fields = append(embed.Fields, embedField("Leaflet", info.LeafletURL))
+	}
+
+	c.respondWithEmbed(s, i, embed)
+}
+
+// handleUpdateCommand updates the details recorded for a medication, leaving omitted fields unchanged
+func (c *Client) handleUpdateCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	name := options["name"].StringValue()
+	if !c.hasBaseMedication(name) {
+		c.respondWithError(s, i, fmt.Sprintf("Unknown medication: %s", name))
+		return
+	}
+
+	info, err := c.storeFor(ctx).GetMedicationInfo(ctx, name)
+	if err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error getting medication info for %s", name), err)
+		return
+	}
+
+	fields := map[string]*string{
+		"dose":          &info.Dose,
+		"instructions":  &info.Instructions,
+		"prescriber":    &info.Prescriber,
+		"pharmacy":      &info.Pharmacy,
+		"start_date":    &info.StartDate,
+		"refill_status": &info.RefillStatus,
+		"leaflet_url":   &info.LeafletURL,
+	}
+	for key, field := range fields {
+		if option, ok := options[key]; ok {
+			*field = option.StringValue()
+		}
+	}
+	if option, ok := options["pills"]; ok {
+		info.PillsRemaining = int(option.IntValue())
+	}
+
+	if err := validateMedicationInfo(info); err != nil {
+		c.respondWithError(s, i, err.Error())
+		return
+	}
+
+	if err := c.storeFor(ctx).SaveMedicationInfo(ctx, info); err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error saving medication info for %s", name), err)
+		return
+	}
+
+	c.respond(s, i, fmt.Sprintf("Updated the details for %s.", name))
+}
+
+// handleRefillDueCommand sets the date a medication needs refilling by
+func (c *Client) handleRefillDueCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	name := options["name"].StringValue()
+	if !c.hasBaseMedication(name) {
+		c.respondWithError(s, i, fmt.Sprintf("Unknown medication: %s", name))
+		return
+	}
+
+	date := options["date"].StringValue()
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		c.respondWithError(s, i, "Refill due date must be in the format YYYY-MM-DD")
+		return
+	}
+
+	info, err := c.storeFor(ctx).GetMedicationInfo(ctx, name)
+	if err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error getting medication info for %s", name), err)
+		return
+	}
+
+	info.RefillDue = date
+	info.RefillRemindedOn = ""
+	if err := c.storeFor(ctx).SaveMedicationInfo(ctx, info); err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error saving refill due date for %s", name), err)
+		return
+	}
+
+	c.respond(s, i, fmt.Sprintf("%s refill is due on %s. I'll remind you beforehand.", name, date))
+}
+
+// handleRefilledCommand marks a medication as refilled, optionally setting the next due date and adding
+// the doses picked up to its stock
+func (c *Client) handleRefilledCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	name := options["name"].StringValue()
+	if !c.hasBaseMedication(name) {
+		c.respondWithError(s, i, fmt.Sprintf("Unknown medication: %s", name))
+		return
+	}
+
+	nextDue := ""
+	if option, ok := options["next_due"]; ok {
+		nextDue = option.StringValue()
+		if _, err := time.Parse("2006-01-02", nextDue); err != nil {
+			c.respondWithError(s, i, "Next refill due date must be in the format YYYY-MM-DD")
+			return
+		}
+	}
+
+	var costCents, copayCents int64
+	if option, ok := options["cost"]; ok {
+		costCents = int64(math.Round(option.FloatValue() * 100))
+	}
+	if option, ok := options["copay"]; ok {
+		copayCents = int64(math.Round(option.FloatValue() * 100))
+	}
+	if costCents < 0 || copayCents < 0 {
+		c.respondWithError(s, i, "Cost and copay must not be negative")
+		return
+	}
+
+	count := 0
+	if option, ok := options["count"]; ok {
+		count = int(option.IntValue())
+		if count < 1 {
+			c.respondWithError(s, i, "Count must be at least 1")
+			return
+		}
+	}
+
+	if err := c.markRefilled(ctx, name, nextDue, costCents, copayCents); err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error marking %s as refilled", name), err)
+		return
+	}
+
+	content := fmt.Sprintf("Marked %s as refilled.", name)
+	if count > 0 {
+		remaining, err := c.storeFor(ctx).RestockPills(ctx, name, count)
+		if err != nil {
+			c.respondWithFailure(s, i, fmt.Sprintf("Error adding doses of %s", name), err)
+			return
+		}
+		content += fmt.Sprintf(" Added %s, so you have %s left.", doseCount(count), doseCount(remaining))
+	}
+	if nextDue != "" {
+		content += fmt.Sprintf(" The next refill is due on %s.", nextDue)
+	}
+	c.respond(s, i, content)
+}
+
+// handleCostsCommand summarises refill costs and copays per medication for a year
+func (c *Client) handleCostsCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	year := time.Now().In(c.location).Year()
+	if option, ok := options["year"]; ok {
+		year = int(option.IntValue())
+	}
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, c.location)
+	to := time.Date(year, time.December, 31, 0, 0, 0, 0, c.location)
+
+	refills, err := c.storeFor(ctx).GetRefills(ctx, from, to)
+	if err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error getting refills for %d", year), err)
+		return
+	}
+
+	if len(refills) == 0 {
+		c.respond(s, i, fmt.Sprintf("No refills were recorded in %d.", year))
+		return
+	}
+
+	type costSummary struct {
+		count      int
+		costCents  int64
+		copayCents int64
+	}
+
+	var names []string
+	summaries := make(map[string]*costSummary)
+	var totalCost, totalCopay int64
+	for _, refill := range refills {
+		summary, ok := summaries[refill.Medication]
+		if !ok {
+			summary = &costSummary{}
+			summaries[refill.Medication] = summary
+			names = append(names, refill.Medication)
+		}
+		summary.count++
+		summary.costCents += refill.CostCents
+		summary.copayCents += refill.CopayCents
+		totalCost += refill.CostCents
+		totalCopay += refill.CopayCents
+	}
+	sort.Strings(names)
+
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("💰 Refill Costs %d", year),
+	}
+	for _, name := range names {
+		summary := summaries[name]
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  name,
+			Value: fmt.Sprintf("%d refills\nCost: %s\nCopay: %s", summary.count, formatCents(summary.costCents), formatCents(summary.copayCents)),
+		})
+	}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:  "Total",
+		Value: fmt.Sprintf("%d refills\nCost: %s\nCopay: %s", len(refills), formatCents(totalCost), formatCents(totalCopay)),
+	})
+
+	c.respondWithEmbed(s, i, embed)
+}
+
+// handleTripCommand saves a trip, moving reminders to the destination timezone for its dates
+func (c *Client) handleTripCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	trip := &schedule.Trip{
+		Timezone: options["timezone"].StringValue(),
+		Start:    options["start"].StringValue(),
+		End:      options["end"].StringValue(),
+	}
+	if opt, ok := options["shift_hours"]; ok {
+		trip.ShiftHoursPerDay = int(opt.IntValue())
+	}
+
+	if err := trip.Validate(); err != nil {
+		c.respond(s, i, fmt.Sprintf("Invalid trip: %v", err))
+		return
+	}
+
+	if err := schedule.SaveTrip(ctx, c.storeFor(ctx), trip); err != nil {
+		log.Printf("Error saving trip: %v", err)
+		c.respond(s, i, "Failed to save trip")
+		return
+	}
+
+	content := fmt.Sprintf("✈️ Reminders will follow %s time from %s to %s", trip.Timezone, trip.Start, trip.End)
+	if trip.ShiftHoursPerDay > 0 {
+		content += fmt.Sprintf(", shifting %d hour(s) a day", trip.ShiftHoursPerDay)
+	}
+	c.respond(s, i, content+".")
+}
+
+// handleTripCancelCommand clears the current trip
+func (c *Client) handleTripCancelCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	if err := schedule.ClearTrip(ctx, c.storeFor(ctx)); err != nil {
+		log.Printf("Error clearing trip: %v", err)
+		c.respond(s, i, "Failed to cancel trip")
+		return
+	}
+
+	c.respond(s, i, "🏠 Trip cancelled, reminders are back on home time.")
+}
+
+// handleShiftCommand saves a gradual time shift for a medication and shows the intermediate schedule
+func (c *Client) handleShiftCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	name := options["name"].StringValue()
+	medication := c.medicationByName(name)
+
+	target, err := schedule.ParseTime(options["target"].StringValue())
+	if err != nil {
+		c.respond(s, i, fmt.Sprintf("Invalid target: %v", err))
+		return
+	}
+
+	// Continue an existing shift from wherever it has reached today
+	from := medication.Hour*60 + medication.Minute
+	existing, err := schedule.LoadShift(ctx, c.storeFor(ctx), name)
+	if err != nil {
+		log.Printf("Error loading schedule shift for %s: %v", name, err)
+		c.respond(s, i, "Failed to load the current schedule")
+		return
+	}
+	if existing != nil {
+		from = existing.TimeOn(time.Now().In(c.location))
+	}
+
+	shift := &schedule.Shift{
+		Medication: name,
+		From:       from,
+		To:         target,
+		StepMins:   int(options["step_minutes"].IntValue()),
+		StartDate:  time.Now().In(c.location).AddDate(0, 0, 1).Format("2006-01-02"),
+	}
+	if opt, ok := options["start"]; ok {
+		shift.StartDate = opt.StringValue()
+	}
+
+	if err := shift.Validate(); err != nil {
+		c.respond(s, i, fmt.Sprintf("Invalid shift: %v", err))
+		return
+	}
+
+	if err := schedule.SaveShift(ctx, c.storeFor(ctx), shift); err != nil {
+		log.Printf("Error saving schedule shift for %s: %v", name, err)
+		c.respond(s, i, "Failed to save schedule shift")
+		return
+	}
+
+	var plan strings.Builder
+	plan.WriteString(fmt.Sprintf("🕰️ Moving %s from %s to %s:\n", name, schedule.FormatTime(shift.From), schedule.FormatTime(shift.To)))
+	steps := shift.Plan()
+	for n, step := range steps {
+		// Keep long plans within Discord's message length limit
+		if n == maxShiftPlanSteps {
+			plan.WriteString(fmt.Sprintf("- …and %d more days\n", len(steps)-n))
+			break
+		}
+		plan.WriteString(fmt.Sprintf("- %s: %s\n", step.Date, schedule.FormatTime(step.Time)))
+	}
+	c.respond(s, i, strings.TrimSuffix(plan.String(), "\n"))
+}
+
+// handleShiftCancelCommand clears a medication's time shift
+func (c *Client) handleShiftCancelCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	name := options["name"].StringValue()
+
+	if err := schedule.ClearShift(ctx, c.storeFor(ctx), name); err != nil {
+		log.Printf("Error clearing schedule shift for %s: %v", name, err)
+		c.respond(s, i, "Failed to cancel schedule shift")
+		return
+	}
+
+	c.respond(s, i, fmt.Sprintf("%s is back on its configured time.", name))
+}
+
+// handleAwakeCommand saves today's wake check-in and shows the times of the medications anchored to it
+func (c *Client) handleAwakeCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	now := time.Now().In(c.location)
+	wake := now
+	if opt, ok := options["time"]; ok {
+		minutes, err := schedule.ParseTime(opt.StringValue())
+		if err != nil {
+			c.respond(s, i, fmt.Sprintf("Invalid time: %v", err))
+			return
+		}
+		wake = time.Date(now.Year(), now.Month(), now.Day(), minutes/60, minutes%60, 0, 0, c.location)
+		if wake.After(now) {
+			c.respond(s, i, "Invalid time: you can't check in for later today")
+			return
+		}
+	}
+
+	if err := schedule.SaveWake(ctx, c.storeFor(ctx), wake); err != nil {
+		c.respondWithFailure(s, i, "Error saving wake check-in", err)
+		return
+	}
+	// Checking in counts as being up whoever does it, so held morning reminders are sent
+	c.noteActivity(ctx, now)
+
+	var scheduled []string
+	for _, medication := range c.medications {
+		if medication.Anchor != config.AnchorWake {
+			continue
+		}
+		due := wake.Add(time.Duration(medication.AnchorOffsetMins) * time.Minute)
+		if due.YearDay() != wake.YearDay() {
+			// Doses that fall after midnight are due then, and today's keeps its configured time
+			scheduled = append(scheduled, fmt.Sprintf("- %s: %s tomorrow (and %s today, its usual time)", medication.Name, due.Format("15:04"), medication.Clock()))
+			continue
+		}
+		scheduled = append(scheduled, fmt.Sprintf("- %s: %s", medication.Name, due.Format("15:04")))
+	}
+
+	content := fmt.Sprintf("☀️ Awake at %s.", wake.Format("15:04"))
+	if len(scheduled) == 0 {
+		c.respond(s, i, content+" No medications are scheduled from waking up.")
+		return
+	}
+	c.respond(s, i, content+" Today's schedule:\n"+strings.Join(scheduled, "\n"))
+}
+
+// handleContactCommand adds or updates a prescriber or pharmacy contact, leaving omitted fields unchanged
+func (c *Client) handleContactCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	kind := options["type"].StringValue()
+	name := strings.TrimSpace(options["name"].StringValue())
+	if name == "" {
+		c.respondWithError(s, i, "Contact name is required")
+		return
+	}
+
+	contact, err := c.storeFor(ctx).GetContact(ctx, kind, name)
+	if err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error getting contact %s", name), err)
+		return
+	}
+	if contact == nil {
+		contact = &db.Contact{Kind: kind, Name: name}
+	}
+
+	if option, ok := options["phone"]; ok {
+		contact.Phone = option.StringValue()
+	}
+	if option, ok := options["email"]; ok {
+		contact.Email = option.StringValue()
+	}
+	if option, ok := options["address"]; ok {
+		contact.Address = option.StringValue()
+	}
+
+	if err := c.storeFor(ctx).SaveContact(ctx, contact); err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error saving contact %s", name), err)
+		return
+	}
+
+	c.respond(s, i, fmt.Sprintf("Saved %s contact %s.", kind, contact.Name))
+}
+
+// handleContactsCommand lists all prescriber and pharmacy contacts
+func (c *Client) handleContactsCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	contacts, err := c.storeFor(ctx).ListContacts(ctx)
+	if err != nil {
+		c.respondWithFailure(s, i, "Error listing contacts", err)
+		return
+	}
+
+	if len(contacts) == 0 {
+		c.respond(s, i, "No contacts have been recorded yet. Add one with `/meds contact`.")
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{Title: "📇 Contacts"}
+	for _, contact := range contacts {
+		var details []string
+		for _, detail := range []string{contact.Phone, contact.Email, contact.Address} {
+			if detail != "" {
+				details = append(details, detail)
+			}
+		}
+
+		field := embedField(fmt.Sprintf("%s (%s)", contact.Name, contact.Kind), strings.Join(details, "\n"))
+		field.Inline = false
+		embed.Fields = append(embed.Fields, field)
+	}
+
+	c.respondWithEmbed(s, i, embed)
+}
+
+// contactSummary returns a contact's name with its phone number if one is recorded
+func (c *Client) contactSummary(ctx context.Context, kind, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+
+	contact, err := c.storeFor(ctx).GetContact(ctx, kind, name)
+	if err != nil {
+		return name, err
+	}
+	if contact == nil || contact.Phone == "" {
+		return name, nil
+	}
+
+	return fmt.Sprintf("%s (%s)", name, contact.Phone), nil
+}
+
+// refillContactLine returns a prompt to contact the pharmacy for a refill, e.g. "Call CityPharm on 555-1234",
+// or an empty string if no pharmacy contact is recorded
+func (c *Client) refillContactLine(ctx context.Context, info *db.MedicationInfo) string {
+	if info.Pharmacy == "" {
+		return ""
+	}
+
+	contact, err := c.storeFor(ctx).GetContact(ctx, db.ContactPharmacy, info.Pharmacy)
+	if err != nil {
+		log.Printf("Error getting pharmacy contact for %s: %v", info.Name, err)
+		return ""
+	}
+	if contact == nil {
+		return ""
+	}
+
+	switch {
+	case contact.Phone != "":
+		return fmt.Sprintf("Call %s on %s", contact.Name, contact.Phone)
+	case contact.Email != "":
+		return fmt.Sprintf("Email %s at %s", contact.Name, contact.Email)
+	default:
+		return ""
+	}
+}
+
+// validateMedicationInfo checks that dates and links in a medication's details are well formed
+func validateMedicationInfo(info *db.MedicationInfo) error {
+	if info.StartDate != "" {
+		if _, err := time.Parse("2006-01-02", info.StartDate); err != nil {
+			return fmt.Errorf("start date must be in the format YYYY-MM-DD")
+		}
+	}
+
+	if info.LeafletURL != "" {
+		u, err := url.Parse(info.LeafletURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("leaflet URL must be an http or https link")
+		}
+	}
+
+	return nil
+}
+
+// medicationOption returns a required option for choosing one of the configured medications
+func (c *Client) medicationOption() *discordgo.ApplicationCommandOption {
+	return c.medicationChoiceOption("name", "Medication name")
+}
+
+// doseOption returns a required option for choosing one of the configured medications, with a choice
+// for each dose of those taken more than once a day
+func (c *Client) doseOption() *discordgo.ApplicationCommandOption {
+	option := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "name",
+		Description: "Medication name",
+		Required:    true,
+	}
+
+	// Discord allows up to 25 choices per option
+	for _, medication := range c.medications {
+		if len(option.Choices) == 25 {
+			break
+		}
+		option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  medication.Name,
+			Value: medication.Name,
+		})
+	}
+
+	return option
+}
+
+// medicationChoiceOption returns a required option with the given name for choosing one of the configured medications
+func (c *Client) medicationChoiceOption(name, description string) *discordgo.ApplicationCommandOption {
+	option := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        name,
+		Description: description,
+		Required:    true,
+	}
+
+	// Discord allows up to 25 choices per option, and the doses of a medication taken more than once
+	// a day are one choice
+	var names []string
+	for _, medication := range c.medications {
+		if !slices.Contains(names, medication.Base()) {
+			names = append(names, medication.Base())
+		}
+	}
+	for _, name := range names {
+		if len(option.Choices) == 25 {
+			break
+		}
+		option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: name,
+		})
+	}
+
+	return option
+}
+
+// stringOption returns an optional string option
+func stringOption(name, description string) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        name,
+		Description: description,
+	}
+}
+
+// embedField returns an inline embed field, showing a placeholder for empty values
+func embedField(name, value string) *discordgo.MessageEmbedField {
+	return &discordgo.MessageEmbedField{
+		Name:   name,
+		Value:  orPlaceholder(value),
+		Inline: true,
+	}
+}
+
+// orPlaceholder returns a placeholder for empty values, which Discord doesn't allow in embed fields
+func orPlaceholder(value string) string {
+	if value == "" {
+		return "—"
+	}
+	return value
+}
+
+// respond responds to an interaction with an ephemeral message
+func (c *Client) respond(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+// respondWithEmbed responds to an interaction with an ephemeral embed
+func (c *Client) respondWithEmbed(s *discordgo.Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding with embed: %v", err)
+	}
+}
@@ -0,0 +1,339 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/events"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// confirmAction is the custom ID action of the button confirming a dose that needs more than one
+// confirmation. It's kept from when only guardians confirmed doses, so buttons already sent still work.
+const confirmAction = "guard"
+
+// pendingStateKey returns the bot state key a medication's dose awaiting confirmation is kept under
+func pendingStateKey(medicationName string) string {
+	return "pending_confirmation:" + medicationName
+}
+
+// errConfirmationChanged is returned when a dose's confirmations were changed by someone else after they
+// were read
+var errConfirmationChanged = errors.New("the confirmations were changed by someone else")
+
+// pendingDose is today's dose of a medication waiting for more confirmations before it's recorded
+type pendingDose struct {
+	// messageID is the message asking for the remaining confirmations
+	messageID string
+	// confirmedBy are the users who have confirmed the dose, in order
+	confirmedBy []string
+}
+
+// pendingConfirmation returns today's dose of a medication waiting for confirmations, or nil if it
+// isn't waiting for any, along with the state it was read from for saving changes to it
+func (c *Client) pendingConfirmation(ctx context.Context, medicationName string) (*pendingDose, string, error) {
+	value, err := c.storeFor(ctx).GetState(ctx, pendingStateKey(medicationName))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get pending confirmation: %w", err)
+	}
+
+	// The state holds the date of the dose, the confirmation message and who has confirmed it
+	fields := strings.Fields(value)
+	if len(fields) < 2 || fields[0] != c.doseDate(medicationName) {
+		return nil, value, nil
+	}
+
+	pending := &pendingDose{messageID: fields[1]}
+	if len(fields) > 2 {
+		pending.confirmedBy = strings.Split(fields[2], ",")
+	}
+	return pending, value, nil
+}
+
+// savePendingConfirmation saves today's dose of a medication waiting for confirmations, or clears it if
+// pending is nil, returning errConfirmationChanged if the state is no longer the one it was read from
+func (c *Client) savePendingConfirmation(ctx context.Context, medicationName, previous string, pending *pendingDose) error {
+	value := ""
+	if pending != nil {
+		value = fmt.Sprintf("%s %s %s", c.doseDate(medicationName), pending.messageID, strings.Join(pending.confirmedBy, ","))
+	}
+
+	saved, err := c.storeFor(ctx).CompareAndSetState(ctx, pendingStateKey(medicationName), previous, value)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		return errConfirmationChanged
+	}
+	return nil
+}
+
+// confirmationContent describes a dose's confirmations so far and who can still confirm it
+func confirmationContent(medication config.Medication, confirmedBy []string) string {
+	var waiting []string
+	for _, userID := range medication.ConfirmingUsers() {
+		if !slices.Contains(confirmedBy, userID) {
+			waiting = append(waiting, fmt.Sprintf("<@%s>", userID))
+		}
+	}
+
+	confirmed := make([]string, len(confirmedBy))
+	for n, userID := range confirmedBy {
+		confirmed[n] = fmt.Sprintf("<@%s>", userID)
+	}
+
+	// Enough people may have confirmed it without the guardian, who always has to
+	if len(confirmedBy) >= medication.RequiredConfirmations() {
+		return fmt.Sprintf("⏳ **%s** has been confirmed by %s, and is recorded once its guardian confirms it too. Waiting for <@%s>.",
+			medication.Name, strings.Join(confirmed, ", "), medication.GuardianID)
+	}
+
+	return fmt.Sprintf("⏳ **%s** has %d of the %d confirmations it needs before it's recorded, from %s. Waiting for %s.",
+		medication.Name, len(confirmedBy), medication.RequiredConfirmations(), strings.Join(confirmed, ", "), strings.Join(waiting, " or "))
+}
+
+// requestConfirmation records the first confirmation of a dose that needs more than one, from the user
+// it's for, and asks the other confirmers to confirm it, replying to the deferred interaction
+func (c *Client) requestConfirmation(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, medication config.Medication, userID string) {
+	reminder, err := c.storeFor(ctx).GetTodayReminder(ctx, medication.Name)
+	if err != nil {
+		c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error getting reminder for %s", medication.Name), err))
+		return
+	}
+	if reminder.Acknowledged {
+		c.editDeferred(s, i, fmt.Sprintf("You've already acknowledged taking your %s today. Thank you!", medication.Name))
+		return
+	}
+
+	pending, previous, err := c.pendingConfirmation(ctx, medication.Name)
+	if err != nil {
+		c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error checking %s", medication.Name), err))
+		return
+	}
+	if pending != nil {
+		c.editDeferred(s, i, fmt.Sprintf("Your %s is already waiting to be confirmed.", medication.Name))
+		return
+	}
+
+	confirmedBy := []string{userID}
+	var others []string
+	for _, confirmer := range medication.ConfirmingUsers() {
+		if confirmer != userID {
+			others = append(others, confirmer)
+		}
+	}
+
+	content := confirmationContent(medication, confirmedBy)
+	message, err := c.session.Load().ChannelMessageSendComplex(c.channelID, &discordgo.MessageSend{
+		Content: content,
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    fmt.Sprintf("Confirm %s", medication.Name),
+						Style:    discordgo.SuccessButton,
+						CustomID: c.customID(confirmAction, medication.Name, c.doseDate(medication.Name)),
+					},
+				},
+			},
+		},
+		AllowedMentions: &discordgo.MessageAllowedMentions{Users: others},
+	})
+	if err != nil {
+		c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error asking for %s to be confirmed", medication.Name), err))
+		return
+	}
+
+	err = c.savePendingConfirmation(ctx, medication.Name, previous, &pendingDose{messageID: message.ID, confirmedBy: confirmedBy})
+	if errors.Is(err, errConfirmationChanged) {
+		// Another click asked for it to be confirmed first, so only its message is kept
+		if err := c.session.Load().ChannelMessageDelete(c.channelID, message.ID); err != nil {
+			log.Printf("Error deleting duplicate confirmation message for %s [dose %s]: %v", medication.Name, reminder.CorrelationID, err)
+		}
+		c.editDeferred(s, i, fmt.Sprintf("Your %s is already waiting to be confirmed.", medication.Name))
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving pending confirmation of %s [dose %s]: %v", medication.Name, reminder.CorrelationID, err)
+	}
+	c.publishConfirmation(ctx, medication.Name, reminder, userID)
+
+	c.editDeferred(s, i, fmt.Sprintf("Thank you! Your %s will be recorded once it's been confirmed.", medication.Name))
+}
+
+// registerConfirmHandler registers the handler for confirming a dose that needs more than one confirmation
+func (c *Client) registerConfirmHandler(ctx context.Context) {
+	c.RegisterHandler(confirmAction, func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+		medicationName := args[0]
+
+		if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+		}); err != nil {
+			log.Printf("Error sending deferred response: %v", err)
+		}
+
+		if date := c.doseDate(medicationName); len(args) < 2 || args[1] != date {
+			c.editDeferred(s, i, fmt.Sprintf("This confirmation is from an earlier day, so it can't mark today's %s as taken.", medicationName))
+			return
+		}
+
+		medication := c.medicationByName(medicationName)
+		userID := interactionUserID(i)
+		if !slices.Contains(medication.ConfirmingUsers(), userID) {
+			c.editDeferred(s, i, fmt.Sprintf("You aren't one of the people who can confirm %s.", medicationName))
+			return
+		}
+
+		// Confirmations are saved only if nobody else's was saved since they were read, and otherwise
+		// added again to the confirmations as they are now
+		for attempt := 1; ; attempt++ {
+			pending, previous, err := c.pendingConfirmation(ctx, medicationName)
+			if err != nil {
+				c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error checking %s", medicationName), err))
+				return
+			}
+			if pending == nil {
+				c.editDeferred(s, i, fmt.Sprintf("Today's %s isn't waiting to be confirmed.", medicationName))
+				return
+			}
+			if slices.Contains(pending.confirmedBy, userID) {
+				c.editDeferred(s, i, fmt.Sprintf("You've already confirmed %s. It needs someone else to confirm it too.", medicationName))
+				return
+			}
+			pending.confirmedBy = append(pending.confirmedBy, userID)
+
+			if medication.Confirmed(pending.confirmedBy) {
+				err = c.completeConfirmation(ctx, s, i, medication, previous, pending)
+			} else {
+				err = c.addConfirmation(ctx, s, i, medication, previous, pending)
+			}
+			if !errors.Is(err, errConfirmationChanged) {
+				return
+			}
+			if attempt == maxUpdateAttempts {
+				c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error saving your confirmation of %s", medicationName), err))
+				return
+			}
+			log.Printf("Debug: Confirmations of %s changed while %s confirmed it, retrying", medicationName, userID)
+		}
+	})
+}
+
+// addConfirmation records a confirmation of a dose that still needs more, showing it on the confirmation
+// message. It returns errConfirmationChanged, without replying, if the confirmations changed since they
+// were read.
+func (c *Client) addConfirmation(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, medication config.Medication, previous string, pending *pendingDose) error {
+	reminder, err := c.storeFor(ctx).GetTodayReminder(ctx, medication.Name)
+	if err != nil {
+		c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error getting reminder for %s", medication.Name), err))
+		return err
+	}
+
+	if err := c.savePendingConfirmation(ctx, medication.Name, previous, pending); err != nil {
+		if !errors.Is(err, errConfirmationChanged) {
+			c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error saving your confirmation of %s", medication.Name), err))
+		}
+		return err
+	}
+	c.publishConfirmation(ctx, medication.Name, reminder, interactionUserID(i))
+
+	content := confirmationContent(medication, pending.confirmedBy)
+	if _, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel: c.channelID,
+		ID:      pending.messageID,
+		Content: &content,
+	}); err != nil {
+		log.Printf("Error updating confirmation of %s [dose %s]: %v", medication.Name, reminder.CorrelationID, err)
+	}
+
+	if len(pending.confirmedBy) >= medication.RequiredConfirmations() {
+		c.editDeferred(s, i, fmt.Sprintf("Thank you! %s will be recorded once its guardian confirms it too.", medication.Name))
+		return nil
+	}
+	c.editDeferred(s, i, fmt.Sprintf("Thank you! %s now has %d of the %d confirmations it needs.", medication.Name, len(pending.confirmedBy), medication.RequiredConfirmations()))
+	return nil
+}
+
+// completeConfirmation records a dose as taken once it has all the confirmations it needs. The pending
+// confirmations are cleared first, so only one of the people confirming it at once records it, and it
+// returns errConfirmationChanged, without replying, if they changed since they were read.
+func (c *Client) completeConfirmation(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, medication config.Medication, previous string, pending *pendingDose) error {
+	if err := c.savePendingConfirmation(ctx, medication.Name, previous, nil); err != nil {
+		if !errors.Is(err, errConfirmationChanged) {
+			c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error saving your confirmation of %s", medication.Name), err))
+		}
+		return err
+	}
+
+	// The confirmation isn't the reminder, so the reminder's current message shows the dose was taken
+	clickedID, err := c.currentReminderMessage(ctx, medication.Name)
+	if err != nil {
+		log.Printf("Error getting reminder message for %s: %v", medication.Name, err)
+	}
+
+	// The dose is recorded against the user who took it
+	reminder, alreadyTaken, err := c.acknowledgeReminder(ctx, medication.Name, medication.UserID, pending.confirmedBy, time.Time{}, func(*db.Reminder) string {
+		return clickedID
+	})
+	if err != nil {
+		// The confirmations are put back, so they can be confirmed again
+		if err := c.savePendingConfirmation(ctx, medication.Name, "", pending); err != nil {
+			log.Printf("Error restoring pending confirmation of %s: %v", medication.Name, err)
+		}
+		c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error acknowledging %s", medication.Name), err))
+		return nil
+	}
+
+	confirmed := make([]string, len(pending.confirmedBy))
+	for n, userID := range pending.confirmedBy {
+		confirmed[n] = fmt.Sprintf("<@%s>", userID)
+	}
+	content := fmt.Sprintf("✅ **%s** was confirmed by %s.", medication.Name, strings.Join(confirmed, ", "))
+	if _, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel:    c.channelID,
+		ID:         pending.messageID,
+		Content:    &content,
+		Components: &[]discordgo.MessageComponent{},
+	}); err != nil {
+		log.Printf("Error updating confirmation of %s [dose %s]: %v", medication.Name, reminder.CorrelationID, err)
+	}
+
+	if alreadyTaken {
+		c.editDeferred(s, i, fmt.Sprintf("Today's %s was already acknowledged.", medication.Name))
+		return nil
+	}
+
+	if clickedID != "" {
+		c.resolveClickedMessage(ctx, medication.Name, reminder, clickedID)
+	}
+	// Each confirmation is in the dose event log, and the acknowledgment names everyone who gave one
+	c.publishAcknowledged(ctx, medication.Name, reminder, "Discord, confirmed by "+strings.Join(pending.confirmedBy, ", "))
+
+	c.editDeferred(s, i, fmt.Sprintf("Confirmed! Today's %s has been recorded.", medication.Name))
+	return nil
+}
+
+// publishConfirmation publishes a confirmation of a dose that isn't recorded as taken yet
+func (c *Client) publishConfirmation(ctx context.Context, medicationName string, reminder *db.Reminder, userID string) {
+	log.Printf("Dose of %s confirmed by %s, pending further confirmation [dose %s]", medicationName, userID, reminder.CorrelationID)
+
+	err := c.events.Publish(ctx, events.DosePendingConfirmation{
+		Medication:    medicationName,
+		Date:          reminder.Date,
+		ReminderID:    reminder.ID,
+		UserID:        userID,
+		CorrelationID: reminder.CorrelationID,
+	})
+	if err != nil {
+		log.Printf("Error publishing confirmation of %s [dose %s]: %v", medicationName, reminder.CorrelationID, err)
+	}
+}
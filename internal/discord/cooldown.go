@@ -0,0 +1,132 @@
+package discord
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxCooldownEntries is how many cooldowns are kept before the expired ones are cleared out
+const maxCooldownEntries = 1000
+
+// cooldowns limits how often each user and each guild can run expensive commands, so one busy server
+// can't use up the bot's shared Discord rate limits and starve the others
+type cooldowns struct {
+	mu    sync.Mutex
+	user  time.Duration
+	guild time.Duration
+	// until is when each cooldown ends, keyed by whose it is and the command
+	until map[string]time.Time
+	now   func() time.Time
+}
+
+// newCooldowns creates cooldowns for users and guilds, or returns nil if both are disabled
+func newCooldowns(user, guild time.Duration) *cooldowns {
+	if user <= 0 && guild <= 0 {
+		return nil
+	}
+	return &cooldowns{user: user, guild: guild, until: make(map[string]time.Time), now: time.Now}
+}
+
+// start starts a command's cooldowns for the user and their guild, or returns when the cooldown they're
+// in ends and whether it's the guild's. Commands in DMs only have the user's cooldown. The cooldowns are
+// held while the command runs, and released if it fails.
+func (c *cooldowns) start(command, userID, guildID string) (time.Time, bool, bool) {
+	if c == nil {
+		return time.Time{}, false, true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	userKey := "user:" + userID + ":" + command
+	guildKey := "guild:" + guildID + ":" + command
+	if until := c.until[userKey]; now.Before(until) {
+		return until, false, false
+	}
+	if until := c.until[guildKey]; guildID != "" && now.Before(until) {
+		return until, true, false
+	}
+
+	if len(c.until) >= maxCooldownEntries {
+		for key, until := range c.until {
+			if !now.Before(until) {
+				delete(c.until, key)
+			}
+		}
+	}
+	if c.user > 0 {
+		c.until[userKey] = now.Add(c.user)
+	}
+	if c.guild > 0 && guildID != "" {
+		c.until[guildKey] = now.Add(c.guild)
+	}
+	return time.Time{}, false, true
+}
+
+// release ends a command's cooldowns for the user and their guild, for a command that failed. Nobody
+// else could start the command while they were held, so they're still the ones start set.
+func (c *cooldowns) release(command, userID, guildID string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.until, "user:"+userID+":"+command)
+	if guildID != "" {
+		delete(c.until, "guild:"+guildID+":"+command)
+	}
+}
+
+// commandOutcome records whether a command running on a cooldown failed
+type commandOutcome struct {
+	failed atomic.Bool
+}
+
+// runWithCooldown runs a command unless it's cooling down for the user or their guild. Its cooldowns are
+// only kept if it succeeds, so a command that failed, such as for a mistyped name, can be run again
+// straight away.
+func (c *Client) runWithCooldown(s *discordgo.Session, i *discordgo.InteractionCreate, command string, run func()) {
+	if !c.startCooldown(s, i, command) {
+		return
+	}
+
+	outcome := &commandOutcome{}
+	c.outcomes.Store(i.ID, outcome)
+	defer c.outcomes.Delete(i.ID)
+
+	run()
+	if outcome.failed.Load() {
+		c.cooldowns.release(command, interactionUserID(i), i.GuildID)
+	}
+}
+
+// noteFailure records that the command an interaction ran failed, if it's running on a cooldown
+func (c *Client) noteFailure(i *discordgo.InteractionCreate) {
+	if outcome, ok := c.outcomes.Load(i.ID); ok {
+		outcome.(*commandOutcome).failed.Store(true)
+	}
+}
+
+// startCooldown starts a command's cooldowns, telling the user when they can run it again if it's still
+// cooling down
+func (c *Client) startCooldown(s *discordgo.Session, i *discordgo.InteractionCreate, command string) bool {
+	until, guild, ok := c.cooldowns.start(command, interactionUserID(i), i.GuildID)
+	if ok {
+		return true
+	}
+
+	again := fmt.Sprintf("<t:%d:R>", until.Add(time.Second-1).Unix())
+	message := fmt.Sprintf("⏳ You've just used `/%s %s`, so give it a moment. You can use it again %s.", commandName, command, again)
+	if guild {
+		message = fmt.Sprintf("⏳ `/%s %s` was just used in this server, so give it a moment. You can use it again %s.", commandName, command, again)
+	}
+	c.respond(s, i, message)
+	return false
+}
@@ -0,0 +1,143 @@
+package discord
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestCooldowns tests that commands are limited per user and per guild, and only in DMs per user
+func TestCooldowns(t *testing.T) {
+	type run struct {
+		after     time.Duration
+		command   string
+		userID    string
+		guildID   string
+		wantOK    bool
+		wantGuild bool
+	}
+
+	tests := []struct {
+		name  string
+		user  time.Duration
+		guild time.Duration
+		runs  []run
+	}{
+		{
+			name: "User cooldown",
+			user: time.Minute,
+			runs: []run{
+				{command: "stats", userID: "a", guildID: "g", wantOK: true},
+				{command: "stats", userID: "a", guildID: "g"},
+				{command: "stats", userID: "a", guildID: "other"},
+				{command: "status", userID: "a", guildID: "g", wantOK: true},
+				{command: "stats", userID: "b", guildID: "g", wantOK: true},
+			},
+		},
+		{
+			name:  "Guild cooldown",
+			guild: time.Minute,
+			runs: []run{
+				{command: "stats", userID: "a", guildID: "g", wantOK: true},
+				{command: "stats", userID: "b", guildID: "g", wantGuild: true},
+				{command: "stats", userID: "b", guildID: "other", wantOK: true},
+				{command: "status", userID: "b", guildID: "g", wantOK: true},
+			},
+		},
+		{
+			name:  "User cooldown is reported before the guild's",
+			user:  time.Minute,
+			guild: time.Minute,
+			runs: []run{
+				{command: "stats", userID: "a", guildID: "g", wantOK: true},
+				{command: "stats", userID: "a", guildID: "g"},
+			},
+		},
+		{
+			name:  "DMs have no guild cooldown",
+			user:  time.Minute,
+			guild: time.Hour,
+			runs: []run{
+				{command: "stats", userID: "a", wantOK: true},
+				{command: "stats", userID: "b", wantOK: true},
+				{command: "stats", userID: "a"},
+				{command: "stats", userID: "c", guildID: "g", wantOK: true},
+			},
+		},
+		{
+			name:  "Cooldowns expire",
+			user:  time.Minute,
+			guild: 2 * time.Minute,
+			runs: []run{
+				{command: "stats", userID: "a", guildID: "g", wantOK: true},
+				{after: 30 * time.Second, command: "stats", userID: "a", guildID: "g"},
+				{after: 30 * time.Second, command: "stats", userID: "a", guildID: "g", wantGuild: true},
+				{after: time.Minute, command: "stats", userID: "a", guildID: "g", wantOK: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+			c := newCooldowns(tt.user, tt.guild)
+			c.now = func() time.Time { return now }
+
+			for n, r := range tt.runs {
+				now = now.Add(r.after)
+				until, guild, ok := c.start(r.command, r.userID, r.guildID)
+				if ok != r.wantOK || guild != r.wantGuild {
+					t.Errorf("Run %d: start(%q, %q, %q) = %v, guild %v, want %v, guild %v", n+1, r.command, r.userID, r.guildID, ok, guild, r.wantOK, r.wantGuild)
+				}
+				if !ok && !until.After(now) {
+					t.Errorf("Run %d: cooldown ends at %s, want after %s", n+1, until, now)
+				}
+			}
+		})
+	}
+}
+
+// TestCooldownsRelease tests that a failed command's cooldowns are released
+func TestCooldownsRelease(t *testing.T) {
+	c := newCooldowns(time.Minute, time.Minute)
+	if _, _, ok := c.start("stats", "a", "g"); !ok {
+		t.Fatalf("First run was refused")
+	}
+	c.release("stats", "a", "g")
+	if _, _, ok := c.start("stats", "b", "g"); !ok {
+		t.Errorf("Run after a failure was refused")
+	}
+}
+
+// TestCooldownsPrune tests that expired cooldowns are cleared out once there are too many
+func TestCooldownsPrune(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	c := newCooldowns(time.Minute, 0)
+	c.now = func() time.Time { return now }
+
+	for n := range maxCooldownEntries {
+		c.start("stats", fmt.Sprint(n), "")
+	}
+	if len(c.until) != maxCooldownEntries {
+		t.Fatalf("Got %d cooldowns, want %d", len(c.until), maxCooldownEntries)
+	}
+
+	// All but one expire, and are cleared out by the next command started
+	now = now.Add(time.Minute)
+	c.until["user:0:stats"] = now.Add(time.Second)
+	c.start("stats", "new", "")
+	if len(c.until) != 2 {
+		t.Errorf("Got %d cooldowns after pruning, want the unexpired one and the new one", len(c.until))
+	}
+	if _, _, ok := c.start("stats", "0", ""); ok {
+		t.Errorf("Unexpired cooldown was cleared out")
+	}
+
+	if c := newCooldowns(0, 0); c != nil {
+		t.Errorf("newCooldowns(0, 0) = %v, want nil", c)
+	}
+	var disabled *cooldowns
+	if _, _, ok := disabled.start("stats", "a", "g"); !ok {
+		t.Errorf("Disabled cooldowns refused a command")
+	}
+}
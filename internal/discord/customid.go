@@ -0,0 +1,56 @@
+package discord
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"meds-bot/internal/componentid"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// componentIDKeyStateKey is the bot state key the custom ID signing key is kept under
+const componentIDKeyStateKey = "component_id_key"
+
+// componentHandler handles an interaction with a component, given the arguments packed in its custom ID
+type componentHandler func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string)
+
+// loadComponentIDs creates the codec for custom IDs, signing them with the configured secret. Without
+// one, a key is generated the first time the bot runs and kept in the bot state, so components sent
+// before a restart can still be used.
+func (c *Client) loadComponentIDs(ctx context.Context, secret string) error {
+	if secret != "" {
+		c.ids = componentid.NewCodec([]byte(secret))
+		return nil
+	}
+
+	value, err := c.store.GetState(ctx, componentIDKeyStateKey)
+	if err != nil {
+		return fmt.Errorf("failed to get custom ID key: %w", err)
+	}
+
+	key, err := hex.DecodeString(value)
+	if err != nil || len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+		if err := c.store.SetState(ctx, componentIDKeyStateKey, hex.EncodeToString(key)); err != nil {
+			return fmt.Errorf("failed to save custom ID key: %w", err)
+		}
+	}
+
+	c.ids = componentid.NewCodec(key)
+	return nil
+}
+
+// customID returns the signed custom ID for a component's action and arguments. Encoding only fails
+// for IDs the bot makes too long, which configuration validation rules out, so it's logged rather than returned.
+func (c *Client) customID(action string, args ...string) string {
+	id, err := c.ids.Encode(action, args...)
+	if err != nil {
+		log.Printf("Error encoding custom ID: %v", err)
+	}
+	return id
+}
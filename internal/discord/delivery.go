@@ -0,0 +1,68 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"meds-bot/internal/stats"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// defaultDeliveryDays is how many days back /meds delivery looks by default
+const defaultDeliveryDays = 30
+
+// handleDeliveryCommand reports how reliably each medication's reminders were delivered, and whether its
+// missed doses were missed because the reminders didn't arrive or despite them
+func (c *Client) handleDeliveryCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	days := defaultDeliveryDays
+	if option, ok := options["days"]; ok {
+		days = int(option.IntValue())
+	}
+
+	since := time.Now().In(c.location).AddDate(0, 0, -days)
+	doseEvents, err := c.storeFor(ctx).GetDoseEvents(ctx, "", since)
+	if err != nil {
+		c.respondWithFailure(s, i, "Error getting dose events", err)
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("📬 Reminder Delivery: Last %d Days", days),
+	}
+	for _, medication := range c.medications {
+		if medication.AsNeeded() {
+			continue
+		}
+		delivery := stats.CalculateDelivery(medication.Name, doseEvents)
+		if delivery.Doses == 0 {
+			continue
+		}
+
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  medication.Name,
+			Value: formatDelivery(delivery),
+		})
+	}
+	if len(embed.Fields) == 0 {
+		c.respond(s, i, fmt.Sprintf("No reminders were sent in the last %d days.", days))
+		return
+	}
+
+	c.respondWithEmbed(s, i, embed)
+}
+
+// formatDelivery describes a medication's reminder delivery and missed doses
+func formatDelivery(delivery stats.Delivery) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d/%d reminders delivered (%d%%)", delivery.Sent, delivery.Sent+delivery.Failed, delivery.Percent())
+	if delivery.Fallback > 0 {
+		fmt.Fprintf(&b, ", %d by a backup channel", delivery.Fallback)
+	}
+	fmt.Fprintf(&b, "\nActed on: %d/%d doses\n", delivery.Interacted, delivery.Doses)
+	fmt.Fprintf(&b, "Missed without a reminder arriving: %d\n", delivery.MissedUndelivered)
+	fmt.Fprintf(&b, "Missed despite reminders: %d", delivery.MissedIgnored)
+	return b.String()
+}
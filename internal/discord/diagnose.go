@@ -0,0 +1,127 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// diagnoseStateKey is the state written to check the database is writable
+const diagnoseStateKey = "diagnose_checked_at"
+
+// diagnosis is the result of one diagnostic check, with how to fix it if it failed
+type diagnosis struct {
+	Name string
+	OK   bool
+	Fix  string
+}
+
+// channelPermission is a permission the bot needs in the reminder channel
+type channelPermission struct {
+	Name       string
+	Permission int64
+	Reason     string
+}
+
+// channelPermissions returns the permissions the bot needs in the reminder channel
+func (c *Client) channelPermissions() []channelPermission {
+	permissions := []channelPermission{
+		{"View Channel", discordgo.PermissionViewChannel, "to see the reminder channel"},
+		{"Send Messages", discordgo.PermissionSendMessages, "to post reminders"},
+		{"Embed Links", discordgo.PermissionEmbedLinks, "to show reports, checklists and pill images"},
+		{"Read Message History", discordgo.PermissionReadMessageHistory, "to update reminders after a restart"},
+	}
+
+	if !c.minimalPerms {
+		permissions = append(permissions, channelPermission{"Manage Messages", discordgo.PermissionManageMessages, "to delete old reminders, or set DISCORD_MINIMAL_PERMISSIONS to run without it"})
+	}
+
+	if c.attachesFiles() {
+		permissions = append(permissions, channelPermission{"Attach Files", discordgo.PermissionAttachFiles, "to attach reminder sounds, pill images and QR codes"})
+	}
+
+	return permissions
+}
+
+// attachesFiles reports whether reminders may have files attached
+func (c *Client) attachesFiles() bool {
+	if c.reminderSound != "" || c.qrCodes {
+		return true
+	}
+	for _, medication := range c.medications {
+		if medication.Sound != "" || medication.Image != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// diagnose checks the bot's permissions in the reminder channel, its gateway intents and that the database is writable
+func (c *Client) diagnose(ctx context.Context, s *discordgo.Session) []diagnosis {
+	var results []diagnosis
+
+	permissions, err := s.State.UserChannelPermissions(s.State.User.ID, c.channelID)
+	if err != nil {
+		permissions, err = s.UserChannelPermissions(s.State.User.ID, c.channelID)
+	}
+	if err != nil {
+		results = append(results, diagnosis{
+			Name: "Reminder channel",
+			Fix:  fmt.Sprintf("Channel %s can't be found (%v). Check DISCORD_CHANNEL_ID and that the bot has been added to its server", c.channelID, err),
+		})
+	} else {
+		for _, required := range c.channelPermissions() {
+			results = append(results, diagnosis{
+				Name: required.Name,
+				OK:   permissions&required.Permission != 0,
+				Fix:  fmt.Sprintf("Give the bot's role the %s permission in <#%s> %s", required.Name, c.channelID, required.Reason),
+			})
+		}
+	}
+
+	results = append(results, diagnosis{
+		Name: "Guilds intent",
+		OK:   s.Identify.Intents&discordgo.IntentsGuilds != 0,
+		Fix:  "Include the Guilds intent when connecting, which is needed to look up channels and permissions",
+	})
+
+	writable := diagnosis{Name: "Database writable", OK: true}
+	if err := c.store.SetState(ctx, diagnoseStateKey, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		writable.OK = false
+		writable.Fix = fmt.Sprintf("Writing to the database failed (%v). Check DB_PATH is on a writable disk with free space", err)
+	}
+
+	return append(results, writable)
+}
+
+// handleDiagnoseCommand reports any misconfiguration found by diagnose and how to fix it
+func (c *Client) handleDiagnoseCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	results := c.diagnose(ctx, s)
+
+	var checks, fixes strings.Builder
+	for _, result := range results {
+		if result.OK {
+			checks.WriteString(fmt.Sprintf("✅ %s\n", result.Name))
+			continue
+		}
+		checks.WriteString(fmt.Sprintf("❌ %s\n", result.Name))
+		fixes.WriteString(fmt.Sprintf("• %s\n", result.Fix))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: "🩺 Diagnostics",
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Checks", Value: checks.String()},
+		},
+	}
+	if fixes.Len() > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Fixes", Value: fixes.String()})
+	} else {
+		embed.Description = "Everything looks good."
+	}
+
+	c.respondWithEmbed(s, i, embed)
+}
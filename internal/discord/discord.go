@@ -1,95 +1,331 @@
 package discord
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"meds-bot/internal/acklink"
+	"meds-bot/internal/componentid"
 	"meds-bot/internal/config"
 	"meds-bot/internal/db"
+	"meds-bot/internal/events"
+	"meds-bot/internal/logging"
+	"meds-bot/internal/stats"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/skip2/go-qrcode"
 )
 
 // ClientInterface defines the interface for Discord operations
 type ClientInterface interface {
 	Close() error
-	SendReminder(ctx context.Context, medication config.Medication) (string, error)
+	SendReminder(ctx context.Context, medication config.Medication, opts ReminderOptions) (string, error)
+	SendHeadsUp(ctx context.Context, medication config.Medication, dueAt time.Time) (string, error)
+	SendChecklist(ctx context.Context) (string, error)
+	SendRefillReminder(ctx context.Context, info *db.MedicationInfo) error
+	SendWeeklyReport(ctx context.Context, report *stats.WeeklyReport) error
+	SendLabTestReminder(ctx context.Context, test *db.LabTest) error
 	DeleteMessage(ctx context.Context, messageID string) error
 	RegisterMedicationHandler(ctx context.Context)
+	RefreshReminderButtons(ctx context.Context) error
+	RegisterCommands(ctx context.Context) error
+	Start(ctx context.Context)
+}
+
+// takenAction is the custom ID action of the button marking a medication as taken
+const takenAction = "taken"
+
+// buttonStyles maps configured button styles to Discord button styles
+var buttonStyles = map[string]discordgo.ButtonStyle{
+	"primary":   discordgo.PrimaryButton,
+	"secondary": discordgo.SecondaryButton,
+	"success":   discordgo.SuccessButton,
+	"danger":    discordgo.DangerButton,
+}
+
+// ReminderOptions controls how an individual reminder message is presented
+type ReminderOptions struct {
+	// Escalate mentions everyone in the channel in addition to the configured user
+	Escalate bool
+	// DueAt shows when the dose was due and when its reminders stop, omitted if zero
+	DueAt time.Time
 }
 
 type Client struct {
-	session       *discordgo.Session
-	channelID     string
-	userIDToPing  string
-	store         db.StoreInterface
+	session          atomic.Pointer[discordgo.Session]
+	channelID        string
+	userIDToPing     string
+	reminderMode     string
+	medications      []config.Medication
+	location         *time.Location
+	ackLinks         *acklink.Signer
+	qrCodes          bool
+	stockWarningDays int
+	reminderTemplate string
+	// acknowledgedTemplate is empty for the default wording of reminders once they're taken
+	acknowledgedTemplate string
+	reminderSound        string
+	// accessibleDefault is used for users who haven't chosen whether to use accessible reminders
+	accessibleDefault bool
+	// encouragements is nil when encouragement lines are disabled
+	encouragements *encouragements
+	store          db.StoreInterface
+	events         *events.Bus
+	// logFilter is nil when log levels can't be changed at runtime
+	logFilter         *logging.Filter
+	operatorChannelID string
+	// archiveChannelID is empty when past reminders aren't archived
+	archiveChannelID string
+	// minimalPerms edits messages that would be deleted, for servers that don't grant Manage Messages
+	minimalPerms bool
+	// intents are the gateway intents every session requests
+	intents discordgo.Intent
+	// channelLost pauses sending while the bot can't post in the reminder channel, and maintenance while
+	// an admin has turned maintenance mode on
+	accessMu      sync.Mutex
+	channelLost   bool
+	maintenance   bool
 	handlersMutex sync.Mutex
-	handlers      map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate)
+	handlers      map[string]componentHandler
+	// tokenFile is where the bot token is reloaded from when it changes, empty if it's never reloaded
+	tokenFile string
+	// sessionMu guards the token and the event handlers added to every session
+	sessionMu       sync.Mutex
+	token           string
+	sessionHandlers []any
+	// feedbackURL is the webhook feedback is forwarded to, empty if it's only recorded
+	feedbackURL string
+	// ids encodes and verifies the custom IDs of buttons, select menus and modals
+	ids *componentid.Codec
+	// activeOn is the date the user to ping was last seen active
+	activityMu sync.Mutex
+	activeOn   string
+	// presenceRouting chooses how reminders reach the user from their presence, escalating reminders for
+	// users who are away after awayEscalateAfterNags, 0 if they never escalate
+	presenceRouting       bool
+	awayEscalateAfterNags int
+	// cooldowns limits how often expensive commands are run, nil when there are no cooldowns
+	cooldowns *cooldowns
+	// outcomes are the outcomes of the commands running on a cooldown, by interaction ID
+	outcomes sync.Map
+	// logMu is held while an as-needed dose is checked against its limit and logged
+	logMu sync.Mutex
 }
 
-// NewClient creates a new Discord client
-func NewClient(ctx context.Context, cfg *config.Config, store db.StoreInterface) (*Client, error) {
-	session, err := discordgo.New("Bot " + cfg.DiscordToken)
+// NewClient creates a new Discord client that sends the messages for events published on the bus.
+// ackLinks may be nil if acknowledgment links are disabled, and logFilter if log levels can't be changed.
+func NewClient(ctx context.Context, cfg *config.Config, store db.StoreInterface, ackLinks *acklink.Signer, bus *events.Bus, logFilter *logging.Filter) (*Client, error) {
+	loc, err := cfg.GetLocation()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Discord session: %w", err)
+		return nil, fmt.Errorf("failed to get timezone location: %w", err)
 	}
 
 	client := &Client{
-		session:      session,
-		channelID:    cfg.DiscordChannelID,
-		userIDToPing: cfg.DiscordUserIDToPing,
-		store:        store,
-		handlers:     make(map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate)),
+		channelID:            cfg.DiscordChannelID,
+		userIDToPing:         cfg.DiscordUserIDToPing,
+		reminderMode:         cfg.ReminderMode,
+		medications:          cfg.Medications,
+		location:             loc,
+		ackLinks:             ackLinks,
+		qrCodes:              cfg.ReminderQRCode && ackLinks != nil,
+		stockWarningDays:     cfg.StockWarningDays,
+		reminderTemplate:     cfg.ReminderTemplate,
+		acknowledgedTemplate: cfg.AcknowledgedTemplate,
+		reminderSound:        cfg.ReminderSound,
+		accessibleDefault:    cfg.AccessibleReminders,
+		store:                store,
+		events:               bus,
+		logFilter:            logFilter,
+		operatorChannelID:    cfg.OperatorChannelID,
+		archiveChannelID:     cfg.ArchiveChannelID,
+		minimalPerms:         cfg.MinimalPermissions,
+		handlers:             make(map[string]componentHandler),
+		tokenFile:            cfg.DiscordTokenFile,
+		token:                cfg.DiscordToken,
+		intents:              gatewayIntents(cfg.ExtraIntents),
+		feedbackURL:          cfg.FeedbackWebhookURL,
+		cooldowns:            newCooldowns(time.Duration(cfg.CommandCooldownSecs)*time.Second, time.Duration(cfg.GuildCommandCooldownSecs)*time.Second),
 	}
 
-	session.AddHandler(client.handleInteraction)
+	if cfg.PresenceRouting {
+		client.presenceRouting = true
+		client.awayEscalateAfterNags = (cfg.EscalateAfterNags + 1) / 2
+	}
+	if cfg.Encouragement {
+		client.encouragements = newEncouragements(cfg.EncouragementMessages)
+	}
 
-	if err := session.Open(); err != nil {
-		return nil, fmt.Errorf("failed to open Discord connection: %w", err)
+	if err := client.loadMaintenance(ctx); err != nil {
+		return nil, err
+	}
+	if err := client.loadComponentIDs(ctx, cfg.InteractionSecret); err != nil {
+		return nil, err
 	}
 
+	client.subscribe(ctx, bus)
+	client.addHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		client.handleInteraction(ctx, s, i)
+	})
+	client.addHandler(func(s *discordgo.Session, d *discordgo.ChannelDelete) {
+		if d.ID == client.channelID {
+			client.loseChannel(ctx, "the channel was deleted")
+		}
+	})
+	if cfg.GuildOnboarding {
+		client.addHandler(func(s *discordgo.Session, g *discordgo.GuildCreate) {
+			client.handleGuildCreate(ctx, s, g)
+		})
+	}
+	if cfg.SleepInDeferHours > 0 {
+		client.addHandler(func(s *discordgo.Session, p *discordgo.PresenceUpdate) {
+			client.handlePresenceUpdate(ctx, p)
+		})
+	}
+
+	session, err := client.openSession(cfg.DiscordToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.validateTargets(session); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	client.session.Store(session)
 	return client, nil
 }
 
+// Start registers the interaction handlers and slash commands, and in the background moves pending
+// reminders if the reminder channel changed and refreshes the buttons on old reminder messages.
+// If the token is read from a file, the file is watched for a rotated token.
+func (c *Client) Start(ctx context.Context) {
+	c.RegisterMedicationHandler(ctx)
+
+	if err := c.RegisterCommands(ctx); err != nil {
+		// Reminders still work without slash commands
+		log.Printf("Error registering slash commands: %v", err)
+	}
+
+	if c.tokenFile != "" {
+		go c.watchToken(ctx)
+	}
+
+	// Old reminder messages are moved and refreshed in the background since edits are rate limited
+	go func() {
+		if err := c.MigrateChannel(ctx); err != nil {
+			log.Printf("Error moving reminders to the new channel: %v", err)
+		}
+		if err := c.RefreshReminderButtons(ctx); err != nil {
+			log.Printf("Error refreshing reminder buttons: %v", err)
+		}
+	}()
+}
+
+// Connected reports whether the gateway connection is up and ready
+func (c *Client) Connected() bool {
+	c.session.Load().RLock()
+	defer c.session.Load().RUnlock()
+	return c.session.Load().DataReady
+}
+
+// GuildCount returns the number of guilds the bot is in
+func (c *Client) GuildCount() int {
+	c.session.Load().State.RLock()
+	defer c.session.Load().State.RUnlock()
+	return len(c.session.Load().State.Guilds)
+}
+
 // Close closes the Discord session
 func (c *Client) Close() error {
-	return c.session.Close()
+	return c.session.Load().Close()
 }
 
 // SendReminder sends a reminder message with a button
-func (c *Client) SendReminder(ctx context.Context, medication config.Medication) (string, error) {
-	// Create a unique custom ID for the button
-	customID := fmt.Sprintf("medication_taken_%s", medication.Name)
+func (c *Client) SendReminder(ctx context.Context, medication config.Medication, opts ReminderOptions) (string, error) {
+	accessible := c.accessible(ctx)
 
-	// Create the button component
 	components := []discordgo.MessageComponent{
 		discordgo.ActionsRow{
-			Components: []discordgo.MessageComponent{
-				discordgo.Button{
-					Label:    fmt.Sprintf("I took %s", medication.Name),
-					Style:    discordgo.SuccessButton,
-					CustomID: customID,
-					Emoji: &discordgo.ComponentEmoji{
-						Name: "✅",
-					},
-				},
-			},
+			Components: []discordgo.MessageComponent{c.reminderTakenButton(medication, accessible), c.skipButton(medication, accessible)},
 		},
 	}
 
+	policy := medication.Policy()
+
+	body := c.reminderContent(ctx, medication, accessible)
+	if !accessible {
+		body = c.withEncouragement(body)
+	}
+
 	content := ""
-	if c.userIDToPing != "" {
-		content += fmt.Sprintf("<@%s> ", c.userIDToPing)
+	if opts.Escalate {
+		content += "@here "
+	}
+	// Templates can place the mention themselves with {{.UserMention}}
+	if policy.PingUser && !strings.Contains(body, userMention(medication.UserID)) {
+		content += mention(medication.UserID)
+	}
+	content += body
+	if !opts.DueAt.IsZero() {
+		content += "\n" + deadlineLine(opts.DueAt, accessible)
+	}
+
+	var files []*discordgo.File
+	if c.qrCodes {
+		png, err := c.ackQRCode(medication.Name)
+		if err != nil {
+			// The button still works, so send the reminder without the QR code
+			log.Printf("Error generating QR code for %s: %v", medication.Name, err)
+		} else {
+			content += "\nOr scan the QR code to mark it as taken."
+			files = append(files, &discordgo.File{
+				Name:        "acknowledge.png",
+				ContentType: "image/png",
+				Reader:      bytes.NewReader(png),
+			})
+		}
+	}
+
+	var embeds []*discordgo.MessageEmbed
+	if medication.Image != "" {
+		embed, file, err := pillImage(medication)
+		if err != nil {
+			log.Printf("Error attaching image for %s: %v", medication.Name, err)
+		} else {
+			embeds = append(embeds, embed)
+			if file != nil {
+				files = append(files, file)
+			}
+		}
+	}
+
+	if file, err := c.reminderSoundFile(medication); err != nil {
+		// A missing sound shouldn't stop the reminder
+		log.Printf("Error attaching sound for %s: %v", medication.Name, err)
+	} else if file != nil {
+		files = append(files, file)
 	}
-	content += fmt.Sprintf("🔔 **Medication Reminder: %s** 🔔\n", medication.Name)
-	content += fmt.Sprintf("It's time to take your %s! Please click the button below once you've taken it.", medication.Name)
 
-	msg, err := c.session.ChannelMessageSendComplex(c.channelID, &discordgo.MessageSend{
+	msg, err := c.session.Load().ChannelMessageSendComplex(c.channelID, &discordgo.MessageSend{
 		Content:    content,
 		Components: components,
+		Files:      files,
+		Embeds:     embeds,
+		AllowedMentions: &discordgo.MessageAllowedMentions{
+			Parse: []discordgo.AllowedMentionType{
+				discordgo.AllowedMentionTypeUsers,
+				discordgo.AllowedMentionTypeEveryone,
+			},
+		},
 	})
 
 	if err != nil {
@@ -99,128 +335,519 @@ func (c *Client) SendReminder(ctx context.Context, medication config.Medication)
 	return msg.ID, nil
 }
 
-// DeleteMessage deletes a message
+// deadlineLine shows when a dose was due and when its reminder window closes, using Discord's dynamic
+// timestamps so the relative times stay accurate without editing the message
+func deadlineLine(dueAt time.Time, accessible bool) string {
+	closesAt := dueAt.Add(config.ReminderWindowHours * time.Hour)
+	if accessible {
+		return fmt.Sprintf("Due <t:%d:R>. Take it by <t:%d:t>.", dueAt.Unix(), closesAt.Unix())
+	}
+	return fmt.Sprintf("⏰ Due <t:%d:R> · reminders stop at <t:%d:t> (<t:%d:R>)", dueAt.Unix(), closesAt.Unix(), closesAt.Unix())
+}
+
+// reminderContent returns the reminder message for a medication, from its template if one is configured
+func (c *Client) reminderContent(ctx context.Context, medication config.Medication, accessible bool) string {
+	if text := c.templateFor(medication); text != "" {
+		content, err := c.renderReminderTemplate(ctx, text, medication)
+		if err == nil {
+			return content
+		}
+		// Fall back to the default message rather than missing a reminder
+		log.Printf("Error rendering reminder template for %s: %v", medication.Name, err)
+	}
+
+	if accessible {
+		return accessibleReminderContent(medication.Name)
+	}
+
+	content := ""
+	if medication.Priority == config.PriorityCritical {
+		content += fmt.Sprintf("🚨 **CRITICAL Medication Reminder: %s** 🚨\n", medication.Name)
+	} else {
+		content += fmt.Sprintf("🔔 **Medication Reminder: %s** 🔔\n", medication.Name)
+	}
+	content += fmt.Sprintf("It's time to take your %s! Please click the button below once you've taken it.", medication.Name)
+
+	return content
+}
+
+// reminderSoundFile returns the audio file attached to a medication's reminders, or nil if none is configured
+func (c *Client) reminderSoundFile(medication config.Medication) (*discordgo.File, error) {
+	path := medication.Sound
+	if path == "" {
+		path = c.reminderSound
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sound file: %w", err)
+	}
+
+	return &discordgo.File{
+		Name:        filepath.Base(path),
+		ContentType: config.SoundContentTypes[strings.ToLower(filepath.Ext(path))],
+		Reader:      bytes.NewReader(data),
+	}, nil
+}
+
+// pillImage returns an embed showing a medication's image. Local images are returned as a file to attach.
+func pillImage(medication config.Medication) (*discordgo.MessageEmbed, *discordgo.File, error) {
+	embed := &discordgo.MessageEmbed{
+		Description: fmt.Sprintf("This is what %s looks like.", medication.Name),
+	}
+
+	if config.IsImageURL(medication.Image) {
+		embed.Image = &discordgo.MessageEmbedImage{URL: medication.Image}
+		return embed, nil, nil
+	}
+
+	data, err := os.ReadFile(medication.Image)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read image file: %w", err)
+	}
+
+	// Embeds can show attached files by name
+	name := "pill" + strings.ToLower(filepath.Ext(medication.Image))
+	embed.Image = &discordgo.MessageEmbedImage{URL: "attachment://" + name}
+
+	return embed, &discordgo.File{
+		Name:        name,
+		ContentType: config.ImageContentTypes[strings.ToLower(filepath.Ext(medication.Image))],
+		Reader:      bytes.NewReader(data),
+	}, nil
+}
+
+// takenButton returns the button for marking today's dose of a medication as taken, using the
+// medication's configured label, emoji and style in place of the defaults
+func (c *Client) takenButton(medication config.Medication, label, emoji string) discordgo.Button {
+	if medication.ButtonLabel != "" {
+		label = medication.ButtonLabel
+	}
+	if medication.ButtonEmoji != "" {
+		emoji = medication.ButtonEmoji
+	}
+
+	button := discordgo.Button{
+		Label:    label,
+		Style:    buttonStyles[medication.ButtonStyle],
+		CustomID: c.customID(takenAction, medication.Name, c.doseDate(medication.Name)),
+	}
+	if button.Style == 0 {
+		button.Style = discordgo.SuccessButton
+	}
+	if emoji != "" {
+		button.Emoji = &discordgo.ComponentEmoji{Name: emoji}
+	}
+
+	return button
+}
+
+// reminderTakenButton returns the button on a reminder for marking the dose as taken
+func (c *Client) reminderTakenButton(medication config.Medication, accessible bool) discordgo.Button {
+	if accessible {
+		button := c.takenButton(medication, fmt.Sprintf("I have taken my %s", medication.Name), "")
+		button.Emoji = nil
+		return button
+	}
+	return c.takenButton(medication, fmt.Sprintf("I took %s", medication.Name), "✅")
+}
+
+// SendHeadsUp sends a heads-up that a medication is coming up, for medications that need preparation
+func (c *Client) SendHeadsUp(ctx context.Context, medication config.Medication, dueAt time.Time) (string, error) {
+	minutes := int(time.Until(dueAt).Round(time.Minute).Minutes())
+
+	content := ""
+	if medication.Policy().PingUser {
+		content += mention(medication.UserID)
+	}
+	content += fmt.Sprintf("⏰ Your %s %s is coming up in %d minutes.", dueAt.Format("3:04pm"), medication.Name, minutes)
+
+	msg, err := c.session.Load().ChannelMessageSend(c.channelID, content)
+	if err != nil {
+		return "", fmt.Errorf("failed to send heads-up message: %w", err)
+	}
+
+	return msg.ID, nil
+}
+
+// ackQRCode generates a PNG QR code encoding today's acknowledgment link for a medication
+func (c *Client) ackQRCode(medicationName string) ([]byte, error) {
+	link := c.ackLinks.URL(medicationName, c.doseDate(medicationName), time.Now())
+
+	png, err := qrcode.Encode(link, qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	return png, nil
+}
+
+// DeleteMessage deletes a message in the reminder channel
 func (c *Client) DeleteMessage(ctx context.Context, messageID string) error {
 	if messageID == "" {
 		return nil
 	}
 
-	err := c.session.ChannelMessageDelete(c.channelID, messageID)
-	if err != nil {
+	if err := c.removeMessage(c.channelID, messageID); err != nil {
 		return fmt.Errorf("failed to delete message: %w", err)
 	}
 
 	return nil
 }
 
-// RegisterHandler registers a handler for a custom ID prefix
-func (c *Client) RegisterHandler(prefix string, handler func(s *discordgo.Session, i *discordgo.InteractionCreate)) {
+// retiredContent replaces the content of messages that would be deleted when running with minimal permissions
+const retiredContent = "-# This reminder is no longer in use."
+
+// removeMessage deletes a message, or with minimal permissions, edits it to show it's no longer in use
+// and removes its buttons
+func (c *Client) removeMessage(channelID, messageID string) error {
+	if !c.minimalPerms {
+		return c.session.Load().ChannelMessageDelete(channelID, messageID)
+	}
+
+	content := retiredContent
+	_, err := c.session.Load().ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel:    channelID,
+		ID:         messageID,
+		Content:    &content,
+		Embeds:     &[]*discordgo.MessageEmbed{},
+		Components: &[]discordgo.MessageComponent{},
+	})
+	return err
+}
+
+// RegisterHandler registers a handler for the components whose custom IDs are for an action
+func (c *Client) RegisterHandler(action string, handler componentHandler) {
 	c.handlersMutex.Lock()
 	defer c.handlersMutex.Unlock()
-	c.handlers[prefix] = handler
+	c.handlers[action] = handler
 }
 
-// handleInteraction handles all interactions
-func (c *Client) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	// Only handle message component interactions (buttons)
-	if i.Type != discordgo.InteractionMessageComponent {
+// handleInteraction handles all interactions, with the store of the guild they came from
+func (c *Client) handleInteraction(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// Only handle message component (buttons) and modal submit interactions
+	var customID string
+	switch i.Type {
+	case discordgo.InteractionMessageComponent:
+		customID = i.MessageComponentData().CustomID
+	case discordgo.InteractionModalSubmit:
+		customID = i.ModalSubmitData().CustomID
+	default:
 		return
 	}
 
-	customID := i.MessageComponentData().CustomID
+	// Custom IDs that weren't made by the bot, or were made before it signed them, are rejected
+	id, err := c.ids.Decode(customID)
+	if err != nil {
+		log.Printf("Warning: Rejected custom ID %q: %v", customID, err)
+		c.respondWithError(s, i, "This button is no longer valid, please use a newer message")
+		return
+	}
 
 	c.handlersMutex.Lock()
 	defer c.handlersMutex.Unlock()
 
-	// Find a handler for this custom ID
-	for prefix, handler := range c.handlers {
-		if strings.HasPrefix(customID, prefix) {
-			handler(s, i)
-			return
-		}
+	handler, ok := c.handlers[id.Action]
+	if !ok {
+		log.Printf("Warning: No handler found for custom ID: %s", customID)
+		return
 	}
 
-	log.Printf("Warning: No handler found for custom ID: %s", customID)
+	ctx, err = c.withGuildStore(ctx, s, i)
+	if err != nil {
+		c.respondWithFailure(s, i, "Error finding this server's data", err)
+		return
+	}
+
+	log.Printf("Debug: Handling interaction %s with handler %s", customID, id.Action)
+	handler(ctx, s, i, id.Args)
 }
 
-// RegisterMedicationHandler registers the handler for medication buttons
+// RegisterMedicationHandler registers the handlers for medication buttons
 func (c *Client) RegisterMedicationHandler(ctx context.Context) {
-	c.RegisterHandler("medication_taken_", func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		customID := i.MessageComponentData().CustomID
-
+	c.registerRefillHandler(ctx)
+	c.registerLabTestHandlers(ctx)
+	c.registerOnboardingHandlers(ctx)
+	c.registerDoubleDoseHandler(ctx)
+	c.registerOverLimitHandler(ctx)
+	c.registerConfirmHandler(ctx)
+	c.registerSkipHandlers(ctx)
+
+	c.RegisterHandler(takenAction, func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+		medicationName := args[0]
+
+		// The click is acknowledged straight away, so it can't fail while a nag is being sent
 		if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
 		}); err != nil {
 			log.Printf("Error sending deferred response: %v", err)
 		}
 
-		// Parse the medication name from the customID
-		if len(customID) <= 17 {
-			log.Printf("Invalid customID format: %s", customID)
+		// Buttons only acknowledge the dose they were sent for, so a reminder left from an earlier
+		// day can't mark today's dose as taken
+		if !c.sentForDose(ctx, s, i, medicationName, args, "reminder") {
 			return
 		}
 
-		// Get everything after "medication_taken_"
-		medicationName := customID[17:]
+		// Users sharing the bot can't mark each other's doses as taken
+		userID := interactionUserID(i)
+		medication := c.medicationByName(medicationName)
+		if !canTake(medication, userID) {
+			c.editDeferred(s, i, fmt.Sprintf("%s is someone else's medication, so you can't mark it as taken.", medicationName))
+			return
+		}
 
-		reminder, err := c.store.GetTodayReminder(ctx, medicationName)
+		// Doses recorded soon after the last one need confirming, in case it's a double dose
+		last, err := c.recentDose(ctx, medication)
 		if err != nil {
-			log.Printf("Error getting reminder for %s: %v", medicationName, err)
-			c.respondWithError(s, i, fmt.Sprintf("Error getting reminder: %v", err))
+			// Without knowing when it was last taken, the dose is only recorded once it's confirmed
+			log.Printf("Warning: Couldn't check for a recent dose of %s, so asking to confirm it: %v", medicationName, err)
+			c.askToConfirmDose(s, i, medication, args[1], fmt.Sprintf("⚠️ I couldn't check when you last took %s. Are you sure you haven't taken it already?", medicationName))
 			return
 		}
-
-		// If already acknowledged, just respond
-		if reminder.Acknowledged {
-			err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("You've already acknowledged taking your %s today. Thank you!", medicationName),
-					Flags:   discordgo.MessageFlagsEphemeral,
-				},
-			})
-			if err != nil {
-				log.Printf("Error responding to interaction for %s: %v", medicationName, err)
-			}
+		if !last.IsZero() {
+			c.askToConfirmDose(s, i, medication, args[1], c.recentDoseWarning(medication, last))
 			return
 		}
 
-		err = c.store.UpdateReminderStatus(ctx, reminder.ID, true, i.Message.ID)
+		c.takeDose(ctx, s, i, medicationName, userID, i.Message.ID)
+	})
+}
+
+// sentForDose reports whether a button was sent for today's dose of a medication, going by the date of
+// the reminder it would mark as taken, and otherwise tells the user it's from an earlier day
+func (c *Client) sentForDose(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, medicationName string, args []string, kind string) bool {
+	if len(args) >= 2 {
+		// Medications no longer taken are refused when they're acknowledged, without creating a reminder
+		if !c.hasMedication(medicationName) {
+			return true
+		}
+
+		reminder, err := c.storeFor(ctx).GetTodayReminder(ctx, medicationName)
 		if err != nil {
-			log.Printf("Error updating reminder for %s: %v", medicationName, err)
-			c.respondWithError(s, i, fmt.Sprintf("Error updating reminder: %v", err))
-			return
+			c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error getting today's reminder for %s", medicationName), err))
+			return false
 		}
+		if args[1] == reminder.Date {
+			return true
+		}
+	}
 
-		// Update the original message
-		content := fmt.Sprintf("✅ **%s Taken** ✅\nThank you for taking your %s today!", medicationName, medicationName)
+	log.Printf("Warning: Rejected %s for %s from an earlier day", kind, medicationName)
+	c.editDeferred(s, i, fmt.Sprintf("This %s is from an earlier day, so it can't mark today's %s as taken. Use today's reminder instead.", kind, medicationName))
+	return false
+}
 
-		// Remove the button by setting empty components and update the message content
-		_, err = s.ChannelMessageEditComplex(&discordgo.MessageEdit{
-			Channel:    c.channelID,
-			ID:         i.Message.ID,
-			Content:    &content,
-			Components: &[]discordgo.MessageComponent{},
-		})
-		if err != nil {
-			log.Printf("Error updating message for %s: %v", medicationName, err)
+// takeDose marks today's dose of a medication as taken from a button, showing it on the reminder
+// message that was clicked and replying to the deferred interaction
+func (c *Client) takeDose(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, medicationName, userID, clickedID string) {
+	// A dose that needs confirming by others, such as a child's guardian, waits for them
+	medication := c.medicationByName(medicationName)
+	if medication.RequiredConfirmations() > 1 {
+		c.requestConfirmation(ctx, s, i, medication, userID)
+		return
+	}
+	// A dose that needs a photo is recorded once the photo is added
+	if medication.PhotoProof == config.PhotoProofRequired {
+		c.editDeferred(s, i, fmt.Sprintf("📷 Your %s needs a photo of your dose before it's recorded. Add one with `/meds photo name:%s`.", medicationName, medicationName))
+		return
+	}
+
+	reminder, alreadyTaken, err := c.acknowledgeReminder(ctx, medicationName, userID, nil, time.Time{}, func(*db.Reminder) string {
+		return clickedID
+	})
+	if err != nil {
+		c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error acknowledging %s", medicationName), err))
+		return
+	}
+
+	// If already acknowledged, just respond
+	if alreadyTaken {
+		c.editDeferred(s, i, fmt.Sprintf("You've already acknowledged taking your %s today. Thank you!", medicationName))
+		return
+	}
+
+	if clickedID != "" {
+		c.resolveClickedMessage(ctx, medicationName, reminder, clickedID)
+	}
+	c.publishAcknowledged(ctx, medicationName, reminder, "Discord")
+
+	content := fmt.Sprintf("Thank you for taking your %s! Your response has been recorded.", medicationName)
+	if medication.PhotoProof == config.PhotoProofOptional {
+		content += fmt.Sprintf(" 📷 You can add a photo of your dose with `/meds photo name:%s`.", medicationName)
+	}
+	c.editDeferred(s, i, content)
+}
+
+// AcknowledgeMedication marks today's dose of a medication as taken from outside of Discord, on behalf of
+// the user it's for, updating the reminder message and posting a confirmation to the channel.
+// It reports whether the dose had already been acknowledged. Doses that need confirming by others, or a
+// photo, can only be taken in Discord, so they return db.ErrConfirmationRequired or db.ErrPhotoRequired.
+func (c *Client) AcknowledgeMedication(ctx context.Context, medicationName, source string) (bool, error) {
+	return c.acknowledgeExternally(ctx, medicationName, c.userFor(medicationName), source, time.Time{})
+}
+
+// AcknowledgeMedicationAs marks today's dose of a medication as taken from outside of Discord by a known
+// Discord user, such as one logged in to the dashboard, recording them as who acknowledged it
+func (c *Client) AcknowledgeMedicationAs(ctx context.Context, medicationName, userID, source string) (bool, error) {
+	return c.acknowledgeExternally(ctx, medicationName, userID, source, time.Time{})
+}
+
+// AcknowledgeMedicationAt marks today's dose of a medication as taken from outside of Discord, recording
+// when it was taken for doses reported afterwards, such as by a smart pillbox
+func (c *Client) AcknowledgeMedicationAt(ctx context.Context, medicationName, source string, takenAt time.Time) (bool, error) {
+	return c.acknowledgeExternally(ctx, medicationName, c.userFor(medicationName), source, takenAt)
+}
+
+// acknowledgeExternally marks today's dose of a medication as taken from outside of Discord by the user,
+// at takenAt or now if it's zero, and updates the reminder message and posts a confirmation to the channel
+func (c *Client) acknowledgeExternally(ctx context.Context, medicationName, userID, source string, takenAt time.Time) (bool, error) {
+	reminder, alreadyTaken, err := c.acknowledgeReminder(ctx, medicationName, userID, nil, takenAt, func(reminder *db.Reminder) string {
+		return reminder.MessageID
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to acknowledge %s: %w", medicationName, err)
+	}
+	if alreadyTaken {
+		return true, nil
+	}
+
+	if reminder.MessageID != "" {
+		if err := c.markMessageTaken(ctx, medicationName, reminder.MessageID); err != nil {
+			log.Printf("Error marking %s as taken: %v", medicationName, err)
 		}
+	}
+	c.publishAcknowledged(ctx, medicationName, reminder, source)
 
-		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("Thank you for taking your %s! Your response has been recorded.", medicationName),
-				Flags:   discordgo.MessageFlagsEphemeral,
-			},
-		})
-		if err != nil {
-			log.Printf("Error responding to interaction for %s: %v", medicationName, err)
+	content := fmt.Sprintf("✅ %s was marked as taken via %s.", medicationName, source)
+	if _, err := c.session.Load().ChannelMessageSend(c.channelID, content); err != nil {
+		log.Printf("Error sending acknowledgment confirmation for %s: %v", medicationName, err)
+	}
+
+	return false, nil
+}
+
+// markMessageTaken updates a reminder message to show the medication has been taken
+func (c *Client) markMessageTaken(ctx context.Context, medicationName, messageID string) error {
+	if c.reminderMode == config.ReminderModeChecklist {
+		// Re-render the checklist with the updated progress
+		if err := c.updateChecklist(ctx, messageID); err != nil {
+			return fmt.Errorf("failed to update checklist: %w", err)
 		}
+		return nil
+	}
+
+	accessible := c.accessible(ctx)
+	content, err := c.acknowledgedContent(ctx, medicationName)
+	if err != nil {
+		// Fall back to the default message rather than leaving the reminder's button
+		log.Printf("Error rendering acknowledged template for %s: %v", medicationName, err)
+	}
+	switch {
+	case content == "" && accessible:
+		content = fmt.Sprintf("%s taken. Thank you.", medicationName)
+	case content == "":
+		content = c.withEncouragement(fmt.Sprintf("✅ **%s Taken** ✅\nThank you for taking your %s today!", medicationName, medicationName))
+	case !accessible:
+		content = c.withEncouragement(content)
+	}
+
+	// Remove the button by setting empty components and update the message content
+	_, err = c.session.Load().ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel:    c.channelID,
+		ID:         messageID,
+		Content:    &content,
+		Components: &[]discordgo.MessageComponent{},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update message: %w", err)
+	}
+
+	return nil
+}
+
+// markMessageMissed updates a reminder message to show the dose was missed, removing its button
+func (c *Client) markMessageMissed(ctx context.Context, medicationName, messageID string) error {
+	content := missedContent(medicationName, c.accessible(ctx))
+	_, err := c.session.Load().ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel:    c.channelID,
+		ID:         messageID,
+		Content:    &content,
+		Embeds:     &[]*discordgo.MessageEmbed{},
+		Components: &[]discordgo.MessageComponent{},
 	})
+	if err != nil {
+		return fmt.Errorf("failed to update message: %w", err)
+	}
+
+	return nil
+}
+
+// missedContent is the text a missed dose's reminder message is replaced with
+func missedContent(medicationName string, accessible bool) string {
+	if accessible {
+		return fmt.Sprintf("%s missed. The time to take it has passed.", medicationName)
+	}
+	return fmt.Sprintf("❌ **%s Missed** ❌\nThe reminder window closed, so today's dose was recorded as missed.", medicationName)
+}
+
+// hasMedication checks if a medication is configured
+func (c *Client) hasMedication(name string) bool {
+	for _, medication := range c.medications {
+		if medication.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBaseMedication checks if a medication is configured under the name its details are recorded under,
+// which for one taken more than once a day is shared by all its doses
+func (c *Client) hasBaseMedication(name string) bool {
+	for _, medication := range c.medications {
+		if medication.Base() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// doseDate returns the date of today's dose of a medication, which is where it's taken when that's
+// another timezone, so buttons and links are for the dose they were sent for
+func (c *Client) doseDate(medicationName string) string {
+	return c.store.Calendar().Today(medicationName)
+}
+
+// medicationByName returns the configured medication with the given name, or the first dose of the
+// medication taken more than once a day with that name
+func (c *Client) medicationByName(name string) config.Medication {
+	for _, medication := range c.medications {
+		if medication.Name == name {
+			return medication
+		}
+	}
+	for _, medication := range c.medications {
+		if medication.Base() == name {
+			return medication
+		}
+	}
+	return config.Medication{Name: name}
+}
+
+// editDeferred replaces the deferred response to an interaction
+func (c *Client) editDeferred(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &content}); err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
 }
 
 // respondWithError responds to an interaction with an error message
 func (c *Client) respondWithError(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
+	c.noteFailure(i)
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -0,0 +1,147 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// doubleDoseAction is the custom ID action of the button confirming a dose taken soon after the last one
+const doubleDoseAction = "double"
+
+// recentDose returns when a medication was last taken, if that was within its minimum gap and
+// today's dose hasn't been taken yet, or the zero time if the dose can be recorded straight away.
+// Every dose of a medication taken more than once a day counts, since they're the same medication.
+func (c *Client) recentDose(ctx context.Context, medication config.Medication) (time.Time, error) {
+	if medication.MinGapHours <= 0 {
+		return time.Time{}, nil
+	}
+
+	now := time.Now().In(c.location)
+	gap := time.Duration(medication.MinGapHours) * time.Hour
+	// A read replica may not have the last dose yet, so this reads from the primary database
+	reminders, err := c.storeFor(ctx).GetReminderHistory(db.WithPrimary(ctx), "", now.Add(-gap))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get recent doses: %w", err)
+	}
+
+	var doses []string
+	for _, other := range c.medications {
+		if other.Base() == medication.Base() {
+			doses = append(doses, other.Name)
+		}
+	}
+	if !slices.Contains(doses, medication.Name) {
+		doses = append(doses, medication.Name)
+	}
+
+	today := c.doseDate(medication.Name)
+	var last time.Time
+	for _, reminder := range reminders {
+		if !slices.Contains(doses, reminder.MedicationType) {
+			continue
+		}
+		if reminder.MedicationType == medication.Name && reminder.Date == today && reminder.Acknowledged {
+			// Taking it again today is refused anyway
+			return time.Time{}, nil
+		}
+		if reminder.Acknowledged && reminder.AcknowledgedAt.After(last) {
+			last = reminder.AcknowledgedAt
+		}
+	}
+
+	if last.IsZero() || now.Sub(last) >= gap {
+		return time.Time{}, nil
+	}
+	return last, nil
+}
+
+// recentDoseWarning warns that a medication was taken recently
+func (c *Client) recentDoseWarning(medication config.Medication, last time.Time) string {
+	return fmt.Sprintf("⚠️ You recorded %s %s ago, at %s. Are you sure you've taken another dose?",
+		medication.Name, formatGap(time.Since(last)), last.In(c.location).Format("15:04 on Monday"))
+}
+
+// askToConfirmDose shows a warning about taking a medication again, replying to the deferred interaction
+// with a button to record the dose on the date the reminder was sent for anyway
+func (c *Client) askToConfirmDose(s *discordgo.Session, i *discordgo.InteractionCreate, medication config.Medication, date, content string) {
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Yes, record it",
+					Style:    discordgo.DangerButton,
+					CustomID: c.customID(doubleDoseAction, medication.Name, date),
+				},
+			},
+		},
+	}
+
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &content, Components: &components}); err != nil {
+		log.Printf("Error asking to confirm dose of %s: %v", medication.Name, err)
+	}
+}
+
+// registerDoubleDoseHandler registers the handler for confirming a dose taken soon after the last one
+func (c *Client) registerDoubleDoseHandler(ctx context.Context) {
+	c.RegisterHandler(doubleDoseAction, func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+		medicationName := args[0]
+
+		if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+		}); err != nil {
+			log.Printf("Error sending deferred response: %v", err)
+		}
+
+		if !c.sentForDose(ctx, s, i, medicationName, args, "confirmation") {
+			return
+		}
+
+		userID := interactionUserID(i)
+		if !canTake(c.medicationByName(medicationName), userID) {
+			c.editDeferred(s, i, fmt.Sprintf("%s is someone else's medication, so you can't mark it as taken.", medicationName))
+			return
+		}
+
+		// The confirmation isn't the reminder, so the reminder's current message shows the dose was taken
+		clickedID, err := c.currentReminderMessage(ctx, medicationName)
+		if err != nil {
+			log.Printf("Error getting reminder message for %s: %v", medicationName, err)
+		}
+		log.Printf("Warning: %s confirmed a dose of %s soon after the last one", userID, medicationName)
+		c.takeDose(ctx, s, i, medicationName, userID, clickedID)
+	})
+}
+
+// currentReminderMessage returns the message showing today's dose of a medication: the checklist in
+// checklist mode, or otherwise its latest reminder, if one has been sent
+func (c *Client) currentReminderMessage(ctx context.Context, medicationName string) (string, error) {
+	if c.reminderMode == config.ReminderModeChecklist {
+		return c.storeFor(ctx).GetTodayChecklist(ctx)
+	}
+
+	reminder, err := c.storeFor(ctx).GetTodayReminder(ctx, medicationName)
+	if err != nil {
+		return "", err
+	}
+	return reminder.MessageID, nil
+}
+
+// formatGap describes the time since a dose in hours, or minutes if it was under an hour ago
+func formatGap(d time.Duration) string {
+	if d < time.Hour {
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	}
+	if hours := int(d.Hours()); hours != 1 {
+		return fmt.Sprintf("%d hours", hours)
+	}
+	return "1 hour"
+}
@@ -0,0 +1,99 @@
+package discord
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+)
+
+// newTimezoneClient returns a client in a timezone a day behind medications taken in Kiritimati, whose
+// store dates their doses there as the reminder service does
+func newTimezoneClient(t *testing.T, medications ...config.Medication) *Client {
+	t.Helper()
+
+	home, err := time.LoadLocation("Pacific/Pago_Pago")
+	if err != nil {
+		t.Skipf("Timezone data isn't available: %v", err)
+	}
+	store := db.NewMemoryStore(home)
+	store.Calendar().SetLocator(func(medicationType string) *time.Location {
+		for _, medication := range medications {
+			if medication.Name == medicationType && medication.Timezone != "" {
+				return medication.In(time.Now()).Location()
+			}
+		}
+		return nil
+	})
+
+	return &Client{store: store, location: home, medications: medications}
+}
+
+// TestRecentDoseInMedicationTimezone tests that a dose taken today where the medication is taken, which
+// is tomorrow in the configured timezone, counts as today's dose
+func TestRecentDoseInMedicationTimezone(t *testing.T) {
+	ctx := context.Background()
+	medication := config.Medication{Name: "Morning Pill", Hour: 8, Frequency: "daily", MinGapHours: 4, Timezone: "Pacific/Kiritimati"}
+	client := newTimezoneClient(t, medication)
+
+	reminder, err := client.store.GetTodayReminder(ctx, medication.Name)
+	if err != nil {
+		t.Fatalf("GetTodayReminder() error = %v", err)
+	}
+	if reminder.Date == time.Now().In(client.location).Format("2006-01-02") {
+		t.Fatalf("Expected the dose to be dated in the medication's timezone, got %s", reminder.Date)
+	}
+	if err := client.store.RecordAcknowledgment(ctx, reminder.ID, reminder.Version, "", "user", time.Time{}); err != nil {
+		t.Fatalf("RecordAcknowledgment() error = %v", err)
+	}
+
+	// Taking today's dose again is refused elsewhere, so it isn't warned about as a double dose
+	last, err := client.recentDose(ctx, medication)
+	if err != nil {
+		t.Fatalf("recentDose() error = %v", err)
+	}
+	if !last.IsZero() {
+		t.Errorf("Expected today's dose to count as taken today, got a recent dose at %v", last)
+	}
+}
+
+// TestRecentDoseAcrossDoses tests that a medication taken twice a day counts its other dose as the last
+// time it was taken
+func TestRecentDoseAcrossDoses(t *testing.T) {
+	ctx := context.Background()
+	morning := config.Medication{Name: "Metformin 08:00", BaseName: "Metformin", Hour: 8, Frequency: "daily", MinGapHours: 6}
+	evening := config.Medication{Name: "Metformin 20:00", BaseName: "Metformin", Hour: 20, Frequency: "daily", MinGapHours: 6}
+	other := config.Medication{Name: "Vitamin D", Hour: 8, Frequency: "daily"}
+	store := db.NewMemoryStore(time.UTC)
+	client := &Client{store: store, location: time.UTC, medications: []config.Medication{morning, evening, other}}
+
+	take := func(name string, at time.Time) {
+		t.Helper()
+		reminder, err := store.GetTodayReminder(ctx, name)
+		if err != nil {
+			t.Fatalf("GetTodayReminder() error = %v", err)
+		}
+		if err := store.RecordAcknowledgment(ctx, reminder.ID, reminder.Version, "", "user", at); err != nil {
+			t.Fatalf("RecordAcknowledgment() error = %v", err)
+		}
+	}
+
+	// Test case: Another medication taken recently isn't a double dose
+	take(other.Name, time.Now().Add(-time.Hour))
+	if last, err := client.recentDose(ctx, evening); err != nil || !last.IsZero() {
+		t.Errorf("recentDose() = %v, %v, want no recent dose", last, err)
+	}
+
+	// Test case: The medication's other dose taken recently is
+	takenAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	take(morning.Name, takenAt)
+	last, err := client.recentDose(ctx, evening)
+	if err != nil {
+		t.Fatalf("recentDose() error = %v", err)
+	}
+	if !last.Equal(takenAt) {
+		t.Errorf("recentDose() = %v, want the morning dose at %v", last, takenAt)
+	}
+}
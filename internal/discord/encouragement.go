@@ -0,0 +1,59 @@
+package discord
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// defaultEncouragements are used when encouragement is enabled without a custom list
+var defaultEncouragements = []string{
+	"You've got this! 💪",
+	"Small steps add up. 🌱",
+	"Looking after yourself matters. 💙",
+	"Another day, another win. 🏆",
+	"Future you says thanks! 🙌",
+	"Consistency is a superpower. ⚡",
+	"Proud of you for keeping on top of this. ⭐",
+	"Take a moment and a sip of water too. 💧",
+}
+
+// encouragements picks rotating encouragement lines, avoiding the same line twice in a row
+type encouragements struct {
+	mu    sync.Mutex
+	lines []string
+	last  int
+}
+
+// newEncouragements creates an encouragement pool, using the defaults if no lines are given
+func newEncouragements(lines []string) *encouragements {
+	if len(lines) == 0 {
+		lines = defaultEncouragements
+	}
+	return &encouragements{lines: lines, last: -1}
+}
+
+// Next returns a random encouragement line, or an empty string if the pool is nil
+func (e *encouragements) Next() string {
+	if e == nil {
+		return ""
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	n := rand.IntN(len(e.lines))
+	if n == e.last && len(e.lines) > 1 {
+		n = (n + 1) % len(e.lines)
+	}
+	e.last = n
+
+	return e.lines[n]
+}
+
+// withEncouragement appends an encouragement line to a message when encouragement is enabled
+func (c *Client) withEncouragement(content string) string {
+	if line := c.encouragements.Next(); line != "" {
+		return content + "\n" + line
+	}
+	return content
+}
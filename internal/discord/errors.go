@@ -0,0 +1,126 @@
+package discord
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"meds-bot/internal/db"
+	"meds-bot/internal/telemetry"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Keys of the messages shown to users for errors
+const (
+	errorUnexpected          = "unexpected"
+	errorMedicationInactive  = "medication_inactive"
+	errorReminderNotFound    = "reminder_not_found"
+	errorAlreadyAcknowledged = "already_acknowledged"
+	errorConflict            = "conflict"
+	errorReference           = "reference"
+)
+
+// errorMessages are the messages shown to users for errors, by key and language. Languages
+// without a translation fall back to English.
+var errorMessages = map[string]map[string]string{
+	errorUnexpected: {
+		"en": "Something went wrong, please try again later.",
+		"de": "Etwas ist schiefgelaufen, bitte versuche es später noch einmal.",
+		"fr": "Une erreur s'est produite, veuillez réessayer plus tard.",
+		"es": "Algo salió mal, inténtalo de nuevo más tarde.",
+	},
+	errorMedicationInactive: {
+		"en": "That medication is no longer scheduled.",
+		"de": "Dieses Medikament ist nicht mehr eingeplant.",
+		"fr": "Ce médicament n'est plus programmé.",
+		"es": "Ese medicamento ya no está programado.",
+	},
+	errorReminderNotFound: {
+		"en": "That reminder no longer exists.",
+		"de": "Diese Erinnerung existiert nicht mehr.",
+		"fr": "Ce rappel n'existe plus.",
+		"es": "Ese recordatorio ya no existe.",
+	},
+	errorAlreadyAcknowledged: {
+		"en": "That dose was already marked as taken.",
+		"de": "Diese Dosis wurde bereits als eingenommen markiert.",
+		"fr": "Cette dose a déjà été marquée comme prise.",
+		"es": "Esa dosis ya se marcó como tomada.",
+	},
+	errorConflict: {
+		"en": "That reminder was changed at the same time, please try again.",
+		"de": "Diese Erinnerung wurde gleichzeitig geändert, bitte versuche es noch einmal.",
+		"fr": "Ce rappel a été modifié en même temps, veuillez réessayer.",
+		"es": "Ese recordatorio se modificó al mismo tiempo, inténtalo de nuevo.",
+	},
+	errorReference: {
+		"en": "Reference",
+		"de": "Referenz",
+		"fr": "Référence",
+		"es": "Referencia",
+	},
+}
+
+// errorKey returns the key of the message shown to users for an error
+func errorKey(err error) string {
+	switch {
+	case errors.Is(err, db.ErrMedicationInactive):
+		return errorMedicationInactive
+	case errors.Is(err, db.ErrReminderNotFound):
+		return errorReminderNotFound
+	case errors.Is(err, db.ErrAlreadyAcknowledged):
+		return errorAlreadyAcknowledged
+	case errors.Is(err, db.ErrReminderConflict):
+		return errorConflict
+	default:
+		return errorUnexpected
+	}
+}
+
+// localize returns a message in the language of a Discord locale, such as "de" for "de" or "es" for "es-ES"
+func localize(key string, locale discordgo.Locale) string {
+	language, _, _ := strings.Cut(string(locale), "-")
+	if message, ok := errorMessages[key][language]; ok {
+		return message
+	}
+	return errorMessages[key]["en"]
+}
+
+// presentError logs an error in full under a new reference ID, and returns a short message about it
+// in the user's language, with the reference so it can be found in the logs. The details of the error,
+// which may include SQL, are never shown.
+func presentError(i *discordgo.InteractionCreate, action string, err error) string {
+	reference := newErrorReference()
+	log.Printf("%s [ref %s]: %v", action, reference, err)
+
+	key := errorKey(err)
+	telemetry.Default.Error(key)
+	return fmt.Sprintf("%s\n-# %s: %s", localize(key, i.Locale), localize(errorReference, i.Locale), reference)
+}
+
+// newErrorReference returns a short random ID for matching an error shown to a user with the logs
+func newErrorReference() string {
+	id := make([]byte, 4)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// respondWithFailure responds to an interaction with a localized message for an error that occurred
+// while performing an action, such as "Error saving contact", and logs it
+func (c *Client) respondWithFailure(s *discordgo.Session, i *discordgo.InteractionCreate, action string, err error) {
+	c.noteFailure(i)
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: presentError(i, action, err),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding with error message: %v", err)
+	}
+}
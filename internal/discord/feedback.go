@@ -0,0 +1,84 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"meds-bot/internal/db"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxFeedbackLength is the longest feedback accepted, leaving room in the forwarded message
+// for Discord's 2000 character limit
+const maxFeedbackLength = 1500
+
+// feedbackClient forwards feedback to the operator's webhook
+var feedbackClient = &http.Client{Timeout: 10 * time.Second}
+
+// handleFeedbackCommand records feedback about the bot, and forwards it to the operator's webhook if one is configured
+func (c *Client) handleFeedbackCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	text := strings.TrimSpace(options["text"].StringValue())
+	if text == "" {
+		c.respondWithError(s, i, "Feedback can't be empty")
+		return
+	}
+
+	user := i.User
+	if i.Member != nil {
+		user = i.Member.User
+	}
+
+	feedback := &db.Feedback{UserID: user.ID, Text: text}
+	if err := c.storeFor(ctx).RecordFeedback(ctx, feedback); err != nil {
+		c.respondWithFailure(s, i, "Error saving feedback", err)
+		return
+	}
+	log.Printf("Recorded feedback %d", feedback.ID)
+
+	// Forwarded in the background, so a slow webhook doesn't delay the response past Discord's deadline
+	if c.feedbackURL != "" {
+		go func() {
+			if err := c.forwardFeedback(ctx, feedback, user.Username); err != nil {
+				log.Printf("Error forwarding feedback %d: %v", feedback.ID, err)
+			}
+		}()
+	}
+
+	c.respond(s, i, "Thanks for your feedback, it's been passed on.")
+}
+
+// forwardFeedback posts feedback to the operator's webhook as a Discord webhook message, without
+// pinging anyone it mentions
+func (c *Client) forwardFeedback(ctx context.Context, feedback *db.Feedback, username string) error {
+	body, err := json.Marshal(map[string]any{
+		"content":          fmt.Sprintf("📝 **Feedback #%d** from %s (<@%s>)\n%s", feedback.ID, username, feedback.UserID, feedback.Text),
+		"allowed_mentions": map[string]any{"parse": []string{}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode feedback: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.feedbackURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := feedbackClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post feedback: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
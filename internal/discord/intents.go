@@ -0,0 +1,39 @@
+package discord
+
+import "github.com/bwmarrin/discordgo"
+
+// requiredIntents are the gateway intents the bot needs: Guilds keeps channels and permissions in the
+// state cache and reports deleted channels and new guilds. Interactions are delivered without any intents.
+const requiredIntents = discordgo.IntentGuilds
+
+// intentsByName maps the names of config.IntentNames to gateway intents
+var intentsByName = map[string]discordgo.Intent{
+	"guilds":                        discordgo.IntentGuilds,
+	"guild_members":                 discordgo.IntentGuildMembers,
+	"guild_moderation":              discordgo.IntentGuildModeration,
+	"guild_emojis":                  discordgo.IntentGuildEmojis,
+	"guild_integrations":            discordgo.IntentGuildIntegrations,
+	"guild_webhooks":                discordgo.IntentGuildWebhooks,
+	"guild_invites":                 discordgo.IntentGuildInvites,
+	"guild_voice_states":            discordgo.IntentGuildVoiceStates,
+	"guild_presences":               discordgo.IntentGuildPresences,
+	"guild_messages":                discordgo.IntentGuildMessages,
+	"guild_message_reactions":       discordgo.IntentGuildMessageReactions,
+	"guild_message_typing":          discordgo.IntentGuildMessageTyping,
+	"direct_messages":               discordgo.IntentDirectMessages,
+	"direct_message_reactions":      discordgo.IntentDirectMessageReactions,
+	"direct_message_typing":         discordgo.IntentDirectMessageTyping,
+	"message_content":               discordgo.IntentMessageContent,
+	"guild_scheduled_events":        discordgo.IntentGuildScheduledEvents,
+	"auto_moderation_configuration": discordgo.IntentAutoModerationConfiguration,
+	"auto_moderation_execution":     discordgo.IntentAutoModerationExecution,
+}
+
+// gatewayIntents returns the intents to request: those the bot needs, and any extra ones configured
+func gatewayIntents(extra []string) discordgo.Intent {
+	intents := requiredIntents
+	for _, name := range extra {
+		intents |= intentsByName[name]
+	}
+	return intents
+}
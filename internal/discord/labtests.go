@@ -0,0 +1,317 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"meds-bot/internal/db"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// labRecordAction is the custom ID action of the button to record a lab test result
+	labRecordAction = "lab_record"
+	// labSubmitAction is the custom ID action of the modal a lab test result is entered in
+	labSubmitAction = "lab_submit"
+	// labChartResults is the number of results shown on a lab test chart
+	labChartResults = 20
+)
+
+// sparkBlocks are the characters used to draw sparkline charts, from lowest to highest
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// SendLabTestReminder sends a reminder that a lab test is due, with a button to record the result
+func (c *Client) SendLabTestReminder(ctx context.Context, test *db.LabTest) error {
+	due, err := time.ParseInLocation("2006-01-02", test.NextDue, c.location)
+	if err != nil {
+		return fmt.Errorf("invalid lab test due date %s: %w", test.NextDue, err)
+	}
+
+	content := ""
+	if c.userIDToPing != "" {
+		content += fmt.Sprintf("<@%s> ", c.userIDToPing)
+	}
+	content += fmt.Sprintf("🩸 **Lab Test Reminder: %s** 🩸\n", test.Name)
+	content += fmt.Sprintf("Your %s check for %s is %s (%s). Record the result once you have it.",
+		test.Name, test.Medication, describeDueDate(due, time.Now().In(c.location)), test.NextDue)
+
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    fmt.Sprintf("Record %s result", test.Name),
+					Style:    discordgo.PrimaryButton,
+					CustomID: c.customID(labRecordAction, strconv.FormatInt(test.ID, 10)),
+					Emoji: &discordgo.ComponentEmoji{
+						Name: "🩸",
+					},
+				},
+			},
+		},
+	}
+
+	_, err = c.session.Load().ChannelMessageSendComplex(c.channelID, &discordgo.MessageSend{
+		Content:    content,
+		Components: components,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send lab test reminder message: %w", err)
+	}
+
+	return nil
+}
+
+// registerLabTestHandlers registers the handlers for recording lab test results from reminders
+func (c *Client) registerLabTestHandlers(ctx context.Context) {
+	// The button opens a modal to enter the result value
+	c.RegisterHandler(labRecordAction, func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+		testID := args[0]
+
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: &discordgo.InteractionResponseData{
+				CustomID: c.customID(labSubmitAction, testID),
+				Title:    "Record lab result",
+				Components: []discordgo.MessageComponent{
+					discordgo.ActionsRow{
+						Components: []discordgo.MessageComponent{
+							discordgo.TextInput{
+								CustomID:    "value",
+								Label:       "Result value",
+								Style:       discordgo.TextInputShort,
+								Placeholder: "e.g. 2.5",
+								Required:    true,
+								MaxLength:   20,
+							},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			log.Printf("Error opening lab result modal: %v", err)
+		}
+	})
+
+	c.RegisterHandler(labSubmitAction, func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+		data := i.ModalSubmitData()
+
+		testID, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			log.Printf("Invalid lab result modal ID: %s", data.CustomID)
+			return
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(modalValue(data, "value")), 64)
+		if err != nil {
+			c.respondWithError(s, i, "The result must be a number")
+			return
+		}
+
+		tests, err := c.storeFor(ctx).ListLabTests(ctx)
+		if err != nil {
+			c.respondWithFailure(s, i, "Error listing lab tests", err)
+			return
+		}
+
+		for _, test := range tests {
+			if test.ID == testID {
+				if err := c.recordLabResult(ctx, &test, value); err != nil {
+					c.respondWithFailure(s, i, fmt.Sprintf("Error recording %s result", test.Name), err)
+					return
+				}
+
+				if i.Message != nil {
+					content := fmt.Sprintf("✅ **%s Result Recorded** ✅\n%s %s. The next test is due on %s.", test.Name, formatLabValue(value), test.Unit, test.NextDue)
+					_, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+						Channel:    c.channelID,
+						ID:         i.Message.ID,
+						Content:    &content,
+						Components: &[]discordgo.MessageComponent{},
+					})
+					if err != nil {
+						log.Printf("Error updating lab test message for %s: %v", test.Name, err)
+					}
+				}
+
+				c.respond(s, i, fmt.Sprintf("Recorded %s result of %s %s. The next test is due on %s.", test.Name, formatLabValue(value), test.Unit, test.NextDue))
+				return
+			}
+		}
+
+		c.respondWithError(s, i, "That lab test no longer exists")
+	})
+}
+
+// recordLabResult records a lab test result and schedules the next test
+func (c *Client) recordLabResult(ctx context.Context, test *db.LabTest, value float64) error {
+	now := time.Now().In(c.location)
+
+	if err := c.storeFor(ctx).RecordLabResult(ctx, &db.LabResult{
+		TestID: test.ID,
+		Date:   now.Format("2006-01-02"),
+		Value:  value,
+	}); err != nil {
+		return err
+	}
+
+	test.NextDue = now.AddDate(0, 0, test.IntervalDays).Format("2006-01-02")
+	test.RemindedOn = ""
+
+	return c.storeFor(ctx).SaveLabTest(ctx, test)
+}
+
+// handleLabTestCommand adds or updates a recurring lab test linked to a medication
+func (c *Client) handleLabTestCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	medication := options["medication"].StringValue()
+	if !c.hasBaseMedication(medication) {
+		c.respondWithError(s, i, fmt.Sprintf("Unknown medication: %s", medication))
+		return
+	}
+
+	test := &db.LabTest{
+		Name:         strings.TrimSpace(options["test"].StringValue()),
+		Medication:   medication,
+		IntervalDays: int(options["interval_days"].IntValue()),
+		NextDue:      time.Now().In(c.location).Format("2006-01-02"),
+	}
+	if test.Name == "" {
+		c.respondWithError(s, i, "Lab test name is required")
+		return
+	}
+	if test.IntervalDays < 1 {
+		c.respondWithError(s, i, "Interval must be at least 1 day")
+		return
+	}
+
+	if option, ok := options["next_due"]; ok {
+		test.NextDue = option.StringValue()
+		if _, err := time.Parse("2006-01-02", test.NextDue); err != nil {
+			c.respondWithError(s, i, "Next due date must be in the format YYYY-MM-DD")
+			return
+		}
+	}
+	if option, ok := options["unit"]; ok {
+		test.Unit = option.StringValue()
+	}
+
+	if err := c.storeFor(ctx).SaveLabTest(ctx, test); err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error saving lab test %s", test.Name), err)
+		return
+	}
+
+	c.respond(s, i, fmt.Sprintf("%s checks for %s are due every %d days, next on %s.", test.Name, medication, test.IntervalDays, test.NextDue))
+}
+
+// handleLabResultCommand records a lab test result
+func (c *Client) handleLabResultCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	name := options["test"].StringValue()
+
+	test, err := c.storeFor(ctx).GetLabTest(ctx, name)
+	if err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error getting lab test %s", name), err)
+		return
+	}
+	if test == nil {
+		c.respondWithError(s, i, fmt.Sprintf("Unknown lab test: %s. Add it with `/meds labtest`.", name))
+		return
+	}
+
+	value := options["value"].FloatValue()
+	if err := c.recordLabResult(ctx, test, value); err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error recording %s result", test.Name), err)
+		return
+	}
+
+	c.respond(s, i, fmt.Sprintf("Recorded %s result of %s %s. The next test is due on %s.", test.Name, formatLabValue(value), test.Unit, test.NextDue))
+}
+
+// handleLabChartCommand charts a lab test's recent results
+func (c *Client) handleLabChartCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	name := options["test"].StringValue()
+
+	test, err := c.storeFor(ctx).GetLabTest(ctx, name)
+	if err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error getting lab test %s", name), err)
+		return
+	}
+	if test == nil {
+		c.respondWithError(s, i, fmt.Sprintf("Unknown lab test: %s", name))
+		return
+	}
+
+	results, err := c.storeFor(ctx).GetLabResults(ctx, test.ID, labChartResults)
+	if err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error getting %s results", test.Name), err)
+		return
+	}
+
+	if len(results) == 0 {
+		c.respond(s, i, fmt.Sprintf("No %s results have been recorded yet.", test.Name))
+		return
+	}
+
+	var history strings.Builder
+	for _, result := range results {
+		history.WriteString(fmt.Sprintf("%s: %s %s\n", result.Date, formatLabValue(result.Value), test.Unit))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🩸 %s (%s)", test.Name, test.Medication),
+		Description: fmt.Sprintf("`%s`", sparkline(results)),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Results", Value: history.String()},
+			embedField("Next due", test.NextDue),
+			embedField("Interval", fmt.Sprintf("%d days", test.IntervalDays)),
+		},
+	}
+
+	c.respondWithEmbed(s, i, embed)
+}
+
+// sparkline draws a text chart of lab results scaled between their minimum and maximum values
+func sparkline(results []db.LabResult) string {
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for _, result := range results {
+		minValue = math.Min(minValue, result.Value)
+		maxValue = math.Max(maxValue, result.Value)
+	}
+
+	var chart strings.Builder
+	for _, result := range results {
+		index := len(sparkBlocks) / 2
+		if maxValue > minValue {
+			index = int((result.Value - minValue) / (maxValue - minValue) * float64(len(sparkBlocks)-1))
+		}
+		chart.WriteRune(sparkBlocks[index])
+	}
+
+	return chart.String()
+}
+
+// formatLabValue formats a lab result without trailing zeros
+func formatLabValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// modalValue returns the value of a text input in a submitted modal
+func modalValue(data discordgo.ModalSubmitInteractionData, customID string) string {
+	for _, row := range data.Components {
+		actionsRow, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range actionsRow.Components {
+			if input, ok := component.(*discordgo.TextInput); ok && input.CustomID == customID {
+				return input.Value
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"meds-bot/internal/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// isAdmin checks the user can change how the bot runs, such as its log levels, which needs the Manage
+// Server permission in a guild. By DM, only the user reminders are for can change it.
+func (c *Client) isAdmin(i *discordgo.InteractionCreate) bool {
+	if i.Member == nil {
+		return i.User != nil && c.userIDToPing != "" && i.User.ID == c.userIDToPing
+	}
+	return i.Member.Permissions&discordgo.PermissionManageServer != 0
+}
+
+// handleLogLevelCommand changes the default log level, or one component's level, until the bot restarts
+func (c *Client) handleLogLevelCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	if c.logFilter == nil {
+		c.respondWithError(s, i, "Log levels can't be changed while the bot is running")
+		return
+	}
+	if !c.isAdmin(i) {
+		c.respondWithError(s, i, "You need the Manage Server permission to change log levels")
+		return
+	}
+
+	level, err := logging.ParseLevel(options["level"].StringValue())
+	if err != nil {
+		c.respondWithError(s, i, err.Error())
+		return
+	}
+
+	if option, ok := options["component"]; ok {
+		component := strings.ToLower(strings.TrimSpace(option.StringValue()))
+		c.logFilter.SetComponentLevel(component, level)
+		log.Printf("Log level for %s changed to %s", component, level)
+		c.respond(s, i, fmt.Sprintf("Logging %s at %s and above until the bot restarts.", component, level))
+		return
+	}
+
+	c.logFilter.SetLevel(level)
+	log.Printf("Log level changed to %s", level)
+
+	message := fmt.Sprintf("Logging at %s and above until the bot restarts.", level)
+	if _, levels := c.logFilter.Levels(); len(levels) > 0 {
+		message += fmt.Sprintf(" Components with their own level are unchanged: %s", logging.FormatLevels(levels))
+	}
+	c.respond(s, i, message)
+}
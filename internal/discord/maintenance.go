@@ -0,0 +1,76 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maintenanceStateKey records whether maintenance mode is on, so it survives moving the bot to a new host
+const maintenanceStateKey = "maintenance_mode"
+
+// InMaintenance reports whether sending is paused because maintenance mode is on
+func (c *Client) InMaintenance() bool {
+	c.accessMu.Lock()
+	defer c.accessMu.Unlock()
+	return c.maintenance
+}
+
+// loadMaintenance restores maintenance mode after a restart
+func (c *Client) loadMaintenance(ctx context.Context) error {
+	value, err := c.store.GetState(ctx, maintenanceStateKey)
+	if err != nil {
+		return fmt.Errorf("failed to get maintenance mode: %w", err)
+	}
+
+	if value == "on" {
+		log.Printf("Warning: Maintenance mode is on, reminders are paused until it's turned off with /meds maintenance")
+		c.accessMu.Lock()
+		c.maintenance = true
+		c.accessMu.Unlock()
+	}
+
+	return nil
+}
+
+// handleMaintenanceCommand turns maintenance mode on or off. While it's on nothing is posted in the
+// reminder channel, and reminders that fall due are sent once it's turned off, one per dose rather
+// than every nag that would have been sent in the meantime.
+func (c *Client) handleMaintenanceCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	if !c.isAdmin(i) {
+		c.respondWithError(s, i, "You need the Manage Server permission to change maintenance mode")
+		return
+	}
+
+	on := options["mode"].StringValue() == "on"
+	if on == c.InMaintenance() {
+		c.respond(s, i, fmt.Sprintf("Maintenance mode is already %s.", options["mode"].StringValue()))
+		return
+	}
+
+	value := ""
+	if on {
+		value = "on"
+	}
+	if err := c.store.SetState(ctx, maintenanceStateKey, value); err != nil {
+		c.respondWithFailure(s, i, "Error saving maintenance mode", err)
+		return
+	}
+
+	c.accessMu.Lock()
+	c.maintenance = on
+	c.accessMu.Unlock()
+
+	notice := "✅ **Maintenance over**: reminders have resumed, and any that fell due will be sent shortly."
+	if on {
+		notice = "🛠️ **Maintenance**: reminders are paused while the bot is being maintained. Any that fall due will be sent once it's over."
+	}
+	log.Printf("Maintenance mode turned %s", options["mode"].StringValue())
+	if _, err := c.session.Load().ChannelMessageSend(c.channelID, notice); err != nil {
+		log.Printf("Error posting maintenance notice: %v", err)
+	}
+
+	c.respond(s, i, fmt.Sprintf("Maintenance mode is %s.", options["mode"].StringValue()))
+}
@@ -0,0 +1,108 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"meds-bot/internal/metrics"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord API metrics, by operation (send, edit, delete, respond or other) and error class
+var (
+	apiLatency = metrics.Default.NewHistogram("discord_api_request_duration_seconds",
+		"Latency of Discord API requests.", metrics.DefaultBuckets, "operation")
+	apiErrors = metrics.Default.NewCounter("discord_api_errors_total",
+		"Failed Discord API requests, by class: rate_limited, permission_denied, unknown_message, unknown_channel, client_error, server_error or network.",
+		"operation", "class")
+)
+
+// instrumentedTransport records the latency and errors of the Discord API requests it sends
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+// instrument records metrics for every API request the session sends
+func instrument(session *discordgo.Session) {
+	next := session.Client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	session.Client.Transport = &instrumentedTransport{next: next}
+}
+
+// RoundTrip sends a request, recording its latency and, if it failed, its error class
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	operation := apiOperation(req)
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	apiLatency.Observe(time.Since(start).Seconds(), operation)
+
+	if err != nil {
+		apiErrors.Inc(operation, "network")
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		apiErrors.Inc(operation, errorClass(resp))
+	}
+
+	return resp, nil
+}
+
+// apiOperation names the operation a request performs on messages, so Discord's latency can be
+// compared between sending, editing and deleting them
+func apiOperation(req *http.Request) string {
+	path := req.URL.Path
+	switch {
+	case strings.Contains(path, "/interactions/"):
+		return "respond"
+	case strings.HasSuffix(path, "/messages") && req.Method == http.MethodPost:
+		return "send"
+	case strings.Contains(path, "/messages/") && req.Method == http.MethodPatch:
+		return "edit"
+	case strings.Contains(path, "/messages/") && req.Method == http.MethodDelete:
+		return "delete"
+	default:
+		return "other"
+	}
+}
+
+// errorClass classifies a failed response by its status and Discord's JSON error code,
+// restoring the body so discordgo can still read it
+func errorClass(resp *http.Response) string {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return "rate_limited"
+	case resp.StatusCode >= 500:
+		return "server_error"
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return "client_error"
+	}
+
+	var apiErr struct {
+		Code int `json:"code"`
+	}
+	json.Unmarshal(body, &apiErr)
+
+	switch {
+	case apiErr.Code == discordgo.ErrCodeMissingPermissions, apiErr.Code == discordgo.ErrCodeMissingAccess, resp.StatusCode == http.StatusForbidden:
+		return "permission_denied"
+	case apiErr.Code == discordgo.ErrCodeUnknownMessage:
+		return "unknown_message"
+	case apiErr.Code == discordgo.ErrCodeUnknownChannel:
+		return "unknown_channel"
+	default:
+		return "client_error"
+	}
+}
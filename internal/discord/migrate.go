@@ -0,0 +1,138 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+)
+
+// channelStateKey records the channel reminders were last posted in, so a change of channel can be detected
+const channelStateKey = "reminder_channel_id"
+
+// MigrateChannel moves pending reminders to the reminder channel if it changed since the bot last ran.
+// Pending reminder messages are deleted from the old channel, and today's reminders for configured
+// medications are re-posted in the new one so they can still be acknowledged.
+func (c *Client) MigrateChannel(ctx context.Context) error {
+	previous, err := c.store.GetState(ctx, channelStateKey)
+	if err != nil {
+		return fmt.Errorf("failed to get previous reminder channel: %w", err)
+	}
+	if previous == c.channelID {
+		return nil
+	}
+
+	if previous != "" {
+		log.Printf("Reminder channel changed from %s to %s, moving pending reminders", previous, c.channelID)
+		if err := c.moveReminders(ctx, previous); err != nil {
+			return err
+		}
+	}
+
+	if err := c.store.SetState(ctx, channelStateKey, c.channelID); err != nil {
+		return fmt.Errorf("failed to save reminder channel: %w", err)
+	}
+
+	return nil
+}
+
+// moveReminders deletes pending reminder messages from the previous channel and re-posts active ones.
+// Failures for individual messages are logged rather than returned, since retrying the whole move on
+// the next restart would post duplicates of the reminders that were already moved.
+func (c *Client) moveReminders(ctx context.Context, previous string) error {
+	if c.reminderMode == config.ReminderModeChecklist {
+		return c.moveChecklist(ctx, previous)
+	}
+
+	now := time.Now().In(c.location)
+	today := now.Format("2006-01-02")
+
+	reminders, err := c.store.GetReminderHistory(ctx, "", now.AddDate(0, 0, -buttonRefreshDays))
+	if err != nil {
+		return fmt.Errorf("failed to get recent reminders: %w", err)
+	}
+
+	ticker := time.NewTicker(buttonRefreshDelay)
+	defer ticker.Stop()
+
+	moved := 0
+	for _, reminder := range reminders {
+		if reminder.Resolved() || reminder.MessageID == "" {
+			continue
+		}
+
+		if moved > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		moved++
+
+		if err := c.moveReminder(ctx, reminder, previous, today); err != nil {
+			log.Printf("Error moving reminder for %s on %s [dose %s]: %v", reminder.MedicationType, reminder.Date, reminder.CorrelationID, err)
+		}
+	}
+
+	if moved > 0 {
+		log.Printf("Moved %d pending reminder messages to channel %s", moved, c.channelID)
+	}
+
+	return nil
+}
+
+// moveReminder deletes a pending reminder's message from the previous channel, re-posting it in the
+// new channel if it's for a dose due today. Heads-ups aren't re-posted since the reminder follows shortly.
+func (c *Client) moveReminder(ctx context.Context, reminder db.Reminder, previous, today string) error {
+	if err := c.removeMessage(previous, reminder.MessageID); err != nil {
+		// The old channel may be gone, so the reminder is moved anyway
+		log.Printf("Error deleting reminder message %s from channel %s: %v", reminder.MessageID, previous, err)
+	}
+
+	messageID := ""
+	if reminder.Date == today && reminder.NagCount > 0 && c.hasMedication(reminder.MedicationType) {
+		var err error
+		messageID, err = c.SendReminder(ctx, c.medicationByName(reminder.MedicationType), ReminderOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to re-post reminder: %w", err)
+		}
+	}
+
+	if err := c.store.MoveReminderMessage(ctx, reminder.ID, messageID); err != nil {
+		return fmt.Errorf("failed to update reminder message: %w", err)
+	}
+
+	return nil
+}
+
+// moveChecklist deletes today's checklist from the previous channel and posts it in the new one
+func (c *Client) moveChecklist(ctx context.Context, previous string) error {
+	messageID, err := c.store.GetTodayChecklist(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get today's checklist: %w", err)
+	}
+	if messageID == "" {
+		return nil
+	}
+
+	if err := c.removeMessage(previous, messageID); err != nil {
+		log.Printf("Error deleting checklist message %s from channel %s: %v", messageID, previous, err)
+	}
+
+	messageID, err = c.SendChecklist(ctx)
+	if err != nil {
+		log.Printf("Error re-posting checklist: %v", err)
+		return nil
+	}
+	if err := c.store.SaveTodayChecklist(ctx, messageID); err != nil {
+		log.Printf("Error saving checklist: %v", err)
+		return nil
+	}
+
+	log.Printf("Moved today's checklist to channel %s", c.channelID)
+	return nil
+}
@@ -0,0 +1,148 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"meds-bot/internal/db"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// defaultMissedDays is how many days back /meds missed looks by default
+	defaultMissedDays = 7
+	// maxMissedDoses is the most missed doses listed, as embeds hold at most 25 fields
+	maxMissedDoses = 25
+)
+
+// minMissedDays and maxMissedDays bound the days option of /meds missed
+var minMissedDays, maxMissedDays = 1.0, 90.0
+
+// missedDose is a dose recorded as missed, with the events logged for it
+type missedDose struct {
+	medication string
+	date       string
+	reminders  []time.Time
+	// failed counts the reminders that failed to send
+	failed int
+	// acknowledged is set if the dose was marked as taken after it was recorded as missed
+	acknowledged *db.DoseEvent
+}
+
+// handleMissedCommand lists the doses recorded as missed, with when they were due and every
+// reminder sent for them, so a dose that's believed to have been taken can be checked
+func (c *Client) handleMissedCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	days := defaultMissedDays
+	if option, ok := options["days"]; ok {
+		days = int(option.IntValue())
+	}
+
+	since := time.Now().In(c.location).AddDate(0, 0, -days)
+	doseEvents, err := c.storeFor(ctx).GetDoseEvents(ctx, "", since)
+	if err != nil {
+		c.respondWithFailure(s, i, "Error getting dose events", err)
+		return
+	}
+
+	missed := missedDoses(doseEvents)
+	if len(missed) == 0 {
+		c.respond(s, i, fmt.Sprintf("No doses were recorded as missed in the last %d days.", days))
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("❌ Missed Doses: Last %d Days", days),
+	}
+	if len(missed) > maxMissedDoses {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Showing the latest %d of %d missed doses", maxMissedDoses, len(missed))}
+		missed = missed[len(missed)-maxMissedDoses:]
+	}
+
+	for _, dose := range missed {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("%s on %s", dose.medication, dose.date),
+			Value: c.formatMissedDose(dose),
+		})
+	}
+
+	c.respondWithEmbed(s, i, embed)
+}
+
+// formatMissedDose describes when a missed dose was due, the reminders sent and any late acknowledgment
+func (c *Client) formatMissedDose(dose missedDose) string {
+	var b strings.Builder
+
+	if c.hasMedication(dose.medication) {
+		fmt.Fprintf(&b, "Scheduled for %s\n", c.medicationByName(dose.medication).Clock())
+	}
+
+	times := make([]string, len(dose.reminders))
+	for i, sent := range dose.reminders {
+		times[i] = sent.In(c.location).Format("15:04")
+	}
+	switch len(times) {
+	case 0:
+		b.WriteString("No reminders were logged\n")
+	case 1:
+		fmt.Fprintf(&b, "1 reminder sent at %s\n", times[0])
+	default:
+		fmt.Fprintf(&b, "%d reminders sent at %s\n", len(times), strings.Join(times, ", "))
+	}
+
+	switch dose.failed {
+	case 0:
+	case 1:
+		b.WriteString("1 reminder failed to send\n")
+	default:
+		fmt.Fprintf(&b, "%d reminders failed to send\n", dose.failed)
+	}
+
+	if ack := dose.acknowledged; ack != nil {
+		fmt.Fprintf(&b, "Marked as taken later, at %s via %s", ack.CreatedAt.In(c.location).Format("2 Jan 15:04"), ack.Source)
+	} else {
+		b.WriteString("Never marked as taken")
+	}
+
+	return b.String()
+}
+
+// missedDoses groups a dose event log into the doses recorded as missed, in the order they were missed
+func missedDoses(doseEvents []db.DoseEvent) []missedDose {
+	type key struct{ date, medication string }
+
+	doses := make(map[key]*missedDose)
+	var order []key
+
+	for _, event := range doseEvents {
+		k := key{event.Date, event.Medication}
+		dose, ok := doses[k]
+		if !ok {
+			dose = &missedDose{medication: event.Medication, date: event.Date}
+			doses[k] = dose
+		}
+
+		switch event.Type {
+		case db.DoseEventReminded:
+			dose.reminders = append(dose.reminders, event.CreatedAt)
+		case db.DoseEventUndelivered:
+			dose.failed++
+		case db.DoseEventMissed:
+			// A restart can record the same missed dose again
+			if !slices.Contains(order, k) {
+				order = append(order, k)
+			}
+		case db.DoseEventAcknowledged:
+			dose.acknowledged = &event
+		}
+	}
+
+	missed := make([]missedDose, len(order))
+	for i, k := range order {
+		missed[i] = *doses[k]
+	}
+	return missed
+}
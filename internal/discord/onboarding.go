@@ -0,0 +1,258 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"meds-bot/internal/db"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom ID actions of the onboarding components, whose arguments are the guild ID and, when
+// onboarding by DM, the ID of the user who added the bot
+const (
+	onboardChannelAction = "onboard_channel"
+	onboardDetailsAction = "onboard_details"
+	onboardSubmitAction  = "onboard_submit"
+)
+
+// handleGuildCreate starts onboarding when the bot is added to a guild it hasn't been set up in.
+// GuildCreate is also sent for every guild on connecting, which are skipped once onboarding has started.
+func (c *Client) handleGuildCreate(ctx context.Context, s *discordgo.Session, g *discordgo.GuildCreate) {
+	if g.Unavailable || g.ID == c.homeGuildID(s) {
+		return
+	}
+
+	settings, err := c.store.GetGuildSettings(ctx, g.ID)
+	if err != nil {
+		log.Printf("Error getting settings for guild %s: %v", g.ID, err)
+		return
+	}
+	if settings != nil {
+		return
+	}
+
+	// Record the guild first so onboarding isn't started again on reconnecting
+	if err := c.store.SaveGuildSettings(ctx, &db.GuildSettings{GuildID: g.ID}); err != nil {
+		log.Printf("Error saving settings for guild %s: %v", g.ID, err)
+		return
+	}
+
+	channelID, userID, err := c.onboardingChannel(s, g.Guild)
+	if err != nil {
+		log.Printf("Error finding where to onboard guild %s: %v", g.ID, err)
+		return
+	}
+
+	message := &discordgo.MessageSend{
+		Content:    fmt.Sprintf("👋 Thanks for adding me to **%s**! Pick the channel reminders should be posted in, then set your timezone and first medication.", g.Name),
+		Components: c.onboardingComponents(g.ID, userID),
+	}
+
+	if _, err := s.ChannelMessageSendComplex(channelID, message); err != nil {
+		log.Printf("Error sending onboarding message for guild %s: %v", g.ID, err)
+	}
+}
+
+// homeGuildID returns the guild of the configured channel, which doesn't need onboarding
+func (c *Client) homeGuildID(s *discordgo.Session) string {
+	channel, err := s.State.Channel(c.channelID)
+	if err != nil {
+		channel, err = s.Channel(c.channelID)
+	}
+	if err != nil {
+		return ""
+	}
+	return channel.GuildID
+}
+
+// onboardingChannel returns a DM channel with the user who added the bot, found in the audit log,
+// along with their user ID, or the guild's system channel and no user ID if they can't be found
+func (c *Client) onboardingChannel(s *discordgo.Session, guild *discordgo.Guild) (string, string, error) {
+	auditLog, err := s.GuildAuditLog(guild.ID, "", "", int(discordgo.AuditLogActionBotAdd), 1)
+	if err == nil && len(auditLog.AuditLogEntries) > 0 {
+		userID := auditLog.AuditLogEntries[0].UserID
+		dm, err := s.UserChannelCreate(userID)
+		if err == nil {
+			return dm.ID, userID, nil
+		}
+		log.Printf("Error opening DM with the user who added the bot to guild %s: %v", guild.ID, err)
+	}
+
+	if guild.SystemChannelID == "" {
+		return "", "", fmt.Errorf("the inviter can't be messaged and the guild has no system channel")
+	}
+
+	return guild.SystemChannelID, "", nil
+}
+
+// onboardingComponents returns the channel picker and the button that opens the details modal
+func (c *Client) onboardingComponents(guildID, userID string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					MenuType:     discordgo.ChannelSelectMenu,
+					CustomID:     c.customID(onboardChannelAction, guildID, userID),
+					Placeholder:  "Reminder channel",
+					ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+				},
+			},
+		},
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Set timezone and first medication",
+					Style:    discordgo.PrimaryButton,
+					CustomID: c.customID(onboardDetailsAction, guildID, userID),
+				},
+			},
+		},
+	}
+}
+
+// registerOnboardingHandlers registers the handlers for the onboarding components
+func (c *Client) registerOnboardingHandlers(ctx context.Context) {
+	c.RegisterHandler(onboardChannelAction, func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+		data := i.MessageComponentData()
+		guildID := args[0]
+		if !canOnboard(i, args[1]) || len(data.Values) == 0 {
+			c.respondWithError(s, i, "You need the Manage Server permission to set up the bot")
+			return
+		}
+
+		if err := validateChannel(s, data.Values[0]); err != nil {
+			c.respondWithError(s, i, fmt.Sprintf("That channel can't be used: %v", err))
+			return
+		}
+
+		settings, err := c.guildSettings(ctx, guildID)
+		if err != nil {
+			c.respondWithFailure(s, i, fmt.Sprintf("Error getting settings for guild %s", guildID), err)
+			return
+		}
+
+		settings.ChannelID = data.Values[0]
+		c.saveOnboarding(ctx, s, i, settings, fmt.Sprintf("Reminders will be posted in <#%s>.", settings.ChannelID))
+	})
+
+	c.RegisterHandler(onboardDetailsAction, func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+		guildID := args[0]
+		if !canOnboard(i, args[1]) {
+			c.respondWithError(s, i, "You need the Manage Server permission to set up the bot")
+			return
+		}
+
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: &discordgo.InteractionResponseData{
+				CustomID: c.customID(onboardSubmitAction, guildID, args[1]),
+				Title:    "Set up reminders",
+				Components: []discordgo.MessageComponent{
+					textInputRow("timezone", "Timezone", "e.g. Europe/London", 64),
+					textInputRow("medication", "First medication", "e.g. Vitamin D", 100),
+					textInputRow("hour", "Hour to take it (0-23)", "e.g. 9", 2),
+				},
+			},
+		})
+		if err != nil {
+			log.Printf("Error opening onboarding modal: %v", err)
+		}
+	})
+
+	c.RegisterHandler(onboardSubmitAction, func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+		data := i.ModalSubmitData()
+		guildID := args[0]
+		if !canOnboard(i, args[1]) {
+			c.respondWithError(s, i, "You need the Manage Server permission to set up the bot")
+			return
+		}
+
+		timezone := strings.TrimSpace(modalValue(data, "timezone"))
+		if _, err := time.LoadLocation(timezone); err != nil || timezone == "" {
+			c.respondWithError(s, i, fmt.Sprintf("%q isn't a valid timezone, use a name like Europe/London", timezone))
+			return
+		}
+
+		hour, err := strconv.Atoi(strings.TrimSpace(modalValue(data, "hour")))
+		if err != nil || hour < 0 || hour > 23 {
+			c.respondWithError(s, i, "The hour must be a number from 0 to 23")
+			return
+		}
+
+		settings, err := c.guildSettings(ctx, guildID)
+		if err != nil {
+			c.respondWithFailure(s, i, fmt.Sprintf("Error getting settings for guild %s", guildID), err)
+			return
+		}
+
+		settings.Timezone = timezone
+		settings.Medication = strings.TrimSpace(modalValue(data, "medication"))
+		settings.MedicationHour = hour
+		c.saveOnboarding(ctx, s, i, settings, fmt.Sprintf("%s will be reminded at %02d:00 %s.", settings.Medication, hour, timezone))
+	})
+}
+
+// guildSettings returns a guild's settings, or new settings if it has none. Every guild's settings are kept
+// in the default tenant, since they're how the bot knows which guilds are its tenants.
+func (c *Client) guildSettings(ctx context.Context, guildID string) (*db.GuildSettings, error) {
+	settings, err := c.store.GetGuildSettings(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = &db.GuildSettings{GuildID: guildID}
+	}
+	return settings, nil
+}
+
+// saveOnboarding saves a guild's settings and tells the user what's left to set up
+func (c *Client) saveOnboarding(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, settings *db.GuildSettings, saved string) {
+	settings.Completed = settings.ChannelID != "" && settings.Timezone != "" && settings.Medication != ""
+
+	if err := c.store.SaveGuildSettings(ctx, settings); err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error saving settings for guild %s", settings.GuildID), err)
+		return
+	}
+
+	switch {
+	case settings.Completed:
+		saved += " ✅ Setup is complete!"
+	case settings.ChannelID == "":
+		saved += " Now pick the reminder channel."
+	default:
+		saved += " Now set your timezone and first medication."
+	}
+
+	c.respond(s, i, saved)
+}
+
+// canOnboard checks the user can set up the bot, which needs the Manage Server permission
+// in a guild. Onboarding sent by DM can only be used by the user who added the bot, inviterID.
+func canOnboard(i *discordgo.InteractionCreate, inviterID string) bool {
+	if i.Member == nil {
+		return i.User != nil && inviterID != "" && i.User.ID == inviterID
+	}
+	return i.Member.Permissions&discordgo.PermissionManageServer != 0
+}
+
+// textInputRow returns a row with a single required text input
+func textInputRow(customID, label, placeholder string, maxLength int) discordgo.ActionsRow {
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.TextInput{
+				CustomID:    customID,
+				Label:       label,
+				Style:       discordgo.TextInputShort,
+				Placeholder: placeholder,
+				Required:    true,
+				MaxLength:   maxLength,
+			},
+		},
+	}
+}
@@ -0,0 +1,198 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxPhotoBytes is the largest dose photo accepted, which is Discord's own upload limit
+const maxPhotoBytes = 25 << 20
+
+// photoClient downloads dose photos to keep them
+var photoClient = &http.Client{Timeout: 30 * time.Second}
+
+// handlePhotoCommand records a photo of today's dose of a medication. A dose that isn't recorded yet is
+// recorded as taken, unless it still needs confirming by others.
+func (c *Client) handlePhotoCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	name := options["name"].StringValue()
+	if !c.hasMedication(name) {
+		c.respondWithError(s, i, fmt.Sprintf("Unknown medication: %s", name))
+		return
+	}
+
+	medication := c.medicationByName(name)
+	if medication.PhotoProof == "" {
+		c.respond(s, i, fmt.Sprintf("%s doesn't ask for photos of its doses.", name))
+		return
+	}
+	// Whoever supervises the dose, such as a guardian, can add the photo
+	userID := interactionUserID(i)
+	if !canTake(medication, userID) && !slices.Contains(medication.ConfirmingUsers(), userID) {
+		c.respond(s, i, fmt.Sprintf("%s is someone else's medication, so you can't add a photo of it.", name))
+		return
+	}
+
+	var attachment *discordgo.MessageAttachment
+	if resolved := i.ApplicationCommandData().Resolved; resolved != nil {
+		attachment = resolved.Attachments[fmt.Sprint(options["photo"].Value)]
+	}
+	if attachment == nil || !strings.HasPrefix(attachment.ContentType, "image/") {
+		c.respondWithError(s, i, "Please attach a photo of your dose")
+		return
+	}
+	if attachment.Size > maxPhotoBytes {
+		c.respondWithError(s, i, "That photo is too large, please attach a smaller one")
+		return
+	}
+
+	// Downloading the photo may take longer than Discord waits for a response
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	}); err != nil {
+		log.Printf("Error sending deferred response: %v", err)
+	}
+
+	photo, err := downloadPhoto(ctx, attachment.URL, attachment.ContentType)
+	if err != nil {
+		c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error downloading photo of %s", name), err))
+		return
+	}
+
+	reminder, err := c.storeFor(ctx).GetTodayReminder(ctx, name)
+	if err != nil {
+		c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error getting reminder for %s", name), err))
+		return
+	}
+	if err := c.storeFor(ctx).RecordDoseProof(ctx, reminder.ID, photo); err != nil {
+		c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error saving photo of %s", name), err))
+		return
+	}
+	log.Printf("Recorded photo of %s from %s [dose %s]", name, userID, reminder.CorrelationID)
+
+	if reminder.Acknowledged {
+		c.editDeferred(s, i, fmt.Sprintf("📷 Thank you! The photo has been added to today's %s.", name))
+		return
+	}
+	if medication.RequiredConfirmations() > 1 {
+		c.editDeferred(s, i, fmt.Sprintf("📷 Thank you! The photo has been added, and today's %s will be recorded once it's been confirmed.", name))
+		return
+	}
+
+	c.takePhotographedDose(ctx, s, i, medication)
+}
+
+// takePhotographedDose marks today's dose of a medication as taken once a photo of it has been added,
+// replying to the deferred interaction
+func (c *Client) takePhotographedDose(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, medication config.Medication) {
+	// The dose is recorded against the user who took it, whoever added the photo
+	reminder, alreadyTaken, err := c.acknowledgeReminder(ctx, medication.Name, c.userFor(medication.Name), nil, time.Time{}, func(reminder *db.Reminder) string {
+		return reminder.MessageID
+	})
+	if err != nil {
+		c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error acknowledging %s", medication.Name), err))
+		return
+	}
+	if alreadyTaken {
+		c.editDeferred(s, i, fmt.Sprintf("📷 Thank you! The photo has been added to today's %s.", medication.Name))
+		return
+	}
+
+	if reminder.MessageID != "" {
+		if err := c.markMessageTaken(ctx, medication.Name, reminder.MessageID); err != nil {
+			log.Printf("Error marking %s as taken [dose %s]: %v", medication.Name, reminder.CorrelationID, err)
+		}
+	}
+	c.publishAcknowledged(ctx, medication.Name, reminder, "Discord, with a photo")
+
+	c.editDeferred(s, i, fmt.Sprintf("📷 Thank you for taking your %s! It's been recorded with its photo.", medication.Name))
+}
+
+// downloadPhoto downloads a photo to keep with the recorded dose, since Discord's link to it expires
+func downloadPhoto(ctx context.Context, url, contentType string) (*db.DosePhoto, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := photoClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download photo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download photo: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPhotoBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download photo: %w", err)
+	}
+	if len(data) > maxPhotoBytes {
+		return nil, fmt.Errorf("photo is larger than %d bytes", maxPhotoBytes)
+	}
+
+	return &db.DosePhoto{ContentType: contentType, Data: data}, nil
+}
+
+// handleRedactCommand deletes the photo of a dose of a medication, today's by default, while keeping the
+// record of the dose itself
+func (c *Client) handleRedactCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	name := options["name"].StringValue()
+	if !c.hasMedication(name) {
+		c.respondWithError(s, i, fmt.Sprintf("Unknown medication: %s", name))
+		return
+	}
+
+	medication := c.medicationByName(name)
+	userID := interactionUserID(i)
+	if !canTake(medication, userID) && !slices.Contains(medication.ConfirmingUsers(), userID) {
+		c.respond(s, i, fmt.Sprintf("%s is someone else's medication, so you can't remove its photos.", name))
+		return
+	}
+
+	// Today is where the medication is taken
+	date := c.doseDate(name)
+	if option, ok := options["date"]; ok {
+		date = option.StringValue()
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			c.respondWithError(s, i, "Date must be in the format YYYY-MM-DD")
+			return
+		}
+	}
+
+	reminders, err := c.storeFor(ctx).GetRemindersForDate(ctx, date)
+	if err != nil {
+		c.respondWithFailure(s, i, fmt.Sprintf("Error getting doses of %s", name), err)
+		return
+	}
+
+	for _, reminder := range reminders {
+		if reminder.MedicationType != name || reminder.ProofHash == "" {
+			continue
+		}
+
+		if err := c.storeFor(ctx).RecordDoseProof(ctx, reminder.ID, nil); err != nil {
+			c.respondWithFailure(s, i, fmt.Sprintf("Error removing photo of %s", name), err)
+			return
+		}
+		log.Printf("Removed photo of %s on %s at the request of %s [dose %s]", name, date, userID, reminder.CorrelationID)
+
+		c.respond(s, i, fmt.Sprintf("🗑️ Removed the photo of %s on %s. The dose itself is still recorded.", name, date))
+		return
+	}
+
+	c.respond(s, i, fmt.Sprintf("There's no photo of %s on %s.", name, date))
+}
@@ -0,0 +1,105 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// deliveryRoute is how a reminder chases the user, given the devices Discord shows them on
+type deliveryRoute int
+
+const (
+	// routeChannel pings the user in the reminder channel, for users at a desktop or in a browser
+	routeChannel deliveryRoute = iota
+	// routeMobile also nudges the user by DM, which reaches phones more reliably than channel mentions
+	routeMobile
+	// routeAway also nudges the user by DM and escalates sooner, for users who are idle or offline
+	routeAway
+)
+
+// String returns the route's name for logs
+func (r deliveryRoute) String() string {
+	switch r {
+	case routeMobile:
+		return "mobile"
+	case routeAway:
+		return "away"
+	default:
+		return "channel"
+	}
+}
+
+// presenceRoute chooses how to deliver reminders to a user with the given presence. Users whose
+// presence isn't known get the usual channel ping.
+func presenceRoute(presence *discordgo.Presence) deliveryRoute {
+	if presence == nil {
+		return routeChannel
+	}
+
+	switch presence.Status {
+	case discordgo.StatusIdle, discordgo.StatusOffline, discordgo.StatusInvisible:
+		return routeAway
+	}
+
+	clients := presence.ClientStatus
+	if clients.Desktop == discordgo.StatusOnline || clients.Web == discordgo.StatusOnline {
+		return routeChannel
+	}
+	if clients.Mobile != "" && clients.Mobile != discordgo.StatusOffline {
+		return routeMobile
+	}
+	return routeChannel
+}
+
+// deliveryRoute returns how to deliver reminders to a user, from their presence in the reminder
+// channel's guild. It's always the channel unless presence routing is enabled.
+func (c *Client) deliveryRoute(userID string) deliveryRoute {
+	if !c.presenceRouting || userID == "" {
+		return routeChannel
+	}
+
+	state := c.session.Load().State
+	channel, err := state.Channel(c.channelID)
+	if err != nil {
+		return routeChannel
+	}
+	presence, err := state.Presence(channel.GuildID, userID)
+	if err != nil {
+		// Users who are offline aren't listed in the presences of the guild
+		return routeAway
+	}
+	return presenceRoute(presence)
+}
+
+// escalateAway checks if a reminder for a user who is away should escalate, which it does after half
+// as many reminders as usual
+func (c *Client) escalateAway(medication config.Medication, reminder *db.Reminder) bool {
+	return medication.Policy().Escalate && c.awayEscalateAfterNags > 0 && reminder.NagCount >= c.awayEscalateAfterNags
+}
+
+// nudgeByDM sends the user a medication is for a DM linking to its reminder, so it reaches whichever
+// device they're using
+func (c *Client) nudgeByDM(medication config.Medication, messageID string) {
+	session := c.session.Load()
+	channel, err := session.State.Channel(c.channelID)
+	if err != nil {
+		log.Printf("Error looking up reminder channel to nudge by DM: %v", err)
+		return
+	}
+
+	dm, err := session.UserChannelCreate(medication.UserID)
+	if err != nil {
+		log.Printf("Error opening DM to nudge %s: %v", medication.UserID, err)
+		return
+	}
+
+	link := fmt.Sprintf("https://discord.com/channels/%s/%s/%s", channel.GuildID, c.channelID, messageID)
+	if _, err := session.ChannelMessageSend(dm.ID, fmt.Sprintf("💊 Time for your %s: %s", medication.Name, link)); err != nil {
+		log.Printf("Error nudging %s by DM about %s: %v", medication.UserID, medication.Name, err)
+	}
+}
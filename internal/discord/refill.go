@@ -0,0 +1,158 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"meds-bot/internal/db"
+	"meds-bot/internal/stats"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// refilledAction is the custom ID action of the button marking a medication as refilled
+const refilledAction = "refilled"
+
+// SendRefillReminder sends a reminder that a medication's refill is due, with a button to mark it as refilled
+func (c *Client) SendRefillReminder(ctx context.Context, info *db.MedicationInfo) error {
+	due, err := time.ParseInLocation("2006-01-02", info.RefillDue, c.location)
+	if err != nil {
+		return fmt.Errorf("invalid refill due date %s: %w", info.RefillDue, err)
+	}
+
+	content := mention(c.userFor(info.Name))
+	content += fmt.Sprintf("💊 **Refill Reminder: %s** 💊\n", info.Name)
+	content += fmt.Sprintf("Your %s refill is %s (%s).", info.Name, describeDueDate(due, time.Now().In(c.location)), info.RefillDue)
+	if line := c.refillContactLine(ctx, info); line != "" {
+		content += fmt.Sprintf("\n📞 %s.", line)
+	}
+
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    fmt.Sprintf("I refilled %s", info.Name),
+					Style:    discordgo.PrimaryButton,
+					CustomID: c.customID(refilledAction, info.Name),
+					Emoji: &discordgo.ComponentEmoji{
+						Name: "💊",
+					},
+				},
+			},
+		},
+	}
+
+	_, err = c.session.Load().ChannelMessageSendComplex(c.channelID, &discordgo.MessageSend{
+		Content:    content,
+		Components: components,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send refill reminder message: %w", err)
+	}
+
+	return nil
+}
+
+// SendLowSupplyWarning warns that a medication is running low on its tracked stock, so it's refilled in time
+func (c *Client) SendLowSupplyWarning(ctx context.Context, forecast stats.StockForecast) error {
+	info, err := c.storeFor(ctx).GetMedicationInfo(ctx, forecast.Medication)
+	if err != nil {
+		return fmt.Errorf("failed to get medication info for %s: %w", forecast.Medication, err)
+	}
+
+	content := mention(c.userFor(forecast.Medication))
+	content += fmt.Sprintf("⚠️ **Running Low: %s** ⚠️\n", forecast.Medication)
+	content += fmt.Sprintf("You have %s of %s left, time to refill. %s.", doseCount(forecast.PillsRemaining), forecast.Medication, forecast.Warning())
+	if line := c.refillContactLine(ctx, info); line != "" {
+		content += fmt.Sprintf("\n📞 %s.", line)
+	}
+	content += "\nAdd the doses you pick up with `/meds refilled`."
+
+	if _, err := c.session.Load().ChannelMessageSend(c.channelID, content); err != nil {
+		return fmt.Errorf("failed to send low supply warning message: %w", err)
+	}
+
+	return nil
+}
+
+// doseCount describes a number of doses, e.g. "1 dose" or "5 doses"
+func doseCount(n int) string {
+	if n == 1 {
+		return "1 dose"
+	}
+	return fmt.Sprintf("%d doses", n)
+}
+
+// registerRefillHandler registers the handler for refill buttons
+func (c *Client) registerRefillHandler(ctx context.Context) {
+	c.RegisterHandler(refilledAction, func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+		medicationName := args[0]
+
+		if err := c.markRefilled(ctx, medicationName, "", 0, 0); err != nil {
+			c.respondWithFailure(s, i, fmt.Sprintf("Error marking %s as refilled", medicationName), err)
+			return
+		}
+
+		content := fmt.Sprintf("✅ **%s Refilled** ✅\nSet the next refill date with `/meds refilldue`, or use `/meds refilled` to record the cost and the doses picked up.", medicationName)
+		_, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			Channel:    c.channelID,
+			ID:         i.Message.ID,
+			Content:    &content,
+			Components: &[]discordgo.MessageComponent{},
+		})
+		if err != nil {
+			log.Printf("Error updating refill message for %s: %v", medicationName, err)
+		}
+
+		c.respond(s, i, fmt.Sprintf("Marked %s as refilled.", medicationName))
+	})
+}
+
+// markRefilled logs a refill with its cost and copay and clears the medication's refill due date,
+// stopping refill reminders. If nextDue is set it becomes the new refill due date.
+func (c *Client) markRefilled(ctx context.Context, medicationName, nextDue string, costCents, copayCents int64) error {
+	today := time.Now().In(c.location).Format("2006-01-02")
+
+	if err := c.storeFor(ctx).RecordRefill(ctx, &db.Refill{
+		Medication: medicationName,
+		Date:       today,
+		CostCents:  costCents,
+		CopayCents: copayCents,
+	}); err != nil {
+		return err
+	}
+
+	return c.storeFor(ctx).MarkRefilled(ctx, medicationName, nextDue, fmt.Sprintf("Refilled on %s", today))
+}
+
+// formatCents formats an amount in cents as a decimal amount, e.g. 1250 as "12.50"
+func formatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// describeDueDate describes how far away a due date is, e.g. "due in 3 days" or "overdue by 1 day"
+func describeDueDate(due, now time.Time) string {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	days := int(due.Sub(today).Hours() / 24)
+
+	switch {
+	case days == 0:
+		return "due today"
+	case days == 1:
+		return "due tomorrow"
+	case days > 1:
+		return fmt.Sprintf("due in %d days", days)
+	case days == -1:
+		return "overdue by 1 day"
+	default:
+		return fmt.Sprintf("overdue by %d days", -days)
+	}
+}
@@ -0,0 +1,118 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// buttonRefreshDays is how far back reminder messages are checked on startup
+	buttonRefreshDays = 7
+	// buttonRefreshDelay spaces out message edits on startup to stay well within Discord's rate limits
+	buttonRefreshDelay = time.Second
+)
+
+// RefreshReminderButtons re-validates the buttons on recent pending reminder messages after a restart.
+// Today's reminders are refreshed with the current button configuration, while older reminders and
+// reminders for medications no longer configured have their buttons removed, since clicking them
+// would acknowledge the wrong dose.
+func (c *Client) RefreshReminderButtons(ctx context.Context) error {
+	now := time.Now().In(c.location)
+
+	if c.reminderMode == config.ReminderModeChecklist {
+		messageID, err := c.storeFor(ctx).GetTodayChecklist(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get today's checklist: %w", err)
+		}
+		if messageID == "" {
+			return nil
+		}
+		return c.updateChecklist(ctx, messageID)
+	}
+
+	reminders, err := c.storeFor(ctx).GetReminderHistory(ctx, "", now.AddDate(0, 0, -buttonRefreshDays))
+	if err != nil {
+		return fmt.Errorf("failed to get recent reminders: %w", err)
+	}
+
+	ticker := time.NewTicker(buttonRefreshDelay)
+	defer ticker.Stop()
+
+	refreshed := 0
+	for _, reminder := range reminders {
+		if reminder.Resolved() || reminder.MessageID == "" {
+			continue
+		}
+
+		if refreshed > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		refreshed++
+
+		if err := c.refreshReminderButton(reminder); err != nil {
+			// The message may have been deleted, so carry on with the rest
+			log.Printf("Error refreshing reminder message for %s on %s: %v", reminder.MedicationType, reminder.Date, err)
+		}
+	}
+
+	if refreshed > 0 {
+		log.Printf("Refreshed %d pending reminder messages", refreshed)
+	}
+
+	return nil
+}
+
+// refreshReminderButton updates a pending reminder message's button, or removes it if the reminder is stale
+func (c *Client) refreshReminderButton(reminder db.Reminder) error {
+	edit := &discordgo.MessageEdit{
+		Channel: c.channelID,
+		ID:      reminder.MessageID,
+	}
+
+	now := time.Now().In(c.location)
+	today := c.doseDate(reminder.MedicationType)
+	if reminder.Date == today && c.hasMedication(reminder.MedicationType) && reminderWindowClosed(c.medicationByName(reminder.MedicationType), now) {
+		// The dose's window closed while the bot was offline
+		content := missedContent(reminder.MedicationType, false)
+		edit.Content = &content
+		edit.Components = &[]discordgo.MessageComponent{}
+	} else if reminder.Date == today && c.hasMedication(reminder.MedicationType) {
+		medication := c.medicationByName(reminder.MedicationType)
+		edit.Components = &[]discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					c.takenButton(medication, fmt.Sprintf("I took %s", medication.Name), "✅"),
+					c.skipButton(medication, false),
+				},
+			},
+		}
+	} else {
+		content := fmt.Sprintf("⚪ **%s** reminder from %s is no longer active.", reminder.MedicationType, reminder.Date)
+		edit.Content = &content
+		edit.Components = &[]discordgo.MessageComponent{}
+	}
+
+	if _, err := c.session.Load().ChannelMessageEditComplex(edit); err != nil {
+		return fmt.Errorf("failed to edit reminder message: %w", err)
+	}
+
+	return nil
+}
+
+// reminderWindowClosed checks if today's reminder window of a medication has closed, in its own timezone
+// if it has one
+func reminderWindowClosed(medication config.Medication, now time.Time) bool {
+	local := medication.In(now)
+	return !local.Before(medication.DueAt(local).Add(config.ReminderWindowHours * time.Hour))
+}
@@ -0,0 +1,125 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// replaceGracePeriod is how long a reminder message is kept after a nag replaces it, so a click
+	// on it that's already in flight still finds it
+	replaceGracePeriod = 10 * time.Second
+	// maxUpdateAttempts is how many times a reminder is updated before giving up on other writers changing it first
+	maxUpdateAttempts = 3
+)
+
+// isUnknownMessage reports whether an error is Discord saying the message no longer exists
+func isUnknownMessage(err error) bool {
+	var restErr *discordgo.RESTError
+	return errors.As(err, &restErr) && restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownMessage
+}
+
+// acknowledgeReminder marks today's dose of a medication as taken by a user, recording the message chosen by
+// messageID. If another writer changes the reminder first, it's read again and the update retried.
+// It returns the reminder as it was before being acknowledged, and whether it already had been.
+// Medications that are no longer configured return db.ErrMedicationInactive, rather than starting a new dose,
+// doses needing more than one confirmation return db.ErrConfirmationRequired unless confirmedBy confirms them,
+// and doses needing a photo return db.ErrPhotoRequired until one has been recorded. takenAt is when the
+// dose was taken, if it's reported later, or zero for now.
+func (c *Client) acknowledgeReminder(ctx context.Context, medicationName, userID string, confirmedBy []string, takenAt time.Time, messageID func(reminder *db.Reminder) string) (*db.Reminder, bool, error) {
+	if !c.hasMedication(medicationName) {
+		return nil, false, fmt.Errorf("%w: %s", db.ErrMedicationInactive, medicationName)
+	}
+
+	for attempt := 1; ; attempt++ {
+		reminder, err := c.storeFor(ctx).GetTodayReminder(ctx, medicationName)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get reminder: %w", err)
+		}
+		if reminder.Acknowledged {
+			return reminder, true, nil
+		}
+		// A dose that needs confirming by others, such as a child's guardian, is only recorded once they
+		// have, however it's acknowledged
+		if medication := c.medicationByName(medicationName); medication.RequiredConfirmations() > 1 && !medication.Confirmed(confirmedBy) {
+			return nil, false, fmt.Errorf("%w: %s", db.ErrConfirmationRequired, medicationName)
+		}
+		// Likewise a dose that needs a photo is only recorded once one's been added
+		if c.medicationByName(medicationName).PhotoProof == config.PhotoProofRequired && reminder.ProofHash == "" {
+			return nil, false, fmt.Errorf("%w: %s", db.ErrPhotoRequired, medicationName)
+		}
+
+		err = c.storeFor(ctx).RecordAcknowledgment(ctx, reminder.ID, reminder.Version, messageID(reminder), userID, takenAt)
+		if err == nil {
+			return reminder, false, nil
+		}
+		if errors.Is(err, db.ErrAlreadyAcknowledged) {
+			return reminder, true, nil
+		}
+		if !errors.Is(err, db.ErrReminderConflict) || attempt == maxUpdateAttempts {
+			return nil, false, fmt.Errorf("failed to update reminder: %w", err)
+		}
+		log.Printf("Debug: Reminder for %s changed while acknowledging it, retrying [dose %s]", medicationName, reminder.CorrelationID)
+	}
+}
+
+// deleteReplaced deletes a reminder message replaced by a nag once the grace period is over,
+// unless the dose was acknowledged from it in the meantime
+func (c *Client) deleteReplaced(ctx context.Context, medicationName, messageID, correlationID string) {
+	time.AfterFunc(replaceGracePeriod, func() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		reminder, err := c.storeFor(ctx).GetTodayReminder(ctx, medicationName)
+		if err != nil {
+			log.Printf("Error getting reminder for %s [dose %s]: %v", medicationName, correlationID, err)
+			return
+		}
+		if reminder.MessageID == messageID {
+			// The replaced message was clicked, so it now shows the dose was taken
+			return
+		}
+
+		if err := c.DeleteMessage(ctx, messageID); err != nil && !isUnknownMessage(err) {
+			log.Printf("Error deleting previous message for %s [dose %s]: %v", medicationName, correlationID, err)
+		}
+	})
+}
+
+// resolveClickedMessage marks the clicked reminder message as taken. A nag may have replaced the
+// message while it was being clicked, in which case the nag is removed, or shows the dose was
+// taken instead if the clicked message is already gone.
+func (c *Client) resolveClickedMessage(ctx context.Context, medicationName string, reminder *db.Reminder, clickedID string) {
+	current := reminder.MessageID
+	replaced := current != "" && current != clickedID && c.reminderMode != config.ReminderModeChecklist
+
+	err := c.markMessageTaken(ctx, medicationName, clickedID)
+	if replaced && isUnknownMessage(err) {
+		log.Printf("Debug: Clicked reminder for %s was already replaced, marking its replacement as taken [dose %s]", medicationName, reminder.CorrelationID)
+		if err := c.markMessageTaken(ctx, medicationName, current); err != nil {
+			log.Printf("Error marking %s as taken [dose %s]: %v", medicationName, reminder.CorrelationID, err)
+		}
+		if err := c.storeFor(ctx).MoveReminderMessage(ctx, reminder.ID, current); err != nil {
+			log.Printf("Error updating reminder message for %s [dose %s]: %v", medicationName, reminder.CorrelationID, err)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Error marking %s as taken [dose %s]: %v", medicationName, reminder.CorrelationID, err)
+	}
+
+	if replaced {
+		if err := c.DeleteMessage(ctx, current); err != nil && !isUnknownMessage(err) {
+			log.Printf("Error deleting superseded reminder for %s [dose %s]: %v", medicationName, reminder.CorrelationID, err)
+		}
+	}
+}
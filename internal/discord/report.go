@@ -0,0 +1,154 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"meds-bot/internal/stats"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// SendWeeklyReport posts the weekly adherence summary and stock warnings
+func (c *Client) SendWeeklyReport(ctx context.Context, report *stats.WeeklyReport) error {
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("📊 Weekly Report: %s – %s", report.From.Format("2 Jan"), report.To.Format("2 Jan")),
+	}
+
+	var adherence strings.Builder
+	for _, a := range report.Adherence {
+		adherence.WriteString(fmt.Sprintf("%s: %d/%d doses (%d%%)\n", a.Medication, a.Taken, a.Due, a.Percent()))
+	}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:  "Adherence",
+		Value: orPlaceholder(adherence.String()),
+	})
+
+	if len(report.StockWarnings) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "⚠️ Stock Warnings",
+			Value: formatStockWarnings(report.StockWarnings),
+		})
+	}
+
+	_, err := c.session.Load().ChannelMessageSendEmbed(c.channelID, embed)
+	if err != nil {
+		return fmt.Errorf("failed to send weekly report: %w", err)
+	}
+
+	return nil
+}
+
+// handleStatusCommand shows the status of today's doses and the stock forecast of each medication
+func (c *Client) handleStatusCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	now := time.Now().In(c.location)
+
+	var today strings.Builder
+	for _, medication := range c.medications {
+		if !medication.IsScheduledOn(now) {
+			continue
+		}
+
+		reminder, err := c.storeFor(ctx).GetTodayReminder(ctx, medication.Name)
+		if err != nil {
+			c.respondWithFailure(s, i, fmt.Sprintf("Error getting reminder for %s", medication.Name), err)
+			return
+		}
+
+		status := "⬜"
+		if reminder.Acknowledged {
+			status = "✅"
+		} else if reminder.Skipped {
+			status = "⏭️"
+		}
+		today.WriteString(fmt.Sprintf("%s %s (%s)\n", status, medication.Name, medication.Clock()))
+	}
+
+	forecasts, err := stats.StockForecasts(ctx, c.storeFor(ctx), c.medications, now)
+	if err != nil {
+		c.respondWithFailure(s, i, "Error forecasting stock", err)
+		return
+	}
+
+	var stock strings.Builder
+	for _, forecast := range forecasts {
+		marker := ""
+		if forecast.DaysLeft <= c.stockWarningDays {
+			marker = "⚠️ "
+		}
+		stock.WriteString(fmt.Sprintf("%s%s: %d doses left, %s\n", marker, forecast.Medication, forecast.PillsRemaining, strings.TrimPrefix(forecast.Warning(), forecast.Medication+" ")))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: "💊 Medication Status",
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Today", Value: orPlaceholder(today.String())},
+			{Name: "Stock", Value: orPlaceholder(stock.String())},
+		},
+	}
+
+	c.respondWithEmbed(s, i, embed)
+}
+
+// defaultStatsDays is how many days /meds stats looks back without the days option
+const defaultStatsDays = 30
+
+// maxStatsMissedDates is how many of a medication's missed dates /meds stats lists, keeping the embed field short
+const maxStatsMissedDates = 10
+
+// handleStatsCommand shows each medication's adherence, current streak and missed days over the last 30 or 90 days
+func (c *Client) handleStatsCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	days := defaultStatsDays
+	if option, ok := options["days"]; ok {
+		days = int(option.IntValue())
+	}
+
+	now := time.Now().In(c.location)
+	reminders, err := c.storeFor(ctx).GetReminderHistory(ctx, "", now.AddDate(0, 0, -days))
+	if err != nil {
+		c.respondWithFailure(s, i, "Error getting reminder history", err)
+		return
+	}
+
+	today := now.Format("2006-01-02")
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("📈 Adherence: Last %d Days", days),
+	}
+	for _, medication := range c.medications {
+		if medication.AsNeeded() {
+			continue
+		}
+		adherence := stats.CalculateAdherence(medication.Name, reminders, today)
+		streak := stats.Streak(medication.Name, reminders, today)
+		missed := stats.MissedDates(medication.Name, reminders, today)
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "%d/%d doses (%d%%)\n", adherence.Taken, adherence.Due, adherence.Percent())
+		fmt.Fprintf(&b, "Streak: %d doses\n", streak)
+		fmt.Fprintf(&b, "Missed: %d days", len(missed))
+		if len(missed) > maxStatsMissedDates {
+			fmt.Fprintf(&b, " (latest %s)", strings.Join(missed[len(missed)-maxStatsMissedDates:], ", "))
+		} else if len(missed) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(missed, ", "))
+		}
+
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  medication.Name,
+			Value: b.String(),
+		})
+	}
+
+	c.respondWithEmbed(s, i, embed)
+}
+
+// formatStockWarnings lists stock warnings one per line
+func formatStockWarnings(warnings []stats.StockForecast) string {
+	var lines []string
+	for _, warning := range warnings {
+		lines = append(lines, warning.Warning())
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,211 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/events"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// skipAction is the custom ID action of the button to skip today's dose on purpose
+	skipAction = "skip"
+	// skipSubmitAction is the custom ID action of the modal the reason for skipping a dose is entered in
+	skipSubmitAction = "skip_submit"
+	// maxSkipReasonLength is the longest reason for skipping a dose that can be entered
+	maxSkipReasonLength = 200
+)
+
+// skipButton returns the button for skipping today's dose of a medication on purpose, such as on a
+// doctor's orders or when fasting for a blood test
+func (c *Client) skipButton(medication config.Medication, accessible bool) discordgo.Button {
+	button := discordgo.Button{
+		Label:    "Skip today",
+		Style:    discordgo.SecondaryButton,
+		CustomID: c.customID(skipAction, medication.Name, c.doseDate(medication.Name)),
+	}
+	if accessible {
+		button.Label = fmt.Sprintf("Skip today's %s", medication.Name)
+	} else {
+		button.Emoji = &discordgo.ComponentEmoji{Name: "⏭️"}
+	}
+	return button
+}
+
+// registerSkipHandlers registers the handlers for skipping a dose from its reminder
+func (c *Client) registerSkipHandlers(ctx context.Context) {
+	// The button opens a modal to give an optional reason for skipping the dose
+	c.RegisterHandler(skipAction, func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+		medicationName := args[0]
+
+		if date := c.doseDate(medicationName); len(args) < 2 || args[1] != date {
+			c.respondWithError(s, i, fmt.Sprintf("This reminder is from an earlier day, so it can't skip today's %s", medicationName))
+			return
+		}
+		if !canTake(c.medicationByName(medicationName), interactionUserID(i)) {
+			c.respondWithError(s, i, fmt.Sprintf("%s is someone else's medication, so you can't skip it", medicationName))
+			return
+		}
+
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: &discordgo.InteractionResponseData{
+				CustomID: c.customID(skipSubmitAction, medicationName, args[1]),
+				Title:    "Skip today's dose",
+				Components: []discordgo.MessageComponent{
+					discordgo.ActionsRow{
+						Components: []discordgo.MessageComponent{
+							discordgo.TextInput{
+								CustomID:    "reason",
+								Label:       "Reason (optional)",
+								Style:       discordgo.TextInputShort,
+								Placeholder: "e.g. fasting for a blood test",
+								Required:    false,
+								MaxLength:   maxSkipReasonLength,
+							},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			log.Printf("Error opening skip modal: %v", err)
+		}
+	})
+
+	c.RegisterHandler(skipSubmitAction, func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+		medicationName := args[0]
+
+		if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+		}); err != nil {
+			log.Printf("Error sending deferred response: %v", err)
+		}
+
+		// The modal may have been left open past midnight
+		if date := c.doseDate(medicationName); len(args) < 2 || args[1] != date {
+			c.editDeferred(s, i, fmt.Sprintf("This reminder is from an earlier day, so it can't skip today's %s. Use today's reminder instead.", medicationName))
+			return
+		}
+		if !canTake(c.medicationByName(medicationName), interactionUserID(i)) {
+			c.editDeferred(s, i, fmt.Sprintf("%s is someone else's medication, so you can't skip it.", medicationName))
+			return
+		}
+
+		reason := strings.TrimSpace(modalValue(i.ModalSubmitData(), "reason"))
+		clickedID := ""
+		if i.Message != nil {
+			clickedID = i.Message.ID
+		}
+
+		reminder, err := c.skipDose(ctx, medicationName, reason, clickedID)
+		if errors.Is(err, db.ErrAlreadyAcknowledged) {
+			c.editDeferred(s, i, fmt.Sprintf("You've already taken your %s today, so it can't be skipped.", medicationName))
+			return
+		}
+		if err != nil {
+			c.editDeferred(s, i, presentError(i, fmt.Sprintf("Error skipping %s", medicationName), err))
+			return
+		}
+
+		c.markMessagesSkipped(medicationName, reason, c.accessible(ctx), clickedID, reminder.MessageID)
+
+		log.Printf("Dose of %s skipped [dose %s]", medicationName, reminder.CorrelationID)
+		err = c.events.Publish(ctx, events.DoseSkipped{
+			Medication:    medicationName,
+			Date:          reminder.Date,
+			ReminderID:    reminder.ID,
+			Reason:        reason,
+			CorrelationID: reminder.CorrelationID,
+		})
+		if err != nil {
+			log.Printf("Error publishing skipped dose of %s [dose %s]: %v", medicationName, reminder.CorrelationID, err)
+		}
+
+		c.editDeferred(s, i, fmt.Sprintf("Skipped today's %s. It won't count as a missed dose, and you can still mark it as taken if you take it after all.", medicationName))
+	})
+}
+
+// skipDose records today's dose of a medication as skipped, returning its reminder as it was before
+// the skip, or db.ErrAlreadyAcknowledged if the dose was already taken
+func (c *Client) skipDose(ctx context.Context, medicationName, reason, messageID string) (*db.Reminder, error) {
+	if !c.hasMedication(medicationName) {
+		return nil, fmt.Errorf("%w: %s", db.ErrMedicationInactive, medicationName)
+	}
+
+	for attempt := 1; ; attempt++ {
+		reminder, err := c.storeFor(ctx).GetTodayReminder(ctx, medicationName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get reminder: %w", err)
+		}
+		if reminder.Acknowledged {
+			return nil, db.ErrAlreadyAcknowledged
+		}
+		if messageID == "" {
+			messageID = reminder.MessageID
+		}
+
+		err = c.storeFor(ctx).RecordSkip(ctx, reminder.ID, reminder.Version, messageID, reason)
+		if err == nil {
+			return reminder, nil
+		}
+		if !errors.Is(err, db.ErrReminderConflict) || attempt == maxUpdateAttempts {
+			return nil, fmt.Errorf("failed to update reminder: %w", err)
+		}
+		log.Printf("Debug: Reminder for %s changed while skipping it, retrying [dose %s]", medicationName, reminder.CorrelationID)
+	}
+}
+
+// markMessagesSkipped updates the reminder messages for a dose to show it was skipped, leaving only the
+// button to mark it as taken in case it's taken after all. A nag may have replaced the clicked message
+// while the reason was being entered.
+func (c *Client) markMessagesSkipped(medicationName, reason string, accessible bool, messageIDs ...string) {
+	content := skippedContent(medicationName, reason, accessible)
+	components := []discordgo.MessageComponent{}
+	if c.hasMedication(medicationName) {
+		components = append(components, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{c.reminderTakenButton(c.medicationByName(medicationName), accessible)},
+		})
+	}
+	edited := make(map[string]bool)
+	for _, messageID := range messageIDs {
+		if messageID == "" || edited[messageID] {
+			continue
+		}
+		edited[messageID] = true
+
+		_, err := c.session.Load().ChannelMessageEditComplex(&discordgo.MessageEdit{
+			Channel:    c.channelID,
+			ID:         messageID,
+			Content:    &content,
+			Components: &components,
+		})
+		if err != nil && !isUnknownMessage(err) {
+			log.Printf("Error marking %s as skipped: %v", medicationName, err)
+		}
+	}
+}
+
+// skippedContent is the content of a reminder message for a dose that was skipped
+func skippedContent(medicationName, reason string, accessible bool) string {
+	if accessible {
+		if reason != "" {
+			return fmt.Sprintf("%s skipped today: %s.", medicationName, reason)
+		}
+		return fmt.Sprintf("%s skipped today.", medicationName)
+	}
+
+	content := fmt.Sprintf("⏭️ **%s Skipped** ⏭️\nToday's dose was skipped on purpose, so it won't count as missed. If you take it after all, you can still mark it as taken.", medicationName)
+	if reason != "" {
+		content += fmt.Sprintf("\nReason: %s", reason)
+	}
+	return content
+}
@@ -0,0 +1,188 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"meds-bot/internal/db"
+	"meds-bot/internal/events"
+)
+
+// subscribe registers the client to send the messages for reminder events, which are paused while
+// the reminder channel can't be reached
+func (c *Client) subscribe(ctx context.Context, bus *events.Bus) {
+	events.On(bus, whileAccessible(ctx, c, c.onReminderDue))
+	events.On(bus, whileAccessible(ctx, c, c.onHeadsUpDue))
+	events.On(bus, whileAccessible(ctx, c, c.onChecklistDue))
+	events.On(bus, whileAccessible(ctx, c, c.onDoseMissed))
+	events.On(bus, whileAccessible(ctx, c, c.onArchiveDue))
+	events.On(bus, whileAccessible(ctx, c, c.onCleanupDue))
+	events.On(bus, whileAccessible(ctx, c, func(ctx context.Context, event events.RefillDue) error {
+		return c.SendRefillReminder(ctx, event.Info)
+	}))
+	events.On(bus, whileAccessible(ctx, c, func(ctx context.Context, event events.LowSupply) error {
+		return c.SendLowSupplyWarning(ctx, event.Forecast)
+	}))
+	events.On(bus, whileAccessible(ctx, c, func(ctx context.Context, event events.LabTestDue) error {
+		return c.SendLabTestReminder(ctx, event.Test)
+	}))
+	events.On(bus, whileAccessible(ctx, c, func(ctx context.Context, event events.WeeklyReportDue) error {
+		return c.SendWeeklyReport(ctx, event.Report)
+	}))
+}
+
+// onReminderDue replaces the previous reminder message for a dose with a new one. The new message is
+// sent before the previous one is deleted, so a click racing with the nag resolves in the user's favor.
+func (c *Client) onReminderDue(ctx context.Context, event events.ReminderDue) error {
+	// The dose may have been taken since the check that published the event
+	reminder, err := c.storeFor(ctx).GetTodayReminder(ctx, event.Medication.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get reminder for %s [dose %s]: %w", event.Medication.Name, event.Reminder.CorrelationID, err)
+	}
+	if reminder.Resolved() {
+		log.Printf("Debug: Skipping reminder for %s taken or skipped since it was due [dose %s]", event.Medication.Name, reminder.CorrelationID)
+		return nil
+	}
+	if event.Medication.RequiredConfirmations() > 1 {
+		// A dose the user says they've taken isn't nagged about while it waits for others to confirm it
+		pending, _, err := c.pendingConfirmation(ctx, event.Medication.Name)
+		if err != nil {
+			log.Printf("Error checking pending confirmation of %s [dose %s]: %v", event.Medication.Name, reminder.CorrelationID, err)
+		}
+		if pending != nil {
+			log.Printf("Debug: Skipping reminder for %s waiting for confirmation [dose %s]", event.Medication.Name, reminder.CorrelationID)
+			return nil
+		}
+	}
+
+	opts := ReminderOptions{Escalate: event.Escalate, DueAt: event.DueAt}
+	route := c.deliveryRoute(event.Medication.UserID)
+	if route == routeAway && c.escalateAway(event.Medication, reminder) {
+		opts.Escalate = true
+	}
+
+	messageID, err := c.SendReminder(ctx, event.Medication, opts)
+	if err != nil {
+		err = fmt.Errorf("failed to send reminder for %s [dose %s]: %w", event.Medication.Name, reminder.CorrelationID, err)
+		// Backup channels take over once reminders keep failing to send
+		if publishErr := c.events.Publish(ctx, events.ReminderUndelivered{
+			Medication:    event.Medication.Name,
+			Date:          reminder.Date,
+			ReminderID:    reminder.ID,
+			Err:           err,
+			CorrelationID: reminder.CorrelationID,
+			Channel:       events.ChannelDiscord,
+		}); publishErr != nil {
+			log.Printf("Error publishing undelivered reminder for %s [dose %s]: %v", event.Medication.Name, reminder.CorrelationID, publishErr)
+		}
+		return err
+	}
+	if route != routeChannel && event.Medication.Policy().PingUser {
+		log.Printf("Debug: Nudging user by DM about %s, who is %s [dose %s]", event.Medication.Name, route, reminder.CorrelationID)
+		c.nudgeByDM(event.Medication, messageID)
+	}
+
+	// Update the reminder with the new message ID, unless the dose was taken while it was sent
+	for attempt := 1; ; attempt++ {
+		err := c.storeFor(ctx).RecordNag(ctx, reminder.ID, reminder.Version, messageID)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, db.ErrAlreadyAcknowledged) || errors.Is(err, db.ErrDoseSkipped) {
+			log.Printf("Debug: Withdrawing reminder for %s taken or skipped while it was sent [dose %s]", event.Medication.Name, reminder.CorrelationID)
+			if err := c.DeleteMessage(ctx, messageID); err != nil {
+				log.Printf("Error deleting withdrawn reminder for %s [dose %s]: %v", event.Medication.Name, reminder.CorrelationID, err)
+			}
+			return nil
+		}
+		if !errors.Is(err, db.ErrReminderConflict) || attempt == maxUpdateAttempts {
+			return fmt.Errorf("failed to update reminder status for %s [dose %s]: %w", event.Medication.Name, reminder.CorrelationID, err)
+		}
+
+		reminder, err = c.storeFor(ctx).GetTodayReminder(ctx, event.Medication.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get reminder for %s [dose %s]: %w", event.Medication.Name, event.Reminder.CorrelationID, err)
+		}
+	}
+	log.Printf("Sent reminder %d for %s in message %s [dose %s]", reminder.NagCount+1, event.Medication.Name, messageID, reminder.CorrelationID)
+
+	if reminder.MessageID != "" {
+		c.deleteReplaced(ctx, event.Medication.Name, reminder.MessageID, reminder.CorrelationID)
+	}
+
+	return c.events.Publish(ctx, events.ReminderSent{
+		Medication:    event.Medication.Name,
+		Date:          reminder.Date,
+		ReminderID:    reminder.ID,
+		MessageID:     messageID,
+		NagCount:      reminder.NagCount + 1,
+		CorrelationID: reminder.CorrelationID,
+		Channel:       events.ChannelDiscord,
+	})
+}
+
+// onHeadsUpDue sends a heads-up for a dose, which the reminder replaces once it's due
+func (c *Client) onHeadsUpDue(ctx context.Context, event events.HeadsUpDue) error {
+	messageID, err := c.SendHeadsUp(ctx, event.Medication, event.DueAt)
+	if err != nil {
+		return fmt.Errorf("failed to send heads-up for %s [dose %s]: %w", event.Medication.Name, event.Reminder.CorrelationID, err)
+	}
+
+	if err := c.storeFor(ctx).RecordHeadsUp(ctx, event.Reminder.ID, messageID); err != nil {
+		return fmt.Errorf("failed to record heads-up for %s [dose %s]: %w", event.Medication.Name, event.Reminder.CorrelationID, err)
+	}
+
+	return nil
+}
+
+// onDoseMissed removes the button from a missed dose's reminder, which would otherwise still record the
+// dose as taken after its window closed
+func (c *Client) onDoseMissed(ctx context.Context, event events.DoseMissed) error {
+	reminders, err := c.storeFor(ctx).GetRemindersForDate(ctx, event.Date)
+	if err != nil {
+		return fmt.Errorf("failed to get reminders for missed %s [dose %s]: %w", event.Medication, event.CorrelationID, err)
+	}
+
+	for _, reminder := range reminders {
+		if reminder.ID != event.ReminderID || reminder.MessageID == "" {
+			continue
+		}
+		if err := c.markMessageMissed(ctx, event.Medication, reminder.MessageID); err != nil {
+			return fmt.Errorf("failed to mark %s as missed [dose %s]: %w", event.Medication, event.CorrelationID, err)
+		}
+	}
+
+	return nil
+}
+
+// onChecklistDue posts the day's checklist, which is then edited as doses are taken
+func (c *Client) onChecklistDue(ctx context.Context, event events.ChecklistDue) error {
+	messageID, err := c.SendChecklist(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to send checklist: %w", err)
+	}
+
+	if err := c.storeFor(ctx).SaveTodayChecklist(ctx, messageID); err != nil {
+		return fmt.Errorf("failed to save checklist: %w", err)
+	}
+
+	return nil
+}
+
+// publishAcknowledged announces that a dose has been taken
+func (c *Client) publishAcknowledged(ctx context.Context, medicationName string, reminder *db.Reminder, source string) {
+	log.Printf("Dose of %s acknowledged via %s [dose %s]", medicationName, source, reminder.CorrelationID)
+
+	err := c.events.Publish(ctx, events.DoseAcknowledged{
+		Medication:    medicationName,
+		Date:          reminder.Date,
+		ReminderID:    reminder.ID,
+		Source:        source,
+		CorrelationID: reminder.CorrelationID,
+	})
+	if err != nil {
+		log.Printf("Error publishing acknowledgment of %s [dose %s]: %v", medicationName, reminder.CorrelationID, err)
+	}
+}
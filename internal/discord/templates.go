@@ -0,0 +1,126 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/stats"
+)
+
+// streakDays is how far back reminder history is searched when calculating streaks
+const streakDays = 365
+
+// ReminderTemplateData is the data available to reminder and acknowledged message templates
+type ReminderTemplateData struct {
+	Name    string
+	Dose    string
+	DueTime string
+	Streak  int
+	// PillsLeft is the number of doses remaining, or -1 if stock isn't tracked
+	PillsLeft int
+	// Medication and Time are the same as Name and DueTime, under the names used in the docs' examples
+	Medication string
+	Time       string
+	// UserMention mentions the user the medication is for, empty if there's no one to mention
+	UserMention string
+	// TakenAt is the time the dose was taken in the medication's timezone, e.g. 08:05, only set for
+	// acknowledged messages
+	TakenAt string
+}
+
+// templateFor returns the template for a medication's reminders, or an empty string for the default message
+func (c *Client) templateFor(medication config.Medication) string {
+	if medication.Template != "" {
+		return medication.Template
+	}
+	return c.reminderTemplate
+}
+
+// renderReminderTemplate renders a reminder message template with the medication's details
+func (c *Client) renderReminderTemplate(ctx context.Context, text string, medication config.Medication) (string, error) {
+	data, err := c.reminderTemplateData(ctx, medication)
+	if err != nil {
+		return "", err
+	}
+
+	return renderTemplate(medication.Name, text, data)
+}
+
+// acknowledgedContent renders the acknowledged template for a medication's reminder once its dose is taken,
+// or returns an empty string for the default message
+func (c *Client) acknowledgedContent(ctx context.Context, medicationName string) (string, error) {
+	if c.acknowledgedTemplate == "" || !c.hasMedication(medicationName) {
+		return "", nil
+	}
+
+	medication := c.medicationByName(medicationName)
+	data, err := c.reminderTemplateData(ctx, medication)
+	if err != nil {
+		return "", err
+	}
+
+	reminder, err := c.storeFor(ctx).GetTodayReminder(ctx, medicationName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get reminder for %s: %w", medicationName, err)
+	}
+	data.TakenAt = takenAtTime(reminder, medication, c.location)
+
+	return renderTemplate(medicationName, c.acknowledgedTemplate, data)
+}
+
+// takenAtTime returns when a dose was taken, in the medication's timezone, e.g. 08:05. Doses reported
+// afterwards, such as by a smart pillbox, were taken before they were marked as taken.
+func takenAtTime(reminder *db.Reminder, medication config.Medication, location *time.Location) string {
+	takenAt := reminder.AcknowledgedAt
+	if takenAt.IsZero() {
+		takenAt = time.Now()
+	}
+	return medication.In(takenAt.In(location)).Format("15:04")
+}
+
+// renderTemplate renders a message template with the data
+func renderTemplate(name, text string, data *ReminderTemplateData) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var content strings.Builder
+	if err := tmpl.Execute(&content, data); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return content.String(), nil
+}
+
+// reminderTemplateData gathers the values available to reminder templates
+func (c *Client) reminderTemplateData(ctx context.Context, medication config.Medication) (*ReminderTemplateData, error) {
+	now := time.Now().In(c.location)
+
+	info, err := c.storeFor(ctx).GetMedicationInfo(ctx, medication.Base())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get medication info for %s: %w", medication.Base(), err)
+	}
+
+	history, err := c.storeFor(ctx).GetReminderHistory(ctx, medication.Name, now.AddDate(0, 0, -streakDays))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reminder history for %s: %w", medication.Name, err)
+	}
+
+	dueTime := medication.Clock()
+	return &ReminderTemplateData{
+		Name:        medication.Name,
+		Dose:        info.Dose,
+		DueTime:     dueTime,
+		Streak:      stats.Streak(medication.Name, history, now.Format("2006-01-02")),
+		PillsLeft:   info.PillsRemaining,
+		Medication:  medication.Name,
+		Time:        dueTime,
+		UserMention: userMention(medication.UserID),
+	}, nil
+}
@@ -0,0 +1,35 @@
+package discord
+
+import (
+	"testing"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+)
+
+// TestTakenAtTime tests that the taken time is when the dose was taken, in the medication's timezone
+func TestTakenAtTime(t *testing.T) {
+	home, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+	takenAt := time.Date(2024, 5, 1, 7, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		medication config.Medication
+		want       string
+	}{
+		{name: "Home timezone", medication: config.Medication{Name: "Morning"}, want: "08:05"},
+		{name: "Medication timezone", medication: config.Medication{Name: "Morning", Timezone: "America/New_York"}, want: "03:05"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := takenAtTime(&db.Reminder{AcknowledgedAt: takenAt}, tt.medication, home); got != tt.want {
+				t.Errorf("takenAtTime = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package discord
+
+import (
+	"context"
+
+	"meds-bot/internal/db"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// tenantStoreKey is the context key for the store of the guild an interaction came from
+type tenantStoreKey struct{}
+
+// withGuildStore returns a context whose store is scoped to the tenant of the guild an interaction came
+// from, so commands and buttons in one guild can never read or change another's data. The home guild and
+// DMs use the default tenant, which is everything that isn't onboarded from another guild.
+func (c *Client) withGuildStore(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) (context.Context, error) {
+	if i.GuildID == "" || i.GuildID == c.homeGuildID(s) {
+		return ctx, nil
+	}
+
+	store, err := db.ScopeToTenant(c.store, i.GuildID)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, tenantStoreKey{}, store), nil
+}
+
+// storeFor returns the store for the guild of the interaction being handled, or the default tenant's
+// store outside of interactions
+func (c *Client) storeFor(ctx context.Context) db.StoreInterface {
+	if store, ok := ctx.Value(tenantStoreKey{}).(db.StoreInterface); ok {
+		return store
+	}
+	return c.store
+}
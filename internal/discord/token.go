@@ -0,0 +1,107 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"meds-bot/internal/config"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// tokenCheckInterval is how often the token file is checked for a rotated token
+const tokenCheckInterval = 30 * time.Second
+
+// addHandler adds an event handler to the current session and every session opened after a token rotation
+func (c *Client) addHandler(handler any) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	c.sessionHandlers = append(c.sessionHandlers, handler)
+	if session := c.session.Load(); session != nil {
+		session.AddHandler(handler)
+	}
+}
+
+// openSession opens a gateway connection with a token, with the client's event handlers added
+func (c *Client) openSession(token string) (*discordgo.Session, error) {
+	session, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Discord session: %w", err)
+	}
+	instrument(session)
+	session.Identify.Intents = c.intents
+
+	c.sessionMu.Lock()
+	for _, handler := range c.sessionHandlers {
+		session.AddHandler(handler)
+	}
+	c.sessionMu.Unlock()
+
+	if err := session.Open(); err != nil {
+		return nil, fmt.Errorf("failed to open Discord connection: %w", err)
+	}
+
+	return session, nil
+}
+
+// watchToken checks the token file until the context is cancelled, rotating the session when the token changes
+func (c *Client) watchToken(ctx context.Context) {
+	ticker := time.NewTicker(tokenCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		token, err := config.ReadTokenFile(c.tokenFile)
+		if err != nil {
+			log.Printf("Error reading Discord token file: %v", err)
+			continue
+		}
+
+		c.sessionMu.Lock()
+		changed := token != c.token
+		c.sessionMu.Unlock()
+		if !changed {
+			continue
+		}
+
+		if err := c.rotateSession(token); err != nil {
+			// The old session keeps running, and the rotation is retried on the next check
+			log.Printf("Error rotating Discord token: %v", err)
+		}
+	}
+}
+
+// rotateSession opens a session with a new token, swaps it in for the current one and then closes the
+// old one, so reminders keep being sent throughout
+func (c *Client) rotateSession(token string) error {
+	session, err := c.openSession(token)
+	if err != nil {
+		return err
+	}
+
+	current := c.session.Load()
+	if session.State.User.ID != current.State.User.ID {
+		session.Close()
+		return fmt.Errorf("new token is for a different bot (%s, not %s)", session.State.User.ID, current.State.User.ID)
+	}
+
+	c.sessionMu.Lock()
+	c.token = token
+	c.sessionMu.Unlock()
+
+	c.session.Store(session)
+	if err := current.Close(); err != nil {
+		log.Printf("Error closing previous Discord session: %v", err)
+	}
+
+	log.Printf("Rotated Discord token")
+	return nil
+}
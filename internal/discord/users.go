@@ -0,0 +1,61 @@
+package discord
+
+import (
+	"fmt"
+	"slices"
+
+	"meds-bot/internal/config"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// mention returns the mention of a user to start a message with, or nothing if there's no user
+func mention(userID string) string {
+	if userID == "" {
+		return ""
+	}
+	return fmt.Sprintf("<@%s> ", userID)
+}
+
+// userMention returns the mention of a user on its own, or an empty string if there's no user
+func userMention(userID string) string {
+	if userID == "" {
+		return ""
+	}
+	return fmt.Sprintf("<@%s>", userID)
+}
+
+// medicationUsers returns the users the configured medications are for, without duplicates
+func (c *Client) medicationUsers() []string {
+	var users []string
+	for _, medication := range c.medications {
+		if medication.UserID != "" && !slices.Contains(users, medication.UserID) {
+			users = append(users, medication.UserID)
+		}
+	}
+	return users
+}
+
+// userFor returns the user a medication is for, or the user to ping for medications no longer configured
+func (c *Client) userFor(medicationName string) string {
+	if userID := c.medicationByName(medicationName).UserID; userID != "" {
+		return userID
+	}
+	return c.userIDToPing
+}
+
+// interactionUserID returns the ID of the user who made an interaction
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+// canTake checks if a user may mark a medication as taken: only the user it's for, if it's for anyone
+func canTake(medication config.Medication, userID string) bool {
+	return medication.UserID == "" || medication.UserID == userID
+}
@@ -0,0 +1,68 @@
+package discord
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// postablePermissions are the permissions the bot needs to post in a channel
+const postablePermissions = discordgo.PermissionViewChannel | discordgo.PermissionSendMessages
+
+// validateTargets checks the configured channel and users exist, so misconfiguration fails
+// at startup rather than on the first reminder
+func (c *Client) validateTargets(s *discordgo.Session) error {
+	if err := validateChannel(s, c.channelID); err != nil {
+		return fmt.Errorf("invalid DISCORD_CHANNEL_ID: %w", err)
+	}
+
+	if c.userIDToPing != "" {
+		if _, err := s.User(c.userIDToPing); err != nil {
+			return fmt.Errorf("invalid DISCORD_USER_ID_TO_PING: user %s not found: %w", c.userIDToPing, err)
+		}
+	}
+
+	for _, userID := range c.medicationUsers() {
+		if userID == c.userIDToPing {
+			continue
+		}
+		if _, err := s.User(userID); err != nil {
+			return fmt.Errorf("invalid medication user ID: user %s not found: %w", userID, err)
+		}
+	}
+
+	for _, medication := range c.medications {
+		for _, userID := range medication.ConfirmingUsers() {
+			if userID == medication.UserID {
+				continue
+			}
+			if _, err := s.User(userID); err != nil {
+				return fmt.Errorf("invalid guardian or confirmer ID for %s: user %s not found: %w", medication.Name, userID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateChannel checks a channel exists, is a text channel and that the bot can post in it
+func validateChannel(s *discordgo.Session, channelID string) error {
+	channel, err := s.Channel(channelID)
+	if err != nil {
+		return fmt.Errorf("channel %s not found, check the ID and that the bot has been added to its server: %w", channelID, err)
+	}
+
+	if channel.Type != discordgo.ChannelTypeGuildText && channel.Type != discordgo.ChannelTypeGuildNews {
+		return fmt.Errorf("channel #%s is not a text channel", channel.Name)
+	}
+
+	permissions, err := s.UserChannelPermissions(s.State.User.ID, channelID)
+	if err != nil {
+		return fmt.Errorf("failed to get permissions in #%s: %w", channel.Name, err)
+	}
+	if permissions&postablePermissions != postablePermissions {
+		return fmt.Errorf("the bot needs the View Channel and Send Messages permissions in #%s", channel.Name)
+	}
+
+	return nil
+}
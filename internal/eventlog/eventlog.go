@@ -0,0 +1,138 @@
+// Package eventlog records dose events in an append-only log, alongside the current state kept in the
+// reminders table, so history can be audited and the state rebuilt from it.
+package eventlog
+
+import (
+	"context"
+	"fmt"
+
+	"meds-bot/internal/db"
+	"meds-bot/internal/events"
+)
+
+// Store appends to and reads the dose event log
+type Store interface {
+	AppendDoseEvent(ctx context.Context, event *db.DoseEvent) error
+}
+
+// Subscribe logs the dose events published on the bus
+func Subscribe(bus *events.Bus, store Store) {
+	events.On(bus, func(ctx context.Context, event events.ReminderSent) error {
+		return appendEvent(ctx, store, &db.DoseEvent{
+			Medication:    event.Medication,
+			Date:          event.Date,
+			Type:          db.DoseEventReminded,
+			ReminderID:    event.ReminderID,
+			Source:        event.Channel,
+			CorrelationID: event.CorrelationID,
+		})
+	})
+	events.On(bus, func(ctx context.Context, event events.ReminderUndelivered) error {
+		return appendEvent(ctx, store, &db.DoseEvent{
+			Medication:    event.Medication,
+			Date:          event.Date,
+			Type:          db.DoseEventUndelivered,
+			ReminderID:    event.ReminderID,
+			Source:        event.Channel,
+			CorrelationID: event.CorrelationID,
+		})
+	})
+	events.On(bus, func(ctx context.Context, event events.DoseAcknowledged) error {
+		return appendEvent(ctx, store, &db.DoseEvent{
+			Medication:    event.Medication,
+			Date:          event.Date,
+			Type:          db.DoseEventAcknowledged,
+			ReminderID:    event.ReminderID,
+			Source:        event.Source,
+			CorrelationID: event.CorrelationID,
+		})
+	})
+	events.On(bus, func(ctx context.Context, event events.DosePendingConfirmation) error {
+		return appendEvent(ctx, store, &db.DoseEvent{
+			Medication:    event.Medication,
+			Date:          event.Date,
+			Type:          db.DoseEventPendingConfirmation,
+			ReminderID:    event.ReminderID,
+			Source:        "Discord user " + event.UserID,
+			CorrelationID: event.CorrelationID,
+		})
+	})
+	events.On(bus, func(ctx context.Context, event events.DoseSkipped) error {
+		return appendEvent(ctx, store, &db.DoseEvent{
+			Medication:    event.Medication,
+			Date:          event.Date,
+			Type:          db.DoseEventSkipped,
+			ReminderID:    event.ReminderID,
+			Source:        event.Reason,
+			CorrelationID: event.CorrelationID,
+		})
+	})
+	events.On(bus, func(ctx context.Context, event events.DoseMissed) error {
+		return appendEvent(ctx, store, &db.DoseEvent{
+			Medication:    event.Medication,
+			Date:          event.Date,
+			Type:          db.DoseEventMissed,
+			ReminderID:    event.ReminderID,
+			CorrelationID: event.CorrelationID,
+		})
+	})
+}
+
+// appendEvent adds an event to the log
+func appendEvent(ctx context.Context, store Store, event *db.DoseEvent) error {
+	if err := store.AppendDoseEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to log %s event for %s: %w", event.Type, event.Medication, err)
+	}
+	return nil
+}
+
+// Replay rebuilds the state of each dose from its events, in the order the doses first appear.
+// Reminders count as nags and set the last reminder time until the dose is acknowledged, and the first
+// acknowledgment sets the time it was taken, matching how the reminders table is updated. A skip holds
+// until the dose is taken, which undoes it. Logged doses of as-needed medications have no reminder, so
+// are left out.
+func Replay(log []db.DoseEvent) []db.Reminder {
+	type key struct{ date, medication string }
+
+	var order []key
+	reminders := make(map[key]*db.Reminder)
+
+	for _, event := range log {
+		if event.Type == db.DoseEventLogged {
+			continue
+		}
+		k := key{event.Date, event.Medication}
+		reminder, ok := reminders[k]
+		if !ok {
+			reminder = &db.Reminder{ID: event.ReminderID, Date: event.Date, MedicationType: event.Medication}
+			reminders[k] = reminder
+			order = append(order, k)
+		}
+
+		switch event.Type {
+		case db.DoseEventReminded:
+			if !reminder.Resolved() {
+				reminder.NagCount++
+				reminder.LastReminderTime = event.CreatedAt
+			}
+		case db.DoseEventAcknowledged:
+			if !reminder.Acknowledged {
+				reminder.Acknowledged = true
+				reminder.AcknowledgedAt = event.CreatedAt
+				reminder.Skipped = false
+				reminder.SkipReason = ""
+			}
+		case db.DoseEventSkipped:
+			if !reminder.Acknowledged {
+				reminder.Skipped = true
+				reminder.SkipReason = event.Source
+			}
+		}
+	}
+
+	result := make([]db.Reminder, 0, len(order))
+	for _, k := range order {
+		result = append(result, *reminders[k])
+	}
+	return result
+}
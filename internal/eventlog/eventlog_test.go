@@ -0,0 +1,87 @@
+package eventlog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"meds-bot/internal/db"
+	"meds-bot/internal/events"
+)
+
+type memoryStore struct {
+	events []db.DoseEvent
+}
+
+func (m *memoryStore) AppendDoseEvent(ctx context.Context, event *db.DoseEvent) error {
+	event.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func TestSubscribe(t *testing.T) {
+	bus := events.NewBus()
+	store := &memoryStore{}
+	Subscribe(bus, store)
+
+	ctx := context.Background()
+	bus.Publish(ctx, events.ReminderUndelivered{Medication: "Morning Pill", Date: "2024-01-01", ReminderID: 1, CorrelationID: "a1", Channel: events.ChannelDiscord})
+	bus.Publish(ctx, events.ReminderSent{Medication: "Morning Pill", Date: "2024-01-01", ReminderID: 1, CorrelationID: "a1"})
+	bus.Publish(ctx, events.DosePendingConfirmation{Medication: "Morning Pill", Date: "2024-01-01", ReminderID: 1, UserID: "42", CorrelationID: "a1"})
+	bus.Publish(ctx, events.DoseAcknowledged{Medication: "Morning Pill", Date: "2024-01-01", ReminderID: 1, Source: "Discord", CorrelationID: "a1"})
+	bus.Publish(ctx, events.DoseMissed{Medication: "Evening Pill", Date: "2024-01-01", ReminderID: 2, CorrelationID: "b2"})
+
+	want := []string{db.DoseEventUndelivered, db.DoseEventReminded, db.DoseEventPendingConfirmation, db.DoseEventAcknowledged, db.DoseEventMissed}
+	if len(store.events) != len(want) {
+		t.Fatalf("logged %d events, want %d", len(store.events), len(want))
+	}
+	wantCorrelationIDs := []string{"a1", "a1", "a1", "a1", "b2"}
+	for i, event := range store.events {
+		if event.Type != want[i] {
+			t.Errorf("event %d type = %s, want %s", i, event.Type, want[i])
+		}
+		if event.CorrelationID != wantCorrelationIDs[i] {
+			t.Errorf("event %d correlation ID = %q, want %q", i, event.CorrelationID, wantCorrelationIDs[i])
+		}
+	}
+	if store.events[0].Source != "Discord" {
+		t.Errorf("undelivered source = %q, want Discord", store.events[0].Source)
+	}
+	if store.events[2].Source != "Discord user 42" {
+		t.Errorf("pending confirmation source = %q, want the child's user", store.events[1].Source)
+	}
+	if store.events[3].Source != "Discord" {
+		t.Errorf("acknowledgment source = %q, want Discord", store.events[2].Source)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	log := []db.DoseEvent{
+		{Medication: "Morning Pill", Date: "2024-01-01", Type: db.DoseEventReminded, ReminderID: 1, CreatedAt: start},
+		{Medication: "Evening Pill", Date: "2024-01-01", Type: db.DoseEventReminded, ReminderID: 2, CreatedAt: start.Add(time.Hour)},
+		{Medication: "Morning Pill", Date: "2024-01-01", Type: db.DoseEventReminded, ReminderID: 1, CreatedAt: start.Add(2 * time.Hour)},
+		{Medication: "Morning Pill", Date: "2024-01-01", Type: db.DoseEventAcknowledged, ReminderID: 1, CreatedAt: start.Add(3 * time.Hour)},
+		// A late duplicate acknowledgment doesn't move the time the dose was taken
+		{Medication: "Morning Pill", Date: "2024-01-01", Type: db.DoseEventAcknowledged, ReminderID: 1, CreatedAt: start.Add(4 * time.Hour)},
+		{Medication: "Evening Pill", Date: "2024-01-01", Type: db.DoseEventMissed, ReminderID: 2, CreatedAt: start.Add(5 * time.Hour)},
+		// As-needed doses have no reminder to rebuild
+		{Medication: "Painkiller", Date: "2024-01-01", Type: db.DoseEventLogged, CreatedAt: start.Add(6 * time.Hour)},
+	}
+
+	got := Replay(log)
+	if len(got) != 2 {
+		t.Fatalf("Replay() returned %d reminders, want 2", len(got))
+	}
+
+	morning := got[0]
+	if morning.MedicationType != "Morning Pill" || !morning.Acknowledged || morning.NagCount != 2 ||
+		!morning.LastReminderTime.Equal(start.Add(2*time.Hour)) || !morning.AcknowledgedAt.Equal(start.Add(3*time.Hour)) {
+		t.Errorf("morning = %+v, want taken at 11:00 after 2 reminders, the last at 10:00", morning)
+	}
+
+	evening := got[1]
+	if evening.Acknowledged || evening.NagCount != 1 {
+		t.Errorf("evening = %+v, want missed after 1 reminder", evening)
+	}
+}
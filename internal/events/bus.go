@@ -0,0 +1,83 @@
+// Package events provides an in-process event bus that decouples the modules producing
+// reminder and dose events from those acting on them.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// ErrDeliveryPaused is returned by subscribers that can't act on an event for now, such as while the
+// reminder channel can't be reached. It isn't a failure, and the event should be published again later.
+var ErrDeliveryPaused = errors.New("delivery is paused")
+
+// Event is something that happened which other modules may act on
+type Event interface {
+	// EventName identifies the type of event subscribers are registered for
+	EventName() string
+}
+
+// Handler acts on a published event
+type Handler func(ctx context.Context, event Event) error
+
+// Bus delivers published events to their subscribers
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+// NewBus creates a new event bus
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]Handler)}
+}
+
+// Subscribe registers a handler for events with the given name
+func (b *Bus) Subscribe(name string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[name] = append(b.handlers[name], handler)
+}
+
+// On registers a handler for events of type T
+func On[T Event](b *Bus, handler func(ctx context.Context, event T) error) {
+	var zero T
+	b.Subscribe(zero.EventName(), func(ctx context.Context, event Event) error {
+		typed, ok := event.(T)
+		if !ok {
+			return fmt.Errorf("unexpected %T for %s event", event, zero.EventName())
+		}
+		return handler(ctx, typed)
+	})
+}
+
+// Publish delivers an event to its subscribers in the order they subscribed, returning their
+// combined errors. A failing or panicking subscriber doesn't stop the others from receiving the event.
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := b.handlers[event.EventName()]
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := deliver(ctx, handler, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// deliver runs a single handler, converting a panic into an error
+func deliver(ctx context.Context, handler Handler, event Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic handling %s event: %v", event.EventName(), r)
+			err = fmt.Errorf("panic handling %s event: %v", event.EventName(), r)
+		}
+	}()
+
+	return handler(ctx, event)
+}
@@ -0,0 +1,44 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBusPublish(t *testing.T) {
+	bus := NewBus()
+
+	var received []string
+	On(bus, func(ctx context.Context, event DoseAcknowledged) error {
+		received = append(received, "first:"+event.Medication)
+		return nil
+	})
+	On(bus, func(ctx context.Context, event DoseAcknowledged) error {
+		panic("subscriber bug")
+	})
+	On(bus, func(ctx context.Context, event DoseAcknowledged) error {
+		received = append(received, "last:"+event.Medication)
+		return errors.New("failed")
+	})
+	On(bus, func(ctx context.Context, event DoseMissed) error {
+		received = append(received, "missed:"+event.Medication)
+		return nil
+	})
+
+	err := bus.Publish(context.Background(), DoseAcknowledged{Medication: "Morning Pill"})
+	if err == nil {
+		t.Error("Publish() expected the failing subscribers' errors")
+	}
+
+	want := []string{"first:Morning Pill", "last:Morning Pill"}
+	if len(received) != len(want) || received[0] != want[0] || received[1] != want[1] {
+		t.Errorf("received = %v, want %v", received, want)
+	}
+}
+
+func TestBusPublishWithoutSubscribers(t *testing.T) {
+	if err := NewBus().Publish(context.Background(), ChecklistDue{Date: "2024-01-01"}); err != nil {
+		t.Errorf("Publish() error = %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package events
+
+import (
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/stats"
+)
+
+// ReminderDue is published when a reminder for a dose should be sent
+type ReminderDue struct {
+	Medication config.Medication
+	Reminder   *db.Reminder
+	// Escalate mentions everyone in the channel in addition to the configured user
+	Escalate bool
+	// DueAt is when the dose was due today, after any schedule adjustments
+	DueAt time.Time
+}
+
+// ReminderSent is published once a reminder message has been sent
+type ReminderSent struct {
+	Medication string
+	Date       string
+	ReminderID int64
+	MessageID  string
+	// NagCount is the number of reminders sent for the dose, including this one
+	NagCount int
+	// CorrelationID identifies the dose cycle, as recorded on its reminder
+	CorrelationID string
+	// Channel is what delivered the reminder, such as "Discord" or a backup channel like "ntfy"
+	Channel string
+}
+
+// ChannelDiscord is the Channel of reminders delivered by Discord
+const ChannelDiscord = db.ChannelDiscord
+
+// ReminderUndelivered is published when a channel couldn't send a reminder that was due
+type ReminderUndelivered struct {
+	Medication    string
+	Date          string
+	ReminderID    int64
+	Err           error
+	CorrelationID string
+	// Channel is what failed to deliver the reminder, such as "Discord" or a backup channel like "ntfy"
+	Channel string
+}
+
+// HeadsUpDue is published when a medication is coming up within its lead time
+type HeadsUpDue struct {
+	Medication config.Medication
+	Reminder   *db.Reminder
+	DueAt      time.Time
+}
+
+// ChecklistDue is published when the day's checklist should be posted
+type ChecklistDue struct {
+	Date string
+}
+
+// DoseAcknowledged is published when a dose is marked as taken
+type DoseAcknowledged struct {
+	Medication string
+	Date       string
+	ReminderID int64
+	// Source describes where the dose was acknowledged, such as "Discord" or "acknowledgment link"
+	Source        string
+	CorrelationID string
+}
+
+// DosePendingConfirmation is published for each confirmation of a dose that needs more than one, such
+// as a child's dose confirmed by their guardian, until it has them all and is acknowledged
+type DosePendingConfirmation struct {
+	Medication    string
+	Date          string
+	ReminderID    int64
+	UserID        string
+	CorrelationID string
+}
+
+// DoseSkipped is published when a dose is skipped on purpose, such as when fasting for a blood test
+type DoseSkipped struct {
+	Medication string
+	Date       string
+	ReminderID int64
+	// Reason is why the dose was skipped, empty if none was given
+	Reason        string
+	CorrelationID string
+}
+
+// DoseMissed is published when a dose's reminder window closes without it being taken
+type DoseMissed struct {
+	Medication    string
+	Date          string
+	ReminderID    int64
+	NagCount      int
+	CorrelationID string
+}
+
+// UserActive is published when the user to ping is first seen active on a day, by checking in,
+// using a command or coming online
+type UserActive struct {
+	At time.Time
+}
+
+// ArchiveDue is published once a day has passed, to archive its reminder messages
+type ArchiveDue struct {
+	Date      string
+	Reminders []db.Reminder
+}
+
+// CleanupDue is published once a day to delete the bot's messages from before a cutoff
+type CleanupDue struct {
+	Before time.Time
+}
+
+// RefillDue is published when a medication is approaching its refill due date
+type RefillDue struct {
+	Info *db.MedicationInfo
+}
+
+// LowSupply is published once when a medication's tracked stock falls to the low supply threshold,
+// and again after it's been refilled above it
+type LowSupply struct {
+	Forecast stats.StockForecast
+}
+
+// LabTestDue is published when a lab test is due
+type LabTestDue struct {
+	Test *db.LabTest
+}
+
+// WeeklyReportDue is published when the weekly report should be sent
+type WeeklyReportDue struct {
+	Report *stats.WeeklyReport
+}
+
+func (ReminderDue) EventName() string             { return "reminder_due" }
+func (ReminderSent) EventName() string            { return "reminder_sent" }
+func (ReminderUndelivered) EventName() string     { return "reminder_undelivered" }
+func (HeadsUpDue) EventName() string              { return "heads_up_due" }
+func (ChecklistDue) EventName() string            { return "checklist_due" }
+func (DoseAcknowledged) EventName() string        { return "dose_acknowledged" }
+func (DosePendingConfirmation) EventName() string { return "dose_pending_confirmation" }
+func (DoseSkipped) EventName() string             { return "dose_skipped" }
+func (DoseMissed) EventName() string              { return "dose_missed" }
+func (ArchiveDue) EventName() string              { return "archive_due" }
+func (CleanupDue) EventName() string              { return "cleanup_due" }
+func (RefillDue) EventName() string               { return "refill_due" }
+func (LowSupply) EventName() string               { return "low_supply" }
+func (LabTestDue) EventName() string              { return "lab_test_due" }
+func (WeeklyReportDue) EventName() string         { return "weekly_report_due" }
+func (UserActive) EventName() string              { return "user_active" }
@@ -0,0 +1,527 @@
+package export
+
+import (
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"meds-bot/internal/db"
+)
+
+// Export paths
+const (
+	// DosesPath is the HTTP path dose events are exported from
+	DosesPath = "/export/doses"
+	// MedicationsPath is the HTTP path medication details and contacts are exported from
+	MedicationsPath = "/export/medications"
+	// RefillsPath is the HTTP path refill costs are exported from
+	RefillsPath = "/export/refills"
+	// EventsPath is the HTTP path the dose event log is exported from
+	EventsPath = "/export/events"
+)
+
+// defaultDays is how far back the export goes when no start date is given
+const defaultDays = 7
+
+// Sample is a dose event in a shape that maps onto health app samples
+// (e.g. Apple Health's "Log Health Sample" shortcut action or a Google Fit data point)
+type Sample struct {
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	Status        string `json:"status"`
+	ScheduledDate string `json:"scheduledDate"`
+	StartDate     string `json:"startDate,omitempty"`
+	EndDate       string `json:"endDate,omitempty"`
+	Value         int    `json:"value"`
+	Unit          string `json:"unit"`
+}
+
+// Handler serves dose events for health app integrations
+type Handler struct {
+	store    db.StoreInterface
+	token    string
+	location *time.Location
+}
+
+// NewHandler creates a new HTTP handler exporting dose events, authenticated with the given token
+func NewHandler(store db.StoreInterface, token string, location *time.Location) *Handler {
+	if location == nil {
+		location = time.UTC
+	}
+
+	return &Handler{
+		store:    store,
+		token:    token,
+		location: location,
+	}
+}
+
+// ServeHTTP exports dose events as JSON (default) or CSV.
+// The start date is taken from the "since" query parameter (YYYY-MM-DD), or "days" ago.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !Authorized(r, h.token) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	since, err := parseSince(r, h.location)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reminders, err := h.store.GetReminderHistory(r.Context(), r.URL.Query().Get("medication"), since)
+	if err != nil {
+		log.Printf("Error exporting dose history: %v", err)
+		http.Error(w, "Failed to load dose history", http.StatusInternalServerError)
+		return
+	}
+
+	samples := make([]Sample, 0, len(reminders))
+	for _, reminder := range reminders {
+		samples = append(samples, h.toSample(reminder))
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "csv":
+		writeCSV(w, samples)
+	case "", "json":
+		writeJSON(w, samples)
+	default:
+		http.Error(w, "Unsupported format, use json or csv", http.StatusBadRequest)
+	}
+}
+
+// Authorized checks the bearer token or token query parameter against the expected token
+func Authorized(r *http.Request, expected string) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// HeaderAuthorized reports whether the request carries the expected bearer token in its Authorization
+// header. Endpoints that change anything use it rather than Authorized, since tokens in URLs end up in
+// proxy and access logs.
+func HeaderAuthorized(r *http.Request, expected string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// parseSince determines the start date of the export
+func parseSince(r *http.Request, location *time.Location) (time.Time, error) {
+	query := r.URL.Query()
+
+	if since := query.Get("since"); since != "" {
+		t, err := time.ParseInLocation("2006-01-02", since, location)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid since date, expected YYYY-MM-DD")
+		}
+		return t, nil
+	}
+
+	days := defaultDays
+	if daysStr := query.Get("days"); daysStr != "" {
+		parsed, err := strconv.Atoi(daysStr)
+		if err != nil || parsed < 1 {
+			return time.Time{}, fmt.Errorf("invalid days, expected a positive number")
+		}
+		days = parsed
+	}
+
+	return time.Now().In(location).AddDate(0, 0, -days), nil
+}
+
+// toSample converts a reminder into a health sample
+func (h *Handler) toSample(reminder db.Reminder) Sample {
+	sample := Sample{
+		Type:          "medication",
+		Name:          reminder.MedicationType,
+		Status:        "pending",
+		ScheduledDate: reminder.Date,
+		Unit:          "dose",
+	}
+
+	if reminder.Acknowledged {
+		sample.Status = "taken"
+		sample.Value = 1
+		if !reminder.AcknowledgedAt.IsZero() {
+			takenAt := reminder.AcknowledgedAt.In(h.location).Format(time.RFC3339)
+			sample.StartDate = takenAt
+			sample.EndDate = takenAt
+		}
+	} else if reminder.Skipped {
+		sample.Status = "skipped"
+	}
+
+	return sample
+}
+
+// writeJSON writes the samples as a JSON document
+func writeJSON(w http.ResponseWriter, samples []Sample) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string][]Sample{"samples": samples}); err != nil {
+		log.Printf("Error writing JSON export: %v", err)
+	}
+}
+
+// writeCSV writes the samples as CSV with a header row
+func writeCSV(w http.ResponseWriter, samples []Sample) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="doses.csv"`)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"medication", "status", "scheduled_date", "taken_at"})
+	for _, sample := range samples {
+		writer.Write([]string{sample.Name, sample.Status, sample.ScheduledDate, sample.StartDate})
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Error writing CSV export: %v", err)
+	}
+}
+
+// MedicationRecord is a medication's details with its linked prescriber and pharmacy contacts
+type MedicationRecord struct {
+	Name         string      `json:"name"`
+	Dose         string      `json:"dose"`
+	Instructions string      `json:"instructions"`
+	StartDate    string      `json:"startDate"`
+	RefillStatus string      `json:"refillStatus"`
+	LeafletURL   string      `json:"leafletUrl"`
+	Prescriber   *db.Contact `json:"prescriber,omitempty"`
+	Pharmacy     *db.Contact `json:"pharmacy,omitempty"`
+}
+
+// MedicationsHandler serves medication details and their prescriber and pharmacy contacts
+type MedicationsHandler struct {
+	store db.StoreInterface
+	token string
+}
+
+// NewMedicationsHandler creates a new HTTP handler exporting medication details, authenticated with the given token
+func NewMedicationsHandler(store db.StoreInterface, token string) *MedicationsHandler {
+	return &MedicationsHandler{
+		store: store,
+		token: token,
+	}
+}
+
+// ServeHTTP exports medication details as JSON (default) or CSV
+func (h *MedicationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !Authorized(r, h.token) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	records, err := h.records(r)
+	if err != nil {
+		log.Printf("Error exporting medications: %v", err)
+		http.Error(w, "Failed to load medications", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "csv":
+		writeMedicationsCSV(w, records)
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string][]MedicationRecord{"medications": records}); err != nil {
+			log.Printf("Error writing JSON export: %v", err)
+		}
+	default:
+		http.Error(w, "Unsupported format, use json or csv", http.StatusBadRequest)
+	}
+}
+
+// records loads each medication's details and linked contacts
+func (h *MedicationsHandler) records(r *http.Request) ([]MedicationRecord, error) {
+	infos, err := h.store.ListMedicationInfo(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]MedicationRecord, 0, len(infos))
+	for _, info := range infos {
+		record := MedicationRecord{
+			Name:         info.Name,
+			Dose:         info.Dose,
+			Instructions: info.Instructions,
+			StartDate:    info.StartDate,
+			RefillStatus: info.RefillStatus,
+			LeafletURL:   info.LeafletURL,
+		}
+
+		if info.Prescriber != "" {
+			record.Prescriber, err = h.store.GetContact(r.Context(), db.ContactPrescriber, info.Prescriber)
+			if err != nil {
+				return nil, err
+			}
+			if record.Prescriber == nil {
+				record.Prescriber = &db.Contact{Kind: db.ContactPrescriber, Name: info.Prescriber}
+			}
+		}
+
+		if info.Pharmacy != "" {
+			record.Pharmacy, err = h.store.GetContact(r.Context(), db.ContactPharmacy, info.Pharmacy)
+			if err != nil {
+				return nil, err
+			}
+			if record.Pharmacy == nil {
+				record.Pharmacy = &db.Contact{Kind: db.ContactPharmacy, Name: info.Pharmacy}
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// writeMedicationsCSV writes medication records as CSV with a header row
+func writeMedicationsCSV(w http.ResponseWriter, records []MedicationRecord) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="medications.csv"`)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"medication", "dose", "instructions", "start_date", "refill_status", "leaflet_url",
+		"prescriber", "prescriber_phone", "prescriber_email",
+		"pharmacy", "pharmacy_phone", "pharmacy_email",
+	})
+	for _, record := range records {
+		row := []string{record.Name, record.Dose, record.Instructions, record.StartDate, record.RefillStatus, record.LeafletURL}
+		for _, contact := range []*db.Contact{record.Prescriber, record.Pharmacy} {
+			if contact == nil {
+				row = append(row, "", "", "")
+				continue
+			}
+			row = append(row, contact.Name, contact.Phone, contact.Email)
+		}
+		writer.Write(row)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Error writing CSV export: %v", err)
+	}
+}
+
+// RefillRecord is a logged refill with its cost and copay
+type RefillRecord struct {
+	Medication string  `json:"medication"`
+	Date       string  `json:"date"`
+	Cost       float64 `json:"cost"`
+	Copay      float64 `json:"copay"`
+}
+
+// RefillsHandler serves logged refills and their costs for insurance reimbursement
+type RefillsHandler struct {
+	store    db.StoreInterface
+	token    string
+	location *time.Location
+}
+
+// NewRefillsHandler creates a new HTTP handler exporting refill costs, authenticated with the given token
+func NewRefillsHandler(store db.StoreInterface, token string, location *time.Location) *RefillsHandler {
+	if location == nil {
+		location = time.UTC
+	}
+
+	return &RefillsHandler{
+		store:    store,
+		token:    token,
+		location: location,
+	}
+}
+
+// ServeHTTP exports the refills for a year (the "year" query parameter, defaulting to this year)
+// as CSV (default) or JSON
+func (h *RefillsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !Authorized(r, h.token) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	year := time.Now().In(h.location).Year()
+	if yearStr := r.URL.Query().Get("year"); yearStr != "" {
+		parsed, err := strconv.Atoi(yearStr)
+		if err != nil {
+			http.Error(w, "invalid year", http.StatusBadRequest)
+			return
+		}
+		year = parsed
+	}
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, h.location)
+	to := time.Date(year, time.December, 31, 0, 0, 0, 0, h.location)
+
+	refills, err := h.store.GetRefills(r.Context(), from, to)
+	if err != nil {
+		log.Printf("Error exporting refills: %v", err)
+		http.Error(w, "Failed to load refills", http.StatusInternalServerError)
+		return
+	}
+
+	records := make([]RefillRecord, 0, len(refills))
+	for _, refill := range refills {
+		records = append(records, RefillRecord{
+			Medication: refill.Medication,
+			Date:       refill.Date,
+			Cost:       float64(refill.CostCents) / 100,
+			Copay:      float64(refill.CopayCents) / 100,
+		})
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string][]RefillRecord{"refills": records}); err != nil {
+			log.Printf("Error writing JSON export: %v", err)
+		}
+	case "", "csv":
+		writeRefillsCSV(w, records, year)
+	default:
+		http.Error(w, "Unsupported format, use json or csv", http.StatusBadRequest)
+	}
+}
+
+// writeRefillsCSV writes refill records as CSV with a header row
+func writeRefillsCSV(w http.ResponseWriter, records []RefillRecord, year int) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="refills-%d.csv"`, year))
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"medication", "date", "cost", "copay"})
+	for _, record := range records {
+		writer.Write([]string{
+			record.Medication,
+			record.Date,
+			strconv.FormatFloat(record.Cost, 'f', 2, 64),
+			strconv.FormatFloat(record.Copay, 'f', 2, 64),
+		})
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Error writing CSV export: %v", err)
+	}
+}
+
+// EventRecord is an entry in the dose event log
+type EventRecord struct {
+	Medication string `json:"medication"`
+	Date       string `json:"date"`
+	Type       string `json:"type"`
+	Source     string `json:"source,omitempty"`
+	// CorrelationID identifies the dose cycle the event belongs to
+	CorrelationID string `json:"correlation_id,omitempty"`
+	Time          string `json:"time"`
+}
+
+// EventsHandler serves the dose event log for auditing
+type EventsHandler struct {
+	store    db.StoreInterface
+	token    string
+	location *time.Location
+}
+
+// NewEventsHandler creates a new HTTP handler exporting the dose event log, authenticated with the given token
+func NewEventsHandler(store db.StoreInterface, token string, location *time.Location) *EventsHandler {
+	if location == nil {
+		location = time.UTC
+	}
+
+	return &EventsHandler{
+		store:    store,
+		token:    token,
+		location: location,
+	}
+}
+
+// ServeHTTP exports the dose event log in the order events happened, as JSON (default) or CSV.
+// The range and medication are chosen the same way as the dose export.
+func (h *EventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !Authorized(r, h.token) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	since, err := parseSince(r, h.location)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.store.GetDoseEvents(r.Context(), r.URL.Query().Get("medication"), since)
+	if err != nil {
+		log.Printf("Error exporting dose events: %v", err)
+		http.Error(w, "Failed to load dose events", http.StatusInternalServerError)
+		return
+	}
+
+	records := make([]EventRecord, 0, len(events))
+	for _, event := range events {
+		records = append(records, EventRecord{
+			Medication:    event.Medication,
+			Date:          event.Date,
+			Type:          event.Type,
+			Source:        event.Source,
+			CorrelationID: event.CorrelationID,
+			Time:          event.CreatedAt.In(h.location).Format(time.RFC3339),
+		})
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="events.csv"`)
+
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"medication", "date", "type", "source", "correlation_id", "time"})
+		for _, record := range records {
+			writer.Write([]string{record.Medication, record.Date, record.Type, record.Source, record.CorrelationID, record.Time})
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			log.Printf("Error writing CSV export: %v", err)
+		}
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string][]EventRecord{"events": records}); err != nil {
+			log.Printf("Error writing JSON export: %v", err)
+		}
+	default:
+		http.Error(w, "Unsupported format, use json or csv", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,179 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"meds-bot/internal/db"
+)
+
+// FeedPath is the HTTP path the Atom feed of dose events is served from
+const FeedPath = "/export/feed"
+
+// maxFeedEntries is how many of the most recent events the feed holds
+const maxFeedEntries = 100
+
+// atomFeed is an Atom feed (RFC 4287)
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// atomEntry is an entry of the feed. Entries have no page to link to, so RFC 4287 requires their
+// content rather than a link.
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+// FeedHandler serves recent dose events as an Atom feed, for feed readers and dashboards that can't
+// receive webhooks or MQTT
+type FeedHandler struct {
+	store    db.StoreInterface
+	token    string
+	location *time.Location
+}
+
+// NewFeedHandler creates a new HTTP handler serving the Atom feed of dose events, authenticated with the given token
+func NewFeedHandler(store db.StoreInterface, token string, location *time.Location) *FeedHandler {
+	if location == nil {
+		location = time.UTC
+	}
+
+	return &FeedHandler{
+		store:    store,
+		token:    token,
+		location: location,
+	}
+}
+
+// ServeHTTP serves the most recent dose events, newest first. The range and medication are chosen the
+// same way as the dose export. Feed readers that can't send headers can pass the token as a query parameter.
+func (h *FeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !Authorized(r, h.token) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	since, err := parseSince(r, h.location)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.store.GetDoseEvents(r.Context(), r.URL.Query().Get("medication"), since)
+	if err != nil {
+		log.Printf("Error loading dose events for the feed: %v", err)
+		http.Error(w, "Failed to load dose events", http.StatusInternalServerError)
+		return
+	}
+
+	slices.Reverse(events)
+	if len(events) > maxFeedEntries {
+		events = events[:maxFeedEntries]
+	}
+
+	feed := atomFeed{
+		ID:      "urn:meds-bot:dose-events",
+		Title:   "Medication doses",
+		Updated: time.Now().In(h.location).Format(time.RFC3339),
+		Author:  atomAuthor{Name: "meds-bot"},
+		// The token is left out of the link, so it isn't shown by feed readers
+		Link: atomLink{Rel: "self", Href: FeedPath},
+	}
+	if len(events) > 0 {
+		feed.Updated = events[0].CreatedAt.In(h.location).Format(time.RFC3339)
+	}
+	for _, event := range events {
+		feed.Entries = append(feed.Entries, h.toEntry(event))
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		log.Printf("Error writing Atom feed: %v", err)
+	}
+}
+
+// toEntry converts a dose event into a feed entry
+func (h *FeedHandler) toEntry(event db.DoseEvent) atomEntry {
+	var title string
+	switch event.Type {
+	case db.DoseEventReminded:
+		title = fmt.Sprintf("🔔 Reminded to take %s", event.Medication)
+	case db.DoseEventAcknowledged:
+		title = fmt.Sprintf("✅ %s taken", event.Medication)
+	case db.DoseEventSkipped:
+		title = fmt.Sprintf("⏭️ %s skipped", event.Medication)
+	case db.DoseEventMissed:
+		title = fmt.Sprintf("❌ %s missed", event.Medication)
+	case db.DoseEventPendingConfirmation:
+		title = fmt.Sprintf("⏳ %s waiting for confirmation", event.Medication)
+	case db.DoseEventUndelivered:
+		title = fmt.Sprintf("⚠️ Reminder to take %s failed to send", event.Medication)
+	case db.DoseEventLogged:
+		title = fmt.Sprintf("💊 %s taken as needed", event.Medication)
+	default:
+		title = fmt.Sprintf("%s: %s", event.Medication, event.Type)
+	}
+
+	content := fmt.Sprintf("Dose of %s on %s", event.Medication, event.Date)
+	switch {
+	case event.Source == "":
+	case event.Type == db.DoseEventSkipped:
+		content += ", skipped because: " + event.Source
+	case event.Type == db.DoseEventUndelivered:
+		content += ", not delivered by " + event.Source
+	default:
+		content += ", via " + event.Source
+	}
+
+	return atomEntry{
+		ID:         "urn:meds-bot:dose-event:" + strconv.FormatInt(event.ID, 10),
+		Title:      title,
+		Updated:    event.CreatedAt.In(h.location).Format(time.RFC3339),
+		Categories: []atomCategory{{Term: event.Type}},
+		Content:    atomContent{Type: "text", Text: content},
+	}
+}
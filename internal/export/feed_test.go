@@ -0,0 +1,77 @@
+package export
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"meds-bot/internal/db"
+)
+
+// TestFeed tests that the feed holds the most recent events newest first, each with the content RFC 4287
+// requires of entries without a link
+func TestFeed(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore(time.UTC)
+	today := time.Now().UTC().Format("2006-01-02")
+	for _, event := range []db.DoseEvent{
+		{Medication: "Morning", Date: today, Type: db.DoseEventReminded, CreatedAt: time.Now().Add(-2 * time.Hour)},
+		{Medication: "Morning", Date: today, Type: db.DoseEventAcknowledged, Source: "discord", CreatedAt: time.Now().Add(-time.Hour)},
+		{Medication: "Evening", Date: today, Type: db.DoseEventSkipped, Source: "fasting", CreatedAt: time.Now()},
+	} {
+		if err := store.AppendDoseEvent(ctx, &event); err != nil {
+			t.Fatalf("AppendDoseEvent failed: %v", err)
+		}
+	}
+	handler := NewFeedHandler(store, "feed-token", time.UTC)
+
+	// Unauthorized requests are rejected
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, FeedPath, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Status without a token = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, FeedPath+"?token=feed-token", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/atom+xml; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want an Atom feed", contentType)
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("Failed to parse the feed: %v", err)
+	}
+	if len(feed.Entries) != 3 {
+		t.Fatalf("Got %d entries, want 3", len(feed.Entries))
+	}
+	if feed.Updated != feed.Entries[0].Updated {
+		t.Errorf("Feed updated %s, want the newest entry's %s", feed.Updated, feed.Entries[0].Updated)
+	}
+
+	want := []struct {
+		title   string
+		content string
+	}{
+		{"⏭️ Evening skipped", "Dose of Evening on " + today + ", skipped because: fasting"},
+		{"✅ Morning taken", "Dose of Morning on " + today + ", via discord"},
+		{"🔔 Reminded to take Morning", "Dose of Morning on " + today},
+	}
+	for i, entry := range feed.Entries {
+		if entry.ID == "" || entry.Updated == "" {
+			t.Errorf("Entry %d is missing its ID or updated time: %+v", i, entry)
+		}
+		if entry.Title != want[i].title {
+			t.Errorf("Entry %d title = %q, want %q", i, entry.Title, want[i].title)
+		}
+		if entry.Content.Type != "text" || entry.Content.Text != want[i].content {
+			t.Errorf("Entry %d content = %+v, want text %q", i, entry.Content, want[i].content)
+		}
+	}
+}
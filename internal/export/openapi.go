@@ -0,0 +1,25 @@
+package export
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPIPath is the HTTP path the OpenAPI document describing the API is served from
+const OpenAPIPath = "/api/openapi.json"
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPIHandler serves the OpenAPI 3 document for the export and acknowledgment link endpoints
+func OpenAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+}
@@ -0,0 +1,228 @@
+package failover
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Timeout is how long a backup channel's service has to respond
+const Timeout = 10 * time.Second
+
+// twilioAPIURL is the base URL of Twilio's REST API
+const twilioAPIURL = "https://api.twilio.com/2010-04-01"
+
+// Ntfy publishes reminders to an ntfy topic (https://ntfy.sh), which pushes them to the ntfy app
+type Ntfy struct {
+	topicURL string
+	token    string
+	client   *http.Client
+}
+
+// NewNtfy creates a channel publishing to the topic URL, authenticated with the access token if it's set
+func NewNtfy(topicURL, token string) *Ntfy {
+	return &Ntfy{topicURL: topicURL, token: token, client: &http.Client{Timeout: Timeout}}
+}
+
+// Name identifies the channel
+func (n *Ntfy) Name() string { return "ntfy" }
+
+// Send publishes a message at high priority, opening the acknowledgment link when it's tapped
+func (n *Ntfy) Send(ctx context.Context, message Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.topicURL, strings.NewReader(message.Body))
+	if err != nil {
+		return fmt.Errorf("failed to create ntfy request: %w", err)
+	}
+	req.Header.Set("Title", message.Title)
+	req.Header.Set("Priority", "high")
+	req.Header.Set("Tags", "pill")
+	if message.AckURL != "" {
+		req.Header.Set("Actions", fmt.Sprintf("view, Mark as taken, %s, clear=true", message.AckURL))
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish to ntfy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("ntfy returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Email sends reminders by email through an SMTP server, using STARTTLS when the server supports it
+type Email struct {
+	addr     string
+	username string
+	password string
+	from     string
+	to       string
+}
+
+// NewEmail creates a channel emailing the address through the server at addr (host:port), logging in
+// if a username is set
+func NewEmail(addr, username, password, from, to string) *Email {
+	return &Email{addr: addr, username: username, password: password, from: from, to: to}
+}
+
+// Name identifies the channel
+func (e *Email) Name() string { return "email" }
+
+// Send emails a message
+func (e *Email) Send(ctx context.Context, message Message) error {
+	var auth smtp.Auth
+	if e.username != "" {
+		host, _, err := net.SplitHostPort(e.addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address %q: %w", e.addr, err)
+		}
+		auth = smtp.PlainAuth("", e.username, e.password, host)
+	}
+
+	body := message.Body
+	if message.AckURL != "" {
+		body += "\r\n\r\nMark it as taken: " + message.AckURL
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.from)
+	fmt.Fprintf(&b, "To: %s\r\n", e.to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", message.Title)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(body)
+	b.WriteString("\r\n")
+
+	if err := e.sendMail(ctx, auth, []byte(b.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// sendMail sends a message as smtp.SendMail does, over a connection dialed with the context that gives
+// up at the context's deadline, or Timeout, so a stalled server can't hold it open
+func (e *Email) sendMail(ctx context.Context, auth smtp.Auth, msg []byte) error {
+	host, _, err := net.SplitHostPort(e.addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address %q: %w", e.addr, err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(Timeout)
+	}
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(e.from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(e.to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// SMS texts reminders through Twilio
+type SMS struct {
+	accountSID string
+	authToken  string
+	from       string
+	to         string
+	apiURL     string
+	client     *http.Client
+}
+
+// NewSMS creates a channel texting the number from a Twilio number
+func NewSMS(accountSID, authToken, from, to string) *SMS {
+	return &SMS{
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		to:         to,
+		apiURL:     twilioAPIURL,
+		client:     &http.Client{Timeout: Timeout},
+	}
+}
+
+// Name identifies the channel
+func (s *SMS) Name() string { return "sms" }
+
+// Send texts a message
+func (s *SMS) Send(ctx context.Context, message Message) error {
+	text := message.Title + ". " + message.Body
+	if message.AckURL != "" {
+		text += " Mark it as taken: " + message.AckURL
+	}
+
+	form := url.Values{}
+	form.Set("From", s.from)
+	form.Set("To", s.to)
+	form.Set("Body", text)
+
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", s.apiURL, url.PathEscape(s.accountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create Twilio request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.accountSID, s.authToken)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send SMS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Twilio returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+// Package failover delivers reminders through backup channels (ntfy, email and SMS) while Discord is
+// down, so a Discord outage doesn't mean a missed dose. Discord is considered down once several reminders
+// in a row have failed to send, the bot can't post in the reminder channel, or the gateway has been
+// disconnected for a while. Each reminder is recorded with the channel that actually delivered it.
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"meds-bot/internal/acklink"
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/events"
+)
+
+// gatewayCheckInterval is how often the gateway connection is checked
+const gatewayCheckInterval = 30 * time.Second
+
+// maxUpdateAttempts is how many times a reminder is reread and updated when it changes concurrently
+const maxUpdateAttempts = 3
+
+// Channel is a backup channel reminders can be delivered by
+type Channel interface {
+	// Name identifies the channel in logs and the dose event log
+	Name() string
+	Send(ctx context.Context, message Message) error
+}
+
+// Message is a reminder delivered by a backup channel
+type Message struct {
+	Medication string
+	Title      string
+	Body       string
+	// AckURL marks the dose as taken, empty if acknowledgment links are disabled
+	AckURL string
+}
+
+// Primary is how reminders are normally delivered
+type Primary interface {
+	// Connected reports whether the gateway connection is up
+	Connected() bool
+	// ChannelLost reports whether the bot can't post in the reminder channel
+	ChannelLost() bool
+}
+
+// ReminderStore reads and records the nags of today's reminders
+type ReminderStore interface {
+	GetTodayReminder(ctx context.Context, medicationType string) (*db.Reminder, error)
+	RecordNag(ctx context.Context, id, version int64, messageID string) error
+}
+
+// Failover delivers reminders through the backup channels while the primary is down
+type Failover struct {
+	primary     Primary
+	channels    []Channel
+	store       ReminderStore
+	ackLinks    *acklink.Signer
+	bus         *events.Bus
+	location    *time.Location
+	maxFailures int
+	gatewayDown time.Duration
+	now         func() time.Time
+
+	mu sync.Mutex
+	// failures counts the reminders in a row the primary failed to send
+	failures int
+	// disconnectedSince is when the gateway was first seen disconnected, zero while it's connected
+	disconnectedSince time.Time
+	// delivering records the medications whose reminders are being delivered in the background
+	delivering map[string]bool
+	deliveries sync.WaitGroup
+}
+
+// New creates a failover through the configured backup channels, or returns nil if there are none.
+// ackLinks may be nil if acknowledgment links are disabled.
+func New(cfg *config.Config, primary Primary, store ReminderStore, ackLinks *acklink.Signer, bus *events.Bus, location *time.Location) *Failover {
+	if len(cfg.FailoverChannels) == 0 {
+		return nil
+	}
+
+	f := &Failover{
+		primary:     primary,
+		store:       store,
+		ackLinks:    ackLinks,
+		bus:         bus,
+		location:    location,
+		maxFailures: cfg.FailoverAfterFailures,
+		gatewayDown: time.Duration(cfg.FailoverGatewayDownMinutes) * time.Minute,
+		now:         time.Now,
+		delivering:  make(map[string]bool),
+	}
+	for _, name := range cfg.FailoverChannels {
+		switch name {
+		case config.FailoverNtfy:
+			f.channels = append(f.channels, NewNtfy(cfg.NtfyURL, cfg.NtfyToken))
+		case config.FailoverEmail:
+			f.channels = append(f.channels, NewEmail(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, cfg.EmailFrom, cfg.EmailTo))
+		case config.FailoverSMS:
+			f.channels = append(f.channels, NewSMS(cfg.TwilioAccountSID, cfg.TwilioAuthToken, cfg.SMSFrom, cfg.SMSTo))
+		}
+	}
+	return f
+}
+
+// Start subscribes to reminder events and watches the gateway connection until the context is done.
+// It must be called after the primary has subscribed, so it sees whether the primary sent each reminder.
+func (f *Failover) Start(ctx context.Context) {
+	events.On(f.bus, f.onReminderDue)
+	events.On(f.bus, func(ctx context.Context, event events.ReminderUndelivered) error {
+		if event.Channel == events.ChannelDiscord {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.failures++
+		}
+		return nil
+	})
+	events.On(f.bus, func(ctx context.Context, event events.ReminderSent) error {
+		if event.Channel == events.ChannelDiscord {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.failures = 0
+		}
+		return nil
+	})
+
+	go func() {
+		ticker := time.NewTicker(gatewayCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				f.checkGateway()
+			}
+		}
+	}()
+}
+
+// checkGateway records when the gateway disconnected
+func (f *Failover) checkGateway() {
+	connected := f.primary.Connected()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case connected:
+		f.disconnectedSince = time.Time{}
+	case f.disconnectedSince.IsZero():
+		f.disconnectedSince = f.now()
+	}
+}
+
+// primaryDown returns why the primary is considered down, or an empty string if it's up
+func (f *Failover) primaryDown() string {
+	f.checkGateway()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case f.primary.ChannelLost():
+		return "the reminder channel can't be reached"
+	case f.failures >= f.maxFailures:
+		return fmt.Sprintf("%d reminders in a row failed to send", f.failures)
+	case !f.disconnectedSince.IsZero() && f.now().Sub(f.disconnectedSince) >= f.gatewayDown:
+		return fmt.Sprintf("the gateway has been disconnected since %s", f.disconnectedSince.In(f.location).Format("15:04"))
+	default:
+		return ""
+	}
+}
+
+// onReminderDue delivers a reminder through the backup channels while the primary is down. Backup
+// channels can take a while to respond, so it's delivered in the background rather than holding up the
+// event bus, skipping medications whose last reminder is still being delivered.
+func (f *Failover) onReminderDue(ctx context.Context, event events.ReminderDue) error {
+	reason := f.primaryDown()
+	if reason == "" {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.delivering[event.Medication.Name] {
+		log.Printf("Debug: Reminder for %s is still being delivered by a backup channel", event.Medication.Name)
+		return nil
+	}
+	f.delivering[event.Medication.Name] = true
+
+	f.deliveries.Add(1)
+	go func() {
+		defer f.deliveries.Done()
+		if err := f.remind(ctx, event, reason); err != nil {
+			log.Printf("Error sending backup reminder: %v", err)
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.delivering, event.Medication.Name)
+	}()
+	return nil
+}
+
+// remind delivers a reminder through the backup channels. A reminder the primary sent anyway, such as
+// while only the gateway is down, is delivered again since its buttons won't work, but it isn't counted
+// as another nag.
+func (f *Failover) remind(ctx context.Context, event events.ReminderDue, reason string) error {
+	reminder, err := f.store.GetTodayReminder(ctx, event.Medication.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get reminder for %s: %w", event.Medication.Name, err)
+	}
+	if reminder.Resolved() {
+		return nil
+	}
+	sentByPrimary := event.Reminder != nil && reminder.NagCount > event.Reminder.NagCount
+
+	message := Message{
+		Medication: event.Medication.Name,
+		Title:      fmt.Sprintf("Time to take your %s", event.Medication.Name),
+		Body: fmt.Sprintf("Your %s was due at %s. Discord is unavailable (%s), so this reminder was sent here instead.",
+			event.Medication.Name, event.DueAt.In(f.location).Format("15:04"), reason),
+	}
+	if f.ackLinks != nil {
+		message.AckURL = f.ackLinks.URL(event.Medication.Name, reminder.Date, f.now())
+	}
+
+	channel, err := f.deliver(ctx, message, reminder)
+	if err != nil {
+		return fmt.Errorf("failed to deliver reminder for %s by any backup channel [dose %s]: %w", event.Medication.Name, reminder.CorrelationID, err)
+	}
+	log.Printf("Sent reminder for %s by %s, since %s [dose %s]", event.Medication.Name, channel, reason, reminder.CorrelationID)
+
+	if !sentByPrimary {
+		// The reminder keeps its last Discord message, which is replaced once Discord is back
+		for attempt := 1; ; attempt++ {
+			err := f.store.RecordNag(ctx, reminder.ID, reminder.Version, reminder.MessageID)
+			if err == nil {
+				break
+			}
+			if errors.Is(err, db.ErrAlreadyAcknowledged) || errors.Is(err, db.ErrDoseSkipped) {
+				return nil
+			}
+			if !errors.Is(err, db.ErrReminderConflict) || attempt == maxUpdateAttempts {
+				return fmt.Errorf("failed to update reminder status for %s [dose %s]: %w", event.Medication.Name, reminder.CorrelationID, err)
+			}
+
+			correlationID := reminder.CorrelationID
+			reminder, err = f.store.GetTodayReminder(ctx, event.Medication.Name)
+			if err != nil {
+				return fmt.Errorf("failed to get reminder for %s [dose %s]: %w", event.Medication.Name, correlationID, err)
+			}
+		}
+	}
+
+	nagCount := reminder.NagCount
+	if !sentByPrimary {
+		nagCount++
+	}
+	return f.bus.Publish(ctx, events.ReminderSent{
+		Medication:    event.Medication.Name,
+		Date:          reminder.Date,
+		ReminderID:    reminder.ID,
+		NagCount:      nagCount,
+		CorrelationID: reminder.CorrelationID,
+		Channel:       channel,
+	})
+}
+
+// deliver sends a message by the first backup channel that accepts it, returning the channel's name.
+// Each channel that fails is published as having not delivered the reminder.
+func (f *Failover) deliver(ctx context.Context, message Message, reminder *db.Reminder) (string, error) {
+	var errs []error
+	for _, channel := range f.channels {
+		err := channel.Send(ctx, message)
+		if err == nil {
+			return channel.Name(), nil
+		}
+		log.Printf("Error sending reminder for %s by %s, trying the next channel [dose %s]: %v", message.Medication, channel.Name(), reminder.CorrelationID, err)
+		errs = append(errs, fmt.Errorf("%s: %w", channel.Name(), err))
+
+		if err := f.bus.Publish(ctx, events.ReminderUndelivered{
+			Medication:    message.Medication,
+			Date:          reminder.Date,
+			ReminderID:    reminder.ID,
+			Err:           err,
+			CorrelationID: reminder.CorrelationID,
+			Channel:       channel.Name(),
+		}); err != nil {
+			log.Printf("Error publishing undelivered reminder for %s [dose %s]: %v", message.Medication, reminder.CorrelationID, err)
+		}
+	}
+	return "", errors.Join(errs...)
+}
@@ -0,0 +1,208 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/events"
+)
+
+type fakePrimary struct {
+	connected   bool
+	channelLost bool
+}
+
+func (p *fakePrimary) Connected() bool   { return p.connected }
+func (p *fakePrimary) ChannelLost() bool { return p.channelLost }
+
+type fakeChannel struct {
+	name string
+	err  error
+	sent []Message
+}
+
+func (c *fakeChannel) Name() string { return c.name }
+
+func (c *fakeChannel) Send(ctx context.Context, message Message) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.sent = append(c.sent, message)
+	return nil
+}
+
+func newTestFailover(t *testing.T, channels ...Channel) (*Failover, *fakePrimary, *db.MemoryStore, *events.Bus) {
+	t.Helper()
+	store := db.NewMemoryStore(time.UTC)
+	bus := events.NewBus()
+	primary := &fakePrimary{connected: true}
+	f := New(&config.Config{
+		FailoverChannels:           []string{config.FailoverNtfy},
+		FailoverAfterFailures:      2,
+		FailoverGatewayDownMinutes: 10,
+	}, primary, store, nil, bus, time.UTC)
+	f.channels = channels
+	f.Start(t.Context())
+	return f, primary, store, bus
+}
+
+func reminderDue(t *testing.T, store *db.MemoryStore) events.ReminderDue {
+	t.Helper()
+	reminder, err := store.GetTodayReminder(context.Background(), "Vitamin D")
+	if err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+	return events.ReminderDue{Medication: config.Medication{Name: "Vitamin D", Hour: 8}, Reminder: reminder, DueAt: time.Now()}
+}
+
+// publishDue publishes that the reminder is due, waiting for any backup reminder to be delivered
+func publishDue(t *testing.T, f *Failover, bus *events.Bus, store *db.MemoryStore) {
+	t.Helper()
+	if err := bus.Publish(context.Background(), reminderDue(t, store)); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	f.deliveries.Wait()
+}
+
+func TestFailoverAfterFailures(t *testing.T) {
+	broken := &fakeChannel{name: "ntfy", err: errors.New("unreachable")}
+	backup := &fakeChannel{name: "email"}
+	f, _, store, bus := newTestFailover(t, broken, backup)
+	ctx := context.Background()
+
+	var delivered []string
+	events.On(bus, func(ctx context.Context, event events.ReminderSent) error {
+		delivered = append(delivered, event.Channel)
+		return nil
+	})
+	var undelivered []string
+	events.On(bus, func(ctx context.Context, event events.ReminderUndelivered) error {
+		undelivered = append(undelivered, event.Channel)
+		return nil
+	})
+
+	for range 2 {
+		if err := bus.Publish(ctx, events.ReminderUndelivered{Medication: "Vitamin D", Channel: events.ChannelDiscord}); err != nil {
+			t.Fatalf("Failed to publish: %v", err)
+		}
+	}
+	publishDue(t, f, bus, store)
+
+	if len(backup.sent) != 1 || len(delivered) != 1 || delivered[0] != "email" {
+		t.Fatalf("Expected the reminder to be delivered by the second channel, got %d sent and %v", len(backup.sent), delivered)
+	}
+	if len(undelivered) != 3 || undelivered[2] != "ntfy" {
+		t.Errorf("Expected the first channel's failure to be published, got %v", undelivered)
+	}
+	reminder, _ := store.GetTodayReminder(ctx, "Vitamin D")
+	if reminder.NagCount != 1 {
+		t.Errorf("Expected the reminder to be recorded as sent, got %d nags", reminder.NagCount)
+	}
+
+	// A reminder Discord sends again stops the failover
+	if err := bus.Publish(ctx, events.ReminderSent{Medication: "Vitamin D", Channel: events.ChannelDiscord}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	publishDue(t, f, bus, store)
+	if len(backup.sent) != 1 {
+		t.Errorf("Expected no backup reminder once Discord recovered, got %d", len(backup.sent))
+	}
+}
+
+func TestFailoverGatewayDown(t *testing.T) {
+	backup := &fakeChannel{name: "ntfy"}
+	f, primary, store, bus := newTestFailover(t, backup)
+	ctx := context.Background()
+
+	now := time.Now()
+	f.now = func() time.Time { return now }
+	primary.connected = false
+	publishDue(t, f, bus, store)
+	if len(backup.sent) != 0 {
+		t.Fatal("Expected no failover as soon as the gateway disconnects")
+	}
+
+	now = now.Add(10 * time.Minute)
+	publishDue(t, f, bus, store)
+	if len(backup.sent) != 1 {
+		t.Fatalf("Expected failover once the gateway was down for 10 minutes, got %d reminders", len(backup.sent))
+	}
+
+	// Taken doses aren't reminded about
+	reminder, _ := store.GetTodayReminder(ctx, "Vitamin D")
+	if err := store.RecordAcknowledgment(ctx, reminder.ID, reminder.Version, "", "", time.Time{}); err != nil {
+		t.Fatalf("Failed to acknowledge: %v", err)
+	}
+	publishDue(t, f, bus, store)
+	if len(backup.sent) != 1 {
+		t.Errorf("Expected no reminder for a taken dose, got %d reminders", len(backup.sent))
+	}
+}
+
+func TestChannels(t *testing.T) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		requests = append(requests, r)
+	}))
+	defer server.Close()
+
+	message := Message{Medication: "Vitamin D", Title: "Time to take your Vitamin D", Body: "It was due at 08:00.", AckURL: "https://meds.example.com/ack?sig=x"}
+
+	if err := NewNtfy(server.URL+"/meds", "tk_secret").Send(context.Background(), message); err != nil {
+		t.Fatalf("Failed to send by ntfy: %v", err)
+	}
+	sms := NewSMS("AC123", "auth", "+15550001", "+15550002")
+	sms.apiURL = server.URL
+	if err := sms.Send(context.Background(), message); err != nil {
+		t.Fatalf("Failed to send by SMS: %v", err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+	if ntfy := requests[0]; ntfy.URL.Path != "/meds" || ntfy.Header.Get("Title") != message.Title || ntfy.Header.Get("Authorization") != "Bearer tk_secret" {
+		t.Errorf("Unexpected ntfy request to %s with headers %v", ntfy.URL.Path, ntfy.Header)
+	}
+	twilio := requests[1]
+	if user, password, _ := twilio.BasicAuth(); twilio.URL.Path != "/Accounts/AC123/Messages.json" || user != "AC123" || password != "auth" {
+		t.Errorf("Unexpected Twilio request to %s", twilio.URL.Path)
+	}
+	if twilio.PostForm.Get("To") != "+15550002" || twilio.PostForm.Get("Body") == "" {
+		t.Errorf("Unexpected Twilio form %v", twilio.PostForm)
+	}
+}
+
+func TestEmailStalledServer(t *testing.T) {
+	// The server accepts the connection but never greets the client
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	email := NewEmail(listener.Addr().String(), "", "", "bot@example.com", "me@example.com")
+	if err := email.Send(ctx, Message{Medication: "Vitamin D", Title: "Time to take your Vitamin D"}); err == nil {
+		t.Fatal("Expected sending to a stalled server to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the send to give up at the deadline, took %s", elapsed)
+	}
+}
@@ -0,0 +1,446 @@
+// Package graphapi serves a GraphQL endpoint for flexible queries over medications, reminders,
+// lab results and adherence stats.
+package graphapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/export"
+	"meds-bot/internal/stats"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+// Path is the HTTP path the GraphQL endpoint is served from
+const Path = "/api/graphql"
+
+const (
+	// defaultHistoryDays is how far back reminders are queried when no start date is given
+	defaultHistoryDays = 30
+	// defaultAdherenceDays is the period adherence is calculated over when none is given
+	defaultAdherenceDays = 7
+	// maxPageSize is the largest number of reminders returned in one page
+	maxPageSize = 500
+	// defaultLabResults is the number of lab results returned when none is given
+	defaultLabResults = 20
+)
+
+//go:embed schema.graphql
+var schema string
+
+// NewHandler creates the GraphQL HTTP handler, authenticated with the export token
+func NewHandler(store db.StoreInterface, medications []config.Medication, token string, location *time.Location) (http.Handler, error) {
+	if location == nil {
+		location = time.UTC
+	}
+
+	resolver := &resolver{store: store, medications: medications, location: location}
+	parsed, err := graphql.ParseSchema(schema, resolver, graphql.UseFieldResolvers(), graphql.MaxDepth(8))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GraphQL schema: %w", err)
+	}
+
+	relayHandler := &relay.Handler{Schema: parsed}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !export.Authorized(r, token) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		relayHandler.ServeHTTP(w, r)
+	}), nil
+}
+
+// resolver resolves the root query fields
+type resolver struct {
+	store       db.StoreInterface
+	medications []config.Medication
+	location    *time.Location
+}
+
+// Medications resolves the configured medications
+func (r *resolver) Medications() []*medicationResolver {
+	medications := make([]*medicationResolver, 0, len(r.medications))
+	for _, medication := range r.medications {
+		medications = append(medications, &medicationResolver{root: r, medication: medication})
+	}
+	return medications
+}
+
+// Medication resolves a configured medication by name
+func (r *resolver) Medication(args struct{ Name string }) *medicationResolver {
+	for _, medication := range r.medications {
+		if medication.Name == args.Name {
+			return &medicationResolver{root: r, medication: medication}
+		}
+	}
+	return nil
+}
+
+// reminderArgs filter and paginate reminders
+type reminderArgs struct {
+	Since        *string
+	Until        *string
+	Acknowledged *bool
+	First        *int32
+	After        *string
+}
+
+// Reminders resolves a page of reminders, optionally for a single medication
+func (r *resolver) Reminders(ctx context.Context, args struct {
+	Medication *string
+	reminderArgs
+}) (*reminderConnection, error) {
+	medication := ""
+	if args.Medication != nil {
+		medication = *args.Medication
+	}
+	return r.reminders(ctx, medication, args.reminderArgs)
+}
+
+// reminders loads, filters and paginates reminders
+func (r *resolver) reminders(ctx context.Context, medication string, args reminderArgs) (*reminderConnection, error) {
+	since := time.Now().In(r.location).AddDate(0, 0, -defaultHistoryDays)
+	if args.Since != nil {
+		parsed, err := time.ParseInLocation("2006-01-02", *args.Since, r.location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since date, expected YYYY-MM-DD")
+		}
+		since = parsed
+	}
+
+	history, err := r.store.GetReminderHistory(ctx, medication, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reminders: %w", err)
+	}
+
+	var filtered []db.Reminder
+	for _, reminder := range history {
+		if args.Until != nil && reminder.Date > *args.Until {
+			continue
+		}
+		if args.Acknowledged != nil && reminder.Acknowledged != *args.Acknowledged {
+			continue
+		}
+		filtered = append(filtered, reminder)
+	}
+
+	return paginate(filtered, args.First, args.After)
+}
+
+// paginate returns the page of reminders after the cursor
+func paginate(reminders []db.Reminder, first *int32, after *string) (*reminderConnection, error) {
+	start := 0
+	if after != nil {
+		afterID, err := decodeCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		for i, reminder := range reminders {
+			if reminder.ID == afterID {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	size := maxPageSize
+	if first != nil {
+		if *first < 0 {
+			return nil, fmt.Errorf("first must not be negative")
+		}
+		size = min(int(*first), maxPageSize)
+	}
+
+	end := min(start+size, len(reminders))
+	connection := &reminderConnection{
+		TotalCount: int32(len(reminders)),
+		PageInfo:   &pageInfo{HasNextPage: end < len(reminders)},
+	}
+	for _, reminder := range reminders[start:end] {
+		connection.Nodes = append(connection.Nodes, newReminder(reminder))
+	}
+	if len(connection.Nodes) > 0 {
+		cursor := encodeCursor(reminders[end-1].ID)
+		connection.PageInfo.EndCursor = &cursor
+	}
+
+	return connection, nil
+}
+
+// LabTests resolves lab tests, optionally for a single medication
+func (r *resolver) LabTests(ctx context.Context, args struct{ Medication *string }) ([]*labTestResolver, error) {
+	tests, err := r.store.ListLabTests(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lab tests: %w", err)
+	}
+
+	var resolvers []*labTestResolver
+	for _, test := range tests {
+		if args.Medication != nil && test.Medication != *args.Medication {
+			continue
+		}
+		resolvers = append(resolvers, &labTestResolver{root: r, test: test})
+	}
+
+	return resolvers, nil
+}
+
+// Adherence resolves the adherence of every medication
+func (r *resolver) Adherence(ctx context.Context, args struct{ Days *int32 }) ([]*adherence, error) {
+	var results []*adherence
+	for _, medication := range r.medications {
+		result, err := r.adherence(ctx, medication.Name, args.Days)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// adherence calculates a medication's adherence over the last number of days
+func (r *resolver) adherence(ctx context.Context, medication string, days *int32) (*adherence, error) {
+	period := defaultAdherenceDays
+	if days != nil {
+		period = int(*days)
+	}
+
+	now := time.Now().In(r.location)
+	history, err := r.store.GetReminderHistory(ctx, medication, now.AddDate(0, 0, -period))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reminders for %s: %w", medication, err)
+	}
+
+	result := stats.CalculateAdherence(medication, history, now.Format("2006-01-02"))
+	return &adherence{
+		Medication: result.Medication,
+		Taken:      int32(result.Taken),
+		Due:        int32(result.Due),
+		Percent:    int32(result.Percent()),
+	}, nil
+}
+
+// medicationResolver resolves a configured medication and its recorded details
+type medicationResolver struct {
+	root       *resolver
+	medication config.Medication
+	info       *db.MedicationInfo
+}
+
+// details loads the medication's recorded details once per query
+func (m *medicationResolver) details(ctx context.Context) (*db.MedicationInfo, error) {
+	if m.info == nil {
+		info, err := m.root.store.GetMedicationInfo(ctx, m.medication.Base())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load details for %s: %w", m.medication.Name, err)
+		}
+		m.info = info
+	}
+	return m.info, nil
+}
+
+func (m *medicationResolver) Name() string      { return m.medication.Name }
+func (m *medicationResolver) Hour() int32       { return int32(m.medication.Hour) }
+func (m *medicationResolver) Frequency() string { return m.medication.Frequency }
+func (m *medicationResolver) Day() *string      { return optional(m.medication.Day) }
+func (m *medicationResolver) Priority() string  { return m.medication.Priority }
+
+func (m *medicationResolver) Dose(ctx context.Context) (*string, error) {
+	info, err := m.details(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return optional(info.Dose), nil
+}
+
+func (m *medicationResolver) Instructions(ctx context.Context) (*string, error) {
+	info, err := m.details(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return optional(info.Instructions), nil
+}
+
+func (m *medicationResolver) Prescriber(ctx context.Context) (*string, error) {
+	info, err := m.details(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return optional(info.Prescriber), nil
+}
+
+func (m *medicationResolver) Pharmacy(ctx context.Context) (*string, error) {
+	info, err := m.details(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return optional(info.Pharmacy), nil
+}
+
+func (m *medicationResolver) PillsRemaining(ctx context.Context) (*int32, error) {
+	info, err := m.details(ctx)
+	if err != nil || info.PillsRemaining == db.UntrackedPills {
+		return nil, err
+	}
+	pills := int32(info.PillsRemaining)
+	return &pills, nil
+}
+
+func (m *medicationResolver) RefillDue(ctx context.Context) (*string, error) {
+	info, err := m.details(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return optional(info.RefillDue), nil
+}
+
+func (m *medicationResolver) Reminders(ctx context.Context, args reminderArgs) (*reminderConnection, error) {
+	return m.root.reminders(ctx, m.medication.Name, args)
+}
+
+func (m *medicationResolver) Adherence(ctx context.Context, args struct{ Days *int32 }) (*adherence, error) {
+	return m.root.adherence(ctx, m.medication.Name, args.Days)
+}
+
+func (m *medicationResolver) LabTests(ctx context.Context) ([]*labTestResolver, error) {
+	return m.root.LabTests(ctx, struct{ Medication *string }{&m.medication.Name})
+}
+
+// reminder is a reminder as exposed over GraphQL
+type reminder struct {
+	ID               graphql.ID
+	Date             string
+	Medication       string
+	Acknowledged     bool
+	Skipped          bool
+	SkipReason       *string
+	NagCount         int32
+	LastReminderTime *string
+}
+
+// newReminder converts a stored reminder
+func newReminder(r db.Reminder) *reminder {
+	result := &reminder{
+		ID:           graphql.ID(strconv.FormatInt(r.ID, 10)),
+		Date:         r.Date,
+		Medication:   r.MedicationType,
+		Acknowledged: r.Acknowledged,
+		Skipped:      r.Skipped,
+		NagCount:     int32(r.NagCount),
+	}
+	if r.SkipReason != "" {
+		result.SkipReason = optional(r.SkipReason)
+	}
+	if !r.LastReminderTime.IsZero() {
+		result.LastReminderTime = optional(r.LastReminderTime.Format(time.RFC3339))
+	}
+	return result
+}
+
+// reminderConnection is a page of reminders
+type reminderConnection struct {
+	Nodes      []*reminder
+	PageInfo   *pageInfo
+	TotalCount int32
+}
+
+// pageInfo describes whether more reminders follow a page
+type pageInfo struct {
+	HasNextPage bool
+	EndCursor   *string
+}
+
+// labTestResolver resolves a lab test and its results
+type labTestResolver struct {
+	root *resolver
+	test db.LabTest
+}
+
+func (l *labTestResolver) Name() string       { return l.test.Name }
+func (l *labTestResolver) Medication() string { return l.test.Medication }
+func (l *labTestResolver) Unit() *string      { return optional(l.test.Unit) }
+func (l *labTestResolver) IntervalDays() int32 {
+	return int32(l.test.IntervalDays)
+}
+func (l *labTestResolver) NextDue() string { return l.test.NextDue }
+
+func (l *labTestResolver) Results(ctx context.Context, args struct{ Last *int32 }) ([]*labResult, error) {
+	limit := defaultLabResults
+	if args.Last != nil {
+		limit = int(*args.Last)
+	}
+
+	results, err := l.root.store.GetLabResults(ctx, l.test.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load results for %s: %w", l.test.Name, err)
+	}
+
+	resolved := make([]*labResult, 0, len(results))
+	for _, result := range results {
+		resolved = append(resolved, &labResult{Date: result.Date, Value: result.Value})
+	}
+	return resolved, nil
+}
+
+// labResult is a lab test result
+type labResult struct {
+	Date  string
+	Value float64
+}
+
+// adherence is a medication's doses taken out of those due
+type adherence struct {
+	Medication string
+	Taken      int32
+	Due        int32
+	Percent    int32
+}
+
+// encodeCursor encodes a reminder ID as an opaque cursor
+func encodeCursor(id int64) string {
+	return base64.URLEncoding.EncodeToString([]byte("reminder:" + strconv.FormatInt(id, 10)))
+}
+
+// decodeCursor decodes a reminder ID from a cursor
+func decodeCursor(cursor string) (int64, error) {
+	data, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+
+	value, ok := strings.CutPrefix(string(data), "reminder:")
+	if !ok {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return id, nil
+}
+
+// optional returns nil for empty strings
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
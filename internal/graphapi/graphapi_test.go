@@ -0,0 +1,53 @@
+package graphapi
+
+import (
+	"testing"
+
+	"meds-bot/internal/db"
+)
+
+func TestPaginate(t *testing.T) {
+	reminders := []db.Reminder{{ID: 3}, {ID: 5}, {ID: 8}, {ID: 13}, {ID: 21}}
+	first := int32(2)
+
+	page, err := paginate(reminders, &first, nil)
+	if err != nil {
+		t.Fatalf("paginate() error = %v", err)
+	}
+	if len(page.Nodes) != 2 || page.Nodes[0].ID != "3" || page.Nodes[1].ID != "5" {
+		t.Fatalf("first page = %+v, want reminders 3 and 5", page.Nodes)
+	}
+	if !page.PageInfo.HasNextPage || page.TotalCount != 5 {
+		t.Errorf("pageInfo = %+v, totalCount = %d, want next page of 5", page.PageInfo, page.TotalCount)
+	}
+
+	page, err = paginate(reminders, &first, page.PageInfo.EndCursor)
+	if err != nil {
+		t.Fatalf("paginate() error = %v", err)
+	}
+	if len(page.Nodes) != 2 || page.Nodes[0].ID != "8" {
+		t.Fatalf("second page = %+v, want reminders 8 and 13", page.Nodes)
+	}
+
+	page, err = paginate(reminders, &first, page.PageInfo.EndCursor)
+	if err != nil {
+		t.Fatalf("paginate() error = %v", err)
+	}
+	if len(page.Nodes) != 1 || page.PageInfo.HasNextPage {
+		t.Errorf("last page = %+v, hasNextPage = %v, want only reminder 21", page.Nodes, page.PageInfo.HasNextPage)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, cursor := range []string{"not base64!", encodeCursor(7)[:4]} {
+		if _, err := decodeCursor(cursor); err == nil {
+			t.Errorf("decodeCursor(%q) expected error", cursor)
+		}
+	}
+}
+
+func TestSchemaParses(t *testing.T) {
+	if _, err := NewHandler(nil, nil, "token", nil); err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+}
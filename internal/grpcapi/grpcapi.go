@@ -0,0 +1,348 @@
+// Package grpcapi serves medications, reminders and acknowledgments over gRPC for companion apps.
+package grpcapi
+
+//go:generate protoc -I medsbotpb --go_out=medsbotpb --go_opt=paths=source_relative --go-grpc_out=medsbotpb --go-grpc_opt=paths=source_relative medsbot.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/events"
+	"meds-bot/internal/grpcapi/medsbotpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// defaultHistoryDays is how far back reminders are listed when no start date is given
+	defaultHistoryDays = 7
+	// watchBuffer is the number of events queued for each watcher before further events are dropped
+	watchBuffer = 32
+)
+
+// Acknowledger marks a medication dose as taken
+type Acknowledger interface {
+	AcknowledgeMedication(ctx context.Context, medicationName, source string) (bool, error)
+}
+
+// Server implements the MedsBot gRPC service
+type Server struct {
+	medsbotpb.UnimplementedMedsBotServer
+
+	store        db.StoreInterface
+	medications  []config.Medication
+	acknowledger Acknowledger
+	location     *time.Location
+
+	watchersMutex sync.Mutex
+	watchers      map[*watcher]struct{}
+}
+
+// NewServer creates a new MedsBot service streaming the reminder events published on the bus
+func NewServer(store db.StoreInterface, medications []config.Medication, acknowledger Acknowledger, location *time.Location, bus *events.Bus) *Server {
+	if location == nil {
+		location = time.UTC
+	}
+
+	s := &Server{
+		store:        store,
+		medications:  medications,
+		acknowledger: acknowledger,
+		location:     location,
+		watchers:     make(map[*watcher]struct{}),
+	}
+	s.subscribe(bus)
+	return s
+}
+
+// writeMethods are the methods that change data, which need the write token rather than the read-only one
+var writeMethods = map[string]bool{
+	medsbotpb.MedsBot_Acknowledge_FullMethodName:      true,
+	medsbotpb.MedsBot_UpdateMedication_FullMethodName: true,
+}
+
+// NewGRPCServer creates a gRPC server with the MedsBot service registered. Calls are authenticated with
+// the read-only token or the write token, and those changing data only with the write token.
+func NewGRPCServer(service *Server, token, writeToken string) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := checkToken(ctx, info.FullMethod, token, writeToken); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := checkToken(stream.Context(), info.FullMethod, token, writeToken); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	medsbotpb.RegisterMedsBotServer(server, service)
+	return server
+}
+
+// checkToken returns an error unless the request's token allows calling a method
+func checkToken(ctx context.Context, method, token, writeToken string) error {
+	if authorized(ctx, writeToken) {
+		return nil
+	}
+	if !authorized(ctx, token) {
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if writeMethods[method] {
+		return status.Error(codes.PermissionDenied, "this call needs the write token")
+	}
+	return nil
+}
+
+// authorized checks the bearer token in the request metadata against the expected token
+func authorized(ctx context.Context, token string) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || token == "" {
+		return false
+	}
+
+	for _, value := range md.Get("authorization") {
+		provided, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// ListMedications returns the configured medications with their recorded details
+func (s *Server) ListMedications(ctx context.Context, _ *medsbotpb.ListMedicationsRequest) (*medsbotpb.ListMedicationsResponse, error) {
+	response := &medsbotpb.ListMedicationsResponse{}
+	for _, medication := range s.medications {
+		info, err := s.store.GetMedicationInfo(ctx, medication.Base())
+		if err != nil {
+			log.Printf("Error getting medication info for %s: %v", medication.Name, err)
+			return nil, status.Error(codes.Internal, "failed to load medication details")
+		}
+		response.Medications = append(response.Medications, newMedication(medication, info))
+	}
+	return response, nil
+}
+
+// UpdateMedication records the details of a configured medication, leaving omitted fields unchanged
+func (s *Server) UpdateMedication(ctx context.Context, req *medsbotpb.UpdateMedicationRequest) (*medsbotpb.Medication, error) {
+	medication, ok := s.medication(req.GetName())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown medication: %s", req.GetName())
+	}
+
+	info, err := s.store.GetMedicationInfo(ctx, medication.Base())
+	if err != nil {
+		log.Printf("Error getting medication info for %s: %v", medication.Name, err)
+		return nil, status.Error(codes.Internal, "failed to load medication details")
+	}
+
+	if req.Dose != nil {
+		info.Dose = req.GetDose()
+	}
+	if req.Instructions != nil {
+		info.Instructions = req.GetInstructions()
+	}
+	if req.PillsRemaining != nil {
+		if req.GetPillsRemaining() < db.UntrackedPills {
+			return nil, status.Error(codes.InvalidArgument, "pills remaining must not be negative, or -1 to stop tracking")
+		}
+		info.PillsRemaining = int(req.GetPillsRemaining())
+	}
+
+	if err := s.store.SaveMedicationInfo(ctx, info); err != nil {
+		log.Printf("Error saving medication info for %s: %v", medication.Name, err)
+		return nil, status.Error(codes.Internal, "failed to save medication details")
+	}
+
+	return newMedication(medication, info), nil
+}
+
+// ListReminders returns reminders since a date, oldest first
+func (s *Server) ListReminders(ctx context.Context, req *medsbotpb.ListRemindersRequest) (*medsbotpb.ListRemindersResponse, error) {
+	since := time.Now().In(s.location).AddDate(0, 0, -defaultHistoryDays)
+	if req.GetSince() != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", req.GetSince(), s.location)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "since must be in the format YYYY-MM-DD")
+		}
+		since = parsed
+	}
+
+	history, err := s.store.GetReminderHistory(ctx, req.GetMedication(), since)
+	if err != nil {
+		log.Printf("Error getting reminder history: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load reminders")
+	}
+
+	response := &medsbotpb.ListRemindersResponse{}
+	for _, reminder := range history {
+		response.Reminders = append(response.Reminders, newReminder(reminder))
+	}
+	return response, nil
+}
+
+// Acknowledge marks today's dose of a medication as taken
+func (s *Server) Acknowledge(ctx context.Context, req *medsbotpb.AcknowledgeRequest) (*medsbotpb.AcknowledgeResponse, error) {
+	if _, ok := s.medication(req.GetMedication()); !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown medication: %s", req.GetMedication())
+	}
+
+	alreadyTaken, err := s.acknowledger.AcknowledgeMedication(ctx, req.GetMedication(), "the companion app")
+	if errors.Is(err, db.ErrConfirmationRequired) {
+		return nil, status.Errorf(codes.FailedPrecondition, "%s needs confirming by others in Discord before it's recorded", req.GetMedication())
+	}
+	if errors.Is(err, db.ErrPhotoRequired) {
+		return nil, status.Errorf(codes.FailedPrecondition, "%s needs a photo added in Discord before it's recorded", req.GetMedication())
+	}
+	if err != nil {
+		log.Printf("Error acknowledging %s over gRPC: %v", req.GetMedication(), err)
+		return nil, status.Error(codes.Internal, "failed to acknowledge medication")
+	}
+
+	return &medsbotpb.AcknowledgeResponse{AlreadyTaken: alreadyTaken}, nil
+}
+
+// WatchReminders streams reminder events until the client disconnects
+func (s *Server) WatchReminders(req *medsbotpb.WatchRemindersRequest, stream grpc.ServerStreamingServer[medsbotpb.ReminderEvent]) error {
+	ctx := stream.Context()
+
+	w := &watcher{medication: req.GetMedication(), events: make(chan *medsbotpb.ReminderEvent, watchBuffer)}
+	s.watchersMutex.Lock()
+	s.watchers[w] = struct{}{}
+	s.watchersMutex.Unlock()
+
+	defer func() {
+		s.watchersMutex.Lock()
+		delete(s.watchers, w)
+		s.watchersMutex.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-w.events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// watcher is a client streaming reminder events
+type watcher struct {
+	// medication limits the events to one medication, or all if empty
+	medication string
+	events     chan *medsbotpb.ReminderEvent
+}
+
+// subscribe forwards reminder events from the bus to watchers
+func (s *Server) subscribe(bus *events.Bus) {
+	events.On(bus, func(ctx context.Context, event events.ReminderDue) error {
+		s.broadcast(medsbotpb.ReminderEvent_TYPE_DUE, newReminder(*event.Reminder))
+		return nil
+	})
+	events.On(bus, func(ctx context.Context, event events.ReminderSent) error {
+		s.broadcast(medsbotpb.ReminderEvent_TYPE_SENT, &medsbotpb.Reminder{
+			Id:               event.ReminderID,
+			Date:             event.Date,
+			Medication:       event.Medication,
+			NagCount:         int32(event.NagCount),
+			LastReminderTime: timestamppb.Now(),
+		})
+		return nil
+	})
+	events.On(bus, func(ctx context.Context, event events.DoseAcknowledged) error {
+		s.broadcast(medsbotpb.ReminderEvent_TYPE_ACKNOWLEDGED, &medsbotpb.Reminder{
+			Id:           event.ReminderID,
+			Date:         event.Date,
+			Medication:   event.Medication,
+			Acknowledged: true,
+		})
+		return nil
+	})
+	events.On(bus, func(ctx context.Context, event events.DoseMissed) error {
+		s.broadcast(medsbotpb.ReminderEvent_TYPE_MISSED, &medsbotpb.Reminder{
+			Id:         event.ReminderID,
+			Date:       event.Date,
+			Medication: event.Medication,
+			NagCount:   int32(event.NagCount),
+		})
+		return nil
+	})
+}
+
+// broadcast sends an event to the watchers of its medication. Slow watchers miss events rather
+// than holding up the publisher.
+func (s *Server) broadcast(eventType medsbotpb.ReminderEvent_Type, reminder *medsbotpb.Reminder) {
+	event := &medsbotpb.ReminderEvent{Type: eventType, Reminder: reminder}
+
+	s.watchersMutex.Lock()
+	defer s.watchersMutex.Unlock()
+
+	for w := range s.watchers {
+		if w.medication != "" && w.medication != reminder.GetMedication() {
+			continue
+		}
+
+		select {
+		case w.events <- event:
+		default:
+			log.Printf("Dropped %s event for a slow gRPC watcher", eventType)
+		}
+	}
+}
+
+// medication finds a configured medication by name
+func (s *Server) medication(name string) (config.Medication, bool) {
+	for _, medication := range s.medications {
+		if medication.Name == name {
+			return medication, true
+		}
+	}
+	return config.Medication{}, false
+}
+
+// newMedication converts a configured medication and its recorded details
+func newMedication(medication config.Medication, info *db.MedicationInfo) *medsbotpb.Medication {
+	return &medsbotpb.Medication{
+		Name:           medication.Name,
+		Hour:           int32(medication.Hour),
+		Frequency:      medication.Frequency,
+		Day:            medication.Day,
+		Priority:       medication.Priority,
+		Dose:           info.Dose,
+		Instructions:   info.Instructions,
+		PillsRemaining: int32(info.PillsRemaining),
+		RefillDue:      info.RefillDue,
+	}
+}
+
+// newReminder converts a stored reminder
+func newReminder(reminder db.Reminder) *medsbotpb.Reminder {
+	result := &medsbotpb.Reminder{
+		Id:           reminder.ID,
+		Date:         reminder.Date,
+		Medication:   reminder.MedicationType,
+		Acknowledged: reminder.Acknowledged,
+		NagCount:     int32(reminder.NagCount),
+	}
+	if !reminder.LastReminderTime.IsZero() {
+		result.LastReminderTime = timestamppb.New(reminder.LastReminderTime)
+	}
+	return result
+}
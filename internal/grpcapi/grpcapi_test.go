@@ -0,0 +1,95 @@
+package grpcapi
+
+import (
+	"context"
+	"testing"
+
+	"meds-bot/internal/events"
+	"meds-bot/internal/grpcapi/medsbotpb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestWatchersReceiveEvents(t *testing.T) {
+	bus := events.NewBus()
+	s := NewServer(nil, nil, nil, nil, bus)
+
+	all := &watcher{events: make(chan *medsbotpb.ReminderEvent, 1)}
+	other := &watcher{medication: "Evening Pill", events: make(chan *medsbotpb.ReminderEvent, 1)}
+	s.watchers[all] = struct{}{}
+	s.watchers[other] = struct{}{}
+
+	err := bus.Publish(context.Background(), events.DoseAcknowledged{Medication: "Morning Pill", Date: "2024-01-01", ReminderID: 7})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	select {
+	case event := <-all.events:
+		if event.GetType() != medsbotpb.ReminderEvent_TYPE_ACKNOWLEDGED || event.GetReminder().GetId() != 7 {
+			t.Errorf("event = %v, want acknowledgment of reminder 7", event)
+		}
+	default:
+		t.Error("watcher of all medications didn't receive the event")
+	}
+
+	if len(other.events) != 0 {
+		t.Error("watcher of another medication received the event")
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		token string
+		want  bool
+	}{
+		{"no metadata", context.Background(), "secret", false},
+		{"matching token", metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret")), "secret", true},
+		{"wrong token", metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer other")), "secret", false},
+		{"no configured token", metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer ")), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := authorized(tt.ctx, tt.token); got != tt.want {
+				t.Errorf("authorized() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+	read := medsbotpb.MedsBot_ListReminders_FullMethodName
+	write := medsbotpb.MedsBot_Acknowledge_FullMethodName
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		method     string
+		writeToken string
+		want       codes.Code
+	}{
+		{"read with the export token", withToken("export"), read, "write", codes.OK},
+		{"read with the write token", withToken("write"), read, "write", codes.OK},
+		{"acknowledge with the export token", withToken("export"), write, "write", codes.PermissionDenied},
+		{"update with the export token", withToken("export"), medsbotpb.MedsBot_UpdateMedication_FullMethodName, "write", codes.PermissionDenied},
+		{"acknowledge with the write token", withToken("write"), write, "write", codes.OK},
+		{"acknowledge without a write token configured", withToken("export"), write, "", codes.PermissionDenied},
+		{"wrong token", withToken("other"), read, "write", codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(checkToken(tt.ctx, tt.method, "export", tt.writeToken)); got != tt.want {
+				t.Errorf("checkToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
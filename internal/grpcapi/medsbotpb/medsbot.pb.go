@@ -0,0 +1,856 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: medsbot.proto
+
+package medsbotpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ReminderEvent_Type int32
+
+const (
+	ReminderEvent_TYPE_UNSPECIFIED ReminderEvent_Type = 0
+	// A reminder was created for a dose that is due.
+	ReminderEvent_TYPE_DUE ReminderEvent_Type = 1
+	// A reminder message was sent.
+	ReminderEvent_TYPE_SENT ReminderEvent_Type = 2
+	// The dose was taken.
+	ReminderEvent_TYPE_ACKNOWLEDGED ReminderEvent_Type = 3
+	// The dose's reminder window closed without it being taken.
+	ReminderEvent_TYPE_MISSED ReminderEvent_Type = 4
+)
+
+// Enum value maps for ReminderEvent_Type.
+var (
+	ReminderEvent_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "TYPE_DUE",
+		2: "TYPE_SENT",
+		3: "TYPE_ACKNOWLEDGED",
+		4: "TYPE_MISSED",
+	}
+	ReminderEvent_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED":  0,
+		"TYPE_DUE":          1,
+		"TYPE_SENT":         2,
+		"TYPE_ACKNOWLEDGED": 3,
+		"TYPE_MISSED":       4,
+	}
+)
+
+func (x ReminderEvent_Type) Enum() *ReminderEvent_Type {
+	p := new(ReminderEvent_Type)
+	*p = x
+	return p
+}
+
+func (x ReminderEvent_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ReminderEvent_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_medsbot_proto_enumTypes[0].Descriptor()
+}
+
+func (ReminderEvent_Type) Type() protoreflect.EnumType {
+	return &file_medsbot_proto_enumTypes[0]
+}
+
+func (x ReminderEvent_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ReminderEvent_Type.Descriptor instead.
+func (ReminderEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return file_medsbot_proto_rawDescGZIP(), []int{10, 0}
+}
+
+type Medication struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Name         string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Hour         int32                  `protobuf:"varint,2,opt,name=hour,proto3" json:"hour,omitempty"`
+	Frequency    string                 `protobuf:"bytes,3,opt,name=frequency,proto3" json:"frequency,omitempty"`
+	Day          string                 `protobuf:"bytes,4,opt,name=day,proto3" json:"day,omitempty"`
+	Priority     string                 `protobuf:"bytes,5,opt,name=priority,proto3" json:"priority,omitempty"`
+	Dose         string                 `protobuf:"bytes,6,opt,name=dose,proto3" json:"dose,omitempty"`
+	Instructions string                 `protobuf:"bytes,7,opt,name=instructions,proto3" json:"instructions,omitempty"`
+	// Doses remaining, or -1 if stock isn't tracked.
+	PillsRemaining int32  `protobuf:"varint,8,opt,name=pills_remaining,json=pillsRemaining,proto3" json:"pills_remaining,omitempty"`
+	RefillDue      string `protobuf:"bytes,9,opt,name=refill_due,json=refillDue,proto3" json:"refill_due,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Medication) Reset() {
+	*x = Medication{}
+	mi := &file_medsbot_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Medication) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Medication) ProtoMessage() {}
+
+func (x *Medication) ProtoReflect() protoreflect.Message {
+	mi := &file_medsbot_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Medication.ProtoReflect.Descriptor instead.
+func (*Medication) Descriptor() ([]byte, []int) {
+	return file_medsbot_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Medication) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Medication) GetHour() int32 {
+	if x != nil {
+		return x.Hour
+	}
+	return 0
+}
+
+func (x *Medication) GetFrequency() string {
+	if x != nil {
+		return x.Frequency
+	}
+	return ""
+}
+
+func (x *Medication) GetDay() string {
+	if x != nil {
+		return x.Day
+	}
+	return ""
+}
+
+func (x *Medication) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+func (x *Medication) GetDose() string {
+	if x != nil {
+		return x.Dose
+	}
+	return ""
+}
+
+func (x *Medication) GetInstructions() string {
+	if x != nil {
+		return x.Instructions
+	}
+	return ""
+}
+
+func (x *Medication) GetPillsRemaining() int32 {
+	if x != nil {
+		return x.PillsRemaining
+	}
+	return 0
+}
+
+func (x *Medication) GetRefillDue() string {
+	if x != nil {
+		return x.RefillDue
+	}
+	return ""
+}
+
+type ListMedicationsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMedicationsRequest) Reset() {
+	*x = ListMedicationsRequest{}
+	mi := &file_medsbot_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMedicationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMedicationsRequest) ProtoMessage() {}
+
+func (x *ListMedicationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_medsbot_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMedicationsRequest.ProtoReflect.Descriptor instead.
+func (*ListMedicationsRequest) Descriptor() ([]byte, []int) {
+	return file_medsbot_proto_rawDescGZIP(), []int{1}
+}
+
+type ListMedicationsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Medications   []*Medication          `protobuf:"bytes,1,rep,name=medications,proto3" json:"medications,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMedicationsResponse) Reset() {
+	*x = ListMedicationsResponse{}
+	mi := &file_medsbot_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMedicationsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMedicationsResponse) ProtoMessage() {}
+
+func (x *ListMedicationsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_medsbot_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMedicationsResponse.ProtoReflect.Descriptor instead.
+func (*ListMedicationsResponse) Descriptor() ([]byte, []int) {
+	return file_medsbot_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListMedicationsResponse) GetMedications() []*Medication {
+	if x != nil {
+		return x.Medications
+	}
+	return nil
+}
+
+type UpdateMedicationRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Name           string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Dose           *string                `protobuf:"bytes,2,opt,name=dose,proto3,oneof" json:"dose,omitempty"`
+	Instructions   *string                `protobuf:"bytes,3,opt,name=instructions,proto3,oneof" json:"instructions,omitempty"`
+	PillsRemaining *int32                 `protobuf:"varint,4,opt,name=pills_remaining,json=pillsRemaining,proto3,oneof" json:"pills_remaining,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *UpdateMedicationRequest) Reset() {
+	*x = UpdateMedicationRequest{}
+	mi := &file_medsbot_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateMedicationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateMedicationRequest) ProtoMessage() {}
+
+func (x *UpdateMedicationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_medsbot_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateMedicationRequest.ProtoReflect.Descriptor instead.
+func (*UpdateMedicationRequest) Descriptor() ([]byte, []int) {
+	return file_medsbot_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UpdateMedicationRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UpdateMedicationRequest) GetDose() string {
+	if x != nil && x.Dose != nil {
+		return *x.Dose
+	}
+	return ""
+}
+
+func (x *UpdateMedicationRequest) GetInstructions() string {
+	if x != nil && x.Instructions != nil {
+		return *x.Instructions
+	}
+	return ""
+}
+
+func (x *UpdateMedicationRequest) GetPillsRemaining() int32 {
+	if x != nil && x.PillsRemaining != nil {
+		return *x.PillsRemaining
+	}
+	return 0
+}
+
+type Reminder struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Id               int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Date             string                 `protobuf:"bytes,2,opt,name=date,proto3" json:"date,omitempty"`
+	Medication       string                 `protobuf:"bytes,3,opt,name=medication,proto3" json:"medication,omitempty"`
+	Acknowledged     bool                   `protobuf:"varint,4,opt,name=acknowledged,proto3" json:"acknowledged,omitempty"`
+	NagCount         int32                  `protobuf:"varint,5,opt,name=nag_count,json=nagCount,proto3" json:"nag_count,omitempty"`
+	LastReminderTime *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=last_reminder_time,json=lastReminderTime,proto3" json:"last_reminder_time,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Reminder) Reset() {
+	*x = Reminder{}
+	mi := &file_medsbot_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Reminder) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reminder) ProtoMessage() {}
+
+func (x *Reminder) ProtoReflect() protoreflect.Message {
+	mi := &file_medsbot_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reminder.ProtoReflect.Descriptor instead.
+func (*Reminder) Descriptor() ([]byte, []int) {
+	return file_medsbot_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Reminder) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Reminder) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *Reminder) GetMedication() string {
+	if x != nil {
+		return x.Medication
+	}
+	return ""
+}
+
+func (x *Reminder) GetAcknowledged() bool {
+	if x != nil {
+		return x.Acknowledged
+	}
+	return false
+}
+
+func (x *Reminder) GetNagCount() int32 {
+	if x != nil {
+		return x.NagCount
+	}
+	return 0
+}
+
+func (x *Reminder) GetLastReminderTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastReminderTime
+	}
+	return nil
+}
+
+type ListRemindersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only reminders for this medication, or all if empty.
+	Medication string `protobuf:"bytes,1,opt,name=medication,proto3" json:"medication,omitempty"`
+	// First date to include (YYYY-MM-DD), defaulting to 7 days ago.
+	Since         string `protobuf:"bytes,2,opt,name=since,proto3" json:"since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRemindersRequest) Reset() {
+	*x = ListRemindersRequest{}
+	mi := &file_medsbot_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRemindersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRemindersRequest) ProtoMessage() {}
+
+func (x *ListRemindersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_medsbot_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRemindersRequest.ProtoReflect.Descriptor instead.
+func (*ListRemindersRequest) Descriptor() ([]byte, []int) {
+	return file_medsbot_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListRemindersRequest) GetMedication() string {
+	if x != nil {
+		return x.Medication
+	}
+	return ""
+}
+
+func (x *ListRemindersRequest) GetSince() string {
+	if x != nil {
+		return x.Since
+	}
+	return ""
+}
+
+type ListRemindersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reminders     []*Reminder            `protobuf:"bytes,1,rep,name=reminders,proto3" json:"reminders,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRemindersResponse) Reset() {
+	*x = ListRemindersResponse{}
+	mi := &file_medsbot_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRemindersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRemindersResponse) ProtoMessage() {}
+
+func (x *ListRemindersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_medsbot_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRemindersResponse.ProtoReflect.Descriptor instead.
+func (*ListRemindersResponse) Descriptor() ([]byte, []int) {
+	return file_medsbot_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListRemindersResponse) GetReminders() []*Reminder {
+	if x != nil {
+		return x.Reminders
+	}
+	return nil
+}
+
+type AcknowledgeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Medication    string                 `protobuf:"bytes,1,opt,name=medication,proto3" json:"medication,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AcknowledgeRequest) Reset() {
+	*x = AcknowledgeRequest{}
+	mi := &file_medsbot_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AcknowledgeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AcknowledgeRequest) ProtoMessage() {}
+
+func (x *AcknowledgeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_medsbot_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AcknowledgeRequest.ProtoReflect.Descriptor instead.
+func (*AcknowledgeRequest) Descriptor() ([]byte, []int) {
+	return file_medsbot_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AcknowledgeRequest) GetMedication() string {
+	if x != nil {
+		return x.Medication
+	}
+	return ""
+}
+
+type AcknowledgeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Whether the dose had already been taken.
+	AlreadyTaken  bool `protobuf:"varint,1,opt,name=already_taken,json=alreadyTaken,proto3" json:"already_taken,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AcknowledgeResponse) Reset() {
+	*x = AcknowledgeResponse{}
+	mi := &file_medsbot_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AcknowledgeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AcknowledgeResponse) ProtoMessage() {}
+
+func (x *AcknowledgeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_medsbot_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AcknowledgeResponse.ProtoReflect.Descriptor instead.
+func (*AcknowledgeResponse) Descriptor() ([]byte, []int) {
+	return file_medsbot_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *AcknowledgeResponse) GetAlreadyTaken() bool {
+	if x != nil {
+		return x.AlreadyTaken
+	}
+	return false
+}
+
+type WatchRemindersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only events for this medication, or all if empty.
+	Medication    string `protobuf:"bytes,1,opt,name=medication,proto3" json:"medication,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRemindersRequest) Reset() {
+	*x = WatchRemindersRequest{}
+	mi := &file_medsbot_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRemindersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRemindersRequest) ProtoMessage() {}
+
+func (x *WatchRemindersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_medsbot_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRemindersRequest.ProtoReflect.Descriptor instead.
+func (*WatchRemindersRequest) Descriptor() ([]byte, []int) {
+	return file_medsbot_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WatchRemindersRequest) GetMedication() string {
+	if x != nil {
+		return x.Medication
+	}
+	return ""
+}
+
+type ReminderEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          ReminderEvent_Type     `protobuf:"varint,1,opt,name=type,proto3,enum=medsbot.v1.ReminderEvent_Type" json:"type,omitempty"`
+	Reminder      *Reminder              `protobuf:"bytes,2,opt,name=reminder,proto3" json:"reminder,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReminderEvent) Reset() {
+	*x = ReminderEvent{}
+	mi := &file_medsbot_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReminderEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReminderEvent) ProtoMessage() {}
+
+func (x *ReminderEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_medsbot_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReminderEvent.ProtoReflect.Descriptor instead.
+func (*ReminderEvent) Descriptor() ([]byte, []int) {
+	return file_medsbot_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ReminderEvent) GetType() ReminderEvent_Type {
+	if x != nil {
+		return x.Type
+	}
+	return ReminderEvent_TYPE_UNSPECIFIED
+}
+
+func (x *ReminderEvent) GetReminder() *Reminder {
+	if x != nil {
+		return x.Reminder
+	}
+	return nil
+}
+
+var File_medsbot_proto protoreflect.FileDescriptor
+
+const file_medsbot_proto_rawDesc = "" +
+	"\n" +
+	"\rmedsbot.proto\x12\n" +
+	"medsbot.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x80\x02\n" +
+	"\n" +
+	"Medication\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04hour\x18\x02 \x01(\x05R\x04hour\x12\x1c\n" +
+	"\tfrequency\x18\x03 \x01(\tR\tfrequency\x12\x10\n" +
+	"\x03day\x18\x04 \x01(\tR\x03day\x12\x1a\n" +
+	"\bpriority\x18\x05 \x01(\tR\bpriority\x12\x12\n" +
+	"\x04dose\x18\x06 \x01(\tR\x04dose\x12\"\n" +
+	"\finstructions\x18\a \x01(\tR\finstructions\x12'\n" +
+	"\x0fpills_remaining\x18\b \x01(\x05R\x0epillsRemaining\x12\x1d\n" +
+	"\n" +
+	"refill_due\x18\t \x01(\tR\trefillDue\"\x18\n" +
+	"\x16ListMedicationsRequest\"S\n" +
+	"\x17ListMedicationsResponse\x128\n" +
+	"\vmedications\x18\x01 \x03(\v2\x16.medsbot.v1.MedicationR\vmedications\"\xcb\x01\n" +
+	"\x17UpdateMedicationRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x17\n" +
+	"\x04dose\x18\x02 \x01(\tH\x00R\x04dose\x88\x01\x01\x12'\n" +
+	"\finstructions\x18\x03 \x01(\tH\x01R\finstructions\x88\x01\x01\x12,\n" +
+	"\x0fpills_remaining\x18\x04 \x01(\x05H\x02R\x0epillsRemaining\x88\x01\x01B\a\n" +
+	"\x05_doseB\x0f\n" +
+	"\r_instructionsB\x12\n" +
+	"\x10_pills_remaining\"\xd9\x01\n" +
+	"\bReminder\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04date\x18\x02 \x01(\tR\x04date\x12\x1e\n" +
+	"\n" +
+	"medication\x18\x03 \x01(\tR\n" +
+	"medication\x12\"\n" +
+	"\facknowledged\x18\x04 \x01(\bR\facknowledged\x12\x1b\n" +
+	"\tnag_count\x18\x05 \x01(\x05R\bnagCount\x12H\n" +
+	"\x12last_reminder_time\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\x10lastReminderTime\"L\n" +
+	"\x14ListRemindersRequest\x12\x1e\n" +
+	"\n" +
+	"medication\x18\x01 \x01(\tR\n" +
+	"medication\x12\x14\n" +
+	"\x05since\x18\x02 \x01(\tR\x05since\"K\n" +
+	"\x15ListRemindersResponse\x122\n" +
+	"\treminders\x18\x01 \x03(\v2\x14.medsbot.v1.ReminderR\treminders\"4\n" +
+	"\x12AcknowledgeRequest\x12\x1e\n" +
+	"\n" +
+	"medication\x18\x01 \x01(\tR\n" +
+	"medication\":\n" +
+	"\x13AcknowledgeResponse\x12#\n" +
+	"\ralready_taken\x18\x01 \x01(\bR\falreadyTaken\"7\n" +
+	"\x15WatchRemindersRequest\x12\x1e\n" +
+	"\n" +
+	"medication\x18\x01 \x01(\tR\n" +
+	"medication\"\xd8\x01\n" +
+	"\rReminderEvent\x122\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1e.medsbot.v1.ReminderEvent.TypeR\x04type\x120\n" +
+	"\breminder\x18\x02 \x01(\v2\x14.medsbot.v1.ReminderR\breminder\"a\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\f\n" +
+	"\bTYPE_DUE\x10\x01\x12\r\n" +
+	"\tTYPE_SENT\x10\x02\x12\x15\n" +
+	"\x11TYPE_ACKNOWLEDGED\x10\x03\x12\x0f\n" +
+	"\vTYPE_MISSED\x10\x042\xae\x03\n" +
+	"\aMedsBot\x12Z\n" +
+	"\x0fListMedications\x12\".medsbot.v1.ListMedicationsRequest\x1a#.medsbot.v1.ListMedicationsResponse\x12O\n" +
+	"\x10UpdateMedication\x12#.medsbot.v1.UpdateMedicationRequest\x1a\x16.medsbot.v1.Medication\x12T\n" +
+	"\rListReminders\x12 .medsbot.v1.ListRemindersRequest\x1a!.medsbot.v1.ListRemindersResponse\x12N\n" +
+	"\vAcknowledge\x12\x1e.medsbot.v1.AcknowledgeRequest\x1a\x1f.medsbot.v1.AcknowledgeResponse\x12P\n" +
+	"\x0eWatchReminders\x12!.medsbot.v1.WatchRemindersRequest\x1a\x19.medsbot.v1.ReminderEvent0\x01B%Z#meds-bot/internal/grpcapi/medsbotpbb\x06proto3"
+
+var (
+	file_medsbot_proto_rawDescOnce sync.Once
+	file_medsbot_proto_rawDescData []byte
+)
+
+func file_medsbot_proto_rawDescGZIP() []byte {
+	file_medsbot_proto_rawDescOnce.Do(func() {
+		file_medsbot_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_medsbot_proto_rawDesc), len(file_medsbot_proto_rawDesc)))
+	})
+	return file_medsbot_proto_rawDescData
+}
+
+var file_medsbot_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_medsbot_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_medsbot_proto_goTypes = []any{
+	(ReminderEvent_Type)(0),         // 0: medsbot.v1.ReminderEvent.Type
+	(*Medication)(nil),              // 1: medsbot.v1.Medication
+	(*ListMedicationsRequest)(nil),  // 2: medsbot.v1.ListMedicationsRequest
+	(*ListMedicationsResponse)(nil), // 3: medsbot.v1.ListMedicationsResponse
+	(*UpdateMedicationRequest)(nil), // 4: medsbot.v1.UpdateMedicationRequest
+	(*Reminder)(nil),                // 5: medsbot.v1.Reminder
+	(*ListRemindersRequest)(nil),    // 6: medsbot.v1.ListRemindersRequest
+	(*ListRemindersResponse)(nil),   // 7: medsbot.v1.ListRemindersResponse
+	(*AcknowledgeRequest)(nil),      // 8: medsbot.v1.AcknowledgeRequest
+	(*AcknowledgeResponse)(nil),     // 9: medsbot.v1.AcknowledgeResponse
+	(*WatchRemindersRequest)(nil),   // 10: medsbot.v1.WatchRemindersRequest
+	(*ReminderEvent)(nil),           // 11: medsbot.v1.ReminderEvent
+	(*timestamppb.Timestamp)(nil),   // 12: google.protobuf.Timestamp
+}
+var file_medsbot_proto_depIdxs = []int32{
+	1,  // 0: medsbot.v1.ListMedicationsResponse.medications:type_name -> medsbot.v1.Medication
+	12, // 1: medsbot.v1.Reminder.last_reminder_time:type_name -> google.protobuf.Timestamp
+	5,  // 2: medsbot.v1.ListRemindersResponse.reminders:type_name -> medsbot.v1.Reminder
+	0,  // 3: medsbot.v1.ReminderEvent.type:type_name -> medsbot.v1.ReminderEvent.Type
+	5,  // 4: medsbot.v1.ReminderEvent.reminder:type_name -> medsbot.v1.Reminder
+	2,  // 5: medsbot.v1.MedsBot.ListMedications:input_type -> medsbot.v1.ListMedicationsRequest
+	4,  // 6: medsbot.v1.MedsBot.UpdateMedication:input_type -> medsbot.v1.UpdateMedicationRequest
+	6,  // 7: medsbot.v1.MedsBot.ListReminders:input_type -> medsbot.v1.ListRemindersRequest
+	8,  // 8: medsbot.v1.MedsBot.Acknowledge:input_type -> medsbot.v1.AcknowledgeRequest
+	10, // 9: medsbot.v1.MedsBot.WatchReminders:input_type -> medsbot.v1.WatchRemindersRequest
+	3,  // 10: medsbot.v1.MedsBot.ListMedications:output_type -> medsbot.v1.ListMedicationsResponse
+	1,  // 11: medsbot.v1.MedsBot.UpdateMedication:output_type -> medsbot.v1.Medication
+	7,  // 12: medsbot.v1.MedsBot.ListReminders:output_type -> medsbot.v1.ListRemindersResponse
+	9,  // 13: medsbot.v1.MedsBot.Acknowledge:output_type -> medsbot.v1.AcknowledgeResponse
+	11, // 14: medsbot.v1.MedsBot.WatchReminders:output_type -> medsbot.v1.ReminderEvent
+	10, // [10:15] is the sub-list for method output_type
+	5,  // [5:10] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_medsbot_proto_init() }
+func file_medsbot_proto_init() {
+	if File_medsbot_proto != nil {
+		return
+	}
+	file_medsbot_proto_msgTypes[3].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_medsbot_proto_rawDesc), len(file_medsbot_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_medsbot_proto_goTypes,
+		DependencyIndexes: file_medsbot_proto_depIdxs,
+		EnumInfos:         file_medsbot_proto_enumTypes,
+		MessageInfos:      file_medsbot_proto_msgTypes,
+	}.Build()
+	File_medsbot_proto = out.File
+	file_medsbot_proto_goTypes = nil
+	file_medsbot_proto_depIdxs = nil
+}
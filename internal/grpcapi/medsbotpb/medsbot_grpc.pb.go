@@ -0,0 +1,291 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: medsbot.proto
+
+package medsbotpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MedsBot_ListMedications_FullMethodName  = "/medsbot.v1.MedsBot/ListMedications"
+	MedsBot_UpdateMedication_FullMethodName = "/medsbot.v1.MedsBot/UpdateMedication"
+	MedsBot_ListReminders_FullMethodName    = "/medsbot.v1.MedsBot/ListReminders"
+	MedsBot_Acknowledge_FullMethodName      = "/medsbot.v1.MedsBot/Acknowledge"
+	MedsBot_WatchReminders_FullMethodName   = "/medsbot.v1.MedsBot/WatchReminders"
+)
+
+// MedsBotClient is the client API for MedsBot service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// MedsBot exposes medications, reminders and acknowledgments to companion apps.
+type MedsBotClient interface {
+	// ListMedications returns the configured medications with their recorded details.
+	ListMedications(ctx context.Context, in *ListMedicationsRequest, opts ...grpc.CallOption) (*ListMedicationsResponse, error)
+	// UpdateMedication records the dose, instructions or pill count of a configured medication.
+	UpdateMedication(ctx context.Context, in *UpdateMedicationRequest, opts ...grpc.CallOption) (*Medication, error)
+	// ListReminders returns reminders since a date, oldest first.
+	ListReminders(ctx context.Context, in *ListRemindersRequest, opts ...grpc.CallOption) (*ListRemindersResponse, error)
+	// Acknowledge marks today's dose of a medication as taken.
+	Acknowledge(ctx context.Context, in *AcknowledgeRequest, opts ...grpc.CallOption) (*AcknowledgeResponse, error)
+	// WatchReminders streams reminder events as doses are due, reminded and taken.
+	WatchReminders(ctx context.Context, in *WatchRemindersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ReminderEvent], error)
+}
+
+type medsBotClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMedsBotClient(cc grpc.ClientConnInterface) MedsBotClient {
+	return &medsBotClient{cc}
+}
+
+func (c *medsBotClient) ListMedications(ctx context.Context, in *ListMedicationsRequest, opts ...grpc.CallOption) (*ListMedicationsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMedicationsResponse)
+	err := c.cc.Invoke(ctx, MedsBot_ListMedications_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *medsBotClient) UpdateMedication(ctx context.Context, in *UpdateMedicationRequest, opts ...grpc.CallOption) (*Medication, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Medication)
+	err := c.cc.Invoke(ctx, MedsBot_UpdateMedication_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *medsBotClient) ListReminders(ctx context.Context, in *ListRemindersRequest, opts ...grpc.CallOption) (*ListRemindersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRemindersResponse)
+	err := c.cc.Invoke(ctx, MedsBot_ListReminders_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *medsBotClient) Acknowledge(ctx context.Context, in *AcknowledgeRequest, opts ...grpc.CallOption) (*AcknowledgeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AcknowledgeResponse)
+	err := c.cc.Invoke(ctx, MedsBot_Acknowledge_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *medsBotClient) WatchReminders(ctx context.Context, in *WatchRemindersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ReminderEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MedsBot_ServiceDesc.Streams[0], MedsBot_WatchReminders_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRemindersRequest, ReminderEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MedsBot_WatchRemindersClient = grpc.ServerStreamingClient[ReminderEvent]
+
+// MedsBotServer is the server API for MedsBot service.
+// All implementations must embed UnimplementedMedsBotServer
+// for forward compatibility.
+//
+// MedsBot exposes medications, reminders and acknowledgments to companion apps.
+type MedsBotServer interface {
+	// ListMedications returns the configured medications with their recorded details.
+	ListMedications(context.Context, *ListMedicationsRequest) (*ListMedicationsResponse, error)
+	// UpdateMedication records the dose, instructions or pill count of a configured medication.
+	UpdateMedication(context.Context, *UpdateMedicationRequest) (*Medication, error)
+	// ListReminders returns reminders since a date, oldest first.
+	ListReminders(context.Context, *ListRemindersRequest) (*ListRemindersResponse, error)
+	// Acknowledge marks today's dose of a medication as taken.
+	Acknowledge(context.Context, *AcknowledgeRequest) (*AcknowledgeResponse, error)
+	// WatchReminders streams reminder events as doses are due, reminded and taken.
+	WatchReminders(*WatchRemindersRequest, grpc.ServerStreamingServer[ReminderEvent]) error
+	mustEmbedUnimplementedMedsBotServer()
+}
+
+// UnimplementedMedsBotServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMedsBotServer struct{}
+
+func (UnimplementedMedsBotServer) ListMedications(context.Context, *ListMedicationsRequest) (*ListMedicationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMedications not implemented")
+}
+func (UnimplementedMedsBotServer) UpdateMedication(context.Context, *UpdateMedicationRequest) (*Medication, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateMedication not implemented")
+}
+func (UnimplementedMedsBotServer) ListReminders(context.Context, *ListRemindersRequest) (*ListRemindersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListReminders not implemented")
+}
+func (UnimplementedMedsBotServer) Acknowledge(context.Context, *AcknowledgeRequest) (*AcknowledgeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Acknowledge not implemented")
+}
+func (UnimplementedMedsBotServer) WatchReminders(*WatchRemindersRequest, grpc.ServerStreamingServer[ReminderEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchReminders not implemented")
+}
+func (UnimplementedMedsBotServer) mustEmbedUnimplementedMedsBotServer() {}
+func (UnimplementedMedsBotServer) testEmbeddedByValue()                 {}
+
+// UnsafeMedsBotServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MedsBotServer will
+// result in compilation errors.
+type UnsafeMedsBotServer interface {
+	mustEmbedUnimplementedMedsBotServer()
+}
+
+func RegisterMedsBotServer(s grpc.ServiceRegistrar, srv MedsBotServer) {
+	// If the following call pancis, it indicates UnimplementedMedsBotServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MedsBot_ServiceDesc, srv)
+}
+
+func _MedsBot_ListMedications_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMedicationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MedsBotServer).ListMedications(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MedsBot_ListMedications_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MedsBotServer).ListMedications(ctx, req.(*ListMedicationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MedsBot_UpdateMedication_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateMedicationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MedsBotServer).UpdateMedication(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MedsBot_UpdateMedication_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MedsBotServer).UpdateMedication(ctx, req.(*UpdateMedicationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MedsBot_ListReminders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRemindersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MedsBotServer).ListReminders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MedsBot_ListReminders_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MedsBotServer).ListReminders(ctx, req.(*ListRemindersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MedsBot_Acknowledge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AcknowledgeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MedsBotServer).Acknowledge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MedsBot_Acknowledge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MedsBotServer).Acknowledge(ctx, req.(*AcknowledgeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MedsBot_WatchReminders_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRemindersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MedsBotServer).WatchReminders(m, &grpc.GenericServerStream[WatchRemindersRequest, ReminderEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MedsBot_WatchRemindersServer = grpc.ServerStreamingServer[ReminderEvent]
+
+// MedsBot_ServiceDesc is the grpc.ServiceDesc for MedsBot service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MedsBot_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "medsbot.v1.MedsBot",
+	HandlerType: (*MedsBotServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListMedications",
+			Handler:    _MedsBot_ListMedications_Handler,
+		},
+		{
+			MethodName: "UpdateMedication",
+			Handler:    _MedsBot_UpdateMedication_Handler,
+		},
+		{
+			MethodName: "ListReminders",
+			Handler:    _MedsBot_ListReminders_Handler,
+		},
+		{
+			MethodName: "Acknowledge",
+			Handler:    _MedsBot_Acknowledge_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchReminders",
+			Handler:       _MedsBot_WatchReminders_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "medsbot.proto",
+}
@@ -0,0 +1,416 @@
+// Package homeassistant publishes each scheduled medication to Home Assistant over MQTT. MQTT discovery
+// messages make each one appear as a device with no configuration in Home Assistant, with a binary
+// sensor that's on while a dose is waiting to be taken and a button that marks it as taken.
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/events"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Payloads of the pending sensor, the button and the bot's availability, which are Home Assistant's defaults
+const (
+	PayloadOn      = "ON"
+	PayloadOff     = "OFF"
+	PayloadPress   = "PRESS"
+	PayloadOnline  = "online"
+	PayloadOffline = "offline"
+)
+
+// Source is where doses marked as taken with the button are recorded as taken from
+const Source = "Home Assistant"
+
+// Timeout is how long the broker has to accept a message
+const Timeout = 10 * time.Second
+
+// queueSize is how many states can wait to be published before more are dropped
+const queueSize = 64
+
+// Acknowledger marks today's dose of a medication as taken
+type Acknowledger interface {
+	AcknowledgeMedication(ctx context.Context, medicationName, source string) (bool, error)
+}
+
+// ReminderStore gets today's reminder for a medication
+type ReminderStore interface {
+	GetTodayReminder(ctx context.Context, medicationType string) (*db.Reminder, error)
+}
+
+// Message is a message published to the broker
+type Message struct {
+	Topic    string
+	Payload  string
+	Retained bool
+}
+
+// device groups a medication's entities in Home Assistant
+type device struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// entity is the discovery config of a medication's binary sensor or button
+type entity struct {
+	Name              string `json:"name"`
+	UniqueID          string `json:"unique_id"`
+	StateTopic        string `json:"state_topic,omitempty"`
+	CommandTopic      string `json:"command_topic,omitempty"`
+	AvailabilityTopic string `json:"availability_topic"`
+	Icon              string `json:"icon"`
+	Device            device `json:"device"`
+}
+
+// Bridge keeps Home Assistant's view of the medications up to date and marks doses as taken when
+// their button is pressed
+type Bridge struct {
+	medications     []config.Medication
+	objectIDs       map[string]string
+	topicPrefix     string
+	discoveryPrefix string
+	store           ReminderStore
+	acknowledger    Acknowledger
+	location        *time.Location
+	now             func() time.Time
+
+	client  mqtt.Client
+	publish func(Message) error
+	// queue holds the states published for events, so the event bus never waits on the broker
+	queue chan Message
+}
+
+// NewBridge creates a bridge to the MQTT broker in the config. It doesn't connect until it's started.
+func NewBridge(cfg *config.Config, store ReminderStore, acknowledger Acknowledger, location *time.Location) *Bridge {
+	b := &Bridge{
+		objectIDs:       make(map[string]string),
+		topicPrefix:     cfg.MQTTTopicPrefix,
+		discoveryPrefix: cfg.HADiscoveryPrefix,
+		store:           store,
+		acknowledger:    acknowledger,
+		location:        location,
+		now:             time.Now,
+		queue:           make(chan Message, queueSize),
+	}
+	// As-needed medications are never pending, so they're logged with /meds log instead
+	for _, medication := range cfg.Medications {
+		if medication.AsNeeded() {
+			continue
+		}
+		b.medications = append(b.medications, medication)
+		b.objectIDs[medication.Name] = uniqueObjectID(medication.Name, b.objectIDs)
+	}
+
+	options := mqtt.NewClientOptions().
+		AddBroker(cfg.MQTTBrokerURL).
+		SetClientID(cfg.MQTTTopicPrefix).
+		SetUsername(cfg.MQTTUsername).
+		SetPassword(cfg.MQTTPassword).
+		SetWill(b.availabilityTopic(), PayloadOffline, 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		// Pressing a button acknowledges the dose over Discord, which mustn't hold up other messages
+		SetOrderMatters(false).
+		// Discovery, button subscriptions and states are (re)sent every time the connection is made
+		SetOnConnectHandler(b.announce).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("Warning: lost connection to MQTT broker, reconnecting: %v", err)
+		})
+	b.client = mqtt.NewClient(options)
+	b.publish = b.publishToBroker
+
+	return b
+}
+
+// Start connects to the broker in the background, retrying until it's reachable, and publishes the
+// medications' states as their doses are reminded about, taken and missed
+func (b *Bridge) Start(ctx context.Context, bus *events.Bus) {
+	b.subscribe(bus)
+	go b.publishQueued(ctx)
+
+	b.client.Connect()
+	log.Printf("Publishing %d medications to Home Assistant over MQTT", len(b.medications))
+	go func() {
+		<-ctx.Done()
+		b.Close()
+	}()
+}
+
+// announce publishes the medications to Home Assistant and listens for their buttons, each time the
+// bridge connects to the broker
+func (b *Bridge) announce(client mqtt.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	log.Println("Connected to MQTT broker")
+	messages, err := b.DiscoveryMessages()
+	if err != nil {
+		log.Printf("Error creating Home Assistant discovery: %v", err)
+	}
+	for _, message := range messages {
+		if err := b.publish(message); err != nil {
+			log.Printf("Error publishing Home Assistant discovery: %v", err)
+		}
+	}
+
+	for _, medication := range b.medications {
+		name := medication.Name
+		token := client.Subscribe(b.commandTopic(name), 1, func(_ mqtt.Client, message mqtt.Message) {
+			b.handleCommand(name, string(message.Payload()))
+		})
+		if token.WaitTimeout(Timeout) && token.Error() != nil {
+			log.Printf("Error subscribing to Home Assistant button for %s: %v", name, token.Error())
+		}
+		b.publishCurrentState(ctx, medication)
+	}
+
+	if err := b.publish(Message{Topic: b.availabilityTopic(), Payload: PayloadOnline, Retained: true}); err != nil {
+		log.Printf("Error publishing MQTT availability: %v", err)
+	}
+}
+
+// subscribe publishes a medication's pending state when a reminder for it is sent, and clears it
+// when the dose is taken, skipped or missed. States are queued and published in the background, on a
+// best-effort basis, since the broker may be slow or unreachable.
+func (b *Bridge) subscribe(bus *events.Bus) {
+	events.On(bus, func(ctx context.Context, event events.ReminderSent) error {
+		b.queueState(event.Medication, true)
+		return nil
+	})
+	events.On(bus, func(ctx context.Context, event events.DoseAcknowledged) error {
+		b.queueState(event.Medication, false)
+		return nil
+	})
+	events.On(bus, func(ctx context.Context, event events.DoseSkipped) error {
+		b.queueState(event.Medication, false)
+		return nil
+	})
+	events.On(bus, func(ctx context.Context, event events.DoseMissed) error {
+		b.queueState(event.Medication, false)
+		return nil
+	})
+}
+
+// queueState queues a medication's pending sensor state to be published, if it's published to Home
+// Assistant, dropping it if the queue is full
+func (b *Bridge) queueState(medicationName string, pending bool) {
+	message, ok := b.stateMessage(medicationName, pending)
+	if !ok {
+		return
+	}
+
+	select {
+	case b.queue <- message:
+	default:
+		log.Printf("Warning: Home Assistant publishing is behind, dropping the state of %s", medicationName)
+	}
+}
+
+// publishQueued publishes the queued states in order until the context is done
+func (b *Bridge) publishQueued(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message := <-b.queue:
+			if err := b.publish(message); err != nil {
+				log.Printf("Error publishing Home Assistant state: %v", err)
+			}
+		}
+	}
+}
+
+// Close marks the bot as offline in Home Assistant and disconnects from the broker, or stops trying to
+// connect to it
+func (b *Bridge) Close() {
+	if b.client.IsConnected() {
+		if err := b.publish(Message{Topic: b.availabilityTopic(), Payload: PayloadOffline, Retained: true}); err != nil {
+			log.Printf("Error publishing MQTT availability: %v", err)
+		}
+	}
+	b.client.Disconnect(uint(time.Second / time.Millisecond))
+}
+
+// DiscoveryMessages returns the retained discovery configs of each medication's pending sensor and button
+func (b *Bridge) DiscoveryMessages() ([]Message, error) {
+	var messages []Message
+	for _, medication := range b.medications {
+		objectID := b.objectIDs[medication.Name]
+		dev := device{
+			Identifiers:  []string{"meds_bot_" + objectID},
+			Name:         medication.Name,
+			Manufacturer: "meds-bot",
+			Model:        "Medication",
+		}
+
+		sensor := entity{
+			Name:              "Dose pending",
+			UniqueID:          "meds_bot_" + objectID + "_pending",
+			StateTopic:        b.stateTopic(medication.Name),
+			AvailabilityTopic: b.availabilityTopic(),
+			Icon:              "mdi:pill",
+			Device:            dev,
+		}
+		button := entity{
+			Name:              "Mark as taken",
+			UniqueID:          "meds_bot_" + objectID + "_take",
+			CommandTopic:      b.commandTopic(medication.Name),
+			AvailabilityTopic: b.availabilityTopic(),
+			Icon:              "mdi:check",
+			Device:            dev,
+		}
+
+		sensorConfig, err := json.Marshal(sensor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s sensor: %w", medication.Name, err)
+		}
+		buttonConfig, err := json.Marshal(button)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s button: %w", medication.Name, err)
+		}
+
+		messages = append(messages,
+			Message{Topic: fmt.Sprintf("%s/binary_sensor/meds_bot_%s/pending/config", b.discoveryPrefix, objectID), Payload: string(sensorConfig), Retained: true},
+			Message{Topic: fmt.Sprintf("%s/button/meds_bot_%s/take/config", b.discoveryPrefix, objectID), Payload: string(buttonConfig), Retained: true},
+		)
+	}
+	return messages, nil
+}
+
+// handleCommand marks a medication's dose as taken when its button is pressed in Home Assistant
+func (b *Bridge) handleCommand(medicationName, payload string) {
+	if payload != PayloadPress {
+		log.Printf("Warning: ignoring unknown Home Assistant command %q for %s", payload, medicationName)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	alreadyTaken, err := b.acknowledger.AcknowledgeMedication(ctx, medicationName, Source)
+	if err != nil {
+		log.Printf("Error acknowledging %s from Home Assistant: %v", medicationName, err)
+		return
+	}
+	if alreadyTaken {
+		log.Printf("%s was already taken when it was marked as taken in Home Assistant", medicationName)
+		// The sensor may have missed the acknowledgment while the bridge was disconnected
+		if err := b.publishState(medicationName, false); err != nil {
+			log.Printf("Error publishing Home Assistant state of %s: %v", medicationName, err)
+		}
+	}
+}
+
+// publishCurrentState publishes whether today's dose of a medication is waiting to be taken, from its reminder
+func (b *Bridge) publishCurrentState(ctx context.Context, medication config.Medication) {
+	reminder, err := b.store.GetTodayReminder(ctx, medication.Name)
+	if err != nil {
+		log.Printf("Error getting reminder for %s: %v", medication.Name, err)
+		return
+	}
+	if err := b.publishState(medication.Name, Pending(medication, reminder, b.now().In(b.location))); err != nil {
+		log.Printf("Error publishing Home Assistant state of %s: %v", medication.Name, err)
+	}
+}
+
+// publishState publishes a medication's pending sensor state, if it's published to Home Assistant
+func (b *Bridge) publishState(medicationName string, pending bool) error {
+	message, ok := b.stateMessage(medicationName, pending)
+	if !ok {
+		return nil
+	}
+	return b.publish(message)
+}
+
+// stateMessage returns the message with a medication's pending sensor state, or false if it isn't
+// published to Home Assistant
+func (b *Bridge) stateMessage(medicationName string, pending bool) (Message, bool) {
+	if _, ok := b.objectIDs[medicationName]; !ok {
+		return Message{}, false
+	}
+
+	payload := PayloadOff
+	if pending {
+		payload = PayloadOn
+	}
+	return Message{Topic: b.stateTopic(medicationName), Payload: payload, Retained: true}, true
+}
+
+// publishToBroker publishes a message, waiting for the broker to accept it
+func (b *Bridge) publishToBroker(message Message) error {
+	token := b.client.Publish(message.Topic, 1, message.Retained, message.Payload)
+	if !token.WaitTimeout(Timeout) {
+		return fmt.Errorf("timed out publishing to %s", message.Topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", message.Topic, err)
+	}
+	return nil
+}
+
+// Pending reports whether a medication's dose has been reminded about and is still waiting to be
+// taken, which stops once it's missed
+func Pending(medication config.Medication, reminder *db.Reminder, now time.Time) bool {
+	now = medication.In(now)
+	if reminder.Resolved() || reminder.NagCount == 0 || !medication.IsScheduledOn(now) {
+		return false
+	}
+	return now.Before(medication.DueAt(now).Add(config.ReminderWindowHours * time.Hour))
+}
+
+func (b *Bridge) availabilityTopic() string {
+	return b.topicPrefix + "/status"
+}
+
+func (b *Bridge) stateTopic(medicationName string) string {
+	return fmt.Sprintf("%s/%s/pending", b.topicPrefix, b.objectIDs[medicationName])
+}
+
+func (b *Bridge) commandTopic(medicationName string) string {
+	return fmt.Sprintf("%s/%s/take", b.topicPrefix, b.objectIDs[medicationName])
+}
+
+// uniqueObjectID returns an ID for a medication made only of the characters allowed in topics and
+// Home Assistant entity IDs, e.g. "metformin_08_00", numbered if it's already taken
+func uniqueObjectID(name string, taken map[string]string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteRune('_')
+			underscore = true
+		}
+	}
+	base := strings.TrimSuffix(b.String(), "_")
+	if base == "" {
+		base = "medication"
+	}
+
+	id := base
+	for n := 2; ; n++ {
+		inUse := false
+		for _, other := range taken {
+			if other == id {
+				inUse = true
+				break
+			}
+		}
+		if !inUse {
+			return id
+		}
+		id = fmt.Sprintf("%s_%d", base, n)
+	}
+}
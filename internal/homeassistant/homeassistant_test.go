@@ -0,0 +1,182 @@
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/events"
+)
+
+type fakeAcknowledger struct {
+	acknowledged []string
+	sources      []string
+}
+
+func (f *fakeAcknowledger) AcknowledgeMedication(ctx context.Context, medicationName, source string) (bool, error) {
+	f.acknowledged = append(f.acknowledged, medicationName)
+	f.sources = append(f.sources, source)
+	return false, nil
+}
+
+// newTestBridge creates a bridge that records what it publishes instead of connecting to a broker
+func newTestBridge(acknowledger Acknowledger, medications ...config.Medication) (*Bridge, *[]Message) {
+	cfg := &config.Config{
+		Medications:       medications,
+		MQTTBrokerURL:     "tcp://localhost:1883",
+		MQTTTopicPrefix:   "meds-bot",
+		HADiscoveryPrefix: "homeassistant",
+	}
+	bridge := NewBridge(cfg, db.NewMemoryStore(time.UTC), acknowledger, time.UTC)
+
+	var published []Message
+	bridge.publish = func(message Message) error {
+		published = append(published, message)
+		return nil
+	}
+	return bridge, &published
+}
+
+func TestDiscoveryMessages(t *testing.T) {
+	bridge, _ := newTestBridge(&fakeAcknowledger{},
+		config.Medication{Name: "Metformin 08:00", Hour: 8, Frequency: "daily"},
+		config.Medication{Name: "Ibuprofen", Frequency: config.FrequencyAsNeeded},
+	)
+
+	messages, err := bridge.DiscoveryMessages()
+	if err != nil {
+		t.Fatalf("DiscoveryMessages failed: %v", err)
+	}
+	// As-needed medications are left out
+	if len(messages) != 2 {
+		t.Fatalf("Expected a sensor and a button, got %d messages", len(messages))
+	}
+
+	sensor := messages[0]
+	if sensor.Topic != "homeassistant/binary_sensor/meds_bot_metformin_08_00/pending/config" || !sensor.Retained {
+		t.Errorf("Unexpected sensor discovery message: %+v", sensor)
+	}
+	var sensorConfig entity
+	if err := json.Unmarshal([]byte(sensor.Payload), &sensorConfig); err != nil {
+		t.Fatalf("Failed to decode sensor config: %v", err)
+	}
+	if sensorConfig.StateTopic != "meds-bot/metformin_08_00/pending" || sensorConfig.AvailabilityTopic != "meds-bot/status" {
+		t.Errorf("Unexpected sensor config: %+v", sensorConfig)
+	}
+	if sensorConfig.Device.Name != "Metformin 08:00" {
+		t.Errorf("Sensor device = %q, want the medication's name", sensorConfig.Device.Name)
+	}
+
+	var buttonConfig entity
+	if err := json.Unmarshal([]byte(messages[1].Payload), &buttonConfig); err != nil {
+		t.Fatalf("Failed to decode button config: %v", err)
+	}
+	if messages[1].Topic != "homeassistant/button/meds_bot_metformin_08_00/take/config" || buttonConfig.CommandTopic != "meds-bot/metformin_08_00/take" {
+		t.Errorf("Unexpected button discovery: %s %+v", messages[1].Topic, buttonConfig)
+	}
+	if buttonConfig.Device.Identifiers[0] != sensorConfig.Device.Identifiers[0] {
+		t.Error("Expected the sensor and button to belong to the same device")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	bridge, published := newTestBridge(&fakeAcknowledger{}, config.Medication{Name: "Heart Pill", Hour: 8, Frequency: "daily"})
+	bus := events.NewBus()
+	bridge.subscribe(bus)
+
+	ctx := context.Background()
+	for _, event := range []events.Event{
+		events.ReminderSent{Medication: "Heart Pill", NagCount: 1},
+		events.DoseAcknowledged{Medication: "Heart Pill", Source: "Discord"},
+		// Medications that aren't published are ignored
+		events.ReminderSent{Medication: "Vitamin D", NagCount: 1},
+	} {
+		if err := bus.Publish(ctx, event); err != nil {
+			t.Fatalf("Failed to publish: %v", err)
+		}
+	}
+
+	// States are queued rather than published while the event is handled
+	if len(*published) != 0 {
+		t.Fatalf("Expected states to be queued, got %+v", *published)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		bridge.publishQueued(ctx)
+		close(done)
+	}()
+	for len(bridge.queue) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if len(*published) != 2 {
+		t.Fatalf("Expected 2 states, got %d: %+v", len(*published), *published)
+	}
+	if (*published)[0].Topic != "meds-bot/heart_pill/pending" || (*published)[0].Payload != PayloadOn {
+		t.Errorf("Expected the dose to be pending once reminded about, got %+v", (*published)[0])
+	}
+	if (*published)[1].Payload != PayloadOff {
+		t.Errorf("Expected the dose to stop pending once taken, got %+v", (*published)[1])
+	}
+}
+
+func TestHandleCommand(t *testing.T) {
+	acknowledger := &fakeAcknowledger{}
+	bridge, _ := newTestBridge(acknowledger, config.Medication{Name: "Heart Pill", Hour: 8, Frequency: "daily"})
+
+	bridge.handleCommand("Heart Pill", "unexpected")
+	bridge.handleCommand("Heart Pill", PayloadPress)
+
+	if len(acknowledger.acknowledged) != 1 || acknowledger.acknowledged[0] != "Heart Pill" || acknowledger.sources[0] != Source {
+		t.Errorf("Expected one dose acknowledged from Home Assistant, got %v from %v", acknowledger.acknowledged, acknowledger.sources)
+	}
+}
+
+func TestPending(t *testing.T) {
+	medication := config.Medication{Name: "Heart Pill", Hour: 8, Frequency: "daily"}
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		reminder db.Reminder
+		now      time.Time
+		want     bool
+	}{
+		{name: "Not reminded yet", reminder: db.Reminder{}, now: now, want: false},
+		{name: "Reminded", reminder: db.Reminder{NagCount: 1}, now: now, want: true},
+		{name: "Taken", reminder: db.Reminder{NagCount: 1, Acknowledged: true}, now: now, want: false},
+		{name: "Missed", reminder: db.Reminder{NagCount: 3}, now: now.Add(5 * time.Hour), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Pending(medication, &tt.reminder, tt.now); got != tt.want {
+				t.Errorf("Pending() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUniqueObjectID(t *testing.T) {
+	taken := map[string]string{}
+	for name, want := range map[string]string{
+		"Vitamin D (Sam)": "vitamin_d_sam",
+		"Metformin 08:00": "metformin_08_00",
+		"💊":               "medication",
+	} {
+		if got := uniqueObjectID(name, taken); got != want {
+			t.Errorf("uniqueObjectID(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	taken["Vitamin D (Sam)"] = "vitamin_d_sam"
+	if got := uniqueObjectID("Vitamin D [Sam]", taken); got != "vitamin_d_sam_2" {
+		t.Errorf("uniqueObjectID() of a clashing name = %q, want vitamin_d_sam_2", got)
+	}
+}
@@ -0,0 +1,185 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"log"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+)
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before writing it
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Logging logs each request's method, path, status and duration.
+// Query strings are left out since they may contain tokens.
+func Logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		log.Printf("%s %s %d %s", r.Method, r.URL.Path, recorder.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// Recover responds with a 500 instead of dropping the connection when a handler panics
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("Panic handling %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// gzipResponseWriter compresses the response if it has a body of a compressible content type. The
+// status is held back until the first write, so the content type can be sniffed from it if it isn't set.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz      *gzip.Writer
+	status  int
+	decided bool
+}
+
+// WriteHeader holds back the status until the body is written, except for informational responses,
+// which are sent straight away
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write compresses data if the response is compressed
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(data))
+		}
+		w.decide()
+	}
+	if w.gz != nil {
+		return w.gz.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// decide compresses the response if it can have a body and its content type is compressible, then
+// writes the status
+func (w *gzipResponseWriter) decide() {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	bodyless := w.status == http.StatusNoContent || w.status == http.StatusNotModified
+	if !bodyless && w.Header().Get("Content-Encoding") == "" && compressible(w.Header().Get("Content-Type")) {
+		// The content length set by the handler no longer matches once compressed
+		w.Header().Del("Content-Length")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// close writes the status of a response without a body, or finishes compressing one
+func (w *gzipResponseWriter) close() error {
+	if !w.decided {
+		// Without a body there's nothing to compress
+		if w.status != 0 {
+			w.decided = true
+			w.ResponseWriter.WriteHeader(w.status)
+		}
+		return nil
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// compressible reports whether a content type is text, which compresses well, rather than an image or
+// other already compressed data
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	return slices.Contains([]string{"application/json", "application/xml", "application/javascript"}, mediaType)
+}
+
+// Gzip compresses responses with a body of a compressible content type for clients that accept gzip
+// encoding. Responses to HEAD requests aren't compressed, since they have no body to match the headers.
+func Gzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if r.Method == http.MethodHead || !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer func() {
+			if err := gw.close(); err != nil {
+				log.Printf("Error compressing response to %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		}()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// CORS allows browser requests from the given origins, or any origin if "*" is included.
+// No CORS headers are added when the list is empty.
+func CORS(allowedOrigins []string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || len(allowedOrigins) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if slices.Contains(allowedOrigins, "*") {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else if slices.Contains(allowedOrigins, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestMiddlewareStack tests that panics are recovered and responses are compressed
+func TestMiddlewareStack(t *testing.T) {
+	server := NewBuilder(":0").
+		Use(Recover, Gzip, CORS([]string{"https://dashboard.example.com"})).
+		HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		}).
+		HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}).
+		Build()
+
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a gzip response, got headers %v", rec.Header())
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://dashboard.example.com" {
+		t.Errorf("Expected the origin to be allowed, got %q", rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Failed to read gzip body: %v", err)
+	}
+	body, _ := io.ReadAll(gz)
+	if string(body) != "hello" {
+		t.Errorf("Expected body hello, got %q", body)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 after a panic, got %d", rec.Code)
+	}
+
+	// Other origins aren't allowed
+	req = httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS header for other origins, got %q", rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+// TestGzip tests that only responses with a body of a compressible content type are compressed
+func TestGzip(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		handler      http.HandlerFunc
+		wantStatus   int
+		wantCompress bool
+	}{
+		{
+			name:         "Sniffed text",
+			handler:      func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<html>hello</html>")) },
+			wantStatus:   http.StatusOK,
+			wantCompress: true,
+		},
+		{
+			name: "JSON set after the status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"ok":true}`))
+			},
+			wantStatus:   http.StatusCreated,
+			wantCompress: true,
+		},
+		{
+			name: "Image",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/jpeg")
+				w.Write([]byte("jpeg"))
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Sniffed binary",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.Write([]byte{0x1f, 0x8b, 0x08, 0x00}) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "No content",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "Not modified",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusNotModified)
+			},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:   "HEAD",
+			method: http.MethodHead,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Content-Length", "5")
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			Gzip(tt.handler).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if compressed := rec.Header().Get("Content-Encoding") == "gzip"; compressed != tt.wantCompress {
+				t.Errorf("Expected compressed %v, got headers %v", tt.wantCompress, rec.Header())
+			}
+			if !tt.wantCompress {
+				return
+			}
+			gz, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatalf("Failed to read gzip body: %v", err)
+			}
+			if _, err := io.ReadAll(gz); err != nil {
+				t.Errorf("Failed to decompress body: %v", err)
+			}
+		})
+	}
+}
+
+// TestGzipInformational tests that an informational response is sent as it is, before the compressed
+// final response. ResponseRecorder only keeps the first status, so this uses a server.
+func TestGzipInformational(t *testing.T) {
+	server := httptest.NewServer(Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Write([]byte("hello"))
+	})))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	// The transport, unlike the client, leaves the body compressed
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a compressed 200 response, got %d with headers %v", resp.StatusCode, resp.Header)
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read gzip body: %v", err)
+	}
+	body, _ := io.ReadAll(gz)
+	if string(body) != "hello" {
+		t.Errorf("Expected body hello, got %q", body)
+	}
+}
@@ -0,0 +1,133 @@
+package httpserver
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// limiterIdleTTL is how long an unused client's bucket is kept before being discarded
+const limiterIdleTTL = 10 * time.Minute
+
+// bucket is a token bucket for a single client
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// RateLimiter limits requests per client with token buckets
+type RateLimiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter creates a rate limiter allowing the given requests per minute, with bursts of up to burst requests
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	return &RateLimiter{
+		perSecond: float64(perMinute) / 60,
+		burst:     float64(max(burst, 1)),
+		buckets:   make(map[string]*bucket),
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token from the client's bucket, returning false and the time until the next token if it's empty
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.lastSeen).Seconds()*l.perSecond)
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.perSecond * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// sweep discards buckets of clients that haven't made a request recently
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < limiterIdleTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > limiterIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimit limits requests both per client IP and per credential, so a leaked link or misbehaving
+// integration can't hammer the store. Credentials are bearer tokens, token query parameters and
+// acknowledgment link signatures. When trustForwarded is set, the client IP is taken from the
+// X-Forwarded-For entry added by a reverse proxy.
+func RateLimit(limiter *RateLimiter, trustForwarded bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys := []string{"ip:" + clientIP(r, trustForwarded)}
+			if credential := requestCredential(r); credential != "" {
+				keys = append(keys, "credential:"+credential)
+			}
+
+			for _, key := range keys {
+				if ok, wait := limiter.Allow(key); !ok {
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+					http.Error(w, "too many requests", http.StatusTooManyRequests)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the IP address of the client making a request. Behind a proxy that's the last
+// X-Forwarded-For entry, which the proxy added, since clients can put anything before it.
+func clientIP(r *http.Request, trustForwarded bool) string {
+	if trustForwarded {
+		forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		if last := strings.TrimSpace(forwarded[len(forwarded)-1]); last != "" {
+			return last
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requestCredential returns the token or link signature a request is authenticated with, if any
+func requestCredential(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("sig")
+}
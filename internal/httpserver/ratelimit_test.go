@@ -0,0 +1,91 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestRateLimiter tests that buckets empty after a burst and refill over time
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(60, 2)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow("client"); !ok {
+			t.Fatalf("Request %d within the burst was limited", i+1)
+		}
+	}
+
+	ok, wait := limiter.Allow("client")
+	if ok {
+		t.Fatal("Expected the request after the burst to be limited")
+	}
+	if wait != time.Second {
+		t.Errorf("Expected to wait 1s, got %v", wait)
+	}
+
+	// Other clients have their own buckets
+	if ok, _ := limiter.Allow("other"); !ok {
+		t.Error("Expected another client's request to be allowed")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := limiter.Allow("client"); !ok {
+		t.Error("Expected a request to be allowed once a token refilled")
+	}
+}
+
+// TestRateLimitCredential tests that requests are limited per credential across IPs
+func TestRateLimitCredential(t *testing.T) {
+	limiter := NewRateLimiter(60, 1)
+	handler := RateLimit(limiter, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i, ip := range []string{"192.0.2.1:1234", "192.0.2.2:1234"} {
+		req := httptest.NewRequest(http.MethodGet, "/ack?sig=leaked", nil)
+		req.RemoteAddr = ip
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		expected := http.StatusOK
+		if i > 0 {
+			expected = http.StatusTooManyRequests
+		}
+		if rec.Code != expected {
+			t.Errorf("Request %d: expected status %d, got %d", i+1, expected, rec.Code)
+		}
+	}
+}
+
+// TestClientIP tests that only the X-Forwarded-For entry added by the proxy is trusted
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		forwarded      []string
+		trustForwarded bool
+		want           string
+	}{
+		{name: "Direct", want: "192.0.2.1"},
+		{name: "Forwarded header ignored", forwarded: []string{"203.0.113.9"}, want: "192.0.2.1"},
+		{name: "Behind a proxy", forwarded: []string{"203.0.113.9"}, trustForwarded: true, want: "203.0.113.9"},
+		{name: "Spoofed entries", forwarded: []string{"10.0.0.1, 10.0.0.2, 203.0.113.9"}, trustForwarded: true, want: "203.0.113.9"},
+		{name: "Spoofed header", forwarded: []string{"10.0.0.1", "203.0.113.9"}, trustForwarded: true, want: "203.0.113.9"},
+		{name: "No header behind a proxy", trustForwarded: true, want: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := clientIP(r, tt.trustForwarded); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package httpserver
+
+import (
+	"net/http"
+	"time"
+)
+
+// Default server timeouts, so slow or stalled clients can't hold connections open indefinitely
+const (
+	DefaultReadTimeout  = 10 * time.Second
+	DefaultWriteTimeout = 30 * time.Second
+	DefaultIdleTimeout  = 60 * time.Second
+)
+
+// Middleware wraps an HTTP handler
+type Middleware func(http.Handler) http.Handler
+
+// Builder assembles an HTTP server with timeouts and a middleware stack
+type Builder struct {
+	addr         string
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+	mux          *http.ServeMux
+	middleware   []Middleware
+}
+
+// NewBuilder creates a server builder listening on the given address with the default timeouts
+func NewBuilder(addr string) *Builder {
+	return &Builder{
+		addr:         addr,
+		readTimeout:  DefaultReadTimeout,
+		writeTimeout: DefaultWriteTimeout,
+		idleTimeout:  DefaultIdleTimeout,
+		mux:          http.NewServeMux(),
+	}
+}
+
+// WithTimeouts sets the server's read, write and idle timeouts. Zero values keep the current timeout.
+func (b *Builder) WithTimeouts(read, write, idle time.Duration) *Builder {
+	if read > 0 {
+		b.readTimeout = read
+	}
+	if write > 0 {
+		b.writeTimeout = write
+	}
+	if idle > 0 {
+		b.idleTimeout = idle
+	}
+	return b
+}
+
+// Use adds middleware to the stack. Middleware added first is outermost.
+func (b *Builder) Use(middleware ...Middleware) *Builder {
+	b.middleware = append(b.middleware, middleware...)
+	return b
+}
+
+// Handle registers a handler for a path
+func (b *Builder) Handle(path string, handler http.Handler) *Builder {
+	b.mux.Handle(path, handler)
+	return b
+}
+
+// HandleFunc registers a handler function for a path
+func (b *Builder) HandleFunc(path string, handler func(http.ResponseWriter, *http.Request)) *Builder {
+	b.mux.HandleFunc(path, handler)
+	return b
+}
+
+// Build creates the server with the middleware stack wrapped around the registered handlers
+func (b *Builder) Build() *http.Server {
+	var handler http.Handler = b.mux
+	for i := len(b.middleware) - 1; i >= 0; i-- {
+		handler = b.middleware[i](handler)
+	}
+
+	return &http.Server{
+		Addr:              b.addr,
+		Handler:           handler,
+		ReadTimeout:       b.readTimeout,
+		ReadHeaderTimeout: b.readTimeout,
+		WriteTimeout:      b.writeTimeout,
+		IdleTimeout:       b.idleTimeout,
+	}
+}
@@ -0,0 +1,189 @@
+// Package loadtest simulates a large deployment against a stub notifier, measuring how long
+// reminder checks take and how many database operations they make.
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/eventlog"
+	"meds-bot/internal/events"
+	"meds-bot/internal/reminder"
+)
+
+// Options configures a load test
+type Options struct {
+	// Guilds and Medications simulate this many guilds each with this many medications
+	Guilds      int
+	Medications int
+	// Ticks is how many reminder checks are run
+	Ticks int
+	// Workers is how many reminders are sent at once
+	Workers int
+	// Latency is how long the stub notifier takes to send each reminder
+	Latency time.Duration
+	// DBPath is the database used, a temporary one when empty, or MemoryDB for the in-memory store
+	DBPath string
+	// Cache wraps the store with the in-memory reminder cache, as the bot does
+	Cache bool
+}
+
+// MemoryDB is the DBPath that runs a load test against the in-memory store
+const MemoryDB = "memory"
+
+// Report summarises a load test
+type Report struct {
+	Medications int
+	Ticks       int
+	Reminders   int64
+	DBOps       int64
+	Total       time.Duration
+	Mean        time.Duration
+	P50         time.Duration
+	P95         time.Duration
+	Max         time.Duration
+}
+
+// DBOpsPerSecond is the database throughput over all ticks
+func (r *Report) DBOpsPerSecond() float64 {
+	if r.Total <= 0 {
+		return 0
+	}
+	return float64(r.DBOps) / r.Total.Seconds()
+}
+
+// Write prints the report
+func (r *Report) Write(w io.Writer) {
+	fmt.Fprintf(w, "Medications:     %d\n", r.Medications)
+	fmt.Fprintf(w, "Ticks:           %d\n", r.Ticks)
+	fmt.Fprintf(w, "Reminders sent:  %d\n", r.Reminders)
+	fmt.Fprintf(w, "Tick latency:    mean %v, p50 %v, p95 %v, max %v\n", r.Mean, r.P50, r.P95, r.Max)
+	fmt.Fprintf(w, "DB operations:   %d (%.0f/s)\n", r.DBOps, r.DBOpsPerSecond())
+}
+
+// Run runs a load test
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.Guilds < 1 || opts.Medications < 1 || opts.Ticks < 1 {
+		return nil, fmt.Errorf("guilds, medications and ticks must be at least 1")
+	}
+
+	store, err := openStore(ctx, opts.DBPath)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	if opts.Cache {
+		store = db.NewCachedStore(store, db.DefaultCacheTTL)
+	}
+	counter := &countingStore{StoreInterface: store}
+
+	// Every medication is due now, so each tick sends (or nags) all of them
+	hour := time.Now().UTC().Hour()
+	cfg := &config.Config{
+		ReminderIntervalMins: 1,
+		ReminderWorkers:      max(opts.Workers, 1),
+		Timezone:             "UTC",
+	}
+	for g := range opts.Guilds {
+		for m := range opts.Medications {
+			cfg.Medications = append(cfg.Medications, config.Medication{
+				Name:      fmt.Sprintf("guild%d-med%d", g+1, m+1),
+				Hour:      hour,
+				Frequency: "daily",
+			})
+		}
+	}
+
+	bus := events.NewBus()
+	eventlog.Subscribe(bus, counter)
+
+	var sent atomic.Int64
+	events.On(bus, func(ctx context.Context, event events.ReminderDue) error {
+		time.Sleep(opts.Latency)
+
+		messageID := fmt.Sprintf("stub-%d", sent.Add(1))
+		if err := counter.RecordNag(ctx, event.Reminder.ID, event.Reminder.Version, messageID); err != nil {
+			return err
+		}
+
+		return bus.Publish(ctx, events.ReminderSent{
+			Medication: event.Medication.Name,
+			Date:       event.Reminder.Date,
+			ReminderID: event.Reminder.ID,
+			MessageID:  messageID,
+			NagCount:   event.Reminder.NagCount + 1,
+		})
+	})
+
+	service := reminder.NewService(cfg, counter, bus)
+
+	latencies := make([]time.Duration, opts.Ticks)
+	for i := range opts.Ticks {
+		start := time.Now()
+		if err := service.CheckReminders(ctx); err != nil {
+			return nil, fmt.Errorf("failed to check reminders on tick %d: %w", i+1, err)
+		}
+		latencies[i] = time.Since(start)
+	}
+
+	report := &Report{
+		Medications: len(cfg.Medications),
+		Ticks:       opts.Ticks,
+		Reminders:   sent.Load(),
+		DBOps:       counter.ops.Load(),
+	}
+	for _, latency := range latencies {
+		report.Total += latency
+	}
+	report.Mean = report.Total / time.Duration(len(latencies))
+
+	slices.Sort(latencies)
+	report.P50 = percentile(latencies, 50)
+	report.P95 = percentile(latencies, 95)
+	report.Max = latencies[len(latencies)-1]
+
+	return report, nil
+}
+
+// openStore opens the store a load test runs against, removing a temporary database when it's closed
+func openStore(ctx context.Context, dbPath string) (db.StoreInterface, error) {
+	if dbPath == MemoryDB {
+		return db.NewMemoryStore(time.UTC), nil
+	}
+
+	var tempDir string
+	if dbPath == "" {
+		var err error
+		tempDir, err = os.MkdirTemp("", "meds-bot-loadtest")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		dbPath = filepath.Join(tempDir, "loadtest.db")
+	}
+
+	store, err := db.NewStore(ctx, dbPath, time.UTC)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	if tempDir == "" {
+		return store, nil
+	}
+
+	return &tempStore{StoreInterface: store, dir: tempDir}, nil
+}
+
+// percentile returns the pth percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p+99)/100 - 1
+	return sorted[max(i, 0)]
+}
@@ -0,0 +1,44 @@
+package loadtest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	for _, dbPath := range []string{"", MemoryDB} {
+		report, err := Run(context.Background(), Options{Guilds: 2, Medications: 3, Ticks: 2, Workers: 2, DBPath: dbPath, Cache: true})
+		if err != nil {
+			t.Fatalf("Load test failed: %v", err)
+		}
+		checkReport(t, report)
+	}
+}
+
+// checkReport checks a load test of 6 medications over 2 ticks
+func checkReport(t *testing.T, report *Report) {
+	t.Helper()
+
+	if report.Medications != 6 {
+		t.Errorf("Expected 6 medications, got %d", report.Medications)
+	}
+	// Every medication is reminded on the first tick and nagged on the second
+	if report.Reminders != 12 {
+		t.Errorf("Expected 12 reminders, got %d", report.Reminders)
+	}
+	if report.DBOps == 0 || report.Mean <= 0 || report.Max < report.P50 {
+		t.Errorf("Expected database operations and tick latencies to be measured, got %+v", report)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	if got := percentile(sorted, 50); got != 5 {
+		t.Errorf("Expected p50 of 5, got %v", got)
+	}
+	if got := percentile(sorted, 95); got != 10 {
+		t.Errorf("Expected p95 of 10, got %v", got)
+	}
+}
@@ -0,0 +1,63 @@
+package loadtest
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"meds-bot/internal/db"
+)
+
+// tempStore removes its temporary database directory when closed
+type tempStore struct {
+	db.StoreInterface
+	dir string
+}
+
+func (s *tempStore) Close() error {
+	err := s.StoreInterface.Close()
+	os.RemoveAll(s.dir)
+	return err
+}
+
+// countingStore counts the store operations made on the reminder hot path
+type countingStore struct {
+	db.StoreInterface
+	ops atomic.Int64
+}
+
+func (s *countingStore) GetTodayReminder(ctx context.Context, medicationType string) (*db.Reminder, error) {
+	s.ops.Add(1)
+	return s.StoreInterface.GetTodayReminder(ctx, medicationType)
+}
+
+func (s *countingStore) RecordNag(ctx context.Context, id, version int64, messageID string) error {
+	s.ops.Add(1)
+	return s.StoreInterface.RecordNag(ctx, id, version, messageID)
+}
+
+func (s *countingStore) GetRemindersForDate(ctx context.Context, date string) ([]db.Reminder, error) {
+	s.ops.Add(1)
+	return s.StoreInterface.GetRemindersForDate(ctx, date)
+}
+
+func (s *countingStore) EnsureReminders(ctx context.Context, date string, medicationTypes []string) ([]db.Reminder, error) {
+	s.ops.Add(1)
+	return s.StoreInterface.EnsureReminders(ctx, date, medicationTypes)
+}
+
+func (s *countingStore) GetState(ctx context.Context, key string) (string, error) {
+	s.ops.Add(1)
+	return s.StoreInterface.GetState(ctx, key)
+}
+
+func (s *countingStore) AppendDoseEvent(ctx context.Context, event *db.DoseEvent) error {
+	s.ops.Add(1)
+	return s.StoreInterface.AppendDoseEvent(ctx, event)
+}
+
+func (s *countingStore) GetDoseEvents(ctx context.Context, medication string, since time.Time) ([]db.DoseEvent, error) {
+	s.ops.Add(1)
+	return s.StoreInterface.GetDoseEvents(ctx, medication, since)
+}
@@ -0,0 +1,168 @@
+// Package logfile writes logs to a file that is rotated when it grows too large, keeping a limited number of backups
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is appended to the file name of backups, sorting them oldest first
+const backupTimeFormat = "20060102-150405.000"
+
+// Options controls when the log file is rotated and which backups are kept
+type Options struct {
+	// MaxSize is the size in bytes the file is rotated at
+	MaxSize int64
+	// MaxBackups is how many rotated files are kept, 0 keeps them all
+	MaxBackups int
+	// MaxAge is how long rotated files are kept, 0 keeps them regardless of age
+	MaxAge time.Duration
+}
+
+// Writer is an io.Writer appending to a log file that is rotated by size
+type Writer struct {
+	mu   sync.Mutex
+	path string
+	opts Options
+	file *os.File
+	size int64
+	now  func() time.Time
+}
+
+// New opens the log file at path for appending, creating it and its directory if needed
+func New(path string, opts Options) (*Writer, error) {
+	if opts.MaxSize <= 0 {
+		return nil, fmt.Errorf("log file max size must be positive")
+	}
+
+	w := &Writer{path: path, opts: opts, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write writes to the log file, rotating it first if the write would take it past the max size
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.size > 0 && w.size+int64(len(p)) > w.opts.MaxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the log file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// open opens the log file for appending
+func (w *Writer) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// rotate renames the log file to a timestamped backup, opens a new one and removes old backups
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+
+	backup := w.path + "." + w.now().Format(backupTimeFormat)
+	if err := os.Rename(w.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	return w.removeOldBackups()
+}
+
+// removeOldBackups removes backups beyond the max count or older than the max age
+func (w *Writer) removeOldBackups() error {
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+
+	cutoff := w.now().Add(-w.opts.MaxAge)
+	for i, backup := range backups {
+		tooMany := w.opts.MaxBackups > 0 && i < len(backups)-w.opts.MaxBackups
+		tooOld := w.opts.MaxAge > 0 && backup.time.Before(cutoff)
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(backup.path); err != nil {
+			return fmt.Errorf("failed to remove old log file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// backup is a rotated log file and when it was rotated
+type backup struct {
+	path string
+	time time.Time
+}
+
+// backups returns the rotated log files, oldest first
+func (w *Writer) backups() ([]backup, error) {
+	entries, err := os.ReadDir(filepath.Dir(w.path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log files: %w", err)
+	}
+
+	prefix := filepath.Base(w.path) + "."
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rotated, err := time.ParseInLocation(backupTimeFormat, strings.TrimPrefix(name, prefix), time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(filepath.Dir(w.path), name), time: rotated})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].time.Before(backups[j].time)
+	})
+
+	return backups, nil
+}
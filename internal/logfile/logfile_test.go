@@ -0,0 +1,81 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriterRotates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "bot.log")
+
+	w, err := New(path, Options{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer w.Close()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	w.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if string(current) != "fourth\n" {
+		t.Errorf("Expected only the latest line in the log file, got %q", current)
+	}
+
+	backups, err := w.backups()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups to be kept, got %d", len(backups))
+	}
+
+	oldest, err := os.ReadFile(backups[0].path)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if string(oldest) != "second\n" {
+		t.Errorf("Expected the oldest backup kept to be the second line, got %q", oldest)
+	}
+}
+
+func TestWriterRemovesOldBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+
+	w, err := New(path, Options{MaxSize: 1, MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer w.Close()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	w.now = func() time.Time { return now }
+
+	w.Write([]byte("a"))
+	w.Write([]byte("b"))
+	now = now.Add(2 * time.Hour)
+	w.Write([]byte("c"))
+
+	backups, err := w.backups()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 1 || !backups[0].time.Equal(now) {
+		t.Errorf("Expected only the recent backup to be kept, got %+v", backups)
+	}
+}
@@ -0,0 +1,267 @@
+// Package logging filters the standard logger's output by level, with per-component overrides.
+// The level of a message comes from its prefix (Debug, Warning or Error) and its component is the
+// package that logged it, so existing log calls don't need to change.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Path is the HTTP path log levels are served and changed on
+const Path = "/loglevel"
+
+// Level is the severity of a log message
+type Level int
+
+// Log levels, from most to least verbose
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel parses a level name: debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "warning" {
+		return LevelWarn, nil
+	}
+	for level, levelName := range levelNames {
+		if levelName == name {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+}
+
+// ParseLevels parses comma-separated component levels such as "discord=debug,db=warn"
+func ParseLevels(spec string) (map[string]Level, error) {
+	levels := make(map[string]Level)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		component, name, ok := strings.Cut(pair, "=")
+		component = strings.TrimSpace(component)
+		if !ok || component == "" {
+			return nil, fmt.Errorf("invalid log level %q, expected component=level", pair)
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return nil, err
+		}
+		levels[component] = level
+	}
+	return levels, nil
+}
+
+// FormatLevels formats component levels as ParseLevels expects them
+func FormatLevels(levels map[string]Level) string {
+	var pairs []string
+	for component, level := range levels {
+		pairs = append(pairs, component+"="+level.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// headerPattern matches the end of the standard logger's header when file names are logged
+var headerPattern = regexp.MustCompile(`\.go:\d+: `)
+
+// messageLevel returns the level of a log line from the prefix of its message
+func messageLevel(line []byte) Level {
+	message := string(line)
+	if loc := headerPattern.FindStringIndex(message); loc != nil {
+		message = message[loc[1]:]
+	}
+
+	switch {
+	case strings.HasPrefix(message, "Debug"):
+		return LevelDebug
+	case strings.HasPrefix(message, "Warning"):
+		return LevelWarn
+	case strings.HasPrefix(message, "Error"), strings.HasPrefix(message, "Failed"), strings.Contains(message, " error: "):
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
+// Filter is an io.Writer for the standard logger that drops messages below their component's level
+type Filter struct {
+	out          io.Writer
+	mu           sync.RWMutex
+	defaultLevel Level
+	levels       map[string]Level
+}
+
+// NewFilter returns a filter writing to out, using the default level for components without their own
+func NewFilter(out io.Writer, defaultLevel Level, levels map[string]Level) *Filter {
+	return &Filter{out: out, defaultLevel: defaultLevel, levels: levels}
+}
+
+// Write writes a log line if it's at or above its component's level
+func (f *Filter) Write(p []byte) (int, error) {
+	if messageLevel(p) < f.Level(callerComponent()) {
+		return len(p), nil
+	}
+	return f.out.Write(p)
+}
+
+// Level returns a component's level
+func (f *Filter) Level(component string) Level {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if level, ok := f.levels[component]; ok {
+		return level
+	}
+	return f.defaultLevel
+}
+
+// callerComponent returns the last element of the package path of the code that logged,
+// e.g. "discord" for meds-bot/internal/discord
+func callerComponent() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		pkg := packagePath(frame.Function)
+		if pkg != "log" && pkg != "io" {
+			return pkg[strings.LastIndex(pkg, "/")+1:]
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// packagePath returns the package path of a fully qualified function name
+func packagePath(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// Levels returns the default level and a copy of the component levels
+func (f *Filter) Levels() (Level, map[string]Level) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	levels := make(map[string]Level, len(f.levels))
+	for component, level := range f.levels {
+		levels[component] = level
+	}
+	return f.defaultLevel, levels
+}
+
+// SetLevel changes the default level, used for components without their own
+func (f *Filter) SetLevel(level Level) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.defaultLevel = level
+}
+
+// SetComponentLevel changes a component's level
+func (f *Filter) SetComponentLevel(component string, level Level) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.levels == nil {
+		f.levels = make(map[string]Level)
+	}
+	f.levels[component] = level
+}
+
+// SetLevels replaces the component levels
+func (f *Filter) SetLevels(levels map[string]Level) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.levels = levels
+}
+
+// levelsResponse is the JSON served by the log level endpoint
+type levelsResponse struct {
+	Level  string            `json:"level"`
+	Levels map[string]string `json:"levels"`
+}
+
+// ServeHTTP serves the current log levels as JSON. A POST changes them first, with the level
+// parameter setting the default level and levels replacing the component levels, e.g.
+// level=debug or levels=discord=debug,db=warn.
+func (f *Filter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := f.update(r.FormValue("level"), r.FormValue("levels"), r.Form.Has("levels")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	defaultLevel, levels := f.Levels()
+	response := levelsResponse{Level: defaultLevel.String(), Levels: make(map[string]string, len(levels))}
+	for component, level := range levels {
+		response.Levels[component] = level.String()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error writing log levels: %v", err)
+	}
+}
+
+// update validates and applies the levels from a request, leaving either alone if it wasn't given
+func (f *Filter) update(levelName, spec string, hasLevels bool) error {
+	var level Level
+	if levelName != "" {
+		var err error
+		if level, err = ParseLevel(levelName); err != nil {
+			return err
+		}
+	}
+	levels, err := ParseLevels(spec)
+	if err != nil {
+		return err
+	}
+
+	if levelName != "" {
+		f.SetLevel(level)
+	}
+	if hasLevels {
+		f.SetLevels(levels)
+	}
+
+	defaultLevel, levels := f.Levels()
+	log.Printf("Log level changed to %s, with component levels %q", defaultLevel, FormatLevels(levels))
+	return nil
+}
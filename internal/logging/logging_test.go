@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels("discord=debug, db=warn,reminder=INFO")
+	if err != nil {
+		t.Fatalf("Failed to parse levels: %v", err)
+	}
+
+	want := map[string]Level{"discord": LevelDebug, "db": LevelWarn, "reminder": LevelInfo}
+	if !reflect.DeepEqual(levels, want) {
+		t.Errorf("Expected %v, got %v", want, levels)
+	}
+	if got := FormatLevels(levels); got != "db=warn,discord=debug,reminder=info" {
+		t.Errorf("Expected levels to round trip, got %q", got)
+	}
+
+	for _, spec := range []string{"discord", "=debug", "discord=loud"} {
+		if _, err := ParseLevels(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestMessageLevel(t *testing.T) {
+	tests := map[string]Level{
+		"2024/01/01 12:00:00 discord.go:42: Debug: Handling interaction": LevelDebug,
+		"2024/01/01 12:00:00 discord.go:42: Sent reminder":               LevelInfo,
+		"2024/01/01 12:00:00 discord.go:42: Warning: No handler found":   LevelWarn,
+		"2024/01/01 12:00:00 discord.go:42: Error sending reminder: x":   LevelError,
+		"2024/01/01 12:00:00 main.go:42: Health server error: x":         LevelError,
+		"Failed to start application":                                    LevelError,
+	}
+
+	for line, want := range tests {
+		if got := messageLevel([]byte(line)); got != want {
+			t.Errorf("Expected %v for %q, got %v", want, line, got)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New(NewFilter(&out, LevelWarn, map[string]Level{"logging": LevelDebug}), "", log.Lshortfile)
+
+	// This package logs at debug, overriding the default of warn
+	logger.Print("Debug: shown")
+	if !bytes.Contains(out.Bytes(), []byte("Debug: shown")) {
+		t.Errorf("Expected the debug message to be written, got %q", out.String())
+	}
+
+	out.Reset()
+	filter := NewFilter(&out, LevelWarn, nil)
+	logger.SetOutput(filter)
+	logger.Print("Info hidden")
+	logger.Print("Error shown")
+	if bytes.Contains(out.Bytes(), []byte("Info hidden")) || !bytes.Contains(out.Bytes(), []byte("Error shown")) {
+		t.Errorf("Expected only the error to be written, got %q", out.String())
+	}
+}
+
+func TestPackagePath(t *testing.T) {
+	tests := map[string]string{
+		"meds-bot/internal/discord.(*Client).handleInteraction": "meds-bot/internal/discord",
+		"meds-bot/internal/db.NewStore":                         "meds-bot/internal/db",
+		"main.runBot.func1":                                     "main",
+	}
+
+	for function, want := range tests {
+		if got := packagePath(function); got != want {
+			t.Errorf("Expected %q for %q, got %q", want, function, got)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	filter := NewFilter(io.Discard, LevelInfo, map[string]Level{"db": LevelWarn})
+
+	form := url.Values{"level": {"debug"}}
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	filter.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if filter.Level("discord") != LevelDebug || filter.Level("db") != LevelWarn {
+		t.Errorf("Expected only the default level to change, got discord=%v db=%v", filter.Level("discord"), filter.Level("db"))
+	}
+
+	rec = httptest.NewRecorder()
+	filter.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, Path+"?levels=reminder%3Derror", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if filter.Level("db") != LevelDebug || filter.Level("reminder") != LevelError {
+		t.Errorf("Expected the component levels to be replaced, got db=%v reminder=%v", filter.Level("db"), filter.Level("reminder"))
+	}
+	if !strings.Contains(rec.Body.String(), `"reminder":"error"`) {
+		t.Errorf("Expected the new levels in the response, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	filter.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, Path+"?level=loud", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown level, got %d", rec.Code)
+	}
+	if filter.Level("discord") != LevelDebug {
+		t.Errorf("Expected an invalid level to leave the levels unchanged, got %v", filter.Level("discord"))
+	}
+}
@@ -0,0 +1,226 @@
+// Package metrics provides counters and histograms served in the Prometheus text format,
+// without depending on the Prometheus client.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Path is the HTTP path metrics are served on
+const Path = "/metrics"
+
+// DefaultBuckets are the histogram bucket upper bounds, in seconds, suited to API latencies
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default is the registry packages register their metrics with
+var Default = NewRegistry()
+
+// metric is a counter or histogram that can write itself in the text format
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry holds metrics and serves them over HTTP
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewCounter registers a counter with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	counter := &Counter{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	r.register(counter)
+	return counter
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds and label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	histogram := &Histogram{name: name, help: help, buckets: buckets, labels: labels, series: make(map[string]*series)}
+	r.register(histogram)
+	return histogram
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// ServeHTTP serves every registered metric in the Prometheus text format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	var b strings.Builder
+	for _, m := range metrics {
+		m.write(&b)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		log.Printf("Error writing metrics: %v", err)
+	}
+}
+
+// Counter is a value that only goes up, per combination of label values
+type Counter struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// Inc adds one to the counter for the label values, given in the order of the label names
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds to the counter for the label values
+func (c *Counter) Add(value float64, labelValues ...string) {
+	key := labelSet(c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] += value
+}
+
+// Value returns the counter for the label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := labelSet(c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, braces(key), formatFloat(c.values[key]))
+	}
+}
+
+// Histogram counts observations into buckets, per combination of label values
+type Histogram struct {
+	name, help string
+	buckets    []float64
+	labels     []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is the observations for one combination of label values
+type series struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe records a value for the label values, given in the order of the label names
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := labelSet(h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &series{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// Count returns the number of values observed for the label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := labelSet(h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, braces(join(key, `le="`+formatFloat(bound)+`"`)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, braces(join(key, `le="+Inf"`)), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, braces(key), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, braces(key), s.count)
+	}
+}
+
+// labelSet formats label names and values as they appear between braces, e.g. op="send",class="timeout"
+func labelSet(names, values []string) string {
+	if len(names) != len(values) {
+		panic(fmt.Sprintf("metrics: got %d label values for labels %v", len(values), names))
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + strconv.Quote(values[i])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// join adds a label to a label set
+func join(set, label string) string {
+	if set == "" {
+		return label
+	}
+	return set + "," + label
+}
+
+// braces wraps a non-empty label set in braces
+func braces(set string) string {
+	if set == "" {
+		return ""
+	}
+	return "{" + set + "}"
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	errors := registry.NewCounter("api_errors_total", "API errors.", "operation", "class")
+	latency := registry.NewHistogram("api_duration_seconds", "API latency.", []float64{0.1, 1}, "operation")
+
+	errors.Inc("send", "rate_limited")
+	errors.Inc("send", "rate_limited")
+	latency.Observe(0.05, "send")
+	latency.Observe(0.5, "send")
+	latency.Observe(3, "send")
+
+	if got := errors.Value("send", "rate_limited"); got != 2 {
+		t.Errorf("Expected 2 errors, got %v", got)
+	}
+	if got := latency.Count("send"); got != 3 {
+		t.Errorf("Expected 3 observations, got %d", got)
+	}
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+
+	for _, line := range []string{
+		"# TYPE api_errors_total counter",
+		`api_errors_total{operation="send",class="rate_limited"} 2`,
+		"# TYPE api_duration_seconds histogram",
+		`api_duration_seconds_bucket{operation="send",le="0.1"} 1`,
+		`api_duration_seconds_bucket{operation="send",le="1"} 2`,
+		`api_duration_seconds_bucket{operation="send",le="+Inf"} 3`,
+		`api_duration_seconds_sum{operation="send"} 3.55`,
+		`api_duration_seconds_count{operation="send"} 3`,
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("Expected %q in metrics, got:\n%s", line, rec.Body.String())
+		}
+	}
+}
+
+func TestLabelCountMismatch(t *testing.T) {
+	counter := NewRegistry().NewCounter("requests_total", "Requests.", "operation")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for missing label values")
+		}
+	}()
+	counter.Inc()
+}
@@ -0,0 +1,195 @@
+package reminder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"meds-bot/internal/events"
+)
+
+// JobsPath is the HTTP path job metrics are served on
+const JobsPath = "/jobs"
+
+// Job is a periodic background task
+type Job struct {
+	Name string
+	// Interval is the time between runs, and Jitter a random extra delay added to each interval
+	Interval time.Duration
+	Jitter   time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// JobStats are the metrics recorded for a job
+type JobStats struct {
+	Name         string        `json:"name"`
+	Runs         int           `json:"runs"`
+	Failures     int           `json:"failures"`
+	Panics       int           `json:"panics"`
+	LastRun      time.Time     `json:"last_run"`
+	LastDuration time.Duration `json:"last_duration_ns"`
+	LastError    string        `json:"last_error,omitempty"`
+}
+
+// Scheduler runs registered jobs on their own intervals, isolating panics and recording metrics
+type Scheduler struct {
+	mu    sync.Mutex
+	jobs  []Job
+	stats map[string]*JobStats
+	wg    sync.WaitGroup
+	// triggers wake each job's loop to run it before its interval is up
+	triggers map[string]chan struct{}
+}
+
+// NewScheduler creates an empty job scheduler
+func NewScheduler() *Scheduler {
+	return &Scheduler{stats: make(map[string]*JobStats), triggers: make(map[string]chan struct{})}
+}
+
+// Register adds a job to the scheduler. Jobs must be registered before the scheduler is started.
+func (s *Scheduler) Register(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs = append(s.jobs, job)
+	s.stats[job.Name] = &JobStats{Name: job.Name}
+	s.triggers[job.Name] = make(chan struct{}, 1)
+}
+
+// Trigger runs a job as soon as its current run, if any, finishes, without waiting for its interval.
+// Triggers while a run is already pending are merged into it.
+func (s *Scheduler) Trigger(name string) {
+	s.mu.Lock()
+	trigger, ok := s.triggers[name]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs each job immediately and then on its interval until the context is cancelled or stop is closed
+func (s *Scheduler) Start(ctx context.Context, stop <-chan struct{}) {
+	s.mu.Lock()
+	jobs := append([]Job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	for _, job := range jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, stop, job)
+	}
+}
+
+// Wait blocks until all job loops have stopped
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// Stats returns a snapshot of every job's metrics, ordered by name
+func (s *Scheduler) Stats() []JobStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]JobStats, 0, len(s.stats))
+	for _, stat := range s.stats {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+
+	return stats
+}
+
+// ServeHTTP serves the job metrics as JSON
+func (s *Scheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Stats()); err != nil {
+		log.Printf("Error writing job stats: %v", err)
+	}
+}
+
+// loop runs a job until the scheduler is stopped
+func (s *Scheduler) loop(ctx context.Context, stop <-chan struct{}, job Job) {
+	defer s.wg.Done()
+
+	s.mu.Lock()
+	trigger := s.triggers[job.Name]
+	s.mu.Unlock()
+
+	for {
+		s.runJob(ctx, job)
+
+		timer := time.NewTimer(nextDelay(job))
+		select {
+		case <-timer.C:
+		case <-trigger:
+			timer.Stop()
+		case <-stop:
+			timer.Stop()
+			return
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// runJob runs a job once, recovering from panics so one job can't take down the others
+func (s *Scheduler) runJob(ctx context.Context, job Job) {
+	start := time.Now()
+	var err error
+	panicked := false
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				panicked = true
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		err = job.Run(ctx)
+	}()
+
+	// Jobs whose messages can't be delivered for now are run again on their next interval
+	if errors.Is(err, events.ErrDeliveryPaused) {
+		log.Printf("Debug: Job %s skipped while delivery is paused", job.Name)
+		err = nil
+	}
+	if err != nil {
+		log.Printf("Error running job %s: %v", job.Name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stat := s.stats[job.Name]
+	stat.Runs++
+	stat.LastRun = start
+	stat.LastDuration = time.Since(start)
+	stat.LastError = ""
+	if err != nil {
+		stat.Failures++
+		stat.LastError = err.Error()
+	}
+	if panicked {
+		stat.Panics++
+	}
+}
+
+// nextDelay returns the time until a job's next run, including random jitter
+func nextDelay(job Job) time.Duration {
+	if job.Jitter <= 0 {
+		return job.Interval
+	}
+	return job.Interval + rand.N(job.Jitter)
+}
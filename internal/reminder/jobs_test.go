@@ -0,0 +1,98 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"meds-bot/internal/events"
+)
+
+// TestSchedulerRunJob tests that job failures and panics are recorded without stopping the scheduler
+func TestSchedulerRunJob(t *testing.T) {
+	scheduler := NewScheduler()
+
+	ok := Job{Name: "ok", Interval: time.Minute, Run: func(ctx context.Context) error { return nil }}
+	failing := Job{Name: "failing", Interval: time.Minute, Run: func(ctx context.Context) error { return errors.New("boom") }}
+	panicking := Job{Name: "panicking", Interval: time.Minute, Run: func(ctx context.Context) error { panic("oops") }}
+
+	for _, job := range []Job{ok, failing, panicking} {
+		scheduler.Register(job)
+		scheduler.runJob(context.Background(), job)
+	}
+
+	stats := scheduler.Stats()
+	if len(stats) != 3 {
+		t.Fatalf("Expected stats for 3 jobs, got %d", len(stats))
+	}
+
+	// Stats are ordered by name
+	failingStats, okStats, panickingStats := stats[0], stats[1], stats[2]
+
+	if okStats.Runs != 1 || okStats.Failures != 0 || okStats.LastError != "" {
+		t.Errorf("Unexpected stats for ok job: %+v", okStats)
+	}
+	if failingStats.Runs != 1 || failingStats.Failures != 1 || failingStats.LastError != "boom" {
+		t.Errorf("Unexpected stats for failing job: %+v", failingStats)
+	}
+	if panickingStats.Runs != 1 || panickingStats.Failures != 1 || panickingStats.Panics != 1 {
+		t.Errorf("Unexpected stats for panicking job: %+v", panickingStats)
+	}
+}
+
+// TestSchedulerRunJobPaused tests that jobs skipped while delivery is paused aren't counted as failures
+func TestSchedulerRunJobPaused(t *testing.T) {
+	scheduler := NewScheduler()
+	paused := Job{Name: "paused", Interval: time.Minute, Run: func(ctx context.Context) error {
+		return fmt.Errorf("failed to send weekly report: %w", events.ErrDeliveryPaused)
+	}}
+
+	scheduler.Register(paused)
+	scheduler.runJob(context.Background(), paused)
+
+	if stats := scheduler.Stats()[0]; stats.Runs != 1 || stats.Failures != 0 || stats.LastError != "" {
+		t.Errorf("Unexpected stats for paused job: %+v", stats)
+	}
+}
+
+// TestSchedulerTrigger tests that triggered jobs run without waiting for their interval
+func TestSchedulerTrigger(t *testing.T) {
+	scheduler := NewScheduler()
+	runs := make(chan struct{}, 2)
+	scheduler.Register(Job{Name: "reminders", Interval: time.Hour, Run: func(ctx context.Context) error {
+		runs <- struct{}{}
+		return nil
+	}})
+
+	stop := make(chan struct{})
+	scheduler.Start(context.Background(), stop)
+	defer func() {
+		close(stop)
+		scheduler.Wait()
+	}()
+
+	// Jobs run once when the scheduler starts
+	<-runs
+	scheduler.Trigger("reminders")
+	scheduler.Trigger("unknown")
+
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the triggered job to run")
+	}
+}
+
+// TestNextDelay tests that jitter only ever lengthens the interval
+func TestNextDelay(t *testing.T) {
+	job := Job{Interval: time.Minute, Jitter: 10 * time.Second}
+
+	for i := 0; i < 100; i++ {
+		delay := nextDelay(job)
+		if delay < time.Minute || delay >= time.Minute+10*time.Second {
+			t.Fatalf("Delay %v outside of interval plus jitter", delay)
+		}
+	}
+}
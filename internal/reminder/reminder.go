@@ -2,17 +2,23 @@ package reminder
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"meds-bot/internal/config"
 	"meds-bot/internal/db"
-	"meds-bot/internal/discord"
+	"meds-bot/internal/events"
+	"meds-bot/internal/schedule"
+	"meds-bot/internal/stats"
 )
 
+// remindersJob is the name of the job sending due reminders
+const remindersJob = "reminders"
+
 // ServiceInterface defines the interface for the reminder service
 type ServiceInterface interface {
 	Start(ctx context.Context) error
@@ -22,136 +28,904 @@ type ServiceInterface interface {
 type Service struct {
 	config   *config.Config
 	store    db.StoreInterface
-	discord  discord.ClientInterface
+	events   *events.Bus
 	stopCh   chan struct{}
 	stopOnce sync.Once
-	wg       sync.WaitGroup
+	jobs     *Scheduler
+	// tripLocation overrides the configured timezone while a trip is active
+	tripLocation atomic.Pointer[time.Location]
+	// missedOn caches the date each medication's missed dose was last published, so it's only published once
+	missedOn map[string]string
 }
 
-func NewService(cfg *config.Config, store db.StoreInterface, discord discord.ClientInterface) *Service {
-	return &Service{
-		config:  cfg,
-		store:   store,
-		discord: discord,
-		stopCh:  make(chan struct{}),
+// NewService creates a reminder service that publishes due reminders and missed doses on the bus
+func NewService(cfg *config.Config, store db.StoreInterface, bus *events.Bus) *Service {
+	s := &Service{
+		config:   cfg,
+		store:    store,
+		events:   bus,
+		stopCh:   make(chan struct{}),
+		jobs:     NewScheduler(),
+		missedOn: make(map[string]string),
+	}
+
+	interval := cfg.GetReminderInterval()
+	// Secondary checks are jittered so they don't all hit the store at the same moment
+	jitter := interval / 10
+
+	s.jobs.Register(Job{Name: remindersJob, Interval: interval, Run: s.checkAndSendReminders})
+	s.jobs.Register(Job{Name: "refill-reminders", Interval: interval, Jitter: jitter, Run: s.checkRefillReminders})
+	s.jobs.Register(Job{Name: lowSupplyJob, Interval: interval, Jitter: jitter, Run: s.checkLowSupply})
+	s.jobs.Register(Job{Name: "lab-test-reminders", Interval: interval, Jitter: jitter, Run: s.checkLabTestReminders})
+	s.jobs.Register(Job{Name: "weekly-report", Interval: interval, Jitter: jitter, Run: s.checkWeeklyReport})
+	s.jobs.Register(Job{Name: "archive", Interval: interval, Jitter: jitter, Run: s.checkArchive})
+	s.jobs.Register(Job{Name: "message-cleanup", Interval: interval, Jitter: jitter, Run: s.checkMessageCleanup})
+	s.jobs.Register(Job{Name: "proof-retention", Interval: interval, Jitter: jitter, Run: s.checkProofRetention})
+
+	if cfg.SleepInDeferHours > 0 {
+		events.On(bus, s.onUserActive)
 	}
+	events.On(bus, s.onDoseAcknowledged)
+
+	return s
+}
+
+// onUserActive records that the user is up, and checks reminders straight away so any held morning
+// reminders are sent without waiting for the next tick
+func (s *Service) onUserActive(ctx context.Context, event events.UserActive) error {
+	if err := schedule.SaveActivity(ctx, s.store, event.At); err != nil {
+		return err
+	}
+
+	s.jobs.Trigger(remindersJob)
+	return nil
+}
+
+// Jobs returns the scheduler running the service's background jobs
+func (s *Service) Jobs() *Scheduler {
+	return s.jobs
 }
 
 // Start starts the reminder service
 func (s *Service) Start(ctx context.Context) error {
-	s.discord.RegisterMedicationHandler(ctx)
+	if err := s.migrateDoseTimes(ctx); err != nil {
+		log.Printf("Error moving reminders to dose times: %v", err)
+	}
 
-	s.wg.Add(1)
-	go s.reminderLoop(ctx)
+	s.jobs.Start(ctx, s.stopCh)
 
 	log.Println("Reminder service started")
 	return nil
 }
 
+// migrateDoseTimes moves the reminders of a medication that has been given dose times, which were
+// recorded under its name, to its earliest dose. Today's dose taken before the change is then still
+// taken, and its history carries on in the earliest dose's statistics.
+func (s *Service) migrateDoseTimes(ctx context.Context) error {
+	earliest := make(map[string]config.Medication)
+	for _, medication := range s.config.Medications {
+		if medication.BaseName == "" {
+			continue
+		}
+		if first, ok := earliest[medication.BaseName]; !ok || medication.Hour*60+medication.Minute < first.Hour*60+first.Minute {
+			earliest[medication.BaseName] = medication
+		}
+	}
+
+	for base, dose := range earliest {
+		renamed, err := s.store.RenameReminders(ctx, base, dose.Name)
+		if err != nil {
+			return fmt.Errorf("failed to move reminders of %s to %s: %w", base, dose.Name, err)
+		}
+		if renamed > 0 {
+			log.Printf("Moved %d reminders of %s to %s, now that it has dose times", renamed, base, dose.Name)
+		}
+	}
+	return nil
+}
+
 // Stop stops the reminder service
 func (s *Service) Stop() {
 	s.stopOnce.Do(func() {
 		close(s.stopCh)
-		s.wg.Wait()
+		s.jobs.Wait()
 		log.Println("Reminder service stopped")
 	})
 }
 
-// reminderLoop is the main reminder loop
-func (s *Service) reminderLoop(ctx context.Context) {
-	defer s.wg.Done()
+// CheckReminders runs a single reminder check, as the reminders job does on every tick
+func (s *Service) CheckReminders(ctx context.Context) error {
+	return s.checkAndSendReminders(ctx)
+}
 
-	ticker := time.NewTicker(s.config.GetReminderInterval())
-	defer ticker.Stop()
+// checkAndSendReminders checks if reminders need to be sent and sends them
+func (s *Service) checkAndSendReminders(ctx context.Context) error {
+	log.Printf("Debug: Checking reminders at %s", s.now().Format(time.RFC3339))
+
+	if err := s.refreshTrip(ctx); err != nil {
+		log.Printf("Error checking trip: %v", err)
+	}
 
-	// Check immediately on startup
-	if err := s.checkAndSendReminders(ctx); err != nil {
-		log.Printf("Error checking and sending reminders: %v", err)
+	if s.config.ReminderMode == config.ReminderModeChecklist {
+		return s.checkAndSendChecklist(ctx)
 	}
 
-	for {
-		select {
-		case <-ticker.C:
-			if err := s.checkAndSendReminders(ctx); err != nil {
-				log.Printf("Error checking and sending reminders: %v", err)
+	medications := s.scheduledMedications(ctx)
+
+	if err := s.checkHeadsUps(ctx, medications); err != nil {
+		log.Printf("Error checking heads-ups: %v", err)
+	}
+
+	if err := s.checkMissedDoses(ctx, medications, s.now()); err != nil {
+		log.Printf("Error checking missed doses: %v", err)
+	}
+
+	var due []config.Medication
+	for _, medication := range medications {
+		if s.shouldSendReminder(medication) {
+			due = append(due, medication)
+		}
+	}
+
+	reminders, err := s.todayReminders(ctx, due, s.now())
+	if err != nil {
+		return err
+	}
+
+	var active time.Time
+	if s.config.SleepInDeferHours > 0 {
+		if active, err = schedule.LoadActivity(ctx, s.store); err != nil {
+			log.Printf("Error loading last activity: %v", err)
+		}
+	}
+
+	return s.forEachMedication(due, func(medication config.Medication) error {
+		reminder := reminders[medication.Name]
+		if reminder.Resolved() {
+			return nil
+		}
+
+		if sleepingIn(medication, active, s.now(), s.config.SleepInDeferHours) {
+			log.Printf("Debug: Holding reminder for %s until the user is active [dose %s]", medication.Name, reminder.CorrelationID)
+			return nil
+		}
+
+		policy := medication.Policy()
+
+		if !s.nagDue(reminder, policy, time.Now()) {
+			return nil
+		}
+
+		if !policy.OverrideQuietHours && s.config.InQuietHours(s.now()) {
+			return nil
+		}
+
+		log.Printf("Debug: Reminder due for %s after %d reminders [dose %s]", medication.Name, reminder.NagCount, reminder.CorrelationID)
+		err := s.events.Publish(ctx, events.ReminderDue{
+			Medication: medication,
+			Reminder:   reminder,
+			DueAt:      medication.DueAt(medication.In(s.now())),
+			Escalate:   policy.Escalate && s.config.EscalateAfterNags > 0 && reminder.NagCount >= s.config.EscalateAfterNags,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send reminder for %s: %w", medication.Name, err)
+		}
+
+		return nil
+	})
+}
+
+// forEachMedication runs fn for each medication on a bounded pool of workers, so one slow
+// Discord call doesn't hold up every other reminder. A failing medication doesn't stop the
+// others, and the errors of all of them are returned together.
+func (s *Service) forEachMedication(medications []config.Medication, fn func(config.Medication) error) error {
+	workers := min(max(s.config.ReminderWorkers, 1), len(medications))
+
+	jobs := make(chan config.Medication)
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for medication := range jobs {
+				// Paused reminders are sent on a later tick
+				if err := runIsolated(medication, fn); err != nil && !errors.Is(err, events.ErrDeliveryPaused) {
+					log.Printf("Error processing %s: %v", medication.Name, err)
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
 			}
-		case <-s.stopCh:
-			log.Println("Reminder loop stopped")
-			return
-		case <-ctx.Done():
-			log.Println("Context cancelled, stopping reminder loop")
-			return
+		}()
+	}
+
+	for _, medication := range medications {
+		jobs <- medication
+	}
+	close(jobs)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// runIsolated runs fn for a medication, converting a panic into an error
+func runIsolated(medication config.Medication, fn func(config.Medication) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic processing %s: %v", medication.Name, r)
+		}
+	}()
+
+	return fn(medication)
+}
+
+// checkHeadsUps sends a heads-up for each medication coming up within its lead time.
+// The heads-up is replaced by the reminder once the medication is due.
+func (s *Service) checkHeadsUps(ctx context.Context, medications []config.Medication) error {
+	now := s.now()
+
+	var due []config.Medication
+	for _, medication := range medications {
+		if headsUpDue(medication, medication.In(now)) {
+			due = append(due, medication)
 		}
 	}
+
+	reminders, err := s.todayReminders(ctx, due, now)
+	if err != nil {
+		return err
+	}
+
+	return s.forEachMedication(due, func(medication config.Medication) error {
+		reminder := reminders[medication.Name]
+		if reminder.HeadsUpSent || reminder.Resolved() {
+			return nil
+		}
+
+		if !medication.Policy().OverrideQuietHours && s.config.InQuietHours(now) {
+			return nil
+		}
+
+		err := s.events.Publish(ctx, events.HeadsUpDue{Medication: medication, Reminder: reminder, DueAt: medication.DueAt(medication.In(now))})
+		if err != nil {
+			return fmt.Errorf("failed to send heads-up for %s: %w", medication.Name, err)
+		}
+
+		return nil
+	})
 }
 
-// checkAndSendReminders checks if reminders need to be sent and sends them
-func (s *Service) checkAndSendReminders(ctx context.Context) error {
-	for _, medication := range s.config.Medications {
-		if !s.shouldSendReminder(medication) {
+// todayReminders gets or creates today's reminders for the medications in one batch per date, keyed by
+// medication name
+func (s *Service) todayReminders(ctx context.Context, medications []config.Medication, now time.Time) (map[string]*db.Reminder, error) {
+	if len(medications) == 0 {
+		return nil, nil
+	}
+
+	// Each dose is stored by its date where it's taken, which differs for medications in other timezones
+	var dates []string
+	names := make(map[string][]string)
+	for _, medication := range medications {
+		date := s.doseDate(medication, now)
+		if names[date] == nil {
+			dates = append(dates, date)
+		}
+		names[date] = append(names[date], medication.Name)
+	}
+
+	byName := make(map[string]*db.Reminder, len(medications))
+	for _, date := range dates {
+		reminders, err := s.store.EnsureReminders(ctx, date, names[date])
+		if err != nil {
+			return nil, fmt.Errorf("failed to get today's reminders: %w", err)
+		}
+
+		for i := range reminders {
+			byName[reminders[i].MedicationType] = &reminders[i]
+		}
+	}
+
+	for _, medication := range medications {
+		if byName[medication.Name] == nil {
+			return nil, fmt.Errorf("failed to get reminder for %s: not created", medication.Name)
+		}
+	}
+
+	return byName, nil
+}
+
+// missedDoseStateKeyPrefix prefixes the state keys recording the date each medication's missed dose was last
+// published, so a restart doesn't publish it again
+const missedDoseStateKeyPrefix = "missed_dose_published:"
+
+// checkMissedDoses publishes a missed dose once for each medication whose reminder window
+// has closed without the dose being taken
+func (s *Service) checkMissedDoses(ctx context.Context, medications []config.Medication, now time.Time) error {
+	// Reminders are looked up by the date of each dose, which differs for medications in other timezones
+	// and for doses whose window closes after midnight
+	closedOn := make(map[string]string)
+	var closed []config.Medication
+	for _, medication := range medications {
+		date, ok := closedDoseDate(medication, now)
+		if !ok {
+			continue
+		}
+		published, err := s.missedDate(ctx, medication.Name)
+		if err != nil {
+			return err
+		}
+		if published != date {
+			closedOn[medication.Name] = date
+			closed = append(closed, medication)
+		}
+	}
+	if len(closed) == 0 {
+		return nil
+	}
+
+	type doseKey struct{ date, medication string }
+	doseReminders := make(map[doseKey]db.Reminder)
+	fetched := make(map[string]bool)
+	for _, medication := range closed {
+		date := closedOn[medication.Name]
+		if fetched[date] {
 			continue
 		}
+		fetched[date] = true
 
-		reminder, err := s.store.GetTodayReminder(ctx, medication.Name)
+		reminders, err := s.store.GetRemindersForDate(ctx, date)
 		if err != nil {
-			return fmt.Errorf("failed to get reminder for %s: %w", medication.Name, err)
+			return fmt.Errorf("failed to get reminders for %s: %w", date, err)
+		}
+		for _, reminder := range reminders {
+			doseReminders[doseKey{date, reminder.MedicationType}] = reminder
+		}
+	}
+
+	for _, medication := range closed {
+		date := closedOn[medication.Name]
+		if err := s.store.SetState(ctx, missedDoseStateKeyPrefix+medication.Name, date); err != nil {
+			return fmt.Errorf("failed to save missed dose of %s: %w", medication.Name, err)
 		}
+		s.missedOn[medication.Name] = date
 
-		if reminder.Acknowledged {
+		// Doses that were skipped or never reminded about, such as while the bot was offline, aren't reported
+		reminder, ok := doseReminders[doseKey{date, medication.Name}]
+		if !ok || reminder.Resolved() {
 			continue
 		}
 
-		// Delete existing message
-		if reminder.MessageID != "" {
-			if err := s.discord.DeleteMessage(ctx, reminder.MessageID); err != nil {
-				log.Printf("Error deleting previous message for %s: %v", medication.Name, err)
+		err := s.events.Publish(ctx, events.DoseMissed{
+			Medication:    medication.Name,
+			Date:          date,
+			ReminderID:    reminder.ID,
+			NagCount:      reminder.NagCount,
+			CorrelationID: reminder.CorrelationID,
+		})
+		if err != nil {
+			log.Printf("Error publishing missed dose of %s [dose %s]: %v", medication.Name, reminder.CorrelationID, err)
+		}
+	}
+
+	return nil
+}
+
+// missedDate returns the date a medication's missed dose was last published, reading it from the store
+// the first time
+func (s *Service) missedDate(ctx context.Context, medicationName string) (string, error) {
+	if date, ok := s.missedOn[medicationName]; ok {
+		return date, nil
+	}
+
+	date, err := s.store.GetState(ctx, missedDoseStateKeyPrefix+medicationName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get missed dose of %s: %w", medicationName, err)
+	}
+	s.missedOn[medicationName] = date
+	return date, nil
+}
+
+// scheduledMedications returns the configured medications with any gradual time shifts applied for today
+func (s *Service) scheduledMedications(ctx context.Context) []config.Medication {
+	now := s.now()
+	medications := make([]config.Medication, len(s.config.Medications))
+
+	wake, err := schedule.LoadWake(ctx, s.store)
+	if err != nil {
+		log.Printf("Error loading wake check-in: %v", err)
+	}
+
+	for i, medication := range s.config.Medications {
+		shift, err := schedule.LoadShift(ctx, s.store, medication.Name)
+		if err != nil {
+			log.Printf("Error loading schedule shift for %s: %v", medication.Name, err)
+		}
+		if shift != nil {
+			minutes := shift.TimeOn(now)
+			medication.Hour, medication.Minute = minutes/60%24, minutes%60
+		}
+		if anchored, ok := s.anchoredTime(medication, now, wake); ok {
+			medication.Hour, medication.Minute = anchored.Hour(), anchored.Minute()
+		}
+		medications[i] = medication
+	}
+
+	return medications
+}
+
+// anchoredTime returns today's time for a medication anchored to sunrise, sunset or the latest wake check-in
+func (s *Service) anchoredTime(medication config.Medication, now, wake time.Time) (time.Time, bool) {
+	var event time.Time
+	var ok bool
+
+	switch medication.Anchor {
+	case config.AnchorSunrise:
+		event, ok = schedule.Sunrise(now, s.config.Latitude, s.config.Longitude)
+	case config.AnchorSunset:
+		event, ok = schedule.Sunset(now, s.config.Latitude, s.config.Longitude)
+	case config.AnchorWake:
+		// The check-in is used until the next one, including for doses it puts after midnight
+		return schedule.WakeDoseOn(wake, time.Duration(medication.AnchorOffsetMins)*time.Minute, now)
+	}
+	if !ok {
+		return time.Time{}, false
+	}
+
+	event = event.Add(time.Duration(medication.AnchorOffsetMins) * time.Minute)
+	if event.YearDay() != now.YearDay() {
+		// The offset moved the dose onto another day, so keep the configured time
+		return time.Time{}, false
+	}
+
+	return event, true
+}
+
+// sleepingIn checks if a morning reminder should be held because the user hasn't been seen active
+// today, until it has been held for deferHours
+func sleepingIn(medication config.Medication, active, now time.Time, deferHours int) bool {
+	if deferHours <= 0 || medication.Hour >= config.SleepInMorningEndHour {
+		return false
+	}
+
+	if _, ok := schedule.WakeOn(active, now); ok {
+		return false
+	}
+	return now.Before(medication.DueAt(now).Add(time.Duration(deferHours) * time.Hour))
+}
+
+// headsUpDue checks if the current time is within a medication's heads-up lead time
+func headsUpDue(medication config.Medication, now time.Time) bool {
+	if medication.LeadTimeMins <= 0 || !medication.IsScheduledOn(now) {
+		return false
+	}
+
+	dueAt := medication.DueAt(now)
+	return !now.Before(dueAt.Add(-time.Duration(medication.LeadTimeMins)*time.Minute)) && now.Before(dueAt)
+}
+
+// checkAndSendChecklist posts today's checklist once the checklist hour has been reached
+func (s *Service) checkAndSendChecklist(ctx context.Context) error {
+	if s.now().Hour() < s.config.ChecklistHour {
+		return nil
+	}
+
+	messageID, err := s.store.GetTodayChecklist(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get today's checklist: %w", err)
+	}
+
+	// The checklist is posted once and then edited as doses are taken
+	if messageID != "" {
+		return nil
+	}
+
+	return s.events.Publish(ctx, events.ChecklistDue{Date: s.now().Format("2006-01-02")})
+}
+
+// checkRefillReminders sends a daily reminder for each medication approaching its refill due date
+func (s *Service) checkRefillReminders(ctx context.Context) error {
+	now := s.now()
+	if now.Hour() < s.config.RefillReminderHour {
+		return nil
+	}
+
+	for _, medication := range s.config.Medications {
+		// The doses of a medication taken more than once a day share its refills, so once one has sent
+		// the day's reminder the others find it already sent
+		info, err := s.store.GetMedicationInfo(ctx, medication.Base())
+		if err != nil {
+			return fmt.Errorf("failed to get medication info for %s: %w", medication.Base(), err)
+		}
+
+		if !refillReminderDue(info, now, s.config.RefillReminderDays) {
+			continue
+		}
+
+		if err := s.events.Publish(ctx, events.RefillDue{Info: info}); err != nil {
+			return fmt.Errorf("failed to send refill reminder for %s: %w", medication.Base(), err)
+		}
+
+		// Reminders repeat daily until the medication is marked as refilled
+		if err := s.store.RecordRefillReminder(ctx, medication.Base(), now.Format("2006-01-02")); err != nil {
+			return fmt.Errorf("failed to save refill reminder for %s: %w", medication.Base(), err)
+		}
+	}
+
+	return nil
+}
+
+// lowSupplyJob is the name of the job warning about medications running low
+const lowSupplyJob = "low-supply"
+
+// lowSupplyStateKeyPrefix prefixes the state keys recording that a medication's low supply was warned about
+const lowSupplyStateKeyPrefix = "low_supply_warned:"
+
+// onDoseAcknowledged takes a taken dose from its medication's stock, checking straight away whether it's
+// running low
+func (s *Service) onDoseAcknowledged(ctx context.Context, event events.DoseAcknowledged) error {
+	// The doses of a medication taken more than once a day share its stock
+	name := event.Medication
+	for _, medication := range s.config.Medications {
+		if medication.Name == event.Medication {
+			name = medication.Base()
+			break
+		}
+	}
+
+	remaining, err := s.store.AdjustPills(ctx, name, -1)
+	if err != nil {
+		return fmt.Errorf("failed to update stock of %s [dose %s]: %w", name, event.CorrelationID, err)
+	}
+	if remaining != db.UntrackedPills {
+		log.Printf("Debug: %d doses of %s left [dose %s]", remaining, name, event.CorrelationID)
+		s.jobs.Trigger(lowSupplyJob)
+	}
+	return nil
+}
+
+// checkLowSupply warns once about each medication whose tracked stock is at or below the low supply
+// threshold, until it's refilled above it
+func (s *Service) checkLowSupply(ctx context.Context) error {
+	if s.config.LowSupplyDoses == 0 {
+		return nil
+	}
+
+	forecasts, err := stats.StockForecasts(ctx, s.store, s.config.Medications, s.now())
+	if err != nil {
+		return err
+	}
+
+	for _, forecast := range forecasts {
+		key := lowSupplyStateKeyPrefix + forecast.Medication
+		warned, err := s.store.GetState(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to get low supply warning of %s: %w", forecast.Medication, err)
+		}
+
+		low := forecast.PillsRemaining <= s.config.LowSupplyDoses
+		switch {
+		case low && warned == "":
+			if err := s.events.Publish(ctx, events.LowSupply{Forecast: forecast}); err != nil {
+				return fmt.Errorf("failed to send low supply warning for %s: %w", forecast.Medication, err)
+			}
+			if err := s.store.SetState(ctx, key, s.now().Format("2006-01-02")); err != nil {
+				return fmt.Errorf("failed to save low supply warning of %s: %w", forecast.Medication, err)
+			}
+		case !low && warned != "":
+			// Refilled, so the next time it runs low is warned about again
+			if err := s.store.SetState(ctx, key, ""); err != nil {
+				return fmt.Errorf("failed to clear low supply warning of %s: %w", forecast.Medication, err)
 			}
 		}
+	}
+
+	return nil
+}
+
+// checkLabTestReminders sends a daily reminder for each lab test that is due until its result is recorded
+func (s *Service) checkLabTestReminders(ctx context.Context) error {
+	now := s.now()
+	if now.Hour() < s.config.LabReminderHour {
+		return nil
+	}
+
+	tests, err := s.store.ListLabTests(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list lab tests: %w", err)
+	}
+
+	today := now.Format("2006-01-02")
+	for _, test := range tests {
+		if test.NextDue > today || test.RemindedOn == today {
+			continue
+		}
+
+		if err := s.events.Publish(ctx, events.LabTestDue{Test: &test}); err != nil {
+			return fmt.Errorf("failed to send lab test reminder for %s: %w", test.Name, err)
+		}
+
+		test.RemindedOn = today
+		if err := s.store.SaveLabTest(ctx, &test); err != nil {
+			return fmt.Errorf("failed to save lab test reminder for %s: %w", test.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// weeklyReportStateKey records the date the last weekly report was sent
+const weeklyReportStateKey = "weekly_report_last_sent"
+
+// checkWeeklyReport sends the weekly report once on the configured day and hour
+func (s *Service) checkWeeklyReport(ctx context.Context) error {
+	if s.config.WeeklyReportDay == "" {
+		return nil
+	}
+
+	now := s.now()
+	day, _ := config.ParseWeekday(s.config.WeeklyReportDay)
+	if now.Weekday() != day || now.Hour() < s.config.WeeklyReportHour {
+		return nil
+	}
+
+	today := now.Format("2006-01-02")
+	lastSent, err := s.store.GetState(ctx, weeklyReportStateKey)
+	if err != nil {
+		return fmt.Errorf("failed to get last weekly report date: %w", err)
+	}
+	if lastSent == today {
+		return nil
+	}
+
+	report, err := stats.BuildWeeklyReport(ctx, s.store, s.config.Medications, now, s.config.StockWarningDays)
+	if err != nil {
+		return fmt.Errorf("failed to build weekly report: %w", err)
+	}
+
+	if err := s.events.Publish(ctx, events.WeeklyReportDue{Report: report}); err != nil {
+		return fmt.Errorf("failed to send weekly report: %w", err)
+	}
+
+	return s.store.SetState(ctx, weeklyReportStateKey, today)
+}
+
+// archiveStateKey records the last date whose reminders were archived
+const archiveStateKey = "archive_last_date"
+
+// archiveCatchUpDays is how many past days are archived at most, such as after the bot was offline
+const archiveCatchUpDays = 7
 
-		newMessageID, err := s.discord.SendReminder(ctx, medication)
+// checkArchive archives the reminders of each day that has passed since the last archived one
+func (s *Service) checkArchive(ctx context.Context) error {
+	if s.config.ArchiveChannelID == "" {
+		return nil
+	}
+
+	lastArchived, err := s.store.GetState(ctx, archiveStateKey)
+	if err != nil {
+		return fmt.Errorf("failed to get last archived date: %w", err)
+	}
+
+	for _, date := range archiveDates(lastArchived, s.now()) {
+		reminders, err := s.store.GetRemindersForDate(ctx, date)
 		if err != nil {
-			return fmt.Errorf("failed to send reminder for %s: %w", medication.Name, err)
+			return fmt.Errorf("failed to get reminders for %s: %w", date, err)
 		}
 
-		// Update the reminder with the new message ID
-		if err := s.store.UpdateReminderStatus(ctx, reminder.ID, false, newMessageID); err != nil {
-			return fmt.Errorf("failed to update reminder status for %s: %w", medication.Name, err)
+		if len(reminders) > 0 {
+			if err := s.events.Publish(ctx, events.ArchiveDue{Date: date, Reminders: reminders}); err != nil {
+				return fmt.Errorf("failed to archive reminders for %s: %w", date, err)
+			}
+		}
+
+		if err := s.store.SetState(ctx, archiveStateKey, date); err != nil {
+			return fmt.Errorf("failed to save last archived date: %w", err)
 		}
 	}
 
 	return nil
 }
 
-// shouldSendReminder checks if it's time to send a reminder for a specific medication
-func (s *Service) shouldSendReminder(medication config.Medication) bool {
-	// Get the location from the config
+// archiveDates returns the past days still to be archived, oldest first. Only yesterday is archived
+// when nothing has been before, so enabling the archive doesn't clear out older messages.
+func archiveDates(lastArchived string, now time.Time) []string {
+	var dates []string
+	for days := archiveCatchUpDays; days >= 1; days-- {
+		date := now.AddDate(0, 0, -days).Format("2006-01-02")
+		if lastArchived == "" && days > 1 || date <= lastArchived {
+			continue
+		}
+		dates = append(dates, date)
+	}
+	return dates
+}
+
+// messageCleanupStateKey records the date old messages were last cleaned up
+const messageCleanupStateKey = "message_cleanup_last_run"
+
+// checkMessageCleanup cleans up messages older than the retention period once a day
+func (s *Service) checkMessageCleanup(ctx context.Context) error {
+	if s.config.MessageRetentionDays == 0 {
+		return nil
+	}
+
+	now := s.now()
+	today := now.Format("2006-01-02")
+	lastRun, err := s.store.GetState(ctx, messageCleanupStateKey)
+	if err != nil {
+		return fmt.Errorf("failed to get last message cleanup date: %w", err)
+	}
+	if lastRun == today {
+		return nil
+	}
+
+	if err := s.events.Publish(ctx, events.CleanupDue{Before: now.AddDate(0, 0, -s.config.MessageRetentionDays)}); err != nil {
+		return fmt.Errorf("failed to clean up old messages: %w", err)
+	}
+
+	return s.store.SetState(ctx, messageCleanupStateKey, today)
+}
+
+// proofRetentionStateKey records the date old dose photos were last deleted
+const proofRetentionStateKey = "proof_retention_last_run"
+
+// checkProofRetention deletes the photos of doses older than the retention period once a day
+func (s *Service) checkProofRetention(ctx context.Context) error {
+	if s.config.ProofRetentionDays == 0 {
+		return nil
+	}
+
+	now := s.now()
+	today := now.Format("2006-01-02")
+	lastRun, err := s.store.GetState(ctx, proofRetentionStateKey)
+	if err != nil {
+		return fmt.Errorf("failed to get last proof retention date: %w", err)
+	}
+	if lastRun == today {
+		return nil
+	}
+
+	before := now.AddDate(0, 0, -s.config.ProofRetentionDays).Format("2006-01-02")
+	deleted, err := s.store.DeleteDoseProofs(ctx, before)
+	if err != nil {
+		return fmt.Errorf("failed to delete old dose photos: %w", err)
+	}
+	if deleted > 0 {
+		log.Printf("Deleted the photos of %d doses from before %s", deleted, before)
+	}
+
+	return s.store.SetState(ctx, proofRetentionStateKey, today)
+}
+
+// refillReminderDue checks if a refill reminder should be sent today, starting the given number
+// of days before the refill due date
+func refillReminderDue(info *db.MedicationInfo, now time.Time, daysBefore int) bool {
+	if info.RefillDue == "" || info.RefillRemindedOn == now.Format("2006-01-02") {
+		return false
+	}
+
+	due, err := time.ParseInLocation("2006-01-02", info.RefillDue, now.Location())
+	if err != nil {
+		log.Printf("Invalid refill due date for %s: %s", info.Name, info.RefillDue)
+		return false
+	}
+
+	return !now.Before(due.AddDate(0, 0, -daysBefore))
+}
+
+// now returns the current time in the configured timezone, or the trip timezone while travelling
+func (s *Service) now() time.Time {
+	if loc := s.tripLocation.Load(); loc != nil {
+		return time.Now().In(loc)
+	}
+
+	return time.Now().In(s.homeLocation())
+}
+
+// DoseLocation returns the timezone a medication's doses are dated in, which is its own timezone if it
+// has one, or the trip's timezone while travelling, or nil for the configured timezone. It's the store
+// calendar's locator.
+func (s *Service) DoseLocation(medicationType string) *time.Location {
+	for _, medication := range s.config.Medications {
+		if medication.Name == medicationType {
+			return medication.In(s.now()).Location()
+		}
+	}
+
+	return s.tripLocation.Load()
+}
+
+// doseDate returns the date a medication's dose due now is recorded under, matching DoseLocation
+func (s *Service) doseDate(medication config.Medication, now time.Time) string {
+	return medication.In(now).Format("2006-01-02")
+}
+
+// homeLocation returns the configured timezone
+func (s *Service) homeLocation() *time.Location {
 	loc, err := s.config.GetLocation()
 	if err != nil {
 		log.Printf("Error getting timezone location: %v, using UTC", err)
 		loc = time.UTC
 	}
 
-	// Get the current time in the configured timezone
-	now := time.Now().In(loc)
-	currentHour := now.Hour()
+	return loc
+}
 
-	// Default to daily if frequency is not specified
-	if medication.Frequency == "" {
-		medication.Frequency = "daily"
+// refreshTrip switches schedules to the trip timezone while a trip is active, and clears the trip once it's over
+func (s *Service) refreshTrip(ctx context.Context) error {
+	trip, err := schedule.LoadTrip(ctx, s.store)
+	if err != nil {
+		return err
 	}
 
-	// For weekly medications, check if today is the specified day
-	if medication.Frequency == "weekly" {
-		// Get the current day of the week
-		currentDay := strings.ToLower(now.Weekday().String())
+	s.tripLocation.Store(nil)
+	if trip == nil {
+		return nil
+	}
 
-		// If the day doesn't match, don't send a reminder
-		if strings.ToLower(medication.Day) != currentDay {
-			return false
+	now := time.Now()
+	if trip.Over(now) {
+		log.Printf("Trip to %s has ended, reverting to %s", trip.Timezone, s.config.Timezone)
+		return schedule.ClearTrip(ctx, s.store)
+	}
+
+	if trip.Active(now) {
+		s.tripLocation.Store(trip.Location(now, s.homeLocation()))
+	}
+
+	return nil
+}
+
+// nagDue checks whether enough reminder intervals have passed since the last reminder
+// was sent, based on the medication's priority
+func (s *Service) nagDue(reminder *db.Reminder, policy config.PriorityPolicy, now time.Time) bool {
+	if reminder.LastReminderTime.IsZero() || policy.NagEvery <= 1 {
+		return true
+	}
+
+	interval := s.config.GetReminderInterval()
+	// Allow some slack so ticker drift doesn't push a nag back by a whole interval
+	wait := time.Duration(policy.NagEvery)*interval - interval/2
+
+	return now.Sub(reminder.LastReminderTime) >= wait
+}
+
+// shouldSendReminder checks if it's time to send a reminder for a specific medication, in its own
+// timezone if it has one
+func (s *Service) shouldSendReminder(medication config.Medication) bool {
+	return reminderWindowOpen(medication, medication.In(s.now()))
+}
+
+// reminderWindowOpen checks if now is within a medication's reminder window today, which opens at the
+// minute it's due and stays open for config.ReminderWindowHours
+func reminderWindowOpen(medication config.Medication, now time.Time) bool {
+	// Weekly and cron scheduled medications are only due on some days
+	if !medication.IsScheduledOn(now) {
+		return false
+	}
+
+	dueAt := medication.DueAt(now)
+	return !now.Before(dueAt) && now.Before(dueAt.Add(config.ReminderWindowHours*time.Hour))
+}
+
+// closedDoseDate returns the date of a medication's dose whose reminder window closed earlier today, in its
+// own timezone. The window is counted from the day the dose was due, so a dose due late in the evening
+// closes after midnight and it's the previous day's dose that's returned.
+func closedDoseDate(medication config.Medication, now time.Time) (string, bool) {
+	now = medication.In(now)
+	today := now.Format("2006-01-02")
+	for _, day := range []time.Time{now, now.AddDate(0, 0, -1)} {
+		if !medication.IsScheduledOn(day) {
+			continue
+		}
+		closesAt := medication.DueAt(day).Add(config.ReminderWindowHours * time.Hour)
+		if closesAt.Format("2006-01-02") == today && !now.Before(closesAt) {
+			return day.Format("2006-01-02"), true
 		}
 	}
 
-	// Check if it's time for this medication
-	// Only send reminders if the current hour is within 5 hours of the medication hour and not before the medication hour
-	return currentHour >= medication.Hour && currentHour < medication.Hour+5
+	return "", false
 }
@@ -1,11 +1,18 @@
 package reminder
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/events"
 )
 
 // TestShouldSendReminder tests the shouldSendReminder function
@@ -137,3 +144,662 @@ func TestShouldSendReminder(t *testing.T) {
 		})
 	}
 }
+
+// TestReminderWindowOpen tests that the reminder window opens at the minute a medication is due
+func TestReminderWindowOpen(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	medication := config.Medication{Name: "Med1", Hour: 7, Minute: 30, Frequency: "daily"}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected bool
+		closed   bool
+	}{
+		{name: "On the hour before it's due", now: day.Add(7 * time.Hour), expected: false, closed: false},
+		{name: "At the minute it's due", now: day.Add(7*time.Hour + 30*time.Minute), expected: true, closed: false},
+		{name: "Within the window", now: day.Add(12 * time.Hour), expected: true, closed: false},
+		{name: "After the window", now: day.Add(12*time.Hour + 30*time.Minute), expected: false, closed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := reminderWindowOpen(medication, tt.now); result != tt.expected {
+				t.Errorf("reminderWindowOpen() = %v, want %v", result, tt.expected)
+			}
+			if _, closed := closedDoseDate(medication, tt.now); closed != tt.closed {
+				t.Errorf("closedDoseDate() = %v, want %v", closed, tt.closed)
+			}
+		})
+	}
+}
+
+// TestClosedDoseDateAfterMidnight tests that a dose due late in the evening closes after midnight, as the
+// previous day's dose
+func TestClosedDoseDateAfterMidnight(t *testing.T) {
+	medication := config.Medication{Name: "Night Pill", Hour: 22, Frequency: "daily"}
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		date   string
+		closed bool
+	}{
+		{name: "Before midnight, the previous day's dose", now: time.Date(2024, 5, 1, 23, 30, 0, 0, time.UTC), date: "2024-04-30", closed: true},
+		{name: "After midnight, within the window", now: time.Date(2024, 5, 2, 2, 30, 0, 0, time.UTC), closed: false},
+		{name: "After the window", now: time.Date(2024, 5, 2, 3, 0, 0, 0, time.UTC), date: "2024-05-01", closed: true},
+		{name: "Later the next day", now: time.Date(2024, 5, 2, 21, 0, 0, 0, time.UTC), date: "2024-05-01", closed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, closed := closedDoseDate(medication, tt.now)
+			if closed != tt.closed || date != tt.date {
+				t.Errorf("closedDoseDate() = %q, %v, want %q, %v", date, closed, tt.date, tt.closed)
+			}
+		})
+	}
+}
+
+// TestMissedDoseAfterMidnight tests that a late evening dose is published as missed once its window closes
+// the next morning, and only once across restarts
+func TestMissedDoseAfterMidnight(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore(time.UTC)
+	medication := config.Medication{Name: "Night Pill", Hour: 22, Frequency: "daily"}
+	cfg := &config.Config{Timezone: "UTC", Medications: []config.Medication{medication}}
+
+	var missed []string
+	bus := events.NewBus()
+	events.On(bus, func(ctx context.Context, event events.DoseMissed) error {
+		missed = append(missed, event.Medication+":"+event.Date)
+		return nil
+	})
+
+	reminders, err := store.EnsureReminders(ctx, "2024-05-01", []string{medication.Name})
+	if err != nil {
+		t.Fatalf("Failed to create reminder: %v", err)
+	}
+	if err := store.RecordNag(ctx, reminders[0].ID, reminders[0].Version, "message"); err != nil {
+		t.Fatalf("Failed to record nag: %v", err)
+	}
+
+	after := time.Date(2024, 5, 2, 3, 0, 0, 0, time.UTC)
+	if err := NewService(cfg, store, bus).checkMissedDoses(ctx, cfg.Medications, after); err != nil {
+		t.Fatalf("Failed to check missed doses: %v", err)
+	}
+	// A restarted service remembers it was published
+	if err := NewService(cfg, store, bus).checkMissedDoses(ctx, cfg.Medications, after.Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to check missed doses: %v", err)
+	}
+
+	if len(missed) != 1 || missed[0] != "Night Pill:2024-05-01" {
+		t.Errorf("Expected the dose to be missed once on 2024-05-01, got %v", missed)
+	}
+}
+
+// TestMigrateDoseTimes tests that the reminders of a medication given dose times move to its earliest
+// dose, except on days that dose already has one
+func TestMigrateDoseTimes(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore(time.UTC)
+	cfg := &config.Config{Timezone: "UTC", Medications: []config.Medication{
+		{Name: "Metformin 20:00", Hour: 20, BaseName: "Metformin", DailyDoses: 2, Frequency: "daily"},
+		{Name: "Metformin 08:00", Hour: 8, BaseName: "Metformin", DailyDoses: 2, Frequency: "daily"},
+	}}
+
+	for _, date := range []string{"2024-04-30", "2024-05-01"} {
+		reminders, err := store.EnsureReminders(ctx, date, []string{"Metformin"})
+		if err != nil {
+			t.Fatalf("Failed to create reminder: %v", err)
+		}
+		if err := store.RecordAcknowledgment(ctx, reminders[0].ID, reminders[0].Version, "message", "user", time.Now()); err != nil {
+			t.Fatalf("Failed to record acknowledgment: %v", err)
+		}
+	}
+	// The bot already ran with the dose times on 2024-05-01 before the reminders were moved
+	if _, err := store.EnsureReminders(ctx, "2024-05-01", []string{"Metformin 08:00"}); err != nil {
+		t.Fatalf("Failed to create reminder: %v", err)
+	}
+
+	// Running again finds nothing left to move
+	for range 2 {
+		if err := NewService(cfg, store, events.NewBus()).migrateDoseTimes(ctx); err != nil {
+			t.Fatalf("Failed to migrate dose times: %v", err)
+		}
+	}
+
+	moved, err := store.GetRemindersForDate(ctx, "2024-04-30")
+	if err != nil {
+		t.Fatalf("Failed to get reminders: %v", err)
+	}
+	if len(moved) != 1 || moved[0].MedicationType != "Metformin 08:00" || !moved[0].Acknowledged {
+		t.Errorf("Expected 2024-04-30's taken dose to move to Metformin 08:00, got %+v", moved)
+	}
+
+	kept, err := store.GetRemindersForDate(ctx, "2024-05-01")
+	if err != nil {
+		t.Fatalf("Failed to get reminders: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, reminder := range kept {
+		names[reminder.MedicationType] = true
+	}
+	if len(kept) != 2 || !names["Metformin"] || !names["Metformin 08:00"] {
+		t.Errorf("Expected 2024-05-01's reminders to be left alone, got %+v", kept)
+	}
+}
+
+// TestReminderWindowOpenOnSchedule tests that medications with a cron schedule are only reminded about
+// on the days it falls on
+func TestReminderWindowOpenOnSchedule(t *testing.T) {
+	// The first Monday of the month, at 09:00
+	medication := config.Medication{Name: "Injection", Hour: 9, Frequency: "daily", Schedule: "0 9 * * 1#1"}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected bool
+	}{
+		{name: "First Monday", now: time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC), expected: true},
+		{name: "Second Monday", now: time.Date(2024, 5, 13, 10, 0, 0, 0, time.UTC), expected: false},
+		{name: "Another day", now: time.Date(2024, 5, 7, 10, 0, 0, 0, time.UTC), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := reminderWindowOpen(medication, tt.now); result != tt.expected {
+				t.Errorf("reminderWindowOpen() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestReminderWindowOpenInMedicationTimezone tests that a medication with its own timezone is due at its
+// hour there rather than in the configured timezone
+func TestReminderWindowOpenInMedicationTimezone(t *testing.T) {
+	medication := config.Medication{Name: "Grandma's Pill", Hour: 9, Frequency: "daily", Timezone: "Asia/Tokyo"}
+	home := time.FixedZone("Home", 0)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected bool
+	}{
+		// 09:00 at home is 18:00 in Tokyo, past the reminder window
+		{name: "At the hour in the configured timezone", now: time.Date(2024, 5, 6, 9, 0, 0, 0, home), expected: false},
+		// 00:30 at home is 09:30 in Tokyo
+		{name: "At the hour in its own timezone", now: time.Date(2024, 5, 6, 0, 30, 0, 0, home), expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := reminderWindowOpen(medication, medication.In(tt.now)); result != tt.expected {
+				t.Errorf("reminderWindowOpen() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+
+	due := medication.DueOn(time.Date(2024, 5, 6, 0, 0, 0, 0, home))
+	if want := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC); !due.Equal(want) {
+		t.Errorf("DueOn() = %s, want %s", due, want)
+	}
+}
+
+// TestMissedDoseInMedicationTimezone tests that a medication in another timezone is dated where it's taken,
+// so its dose is found and missed once when its window closes on the other side of midnight at home
+func TestMissedDoseInMedicationTimezone(t *testing.T) {
+	ctx := context.Background()
+	home, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Timezone data unavailable: %v", err)
+	}
+
+	store := db.NewMemoryStore(home)
+	bus := events.NewBus()
+	medication := config.Medication{Name: "Grandma's Pill", Hour: 7, Frequency: "daily", Timezone: "Asia/Tokyo"}
+	cfg := &config.Config{
+		Timezone:    "America/New_York",
+		Medications: []config.Medication{medication, {Name: "Vitamin D", Hour: 8, Frequency: "daily"}},
+	}
+	s := NewService(cfg, store, bus)
+	store.Calendar().SetLocator(s.DoseLocation)
+
+	var missed []string
+	events.On(bus, func(ctx context.Context, event events.DoseMissed) error {
+		missed = append(missed, event.Medication+":"+event.Date)
+		return nil
+	})
+
+	// 20:00 in New York is 09:00 the next day in Tokyo
+	now := time.Date(2024, 5, 6, 20, 0, 0, 0, home)
+	if date := store.Calendar().Date(medication.Name, now); date != "2024-05-07" {
+		t.Errorf("Expected the dose to be dated in Tokyo, got %s", date)
+	}
+	if date := store.Calendar().Date("Vitamin D", now); date != "2024-05-06" {
+		t.Errorf("Expected other doses to be dated at home, got %s", date)
+	}
+
+	reminders, err := s.todayReminders(ctx, []config.Medication{medication}, now)
+	if err != nil {
+		t.Fatalf("Failed to get today's reminders: %v", err)
+	}
+	reminder := reminders[medication.Name]
+	if reminder.Date != "2024-05-07" {
+		t.Fatalf("Expected the reminder to be for 2024-05-07, got %s", reminder.Date)
+	}
+	if err := store.RecordNag(ctx, reminder.ID, reminder.Version, "message"); err != nil {
+		t.Fatalf("Failed to record nag: %v", err)
+	}
+
+	// The window closes at 12:00 in Tokyo, before midnight in New York
+	if err := s.checkMissedDoses(ctx, []config.Medication{medication}, time.Date(2024, 5, 6, 23, 30, 0, 0, home)); err != nil {
+		t.Fatalf("Failed to check missed doses: %v", err)
+	}
+	// And it's still the same dose after midnight in New York
+	if err := s.checkMissedDoses(ctx, []config.Medication{medication}, time.Date(2024, 5, 7, 0, 30, 0, 0, home)); err != nil {
+		t.Fatalf("Failed to check missed doses: %v", err)
+	}
+
+	if len(missed) != 1 || missed[0] != "Grandma's Pill:2024-05-07" {
+		t.Errorf("Expected the dose to be missed once on 2024-05-07, got %v", missed)
+	}
+}
+
+// TestDoseDateDuringTrip tests that doses are dated in the trip's timezone while travelling, except for
+// medications with their own timezone
+func TestDoseDateDuringTrip(t *testing.T) {
+	ctx := context.Background()
+	trip, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Timezone data unavailable: %v", err)
+	}
+
+	store := db.NewMemoryStore(time.UTC)
+	medication := config.Medication{Name: "Vitamin D", Hour: 8, Frequency: "daily"}
+	cfg := &config.Config{
+		Timezone:    "UTC",
+		Medications: []config.Medication{medication, {Name: "Grandma's Pill", Hour: 9, Frequency: "daily", Timezone: "America/New_York"}},
+	}
+	s := NewService(cfg, store, events.NewBus())
+	store.Calendar().SetLocator(s.DoseLocation)
+	s.tripLocation.Store(trip)
+
+	// 20:00 at home is 05:00 the next day in Tokyo
+	now := time.Date(2024, 5, 6, 20, 0, 0, 0, time.UTC)
+	if date := store.Calendar().Date(medication.Name, now); date != "2024-05-07" {
+		t.Errorf("Expected the dose to be dated in the trip's timezone, got %s", date)
+	}
+	if date := store.Calendar().Date("Grandma's Pill", now); date != "2024-05-06" {
+		t.Errorf("Expected a medication with its own timezone to keep it, got %s", date)
+	}
+
+	reminders, err := s.todayReminders(ctx, []config.Medication{medication}, now.In(trip))
+	if err != nil {
+		t.Fatalf("Failed to get today's reminders: %v", err)
+	}
+	if date := reminders[medication.Name].Date; date != "2024-05-07" {
+		t.Errorf("Expected the reminder to be for 2024-05-07, got %s", date)
+	}
+}
+
+// TestNagDue tests that nags respect the medication's priority
+func TestNagDue(t *testing.T) {
+	service := &Service{
+		config: &config.Config{
+			ReminderIntervalMins: 30,
+		},
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		priority string
+		lastSent time.Time
+		expected bool
+	}{
+		{
+			name:     "First reminder is always due",
+			priority: config.PriorityLow,
+			expected: true,
+		},
+		{
+			name:     "Normal priority nags every interval",
+			priority: config.PriorityNormal,
+			lastSent: now.Add(-30 * time.Minute),
+			expected: true,
+		},
+		{
+			name:     "Low priority skips an interval",
+			priority: config.PriorityLow,
+			lastSent: now.Add(-30 * time.Minute),
+			expected: false,
+		},
+		{
+			name:     "Low priority nags after two intervals",
+			priority: config.PriorityLow,
+			lastSent: now.Add(-60 * time.Minute),
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			medication := config.Medication{Name: "Med", Priority: tt.priority}
+			reminder := &db.Reminder{LastReminderTime: tt.lastSent}
+
+			result := service.nagDue(reminder, medication.Policy(), now)
+			if result != tt.expected {
+				t.Errorf("nagDue() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestRefillReminderDue tests when refill reminders are sent relative to the due date
+func TestRefillReminderDue(t *testing.T) {
+	now := time.Date(2024, 5, 10, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		info     db.MedicationInfo
+		expected bool
+	}{
+		{
+			name:     "No refill due date",
+			info:     db.MedicationInfo{Name: "Med1"},
+			expected: false,
+		},
+		{
+			name:     "Due date is further away than the reminder window",
+			info:     db.MedicationInfo{Name: "Med2", RefillDue: "2024-05-20"},
+			expected: false,
+		},
+		{
+			name:     "Due date is within the reminder window",
+			info:     db.MedicationInfo{Name: "Med3", RefillDue: "2024-05-15"},
+			expected: true,
+		},
+		{
+			name:     "Overdue refills keep reminding",
+			info:     db.MedicationInfo{Name: "Med4", RefillDue: "2024-05-01", RefillRemindedOn: "2024-05-09"},
+			expected: true,
+		},
+		{
+			name:     "Already reminded today",
+			info:     db.MedicationInfo{Name: "Med5", RefillDue: "2024-05-15", RefillRemindedOn: "2024-05-10"},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := refillReminderDue(&tt.info, now, 7)
+			if result != tt.expected {
+				t.Errorf("refillReminderDue() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestArchiveDates tests that each past day is archived once, catching up after the bot was offline
+func TestArchiveDates(t *testing.T) {
+	now := time.Date(2024, 5, 10, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		lastArchived string
+		expected     string
+	}{
+		{name: "Nothing archived yet", lastArchived: "", expected: "2024-05-09"},
+		{name: "Yesterday already archived", lastArchived: "2024-05-09", expected: ""},
+		{name: "Catches up on missed days", lastArchived: "2024-05-07", expected: "2024-05-08,2024-05-09"},
+		{name: "Catches up at most a week", lastArchived: "2024-04-01", expected: "2024-05-03,2024-05-04,2024-05-05,2024-05-06,2024-05-07,2024-05-08,2024-05-09"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := strings.Join(archiveDates(tt.lastArchived, now), ",")
+			if result != tt.expected {
+				t.Errorf("archiveDates() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestHeadsUpDue tests that heads-ups are only sent within the lead time before a medication is due
+func TestHeadsUpDue(t *testing.T) {
+	medication := config.Medication{Name: "Injection", Hour: 21, Frequency: "daily", LeadTimeMins: 30}
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		medication config.Medication
+		now        time.Time
+		expected   bool
+	}{
+		{
+			name:       "Before the lead time",
+			medication: medication,
+			now:        day.Add(20*time.Hour + 15*time.Minute),
+			expected:   false,
+		},
+		{
+			name:       "Within the lead time",
+			medication: medication,
+			now:        day.Add(20*time.Hour + 45*time.Minute),
+			expected:   true,
+		},
+		{
+			name:       "Once the medication is due",
+			medication: medication,
+			now:        day.Add(21 * time.Hour),
+			expected:   false,
+		},
+		{
+			name:       "No lead time configured",
+			medication: config.Medication{Name: "Pill", Hour: 21, Frequency: "daily"},
+			now:        day.Add(20*time.Hour + 45*time.Minute),
+			expected:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := headsUpDue(tt.medication, tt.now)
+			if result != tt.expected {
+				t.Errorf("headsUpDue() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestSleepingIn tests that morning reminders are held until the user is active or the hold runs out
+func TestSleepingIn(t *testing.T) {
+	morning := config.Medication{Name: "Morning Pill", Hour: 8, Frequency: "daily"}
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		medication config.Medication
+		active     time.Time
+		now        time.Time
+		expected   bool
+	}{
+		{
+			name:       "No activity today",
+			medication: morning,
+			active:     day.Add(-2 * time.Hour),
+			now:        day.Add(9 * time.Hour),
+			expected:   true,
+		},
+		{
+			name:       "Active today",
+			medication: morning,
+			active:     day.Add(8*time.Hour + 50*time.Minute),
+			now:        day.Add(9 * time.Hour),
+			expected:   false,
+		},
+		{
+			name:       "Held for the maximum time",
+			medication: morning,
+			now:        day.Add(10 * time.Hour),
+			expected:   false,
+		},
+		{
+			name:       "Afternoon medication",
+			medication: config.Medication{Name: "Afternoon Pill", Hour: 14, Frequency: "daily"},
+			now:        day.Add(14 * time.Hour),
+			expected:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sleepingIn(tt.medication, tt.active, tt.now, 2)
+			if result != tt.expected {
+				t.Errorf("sleepingIn() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+
+	if sleepingIn(morning, time.Time{}, day.Add(9*time.Hour), 0) {
+		t.Error("Expected reminders not to be held when disabled")
+	}
+}
+
+// TestLowSupply tests that taken doses come out of the stock, which is warned about once when it runs low
+// and again after a refill runs low
+func TestLowSupply(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore(time.UTC)
+	bus := events.NewBus()
+	cfg := &config.Config{
+		ReminderIntervalMins: 30,
+		LowSupplyDoses:       5,
+		Medications: []config.Medication{
+			{Name: "Vitamin D", Hour: 8, Frequency: "daily"},
+			{Name: "Fish Oil", Hour: 9, Frequency: "daily"},
+		},
+	}
+	s := NewService(cfg, store, bus)
+
+	var warnings []string
+	events.On(bus, func(ctx context.Context, event events.LowSupply) error {
+		warnings = append(warnings, fmt.Sprintf("%s:%d", event.Forecast.Medication, event.Forecast.PillsRemaining))
+		return nil
+	})
+
+	if err := store.SaveMedicationInfo(ctx, &db.MedicationInfo{Name: "Vitamin D", PillsRemaining: 6}); err != nil {
+		t.Fatalf("Failed to save medication info: %v", err)
+	}
+	takeDose := func() {
+		t.Helper()
+		if err := bus.Publish(ctx, events.DoseAcknowledged{Medication: "Vitamin D"}); err != nil {
+			t.Fatalf("Failed to publish: %v", err)
+		}
+		if err := s.checkLowSupply(ctx); err != nil {
+			t.Fatalf("Failed to check low supply: %v", err)
+		}
+	}
+
+	takeDose()
+	takeDose()
+	if len(warnings) != 1 || warnings[0] != "Vitamin D:5" {
+		t.Fatalf("Expected one warning at 5 doses left, got %v", warnings)
+	}
+	info, _ := store.GetMedicationInfo(ctx, "Vitamin D")
+	if info.PillsRemaining != 4 {
+		t.Errorf("Expected 4 doses left, got %d", info.PillsRemaining)
+	}
+
+	// A refill above the threshold warns again the next time it runs low
+	if _, err := store.AdjustPills(ctx, "Vitamin D", 2); err != nil {
+		t.Fatalf("Failed to refill: %v", err)
+	}
+	if err := s.checkLowSupply(ctx); err != nil {
+		t.Fatalf("Failed to check low supply: %v", err)
+	}
+	takeDose()
+	if len(warnings) != 2 || warnings[1] != "Vitamin D:5" {
+		t.Errorf("Expected a second warning after the refill ran low, got %v", warnings)
+	}
+
+	// Untracked stock isn't touched
+	if err := bus.Publish(ctx, events.DoseAcknowledged{Medication: "Fish Oil"}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	if info, _ := store.GetMedicationInfo(ctx, "Fish Oil"); info.PillsRemaining != db.UntrackedPills {
+		t.Errorf("Expected Fish Oil's stock to stay untracked, got %d", info.PillsRemaining)
+	}
+}
+
+func TestForEachMedication(t *testing.T) {
+	s := &Service{config: &config.Config{ReminderWorkers: 2}}
+	medications := []config.Medication{{Name: "Med1"}, {Name: "Med2"}, {Name: "Med3"}, {Name: "Med4"}}
+
+	var mu sync.Mutex
+	var running, peak int
+	processed := make(map[string]bool)
+
+	err := s.forEachMedication(medications, func(medication config.Medication) error {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		processed[medication.Name] = true
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		switch medication.Name {
+		case "Med2":
+			return errors.New("send failed")
+		case "Med3":
+			panic("unexpected")
+		}
+		return nil
+	})
+
+	if len(processed) != len(medications) {
+		t.Errorf("Expected every medication to be processed despite failures, got %v", processed)
+	}
+	if peak > 2 {
+		t.Errorf("Expected at most 2 medications at once, got %d", peak)
+	}
+	if err == nil || !strings.Contains(err.Error(), "send failed") || !strings.Contains(err.Error(), "panic processing Med3") {
+		t.Errorf("Expected both failures to be returned, got %v", err)
+	}
+}
+
+func BenchmarkCheckReminders(b *testing.B) {
+	ctx := context.Background()
+	store, err := db.NewStore(ctx, filepath.Join(b.TempDir(), "bench.db"), time.UTC)
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	cfg := &config.Config{ReminderIntervalMins: 1, ReminderWorkers: 4, Timezone: "UTC"}
+	for i := range 50 {
+		cfg.Medications = append(cfg.Medications, config.Medication{Name: fmt.Sprintf("Med%d", i), Hour: time.Now().UTC().Hour(), Frequency: "daily"})
+	}
+
+	// Stand in for Discord by recording each reminder as sent
+	bus := events.NewBus()
+	events.On(bus, func(ctx context.Context, event events.ReminderDue) error {
+		return store.RecordNag(ctx, event.Reminder.ID, event.Reminder.Version, "bench")
+	})
+
+	s := NewService(cfg, store, bus)
+
+	for b.Loop() {
+		if err := s.CheckReminders(ctx); err != nil {
+			b.Fatalf("Failed to check reminders: %v", err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Package replication supports continuously replicating the SQLite database to object storage
+// with Litestream (https://litestream.io), so it can be recovered after losing the disk.
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+)
+
+// Command is the Litestream binary used to restore databases
+var Command = "litestream"
+
+// DSN returns the data source name for a database replicated by Litestream. It uses the
+// write-ahead log Litestream replicates from, waits for locks held by Litestream instead of
+// failing, and turns off automatic checkpoints so Litestream controls when the log is checkpointed.
+func DSN(dbPath string) string {
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "journal_mode(wal)")
+	query.Add("_pragma", "synchronous(normal)")
+	query.Add("_pragma", "wal_autocheckpoint(0)")
+
+	return "file:" + (&url.URL{Path: dbPath}).EscapedPath() + "?" + query.Encode()
+}
+
+// Restore restores the database from its Litestream replica if it doesn't exist locally,
+// reporting whether it was restored. Nothing is restored if the replica doesn't exist yet.
+func Restore(ctx context.Context, dbPath, replicaURL string) (bool, error) {
+	if _, err := os.Stat(dbPath); err == nil {
+		return false, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("failed to check database %s: %w", dbPath, err)
+	}
+
+	log.Printf("Database %s not found, restoring from replica", dbPath)
+
+	cmd := exec.CommandContext(ctx, Command, "restore", "-if-replica-exists", "-o", dbPath, replicaURL)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return false, fmt.Errorf("failed to restore database from replica: %w", err)
+	}
+
+	if _, err := os.Stat(dbPath); err != nil {
+		log.Println("No replica found, starting with a new database")
+		return false, nil
+	}
+
+	return true, nil
+}
@@ -0,0 +1,52 @@
+package replication
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDSN(t *testing.T) {
+	dsn := DSN("/app/data/meds reminder.db")
+
+	if !strings.HasPrefix(dsn, "file:/app/data/meds%20reminder.db?") {
+		t.Errorf("Expected an escaped file URI, got %s", dsn)
+	}
+	for _, pragma := range []string{"journal_mode%28wal%29", "wal_autocheckpoint%280%29", "busy_timeout%285000%29"} {
+		if !strings.Contains(dsn, pragma) {
+			t.Errorf("Expected %s in %s", pragma, dsn)
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "meds.db")
+
+	// Stand in for Litestream with a script that writes the restored database
+	script := filepath.Join(dir, "litestream")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho restored > \"$4\"\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	Command = script
+	defer func() { Command = "litestream" }()
+
+	restored, err := Restore(context.Background(), dbPath, "s3://bucket/meds.db")
+	if err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if !restored {
+		t.Error("Expected the missing database to be restored")
+	}
+
+	// Test case: An existing database is left alone
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	restored, err = Restore(context.Background(), dbPath, "s3://bucket/meds.db")
+	if err != nil || restored {
+		t.Errorf("Expected an existing database not to be restored, got %v, %v", restored, err)
+	}
+}
@@ -0,0 +1,203 @@
+package schedule
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed five-field cron expression: minute, hour, day of the month, month and day of the
+// week. Fields take *, numbers, ranges (1-5), lists (1,15), steps (*/2, 1-31/3) and month and day
+// names (jan, mon). A day of the week may be followed by #n for the nth one of the month, so 1#1 is
+// the first Monday. As in cron, when both the day of the month and the day of the week are
+// restricted, a day matching either is scheduled.
+type Cron struct {
+	minutes  []int
+	hours    []int
+	days     []bool // indexed by day of the month, 1-31
+	months   []bool // indexed by month, 1-12
+	weekdays [7]uint8
+	// anyDay and anyWeekday record whether the day of the month and day of the week fields start with *
+	anyDay     bool
+	anyWeekday bool
+}
+
+// everyWeek is the weekdays bit set for every occurrence of a day in the month, rather than only the nth
+const everyWeek uint8 = 1<<6 - 2
+
+var (
+	monthNames   = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// ParseCron parses a five-field cron expression such as "0 9 */2 * *" or "30 8 * * 1#1"
+func ParseCron(expr string) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields (minute hour day month weekday), got %d", len(fields))
+	}
+
+	c := &Cron{
+		days:       make([]bool, 32),
+		months:     make([]bool, 13),
+		anyDay:     strings.HasPrefix(fields[2], "*"),
+		anyWeekday: strings.HasPrefix(fields[4], "*"),
+	}
+
+	minutes, err := parseCronField(fields[0], 0, 59, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minute: %w", err)
+	}
+	hours, err := parseCronField(fields[1], 0, 23, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hour: %w", err)
+	}
+	days, err := parseCronField(fields[2], 1, 31, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid day of the month: %w", err)
+	}
+	months, err := parseCronField(fields[3], 1, 12, monthNames)
+	if err != nil {
+		return nil, fmt.Errorf("invalid month: %w", err)
+	}
+	c.minutes, c.hours = minutes, hours
+	for _, day := range days {
+		c.days[day] = true
+	}
+	for _, month := range months {
+		c.months[month] = true
+	}
+
+	for _, part := range strings.Split(fields[4], ",") {
+		weeks := everyWeek
+		if spec, nth, ok := strings.Cut(part, "#"); ok {
+			n, err := strconv.Atoi(nth)
+			if err != nil || n < 1 || n > 5 {
+				return nil, fmt.Errorf("invalid day of the week: %s (the week after # must be 1 to 5)", part)
+			}
+			part, weeks = spec, 1<<n
+		}
+
+		// 7 is also Sunday, as in most crons
+		weekdays, err := parseCronField(part, 0, 7, weekdayNames)
+		if err != nil {
+			return nil, fmt.Errorf("invalid day of the week: %w", err)
+		}
+		if weeks != everyWeek && len(weekdays) != 1 {
+			return nil, fmt.Errorf("invalid day of the week: %s (# follows a single day)", part)
+		}
+		for _, weekday := range weekdays {
+			c.weekdays[weekday%7] |= weeks
+		}
+	}
+
+	return c, nil
+}
+
+// parseCronField parses a comma-separated cron field into its sorted values between min and max,
+// accepting names for the values from min upwards
+func parseCronField(field string, min, max int, names []string) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(field, ",") {
+		spec, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%s has an invalid step", part)
+			}
+			step = n
+		}
+
+		low, high := min, max
+		switch from, to, isRange := strings.Cut(spec, "-"); {
+		case spec == "*":
+		case isRange:
+			var err error
+			if low, err = parseCronValue(from, min, max, names); err != nil {
+				return nil, err
+			}
+			if high, err = parseCronValue(to, min, max, names); err != nil {
+				return nil, err
+			}
+			if low > high {
+				return nil, fmt.Errorf("%s is a backwards range", part)
+			}
+		default:
+			value, err := parseCronValue(spec, min, max, names)
+			if err != nil {
+				return nil, err
+			}
+			// A step from a single value runs to the end of the field, e.g. 5/10 is 5,15,25...
+			low, high = value, value
+			if hasStep {
+				high = max
+			}
+		}
+
+		for value := low; value <= high; value += step {
+			values = append(values, value)
+		}
+	}
+
+	slices.Sort(values)
+	return slices.Compact(values), nil
+}
+
+// parseCronValue parses a number or name in a cron field
+func parseCronValue(value string, min, max int, names []string) (int, error) {
+	if i := slices.Index(names, strings.ToLower(value)); i >= 0 {
+		return min + i, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q isn't a number", value)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("%d isn't between %d and %d", n, min, max)
+	}
+	return n, nil
+}
+
+// On reports whether the schedule falls on the date of t, at any time of day
+func (c *Cron) On(t time.Time) bool {
+	if !c.months[t.Month()] {
+		return false
+	}
+
+	day := c.days[t.Day()]
+	weekday := c.weekdays[t.Weekday()]&(1<<((t.Day()-1)/7+1)) != 0
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// Times returns the times of day the schedule is due, as minutes after midnight in order
+func (c *Cron) Times() []int {
+	var times []int
+	for _, hour := range c.hours {
+		for _, minute := range c.minutes {
+			times = append(times, hour*60+minute)
+		}
+	}
+	return times
+}
+
+// DayFraction returns the fraction of days the schedule falls on, averaged over a four-year leap cycle
+func (c *Cron) DayFraction() float64 {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(4, 0, 0)
+
+	days, scheduled := 0, 0
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days++
+		if c.On(day) {
+			scheduled++
+		}
+	}
+	return float64(scheduled) / float64(days)
+}
@@ -0,0 +1,86 @@
+package schedule
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestCronOn tests which dates cron expressions fall on
+func TestCronOn(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		date time.Time
+		want bool
+	}{
+		{"every day", "0 8 * * *", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), true},
+		{"odd day", "0 8 */2 * *", time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), true},
+		{"even day", "0 8 */2 * *", time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC), false},
+		{"first Monday", "0 9 * * 1#1", time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC), true},
+		{"second Monday", "0 9 * * mon#1", time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC), false},
+		{"weekday range", "0 9 * * mon-fri", time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC), false},
+		{"Sunday as 7", "0 9 * * 7", time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC), true},
+		{"month list", "0 9 1 jan,jul *", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), true},
+		{"other month", "0 9 1 jan,jul *", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), false},
+		{"day or weekday", "0 9 15 * fri", time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), true},
+		{"neither day nor weekday", "0 9 15 * fri", time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) failed: %v", tt.expr, err)
+			}
+			if got := c.On(tt.date); got != tt.want {
+				t.Errorf("On(%s) = %v, want %v", tt.date.Format("Mon 2006-01-02"), got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCronTimes tests the times of day cron expressions are due
+func TestCronTimes(t *testing.T) {
+	c, err := ParseCron("0,30 8-20/12 * * *")
+	if err != nil {
+		t.Fatalf("ParseCron failed: %v", err)
+	}
+
+	want := []int{8 * 60, 8*60 + 30, 20 * 60, 20*60 + 30}
+	if got := c.Times(); !slices.Equal(got, want) {
+		t.Errorf("Times() = %v, want %v", got, want)
+	}
+}
+
+// TestCronDayFraction tests the share of days schedules fall on
+func TestCronDayFraction(t *testing.T) {
+	weekly, err := ParseCron("0 9 * * mon")
+	if err != nil {
+		t.Fatalf("ParseCron failed: %v", err)
+	}
+	if got := weekly.DayFraction(); got < 0.142 || got > 0.144 {
+		t.Errorf("DayFraction() of a weekly schedule = %f, want about 1/7", got)
+	}
+}
+
+// TestParseCronErrors tests that invalid cron expressions are rejected
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"0 8 * *",
+		"60 8 * * *",
+		"0 24 * * *",
+		"0 8 0 * *",
+		"0 8 * 13 *",
+		"0 8 * * 8",
+		"0 8 * * mon#6",
+		"0 8 * * mon-fri#1",
+		"0 8 */0 * *",
+		"0 8 10-5 * *",
+		"0 8 * * someday",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence is the days a schedule falls on, and the times of day it's due if it sets them
+type Recurrence interface {
+	// On reports whether the schedule falls on the date of t, at any time of day
+	On(t time.Time) bool
+	// Times returns the times of day the schedule is due, as minutes after midnight in order, or none if
+	// it only sets the days
+	Times() []int
+	// DayFraction returns the fraction of days the schedule falls on
+	DayFraction() float64
+}
+
+// ParseRecurrence parses a schedule, which is an interval such as "every 2d from 2024-05-01" or otherwise
+// a cron expression
+func ParseRecurrence(expr string) (Recurrence, error) {
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(expr)), "every ") {
+		return ParseInterval(expr)
+	}
+	return ParseCron(expr)
+}
+
+// Interval is a schedule falling every few days counted from an anchor date, so unlike */2 in a cron
+// expression's day of the month, every other day keeps alternating when a month ends
+type Interval struct {
+	days int
+	// anchor is the first date the schedule falls on, at midnight UTC
+	anchor time.Time
+}
+
+// ParseInterval parses an interval such as "every 2d from 2024-05-01", which falls on the anchor date and
+// every 2 days after it
+func ParseInterval(expr string) (*Interval, error) {
+	fields := strings.Fields(strings.ToLower(expr))
+	if len(fields) != 4 || fields[0] != "every" || fields[2] != "from" || !strings.HasSuffix(fields[1], "d") {
+		return nil, fmt.Errorf("interval must look like \"every 2d from 2024-05-01\", got %q", expr)
+	}
+
+	days, err := strconv.Atoi(strings.TrimSuffix(fields[1], "d"))
+	if err != nil || days < 1 {
+		return nil, fmt.Errorf("invalid interval: %s (must be a whole number of days)", fields[1])
+	}
+	anchor, err := time.Parse("2006-01-02", fields[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date: %s (must be YYYY-MM-DD)", fields[3])
+	}
+
+	return &Interval{days: days, anchor: anchor}, nil
+}
+
+// On reports whether the interval falls on the date of t, which it doesn't before the anchor date
+func (i *Interval) On(t time.Time) bool {
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if date.Before(i.anchor) {
+		return false
+	}
+
+	// Dates are compared at midnight UTC, so every day is 24 hours whatever t's daylight saving
+	elapsed := int(date.Sub(i.anchor) / (24 * time.Hour))
+	return elapsed%i.days == 0
+}
+
+// Times returns no times of day, since an interval only sets the days
+func (i *Interval) Times() []int {
+	return nil
+}
+
+// DayFraction returns the fraction of days the interval falls on
+func (i *Interval) DayFraction() float64 {
+	return 1 / float64(i.days)
+}
@@ -0,0 +1,59 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// TestIntervalOn tests that every other day keeps alternating across the end of a 31-day month
+func TestIntervalOn(t *testing.T) {
+	r, err := ParseRecurrence("every 2d from 2024-05-01")
+	if err != nil {
+		t.Fatalf("ParseRecurrence failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		date time.Time
+		want bool
+	}{
+		{"before the start", time.Date(2024, 4, 29, 0, 0, 0, 0, time.UTC), false},
+		{"start", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), true},
+		{"day after the start", time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), false},
+		{"end of May", time.Date(2024, 5, 31, 9, 0, 0, 0, time.UTC), true},
+		{"start of June", time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC), false},
+		{"second of June", time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC), true},
+		{"late in another timezone", time.Date(2024, 6, 2, 23, 30, 0, 0, time.FixedZone("UTC-10", -10*60*60)), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.On(tt.date); got != tt.want {
+				t.Errorf("On(%s) = %v, want %v", tt.date.Format("Mon 2006-01-02"), got, tt.want)
+			}
+		})
+	}
+
+	if got := r.DayFraction(); got != 0.5 {
+		t.Errorf("DayFraction() = %v, want 0.5", got)
+	}
+	if times := r.Times(); len(times) != 0 {
+		t.Errorf("Times() = %v, want none", times)
+	}
+}
+
+// TestParseIntervalErrors tests that malformed intervals are rejected
+func TestParseIntervalErrors(t *testing.T) {
+	for _, expr := range []string{
+		"every 2d",
+		"every 2 from 2024-05-01",
+		"every 0d from 2024-05-01",
+		"every -1d from 2024-05-01",
+		"every 2d from 2024-13-01",
+		"every 2d since 2024-05-01",
+	} {
+		if _, err := ParseRecurrence(expr); err == nil {
+			t.Errorf("ParseRecurrence(%q) succeeded, want an error", expr)
+		}
+	}
+}
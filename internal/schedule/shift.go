@@ -0,0 +1,131 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// shiftStateKeyPrefix prefixes the state keys medication time shifts are stored under
+const shiftStateKeyPrefix = "schedule_shift_"
+
+// Shift gradually moves a medication's time by a fixed number of minutes per day until the target is reached
+type Shift struct {
+	Medication string `json:"medication"`
+	// From and To are minutes after midnight
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	StepMins  int    `json:"step_mins"`
+	StartDate string `json:"start_date"`
+}
+
+// ShiftStep is the medication time on one day of a shift
+type ShiftStep struct {
+	Date string
+	Time int
+}
+
+// Validate checks the shift's times, step and start date
+func (sh *Shift) Validate() error {
+	if sh.From < 0 || sh.From >= 24*60 || sh.To < 0 || sh.To >= 24*60 {
+		return fmt.Errorf("times must be between 00:00 and 23:59")
+	}
+
+	if sh.StepMins <= 0 {
+		return fmt.Errorf("step must be a positive number of minutes")
+	}
+
+	if _, err := time.Parse("2006-01-02", sh.StartDate); err != nil {
+		return fmt.Errorf("start date must be in the format YYYY-MM-DD")
+	}
+
+	return nil
+}
+
+// TimeOn returns the medication time on the given date, in minutes after midnight
+func (sh *Shift) TimeOn(date time.Time) int {
+	start, err := time.ParseInLocation("2006-01-02", sh.StartDate, date.Location())
+	if err != nil {
+		return sh.From
+	}
+
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	if day.Before(start) {
+		return sh.From
+	}
+
+	// The start date is the first day at the shifted time
+	days := int(day.Sub(start).Hours()/24) + 1
+	moved := days * sh.StepMins
+
+	if sh.To < sh.From {
+		return max(sh.From-moved, sh.To)
+	}
+	return min(sh.From+moved, sh.To)
+}
+
+// Plan returns the medication time on each day from the start date until the target is reached
+func (sh *Shift) Plan() []ShiftStep {
+	start, err := time.Parse("2006-01-02", sh.StartDate)
+	if err != nil {
+		return nil
+	}
+
+	var steps []ShiftStep
+	for day := start; ; day = day.AddDate(0, 0, 1) {
+		t := sh.TimeOn(day)
+		steps = append(steps, ShiftStep{Date: day.Format("2006-01-02"), Time: t})
+		if t == sh.To {
+			return steps
+		}
+	}
+}
+
+// ParseTime parses a time of day in the format HH:MM into minutes after midnight
+func ParseTime(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("time must be in the format HH:MM")
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// FormatTime formats minutes after midnight as HH:MM
+func FormatTime(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// LoadShift returns the shift saved for a medication, or nil if there isn't one
+func LoadShift(ctx context.Context, store StateStore, medication string) (*Shift, error) {
+	value, err := store.GetState(ctx, shiftStateKeyPrefix+medication)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule shift: %w", err)
+	}
+
+	if value == "" {
+		return nil, nil
+	}
+
+	var shift Shift
+	if err := json.Unmarshal([]byte(value), &shift); err != nil {
+		return nil, fmt.Errorf("failed to parse schedule shift: %w", err)
+	}
+
+	return &shift, nil
+}
+
+// SaveShift saves a medication's shift, replacing any existing one
+func SaveShift(ctx context.Context, store StateStore, shift *Shift) error {
+	data, err := json.Marshal(shift)
+	if err != nil {
+		return fmt.Errorf("failed to encode schedule shift: %w", err)
+	}
+
+	return store.SetState(ctx, shiftStateKeyPrefix+shift.Medication, string(data))
+}
+
+// ClearShift removes a medication's shift
+func ClearShift(ctx context.Context, store StateStore, medication string) error {
+	return store.SetState(ctx, shiftStateKeyPrefix+medication, "")
+}
@@ -0,0 +1,34 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// TestShiftPlan tests that shifts move the medication time each day until the target is reached
+func TestShiftPlan(t *testing.T) {
+	shift := &Shift{Medication: "Melatonin", From: 22 * 60, To: 19 * 60, StepMins: 30, StartDate: "2024-05-01"}
+
+	plan := shift.Plan()
+	if len(plan) != 6 {
+		t.Fatalf("Expected 6 steps, got %d: %+v", len(plan), plan)
+	}
+
+	if plan[0].Date != "2024-05-01" || FormatTime(plan[0].Time) != "21:30" {
+		t.Errorf("Unexpected first step: %+v", plan[0])
+	}
+
+	if plan[5].Date != "2024-05-06" || FormatTime(plan[5].Time) != "19:00" {
+		t.Errorf("Unexpected last step: %+v", plan[5])
+	}
+
+	before := time.Date(2024, 4, 30, 12, 0, 0, 0, time.UTC)
+	if got := shift.TimeOn(before); got != 22*60 {
+		t.Errorf("TimeOn() before the start = %s, want 22:00", FormatTime(got))
+	}
+
+	after := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if got := shift.TimeOn(after); got != 19*60 {
+		t.Errorf("TimeOn() after the target = %s, want 19:00", FormatTime(got))
+	}
+}
@@ -0,0 +1,92 @@
+package schedule
+
+import (
+	"math"
+	"time"
+)
+
+// sunZenith is the official zenith for sunrise and sunset, allowing for refraction and the sun's radius
+const sunZenith = 90.833
+
+// Sunrise returns the time of sunrise on the given date at a location, in the date's timezone.
+// It returns false on days the sun doesn't rise, such as during polar night.
+func Sunrise(date time.Time, latitude, longitude float64) (time.Time, bool) {
+	return sunEvent(date, latitude, longitude, true)
+}
+
+// Sunset returns the time of sunset on the given date at a location, in the date's timezone.
+// It returns false on days the sun doesn't set, such as during the midnight sun.
+func Sunset(date time.Time, latitude, longitude float64) (time.Time, bool) {
+	return sunEvent(date, latitude, longitude, false)
+}
+
+// sunEvent calculates sunrise or sunset using the algorithm from the Almanac for Computers,
+// which is accurate to within a couple of minutes
+func sunEvent(date time.Time, latitude, longitude float64, rising bool) (time.Time, bool) {
+	lngHour := longitude / 15
+
+	approx := 18.0
+	if rising {
+		approx = 6.0
+	}
+	t := float64(date.YearDay()) + (approx-lngHour)/24
+
+	// Sun's mean anomaly and true longitude
+	m := 0.9856*t - 3.289
+	l := normalizeDegrees(m + 1.916*sinDeg(m) + 0.020*sinDeg(2*m) + 282.634)
+
+	// Right ascension, in the same quadrant as the true longitude, converted to hours
+	ra := normalizeDegrees(math.Atan(0.91764*math.Tan(l*math.Pi/180)) * 180 / math.Pi)
+	ra += math.Floor(l/90)*90 - math.Floor(ra/90)*90
+	ra /= 15
+
+	// Declination and local hour angle
+	sinDec := 0.39782 * sinDeg(l)
+	cosDec := math.Cos(math.Asin(sinDec))
+	cosH := (cosDeg(sunZenith) - sinDec*sinDeg(latitude)) / (cosDec * cosDeg(latitude))
+	if cosH > 1 || cosH < -1 {
+		return time.Time{}, false
+	}
+
+	h := math.Acos(cosH) * 180 / math.Pi
+	if rising {
+		h = 360 - h
+	}
+	h /= 15
+
+	// Local mean time of the event, converted to UTC
+	ut := math.Mod(h+ra-0.06571*t-6.622-lngHour+48, 24)
+
+	midnight := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	event := midnight.Add(time.Duration(ut * float64(time.Hour))).In(date.Location())
+
+	// The UTC date may differ from the local date, so move the event onto the requested day
+	if event.YearDay() != date.YearDay() {
+		if event.Before(date) {
+			event = event.Add(24 * time.Hour)
+		} else {
+			event = event.Add(-24 * time.Hour)
+		}
+	}
+
+	return event, true
+}
+
+// normalizeDegrees returns an angle in the range [0, 360)
+func normalizeDegrees(degrees float64) float64 {
+	degrees = math.Mod(degrees, 360)
+	if degrees < 0 {
+		degrees += 360
+	}
+	return degrees
+}
+
+// sinDeg returns the sine of an angle in degrees
+func sinDeg(degrees float64) float64 {
+	return math.Sin(degrees * math.Pi / 180)
+}
+
+// cosDeg returns the cosine of an angle in degrees
+func cosDeg(degrees float64) float64 {
+	return math.Cos(degrees * math.Pi / 180)
+}
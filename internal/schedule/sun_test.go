@@ -0,0 +1,65 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSunriseSunset tests sunrise and sunset against published times
+func TestSunriseSunset(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("Timezone data unavailable: %v", err)
+	}
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Skipf("Timezone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		date      time.Time
+		latitude  float64
+		longitude float64
+		sunrise   string
+		sunset    string
+	}{
+		{"London midsummer", time.Date(2024, 6, 21, 0, 0, 0, 0, london), 51.5074, -0.1278, "04:43", "21:21"},
+		{"Sydney midwinter", time.Date(2024, 6, 21, 0, 0, 0, 0, sydney), -33.8688, 151.2093, "07:00", "16:54"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sunrise, ok := Sunrise(tt.date, tt.latitude, tt.longitude)
+			if !ok {
+				t.Fatal("Expected a sunrise")
+			}
+			assertNear(t, "sunrise", sunrise, tt.date, tt.sunrise)
+
+			sunset, ok := Sunset(tt.date, tt.latitude, tt.longitude)
+			if !ok {
+				t.Fatal("Expected a sunset")
+			}
+			assertNear(t, "sunset", sunset, tt.date, tt.sunset)
+		})
+	}
+
+	// The sun doesn't set in Tromsø at midsummer
+	if _, ok := Sunset(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), 69.6492, 18.9553); ok {
+		t.Error("Expected no sunset during the midnight sun")
+	}
+}
+
+// assertNear checks that a time is within a few minutes of the expected time of day
+func assertNear(t *testing.T, name string, got, date time.Time, expected string) {
+	t.Helper()
+
+	want, err := time.ParseInLocation("2006-01-02 15:04", date.Format("2006-01-02")+" "+expected, date.Location())
+	if err != nil {
+		t.Fatalf("Invalid expected time %s: %v", expected, err)
+	}
+
+	if diff := got.Sub(want); diff > 3*time.Minute || diff < -3*time.Minute {
+		t.Errorf("%s = %s, want %s", name, got.Format("2006-01-02 15:04"), want.Format("2006-01-02 15:04"))
+	}
+}
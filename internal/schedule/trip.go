@@ -0,0 +1,146 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TripStateKey is the state key the current trip is stored under
+const TripStateKey = "trip"
+
+// StateStore is the key-value state storage trips are kept in
+type StateStore interface {
+	GetState(ctx context.Context, key string) (string, error)
+	SetState(ctx context.Context, key, value string) error
+}
+
+// Trip temporarily moves schedule computation to another timezone between two dates (inclusive)
+type Trip struct {
+	Timezone string `json:"timezone"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	// ShiftHoursPerDay gradually moves dose times towards the destination timezone, 0 switches immediately
+	ShiftHoursPerDay int `json:"shift_hours_per_day"`
+}
+
+// Validate checks the trip's timezone and dates
+func (t *Trip) Validate() error {
+	if _, err := time.LoadLocation(t.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %s", t.Timezone)
+	}
+
+	start, err := time.Parse("2006-01-02", t.Start)
+	if err != nil {
+		return fmt.Errorf("start date must be in the format YYYY-MM-DD")
+	}
+
+	end, err := time.Parse("2006-01-02", t.End)
+	if err != nil {
+		return fmt.Errorf("end date must be in the format YYYY-MM-DD")
+	}
+
+	if end.Before(start) {
+		return fmt.Errorf("end date must not be before the start date")
+	}
+
+	if t.ShiftHoursPerDay < 0 {
+		return fmt.Errorf("shift hours per day must not be negative")
+	}
+
+	return nil
+}
+
+// Active checks if the trip covers the current date at the destination
+func (t *Trip) Active(now time.Time) bool {
+	date := t.localDate(now)
+	return date >= t.Start && date <= t.End
+}
+
+// Over checks if the trip has ended
+func (t *Trip) Over(now time.Time) bool {
+	return t.localDate(now) > t.End
+}
+
+// Location returns the location schedules are computed in during the trip. When shifting gradually,
+// this is a fixed offset moving from home towards the destination by ShiftHoursPerDay each day.
+func (t *Trip) Location(now time.Time, home *time.Location) *time.Location {
+	dest, err := time.LoadLocation(t.Timezone)
+	if err != nil || t.ShiftHoursPerDay <= 0 {
+		return dest
+	}
+
+	_, homeOffset := now.In(home).Zone()
+	_, destOffset := now.In(dest).Zone()
+	diff := destOffset - homeOffset
+
+	start, err := time.ParseInLocation("2006-01-02", t.Start, dest)
+	if err != nil {
+		return dest
+	}
+
+	// The first day of the trip is already shifted by one step
+	days := int(now.In(dest).Sub(start).Hours()/24) + 1
+	shift := days * t.ShiftHoursPerDay * 3600
+	if shift >= abs(diff) {
+		return dest
+	}
+
+	if diff < 0 {
+		shift = -shift
+	}
+
+	return time.FixedZone(t.Timezone+" (adjusting)", homeOffset+shift)
+}
+
+// localDate returns the current date at the trip's destination
+func (t *Trip) localDate(now time.Time) string {
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		loc = now.Location()
+	}
+	return now.In(loc).Format("2006-01-02")
+}
+
+// LoadTrip returns the saved trip, or nil if there isn't one
+func LoadTrip(ctx context.Context, store StateStore) (*Trip, error) {
+	value, err := store.GetState(ctx, TripStateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trip: %w", err)
+	}
+
+	if value == "" {
+		return nil, nil
+	}
+
+	var trip Trip
+	if err := json.Unmarshal([]byte(value), &trip); err != nil {
+		return nil, fmt.Errorf("failed to parse trip: %w", err)
+	}
+
+	return &trip, nil
+}
+
+// SaveTrip saves a trip, replacing any existing one
+func SaveTrip(ctx context.Context, store StateStore, trip *Trip) error {
+	data, err := json.Marshal(trip)
+	if err != nil {
+		return fmt.Errorf("failed to encode trip: %w", err)
+	}
+
+	return store.SetState(ctx, TripStateKey, string(data))
+}
+
+// ClearTrip removes the saved trip
+func ClearTrip(ctx context.Context, store StateStore) error {
+	return store.SetState(ctx, TripStateKey, "")
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
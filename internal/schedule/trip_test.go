@@ -0,0 +1,65 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTripActive tests that trips are active between their start and end dates at the destination
+func TestTripActive(t *testing.T) {
+	trip := &Trip{Timezone: "Asia/Tokyo", Start: "2024-05-01", End: "2024-05-10"}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected bool
+		over     bool
+	}{
+		{"Before the trip", time.Date(2024, 4, 30, 12, 0, 0, 0, time.UTC), false, false},
+		{"Start date already reached in Tokyo", time.Date(2024, 4, 30, 16, 0, 0, 0, time.UTC), true, false},
+		{"During the trip", time.Date(2024, 5, 5, 12, 0, 0, 0, time.UTC), true, false},
+		{"After the trip", time.Date(2024, 5, 11, 12, 0, 0, 0, time.UTC), false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := trip.Active(tt.now); result != tt.expected {
+				t.Errorf("Active() = %v, want %v", result, tt.expected)
+			}
+			if result := trip.Over(tt.now); result != tt.over {
+				t.Errorf("Over() = %v, want %v", result, tt.over)
+			}
+		})
+	}
+}
+
+// TestTripLocation tests that gradual trips shift the schedule offset towards the destination each day
+func TestTripLocation(t *testing.T) {
+	home := time.UTC
+	trip := &Trip{Timezone: "Asia/Tokyo", Start: "2024-05-01", End: "2024-05-10", ShiftHoursPerDay: 2}
+
+	tests := []struct {
+		name           string
+		now            time.Time
+		expectedOffset int
+	}{
+		{"First day", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), 2 * 3600},
+		{"Third day", time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC), 6 * 3600},
+		{"Fully adjusted", time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC), 9 * 3600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, offset := tt.now.In(trip.Location(tt.now, home)).Zone()
+			if offset != tt.expectedOffset {
+				t.Errorf("offset = %d, want %d", offset, tt.expectedOffset)
+			}
+		})
+	}
+
+	immediate := &Trip{Timezone: "Asia/Tokyo", Start: "2024-05-01", End: "2024-05-10"}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if _, offset := now.In(immediate.Location(now, home)).Zone(); offset != 9*3600 {
+		t.Errorf("immediate offset = %d, want %d", offset, 9*3600)
+	}
+}
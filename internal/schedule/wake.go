@@ -0,0 +1,81 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// WakeStateKey is the state key the latest wake check-in is stored under
+	WakeStateKey = "wake_check_in"
+	// ActivityStateKey is the state key the time the user was last seen active is stored under
+	ActivityStateKey = "last_activity"
+)
+
+// LoadWake returns the time of the latest wake check-in, or the zero time if there hasn't been one
+func LoadWake(ctx context.Context, store StateStore) (time.Time, error) {
+	return loadTime(ctx, store, WakeStateKey, "wake check-in")
+}
+
+// SaveWake saves a wake check-in, replacing the previous one
+func SaveWake(ctx context.Context, store StateStore, wake time.Time) error {
+	return store.SetState(ctx, WakeStateKey, wake.Format(time.RFC3339))
+}
+
+// LoadActivity returns the time the user was last seen active, or the zero time if they never have been
+func LoadActivity(ctx context.Context, store StateStore) (time.Time, error) {
+	return loadTime(ctx, store, ActivityStateKey, "last activity")
+}
+
+// SaveActivity saves the time the user was last seen active
+func SaveActivity(ctx context.Context, store StateStore, active time.Time) error {
+	return store.SetState(ctx, ActivityStateKey, active.Format(time.RFC3339))
+}
+
+// WakeOn returns the wake check-in (or activity) if it was on the same day as now, in now's timezone
+func WakeOn(wake, now time.Time) (time.Time, bool) {
+	if wake.IsZero() {
+		return time.Time{}, false
+	}
+
+	wake = wake.In(now.Location())
+	if wake.Format("2006-01-02") != now.Format("2006-01-02") {
+		return time.Time{}, false
+	}
+	return wake, true
+}
+
+// WakeDoseOn returns when a dose anchored to the latest wake check-in is due, offset from it, if that's on
+// the same day as now in now's timezone. A check-in carries on past midnight until the next one, so a dose
+// due in the small hours after waking in the evening is still due then.
+func WakeDoseOn(wake time.Time, offset time.Duration, now time.Time) (time.Time, bool) {
+	if wake.IsZero() {
+		return time.Time{}, false
+	}
+
+	due := wake.Add(offset).In(now.Location())
+	if due.Format("2006-01-02") != now.Format("2006-01-02") {
+		return time.Time{}, false
+	}
+	return due, true
+}
+
+// loadTime returns the time saved under a state key, or the zero time if there isn't one
+func loadTime(ctx context.Context, store StateStore, key, description string) (time.Time, error) {
+	value, err := store.GetState(ctx, key)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get %s: %w", description, err)
+	}
+
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse %s: %w", description, err)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,65 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// TestWakeOn tests that only a check-in from the same local day is used
+func TestWakeOn(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	now := time.Date(2024, 5, 2, 15, 0, 0, 0, loc)
+
+	// 02:00 UTC is still the previous evening at UTC-5
+	if _, ok := WakeOn(time.Date(2024, 5, 2, 2, 0, 0, 0, time.UTC), now); ok {
+		t.Error("Expected a check-in from the previous local day to be ignored")
+	}
+
+	wake, ok := WakeOn(time.Date(2024, 5, 2, 18, 30, 0, 0, time.UTC), now)
+	if !ok {
+		t.Fatal("Expected today's check-in to be used")
+	}
+	if wake.Hour() != 13 || wake.Minute() != 30 {
+		t.Errorf("Expected the check-in in local time, got %s", wake.Format("15:04"))
+	}
+
+	if _, ok := WakeOn(time.Time{}, now); ok {
+		t.Error("Expected no check-in to be ignored")
+	}
+}
+
+// TestWakeDoseOn tests that a check-in keeps scheduling doses after midnight until the next one
+func TestWakeDoseOn(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	wake := time.Date(2024, 5, 2, 18, 0, 0, 0, loc)
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		now    time.Time
+		want   string
+		ok     bool
+	}{
+		{name: "Same evening", offset: 2 * time.Hour, now: time.Date(2024, 5, 2, 19, 0, 0, 0, loc), want: "2024-05-02 20:00", ok: true},
+		{name: "After midnight", offset: 8 * time.Hour, now: time.Date(2024, 5, 3, 0, 30, 0, 0, loc), want: "2024-05-03 02:00", ok: true},
+		{name: "Before midnight for a dose after it", offset: 8 * time.Hour, now: time.Date(2024, 5, 2, 23, 0, 0, 0, loc), ok: false},
+		{name: "Dose from the previous day", offset: 2 * time.Hour, now: time.Date(2024, 5, 3, 9, 0, 0, 0, loc), ok: false},
+		{name: "Check-in days ago", offset: 8 * time.Hour, now: time.Date(2024, 5, 5, 1, 0, 0, 0, loc), ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, ok := WakeDoseOn(wake, tt.offset, tt.now)
+			if ok != tt.ok {
+				t.Fatalf("WakeDoseOn() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && due.Format("2006-01-02 15:04") != tt.want {
+				t.Errorf("WakeDoseOn() = %s, want %s", due.Format("2006-01-02 15:04"), tt.want)
+			}
+		})
+	}
+
+	if _, ok := WakeDoseOn(time.Time{}, 0, wake); ok {
+		t.Error("Expected no check-in to be ignored")
+	}
+}
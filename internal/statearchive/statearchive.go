@@ -0,0 +1,236 @@
+// Package statearchive exports the bot's state, a snapshot of its database and its settings, as a
+// single archive, and imports it again on another host, so the bot can be moved in one command on each side.
+package statearchive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"meds-bot/internal/db"
+)
+
+// FormatVersion is the version of the archive layout, increased whenever it changes
+const FormatVersion = 1
+
+// Names of the files in an archive. The manifest comes first, so it's checked before anything is extracted.
+const (
+	manifestName = "manifest.json"
+	databaseName = "meds.db"
+	settingsName = ".env"
+)
+
+var (
+	// ErrIncompatible is returned when importing an archive written by a newer version of the bot
+	ErrIncompatible = errors.New("state archive is from a newer version of the bot")
+	// ErrExists is returned when importing would replace an existing database or settings file
+	ErrExists = errors.New("file already exists")
+)
+
+// Manifest describes an archive's contents
+type Manifest struct {
+	Format    int       `json:"format"`
+	Schema    int       `json:"schema"`
+	CreatedAt time.Time `json:"created_at"`
+	// Settings is set if the archive includes a settings file
+	Settings bool `json:"settings"`
+}
+
+// Write writes an archive of a database snapshot and the settings file, which is left out if it doesn't exist
+func Write(w io.Writer, snapshotPath, settingsPath string) (*Manifest, error) {
+	manifest := &Manifest{Format: FormatVersion, Schema: db.SchemaVersion, CreatedAt: time.Now().UTC()}
+	if _, err := os.Stat(settingsPath); err == nil {
+		manifest.Settings = true
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to check settings file %s: %w", settingsPath, err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := writeEntry(tw, manifestName, int64(len(data)), manifest.CreatedAt, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	if err := writeFile(tw, databaseName, snapshotPath, manifest.CreatedAt); err != nil {
+		return nil, err
+	}
+	if manifest.Settings {
+		if err := writeFile(tw, settingsName, settingsPath, manifest.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// writeFile adds a file on disk to an archive under the given name
+func writeFile(tw *tar.Writer, name, path string, modTime time.Time) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	return writeEntry(tw, name, info.Size(), modTime, f)
+}
+
+// writeEntry adds a file to an archive. Files are only readable by their owner, as they hold
+// health data and the bot token.
+func writeEntry(tw *tar.Writer, name string, size int64, modTime time.Time, r io.Reader) error {
+	header := &tar.Header{Name: name, Mode: 0o600, Size: size, ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+// Read imports an archive, writing its database to dbPath and its settings, if it has any, to
+// settingsPath. Archives from a newer version of the bot return ErrIncompatible, and existing
+// files are only replaced if overwrite is set. Nothing is replaced until the whole archive has been read.
+func Read(r io.Reader, dbPath, settingsPath string, overwrite bool) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := map[string]string{databaseName: dbPath}
+	if manifest.Settings {
+		targets[settingsName] = settingsPath
+	}
+	if !overwrite {
+		for _, path := range targets {
+			if _, err := os.Stat(path); err == nil {
+				return nil, fmt.Errorf("%w: %s", ErrExists, path)
+			}
+		}
+	}
+
+	// Files are extracted next to their targets, then moved into place once all have been read
+	extracted := make(map[string]string)
+	defer func() {
+		for _, tmp := range extracted {
+			os.Remove(tmp)
+		}
+	}()
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		path, ok := targets[header.Name]
+		if !ok {
+			return nil, fmt.Errorf("unexpected file %s in archive", header.Name)
+		}
+
+		tmp := path + ".import"
+		extracted[header.Name] = tmp
+		if err := extract(tr, tmp); err != nil {
+			return nil, err
+		}
+	}
+
+	for name := range targets {
+		if _, ok := extracted[name]; !ok {
+			return nil, fmt.Errorf("archive is missing %s", name)
+		}
+	}
+
+	// A write-ahead log left by the old database would be applied to the imported one
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove %s: %w", dbPath+suffix, err)
+		}
+	}
+
+	for name, tmp := range extracted {
+		if err := os.Rename(tmp, targets[name]); err != nil {
+			return nil, fmt.Errorf("failed to replace %s: %w", targets[name], err)
+		}
+		delete(extracted, name)
+	}
+
+	return manifest, nil
+}
+
+// readManifest reads the manifest at the start of an archive and checks this version of the bot can import it
+func readManifest(tr *tar.Reader) (*Manifest, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if header.Name != manifestName {
+		return nil, fmt.Errorf("not a state archive, expected %s first but found %s", manifestName, header.Name)
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	// Older databases are migrated when the bot opens them, but newer ones can't be downgraded
+	if manifest.Format > FormatVersion {
+		return nil, fmt.Errorf("%w: archive format %d, this version reads up to %d", ErrIncompatible, manifest.Format, FormatVersion)
+	}
+	if manifest.Schema > db.SchemaVersion {
+		return nil, fmt.Errorf("%w: database schema %d, this version supports up to %d", ErrIncompatible, manifest.Schema, db.SchemaVersion)
+	}
+
+	return &manifest, nil
+}
+
+// extract writes the current archive entry to a new file at path
+func extract(tr *tar.Reader, path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+
+	if _, err := io.Copy(f, tr); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package statearchive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	src := t.TempDir()
+	snapshot := filepath.Join(src, "snapshot.db")
+	settings := filepath.Join(src, ".env")
+	if err := os.WriteFile(snapshot, []byte("database"), 0o600); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	if err := os.WriteFile(settings, []byte("DISCORD_TOKEN=token\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write settings: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if _, err := Write(&archive, snapshot, settings); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	dst := t.TempDir()
+	dbPath := filepath.Join(dst, "meds.db")
+	settingsPath := filepath.Join(dst, ".env")
+	// A log left by the replaced database must not survive the import
+	if err := os.WriteFile(dbPath+"-wal", []byte("stale"), 0o600); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	manifest, err := Read(bytes.NewReader(archive.Bytes()), dbPath, settingsPath, false)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	if !manifest.Settings || manifest.Format != FormatVersion {
+		t.Errorf("Unexpected manifest %+v", manifest)
+	}
+
+	if data, _ := os.ReadFile(dbPath); string(data) != "database" {
+		t.Errorf("Expected the database to be imported, got %q", data)
+	}
+	if data, _ := os.ReadFile(settingsPath); string(data) != "DISCORD_TOKEN=token\n" {
+		t.Errorf("Expected the settings to be imported, got %q", data)
+	}
+	if _, err := os.Stat(dbPath + "-wal"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the old log to be removed, got %v", err)
+	}
+
+	// Importing again needs overwrite
+	if _, err := Read(bytes.NewReader(archive.Bytes()), dbPath, settingsPath, false); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists, got %v", err)
+	}
+	if _, err := Read(bytes.NewReader(archive.Bytes()), dbPath, settingsPath, true); err != nil {
+		t.Errorf("Failed to overwrite: %v", err)
+	}
+}
+
+func TestReadNewerArchive(t *testing.T) {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	manifest := []byte(`{"format": 1, "schema": 1000}`)
+	tw.WriteHeader(&tar.Header{Name: manifestName, Mode: 0o600, Size: int64(len(manifest))})
+	tw.Write(manifest)
+	tw.Close()
+	gz.Close()
+
+	dbPath := filepath.Join(t.TempDir(), "meds.db")
+	if _, err := Read(&archive, dbPath, "", false); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Expected ErrIncompatible, got %v", err)
+	}
+	if _, err := os.Stat(dbPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected nothing to be imported, got %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+	"meds-bot/internal/schedule"
+)
+
+// StockForecast is the projected run-out date of a medication based on its remaining stock
+type StockForecast struct {
+	Medication     string
+	PillsRemaining int
+	DaysLeft       int
+	RunOutDate     time.Time
+}
+
+// Warning describes when the medication runs out, e.g. "Metformin runs out in 9 days"
+func (f StockForecast) Warning() string {
+	switch f.DaysLeft {
+	case 0:
+		return fmt.Sprintf("%s runs out today", f.Medication)
+	case 1:
+		return fmt.Sprintf("%s runs out tomorrow", f.Medication)
+	default:
+		return fmt.Sprintf("%s runs out in %d days (%s)", f.Medication, f.DaysLeft, f.RunOutDate.Format("Mon 2 Jan"))
+	}
+}
+
+// DosesPerDay returns the average number of doses of a medication taken per day, counting every dose
+// of one taken more than once a day
+func DosesPerDay(medication config.Medication) float64 {
+	doses := float64(max(medication.DailyDoses, 1))
+	if medication.Schedule != "" {
+		if recurrence, err := schedule.ParseRecurrence(medication.Schedule); err == nil {
+			return doses * recurrence.DayFraction()
+		}
+	}
+	if medication.Frequency == "weekly" {
+		return doses / 7
+	}
+
+	return doses
+}
+
+// ForecastStock projects when a medication will run out from its remaining doses
+func ForecastStock(medication config.Medication, pillsRemaining int, now time.Time) StockForecast {
+	daysLeft := 0
+	if pillsRemaining > 0 {
+		daysLeft = int(math.Floor(float64(pillsRemaining) / DosesPerDay(medication)))
+	}
+
+	return StockForecast{
+		Medication:     medication.Base(),
+		PillsRemaining: pillsRemaining,
+		DaysLeft:       daysLeft,
+		RunOutDate:     now.AddDate(0, 0, daysLeft),
+	}
+}
+
+// Adherence is the number of doses of a medication taken out of those due
+type Adherence struct {
+	Medication string
+	Taken      int
+	Due        int
+}
+
+// Percent returns the percentage of due doses that were taken
+func (a Adherence) Percent() int {
+	if a.Due == 0 {
+		return 100
+	}
+
+	return a.Taken * 100 / a.Due
+}
+
+// CalculateAdherence summarises a medication's reminders. Today's dose only counts once it has been taken,
+// since it can still be acknowledged, and skipped doses aren't counted as due.
+func CalculateAdherence(medication string, reminders []db.Reminder, today string) Adherence {
+	adherence := Adherence{Medication: medication}
+	for _, reminder := range reminders {
+		if reminder.MedicationType != medication {
+			continue
+		}
+
+		if reminder.Acknowledged {
+			adherence.Taken++
+			adherence.Due++
+		} else if reminder.Date < today && !reminder.Skipped {
+			adherence.Due++
+		}
+	}
+
+	return adherence
+}
+
+// Streak returns the number of consecutive doses of a medication taken, counting back from the most recent.
+// Reminders must be ordered by date. Today's dose only breaks the streak once the day is over, and skipped
+// doses don't break it.
+func Streak(medication string, reminders []db.Reminder, today string) int {
+	streak := 0
+	for i := len(reminders) - 1; i >= 0; i-- {
+		reminder := reminders[i]
+		if reminder.MedicationType != medication {
+			continue
+		}
+
+		if reminder.Acknowledged {
+			streak++
+		} else if reminder.Date < today && !reminder.Skipped {
+			break
+		}
+	}
+
+	return streak
+}
+
+// MissedDates returns the dates (YYYY-MM-DD) of a medication's doses that weren't taken or skipped, oldest
+// first. Today's dose isn't missed yet, since it can still be acknowledged.
+func MissedDates(medication string, reminders []db.Reminder, today string) []string {
+	var dates []string
+	for _, reminder := range reminders {
+		if reminder.MedicationType == medication && !reminder.Resolved() && reminder.Date < today {
+			dates = append(dates, reminder.Date)
+		}
+	}
+
+	return dates
+}
+
+// Delivery is how reliably a medication's reminders reached the user, telling doses missed because their
+// reminders didn't arrive apart from doses missed despite them
+type Delivery struct {
+	Medication string
+	// Sent is the reminders delivered, and Fallback how many of them were delivered by a backup channel
+	Sent     int
+	Fallback int
+	// Failed is the attempts to deliver a reminder that failed, by any channel
+	Failed int
+	// Doses is the doses reminders were sent or attempted for, and Interacted how many of them the user
+	// acted on by taking, skipping or confirming them
+	Doses      int
+	Interacted int
+	// MissedUndelivered is the missed doses no reminder reached, and MissedIgnored those whose reminders
+	// arrived but weren't acted on
+	MissedUndelivered int
+	MissedIgnored     int
+}
+
+// Percent returns the percentage of attempts to deliver a reminder that succeeded
+func (d Delivery) Percent() int {
+	if d.Sent+d.Failed == 0 {
+		return 100
+	}
+
+	return d.Sent * 100 / (d.Sent + d.Failed)
+}
+
+// CalculateDelivery summarises the delivery of a medication's reminders from the dose event log. A missed
+// dose that was marked as taken later counts as interacted with rather than missed.
+func CalculateDelivery(medication string, doseEvents []db.DoseEvent) Delivery {
+	type dose struct {
+		delivered, interacted, missed bool
+	}
+
+	delivery := Delivery{Medication: medication}
+	doses := make(map[string]*dose)
+	for _, event := range doseEvents {
+		if event.Medication != medication || event.Type == db.DoseEventLogged {
+			continue
+		}
+		d, ok := doses[event.Date]
+		if !ok {
+			d = &dose{}
+			doses[event.Date] = d
+		}
+
+		switch event.Type {
+		case db.DoseEventReminded:
+			delivery.Sent++
+			if event.Source != "" && event.Source != db.ChannelDiscord {
+				delivery.Fallback++
+			}
+			d.delivered = true
+		case db.DoseEventUndelivered:
+			delivery.Failed++
+		case db.DoseEventAcknowledged, db.DoseEventSkipped, db.DoseEventPendingConfirmation:
+			d.interacted = true
+		case db.DoseEventMissed:
+			d.missed = true
+		}
+	}
+
+	for _, d := range doses {
+		delivery.Doses++
+		switch {
+		case d.interacted:
+			delivery.Interacted++
+		case d.missed && !d.delivered:
+			delivery.MissedUndelivered++
+		case d.missed:
+			delivery.MissedIgnored++
+		}
+	}
+
+	return delivery
+}
+
+// WeeklyReport summarises the last week's adherence and upcoming stock shortages
+type WeeklyReport struct {
+	From          time.Time
+	To            time.Time
+	Adherence     []Adherence
+	StockWarnings []StockForecast
+}
+
+// StockForecasts projects the run-out date of every medication whose stock is tracked
+func StockForecasts(ctx context.Context, store db.StoreInterface, medications []config.Medication, now time.Time) ([]StockForecast, error) {
+	var forecasts []StockForecast
+	forecasted := make(map[string]bool)
+	for _, medication := range medications {
+		// The doses of a medication taken more than once a day share its stock
+		if forecasted[medication.Base()] {
+			continue
+		}
+		forecasted[medication.Base()] = true
+
+		info, err := store.GetMedicationInfo(ctx, medication.Base())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get medication info for %s: %w", medication.Base(), err)
+		}
+
+		if info.PillsRemaining == db.UntrackedPills {
+			continue
+		}
+
+		forecasts = append(forecasts, ForecastStock(medication, info.PillsRemaining, now))
+	}
+
+	return forecasts, nil
+}
+
+// StockWarnings returns the forecasts of medications running out within the given number of days
+func StockWarnings(forecasts []StockForecast, withinDays int) []StockForecast {
+	var warnings []StockForecast
+	for _, forecast := range forecasts {
+		if forecast.DaysLeft <= withinDays {
+			warnings = append(warnings, forecast)
+		}
+	}
+
+	return warnings
+}
+
+// BuildWeeklyReport summarises the seven days up to now
+func BuildWeeklyReport(ctx context.Context, store db.StoreInterface, medications []config.Medication, now time.Time, warningDays int) (*WeeklyReport, error) {
+	from := now.AddDate(0, 0, -7)
+
+	reminders, err := store.GetReminderHistory(ctx, "", from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reminder history: %w", err)
+	}
+
+	report := &WeeklyReport{From: from, To: now}
+
+	today := now.Format("2006-01-02")
+	for _, medication := range medications {
+		// As-needed medications have no doses due to adhere to
+		if medication.AsNeeded() {
+			continue
+		}
+		report.Adherence = append(report.Adherence, CalculateAdherence(medication.Name, reminders, today))
+	}
+
+	forecasts, err := StockForecasts(ctx, store, medications, now)
+	if err != nil {
+		return nil, err
+	}
+	report.StockWarnings = StockWarnings(forecasts, warningDays)
+
+	return report, nil
+}
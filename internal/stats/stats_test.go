@@ -0,0 +1,165 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/db"
+)
+
+func TestForecastStock(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		medication config.Medication
+		pills      int
+		daysLeft   int
+		warning    string
+	}{
+		{
+			name:       "Daily medication",
+			medication: config.Medication{Name: "Metformin", Frequency: "daily"},
+			pills:      9,
+			daysLeft:   9,
+			warning:    "Metformin runs out in 9 days (Fri 10 May)",
+		},
+		{
+			name:       "Weekly medication",
+			medication: config.Medication{Name: "Methotrexate", Frequency: "weekly", Day: "monday"},
+			pills:      2,
+			daysLeft:   14,
+			warning:    "Methotrexate runs out in 14 days (Wed 15 May)",
+		},
+		{
+			name:       "Medication on an every-other-day schedule",
+			medication: config.Medication{Name: "Furosemide", Frequency: "daily", Schedule: "0 9 */2 * *"},
+			pills:      10,
+			daysLeft:   19,
+			warning:    "Furosemide runs out in 19 days (Mon 20 May)",
+		},
+		{
+			name:       "Dose of a medication taken twice a day",
+			medication: config.Medication{Name: "Metformin 08:00", Frequency: "daily", BaseName: "Metformin", DailyDoses: 2},
+			pills:      9,
+			daysLeft:   4,
+			warning:    "Metformin runs out in 4 days (Sun 5 May)",
+		},
+		{
+			name:       "Last dose",
+			medication: config.Medication{Name: "Vitamin D", Frequency: "daily"},
+			pills:      1,
+			daysLeft:   1,
+			warning:    "Vitamin D runs out tomorrow",
+		},
+		{
+			name:       "Out of stock",
+			medication: config.Medication{Name: "Iron", Frequency: "daily"},
+			pills:      0,
+			daysLeft:   0,
+			warning:    "Iron runs out today",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forecast := ForecastStock(tt.medication, tt.pills, now)
+			if forecast.DaysLeft != tt.daysLeft {
+				t.Errorf("DaysLeft = %d, want %d", forecast.DaysLeft, tt.daysLeft)
+			}
+			if forecast.Warning() != tt.warning {
+				t.Errorf("Warning() = %q, want %q", forecast.Warning(), tt.warning)
+			}
+		})
+	}
+}
+
+func TestCalculateAdherence(t *testing.T) {
+	reminders := []db.Reminder{
+		{Date: "2024-05-01", MedicationType: "Med", Acknowledged: true},
+		{Date: "2024-05-02", MedicationType: "Med", Acknowledged: false},
+		{Date: "2024-05-03", MedicationType: "Med", Acknowledged: true},
+		{Date: "2024-05-04", MedicationType: "Med", Acknowledged: false},
+		{Date: "2024-05-01", MedicationType: "OtherMed", Acknowledged: false},
+		// A skipped dose isn't due
+		{Date: "2024-04-30", MedicationType: "Med", Skipped: true, SkipReason: "Blood test"},
+	}
+
+	// The pending dose today isn't counted as missed
+	adherence := CalculateAdherence("Med", reminders, "2024-05-04")
+	if adherence.Taken != 2 || adherence.Due != 3 {
+		t.Errorf("Expected 2 of 3 doses taken, got %d of %d", adherence.Taken, adherence.Due)
+	}
+	if adherence.Percent() != 66 {
+		t.Errorf("Expected 66%%, got %d%%", adherence.Percent())
+	}
+}
+
+func TestCalculateDelivery(t *testing.T) {
+	doseEvents := []db.DoseEvent{
+		// Taken after a reminder
+		{Medication: "Med", Date: "2024-05-01", Type: db.DoseEventReminded, Source: db.ChannelDiscord},
+		{Medication: "Med", Date: "2024-05-01", Type: db.DoseEventAcknowledged, Source: "Discord"},
+		// Missed without any reminder arriving
+		{Medication: "Med", Date: "2024-05-02", Type: db.DoseEventUndelivered, Source: db.ChannelDiscord},
+		{Medication: "Med", Date: "2024-05-02", Type: db.DoseEventUndelivered, Source: "ntfy"},
+		{Medication: "Med", Date: "2024-05-02", Type: db.DoseEventMissed},
+		// Missed despite a reminder delivered by a backup channel
+		{Medication: "Med", Date: "2024-05-03", Type: db.DoseEventUndelivered, Source: db.ChannelDiscord},
+		{Medication: "Med", Date: "2024-05-03", Type: db.DoseEventReminded, Source: "email"},
+		{Medication: "Med", Date: "2024-05-03", Type: db.DoseEventMissed},
+		{Medication: "OtherMed", Date: "2024-05-01", Type: db.DoseEventMissed},
+	}
+
+	delivery := CalculateDelivery("Med", doseEvents)
+	if delivery.Sent != 2 || delivery.Failed != 3 || delivery.Fallback != 1 {
+		t.Errorf("Expected 2 reminders sent, 1 by a backup channel, and 3 failed, got %+v", delivery)
+	}
+	if delivery.Doses != 3 || delivery.Interacted != 1 {
+		t.Errorf("Expected 1 of 3 doses acted on, got %d of %d", delivery.Interacted, delivery.Doses)
+	}
+	if delivery.MissedUndelivered != 1 || delivery.MissedIgnored != 1 {
+		t.Errorf("Expected 1 missed dose without a reminder and 1 despite one, got %+v", delivery)
+	}
+	if delivery.Percent() != 40 {
+		t.Errorf("Expected 40%%, got %d%%", delivery.Percent())
+	}
+}
+
+func TestMissedDates(t *testing.T) {
+	reminders := []db.Reminder{
+		{Date: "2024-05-01", MedicationType: "Med", Acknowledged: false},
+		{Date: "2024-05-02", MedicationType: "Med", Acknowledged: true},
+		{Date: "2024-05-02", MedicationType: "OtherMed", Acknowledged: false},
+		{Date: "2024-05-03", MedicationType: "Med", Acknowledged: false},
+		{Date: "2024-05-04", MedicationType: "Med", Acknowledged: false},
+		{Date: "2024-05-02", MedicationType: "OtherMed", Skipped: true},
+	}
+
+	// The pending dose today isn't missed yet
+	missed := MissedDates("Med", reminders, "2024-05-04")
+	if len(missed) != 2 || missed[0] != "2024-05-01" || missed[1] != "2024-05-03" {
+		t.Errorf("Expected 2024-05-01 and 2024-05-03 to be missed, got %v", missed)
+	}
+}
+
+func TestStreak(t *testing.T) {
+	reminders := []db.Reminder{
+		{Date: "2024-05-01", MedicationType: "Med", Acknowledged: false},
+		{Date: "2024-05-02", MedicationType: "Med", Acknowledged: true},
+		{Date: "2024-05-02", MedicationType: "OtherMed", Acknowledged: false},
+		{Date: "2024-05-03", MedicationType: "Med", Skipped: true},
+		{Date: "2024-05-03", MedicationType: "Med", Acknowledged: true},
+		{Date: "2024-05-04", MedicationType: "Med", Acknowledged: false},
+	}
+
+	// The pending dose today and the skipped dose don't break the streak
+	if streak := Streak("Med", reminders, "2024-05-04"); streak != 2 {
+		t.Errorf("Expected a streak of 2, got %d", streak)
+	}
+
+	if streak := Streak("Med", reminders, "2024-05-05"); streak != 0 {
+		t.Errorf("Expected the missed dose to break the streak, got %d", streak)
+	}
+}
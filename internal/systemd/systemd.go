@@ -0,0 +1,86 @@
+// Package systemd implements the sd_notify protocol, telling systemd when the bot is ready
+// and petting its watchdog while the bot is healthy
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Notification states
+const (
+	Ready        = "READY=1"
+	Stopping     = "STOPPING=1"
+	WatchdogPing = "WATCHDOG=1"
+)
+
+// Notify sends a state to systemd. It does nothing if the bot isn't run by systemd with Type=notify.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// Sockets starting with @ are in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to systemd notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify systemd: %w", err)
+	}
+
+	return nil
+}
+
+// WatchdogInterval returns how often systemd expects the watchdog to be petted, or 0 if it isn't enabled
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Watchdog pets systemd's watchdog twice per interval until the context is cancelled, skipping it
+// while healthy returns false so systemd restarts the bot if it stays unhealthy
+func Watchdog(ctx context.Context, healthy func() bool) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !healthy() {
+				log.Println("Warning: Bot is unhealthy, not petting the systemd watchdog")
+				continue
+			}
+			if err := Notify(WatchdogPing); err != nil {
+				log.Printf("Error petting systemd watchdog: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package telemetry sends anonymous usage reports, to show which features are actually used. It's
+// strictly opt-in: nothing is sent unless TELEMETRY_ENABLED is set. Reports hold counts and feature
+// names only, never medication names, user or guild IDs, or message content.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Interval is how often a report is sent
+const Interval = 24 * time.Hour
+
+// installIDStateKey is the bot state key the install ID is kept under
+const installIDStateKey = "telemetry_install_id"
+
+// StateStore persists bot state, such as the install ID
+type StateStore interface {
+	GetState(ctx context.Context, key string) (string, error)
+	SetState(ctx context.Context, key, value string) error
+}
+
+// InstallID returns the random ID reports are sent under, generating one the first time
+func InstallID(ctx context.Context, store StateStore) (string, error) {
+	id, err := store.GetState(ctx, installIDStateKey)
+	if err != nil || id != "" {
+		return id, err
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	id = hex.EncodeToString(b)
+	if err := store.SetState(ctx, installIDStateKey, id); err != nil {
+		return "", fmt.Errorf("failed to save install ID: %w", err)
+	}
+	return id, nil
+}
+
+// Default is the usage packages count commands and errors in
+var Default = NewUsage()
+
+// Usage counts the commands used and errors shown since the last report
+type Usage struct {
+	mu       sync.Mutex
+	commands map[string]int
+	errors   map[string]int
+}
+
+// NewUsage returns empty usage counts
+func NewUsage() *Usage {
+	return &Usage{commands: make(map[string]int), errors: make(map[string]int)}
+}
+
+// Command counts a use of a command, by its name alone, e.g. "missed"
+func (u *Usage) Command(name string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.commands[name]++
+}
+
+// Error counts an error shown to a user, by its class, e.g. "conflict"
+func (u *Usage) Error(class string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.errors[class]++
+}
+
+// take returns the counts and starts counting again from zero
+func (u *Usage) take() (commands, errors map[string]int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	commands, errors = u.commands, u.errors
+	u.commands, u.errors = make(map[string]int), make(map[string]int)
+	return commands, errors
+}
+
+// restore adds back counts that couldn't be reported, so they're included in the next report
+func (u *Usage) restore(commands, errors map[string]int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for name, count := range commands {
+		u.commands[name] += count
+	}
+	for class, count := range errors {
+		u.errors[class] += count
+	}
+}
+
+// Report is the anonymous usage report posted as JSON
+type Report struct {
+	// InstallID is a random ID generated on first use, so reports from the same bot can be counted once
+	InstallID string `json:"install_id"`
+	Guilds    int    `json:"guilds"`
+	// Medications is the number of medications configured, as a range such as "2-5"
+	Medications string         `json:"medications"`
+	Features    []string       `json:"features"`
+	Commands    map[string]int `json:"commands"`
+	Errors      map[string]int `json:"errors"`
+}
+
+// Reporter sends usage reports to the telemetry endpoint
+type Reporter struct {
+	url         string
+	installID   string
+	guilds      func() int
+	medications int
+	features    []string
+	usage       *Usage
+	client      *http.Client
+}
+
+// NewReporter returns a reporter of the default usage counts, along with the guild count, the number
+// of medications configured and the names of the optional features turned on
+func NewReporter(url, installID string, guilds func() int, medications int, features []string) *Reporter {
+	return &Reporter{
+		url:         url,
+		installID:   installID,
+		guilds:      guilds,
+		medications: medications,
+		features:    features,
+		usage:       Default,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Run sends a report every interval until the context is cancelled, starting an interval after it's called
+func (r *Reporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Send(ctx); err != nil {
+				log.Printf("Warning: Failed to send usage report: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Send sends a report of the usage since the last one
+func (r *Reporter) Send(ctx context.Context) error {
+	commands, errors := r.usage.take()
+	report := Report{
+		InstallID:   r.installID,
+		Guilds:      r.guilds(),
+		Medications: Bucket(r.medications),
+		Features:    r.features,
+		Commands:    commands,
+		Errors:      errors,
+	}
+
+	if err := r.post(ctx, report); err != nil {
+		r.usage.restore(commands, errors)
+		return err
+	}
+
+	log.Printf("Debug: Sent usage report")
+	return nil
+}
+
+// post posts a report to the telemetry endpoint
+func (r *Reporter) post(ctx context.Context, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Bucket returns the range a count falls in, so a report doesn't reveal an exact number of medications
+func Bucket(count int) string {
+	switch {
+	case count <= 1:
+		return fmt.Sprint(count)
+	case count <= 5:
+		return "2-5"
+	case count <= 10:
+		return "6-10"
+	default:
+		return "11+"
+	}
+}
@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSend(t *testing.T) {
+	var received Report
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = Report{}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode report: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	reporter := NewReporter(server.URL, "install", func() int { return 2 }, 3, []string{"dashboard"})
+	reporter.usage = NewUsage()
+	reporter.usage.Command("missed")
+	reporter.usage.Command("missed")
+	reporter.usage.Error("conflict")
+
+	if err := reporter.Send(context.Background()); err != nil {
+		t.Fatalf("Failed to send report: %v", err)
+	}
+	if received.InstallID != "install" || received.Guilds != 2 || received.Medications != "2-5" {
+		t.Errorf("Unexpected report %+v", received)
+	}
+	if received.Commands["missed"] != 2 || received.Errors["conflict"] != 1 {
+		t.Errorf("Expected the usage counts, got %+v", received)
+	}
+
+	// Counts start again after a report, and are kept for the next one if it fails
+	reporter.usage.Command("stats")
+	status = http.StatusInternalServerError
+	if err := reporter.Send(context.Background()); err == nil {
+		t.Fatal("Expected an error from a failed report")
+	}
+	status = http.StatusNoContent
+	if err := reporter.Send(context.Background()); err != nil {
+		t.Fatalf("Failed to send report: %v", err)
+	}
+	if len(received.Commands) != 1 || received.Commands["stats"] != 1 {
+		t.Errorf("Expected only the command since the last report, got %v", received.Commands)
+	}
+}
+
+func TestBucket(t *testing.T) {
+	tests := map[int]string{0: "0", 1: "1", 2: "2-5", 5: "2-5", 6: "6-10", 11: "11+"}
+	for count, want := range tests {
+		if got := Bucket(count); got != want {
+			t.Errorf("Bucket(%d) = %s, want %s", count, got, want)
+		}
+	}
+}
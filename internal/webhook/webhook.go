@@ -0,0 +1,218 @@
+// Package webhook notifies a medication's own webhook, such as a clinic's portal or a caregiver's
+// service, when one of its doses is taken, skipped or missed. Only medications with a webhook configured are
+// reported, and each webhook only hears about its own medication.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/events"
+)
+
+// Types of dose notification
+const (
+	EventTaken   = "taken"
+	EventSkipped = "skipped"
+	EventMissed  = "missed"
+)
+
+// Timeout is how long a webhook has to respond
+const Timeout = 10 * time.Second
+
+// maxAttempts is how many times a notification is posted before it's given up on
+const maxAttempts = 4
+
+// retryDelay is how long to wait before posting a notification again the first time, which doubles
+// after each failed attempt
+const retryDelay = 5 * time.Second
+
+// queueSize is how many notifications can wait to be posted before more are dropped
+const queueSize = 64
+
+// Notification is the JSON body posted to a medication's webhook
+type Notification struct {
+	Event      string `json:"event"`
+	Medication string `json:"medication"`
+	// Date is the date (YYYY-MM-DD) of the dose
+	Date string `json:"date"`
+	// Source is where a taken dose was marked as taken, such as "Discord"
+	Source string `json:"source,omitempty"`
+	// Reason is why a skipped dose was skipped, if a reason was given
+	Reason string `json:"reason,omitempty"`
+	// Reminders is how many reminders were sent for a missed dose
+	Reminders int `json:"reminders,omitempty"`
+	// DoseID identifies the dose across notifications and the bot's logs
+	DoseID     string    `json:"dose_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Notifier posts dose notifications to medications' webhooks
+type Notifier struct {
+	medications map[string]config.Medication
+	client      *http.Client
+	now         func() time.Time
+	// retryDelay is how long to wait before the first retry
+	retryDelay time.Duration
+	// queue holds the notifications for events, so the event bus never waits on a webhook
+	queue chan Notification
+}
+
+// newNotifier creates a notifier for the medications that have a webhook
+func newNotifier(medications []config.Medication) *Notifier {
+	notifier := &Notifier{
+		medications: make(map[string]config.Medication),
+		client:      &http.Client{Timeout: Timeout},
+		now:         time.Now,
+		retryDelay:  retryDelay,
+		queue:       make(chan Notification, queueSize),
+	}
+	for _, medication := range medications {
+		if medication.WebhookURL != "" {
+			notifier.medications[medication.Name] = medication
+		}
+	}
+	return notifier
+}
+
+// Subscribe notifies the webhooks of the medications that have one when their doses are taken, skipped
+// or missed, and reports whether any medication has a webhook. Notifications are posted in the background
+// until the context is done, retrying webhooks that fail.
+func Subscribe(ctx context.Context, bus *events.Bus, medications []config.Medication) bool {
+	notifier := newNotifier(medications)
+	if len(notifier.medications) == 0 {
+		return false
+	}
+
+	events.On(bus, func(ctx context.Context, event events.DoseAcknowledged) error {
+		notifier.enqueue(Notification{
+			Event:      EventTaken,
+			Medication: event.Medication,
+			Date:       event.Date,
+			Source:     event.Source,
+			DoseID:     event.CorrelationID,
+		})
+		return nil
+	})
+	events.On(bus, func(ctx context.Context, event events.DoseSkipped) error {
+		notifier.enqueue(Notification{
+			Event:      EventSkipped,
+			Medication: event.Medication,
+			Date:       event.Date,
+			Reason:     event.Reason,
+			DoseID:     event.CorrelationID,
+		})
+		return nil
+	})
+	events.On(bus, func(ctx context.Context, event events.DoseMissed) error {
+		notifier.enqueue(Notification{
+			Event:      EventMissed,
+			Medication: event.Medication,
+			Date:       event.Date,
+			Reminders:  event.NagCount,
+			DoseID:     event.CorrelationID,
+		})
+		return nil
+	})
+	go notifier.notifyQueued(ctx)
+	return true
+}
+
+// enqueue queues a notification for its medication's webhook, if it has one, dropping it if the queue
+// is full. It's timestamped now, rather than when it's posted.
+func (n *Notifier) enqueue(notification Notification) {
+	if _, ok := n.medications[notification.Medication]; !ok {
+		return
+	}
+	if notification.OccurredAt.IsZero() {
+		notification.OccurredAt = n.now().UTC()
+	}
+
+	select {
+	case n.queue <- notification:
+	default:
+		log.Printf("Warning: Webhook notifications are behind, dropping the %s notification for %s [dose %s]", notification.Event, notification.Medication, notification.DoseID)
+	}
+}
+
+// notifyQueued posts the queued notifications in order until the context is done
+func (n *Notifier) notifyQueued(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-n.queue:
+			if err := n.Notify(ctx, notification); err != nil {
+				log.Printf("Error notifying webhook: %v", err)
+			}
+		}
+	}
+}
+
+// Notify posts a notification to its medication's webhook, if it has one. Webhooks that can't be reached,
+// or fail with a server error or too many requests, are tried again with a growing delay up to
+// maxAttempts times in all.
+func (n *Notifier) Notify(ctx context.Context, notification Notification) error {
+	medication, ok := n.medications[notification.Medication]
+	if !ok {
+		return nil
+	}
+	if notification.OccurredAt.IsZero() {
+		notification.OccurredAt = n.now().UTC()
+	}
+
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s webhook notification: %w", medication.Name, err)
+	}
+
+	delay := n.retryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(ctx, medication, notification, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt == maxAttempts {
+			return err
+		}
+
+		log.Printf("Warning: %v, trying again in %s", err, delay)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// post posts a notification's body to its medication's webhook once, reporting whether it's worth trying
+// again if it fails
+func (n *Notifier) post(ctx context.Context, medication config.Medication, notification Notification, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, medication.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s webhook request: %w", medication.Name, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range medication.WebhookHeaders {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to notify %s webhook of %s dose [dose %s]: %w", medication.Name, notification.Event, notification.DoseID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("%s webhook returned %s for %s dose [dose %s]", medication.Name, resp.Status, notification.Event, notification.DoseID)
+	}
+	return false, nil
+}
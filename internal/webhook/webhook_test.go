@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"meds-bot/internal/config"
+	"meds-bot/internal/events"
+)
+
+func TestSubscribe(t *testing.T) {
+	notifications := make(chan Notification, 3)
+	authorizations := make(chan string, 3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification Notification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			t.Errorf("Failed to decode notification: %v", err)
+		}
+		notifications <- notification
+		authorizations <- r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := events.NewBus()
+	subscribed := Subscribe(ctx, bus, []config.Medication{
+		{Name: "Heart Pill", WebhookURL: server.URL, WebhookHeaders: map[string]string{"Authorization": "Bearer clinic"}},
+		{Name: "Vitamin D"},
+	})
+	if !subscribed {
+		t.Fatal("Expected a webhook to be subscribed")
+	}
+
+	if err := bus.Publish(ctx, events.DoseAcknowledged{Medication: "Heart Pill", Date: "2024-01-01", Source: "Discord", CorrelationID: "a1"}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	if err := bus.Publish(ctx, events.DoseMissed{Medication: "Heart Pill", Date: "2024-01-02", NagCount: 3, CorrelationID: "b2"}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	// Medications without a webhook stay private
+	if err := bus.Publish(ctx, events.DoseAcknowledged{Medication: "Vitamin D", Date: "2024-01-01", CorrelationID: "c3"}); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	// Notifications are posted in the background, in order
+	var received []Notification
+	for len(received) < 2 {
+		select {
+		case notification := <-notifications:
+			received = append(received, notification)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected 2 notifications, got %d", len(received))
+		}
+	}
+	if got := received[0]; got.Event != EventTaken || got.Source != "Discord" || got.DoseID != "a1" || got.OccurredAt.IsZero() {
+		t.Errorf("Unexpected taken notification %+v", got)
+	}
+	if got := received[1]; got.Event != EventMissed || got.Reminders != 3 || got.Date != "2024-01-02" {
+		t.Errorf("Unexpected missed notification %+v", got)
+	}
+	if authorization := <-authorizations; authorization != "Bearer clinic" {
+		t.Errorf("Expected the configured headers, got Authorization %q", authorization)
+	}
+
+	select {
+	case notification := <-notifications:
+		t.Errorf("Expected no notification for a medication without a webhook, got %+v", notification)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSubscribeWithoutWebhooks(t *testing.T) {
+	if Subscribe(context.Background(), events.NewBus(), []config.Medication{{Name: "Vitamin D"}}) {
+		t.Error("Expected nothing to be subscribed without webhooks")
+	}
+}
+
+// TestNotifyRetries tests that failing webhooks are tried again a bounded number of times, unless they
+// reject the notification
+func TestNotifyRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "Recovers", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, wantAttempts: 3},
+		{name: "Keeps failing", statuses: []int{http.StatusServiceUnavailable}, wantAttempts: maxAttempts, wantErr: true},
+		{name: "Rejected", statuses: []int{http.StatusBadRequest}, wantAttempts: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := int(attempts.Add(1))
+				w.WriteHeader(tt.statuses[min(attempt, len(tt.statuses))-1])
+			}))
+			defer server.Close()
+
+			notifier := newNotifier([]config.Medication{{Name: "Heart Pill", WebhookURL: server.URL}})
+			notifier.retryDelay = time.Millisecond
+
+			err := notifier.Notify(context.Background(), Notification{Event: EventMissed, Medication: "Heart Pill", Date: "2024-01-01"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Notify() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := int(attempts.Load()); got != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.wantAttempts, got)
+			}
+		})
+	}
+}
+
+// TestNotifyFailure tests that a failing webhook doesn't fail the event it's notified of
+func TestNotifyFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := events.NewBus()
+	Subscribe(ctx, bus, []config.Medication{{Name: "Heart Pill", WebhookURL: server.URL}})
+
+	if err := bus.Publish(ctx, events.DoseMissed{Medication: "Heart Pill", Date: "2024-01-01"}); err != nil {
+		t.Errorf("Expected the event to be handled while the webhook is retried, got %v", err)
+	}
+}
@@ -0,0 +1,11 @@
+// Package winservice installs, controls and runs the bot as a Windows service, logging to the Windows event log
+package winservice
+
+// Name is the name the service is installed under, which is also its event log source
+const Name = "meds-bot"
+
+// displayName is the name shown in the Services console
+const displayName = "Medication Reminder Bot"
+
+// RunFunc runs the bot until stop is closed, returning its exit code
+type RunFunc func(stop <-chan struct{}) int
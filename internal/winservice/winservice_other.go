@@ -0,0 +1,23 @@
+//go:build !windows
+
+package winservice
+
+import "errors"
+
+// ErrUnsupported is returned on platforms other than Windows
+var ErrUnsupported = errors.New("Windows services are only supported on Windows")
+
+// Install installs the service, running the executable at exePath
+func Install(exePath string) error { return ErrUnsupported }
+
+// Uninstall removes the service
+func Uninstall() error { return ErrUnsupported }
+
+// Start starts the installed service
+func Start() error { return ErrUnsupported }
+
+// Stop stops the running service
+func Stop() error { return ErrUnsupported }
+
+// Run runs the bot as the service, when started by the service manager
+func Run(run RunFunc) error { return ErrUnsupported }
@@ -0,0 +1,205 @@
+//go:build windows
+
+package winservice
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// stopTimeout is how long Stop waits for the service to stop
+const stopTimeout = 30 * time.Second
+
+// Install installs the service, running the executable at exePath, and registers its event log source
+func Install(exePath string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(Name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s is already installed", Name)
+	}
+
+	s, err := m.CreateService(Name, exePath, mgr.Config{
+		DisplayName: displayName,
+		Description: "Sends medication reminders to Discord",
+		StartType:   mgr.StartAutomatic,
+	}, "service", "run")
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer s.Close()
+
+	if err := eventlog.InstallAsEventCreate(Name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return fmt.Errorf("failed to register event log source: %w", err)
+	}
+
+	return nil
+}
+
+// Uninstall removes the service and its event log source
+func Uninstall() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(Name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %w", Name, err)
+	}
+	defer s.Close()
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+
+	if err := eventlog.Remove(Name); err != nil {
+		return fmt.Errorf("failed to remove event log source: %w", err)
+	}
+
+	return nil
+}
+
+// Start starts the installed service
+func Start() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(Name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %w", Name, err)
+	}
+	defer s.Close()
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+
+	return nil
+}
+
+// Stop stops the running service, waiting for it to shut down
+func Stop() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(Name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %w", Name, err)
+	}
+	defer s.Close()
+
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return fmt.Errorf("failed to stop service: %w", err)
+	}
+
+	deadline := time.Now().Add(stopTimeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return fmt.Errorf("failed to query service status: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Run runs the bot as the service, when started by the service manager, logging to the event log
+func Run(run RunFunc) error {
+	elog, err := eventlog.Open(Name)
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer elog.Close()
+
+	log.SetOutput(&eventLogWriter{elog: elog})
+	// The event log records the time of each entry
+	log.SetFlags(log.Lshortfile)
+
+	if err := svc.Run(Name, &handler{run: run}); err != nil {
+		return fmt.Errorf("failed to run service: %w", err)
+	}
+
+	return nil
+}
+
+// handler runs the bot in response to service manager requests
+type handler struct {
+	run RunFunc
+}
+
+// Execute runs the bot until the service manager asks it to stop
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	stop := make(chan struct{})
+	exitCode := make(chan int, 1)
+	go func() {
+		exitCode <- h.run(stop)
+	}()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case code := <-exitCode:
+			// The bot failed to start
+			return code != 0, uint32(code)
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				changes <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				close(stop)
+				return false, uint32(<-exitCode)
+			}
+		}
+	}
+}
+
+// eventLogWriter writes log lines to the event log, as errors or warnings depending on their prefix
+type eventLogWriter struct {
+	elog *eventlog.Log
+}
+
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	message := strings.TrimSpace(string(p))
+
+	var err error
+	switch {
+	case strings.Contains(message, "Error") || strings.Contains(message, "Failed"):
+		err = w.elog.Error(1, message)
+	case strings.Contains(message, "Warning"):
+		err = w.elog.Warning(1, message)
+	default:
+		err = w.elog.Info(1, message)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
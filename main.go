@@ -3,98 +3,465 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
+	"meds-bot/internal/ackhook"
+	"meds-bot/internal/acklink"
+	"meds-bot/internal/assistant"
+	"meds-bot/internal/caldav"
 	"meds-bot/internal/config"
+	"meds-bot/internal/dashboard"
 	"meds-bot/internal/db"
 	"meds-bot/internal/discord"
+	"meds-bot/internal/eventlog"
+	"meds-bot/internal/events"
+	"meds-bot/internal/export"
+	"meds-bot/internal/failover"
+	"meds-bot/internal/graphapi"
+	"meds-bot/internal/grpcapi"
+	"meds-bot/internal/homeassistant"
+	"meds-bot/internal/httpserver"
+	"meds-bot/internal/loadtest"
+	"meds-bot/internal/logfile"
+	"meds-bot/internal/logging"
+	"meds-bot/internal/metrics"
 	"meds-bot/internal/reminder"
+	"meds-bot/internal/replication"
+	"meds-bot/internal/statearchive"
+	"meds-bot/internal/systemd"
+	"meds-bot/internal/telemetry"
+	"meds-bot/internal/webhook"
+	"meds-bot/internal/winservice"
+
+	"google.golang.org/grpc"
 )
 
-// run is the main application function that returns the reminder service and any error
-func run(ctx context.Context) (reminder.ServiceInterface, error) {
-	log.Println("Starting medication reminder bot...")
+// Exit codes for startup failures, so supervisors such as systemd and Kubernetes restart the bot
+// and can tell configuration mistakes from outages. Configuration mistakes use sysexits' EX_CONFIG,
+// which supervisors can be told not to restart on, since restarting won't fix them.
+const (
+	exitFailure  = 1
+	exitDatabase = 3
+	exitDiscord  = 4
+	exitConfig   = 78
+)
 
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load configuration: %w", err)
+// startupError is a startup failure and the exit code it's reported with
+type startupError struct {
+	code int
+	err  error
+}
+
+func (e *startupError) Error() string { return e.err.Error() }
+
+func (e *startupError) Unwrap() error { return e.err }
+
+// exitCode returns the exit code for a startup failure
+func exitCode(err error) int {
+	var startupErr *startupError
+	if errors.As(err, &startupErr) {
+		return startupErr.code
 	}
+	return exitFailure
+}
+
+// retryable reports whether a startup failure may be transient, i.e. connecting to the database or Discord
+func retryable(err error) bool {
+	code := exitCode(err)
+	return code == exitDatabase || code == exitDiscord
+}
+
+// startWithRetry runs the application, retrying transient startup failures with exponential backoff
+func startWithRetry(ctx context.Context, cfg *config.Config, logFilter *logging.Filter) (reminder.ServiceInterface, error) {
+	for attempt := 0; ; attempt++ {
+		service, err := run(ctx, cfg, logFilter)
+		if err == nil || !retryable(err) || attempt >= cfg.StartupRetries {
+			return service, err
+		}
 
+		backoff := cfg.GetStartupBackoff(attempt)
+		log.Printf("Error starting, retrying in %v (%d/%d): %v", backoff, attempt+1, cfg.StartupRetries, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+// run is the main application function that returns the reminder service and any error.
+// Everything opened is closed again if startup fails, so it can be retried.
+func run(ctx context.Context, cfg *config.Config, logFilter *logging.Filter) (service reminder.ServiceInterface, err error) {
 	// Get the location from the config
 	loc, err := cfg.GetLocation()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get timezone location: %w", err)
+		return nil, &startupError{exitConfig, fmt.Errorf("failed to get timezone location: %w", err)}
 	}
 
-	store, err := db.NewStore(ctx, cfg.DBPath, loc)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	var baseStore db.StoreInterface
+	switch cfg.DBDriver {
+	case config.DBDriverMemory:
+		log.Println("Using the in-memory store, nothing will be saved when the bot exits")
+		baseStore = db.NewMemoryStore(loc)
+	case config.DBDriverPostgres:
+		baseStore, err = openPostgresStore(ctx, cfg, loc)
+		if err != nil {
+			return nil, &startupError{exitDatabase, err}
+		}
+	default:
+		baseStore, err = openSQLiteStore(ctx, cfg, loc)
+		if err != nil {
+			return nil, &startupError{exitDatabase, err}
+		}
 	}
 	defer func() {
-		if ctx.Err() != nil {
-			if err := store.Close(); err != nil {
+		if err != nil || ctx.Err() != nil {
+			if err := baseStore.Close(); err != nil {
 				log.Printf("Error closing database: %v", err)
 			}
 		}
 	}()
 
-	discordClient, err := discord.NewClient(ctx, cfg, store)
+	// Today's reminders are read on every tick and button click, so they're cached
+	store := db.NewCachedStore(baseStore, db.DefaultCacheTTL)
+
+	// Signed acknowledgment links are optional
+	var signer *acklink.Signer
+	if cfg.AckLinksEnabled() {
+		signer = acklink.NewSigner(cfg.PublicURL, cfg.AckLinkSecret, cfg.GetAckLinkTTL())
+	}
+
+	bus := events.NewBus()
+	eventlog.Subscribe(bus, store)
+	if webhook.Subscribe(ctx, bus, cfg.Medications) {
+		log.Println("Notifying medication webhooks of doses taken and missed")
+	}
+
+	discordClient, err := discord.NewClient(ctx, cfg, store, signer, bus, logFilter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize Discord client: %w", err)
+		return nil, &startupError{exitDiscord, fmt.Errorf("failed to initialize Discord client: %w", err)}
 	}
 	defer func() {
-		if ctx.Err() != nil {
+		if err != nil || ctx.Err() != nil {
 			if err := discordClient.Close(); err != nil {
 				log.Printf("Error closing Discord client: %v", err)
 			}
 		}
 	}()
 
-	reminderService := reminder.NewService(cfg, store, discordClient)
+	discordClient.Start(ctx)
+
+	// Backup channels subscribe after Discord, so they know whether Discord sent each reminder
+	if failoverChain := failover.New(cfg, discordClient, store, signer, bus, loc); failoverChain != nil {
+		failoverChain.Start(ctx)
+		log.Printf("Reminders fail over to %s while Discord is down", strings.Join(cfg.FailoverChannels, ", then "))
+	}
+
+	if cfg.MQTTBrokerURL != "" {
+		bridge := homeassistant.NewBridge(cfg, store, discordClient, loc)
+		bridge.Start(ctx, bus)
+		defer func() {
+			if err != nil {
+				bridge.Close()
+			}
+		}()
+	}
+
+	reminderService := reminder.NewService(cfg, store, bus)
+	// Doses are dated where they're taken, so medications in other timezones get a new reminder at their own midnight
+	store.Calendar().SetLocator(reminderService.DoseLocation)
 
 	if err := reminderService.Start(ctx); err != nil {
 		return nil, fmt.Errorf("failed to start reminder service: %w", err)
 	}
+	defer func() {
+		if err != nil {
+			reminderService.Stop()
+		}
+	}()
+
+	// API and acknowledgment link requests share one rate limiter
+	rateLimit := func(handler http.Handler) http.Handler { return handler }
+	if cfg.RateLimitPerMinute > 0 {
+		limiter := httpserver.NewRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst)
+		rateLimit = httpserver.RateLimit(limiter, cfg.RateLimitTrustProxy)
+	}
+
+	handlers := map[string]http.Handler{
+		reminder.JobsPath:  reminderService.Jobs(),
+		metrics.Path:       metrics.Default,
+		export.OpenAPIPath: export.OpenAPIHandler(),
+	}
+	if signer != nil {
+		handlers[acklink.Path] = rateLimit(acklink.NewHandler(signer, discordClient, store.Calendar()))
+		handlers[acklink.TagPath] = rateLimit(acklink.NewTagHandler(signer, store, cfg.Medications, discordClient, loc))
+	}
+	if cfg.AckHookToken != "" {
+		handlers[ackhook.Path] = rateLimit(ackhook.NewHandler(cfg.AckHookToken, discordClient, loc))
+	}
+	if cfg.AssistantToken != "" {
+		handlers[assistant.Path] = rateLimit(assistant.NewHandler(cfg.AssistantToken, cfg.Medications, store, discordClient, loc))
+	}
+	if cfg.ExportToken != "" {
+		handlers[export.DosesPath] = rateLimit(export.NewHandler(store, cfg.ExportToken, loc))
+		handlers[export.MedicationsPath] = rateLimit(export.NewMedicationsHandler(store, cfg.ExportToken))
+		handlers[export.RefillsPath] = rateLimit(export.NewRefillsHandler(store, cfg.ExportToken, loc))
+		handlers[export.EventsPath] = rateLimit(export.NewEventsHandler(store, cfg.ExportToken, loc))
+		handlers[export.FeedPath] = rateLimit(export.NewFeedHandler(store, cfg.ExportToken, loc))
+	}
+	if cfg.AdminToken != "" {
+		handlers[logging.Path] = rateLimit(requireToken(cfg.AdminToken, logFilter))
+	}
+	if cfg.DashboardEnabled() {
+		access := dashboard.Access{GuildID: cfg.DashboardGuildID, UserIDs: cfg.DashboardAllowedUsers}
+		if cfg.DiscordUserIDToPing != "" {
+			access.UserIDs = append(access.UserIDs, cfg.DiscordUserIDToPing)
+		}
+		for _, medication := range cfg.Medications {
+			if medication.UserID != "" && !slices.Contains(access.UserIDs, medication.UserID) {
+				access.UserIDs = append(access.UserIDs, medication.UserID)
+			}
+		}
+		dashboardHandler := rateLimit(dashboard.NewHandler(store, cfg.Medications, discordClient, loc, dashboard.Options{
+			ClientID:      cfg.DiscordClientID,
+			ClientSecret:  cfg.DiscordClientSecret,
+			SessionSecret: cfg.DashboardSessionSecret,
+			PublicURL:     cfg.PublicURL,
+			Access:        access,
+		}))
+		handlers[dashboard.Path] = dashboardHandler
+		handlers[dashboard.Path+"/"] = dashboardHandler
+	}
+	if cfg.GraphQLEnabled {
+		graphQLHandler, err := graphapi.NewHandler(store, cfg.Medications, cfg.ExportToken, loc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize GraphQL API: %w", err)
+		}
+		handlers[graphapi.Path] = rateLimit(graphQLHandler)
+	}
+	if cfg.CalDAVEnabled {
+		calendarHandler := rateLimit(caldav.NewHandler(store, cfg.Medications, cfg.ExportToken, loc))
+		handlers[caldav.Path] = calendarHandler
+		handlers[caldav.WellKnownPath] = calendarHandler
+	}
 
 	// Start health check server
-	healthServer := startHealthServer()
+	healthServer := startHealthServer(cfg, handlers)
 	defer func() {
-		if ctx.Err() != nil {
+		if err != nil || ctx.Err() != nil {
 			if err := healthServer.Shutdown(ctx); err != nil {
 				log.Printf("Error shutting down health server: %v", err)
 			}
 		}
 	}()
 
+	if cfg.GRPCAddr != "" {
+		service := grpcapi.NewServer(store, cfg.Medications, discordClient, loc, bus)
+		if err := startGRPCServer(ctx, cfg.GRPCAddr, grpcapi.NewGRPCServer(service, cfg.ExportToken, cfg.GRPCWriteToken)); err != nil {
+			return nil, fmt.Errorf("failed to start gRPC server: %w", err)
+		}
+	}
+
+	// systemd restarts the bot if it stays disconnected from Discord for longer than its watchdog timeout
+	go systemd.Watchdog(ctx, discordClient.Connected)
+
+	// Usage reports are opt-in, and failing to set them up doesn't stop the bot
+	if cfg.TelemetryEnabled {
+		installID, err := telemetry.InstallID(ctx, store)
+		if err != nil {
+			log.Printf("Error getting telemetry install ID, usage reports are disabled: %v", err)
+		} else {
+			reporter := telemetry.NewReporter(cfg.TelemetryURL, installID, discordClient.GuildCount, len(cfg.Medications), telemetryFeatures(cfg))
+			go reporter.Run(ctx, telemetry.Interval)
+			log.Printf("Sending anonymous usage reports to %s", cfg.TelemetryURL)
+		}
+	}
+
 	return reminderService, nil
 }
 
-// startHealthServer starts a simple HTTP server with health check endpoints
-func startHealthServer() *http.Server {
-	mux := http.NewServeMux()
+// telemetryFeatures returns the names of the optional features turned on, for usage reports
+func telemetryFeatures(cfg *config.Config) []string {
+	features := []string{"reminder_mode_" + cfg.ReminderMode}
+	add := func(name string, enabled bool) {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+
+	add("ack_links", cfg.AckLinksEnabled())
+	add("ack_hook", cfg.AckHookToken != "")
+	add("voice_assistant", cfg.AssistantToken != "")
+	add("qr_codes", cfg.ReminderQRCode)
+	add("export", cfg.ExportToken != "")
+	add("dashboard", cfg.DashboardEnabled())
+	add("graphql", cfg.GraphQLEnabled)
+	add("caldav", cfg.CalDAVEnabled)
+	add("failover", len(cfg.FailoverChannels) > 0)
+	add("grpc", cfg.GRPCAddr != "")
+	add("memory_store", cfg.DBDriver == config.DBDriverMemory)
+	add("postgres_store", cfg.DBDriver == config.DBDriverPostgres)
+	add("litestream", cfg.LitestreamReplicaURL != "")
+	add("read_replica", cfg.DBReadDSN != "")
+	add("archive_channel", cfg.ArchiveChannelID != "")
+	add("message_retention", cfg.MessageRetentionDays > 0)
+	add("minimal_permissions", cfg.MinimalPermissions)
+	add("token_file", cfg.DiscordTokenFile != "")
+	add("guild_onboarding", cfg.GuildOnboarding)
+	add("encouragement", cfg.Encouragement)
+	add("accessible_reminders", cfg.AccessibleReminders)
+	add("reminder_sound", cfg.ReminderSound != "")
+	add("log_file", cfg.LogFile != "")
+	add("rate_limit", cfg.RateLimitPerMinute > 0)
+	add("command_cooldowns", cfg.CommandCooldownSecs > 0 || cfg.GuildCommandCooldownSecs > 0)
+	add("sleep_in", cfg.SleepInDeferHours > 0)
+	add("presence_routing", cfg.PresenceRouting)
+	add("home_assistant", cfg.MQTTBrokerURL != "")
+	for _, medication := range cfg.Medications {
+		sunAnchored := medication.Anchor == config.AnchorSunrise || medication.Anchor == config.AnchorSunset
+		add("sun_anchor", sunAnchored && !slices.Contains(features, "sun_anchor"))
+		add("wake_anchor", medication.Anchor == config.AnchorWake && !slices.Contains(features, "wake_anchor"))
+		add("heads_up", medication.LeadTimeMins > 0 && !slices.Contains(features, "heads_up"))
+	}
+
+	return features
+}
+
+// requireToken only serves requests with the token in their Authorization header
+func requireToken(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !export.HeaderAuthorized(r, token) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// openSQLiteStore opens the SQLite database, restoring it from its Litestream replica first if it's missing,
+// and its read replica if one is configured
+func openSQLiteStore(ctx context.Context, cfg *config.Config, loc *time.Location) (db.StoreInterface, error) {
+	dsn := cfg.DBPath
+	if cfg.LitestreamReplicaURL != "" {
+		if _, err := replication.Restore(ctx, cfg.DBPath, cfg.LitestreamReplicaURL); err != nil {
+			return nil, err
+		}
+		dsn = replication.DSN(cfg.DBPath)
+	}
+
+	store, err := db.NewStore(ctx, dsn, loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	if cfg.DBReadDSN != "" {
+		if err := store.OpenReadReplica(ctx, cfg.DBReadDSN); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+
+	if cfg.LitestreamReplicaURL != "" {
+		return &checkpointingStore{Store: store}, nil
+	}
+
+	return store, nil
+}
+
+// openPostgresStore opens the Postgres database, applying any migrations it hasn't had, and its read
+// replica if one is configured
+func openPostgresStore(ctx context.Context, cfg *config.Config, loc *time.Location) (db.StoreInterface, error) {
+	store, err := db.NewPostgresStore(ctx, cfg.DatabaseURL, loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	if cfg.DBReadDSN != "" {
+		if err := store.OpenReadReplica(ctx, cfg.DBReadDSN); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// checkpointingStore checkpoints the database before closing it, since automatic checkpoints
+// are turned off while Litestream replicates it
+type checkpointingStore struct {
+	*db.Store
+}
+
+func (s *checkpointingStore) Close() error {
+	if err := s.Checkpoint(context.Background()); err != nil {
+		log.Printf("Error checkpointing database: %v", err)
+	}
+	return s.Store.Close()
+}
+
+// startGRPCServer serves the gRPC API until the context is cancelled
+func startGRPCServer(ctx context.Context, addr string, server *grpc.Server) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("gRPC server error: %v", err)
+		}
+	}()
+
+	// Stop rather than drain, since reminder watchers stay connected indefinitely
+	go func() {
+		<-ctx.Done()
+		server.Stop()
+	}()
+
+	log.Printf("gRPC server started on %s", addr)
+	return nil
+}
+
+// startHealthServer starts the HTTP server with health check endpoints
+// and any additional handlers
+func startHealthServer(cfg *config.Config, handlers map[string]http.Handler) *http.Server {
+	builder := httpserver.NewBuilder(cfg.HTTPAddr).
+		WithTimeouts(
+			time.Duration(cfg.HTTPReadTimeoutSecs)*time.Second,
+			time.Duration(cfg.HTTPWriteTimeoutSecs)*time.Second,
+			time.Duration(cfg.HTTPIdleTimeoutSecs)*time.Second,
+		).
+		Use(httpserver.Logging, httpserver.Recover, httpserver.CORS(cfg.CORSAllowedOrigins), httpserver.Gzip)
+
+	for path, handler := range handlers {
+		builder.Handle(path, handler)
+	}
 
 	// Health check endpoint
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	builder.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
 
 	// Readiness endpoint
-	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+	builder.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Ready"))
 	})
 
-	server := &http.Server{
-		Addr:    ":8080",
-		Handler: mux,
-	}
+	server := builder.Build()
 
 	go func() {
 		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -102,55 +469,272 @@ func startHealthServer() *http.Server {
 		}
 	}()
 
-	log.Println("Health check server started on :8080")
+	log.Printf("HTTP server started on %s", cfg.HTTPAddr)
 	return server
 }
 
-func main() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+// runLoadTest runs the loadtest command, which simulates a large deployment against a stub notifier
+func runLoadTest(args []string) error {
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	opts := loadtest.Options{}
+	flags.IntVar(&opts.Guilds, "guilds", 10, "number of simulated guilds")
+	flags.IntVar(&opts.Medications, "medications", 5, "medications per guild")
+	flags.IntVar(&opts.Ticks, "ticks", 20, "number of reminder checks to run")
+	flags.IntVar(&opts.Workers, "workers", 4, "reminders sent at once")
+	flags.DurationVar(&opts.Latency, "latency", 50*time.Millisecond, "time the stub notifier takes to send a reminder")
+	flags.StringVar(&opts.DBPath, "db", "", "database to use, a temporary one by default, or \"memory\" for the in-memory store")
+	flags.BoolVar(&opts.Cache, "cache", true, "cache today's reminders in memory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := loadtest.Run(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+
+	report.Write(os.Stdout)
+	return nil
+}
+
+// runExportState runs the export-state command, which writes the database and settings to one archive
+// for moving the bot to another host
+func runExportState(args []string) error {
+	flags := flag.NewFlagSet("export-state", flag.ContinueOnError)
+	output := flags.String("o", "meds-bot-state.tar.gz", "archive to write")
+	settings := flags.String("env", ".env", "settings file to include, left out if it doesn't exist")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if cfg.DBDriver == config.DBDriverMemory {
+		return fmt.Errorf("the in-memory store has no state to export")
+	}
+	if cfg.DBDriver == config.DBDriverPostgres {
+		return fmt.Errorf("export-state only exports SQLite databases, back up a Postgres database with pg_dump")
+	}
+	loc, err := cfg.GetLocation()
+	if err != nil {
+		return fmt.Errorf("failed to get timezone location: %w", err)
+	}
+
+	ctx := context.Background()
+	store, err := db.NewStore(ctx, cfg.DBPath, loc)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer store.Close()
+
+	// The snapshot is consistent even while the bot is running
+	dir, err := os.MkdirTemp("", "meds-bot-export")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	snapshot := filepath.Join(dir, "meds.db")
+	if err := store.Snapshot(ctx, snapshot); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", *output, err)
+	}
+	manifest, err := statearchive.Write(f, snapshot, *settings)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write %s: %w", *output, closeErr)
+	}
+	if err != nil {
+		os.Remove(*output)
+		return err
+	}
+
+	if !manifest.Settings {
+		log.Printf("Warning: Settings file %s not found, only the database was exported", *settings)
+	}
+	log.Printf("Exported state to %s", *output)
+	return nil
+}
+
+// runImportState runs the import-state command, which restores the database and settings from an
+// archive written by export-state. The bot must not be running.
+func runImportState(args []string) error {
+	flags := flag.NewFlagSet("import-state", flag.ContinueOnError)
+	dbPath := flags.String("db", "./meds_reminder.db", "database to write")
+	settings := flags.String("env", ".env", "settings file to write")
+	force := flags.Bool("force", false, "replace an existing database and settings file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: meds-bot import-state [-db path] [-env path] [-force] <archive>")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	manifest, err := statearchive.Read(f, *dbPath, *settings, *force)
+	if errors.Is(err, statearchive.ErrExists) {
+		return fmt.Errorf("%w, use -force to replace it", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Imported state exported at %s to %s", manifest.CreatedAt.Format(time.RFC3339), *dbPath)
+	if manifest.Settings {
+		log.Printf("Imported settings to %s, check DB_PATH and any file paths in it match this host", *settings)
+	}
+	return nil
+}
+
+// runTokenCommand runs the token command, which creates and revokes long-lived acknowledgment links
+// for an NFC tag on a pill bottle or a phone shortcut
+func runTokenCommand(args []string) error {
+	usage := fmt.Errorf("usage: meds-bot token ack-link [-revoke] <medication>")
+	if len(args) == 0 || args[0] != "ack-link" {
+		return usage
+	}
+
+	flags := flag.NewFlagSet("token ack-link", flag.ContinueOnError)
+	revoke := flags.Bool("revoke", false, "revoke the medication's links, so they stop working")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return usage
+	}
+	name := flags.Arg(0)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if !cfg.AckLinksEnabled() {
+		return fmt.Errorf("tag links need PUBLIC_URL and ACK_LINK_SECRET to be set")
+	}
+	if !slices.ContainsFunc(cfg.Medications, func(medication config.Medication) bool {
+		return medication.Name == name || medication.Base() == name
+	}) {
+		return fmt.Errorf("unknown medication: %s", name)
+	}
+	loc, err := cfg.GetLocation()
+	if err != nil {
+		return fmt.Errorf("failed to get timezone location: %w", err)
+	}
+
+	// Links are checked against the medication's generation kept in the database
+	ctx := context.Background()
+	var store *db.Store
+	switch cfg.DBDriver {
+	case config.DBDriverMemory:
+		return fmt.Errorf("the in-memory store can't keep tag links")
+	case config.DBDriverPostgres:
+		store, err = db.NewPostgresStore(ctx, cfg.DatabaseURL, loc)
+	default:
+		store, err = db.NewStore(ctx, cfg.DBPath, loc)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer store.Close()
+
+	if *revoke {
+		if _, err := acklink.RevokeTags(ctx, store, name); err != nil {
+			return err
+		}
+		log.Printf("Revoked the tag links of %s, create a new one with: meds-bot token ack-link %q", name, name)
+		return nil
+	}
+
+	generation, err := acklink.TagGeneration(ctx, store, name)
+	if err != nil {
+		return err
+	}
+	signer := acklink.NewSigner(cfg.PublicURL, cfg.AckLinkSecret, cfg.GetAckLinkTTL())
+	// The link is printed on its own so it can be piped to an NFC writer or QR code generator
+	fmt.Println(signer.TagURL(name, generation))
+	return nil
+}
+
+// runBot starts the bot and runs it until stop is closed, returning the exit code
+func runBot(stop <-chan struct{}) int {
+	log.Println("Starting medication reminder bot...")
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return exitConfig
+	}
+
+	if cfg.LogFile != "" {
+		logFile, err := logfile.New(cfg.LogFile, logfile.Options{
+			MaxSize:    int64(cfg.LogFileMaxSizeMB) * 1024 * 1024,
+			MaxBackups: cfg.LogFileMaxBackups,
+			MaxAge:     time.Duration(cfg.LogFileMaxAgeDays) * 24 * time.Hour,
+		})
+		if err != nil {
+			log.Printf("Failed to open log file: %v", err)
+			return exitConfig
+		}
+		output := log.Writer()
+		log.SetOutput(io.MultiWriter(output, logFile))
+		defer func() {
+			log.SetOutput(output)
+			logFile.Close()
+		}()
+	}
+
+	// The levels were validated when loading the configuration
+	logLevel, _ := logging.ParseLevel(cfg.LogLevel)
+	logLevels, _ := logging.ParseLevels(cfg.LogLevels)
+	logOutput := log.Writer()
+	logFilter := logging.NewFilter(logOutput, logLevel, logLevels)
+	log.SetOutput(logFilter)
+	defer log.SetOutput(logOutput)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Create a channel to track the reminder service
-	var reminderService reminder.ServiceInterface
-	serviceReady := make(chan reminder.ServiceInterface)
-
-	// Run the application in a goroutine
+	// Stopping while starting up abandons any startup retries
 	go func() {
-		service, err := run(ctx)
-		if err != nil {
-			log.Printf("Application error: %v", err)
-			cancel() // Cancel the context to signal shutdown
-			serviceReady <- nil
-			return
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
 		}
-		serviceReady <- service
 	}()
 
-	// Wait for the service to be ready
-	reminderService = <-serviceReady
-	if reminderService == nil {
-		log.Println("Failed to start application")
-		return
+	reminderService, err := startWithRetry(ctx, cfg, logFilter)
+	if err != nil {
+		log.Printf("Failed to start application: %v", err)
+		return exitCode(err)
 	}
 
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	if err := systemd.Notify(systemd.Ready); err != nil {
+		log.Printf("Error notifying systemd: %v", err)
+	}
 
 	log.Println("Medication reminder bot is now running. Press CTRL-C to exit.")
 
-	sig := <-sigCh
-	log.Printf("Received signal %v, initiating graceful shutdown...", sig)
+	<-stop
+	if err := systemd.Notify(systemd.Stopping); err != nil {
+		log.Printf("Error notifying systemd: %v", err)
+	}
 
 	// Cancel the context to signal all components to shut down
 	cancel()
 
 	// Stop the reminder service explicitly
-	if reminderService != nil {
-		log.Println("Stopping reminder service...")
-		reminderService.Stop()
-	}
+	log.Println("Stopping reminder service...")
+	reminderService.Stop()
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
@@ -164,4 +748,91 @@ func main() {
 			log.Println("Graceful shutdown timed out, forcing exit")
 		}
 	}
+
+	return 0
+}
+
+// runServiceCommand runs the service command, which installs, controls and runs the bot as a Windows service
+func runServiceCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: meds-bot service install|uninstall|start|stop|run")
+	}
+
+	switch args[0] {
+	case "install":
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to find executable: %w", err)
+		}
+		return winservice.Install(exe)
+	case "uninstall":
+		return winservice.Uninstall()
+	case "start":
+		return winservice.Start()
+	case "stop":
+		return winservice.Stop()
+	case "run":
+		// Services start in the system directory, so .env and the database are found next to the executable
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to find executable: %w", err)
+		}
+		if err := os.Chdir(filepath.Dir(exe)); err != nil {
+			return fmt.Errorf("failed to change to the executable's directory: %w", err)
+		}
+		return winservice.Run(runBot)
+	default:
+		return fmt.Errorf("unknown service command %q, expected install, uninstall, start, stop or run", args[0])
+	}
+}
+
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		if err := runLoadTest(os.Args[2:]); err != nil {
+			log.Fatalf("Load test failed: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "export-state" {
+		if err := runExportState(os.Args[2:]); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "import-state" {
+		if err := runImportState(os.Args[2:]); err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		if err := runTokenCommand(os.Args[2:]); err != nil {
+			log.Fatalf("Token command failed: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		if err := runServiceCommand(os.Args[2:]); err != nil {
+			log.Fatalf("Service command failed: %v", err)
+		}
+		return
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+
+	stop := make(chan struct{})
+	go func() {
+		sig := <-sigCh
+		log.Printf("Received signal %v, initiating graceful shutdown...", sig)
+		close(stop)
+	}()
+
+	os.Exit(runBot(stop))
 }